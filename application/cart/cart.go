@@ -0,0 +1,280 @@
+package cart
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/model"
+	cartrepo "github.com/muhammadheryan/e-commerce/repository/cart"
+	txrepo "github.com/muhammadheryan/e-commerce/repository/tx"
+	warehouserepo "github.com/muhammadheryan/e-commerce/repository/warehouse"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
+	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"github.com/muhammadheryan/e-commerce/utils/money"
+	"go.uber.org/zap"
+)
+
+type CartApp interface {
+	GetCart(ctx context.Context, userID uint64) (*model.CartResponse, error)
+	AddItem(ctx context.Context, userID uint64, req *model.CartItemRequest) error
+	UpdateItem(ctx context.Context, userID, productID uint64, quantity int) error
+	RemoveItem(ctx context.Context, userID, productID uint64) error
+}
+
+type cartAppImpl struct {
+	config        *config.Config
+	txRepo        txrepo.TxRepository
+	cartRepo      cartrepo.CartRepository
+	warehouseRepo warehouserepo.WarehouseRepository
+}
+
+func NewCartApp(config *config.Config, txRepo txrepo.TxRepository, cartRepo cartrepo.CartRepository, warehouseRepo warehouserepo.WarehouseRepository) CartApp {
+	return &cartAppImpl{config: config, txRepo: txRepo, cartRepo: cartRepo, warehouseRepo: warehouseRepo}
+}
+
+func (s *cartAppImpl) GetCart(ctx context.Context, userID uint64) (*model.CartResponse, error) {
+	items, err := s.cartRepo.ListItems(ctx, userID)
+	if err != nil {
+		logger.Error("[GetCart] error cartRepo.ListItems", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	// stock held by this cart is available to its owner
+	held := map[uint64]int64{}
+	if s.config.Cart.ReserveOnAdd && len(items) > 0 {
+		held, err = s.warehouseRepo.GetCartReservedQuantities(ctx, userID)
+		if err != nil {
+			logger.Error("[GetCart] error warehouseRepo.GetCartReservedQuantities", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+	}
+
+	// flag items whose stock dropped below the cart quantity since they were added
+	var subtotal float64
+	for i := range items {
+		available, err := s.warehouseRepo.GetTotalAvailableStock(ctx, uint64(items[i].ProductID))
+		if err != nil {
+			logger.Error("[GetCart] error warehouseRepo.GetTotalAvailableStock", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+		items[i].AvailableStock = available + held[uint64(items[i].ProductID)]
+		items[i].InsufficientStock = items[i].AvailableStock < int64(items[i].Quantity)
+		subtotal += items[i].Price * float64(items[i].Quantity)
+	}
+
+	return &model.CartResponse{
+		Items:    items,
+		Subtotal: money.Round(subtotal, s.config.Store.Currency),
+	}, nil
+}
+
+// AddItem adds a product to the cart, or increases its quantity when it is
+// already there. Stock is only reserved when reserve-on-add is enabled.
+func (s *cartAppImpl) AddItem(ctx context.Context, userID uint64, req *model.CartItemRequest) error {
+	exists, err := s.cartRepo.ProductExists(ctx, uint64(req.ProductID))
+	if err != nil {
+		logger.Error("[AddItem] error cartRepo.ProductExists", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if !exists {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+
+	if s.config.Cart.ReserveOnAdd {
+		return s.addItemReserved(ctx, userID, req)
+	}
+
+	if err := s.cartRepo.AddItem(ctx, userID, uint64(req.ProductID), req.Quantity); err != nil {
+		logger.Error("[AddItem] error cartRepo.AddItem", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	return nil
+}
+
+func (s *cartAppImpl) UpdateItem(ctx context.Context, userID, productID uint64, quantity int) error {
+	if quantity <= 0 {
+		return errors.SetCustomError(constant.ErrInvalidRequest)
+	}
+
+	if s.config.Cart.ReserveOnAdd {
+		return s.updateItemReserved(ctx, userID, productID, quantity)
+	}
+
+	updated, err := s.cartRepo.UpdateItem(ctx, userID, productID, quantity)
+	if err != nil {
+		logger.Error("[UpdateItem] error cartRepo.UpdateItem", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if !updated {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *cartAppImpl) RemoveItem(ctx context.Context, userID, productID uint64) error {
+	if s.config.Cart.ReserveOnAdd {
+		return s.removeItemReserved(ctx, userID, productID)
+	}
+
+	removed, err := s.cartRepo.RemoveItem(ctx, userID, productID)
+	if err != nil {
+		logger.Error("[RemoveItem] error cartRepo.RemoveItem", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if !removed {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *cartAppImpl) addItemReserved(ctx context.Context, userID uint64, req *model.CartItemRequest) error {
+	tx, err := s.txRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("[AddItem] begin tx", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = s.txRepo.RollbackTx(tx)
+		}
+	}()
+
+	cartID, err := s.cartRepo.EnsureCartTx(ctx, tx, userID)
+	if err != nil {
+		logger.Error("[AddItem] ensure cart", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+
+	quantity, err := s.cartRepo.AddItemTx(ctx, tx, cartID, uint64(req.ProductID), req.Quantity)
+	if err != nil {
+		logger.Error("[AddItem] add item", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+
+	if err := s.reserveCartItem(ctx, tx, cartID, uint64(req.ProductID), quantity); err != nil {
+		return err
+	}
+
+	if err := s.txRepo.CommitTx(tx); err != nil {
+		logger.Error("[AddItem] commit tx", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed = true
+	return nil
+}
+
+func (s *cartAppImpl) updateItemReserved(ctx context.Context, userID, productID uint64, quantity int) error {
+	tx, err := s.txRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("[UpdateItem] begin tx", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = s.txRepo.RollbackTx(tx)
+		}
+	}()
+
+	cartID, err := s.cartRepo.GetCartIDTx(ctx, tx, userID)
+	if err != nil {
+		logger.Error("[UpdateItem] get cart", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if cartID == 0 {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+
+	updated, err := s.cartRepo.UpdateItemTx(ctx, tx, cartID, productID, quantity)
+	if err != nil {
+		logger.Error("[UpdateItem] update item", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if !updated {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+
+	if err := s.reserveCartItem(ctx, tx, cartID, productID, quantity); err != nil {
+		return err
+	}
+
+	if err := s.txRepo.CommitTx(tx); err != nil {
+		logger.Error("[UpdateItem] commit tx", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed = true
+	return nil
+}
+
+func (s *cartAppImpl) removeItemReserved(ctx context.Context, userID, productID uint64) error {
+	tx, err := s.txRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("[RemoveItem] begin tx", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = s.txRepo.RollbackTx(tx)
+		}
+	}()
+
+	cartID, err := s.cartRepo.GetCartIDTx(ctx, tx, userID)
+	if err != nil {
+		logger.Error("[RemoveItem] get cart", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if cartID == 0 {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+
+	removed, err := s.cartRepo.RemoveItemTx(ctx, tx, cartID, productID)
+	if err != nil {
+		logger.Error("[RemoveItem] remove item", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if !removed {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+
+	if err := s.warehouseRepo.ReleaseCartReservationsTx(ctx, tx, cartID, productID); err != nil {
+		logger.Error("[RemoveItem] release reservations", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+
+	if err := s.txRepo.CommitTx(tx); err != nil {
+		logger.Error("[RemoveItem] commit tx", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed = true
+	return nil
+}
+
+// reserveCartItem replaces whatever the cart holds for a product with a fresh
+// reservation of quantity, restarting the TTL
+func (s *cartAppImpl) reserveCartItem(ctx context.Context, tx *sqlx.Tx, cartID, productID uint64, quantity int) error {
+	if err := s.warehouseRepo.ReleaseCartReservationsTx(ctx, tx, cartID, productID); err != nil {
+		logger.Error("[reserveCartItem] release reservations", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+
+	req := &model.ReserveRequest{
+		CartID:     cartID,
+		ProductID:  productID,
+		Quantity:   quantity,
+		ExpiresAt:  time.Now().Add(s.config.Cart.ReservationTTL),
+		Allocation: s.config.Warehouse.AllocationStrategy,
+	}
+	if err := s.warehouseRepo.ReserveStockTx(ctx, tx, req); err != nil {
+		if errors.IsType(err, constant.ErrInsufficientStock) {
+			return errors.SetCustomError(constant.ErrInsufficientStock)
+		}
+		logger.Error("[reserveCartItem] reserve stock", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	return nil
+}
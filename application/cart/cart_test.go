@@ -0,0 +1,378 @@
+package cart_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	appcart "github.com/muhammadheryan/e-commerce/application/cart"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	"github.com/muhammadheryan/e-commerce/constant"
+	cartmocks "github.com/muhammadheryan/e-commerce/mocks/repository/cart"
+	txmocks "github.com/muhammadheryan/e-commerce/mocks/repository/tx"
+	warehousemocks "github.com/muhammadheryan/e-commerce/mocks/repository/warehouse"
+	"github.com/muhammadheryan/e-commerce/model"
+	cerr "github.com/muhammadheryan/e-commerce/utils/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCartApp_Mutations(t *testing.T) {
+	type fields struct {
+		cartRepo *cartmocks.CartRepository
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		call     func(app appcart.CartApp) error
+		mockCall func(f fields)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name:   "success: add item",
+			fields: fields{cartRepo: cartmocks.NewCartRepository(t)},
+			call: func(app appcart.CartApp) error {
+				return app.AddItem(context.Background(), 1, &model.CartItemRequest{ProductID: 10, Quantity: 2})
+			},
+			mockCall: func(f fields) {
+				f.cartRepo.On("ProductExists", mock.Anything, uint64(10)).Return(true, nil).Once()
+				f.cartRepo.On("AddItem", mock.Anything, uint64(1), uint64(10), 2).Return(nil).Once()
+			},
+		},
+		{
+			name:   "error: add unknown product",
+			fields: fields{cartRepo: cartmocks.NewCartRepository(t)},
+			call: func(app appcart.CartApp) error {
+				return app.AddItem(context.Background(), 1, &model.CartItemRequest{ProductID: 99, Quantity: 1})
+			},
+			mockCall: func(f fields) {
+				f.cartRepo.On("ProductExists", mock.Anything, uint64(99)).Return(false, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name:   "error: add with repository failure",
+			fields: fields{cartRepo: cartmocks.NewCartRepository(t)},
+			call: func(app appcart.CartApp) error {
+				return app.AddItem(context.Background(), 1, &model.CartItemRequest{ProductID: 10, Quantity: 1})
+			},
+			mockCall: func(f fields) {
+				f.cartRepo.On("ProductExists", mock.Anything, uint64(10)).Return(true, nil).Once()
+				f.cartRepo.On("AddItem", mock.Anything, uint64(1), uint64(10), 1).Return(errors.New("db error")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name:   "success: update item quantity",
+			fields: fields{cartRepo: cartmocks.NewCartRepository(t)},
+			call: func(app appcart.CartApp) error {
+				return app.UpdateItem(context.Background(), 1, 10, 5)
+			},
+			mockCall: func(f fields) {
+				f.cartRepo.On("UpdateItem", mock.Anything, uint64(1), uint64(10), 5).Return(true, nil).Once()
+			},
+		},
+		{
+			name:   "error: update item not in the user's cart",
+			fields: fields{cartRepo: cartmocks.NewCartRepository(t)},
+			call: func(app appcart.CartApp) error {
+				return app.UpdateItem(context.Background(), 2, 10, 5)
+			},
+			mockCall: func(f fields) {
+				f.cartRepo.On("UpdateItem", mock.Anything, uint64(2), uint64(10), 5).Return(false, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name:   "error: update with zero quantity",
+			fields: fields{cartRepo: cartmocks.NewCartRepository(t)},
+			call: func(app appcart.CartApp) error {
+				return app.UpdateItem(context.Background(), 1, 10, 0)
+			},
+			wantErr: true,
+			errCode: constant.ErrInvalidRequest,
+		},
+		{
+			name:   "success: remove item",
+			fields: fields{cartRepo: cartmocks.NewCartRepository(t)},
+			call: func(app appcart.CartApp) error {
+				return app.RemoveItem(context.Background(), 1, 10)
+			},
+			mockCall: func(f fields) {
+				f.cartRepo.On("RemoveItem", mock.Anything, uint64(1), uint64(10)).Return(true, nil).Once()
+			},
+		},
+		{
+			name:   "error: remove item not in the user's cart",
+			fields: fields{cartRepo: cartmocks.NewCartRepository(t)},
+			call: func(app appcart.CartApp) error {
+				return app.RemoveItem(context.Background(), 1, 10)
+			},
+			mockCall: func(f fields) {
+				f.cartRepo.On("RemoveItem", mock.Anything, uint64(1), uint64(10)).Return(false, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mockCall != nil {
+				tt.mockCall(tt.fields)
+			}
+			err := tt.call(appcart.NewCartApp(&config.Config{}, nil, tt.fields.cartRepo, nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+			}
+		})
+	}
+}
+
+func TestCartApp_GetCart(t *testing.T) {
+	type fields struct {
+		cartRepo      *cartmocks.CartRepository
+		warehouseRepo *warehousemocks.WarehouseRepository
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		mockCall func(f fields)
+		want     *model.CartResponse
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: items in stock",
+			fields: fields{
+				cartRepo:      cartmocks.NewCartRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			mockCall: func(f fields) {
+				f.cartRepo.On("ListItems", mock.Anything, uint64(1)).Return([]model.CartItem{
+					{ProductID: 10, Name: "Keyboard", Price: 250000, Quantity: 2},
+					{ProductID: 11, Name: "Mouse", Price: 99999.99, Quantity: 1},
+				}, nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStock", mock.Anything, uint64(10)).Return(int64(5), nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStock", mock.Anything, uint64(11)).Return(int64(1), nil).Once()
+			},
+			want: &model.CartResponse{
+				Items: []model.CartItem{
+					{ProductID: 10, Name: "Keyboard", Price: 250000, Quantity: 2, AvailableStock: 5},
+					{ProductID: 11, Name: "Mouse", Price: 99999.99, Quantity: 1, AvailableStock: 1},
+				},
+				Subtotal: 599999.99,
+			},
+		},
+		{
+			name: "success: item whose stock dropped below the cart quantity is flagged",
+			fields: fields{
+				cartRepo:      cartmocks.NewCartRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			mockCall: func(f fields) {
+				f.cartRepo.On("ListItems", mock.Anything, uint64(1)).Return([]model.CartItem{
+					{ProductID: 10, Name: "Keyboard", Price: 250000, Quantity: 3},
+					{ProductID: 11, Name: "Mouse", Price: 100000, Quantity: 1},
+				}, nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStock", mock.Anything, uint64(10)).Return(int64(2), nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStock", mock.Anything, uint64(11)).Return(int64(0), nil).Once()
+			},
+			want: &model.CartResponse{
+				Items: []model.CartItem{
+					{ProductID: 10, Name: "Keyboard", Price: 250000, Quantity: 3, AvailableStock: 2, InsufficientStock: true},
+					{ProductID: 11, Name: "Mouse", Price: 100000, Quantity: 1, AvailableStock: 0, InsufficientStock: true},
+				},
+				Subtotal: 850000,
+			},
+		},
+		{
+			name: "error: stock lookup fails",
+			fields: fields{
+				cartRepo:      cartmocks.NewCartRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			mockCall: func(f fields) {
+				f.cartRepo.On("ListItems", mock.Anything, uint64(1)).Return([]model.CartItem{
+					{ProductID: 10, Name: "Keyboard", Price: 250000, Quantity: 1},
+				}, nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStock", mock.Anything, uint64(10)).Return(int64(0), errors.New("db error")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockCall(tt.fields)
+			got, err := appcart.NewCartApp(&config.Config{}, nil, tt.fields.cartRepo, tt.fields.warehouseRepo).GetCart(context.Background(), 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetCart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("GetCart() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCartApp_ReserveOnAdd(t *testing.T) {
+	cfg := &config.Config{
+		Cart: config.CartConfig{
+			ReserveOnAdd:   true,
+			ReservationTTL: 15 * time.Minute,
+		},
+	}
+	type fields struct {
+		txRepo        *txmocks.TxRepository
+		cartRepo      *cartmocks.CartRepository
+		warehouseRepo *warehousemocks.WarehouseRepository
+	}
+	newFields := func() fields {
+		return fields{
+			txRepo:        txmocks.NewTxRepository(t),
+			cartRepo:      cartmocks.NewCartRepository(t),
+			warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+		}
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		call     func(app appcart.CartApp) error
+		mockCall func(f fields)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name:   "success: add reserves the full cart quantity with a TTL",
+			fields: newFields(),
+			call: func(app appcart.CartApp) error {
+				return app.AddItem(context.Background(), 1, &model.CartItemRequest{ProductID: 10, Quantity: 2})
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.cartRepo.On("ProductExists", mock.Anything, uint64(10)).Return(true, nil).Once()
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.cartRepo.On("EnsureCartTx", mock.Anything, tx, uint64(1)).Return(uint64(5), nil).Once()
+				// one already in the cart, so three are held after the add
+				f.cartRepo.On("AddItemTx", mock.Anything, tx, uint64(5), uint64(10), 2).Return(3, nil).Once()
+				f.warehouseRepo.On("ReleaseCartReservationsTx", mock.Anything, tx, uint64(5), uint64(10)).Return(nil).Once()
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.MatchedBy(func(req *model.ReserveRequest) bool {
+					ttl := time.Until(req.ExpiresAt)
+					return req.CartID == 5 && req.OrderID == 0 && req.ProductID == 10 && req.Quantity == 3 &&
+						ttl > 14*time.Minute && ttl <= 15*time.Minute
+				})).Return(nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+			},
+		},
+		{
+			name:   "error: add rolls back when stock cannot be reserved",
+			fields: newFields(),
+			call: func(app appcart.CartApp) error {
+				return app.AddItem(context.Background(), 1, &model.CartItemRequest{ProductID: 10, Quantity: 5})
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.cartRepo.On("ProductExists", mock.Anything, uint64(10)).Return(true, nil).Once()
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.cartRepo.On("EnsureCartTx", mock.Anything, tx, uint64(1)).Return(uint64(5), nil).Once()
+				f.cartRepo.On("AddItemTx", mock.Anything, tx, uint64(5), uint64(10), 5).Return(5, nil).Once()
+				f.warehouseRepo.On("ReleaseCartReservationsTx", mock.Anything, tx, uint64(5), uint64(10)).Return(nil).Once()
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(cerr.SetCustomError(constant.ErrInsufficientStock)).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInsufficientStock,
+		},
+		{
+			name:   "success: update re-reserves the new quantity",
+			fields: newFields(),
+			call: func(app appcart.CartApp) error {
+				return app.UpdateItem(context.Background(), 1, 10, 1)
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.cartRepo.On("GetCartIDTx", mock.Anything, tx, uint64(1)).Return(uint64(5), nil).Once()
+				f.cartRepo.On("UpdateItemTx", mock.Anything, tx, uint64(5), uint64(10), 1).Return(true, nil).Once()
+				f.warehouseRepo.On("ReleaseCartReservationsTx", mock.Anything, tx, uint64(5), uint64(10)).Return(nil).Once()
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.MatchedBy(func(req *model.ReserveRequest) bool {
+					return req.CartID == 5 && req.Quantity == 1
+				})).Return(nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+			},
+		},
+		{
+			name:   "success: remove releases the reservation",
+			fields: newFields(),
+			call: func(app appcart.CartApp) error {
+				return app.RemoveItem(context.Background(), 1, 10)
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.cartRepo.On("GetCartIDTx", mock.Anything, tx, uint64(1)).Return(uint64(5), nil).Once()
+				f.cartRepo.On("RemoveItemTx", mock.Anything, tx, uint64(5), uint64(10)).Return(true, nil).Once()
+				f.warehouseRepo.On("ReleaseCartReservationsTx", mock.Anything, tx, uint64(5), uint64(10)).Return(nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+			},
+		},
+		{
+			name:   "error: remove from a user without a cart",
+			fields: newFields(),
+			call: func(app appcart.CartApp) error {
+				return app.RemoveItem(context.Background(), 2, 10)
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.cartRepo.On("GetCartIDTx", mock.Anything, tx, uint64(2)).Return(uint64(0), nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockCall(tt.fields)
+			err := tt.call(appcart.NewCartApp(cfg, tt.fields.txRepo, tt.fields.cartRepo, tt.fields.warehouseRepo))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package cart
+
+import (
+	"context"
+	"time"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+	txrepo "github.com/muhammadheryan/e-commerce/repository/tx"
+	warehouserepo "github.com/muhammadheryan/e-commerce/repository/warehouse"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
+	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"go.uber.org/zap"
+)
+
+// sweeperBatchSize caps how many expired cart reservations are released per scan
+const sweeperBatchSize = 100
+
+// ReservationSweeper periodically releases cart reservations past their TTL.
+// The cart items stay in the cart, they just no longer hold stock.
+type ReservationSweeper struct {
+	txRepo        txrepo.TxRepository
+	warehouseRepo warehouserepo.WarehouseRepository
+	interval      time.Duration
+}
+
+func NewReservationSweeper(txRepo txrepo.TxRepository, warehouseRepo warehouserepo.WarehouseRepository, interval time.Duration) *ReservationSweeper {
+	return &ReservationSweeper{txRepo: txRepo, warehouseRepo: warehouseRepo, interval: interval}
+}
+
+// Start runs the sweep loop in the background until ctx is canceled
+func (w *ReservationSweeper) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.RunOnce(ctx)
+			}
+		}
+	}()
+}
+
+// RunOnce releases one batch of expired cart reservations and returns how many were released
+func (w *ReservationSweeper) RunOnce(ctx context.Context) int {
+	released, _ := w.Sweep(ctx)
+	return released
+}
+
+// Sweep is RunOnce reporting failures, for running a pass on demand
+func (w *ReservationSweeper) Sweep(ctx context.Context) (int, error) {
+	tx, err := w.txRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("[ReservationSweeper] begin tx", zap.String("error", err.Error()))
+		return 0, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	released, err := w.warehouseRepo.ReleaseExpiredCartReservationsTx(ctx, tx, sweeperBatchSize)
+	if err != nil {
+		_ = w.txRepo.RollbackTx(tx)
+		logger.Error("[ReservationSweeper] release expired reservations", zap.String("error", err.Error()))
+		return 0, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	if err := w.txRepo.CommitTx(tx); err != nil {
+		logger.Error("[ReservationSweeper] commit tx", zap.String("error", err.Error()))
+		return 0, errors.SetCustomError(constant.ErrInternal)
+	}
+	if released > 0 {
+		logger.Info("[ReservationSweeper] released expired cart reservations", zap.Int("count", released))
+	}
+	return released, nil
+}
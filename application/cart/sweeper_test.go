@@ -0,0 +1,81 @@
+package cart_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	appcart "github.com/muhammadheryan/e-commerce/application/cart"
+	"github.com/muhammadheryan/e-commerce/constant"
+	txmocks "github.com/muhammadheryan/e-commerce/mocks/repository/tx"
+	warehousemocks "github.com/muhammadheryan/e-commerce/mocks/repository/warehouse"
+	cerr "github.com/muhammadheryan/e-commerce/utils/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReservationSweeper_RunOnce(t *testing.T) {
+	type fields struct {
+		txRepo        *txmocks.TxRepository
+		warehouseRepo *warehousemocks.WarehouseRepository
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		mockCall func(f fields)
+		want     int
+	}{
+		{
+			name: "success: releases expired cart reservations",
+			fields: fields{
+				txRepo:        txmocks.NewTxRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.warehouseRepo.On("ReleaseExpiredCartReservationsTx", mock.Anything, tx, mock.Anything).Return(3, nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+			},
+			want: 3,
+		},
+		{
+			name: "error: release fails and is rolled back",
+			fields: fields{
+				txRepo:        txmocks.NewTxRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.warehouseRepo.On("ReleaseExpiredCartReservationsTx", mock.Anything, tx, mock.Anything).Return(0, errors.New("db error")).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockCall(tt.fields)
+			sweeper := appcart.NewReservationSweeper(tt.fields.txRepo, tt.fields.warehouseRepo, 0)
+			if got := sweeper.RunOnce(context.Background()); got != tt.want {
+				t.Fatalf("RunOnce() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReservationSweeper_SweepReportsFailure(t *testing.T) {
+	txRepo := txmocks.NewTxRepository(t)
+	txRepo.On("BeginTx", mock.Anything).Return(nil, errors.New("db down")).Once()
+
+	sweeper := appcart.NewReservationSweeper(txRepo, warehousemocks.NewWarehouseRepository(t), 0)
+	released, err := sweeper.Sweep(context.Background())
+	var ce cerr.CustomError
+	if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[constant.ErrInternal] {
+		t.Fatalf("Sweep() error = %v, want %v", err, constant.ErrInternal)
+	}
+	if released != 0 {
+		t.Fatalf("Sweep() released = %d, want 0", released)
+	}
+}
@@ -0,0 +1,73 @@
+package order
+
+import (
+	"context"
+	"time"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+	orderrepo "github.com/muhammadheryan/e-commerce/repository/order"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
+	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"go.uber.org/zap"
+)
+
+// expirerBatchSize caps how many expired orders are canceled per scan
+const expirerBatchSize = 100
+
+// OrderExpirer periodically cancels pending orders past their expires_at.
+// It is the broker-less alternative to the RabbitMQ delayed expiration message.
+type OrderExpirer struct {
+	orderApp  OrderApp
+	orderRepo orderrepo.OrderRepository
+	interval  time.Duration
+}
+
+func NewOrderExpirer(orderApp OrderApp, orderRepo orderrepo.OrderRepository, interval time.Duration) *OrderExpirer {
+	return &OrderExpirer{orderApp: orderApp, orderRepo: orderRepo, interval: interval}
+}
+
+// Start runs the scan loop in the background until ctx is canceled
+func (e *OrderExpirer) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.RunOnce(ctx)
+			}
+		}
+	}()
+}
+
+// RunOnce cancels one batch of expired pending orders and returns how many were canceled
+func (e *OrderExpirer) RunOnce(ctx context.Context) int {
+	canceled, _ := e.Sweep(ctx)
+	return canceled
+}
+
+// Sweep is RunOnce reporting a failure to list the expired orders, for running
+// a pass on demand. An order that fails to cancel is logged and skipped.
+func (e *OrderExpirer) Sweep(ctx context.Context) (int, error) {
+	ids, err := e.orderRepo.ListExpiredPendingOrderIDs(ctx, expirerBatchSize)
+	if err != nil {
+		logger.Error("[OrderExpirer] list expired orders", zap.String("error", err.Error()))
+		return 0, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	canceled := 0
+	for _, id := range ids {
+		// same path as the MQ consumer, so status checks and stock release stay identical
+		if _, err := e.orderApp.InternalCancelOrder(ctx, id); err != nil {
+			logger.Error("[OrderExpirer] cancel order", zap.Uint64("order_id", id), zap.String("error", err.Error()))
+			continue
+		}
+		canceled++
+	}
+	if canceled > 0 {
+		logger.Info("[OrderExpirer] canceled expired orders", zap.Int("count", canceled))
+	}
+	return canceled, nil
+}
@@ -0,0 +1,88 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	apporder "github.com/muhammadheryan/e-commerce/application/order"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	"github.com/muhammadheryan/e-commerce/constant"
+	ordermocks "github.com/muhammadheryan/e-commerce/mocks/repository/order"
+	txmocks "github.com/muhammadheryan/e-commerce/mocks/repository/tx"
+	warehousemocks "github.com/muhammadheryan/e-commerce/mocks/repository/warehouse"
+	"github.com/muhammadheryan/e-commerce/model"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOrderExpirer_RunOnce(t *testing.T) {
+	type fields struct {
+		txRepo        *txmocks.TxRepository
+		orderRepo     *ordermocks.OrderRepository
+		warehouseRepo *warehousemocks.WarehouseRepository
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		mockCall func(f fields)
+		want     int
+	}{
+		{
+			name: "success: cancels expired orders and skips ones that changed status",
+			fields: fields{
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.orderRepo.On("ListExpiredPendingOrderIDs", mock.Anything, mock.Anything).Return([]uint64{1, 2}, nil).Once()
+
+				// order 1 is still pending and gets canceled
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Twice()
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					Status: constant.OrderStatusPending,
+				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return([]model.OrderItem{{ProductID: 100, Quantity: 1, UnitPrice: 1000}}, nil).Once()
+				f.warehouseRepo.On("ReleaseReservationsTx", mock.Anything, tx, uint64(1)).Return(nil).Once()
+				f.orderRepo.On("UpdateOrderStatusTx", mock.Anything, tx, uint64(1), int(constant.OrderStatusCanceled)).Return(nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+
+				// order 2 was paid in the meantime
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(2)).Return(&model.OrderDetail{
+					ID:     2,
+					Status: constant.OrderStatusCompleted,
+				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(2)).Return([]model.OrderItem{{ProductID: 100, Quantity: 1, UnitPrice: 1000}}, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			want: 1,
+		},
+		{
+			name: "error: listing expired orders fails",
+			fields: fields{
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			mockCall: func(f fields) {
+				f.orderRepo.On("ListExpiredPendingOrderIDs", mock.Anything, mock.Anything).Return(nil, errors.New("db error")).Once()
+			},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockCall(tt.fields)
+			app := apporder.NewOrderApp(&config.Config{}, tt.fields.txRepo, tt.fields.orderRepo, tt.fields.warehouseRepo, nil, nil, nil)
+			expirer := apporder.NewOrderExpirer(app, tt.fields.orderRepo, 0)
+
+			if got := expirer.RunOnce(context.Background()); got != tt.want {
+				t.Fatalf("RunOnce() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
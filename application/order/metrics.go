@@ -0,0 +1,29 @@
+package order
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	ordersCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_created_total",
+		Help: "Orders created, by initial status.",
+	}, []string{"status"})
+	ordersPaid = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orders_paid_total",
+		Help: "Orders paid.",
+	})
+	ordersCanceled = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orders_canceled_total",
+		Help: "Orders canceled, by the user or by expiration.",
+	})
+	ordersRefunded = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orders_refunded_total",
+		Help: "Paid orders refunded.",
+	})
+	stockReservationFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "stock_reservation_failures_total",
+		Help: "Order creations rejected because stock could not be reserved.",
+	})
+)
@@ -2,24 +2,39 @@ package order
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/muhammadheryan/e-commerce/cmd/config"
 	"github.com/muhammadheryan/e-commerce/constant"
 	"github.com/muhammadheryan/e-commerce/model"
+	cartrepo "github.com/muhammadheryan/e-commerce/repository/cart"
 	orderrepo "github.com/muhammadheryan/e-commerce/repository/order"
+	redisrepo "github.com/muhammadheryan/e-commerce/repository/redis"
 	txrepo "github.com/muhammadheryan/e-commerce/repository/tx"
 	warehouserepo "github.com/muhammadheryan/e-commerce/repository/warehouse"
 	"github.com/muhammadheryan/e-commerce/thirdparty/rabbitmq"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
 	"github.com/muhammadheryan/e-commerce/utils/errors"
 	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"github.com/muhammadheryan/e-commerce/utils/money"
+	validatorx "github.com/muhammadheryan/e-commerce/utils/validator"
 	"go.uber.org/zap"
 )
 
 type OrderApp interface {
 	CreateOrder(ctx context.Context, UserID uint64, req *model.OrderRequest) (*model.OrderResponse, error)
-	PayOrder(ctx context.Context, orderID uint64) error
-	CancelOrder(ctx context.Context, orderID uint64) error
+	PreviewOrder(ctx context.Context, userID uint64, req *model.OrderPreviewRequest) (*model.OrderPreviewResponse, error)
+	PayOrder(ctx context.Context, userID, orderID uint64) (*model.OrderDetail, error)
+	CancelOrder(ctx context.Context, userID, orderID uint64) (*model.OrderDetail, error)
+	InternalCancelOrder(ctx context.Context, orderID uint64) (*model.OrderDetail, error)
+	ApproveOrder(ctx context.Context, orderID uint64) (*model.OrderDetail, error)
+	RejectOrder(ctx context.Context, orderID uint64) (*model.OrderDetail, error)
+	RefundOrder(ctx context.Context, userID, orderID uint64) error
+	ListOrders(ctx context.Context, userID uint64, status *constant.OrderStatus, page, perPage int) (*model.Paginated[model.OrderSummary], error)
 }
 
 type orderAppImpl struct {
@@ -27,17 +42,56 @@ type orderAppImpl struct {
 	txRepo        txrepo.TxRepository
 	orderRepo     orderrepo.OrderRepository
 	warehouseRepo warehouserepo.WarehouseRepository
+	cartRepo      cartrepo.CartRepository
+	redisRepo     redisrepo.RedisRepository
 	publisher     *rabbitmq.Publisher
 }
 
-func NewOrderApp(config *config.Config, txRepo txrepo.TxRepository, orderRepo orderrepo.OrderRepository, warehouseRepo warehouserepo.WarehouseRepository, publisher *rabbitmq.Publisher) OrderApp {
-	return &orderAppImpl{config: config, txRepo: txRepo, orderRepo: orderRepo, warehouseRepo: warehouseRepo, publisher: publisher}
+func NewOrderApp(config *config.Config, txRepo txrepo.TxRepository, orderRepo orderrepo.OrderRepository, warehouseRepo warehouserepo.WarehouseRepository, cartRepo cartrepo.CartRepository, redisRepo redisrepo.RedisRepository, publisher *rabbitmq.Publisher) OrderApp {
+	return &orderAppImpl{config: config, txRepo: txRepo, orderRepo: orderRepo, warehouseRepo: warehouseRepo, cartRepo: cartRepo, redisRepo: redisRepo, publisher: publisher}
 }
 
 func (s *orderAppImpl) CreateOrder(ctx context.Context, UserID uint64, req *model.OrderRequest) (*model.OrderResponse, error) {
-	if len(req.Items) == 0 {
+	// items come either from the request or from the user's saved cart, never both
+	if len(req.Items) == 0 && !req.FromCart {
 		return nil, errors.SetCustomError(constant.ErrInvalidRequest)
 	}
+	if len(req.Items) > 0 && req.FromCart {
+		return nil, errors.SetCustomError(constant.ErrInvalidRequest)
+	}
+	// a product listed twice is rejected rather than merged, so a client bug
+	// never silently orders more than the user saw in a single line
+	if hasDuplicateProduct(req.Items) {
+		return nil, errors.SetCustomError(constant.ErrInvalidRequest)
+	}
+	if req.ShippingAddress != nil && req.AddressID != 0 {
+		return nil, errors.SetCustomError(constant.ErrInvalidRequest)
+	}
+	if req.ShippingAddress != nil {
+		if fieldErrs := validatorx.ValidateStructDetailed(req.ShippingAddress); fieldErrs != nil {
+			for i := range fieldErrs {
+				fieldErrs[i].Field = "shipping_address." + fieldErrs[i].Field
+			}
+			return nil, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs)
+		}
+	}
+
+	if s.config.Auth.RequireVerifiedEmail {
+		verified, err := s.orderRepo.IsUserVerified(ctx, UserID)
+		if err != nil {
+			logger.Error("[CreateOrder] check email verified", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+		if !verified {
+			return nil, errors.SetCustomError(constant.ErrEmailNotVerified)
+		}
+	}
+
+	unlock, err := s.lockProducts(ctx, UserID, req)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	tx, err := s.txRepo.BeginTx(ctx)
 	if err != nil {
@@ -51,25 +105,105 @@ func (s *orderAppImpl) CreateOrder(ctx context.Context, UserID uint64, req *mode
 		}
 	}()
 
+	items := req.Items
+	if req.FromCart {
+		items, err = s.cartRepo.ListItemsTx(ctx, tx, UserID)
+		if err != nil {
+			logger.Error("[CreateOrder] list cart items", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+		if len(items) == 0 {
+			return nil, errors.SetCustomError(constant.ErrInvalidRequest)
+		}
+
+		// stock held by the cart is handed over to the order reservation below
+		if s.config.Cart.ReserveOnAdd {
+			cartID, err := s.cartRepo.GetCartIDTx(ctx, tx, UserID)
+			if err != nil {
+				logger.Error("[CreateOrder] get cart", zap.String("error", err.Error()))
+				return nil, errors.SetCustomError(constant.ErrInternal)
+			}
+			if err := s.warehouseRepo.ReleaseCartReservationsTx(ctx, tx, cartID, 0); err != nil {
+				logger.Error("[CreateOrder] release cart reservations", zap.String("error", err.Error()))
+				return nil, errors.SetCustomError(constant.ErrInternal)
+			}
+		}
+	}
+
+	if err := s.checkOrderLimits(UserID, items); err != nil {
+		return nil, err
+	}
+
+	// resolve saved address, it must belong to the ordering user
+	shippingAddress := req.ShippingAddress
+	if req.AddressID != 0 {
+		addr, err := s.orderRepo.GetUserAddressTx(ctx, tx, uint64(req.AddressID))
+		if err != nil {
+			logger.Error("[CreateOrder] get user address", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+		if addr == nil || addr.UserID != UserID {
+			return nil, errors.SetCustomError(constant.ErrNotFound)
+		}
+		shippingAddress = &addr.ShippingAddress
+	}
+
+	// price items inside the tx, the unit price is kept on each order item so
+	// later price changes don't rewrite history
+	orderItems, subtotal, err := s.priceItemsTx(ctx, tx, items)
+	if err != nil {
+		return nil, err
+	}
+
 	// validate stock for each item
-	for _, item := range req.Items {
-		total, err := s.warehouseRepo.GetTotalAvailableStockTx(ctx, tx, item.ProductID)
+	for _, item := range items {
+		total, err := s.warehouseRepo.GetTotalAvailableStockTx(ctx, tx, uint64(item.ProductID))
 		if err != nil {
 			logger.Error("[CreateOrder] get total stock", zap.String("error", err.Error()))
 			return nil, errors.SetCustomError(constant.ErrInternal)
 		}
 		if total < int64(item.Quantity) {
-			logger.Info("[CreateOrder] insufficient stock", zap.Uint64("product_id", item.ProductID), zap.Int("need", item.Quantity), zap.Int64("available", total))
+			logger.Info("[CreateOrder] insufficient stock", zap.Uint64("product_id", uint64(item.ProductID)), zap.Int("need", item.Quantity), zap.Int64("available", total))
+			stockReservationFailures.Inc()
 			return nil, errors.SetCustomError(constant.ErrInsufficientStock)
 		}
 	}
 
+	taxAmount, grandTotal := s.calculateTax(subtotal)
+
+	// orders above the configured max value are rejected or held for review
+	status := constant.OrderStatusPending
+	if max := s.config.Order.MaxOrderValue; max > 0 && grandTotal > max {
+		if s.config.Order.HighValueAction != constant.OrderHighValueActionReview {
+			logger.Info("[CreateOrder] order value too high", zap.Uint64("user_id", UserID), zap.Float64("grand_total", grandTotal))
+			return nil, errors.SetCustomError(constant.ErrOrderValueTooHigh)
+		}
+		logger.Info("[CreateOrder] order held for review", zap.Uint64("user_id", UserID), zap.Float64("grand_total", grandTotal))
+		status = constant.OrderStatusPendingReview
+	}
+
+	// redeem voucher, if any
+	if req.VoucherCode != "" {
+		if err := s.orderRepo.UseVoucherTx(ctx, tx, req.VoucherCode); err != nil {
+			if errors.IsType(err, constant.ErrVoucherExhausted) {
+				return nil, errors.SetCustomError(constant.ErrVoucherExhausted)
+			}
+			logger.Error("[CreateOrder] use voucher", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+	}
+
 	// insert order
 	expiresAt := time.Now().Add(s.config.Order.OrderExpiration)
 	orderID, err := s.orderRepo.InsertOrderTx(ctx, tx, &model.InsertOrderTxItem{
-		UserID:    UserID,
-		Status:    constant.OrderStatusPending,
-		ExpiresAT: expiresAt,
+		UserID:      UserID,
+		Status:      status,
+		Subtotal:    subtotal,
+		TaxAmount:   taxAmount,
+		GrandTotal:  grandTotal,
+		Currency:    s.currency(),
+		VoucherCode: req.VoucherCode,
+		ExpiresAT:   expiresAt,
 	})
 	if err != nil {
 		logger.Error("[CreateOrder] insert order", zap.String("error", err.Error()))
@@ -77,21 +211,36 @@ func (s *orderAppImpl) CreateOrder(ctx context.Context, UserID uint64, req *mode
 	}
 
 	// insert items
-	if err := s.orderRepo.InsertOrderItemsTx(ctx, tx, orderID, req.Items); err != nil {
+	if err := s.orderRepo.InsertOrderItemsTx(ctx, tx, orderID, orderItems); err != nil {
+		// defensive, duplicates are rejected up front but the unique key has the last word
+		if errors.IsType(err, constant.ErrInvalidRequest) {
+			logger.Info("[CreateOrder] duplicate order item", zap.Uint64("user_id", UserID))
+			return nil, errors.SetCustomError(constant.ErrInvalidRequest)
+		}
 		logger.Error("[CreateOrder] insert items", zap.String("error", err.Error()))
 		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
 
+	// insert shipping address, if any
+	if shippingAddress != nil {
+		if err := s.orderRepo.InsertOrderAddressTx(ctx, tx, orderID, shippingAddress); err != nil {
+			logger.Error("[CreateOrder] insert address", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+	}
+
 	// reserve stock per item
-	for _, item := range req.Items {
+	for _, item := range items {
 		req := &model.ReserveRequest{
-			OrderID:   orderID,
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			ExpiresAt: expiresAt,
+			OrderID:    orderID,
+			ProductID:  uint64(item.ProductID),
+			Quantity:   item.Quantity,
+			ExpiresAt:  expiresAt,
+			Allocation: s.config.Warehouse.AllocationStrategy,
 		}
 		if err := s.warehouseRepo.ReserveStockTx(ctx, tx, req); err != nil {
-			if err.Error() == errors.SetCustomError(constant.ErrInsufficientStock).Error() {
+			stockReservationFailures.Inc()
+			if errors.IsType(err, constant.ErrInsufficientStock) {
 				return nil, errors.SetCustomError(constant.ErrInsufficientStock)
 			}
 			logger.Error("[CreateOrder] reserve stock", zap.String("error", err.Error()))
@@ -99,34 +248,202 @@ func (s *orderAppImpl) CreateOrder(ctx context.Context, UserID uint64, req *mode
 		}
 	}
 
+	// record the expiration message in the same transaction so it survives a broker outage,
+	// orders under review are not expired automatically
+	var outboxID uint64
+	if s.publisher != nil && status == constant.OrderStatusPending {
+		outboxID, err = s.orderRepo.InsertOutboxTx(ctx, tx, &model.OrderOutbox{
+			OrderID:   orderID,
+			UserID:    UserID,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			logger.Error("[CreateOrder] insert outbox", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+	}
+
+	// the cart has been turned into an order
+	if req.FromCart {
+		if err := s.cartRepo.ClearTx(ctx, tx, UserID); err != nil {
+			logger.Error("[CreateOrder] clear cart", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+	}
+
 	if err := s.txRepo.CommitTx(tx); err != nil {
 		logger.Error("[CreateOrder] commit tx", zap.String("error", err.Error()))
 		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
 	committed = true
-	// Publish order expiration message to RabbitMQ
-	if s.publisher != nil {
-		msg := rabbitmq.OrderExpirationMessage{
-			OrderID:   orderID,
-			UserID:    UserID,
-			ExpiresAt: expiresAt,
+	ordersCreated.WithLabelValues(strconv.Itoa(int(status))).Inc()
+	s.publishExpiration(ctx, outboxID, orderID, UserID, expiresAt)
+
+	return &model.OrderResponse{
+		OrderID:    model.ID(orderID),
+		Status:     status,
+		Subtotal:   subtotal,
+		TaxAmount:  taxAmount,
+		GrandTotal: grandTotal,
+		Currency:   s.currency(),
+		ExpiresAt:  expiresAt,
+		Items:      orderItems,
+
+		ShippingAddress: shippingAddress,
+	}, nil
+}
+
+// PreviewOrder quotes the totals CreateOrder would charge for the items, using
+// the same pricing and tax. Nothing is reserved or recorded, so stock isn't
+// checked and the quote may no longer hold when the order is placed.
+func (s *orderAppImpl) PreviewOrder(ctx context.Context, userID uint64, req *model.OrderPreviewRequest) (*model.OrderPreviewResponse, error) {
+	if len(req.Items) == 0 || hasDuplicateProduct(req.Items) {
+		return nil, errors.SetCustomError(constant.ErrInvalidRequest)
+	}
+	if err := s.checkOrderLimits(userID, req.Items); err != nil {
+		return nil, err
+	}
+
+	// only read from, the tx is rolled back once the items are priced
+	tx, err := s.txRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("[PreviewOrder] begin tx", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	defer func() { _ = s.txRepo.RollbackTx(tx) }()
+
+	items, subtotal, err := s.priceItemsTx(ctx, tx, req.Items)
+	if err != nil {
+		return nil, err
+	}
+	taxAmount, grandTotal := s.calculateTax(subtotal)
+
+	return &model.OrderPreviewResponse{
+		Subtotal:   subtotal,
+		TaxAmount:  taxAmount,
+		GrandTotal: grandTotal,
+		Currency:   s.currency(),
+		Items:      items,
+	}, nil
+}
+
+// priceItemsTx prices items from the product table, unit prices and the
+// subtotal are rounded to the order currency. An unknown or deleted product is
+// ErrNotFound.
+func (s *orderAppImpl) priceItemsTx(ctx context.Context, tx *sqlx.Tx, items []model.OrderItemRequest) ([]model.OrderItem, float64, error) {
+	productIDs := make([]uint64, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, uint64(item.ProductID))
+	}
+	prices, err := s.orderRepo.GetProductPricesTx(ctx, tx, productIDs)
+	if err != nil {
+		logger.Error("[priceItemsTx] get product prices", zap.String("error", err.Error()))
+		return nil, 0, errors.SetCustomError(constant.ErrInternal)
+	}
+	var subtotal float64
+	orderItems := make([]model.OrderItem, 0, len(items))
+	for _, item := range items {
+		price, ok := prices[uint64(item.ProductID)]
+		if !ok {
+			// unknown and deleted products would otherwise surface as insufficient stock
+			logger.Info("[priceItemsTx] product not found", zap.Uint64("product_id", uint64(item.ProductID)))
+			return nil, 0, errors.SetCustomError(constant.ErrNotFound)
 		}
-		if err := s.publisher.PublishOrderExpiration(msg); err != nil {
-			logger.Error("[CreateOrder] publish order expiration", zap.String("error", err.Error()))
+		price = s.roundAmount(price)
+		orderItems = append(orderItems, model.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity, UnitPrice: price})
+		subtotal += price * float64(item.Quantity)
+	}
+	return orderItems, s.roundAmount(subtotal), nil
+}
+
+// hasDuplicateProduct reports whether a product is listed more than once
+func hasDuplicateProduct(items []model.OrderItemRequest) bool {
+	seen := make(map[model.ID]bool, len(items))
+	for _, item := range items {
+		if seen[item.ProductID] {
+			return true
 		}
+		seen[item.ProductID] = true
 	}
+	return false
+}
 
-	return &model.OrderResponse{
+// publishExpiration publishes the expiration message recorded in the outbox
+// right away, when it fails the outbox relay retries it. A zero outboxID means
+// nothing was recorded.
+func (s *orderAppImpl) publishExpiration(ctx context.Context, outboxID, orderID, userID uint64, expiresAt time.Time) {
+	if outboxID == 0 {
+		return
+	}
+	msg := rabbitmq.OrderExpirationMessage{
 		OrderID:   orderID,
+		UserID:    userID,
 		ExpiresAt: expiresAt,
-	}, nil
+		RequestID: utilsContext.GetRequestID(ctx),
+	}
+	if err := s.publisher.PublishOrderExpiration(msg); err != nil {
+		logger.Error("[publishExpiration] publish order expiration, left in outbox", zap.String("error", err.Error()), zap.Uint64("order_id", orderID))
+	} else if err := s.orderRepo.MarkOutboxSent(ctx, outboxID); err != nil {
+		logger.Error("[publishExpiration] mark outbox sent", zap.String("error", err.Error()), zap.Uint64("order_id", orderID))
+	}
+}
+
+// checkOrderLimits keeps a single order from reserving an unreasonable share
+// of the stock, the limits apply to cart orders as well
+func (s *orderAppImpl) checkOrderLimits(userID uint64, items []model.OrderItemRequest) error {
+	if max := s.config.Order.MaxItems; max > 0 && len(items) > max {
+		logger.Info("[CreateOrder] too many items", zap.Uint64("user_id", userID), zap.String("field", "items"), zap.Int("count", len(items)), zap.Int("max", max))
+		return errors.SetCustomError(constant.ErrInvalidRequest)
+	}
+	if max := s.config.Order.MaxQuantityPerItem; max > 0 {
+		for i, item := range items {
+			if item.Quantity > max {
+				logger.Info("[CreateOrder] item quantity too high", zap.Uint64("user_id", userID), zap.String("field", fmt.Sprintf("items[%d].quantity", i)), zap.Uint64("product_id", uint64(item.ProductID)), zap.Int("quantity", item.Quantity), zap.Int("max", max))
+				return errors.SetCustomError(constant.ErrInvalidRequest)
+			}
+		}
+	}
+	return nil
+}
+
+// calculateTax returns the tax amount and grand total for a subtotal using the
+// configured store tax rate. With tax-inclusive pricing the subtotal already
+// contains the tax, so only the tax portion is extracted.
+func (s *orderAppImpl) calculateTax(subtotal float64) (float64, float64) {
+	rate := s.config.Store.TaxRate
+	if rate <= 0 {
+		return 0, subtotal
+	}
+	if s.config.Store.TaxInclusive {
+		tax := s.roundAmount(subtotal - subtotal/(1+rate))
+		return tax, subtotal
+	}
+	tax := s.roundAmount(subtotal * rate)
+	return tax, s.roundAmount(subtotal + tax)
+}
+
+// currency is the currency orders are placed in, recorded on each order so
+// its amounts stay auditable if the store currency changes
+func (s *orderAppImpl) currency() string {
+	if s.config.Store.Currency == "" {
+		return money.DefaultCurrency
+	}
+	return s.config.Store.Currency
 }
 
-func (s *orderAppImpl) PayOrder(ctx context.Context, orderID uint64) error {
+// roundAmount rounds a monetary amount half-up to the minor unit of the order currency
+func (s *orderAppImpl) roundAmount(v float64) float64 {
+	return money.Round(v, s.currency())
+}
+
+// PayOrder commits the reservations of a pending order of the user and marks it
+// completed. The returned detail carries the items that were paid for. An order
+// already completed is returned unchanged with AlreadyApplied set.
+func (s *orderAppImpl) PayOrder(ctx context.Context, userID, orderID uint64) (*model.OrderDetail, error) {
 	tx, err := s.txRepo.BeginTx(ctx)
 	if err != nil {
 		logger.Error("[PayOrder] begin tx", zap.String("error", err.Error()))
-		return errors.SetCustomError(constant.ErrInternal)
+		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
 	committed := false
 	defer func() {
@@ -136,42 +453,78 @@ func (s *orderAppImpl) PayOrder(ctx context.Context, orderID uint64) error {
 	}()
 
 	// get order detail and validate status and ownership
-	orderDetail, err := s.orderRepo.GetOrderDetailTx(ctx, tx, orderID)
+	orderDetail, err := s.getOrderWithItemsTx(ctx, tx, userID, orderID)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.SetCustomError(constant.ErrNotFound)
+		}
 		logger.Error("[PayOrder] get order detail", zap.String("error", err.Error()))
-		return errors.SetCustomError(constant.ErrInternal)
+		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
 
-	// verify status is pending
-	if orderDetail.Status != constant.OrderStatusPending {
-		return errors.SetCustomError(constant.ErrInvalidOrderStatus)
+	// a client retrying a pay that went through gets the same answer, paying a
+	// canceled or refunded order is still an illegal transition. Only the owner
+	// gets this far, others can't probe whether an order was paid.
+	if orderDetail.Status == constant.OrderStatusCompleted {
+		orderDetail.AlreadyApplied = true
+		return orderDetail, nil
+	}
+
+	if !constant.CanTransition(orderDetail.Status, constant.OrderStatusCompleted) {
+		return nil, errors.SetCustomError(constant.ErrInvalidOrderStatus)
+	}
+	// nothing would be committed, charging for it can only be a bug
+	if len(orderDetail.Items) == 0 {
+		logger.Error("[PayOrder] order has no items", zap.Uint64("order_id", orderID))
+		return nil, errors.SetCustomError(constant.ErrInvalidOrderStatus)
 	}
 
 	// commit reservations to decrease stock and reserved
 	if err := s.warehouseRepo.CommitReservationsTx(ctx, tx, orderID); err != nil {
 		logger.Error("[PayOrder] commit reservations", zap.String("error", err.Error()))
-		return errors.SetCustomError(constant.ErrInternal)
+		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
 
 	// update order status to completed
 	if err := s.orderRepo.UpdateOrderStatusTx(ctx, tx, orderID, int(constant.OrderStatusCompleted)); err != nil {
 		logger.Error("[PayOrder] update status", zap.String("error", err.Error()))
-		return errors.SetCustomError(constant.ErrInternal)
+		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
 
 	if err := s.txRepo.CommitTx(tx); err != nil {
 		logger.Error("[PayOrder] commit tx", zap.String("error", err.Error()))
-		return errors.SetCustomError(constant.ErrInternal)
+		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
 	committed = true
-	return nil
+	ordersPaid.Inc()
+	orderDetail.Status = constant.OrderStatusCompleted
+	return orderDetail, nil
+}
+
+// CancelOrder releases the reservations of an order of the user and marks it
+// canceled. The returned detail carries the items that were given up.
+func (s *orderAppImpl) CancelOrder(ctx context.Context, userID, orderID uint64) (*model.OrderDetail, error) {
+	return s.cancelOrder(ctx, userID, orderID, false)
 }
 
-func (s *orderAppImpl) CancelOrder(ctx context.Context, orderID uint64) error {
+// InternalCancelOrder is CancelOrder for expirations, whoever the order belongs to
+func (s *orderAppImpl) InternalCancelOrder(ctx context.Context, orderID uint64) (*model.OrderDetail, error) {
+	return s.cancelOrder(ctx, anyUser, orderID, false)
+}
+
+// RejectOrder cancels an order held for review, its reservations are released.
+// Any other order is ErrInvalidOrderStatus, including one already canceled:
+// whether it was rejected or canceled by its owner isn't recorded.
+func (s *orderAppImpl) RejectOrder(ctx context.Context, orderID uint64) (*model.OrderDetail, error) {
+	return s.cancelOrder(ctx, anyUser, orderID, true)
+}
+
+// cancelOrder cancels an order of the user, reviewOnly limits it to orders held for review
+func (s *orderAppImpl) cancelOrder(ctx context.Context, userID, orderID uint64, reviewOnly bool) (*model.OrderDetail, error) {
 	tx, err := s.txRepo.BeginTx(ctx)
 	if err != nil {
 		logger.Error("[CancelOrder] begin tx", zap.String("error", err.Error()))
-		return errors.SetCustomError(constant.ErrInternal)
+		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
 	committed := false
 	defer func() {
@@ -181,33 +534,234 @@ func (s *orderAppImpl) CancelOrder(ctx context.Context, orderID uint64) error {
 	}()
 
 	// get order detail and validate status and ownership
-	orderDetail, err := s.orderRepo.GetOrderDetailTx(ctx, tx, orderID)
+	orderDetail, err := s.getOrderWithItemsTx(ctx, tx, userID, orderID)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.SetCustomError(constant.ErrNotFound)
+		}
 		logger.Error("[CancelOrder] get order detail", zap.String("error", err.Error()))
-		return errors.SetCustomError(constant.ErrInternal)
+		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
 
-	// verify status is pending
-	if orderDetail.Status != constant.OrderStatusPending {
-		return errors.SetCustomError(constant.ErrInvalidOrderStatus)
+	// checked first, a canceled order may never have been held for review
+	if reviewOnly && orderDetail.Status != constant.OrderStatusPendingReview {
+		return nil, errors.SetCustomError(constant.ErrInvalidOrderStatus)
+	}
+
+	// canceling twice is a no-op so redelivered expiration messages are
+	// harmless, like for a pay only the owner is told so
+	if orderDetail.Status == constant.OrderStatusCanceled {
+		orderDetail.AlreadyApplied = true
+		return orderDetail, nil
+	}
+	if !constant.CanTransition(orderDetail.Status, constant.OrderStatusCanceled) {
+		return nil, errors.SetCustomError(constant.ErrInvalidOrderStatus)
 	}
 
 	// release reservations to decrease reserved only
 	if err := s.warehouseRepo.ReleaseReservationsTx(ctx, tx, orderID); err != nil {
 		logger.Error("[CancelOrder] release reservations", zap.String("error", err.Error()))
-		return errors.SetCustomError(constant.ErrInternal)
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	// give the voucher usage back so it can be redeemed again
+	if orderDetail.VoucherCode != "" {
+		if err := s.orderRepo.ReleaseVoucherTx(ctx, tx, orderDetail.VoucherCode); err != nil {
+			logger.Error("[CancelOrder] release voucher", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
 	}
 
 	// update order status to canceled
 	if err := s.orderRepo.UpdateOrderStatusTx(ctx, tx, orderID, int(constant.OrderStatusCanceled)); err != nil {
 		logger.Error("[CancelOrder] update status", zap.String("error", err.Error()))
-		return errors.SetCustomError(constant.ErrInternal)
+		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
 
 	if err := s.txRepo.CommitTx(tx); err != nil {
 		logger.Error("[CancelOrder] commit tx", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	committed = true
+	ordersCanceled.Inc()
+	orderDetail.Status = constant.OrderStatusCanceled
+	return orderDetail, nil
+}
+
+// ApproveOrder releases an order held for review to pending. Its reservations
+// are kept, the order gets a full expiration window from now and its
+// expiration is scheduled like for a new order. Approving it again is a no-op
+// reported with AlreadyApplied.
+func (s *orderAppImpl) ApproveOrder(ctx context.Context, orderID uint64) (*model.OrderDetail, error) {
+	tx, err := s.txRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("[ApproveOrder] begin tx", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = s.txRepo.RollbackTx(tx)
+		}
+	}()
+
+	orderDetail, err := s.getOrderWithItemsTx(ctx, tx, anyUser, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.SetCustomError(constant.ErrNotFound)
+		}
+		logger.Error("[ApproveOrder] get order detail", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	if orderDetail.Status == constant.OrderStatusPending {
+		orderDetail.AlreadyApplied = true
+		return orderDetail, nil
+	}
+	if !constant.CanTransition(orderDetail.Status, constant.OrderStatusPending) {
+		return nil, errors.SetCustomError(constant.ErrInvalidOrderStatus)
+	}
+
+	// the review may have outlasted the original deadline, the buyer gets
+	// the whole window to pay from the approval on
+	expiresAt := time.Now().Add(s.config.Order.OrderExpiration)
+	if err := s.orderRepo.UpdateOrderExpiresAtTx(ctx, tx, orderID, expiresAt); err != nil {
+		logger.Error("[ApproveOrder] update expires at", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	if err := s.orderRepo.UpdateOrderStatusTx(ctx, tx, orderID, int(constant.OrderStatusPending)); err != nil {
+		logger.Error("[ApproveOrder] update status", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	var outboxID uint64
+	if s.publisher != nil {
+		outboxID, err = s.orderRepo.InsertOutboxTx(ctx, tx, &model.OrderOutbox{
+			OrderID:   orderID,
+			UserID:    orderDetail.UserID,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			logger.Error("[ApproveOrder] insert outbox", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+	}
+
+	if err := s.txRepo.CommitTx(tx); err != nil {
+		logger.Error("[ApproveOrder] commit tx", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	committed = true
+	s.publishExpiration(ctx, outboxID, orderID, orderDetail.UserID, expiresAt)
+
+	orderDetail.Status = constant.OrderStatusPending
+	return orderDetail, nil
+}
+
+// anyUser skips the ownership check of getOrderWithItemsTx, user ids start at 1
+const anyUser uint64 = 0
+
+// getOrderWithItemsTx loads an order of the user together with its line items.
+// An order of another user is sql.ErrNoRows like a missing one, so callers
+// can't tell the two apart.
+func (s *orderAppImpl) getOrderWithItemsTx(ctx context.Context, tx *sqlx.Tx, userID, orderID uint64) (*model.OrderDetail, error) {
+	orderDetail, err := s.orderRepo.GetOrderDetailTx(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if userID != anyUser && orderDetail.UserID != userID {
+		return nil, sql.ErrNoRows
+	}
+	orderDetail.Items, err = s.orderRepo.GetOrderItemsTx(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return orderDetail, nil
+}
+
+// RefundOrder reverses a paid order of the user, the committed quantities go back
+// to the warehouses they were fulfilled from
+func (s *orderAppImpl) RefundOrder(ctx context.Context, userID, orderID uint64) error {
+	tx, err := s.txRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("[RefundOrder] begin tx", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = s.txRepo.RollbackTx(tx)
+		}
+	}()
+
+	orderDetail, err := s.orderRepo.GetOrderDetailTx(ctx, tx, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.SetCustomError(constant.ErrNotFound)
+		}
+		logger.Error("[RefundOrder] get order detail", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	// orders of other users are reported as not found
+	if orderDetail.UserID != userID {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+
+	if !constant.CanTransition(orderDetail.Status, constant.OrderStatusRefunded) {
+		return errors.SetCustomError(constant.ErrInvalidOrderStatus)
+	}
+
+	fulfillment, err := s.warehouseRepo.GetFulfillmentByOrderTx(ctx, tx, orderID)
+	if err != nil {
+		logger.Error("[RefundOrder] get fulfillment", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	// orders paid before origins were recorded cannot be restocked accurately
+	if len(fulfillment) == 0 {
+		logger.Error("[RefundOrder] no fulfillment recorded", zap.Uint64("order_id", orderID))
+		return errors.SetCustomError(constant.ErrInvalidOrderStatus)
+	}
+
+	if err := s.warehouseRepo.RestockFulfillmentTx(ctx, tx, fulfillment); err != nil {
+		logger.Error("[RefundOrder] restock", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+
+	if err := s.orderRepo.UpdateOrderStatusTx(ctx, tx, orderID, int(constant.OrderStatusRefunded)); err != nil {
+		logger.Error("[RefundOrder] update status", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+
+	if err := s.txRepo.CommitTx(tx); err != nil {
+		logger.Error("[RefundOrder] commit tx", zap.String("error", err.Error()))
 		return errors.SetCustomError(constant.ErrInternal)
 	}
 	committed = true
+	ordersRefunded.Inc()
 	return nil
 }
+
+func (s *orderAppImpl) ListOrders(ctx context.Context, userID uint64, status *constant.OrderStatus, page, perPage int) (*model.Paginated[model.OrderSummary], error) {
+	if page <= 0 {
+		page = 1
+	}
+	if perPage <= 0 {
+		perPage = 10
+	}
+	perPage = s.config.Server.ClampPerPage(perPage)
+
+	filter := model.OrderListFilter{UserID: userID, Status: status}
+	items, total, err := s.orderRepo.ListOrdersByUser(ctx, filter, page, perPage)
+	if err != nil {
+		logger.Error("[ListOrders] error orderRepo.ListOrdersByUser", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	return &model.Paginated[model.OrderSummary]{
+		Items:      items,
+		TotalCount: total,
+		Page:       page,
+		PerPage:    perPage,
+		PageMeta:   model.NewPageMeta(page, perPage, total),
+	}, nil
+}
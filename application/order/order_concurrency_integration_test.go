@@ -0,0 +1,155 @@
+//go:build integration
+
+package order_test
+
+// Run against a migrated MySQL, for example the docker-compose one:
+//
+//	TEST_DB_DSN='root:root@tcp(127.0.0.1:3306)/e-commerce?parseTime=true' make test-integration
+//
+// The test is skipped when TEST_DB_DSN is not set.
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	apporder "github.com/muhammadheryan/e-commerce/application/order"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/model"
+	orderrepo "github.com/muhammadheryan/e-commerce/repository/order"
+	txrepo "github.com/muhammadheryan/e-commerce/repository/tx"
+	warehouserepo "github.com/muhammadheryan/e-commerce/repository/warehouse"
+	cerr "github.com/muhammadheryan/e-commerce/utils/errors"
+)
+
+// TestCreateOrder_ConcurrentNoOversell races many buyers for a product with less
+// stock than they ask for. Whatever the interleaving, reservations must never
+// exceed the physical stock of any warehouse.
+func TestCreateOrder_ConcurrentNoOversell(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set, skipping concurrency integration test")
+	}
+	db, err := sqlx.Connect("mysql", dsn)
+	if err != nil {
+		t.Fatalf("connect db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	const (
+		buyers       = 60
+		firstUserID  = uint64(990000000)
+		stockPerWH   = 7
+		warehouseNum = 3
+		totalStock   = stockPerWH * warehouseNum
+	)
+	db.SetMaxOpenConns(buyers)
+
+	mustExec := func(query string, args ...any) uint64 {
+		t.Helper()
+		res, err := db.Exec(query, args...)
+		if err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+		id, _ := res.LastInsertId()
+		return uint64(id)
+	}
+	shopID := mustExec("INSERT INTO shop (name) VALUES (?)", "test-shop-oversell")
+	productID := mustExec("INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-oversell", "", 1000)
+	for i := 0; i < warehouseNum; i++ {
+		whID := mustExec("INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "oversell-wh", constant.WarehouseStatusActive)
+		mustExec("INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, 0)", whID, productID, stockPerWH)
+	}
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM order_item WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM stock_reservation WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM `order` WHERE user_id BETWEEN ? AND ?", firstUserID, firstUserID+buyers)
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	cfg := &config.Config{Order: config.OrderConfig{OrderExpiration: time.Hour}}
+	app := apporder.NewOrderApp(cfg, txrepo.NewTxRepository(db), orderrepo.NewOrderRepository(db), warehouserepo.NewWarehouseRepository(db), nil, nil, nil)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		ordered   int
+		soldOut   int
+		otherErrs []error
+		start     = make(chan struct{})
+	)
+	for i := 0; i < buyers; i++ {
+		// mixed quantities so a request can span warehouses
+		quantity := 1 + i%2
+		userID := firstUserID + uint64(i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := app.CreateOrder(context.Background(), userID, &model.OrderRequest{
+				Items: []model.OrderItemRequest{{ProductID: model.ID(productID), Quantity: quantity}},
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			var ce cerr.CustomError
+			switch {
+			case err == nil:
+				ordered += quantity
+			case errors.As(err, &ce) && ce.ErrorCode() == constant.ErrorTypeCode[constant.ErrInsufficientStock]:
+				soldOut++
+			default:
+				otherErrs = append(otherErrs, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for _, err := range otherErrs {
+		t.Errorf("unexpected CreateOrder error: %v", err)
+	}
+	if ordered > totalStock {
+		t.Fatalf("oversold: %d units ordered, only %d in stock", ordered, totalStock)
+	}
+	if soldOut == 0 {
+		t.Fatalf("no buyer was turned away, demand should exceed the %d in stock", totalStock)
+	}
+
+	var rows []struct {
+		WarehouseID uint64 `db:"warehouse_id"`
+		Stock       int64  `db:"stock"`
+		Reserved    int64  `db:"reserved"`
+	}
+	if err := db.Select(&rows, "SELECT warehouse_id, stock, reserved FROM warehouse_stock WHERE product_id = ?", productID); err != nil {
+		t.Fatalf("read stock: %v", err)
+	}
+	var reserved int64
+	for _, r := range rows {
+		if r.Reserved > r.Stock || r.Reserved < 0 {
+			t.Errorf("warehouse %d: reserved %d outside 0..%d", r.WarehouseID, r.Reserved, r.Stock)
+		}
+		reserved += r.Reserved
+	}
+
+	var reservationRows int64
+	if err := db.Get(&reservationRows, "SELECT COALESCE(SUM(quantity), 0) FROM stock_reservation WHERE product_id = ?", productID); err != nil {
+		t.Fatalf("read reservations: %v", err)
+	}
+	if reserved != int64(ordered) || reservationRows != int64(ordered) {
+		t.Fatalf("reserved counter = %d, reservation rows = %d, want both %d (units ordered)", reserved, reservationRows, ordered)
+	}
+	// a buyer is only turned away when what is left can't cover them
+	if left := totalStock - ordered; left >= 2 {
+		t.Fatalf("%d units left unsold while buyers were turned away", left)
+	}
+}
@@ -2,7 +2,12 @@ package order_test
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -10,7 +15,9 @@ import (
 	apporder "github.com/muhammadheryan/e-commerce/application/order"
 	"github.com/muhammadheryan/e-commerce/cmd/config"
 	"github.com/muhammadheryan/e-commerce/constant"
+	cartmocks "github.com/muhammadheryan/e-commerce/mocks/repository/cart"
 	ordermocks "github.com/muhammadheryan/e-commerce/mocks/repository/order"
+	redismocks "github.com/muhammadheryan/e-commerce/mocks/repository/redis"
 	txmocks "github.com/muhammadheryan/e-commerce/mocks/repository/tx"
 	warehousemocks "github.com/muhammadheryan/e-commerce/mocks/repository/warehouse"
 	"github.com/muhammadheryan/e-commerce/model"
@@ -49,6 +56,7 @@ func TestOrderApp_CreateOrder(t *testing.T) {
 					Order: config.OrderConfig{
 						OrderExpiration: 30 * time.Minute,
 					},
+					Warehouse: config.WarehouseConfig{AllocationStrategy: constant.StockAllocationAvailable},
 				},
 				txRepo:        txmocks.NewTxRepository(t),
 				orderRepo:     ordermocks.NewOrderRepository(t),
@@ -73,30 +81,41 @@ func TestOrderApp_CreateOrder(t *testing.T) {
 
 				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
 
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 10000}, nil).Once()
+
 				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.MatchedBy(func(req *model.InsertOrderTxItem) bool {
-					return req.UserID == 1 && req.Status == constant.OrderStatusPending
+					return req.UserID == 1 && req.Status == constant.OrderStatusPending &&
+						req.Subtotal == 50000 && req.TaxAmount == 0 && req.GrandTotal == 50000
 				})).Return(uint64(1), nil).Once()
 
-				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), []model.OrderItemRequest{
-					{ProductID: 1, Quantity: 5},
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), []model.OrderItem{
+					{ProductID: 1, Quantity: 5, UnitPrice: 10000},
 				}).Return(nil).Once()
 
 				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.MatchedBy(func(req *model.ReserveRequest) bool {
-					return req.OrderID == 1 && req.ProductID == 1 && req.Quantity == 5
+					return req.OrderID == 1 && req.ProductID == 1 && req.Quantity == 5 &&
+						req.Allocation == constant.StockAllocationAvailable
 				})).Return(nil).Once()
 			},
 			want: &model.OrderResponse{
-				OrderID: 1,
+				OrderID:    1,
+				Subtotal:   50000,
+				TaxAmount:  0,
+				GrandTotal: 50000,
+				Items:      []model.OrderItem{{ProductID: 1, Quantity: 5, UnitPrice: 10000}},
 			},
 			wantErr: false,
 		},
 		{
-			name: "error: empty items",
+			name: "success: tax-exclusive pricing adds tax on top",
 			fields: fields{
 				config: &config.Config{
 					Order: config.OrderConfig{
 						OrderExpiration: 30 * time.Minute,
 					},
+					Store: config.StoreConfig{
+						TaxRate: 0.11,
+					},
 				},
 				txRepo:        txmocks.NewTxRepository(t),
 				orderRepo:     ordermocks.NewOrderRepository(t),
@@ -106,21 +125,47 @@ func TestOrderApp_CreateOrder(t *testing.T) {
 				ctx:    context.Background(),
 				userID: 1,
 				req: &model.OrderRequest{
-					Items: []model.OrderItemRequest{},
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 2},
+					},
 				},
 			},
-			mockCall: nil,
-			want:     nil,
-			wantErr:  true,
-			errCode:  constant.ErrInvalidRequest,
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
+
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 50000}, nil).Once()
+
+				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.MatchedBy(func(req *model.InsertOrderTxItem) bool {
+					return req.Subtotal == 100000 && req.TaxAmount == 11000 && req.GrandTotal == 111000
+				})).Return(uint64(1), nil).Once()
+
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
+
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil).Once()
+			},
+			want: &model.OrderResponse{
+				OrderID:    1,
+				Subtotal:   100000,
+				TaxAmount:  11000,
+				GrandTotal: 111000,
+			},
+			wantErr: false,
 		},
 		{
-			name: "error: insufficient stock",
+			name: "success: tax-inclusive pricing extracts tax from subtotal",
 			fields: fields{
 				config: &config.Config{
 					Order: config.OrderConfig{
 						OrderExpiration: 30 * time.Minute,
 					},
+					Store: config.StoreConfig{
+						TaxRate:      0.11,
+						TaxInclusive: true,
+					},
 				},
 				txRepo:        txmocks.NewTxRepository(t),
 				orderRepo:     ordermocks.NewOrderRepository(t),
@@ -131,31 +176,46 @@ func TestOrderApp_CreateOrder(t *testing.T) {
 				userID: 1,
 				req: &model.OrderRequest{
 					Items: []model.OrderItemRequest{
-						{
-							ProductID: 1,
-							Quantity:  100,
-						},
+						{ProductID: 1, Quantity: 1},
 					},
 				},
 			},
 			mockCall: func(f fields) {
 				tx := &sqlx.Tx{}
 				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
-				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
 
-				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(50), nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
+
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 111000}, nil).Once()
+
+				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.MatchedBy(func(req *model.InsertOrderTxItem) bool {
+					return req.Subtotal == 111000 && req.TaxAmount == 11000 && req.GrandTotal == 111000
+				})).Return(uint64(1), nil).Once()
+
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
+
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil).Once()
 			},
-			want:    nil,
-			wantErr: true,
-			errCode: constant.ErrInsufficientStock,
+			want: &model.OrderResponse{
+				OrderID:    1,
+				Subtotal:   111000,
+				TaxAmount:  11000,
+				GrandTotal: 111000,
+			},
+			wantErr: false,
 		},
 		{
-			name: "error: BeginTx returns error",
+			name: "success: tax rounds half-up to the currency minor unit",
 			fields: fields{
 				config: &config.Config{
 					Order: config.OrderConfig{
 						OrderExpiration: 30 * time.Minute,
 					},
+					Store: config.StoreConfig{
+						TaxRate:  0.1,
+						Currency: "JPY",
+					},
 				},
 				txRepo:        txmocks.NewTxRepository(t),
 				orderRepo:     ordermocks.NewOrderRepository(t),
@@ -166,19 +226,39 @@ func TestOrderApp_CreateOrder(t *testing.T) {
 				userID: 1,
 				req: &model.OrderRequest{
 					Items: []model.OrderItemRequest{
-						{ProductID: 1, Quantity: 5},
+						{ProductID: 1, Quantity: 1},
 					},
 				},
 			},
 			mockCall: func(f fields) {
-				f.txRepo.On("BeginTx", mock.Anything).Return(nil, errors.New("tx error")).Once()
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
+
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 1005}, nil).Once()
+
+				// 10% of 1005 is 100.5, which rounds up to a whole yen
+				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.MatchedBy(func(req *model.InsertOrderTxItem) bool {
+					return req.Subtotal == 1005 && req.TaxAmount == 101 && req.GrandTotal == 1106 && req.Currency == "JPY"
+				})).Return(uint64(1), nil).Once()
+
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
+
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil).Once()
 			},
-			want:    nil,
-			wantErr: true,
-			errCode: constant.ErrInternal,
+			want: &model.OrderResponse{
+				OrderID:    1,
+				Subtotal:   1005,
+				TaxAmount:  101,
+				GrandTotal: 1106,
+				Currency:   "JPY",
+			},
+			wantErr: false,
 		},
 		{
-			name: "error: GetTotalAvailableStockTx returns error",
+			name: "success: create order with shipping address",
 			fields: fields{
 				config: &config.Config{
 					Order: config.OrderConfig{
@@ -194,23 +274,46 @@ func TestOrderApp_CreateOrder(t *testing.T) {
 				userID: 1,
 				req: &model.OrderRequest{
 					Items: []model.OrderItemRequest{
-						{ProductID: 1, Quantity: 5},
+						{ProductID: 1, Quantity: 1},
+					},
+					ShippingAddress: &model.ShippingAddress{
+						RecipientName: "Budi",
+						Phone:         "081234567890",
+						AddressLine:   "Jl. Sudirman No. 1",
+						City:          "Jakarta",
+						Province:      "DKI Jakarta",
+						PostalCode:    "10220",
 					},
 				},
 			},
 			mockCall: func(f fields) {
 				tx := &sqlx.Tx{}
 				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
-				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
 
-				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(0), errors.New("db error")).Once()
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
+
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 10000}, nil).Once()
+
+				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.Anything).Return(uint64(1), nil).Once()
+
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
+
+				f.orderRepo.On("InsertOrderAddressTx", mock.Anything, tx, uint64(1), mock.MatchedBy(func(addr *model.ShippingAddress) bool {
+					return addr.City == "Jakarta" && addr.PostalCode == "10220"
+				})).Return(nil).Once()
+
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil).Once()
 			},
-			want:    nil,
-			wantErr: true,
-			errCode: constant.ErrInternal,
+			want: &model.OrderResponse{
+				OrderID:    1,
+				Subtotal:   10000,
+				GrandTotal: 10000,
+			},
+			wantErr: false,
 		},
 		{
-			name: "error: ReserveStockTx returns insufficient stock error",
+			name: "error: shipping address missing required fields",
 			fields: fields{
 				config: &config.Config{
 					Order: config.OrderConfig{
@@ -226,86 +329,54 @@ func TestOrderApp_CreateOrder(t *testing.T) {
 				userID: 1,
 				req: &model.OrderRequest{
 					Items: []model.OrderItemRequest{
-						{ProductID: 1, Quantity: 5},
+						{ProductID: 1, Quantity: 1},
+					},
+					ShippingAddress: &model.ShippingAddress{
+						RecipientName: "Budi",
+						AddressLine:   "Jl. Sudirman No. 1",
 					},
 				},
 			},
+			mockCall: nil,
+			want:     nil,
+			wantErr:  true,
+			errCode:  constant.ErrInvalidRequest,
+		},
+		{
+			name: "error: saved address owned by another user",
+			fields: fields{
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 1},
+					},
+					AddressID: 7,
+				},
+			},
 			mockCall: func(f fields) {
 				tx := &sqlx.Tx{}
 				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
 				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
 
-				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
-
-				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.Anything).Return(uint64(1), nil).Once()
-
-				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
-
-				insufficientStockErr := cerr.SetCustomError(constant.ErrInsufficientStock)
-				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(insufficientStockErr).Once()
+				f.orderRepo.On("GetUserAddressTx", mock.Anything, tx, uint64(7)).Return(&model.UserAddress{ID: 7, UserID: 2}, nil).Once()
 			},
 			want:    nil,
 			wantErr: true,
-			errCode: constant.ErrInsufficientStock,
+			errCode: constant.ErrNotFound,
 		},
-	}
-	for _, tt := range tests {
-		tt := tt
-		t.Run(tt.name, func(t *testing.T) {
-			if tt.mockCall != nil {
-				ttFields := tt.fields
-				tt.mockCall(ttFields)
-			}
-			// Use nil publisher since order.go now checks for nil before calling
-			app := apporder.NewOrderApp(tt.fields.config, tt.fields.txRepo, tt.fields.orderRepo, tt.fields.warehouseRepo, nil)
-
-			got, err := app.CreateOrder(tt.args.ctx, tt.args.userID, tt.args.req)
-			if (err != nil) != tt.wantErr {
-				t.Fatalf("CreateOrder() error = %v, wantErr %v", err, tt.wantErr)
-			}
-
-			if tt.wantErr {
-				var ce cerr.CustomError
-				if !errors.As(err, &ce) {
-					t.Fatalf("error type = %T, want CustomError", err)
-				}
-				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
-					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
-				}
-				return
-			}
-
-			if got.OrderID != tt.want.OrderID {
-				t.Fatalf("CreateOrder() OrderID = %v, want %v", got.OrderID, tt.want.OrderID)
-			}
-			if got.ExpiresAt.IsZero() {
-				t.Fatal("CreateOrder() ExpiresAt should not be zero")
-			}
-		})
-	}
-}
-
-func TestOrderApp_PayOrder(t *testing.T) {
-	type fields struct {
-		config        *config.Config
-		txRepo        *txmocks.TxRepository
-		orderRepo     *ordermocks.OrderRepository
-		warehouseRepo *warehousemocks.WarehouseRepository
-	}
-	type args struct {
-		ctx     context.Context
-		orderID uint64
-	}
-	tests := []struct {
-		name     string
-		fields   fields
-		args     args
-		mockCall func(f fields)
-		wantErr  bool
-		errCode  constant.ErrorType
-	}{
 		{
-			name: "success: pay order",
+			name: "error: both saved address and inline address given",
 			fields: fields{
 				config:        &config.Config{},
 				txRepo:        txmocks.NewTxRepository(t),
@@ -313,58 +384,1215 @@ func TestOrderApp_PayOrder(t *testing.T) {
 				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
 			},
 			args: args{
-				ctx:     context.Background(),
-				orderID: 1,
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 1},
+					},
+					AddressID:       7,
+					ShippingAddress: &model.ShippingAddress{},
+				},
+			},
+			mockCall: nil,
+			want:     nil,
+			wantErr:  true,
+			errCode:  constant.ErrInvalidRequest,
+		},
+		{
+			name: "success: order value at max threshold is accepted",
+			fields: fields{
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+						MaxOrderValue:   100000,
+						HighValueAction: constant.OrderHighValueActionReject,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 1},
+					},
+				},
 			},
 			mockCall: func(f fields) {
 				tx := &sqlx.Tx{}
 				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
 				f.txRepo.On("CommitTx", tx).Return(nil).Once()
 
-				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
-					ID:     1,
-					UserID: 1,
-					Status: constant.OrderStatusPending,
-				}, nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
 
-				f.warehouseRepo.On("CommitReservationsTx", mock.Anything, tx, uint64(1)).Return(nil).Once()
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 100000}, nil).Once()
+				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.MatchedBy(func(req *model.InsertOrderTxItem) bool {
+					return req.Status == constant.OrderStatusPending
+				})).Return(uint64(1), nil).Once()
 
-				f.orderRepo.On("UpdateOrderStatusTx", mock.Anything, tx, uint64(1), int(constant.OrderStatusCompleted)).Return(nil).Once()
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
+
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil).Once()
+			},
+			want: &model.OrderResponse{
+				OrderID:    1,
+				Status:     constant.OrderStatusPending,
+				Subtotal:   100000,
+				GrandTotal: 100000,
 			},
 			wantErr: false,
 		},
 		{
-			name: "error: order not found",
+			name: "error: order value above max is rejected",
 			fields: fields{
-				config:        &config.Config{},
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+						MaxOrderValue:   100000,
+						HighValueAction: constant.OrderHighValueActionReject,
+					},
+				},
 				txRepo:        txmocks.NewTxRepository(t),
 				orderRepo:     ordermocks.NewOrderRepository(t),
 				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
 			},
 			args: args{
-				ctx:     context.Background(),
-				orderID: 999,
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 1},
+					},
+				},
 			},
 			mockCall: func(f fields) {
 				tx := &sqlx.Tx{}
 				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
 				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
 
-				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(999)).Return(nil, errors.New("not found")).Once()
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
+
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 100001}, nil).Once()
 			},
+			want:    nil,
 			wantErr: true,
-			errCode: constant.ErrInternal,
+			errCode: constant.ErrOrderValueTooHigh,
 		},
 		{
-			name: "error: invalid order status (not pending)",
+			name: "success: order value at max threshold is not held in review mode",
 			fields: fields{
-				config:        &config.Config{},
-				txRepo:        txmocks.NewTxRepository(t),
-				orderRepo:     ordermocks.NewOrderRepository(t),
-				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+						MaxOrderValue:   100000,
+						HighValueAction: constant.OrderHighValueActionReview,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 1},
+					},
+				},
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
+
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 100000}, nil).Once()
+				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.MatchedBy(func(req *model.InsertOrderTxItem) bool {
+					return req.Status == constant.OrderStatusPending
+				})).Return(uint64(1), nil).Once()
+
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
+
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil).Once()
+			},
+			want: &model.OrderResponse{
+				OrderID:    1,
+				Status:     constant.OrderStatusPending,
+				Subtotal:   100000,
+				GrandTotal: 100000,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success: order value above max is held for review",
+			fields: fields{
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+						MaxOrderValue:   100000,
+						HighValueAction: constant.OrderHighValueActionReview,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 1},
+					},
+				},
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
+
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 100001}, nil).Once()
+				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.MatchedBy(func(req *model.InsertOrderTxItem) bool {
+					return req.Status == constant.OrderStatusPendingReview
+				})).Return(uint64(1), nil).Once()
+
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
+
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil).Once()
+			},
+			want: &model.OrderResponse{
+				OrderID:    1,
+				Status:     constant.OrderStatusPendingReview,
+				Subtotal:   100001,
+				GrandTotal: 100001,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error: empty items",
+			fields: fields{
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{},
+				},
+			},
+			mockCall: nil,
+			want:     nil,
+			wantErr:  true,
+			errCode:  constant.ErrInvalidRequest,
+		},
+		{
+			name: "error: insufficient stock",
+			fields: fields{
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{
+							ProductID: 1,
+							Quantity:  100,
+						},
+					},
+				},
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 10000}, nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(50), nil).Once()
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrInsufficientStock,
+		},
+		{
+			name: "error: duplicate product ids are rejected",
+			fields: fields{
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 2},
+						{ProductID: 2, Quantity: 1},
+						{ProductID: 1, Quantity: 3},
+					},
+				},
+			},
+			mockCall: func(f fields) {},
+			want:     nil,
+			wantErr:  true,
+			errCode:  constant.ErrInvalidRequest,
+		},
+		{
+			name: "error: duplicate key on order item insert is an invalid request",
+			fields: fields{
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{{ProductID: 1, Quantity: 5}},
+				},
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 10000}, nil).Once()
+				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.Anything).Return(uint64(1), nil).Once()
+				// what the repository reports when the (order_id, product_id) unique key is hit
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).
+					Return(cerr.SetCustomError(constant.ErrInvalidRequest)).Once()
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrInvalidRequest,
+		},
+		{
+			name: "error: unknown product is not found rather than out of stock",
+			fields: fields{
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 1},
+						{ProductID: 404, Quantity: 1},
+					},
+				},
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				// stock is never consulted for an order with an unknown product
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1, 404}).Return(map[uint64]float64{1: 10000}, nil).Once()
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name: "error: BeginTx returns error",
+			fields: fields{
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 5},
+					},
+				},
+			},
+			mockCall: func(f fields) {
+				f.txRepo.On("BeginTx", mock.Anything).Return(nil, errors.New("tx error")).Once()
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name: "error: GetTotalAvailableStockTx returns error",
+			fields: fields{
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 5},
+					},
+				},
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 10000}, nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(0), errors.New("db error")).Once()
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name: "error: ReserveStockTx returns insufficient stock error",
+			fields: fields{
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 5},
+					},
+				},
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
+
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 10000}, nil).Once()
+
+				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.Anything).Return(uint64(1), nil).Once()
+
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
+
+				insufficientStockErr := cerr.SetCustomError(constant.ErrInsufficientStock)
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(insufficientStockErr).Once()
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrInsufficientStock,
+		},
+		{
+			name: "error: ReserveStockTx returns wrapped insufficient stock error",
+			fields: fields{
+				config: &config.Config{
+					Order: config.OrderConfig{
+						OrderExpiration: 30 * time.Minute,
+					},
+				},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:    context.Background(),
+				userID: 1,
+				req: &model.OrderRequest{
+					Items: []model.OrderItemRequest{
+						{ProductID: 1, Quantity: 5},
+					},
+				},
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil).Once()
+
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 10000}, nil).Once()
+
+				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.Anything).Return(uint64(1), nil).Once()
+
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
+
+				// classified by type, the wrapping changes the message
+				insufficientStockErr := fmt.Errorf("reserve product 1: %w", cerr.SetCustomError(constant.ErrInsufficientStock))
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(insufficientStockErr).Once()
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrInsufficientStock,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mockCall != nil {
+				ttFields := tt.fields
+				tt.mockCall(ttFields)
+			}
+			// Use nil publisher since order.go now checks for nil before calling
+			app := apporder.NewOrderApp(tt.fields.config, tt.fields.txRepo, tt.fields.orderRepo, tt.fields.warehouseRepo, nil, nil, nil)
+
+			got, err := app.CreateOrder(tt.args.ctx, tt.args.userID, tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+
+			if got.OrderID != tt.want.OrderID {
+				t.Fatalf("CreateOrder() OrderID = %v, want %v", got.OrderID, tt.want.OrderID)
+			}
+			if tt.want.Status != 0 && got.Status != tt.want.Status {
+				t.Fatalf("CreateOrder() Status = %v, want %v", got.Status, tt.want.Status)
+			}
+			if tt.want.Items != nil && !reflect.DeepEqual(got.Items, tt.want.Items) {
+				t.Errorf("CreateOrder() items = %+v, want %+v", got.Items, tt.want.Items)
+			}
+			if tt.want.Currency != "" && got.Currency != tt.want.Currency {
+				t.Fatalf("Currency = %s, want %s", got.Currency, tt.want.Currency)
+			}
+			if got.Subtotal != tt.want.Subtotal || got.TaxAmount != tt.want.TaxAmount || got.GrandTotal != tt.want.GrandTotal {
+				t.Fatalf("CreateOrder() totals = (%v, %v, %v), want (%v, %v, %v)",
+					got.Subtotal, got.TaxAmount, got.GrandTotal, tt.want.Subtotal, tt.want.TaxAmount, tt.want.GrandTotal)
+			}
+			if got.ExpiresAt.IsZero() {
+				t.Fatal("CreateOrder() ExpiresAt should not be zero")
+			}
+		})
+	}
+}
+
+func TestOrderApp_PreviewOrder(t *testing.T) {
+	items := []model.OrderItemRequest{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}}
+	tests := []struct {
+		name     string
+		store    config.StoreConfig
+		items    []model.OrderItemRequest
+		prices   map[uint64]float64
+		priceErr error
+		noRepo   bool
+		want     *model.OrderPreviewResponse
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name:   "success: no tax by default",
+			items:  items,
+			prices: map[uint64]float64{1: 10000, 2: 5000},
+			want: &model.OrderPreviewResponse{
+				Subtotal: 25000, TaxAmount: 0, GrandTotal: 25000, Currency: "IDR",
+				Items: []model.OrderItem{{ProductID: 1, Quantity: 2, UnitPrice: 10000}, {ProductID: 2, Quantity: 1, UnitPrice: 5000}},
+			},
+		},
+		{
+			name:   "success: tax-exclusive pricing",
+			store:  config.StoreConfig{TaxRate: 0.11},
+			items:  items,
+			prices: map[uint64]float64{1: 10000, 2: 5000},
+			want: &model.OrderPreviewResponse{
+				Subtotal: 25000, TaxAmount: 2750, GrandTotal: 27750, Currency: "IDR",
+				Items: []model.OrderItem{{ProductID: 1, Quantity: 2, UnitPrice: 10000}, {ProductID: 2, Quantity: 1, UnitPrice: 5000}},
+			},
+		},
+		{
+			name:   "success: tax-inclusive pricing",
+			store:  config.StoreConfig{TaxRate: 0.11, TaxInclusive: true, Currency: "USD"},
+			items:  []model.OrderItemRequest{{ProductID: 1, Quantity: 1}},
+			prices: map[uint64]float64{1: 111},
+			want: &model.OrderPreviewResponse{
+				Subtotal: 111, TaxAmount: 11, GrandTotal: 111, Currency: "USD",
+				Items: []model.OrderItem{{ProductID: 1, Quantity: 1, UnitPrice: 111}},
+			},
+		},
+		{
+			name:    "error: unknown product",
+			items:   items,
+			prices:  map[uint64]float64{1: 10000},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name:     "error: get prices fails",
+			items:    items,
+			priceErr: errors.New("db error"),
+			wantErr:  true,
+			errCode:  constant.ErrInternal,
+		},
+		{
+			name:    "error: product listed twice",
+			items:   []model.OrderItemRequest{{ProductID: 1, Quantity: 1}, {ProductID: 1, Quantity: 2}},
+			noRepo:  true,
+			wantErr: true,
+			errCode: constant.ErrInvalidRequest,
+		},
+		{
+			name:    "error: no items",
+			noRepo:  true,
+			wantErr: true,
+			errCode: constant.ErrInvalidRequest,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			txRepo := txmocks.NewTxRepository(t)
+			orderRepo := ordermocks.NewOrderRepository(t)
+			if !tt.noRepo {
+				tx := &sqlx.Tx{}
+				productIDs := make([]uint64, 0, len(tt.items))
+				for _, item := range tt.items {
+					productIDs = append(productIDs, uint64(item.ProductID))
+				}
+				txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				txRepo.On("RollbackTx", tx).Return(nil).Once()
+				orderRepo.On("GetProductPricesTx", mock.Anything, tx, productIDs).Return(tt.prices, tt.priceErr).Once()
+			}
+
+			// warehouseRepo is nil, a preview never touches stock
+			app := apporder.NewOrderApp(&config.Config{Store: tt.store}, txRepo, orderRepo, nil, nil, nil, nil)
+			got, err := app.PreviewOrder(context.Background(), 1, &model.OrderPreviewRequest{Items: tt.items})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PreviewOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("PreviewOrder() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestOrderApp_PreviewOrder_MatchesCreateOrder places the previewed items and
+// expects the order to be charged exactly what the preview quoted
+func TestOrderApp_PreviewOrder_MatchesCreateOrder(t *testing.T) {
+	cfg := &config.Config{
+		Order: config.OrderConfig{OrderExpiration: 30 * time.Minute},
+		Store: config.StoreConfig{TaxRate: 0.11, Currency: "USD"},
+	}
+	items := []model.OrderItemRequest{{ProductID: 1, Quantity: 3}}
+	prices := map[uint64]float64{1: 19.995}
+
+	txRepo := txmocks.NewTxRepository(t)
+	orderRepo := ordermocks.NewOrderRepository(t)
+	warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+	tx := &sqlx.Tx{}
+	txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Twice()
+	txRepo.On("RollbackTx", tx).Return(nil).Once()
+	txRepo.On("CommitTx", tx).Return(nil).Once()
+	orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(prices, nil).Twice()
+	warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(10), nil).Once()
+	orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.Anything).Return(uint64(1), nil).Once()
+	orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
+	warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil).Once()
+
+	app := apporder.NewOrderApp(cfg, txRepo, orderRepo, warehouseRepo, nil, nil, nil)
+	preview, err := app.PreviewOrder(context.Background(), 1, &model.OrderPreviewRequest{Items: items})
+	if err != nil {
+		t.Fatalf("PreviewOrder() error = %v", err)
+	}
+	order, err := app.CreateOrder(context.Background(), 1, &model.OrderRequest{Items: items})
+	if err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	got := &model.OrderPreviewResponse{
+		Subtotal:   order.Subtotal,
+		TaxAmount:  order.TaxAmount,
+		GrandTotal: order.GrandTotal,
+		Currency:   order.Currency,
+		Items:      order.Items,
+	}
+	if !reflect.DeepEqual(got, preview) {
+		t.Fatalf("order totals = %+v, preview quoted %+v", got, preview)
+	}
+}
+
+func TestOrderApp_PayOrder(t *testing.T) {
+	orderItems := []model.OrderItem{{ProductID: 100, Quantity: 2, UnitPrice: 1000}}
+	type fields struct {
+		config        *config.Config
+		txRepo        *txmocks.TxRepository
+		orderRepo     *ordermocks.OrderRepository
+		warehouseRepo *warehousemocks.WarehouseRepository
+	}
+	type args struct {
+		ctx     context.Context
+		userID  uint64
+		orderID uint64
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		mockCall func(f fields)
+		wantErr  bool
+		errCode  constant.ErrorType
+		// wantAlreadyApplied is set for a pay that found the order paid already
+		wantAlreadyApplied bool
+	}{
+		{
+			name: "success: pay order",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					UserID: 1,
+					Status: constant.OrderStatusPending,
+				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+
+				f.warehouseRepo.On("CommitReservationsTx", mock.Anything, tx, uint64(1)).Return(nil).Once()
+
+				f.orderRepo.On("UpdateOrderStatusTx", mock.Anything, tx, uint64(1), int(constant.OrderStatusCompleted)).Return(nil).Once()
+			},
+			wantErr: false,
+		},
+		{
+			name: "error: order not found",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 999,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(999)).Return(nil, errors.New("not found")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name: "success: retry after pay",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					UserID: 1,
+					Status: constant.OrderStatusCompleted,
+				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+			},
+			wantAlreadyApplied: true,
+		},
+		{
+			name: "error: pay after cancel",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					UserID: 1,
+					Status: constant.OrderStatusCanceled,
+				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInvalidOrderStatus,
+		},
+		{
+			name: "error: order has no items",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					UserID: 1,
+					Status: constant.OrderStatusPending,
+				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return([]model.OrderItem{}, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInvalidOrderStatus,
+		},
+		{
+			name: "error: CommitReservationsTx returns error",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					UserID: 1,
+					Status: constant.OrderStatusPending,
+				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+
+				f.warehouseRepo.On("CommitReservationsTx", mock.Anything, tx, uint64(1)).Return(errors.New("commit error")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name: "error: order of another user",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  2,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				// reported like a missing order, its items are never read
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					UserID: 1,
+					Status: constant.OrderStatusPending,
+				}, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name: "error: retry by another user",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  2,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				// a stranger doesn't learn the order went through already
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					UserID: 1,
+					Status: constant.OrderStatusCompleted,
+				}, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name: "error: unknown order",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 999,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(999)).Return(nil, sql.ErrNoRows).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mockCall != nil {
+				ttFields := tt.fields
+				tt.mockCall(ttFields)
+			}
+			app := apporder.NewOrderApp(tt.fields.config, tt.fields.txRepo, tt.fields.orderRepo, tt.fields.warehouseRepo, nil, nil, nil)
+
+			got, err := app.PayOrder(tt.args.ctx, tt.args.userID, tt.args.orderID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PayOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (got.Status != constant.OrderStatusCompleted || !reflect.DeepEqual(got.Items, orderItems)) {
+				t.Fatalf("PayOrder() = %+v, want completed with items %+v", got, orderItems)
+			}
+			if !tt.wantErr && got.AlreadyApplied != tt.wantAlreadyApplied {
+				t.Fatalf("PayOrder() AlreadyApplied = %v, want %v", got.AlreadyApplied, tt.wantAlreadyApplied)
+			}
+
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+			}
+		})
+	}
+}
+
+func TestOrderApp_CancelOrder(t *testing.T) {
+	orderItems := []model.OrderItem{{ProductID: 100, Quantity: 2, UnitPrice: 1000}}
+	type fields struct {
+		config        *config.Config
+		txRepo        *txmocks.TxRepository
+		orderRepo     *ordermocks.OrderRepository
+		warehouseRepo *warehousemocks.WarehouseRepository
+	}
+	type args struct {
+		ctx     context.Context
+		userID  uint64
+		orderID uint64
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		mockCall func(f fields)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: cancel order",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					UserID: 1,
+					Status: constant.OrderStatusPending,
+				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+
+				f.warehouseRepo.On("ReleaseReservationsTx", mock.Anything, tx, uint64(1)).Return(nil).Once()
+
+				f.orderRepo.On("UpdateOrderStatusTx", mock.Anything, tx, uint64(1), int(constant.OrderStatusCanceled)).Return(nil).Once()
+			},
+			wantErr: false,
+		},
+		{
+			name: "success: cancel order held for review",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					UserID: 1,
+					Status: constant.OrderStatusPendingReview,
+				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+
+				f.warehouseRepo.On("ReleaseReservationsTx", mock.Anything, tx, uint64(1)).Return(nil).Once()
+
+				f.orderRepo.On("UpdateOrderStatusTx", mock.Anything, tx, uint64(1), int(constant.OrderStatusCanceled)).Return(nil).Once()
+			},
+			wantErr: false,
+		},
+		{
+			name: "success: voucher usage is given back",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:          1,
+					UserID:      1,
+					Status:      constant.OrderStatusPending,
+					VoucherCode: "PROMO10",
+				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+
+				f.warehouseRepo.On("ReleaseReservationsTx", mock.Anything, tx, uint64(1)).Return(nil).Once()
+				f.orderRepo.On("ReleaseVoucherTx", mock.Anything, tx, "PROMO10").Return(nil).Once()
+
+				f.orderRepo.On("UpdateOrderStatusTx", mock.Anything, tx, uint64(1), int(constant.OrderStatusCanceled)).Return(nil).Once()
+			},
+			wantErr: false,
+		},
+		{
+			name: "error: release voucher fails",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:          1,
+					UserID:      1,
+					Status:      constant.OrderStatusPending,
+					VoucherCode: "PROMO10",
+				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+
+				f.warehouseRepo.On("ReleaseReservationsTx", mock.Anything, tx, uint64(1)).Return(nil).Once()
+				f.orderRepo.On("ReleaseVoucherTx", mock.Anything, tx, "PROMO10").Return(errors.New("db error")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name: "error: order not found",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 999,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(999)).Return(nil, errors.New("not found")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name: "success: already canceled order is a no-op",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					UserID: 1,
+					Status: constant.OrderStatusCanceled,
+				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+			},
+			wantErr: false,
+		},
+		{
+			name: "error: invalid order status (not pending)",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
 			},
 			args: args{
 				ctx:     context.Background(),
+				userID:  1,
 				orderID: 1,
 			},
 			mockCall: func(f fields) {
@@ -377,12 +1605,13 @@ func TestOrderApp_PayOrder(t *testing.T) {
 					UserID: 1,
 					Status: constant.OrderStatusCompleted,
 				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
 			},
 			wantErr: true,
 			errCode: constant.ErrInvalidOrderStatus,
 		},
 		{
-			name: "error: CommitReservationsTx returns error",
+			name: "error: ReleaseReservationsTx returns error",
 			fields: fields{
 				config:        &config.Config{},
 				txRepo:        txmocks.NewTxRepository(t),
@@ -391,6 +1620,7 @@ func TestOrderApp_PayOrder(t *testing.T) {
 			},
 			args: args{
 				ctx:     context.Background(),
+				userID:  1,
 				orderID: 1,
 			},
 			mockCall: func(f fields) {
@@ -403,12 +1633,92 @@ func TestOrderApp_PayOrder(t *testing.T) {
 					UserID: 1,
 					Status: constant.OrderStatusPending,
 				}, nil).Once()
+				f.orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
 
-				f.warehouseRepo.On("CommitReservationsTx", mock.Anything, tx, uint64(1)).Return(errors.New("commit error")).Once()
+				f.warehouseRepo.On("ReleaseReservationsTx", mock.Anything, tx, uint64(1)).Return(errors.New("release error")).Once()
 			},
 			wantErr: true,
 			errCode: constant.ErrInternal,
 		},
+		{
+			name: "error: order of another user",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  2,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				// reported like a missing order, its items are never read
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					UserID: 1,
+					Status: constant.OrderStatusPending,
+				}, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name: "error: repeated cancel by another user",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  2,
+				orderID: 1,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				// a stranger doesn't learn the order went through already
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
+					ID:     1,
+					UserID: 1,
+					Status: constant.OrderStatusCanceled,
+				}, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name: "error: unknown order",
+			fields: fields{
+				config:        &config.Config{},
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				userID:  1,
+				orderID: 999,
+			},
+			mockCall: func(f fields) {
+				tx := &sqlx.Tx{}
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(999)).Return(nil, sql.ErrNoRows).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -417,11 +1727,290 @@ func TestOrderApp_PayOrder(t *testing.T) {
 				ttFields := tt.fields
 				tt.mockCall(ttFields)
 			}
-			app := apporder.NewOrderApp(tt.fields.config, tt.fields.txRepo, tt.fields.orderRepo, tt.fields.warehouseRepo, nil)
+			app := apporder.NewOrderApp(tt.fields.config, tt.fields.txRepo, tt.fields.orderRepo, tt.fields.warehouseRepo, nil, nil, nil)
 
-			err := app.PayOrder(tt.args.ctx, tt.args.orderID)
+			got, err := app.CancelOrder(tt.args.ctx, tt.args.userID, tt.args.orderID)
 			if (err != nil) != tt.wantErr {
-				t.Fatalf("PayOrder() error = %v, wantErr %v", err, tt.wantErr)
+				t.Fatalf("CancelOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (got.Status != constant.OrderStatusCanceled || !reflect.DeepEqual(got.Items, orderItems)) {
+				t.Fatalf("CancelOrder() = %+v, want canceled with items %+v", got, orderItems)
+			}
+
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+			}
+		})
+	}
+}
+
+func TestOrderApp_ApproveOrder(t *testing.T) {
+	cfg := &config.Config{Order: config.OrderConfig{OrderExpiration: 30 * time.Minute}}
+	orderItems := []model.OrderItem{{ProductID: 100, Quantity: 2, UnitPrice: 1000}}
+	tests := []struct {
+		name               string
+		mock               func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository, tx *sqlx.Tx)
+		wantErr            constant.ErrorType
+		wantAlreadyApplied bool
+	}{
+		{
+			name: "held order goes back to pending with a fresh deadline",
+			mock: func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository, tx *sqlx.Tx) {
+				orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{ID: 1, UserID: 7, Status: constant.OrderStatusPendingReview}, nil).Once()
+				orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+				// the expirer picks it up from this deadline on
+				orderRepo.On("UpdateOrderExpiresAtTx", mock.Anything, tx, uint64(1), mock.MatchedBy(func(at time.Time) bool {
+					return time.Until(at) > 29*time.Minute && time.Until(at) <= 30*time.Minute
+				})).Return(nil).Once()
+				orderRepo.On("UpdateOrderStatusTx", mock.Anything, tx, uint64(1), int(constant.OrderStatusPending)).Return(nil).Once()
+				txRepo.On("CommitTx", tx).Return(nil).Once()
+			},
+		},
+		{
+			name: "approving twice",
+			mock: func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository, tx *sqlx.Tx) {
+				orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{ID: 1, UserID: 7, Status: constant.OrderStatusPending}, nil).Once()
+				orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+				txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantAlreadyApplied: true,
+		},
+		{
+			name: "order not held for review",
+			mock: func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository, tx *sqlx.Tx) {
+				orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{ID: 1, UserID: 7, Status: constant.OrderStatusCanceled}, nil).Once()
+				orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+				txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantErr: constant.ErrInvalidOrderStatus,
+		},
+		{
+			name: "unknown order",
+			mock: func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository, tx *sqlx.Tx) {
+				orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(nil, sql.ErrNoRows).Once()
+				txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantErr: constant.ErrNotFound,
+		},
+		{
+			name: "status update fails",
+			mock: func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository, tx *sqlx.Tx) {
+				orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{ID: 1, UserID: 7, Status: constant.OrderStatusPendingReview}, nil).Once()
+				orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+				orderRepo.On("UpdateOrderExpiresAtTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
+				orderRepo.On("UpdateOrderStatusTx", mock.Anything, tx, uint64(1), int(constant.OrderStatusPending)).Return(errors.New("db down")).Once()
+				txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantErr: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txRepo := txmocks.NewTxRepository(t)
+			orderRepo := ordermocks.NewOrderRepository(t)
+			tx := &sqlx.Tx{}
+			txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+			tt.mock(txRepo, orderRepo, tx)
+			app := apporder.NewOrderApp(cfg, txRepo, orderRepo, warehousemocks.NewWarehouseRepository(t), nil, nil, nil)
+
+			got, err := app.ApproveOrder(context.Background(), 1)
+			if tt.wantErr != constant.Successful {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[tt.wantErr] {
+					t.Fatalf("ApproveOrder() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApproveOrder() error = %v", err)
+			}
+			if got.Status != constant.OrderStatusPending || got.AlreadyApplied != tt.wantAlreadyApplied {
+				t.Fatalf("ApproveOrder() = %+v, want pending with AlreadyApplied %v", got, tt.wantAlreadyApplied)
+			}
+		})
+	}
+}
+
+func TestOrderApp_RejectOrder(t *testing.T) {
+	orderItems := []model.OrderItem{{ProductID: 100, Quantity: 2, UnitPrice: 1000}}
+	tests := []struct {
+		name    string
+		status  constant.OrderStatus
+		mock    func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository, warehouseRepo *warehousemocks.WarehouseRepository, tx *sqlx.Tx)
+		wantErr constant.ErrorType
+	}{
+		{
+			name:   "held order is canceled and its stock released",
+			status: constant.OrderStatusPendingReview,
+			mock: func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository, warehouseRepo *warehousemocks.WarehouseRepository, tx *sqlx.Tx) {
+				warehouseRepo.On("ReleaseReservationsTx", mock.Anything, tx, uint64(1)).Return(nil).Once()
+				orderRepo.On("UpdateOrderStatusTx", mock.Anything, tx, uint64(1), int(constant.OrderStatusCanceled)).Return(nil).Once()
+				txRepo.On("CommitTx", tx).Return(nil).Once()
+			},
+		},
+		{
+			// a pending order is the buyer's to pay or cancel, not the reviewer's
+			name:   "approved order",
+			status: constant.OrderStatusPending,
+			mock: func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository, warehouseRepo *warehousemocks.WarehouseRepository, tx *sqlx.Tx) {
+				txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantErr: constant.ErrInvalidOrderStatus,
+		},
+		{
+			// never held for review, the owner canceled it
+			name:   "order the user already canceled",
+			status: constant.OrderStatusCanceled,
+			mock: func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository, warehouseRepo *warehousemocks.WarehouseRepository, tx *sqlx.Tx) {
+				txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantErr: constant.ErrInvalidOrderStatus,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txRepo := txmocks.NewTxRepository(t)
+			orderRepo := ordermocks.NewOrderRepository(t)
+			warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+			tx := &sqlx.Tx{}
+			txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+			orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{ID: 1, UserID: 7, Status: tt.status}, nil).Once()
+			orderRepo.On("GetOrderItemsTx", mock.Anything, tx, uint64(1)).Return(orderItems, nil).Once()
+			tt.mock(txRepo, orderRepo, warehouseRepo, tx)
+			app := apporder.NewOrderApp(&config.Config{}, txRepo, orderRepo, warehouseRepo, nil, nil, nil)
+
+			got, err := app.RejectOrder(context.Background(), 1)
+			if tt.wantErr != constant.Successful {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[tt.wantErr] {
+					t.Fatalf("RejectOrder() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RejectOrder() error = %v", err)
+			}
+			if got.Status != constant.OrderStatusCanceled {
+				t.Fatalf("RejectOrder() status = %d, want canceled", got.Status)
+			}
+		})
+	}
+}
+
+func TestOrderApp_RefundOrder(t *testing.T) {
+	type fields struct {
+		txRepo        *txmocks.TxRepository
+		orderRepo     *ordermocks.OrderRepository
+		warehouseRepo *warehousemocks.WarehouseRepository
+	}
+	fulfillment := []model.OrderFulfillment{
+		{OrderID: 1, WarehouseID: 10, ProductID: 100, Quantity: 2},
+		{OrderID: 1, WarehouseID: 11, ProductID: 100, Quantity: 3},
+	}
+	tests := []struct {
+		name     string
+		userID   uint64
+		mockCall func(f fields, tx *sqlx.Tx)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name:   "success: restock and mark refunded",
+			userID: 7,
+			mockCall: func(f fields, tx *sqlx.Tx) {
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{ID: 1, UserID: 7, Status: constant.OrderStatusCompleted}, nil).Once()
+				f.warehouseRepo.On("GetFulfillmentByOrderTx", mock.Anything, tx, uint64(1)).Return(fulfillment, nil).Once()
+				f.warehouseRepo.On("RestockFulfillmentTx", mock.Anything, tx, fulfillment).Return(nil).Once()
+				f.orderRepo.On("UpdateOrderStatusTx", mock.Anything, tx, uint64(1), int(constant.OrderStatusRefunded)).Return(nil).Once()
+			},
+		},
+		{
+			name:   "error: order of another user",
+			userID: 8,
+			mockCall: func(f fields, tx *sqlx.Tx) {
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{ID: 1, UserID: 7, Status: constant.OrderStatusCompleted}, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name:   "error: unknown order",
+			userID: 7,
+			mockCall: func(f fields, tx *sqlx.Tx) {
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(nil, sql.ErrNoRows).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name:   "error: unpaid order",
+			userID: 7,
+			mockCall: func(f fields, tx *sqlx.Tx) {
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{ID: 1, UserID: 7, Status: constant.OrderStatusPending}, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInvalidOrderStatus,
+		},
+		{
+			name:   "error: already refunded",
+			userID: 7,
+			mockCall: func(f fields, tx *sqlx.Tx) {
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{ID: 1, UserID: 7, Status: constant.OrderStatusRefunded}, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInvalidOrderStatus,
+		},
+		{
+			name:   "error: paid before origins were recorded",
+			userID: 7,
+			mockCall: func(f fields, tx *sqlx.Tx) {
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{ID: 1, UserID: 7, Status: constant.OrderStatusCompleted}, nil).Once()
+				f.warehouseRepo.On("GetFulfillmentByOrderTx", mock.Anything, tx, uint64(1)).Return(nil, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInvalidOrderStatus,
+		},
+		{
+			name:   "error: restock fails",
+			userID: 7,
+			mockCall: func(f fields, tx *sqlx.Tx) {
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{ID: 1, UserID: 7, Status: constant.OrderStatusCompleted}, nil).Once()
+				f.warehouseRepo.On("GetFulfillmentByOrderTx", mock.Anything, tx, uint64(1)).Return(fulfillment, nil).Once()
+				f.warehouseRepo.On("RestockFulfillmentTx", mock.Anything, tx, fulfillment).Return(errors.New("db down")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			f := fields{
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			}
+			tx := &sqlx.Tx{}
+			f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+			tt.mockCall(f, tx)
+			app := apporder.NewOrderApp(&config.Config{}, f.txRepo, f.orderRepo, f.warehouseRepo, nil, nil, nil)
+
+			err := app.RefundOrder(context.Background(), tt.userID, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RefundOrder() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
 			if tt.wantErr {
@@ -433,149 +2022,311 @@ func TestOrderApp_PayOrder(t *testing.T) {
 					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
 				}
 			}
-		})
+		})
+	}
+}
+
+func TestOrderApp_CreateOrder_ConcurrentLastVoucher(t *testing.T) {
+	txRepo := txmocks.NewTxRepository(t)
+	orderRepo := ordermocks.NewOrderRepository(t)
+	warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+	cfg := &config.Config{
+		Order: config.OrderConfig{
+			OrderExpiration: 30 * time.Minute,
+		},
+	}
+
+	// remaining mimics `used < max_uses` with a single usage left
+	var remaining int32 = 1
+	tx := &sqlx.Tx{}
+	txRepo.On("BeginTx", mock.Anything).Return(tx, nil)
+	txRepo.On("CommitTx", tx).Return(nil).Maybe()
+	txRepo.On("RollbackTx", tx).Return(nil).Maybe()
+	warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil)
+	orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 10000}, nil)
+	orderRepo.On("UseVoucherTx", mock.Anything, tx, "LAST1").Return(func(ctx context.Context, tx *sqlx.Tx, code string) error {
+		if atomic.AddInt32(&remaining, -1) < 0 {
+			return cerr.SetCustomError(constant.ErrVoucherExhausted)
+		}
+		return nil
+	})
+	// the order keeps the voucher so a cancel can give its usage back
+	orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.MatchedBy(func(req *model.InsertOrderTxItem) bool {
+		return req.VoucherCode == "LAST1"
+	})).Return(uint64(1), nil).Maybe()
+	orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Maybe()
+	warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil).Maybe()
+
+	app := apporder.NewOrderApp(cfg, txRepo, orderRepo, warehouseRepo, nil, nil, nil)
+
+	const workers = 10
+	var (
+		wg        sync.WaitGroup
+		succeeded int32
+		exhausted int32
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := app.CreateOrder(context.Background(), 1, &model.OrderRequest{
+				Items:       []model.OrderItemRequest{{ProductID: 1, Quantity: 1}},
+				VoucherCode: "LAST1",
+			})
+			if err == nil {
+				atomic.AddInt32(&succeeded, 1)
+				return
+			}
+			var ce cerr.CustomError
+			if errors.As(err, &ce) && ce.ErrorCode() == constant.ErrorTypeCode[constant.ErrVoucherExhausted] {
+				atomic.AddInt32(&exhausted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Fatalf("succeeded orders = %d, want 1", succeeded)
+	}
+	if exhausted != workers-1 {
+		t.Fatalf("voucher exhausted errors = %d, want %d", exhausted, workers-1)
 	}
 }
 
-func TestOrderApp_CancelOrder(t *testing.T) {
+func TestOrderApp_CreateOrder_FromCart(t *testing.T) {
 	type fields struct {
-		config        *config.Config
 		txRepo        *txmocks.TxRepository
 		orderRepo     *ordermocks.OrderRepository
 		warehouseRepo *warehousemocks.WarehouseRepository
-	}
-	type args struct {
-		ctx     context.Context
-		orderID uint64
+		cartRepo      *cartmocks.CartRepository
 	}
 	tests := []struct {
 		name     string
 		fields   fields
-		args     args
+		config   *config.Config
+		req      *model.OrderRequest
 		mockCall func(f fields)
+		want     *model.OrderResponse
 		wantErr  bool
 		errCode  constant.ErrorType
 	}{
 		{
-			name: "success: cancel order",
+			name: "success: cart items are ordered and the cart is cleared",
 			fields: fields{
-				config:        &config.Config{},
 				txRepo:        txmocks.NewTxRepository(t),
 				orderRepo:     ordermocks.NewOrderRepository(t),
 				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+				cartRepo:      cartmocks.NewCartRepository(t),
 			},
-			args: args{
-				ctx:     context.Background(),
-				orderID: 1,
-			},
+			req: &model.OrderRequest{FromCart: true},
 			mockCall: func(f fields) {
 				tx := &sqlx.Tx{}
+				cartItems := []model.OrderItemRequest{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}}
 				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.cartRepo.On("ListItemsTx", mock.Anything, tx, uint64(1)).Return(cartItems, nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(10), nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(2)).Return(int64(10), nil).Once()
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1, 2}).Return(map[uint64]float64{1: 10000, 2: 5000}, nil).Once()
+				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.MatchedBy(func(req *model.InsertOrderTxItem) bool {
+					return req.UserID == 1 && req.Subtotal == 25000
+				})).Return(uint64(7), nil).Once()
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(7), []model.OrderItem{
+					{ProductID: 1, Quantity: 2, UnitPrice: 10000},
+					{ProductID: 2, Quantity: 1, UnitPrice: 5000},
+				}).Return(nil).Once()
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil).Twice()
+				f.cartRepo.On("ClearTx", mock.Anything, tx, uint64(1)).Return(nil).Once()
 				f.txRepo.On("CommitTx", tx).Return(nil).Once()
-
-				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
-					ID:     1,
-					UserID: 1,
-					Status: constant.OrderStatusPending,
-				}, nil).Once()
-
-				f.warehouseRepo.On("ReleaseReservationsTx", mock.Anything, tx, uint64(1)).Return(nil).Once()
-
-				f.orderRepo.On("UpdateOrderStatusTx", mock.Anything, tx, uint64(1), int(constant.OrderStatusCanceled)).Return(nil).Once()
 			},
-			wantErr: false,
+			want: &model.OrderResponse{OrderID: 7, Subtotal: 25000, GrandTotal: 25000},
 		},
 		{
-			name: "error: order not found",
+			name: "success: reserve-on-add hands cart reservations over to the order",
 			fields: fields{
-				config:        &config.Config{},
 				txRepo:        txmocks.NewTxRepository(t),
 				orderRepo:     ordermocks.NewOrderRepository(t),
 				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+				cartRepo:      cartmocks.NewCartRepository(t),
 			},
-			args: args{
-				ctx:     context.Background(),
-				orderID: 999,
+			config: &config.Config{
+				Order: config.OrderConfig{OrderExpiration: 30 * time.Minute},
+				Cart:  config.CartConfig{ReserveOnAdd: true},
 			},
+			req: &model.OrderRequest{FromCart: true},
 			mockCall: func(f fields) {
 				tx := &sqlx.Tx{}
+				cartItems := []model.OrderItemRequest{{ProductID: 1, Quantity: 2}}
 				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
-				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
-
-				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(999)).Return(nil, errors.New("not found")).Once()
+				f.cartRepo.On("ListItemsTx", mock.Anything, tx, uint64(1)).Return(cartItems, nil).Once()
+				f.cartRepo.On("GetCartIDTx", mock.Anything, tx, uint64(1)).Return(uint64(5), nil).Once()
+				f.warehouseRepo.On("ReleaseCartReservationsTx", mock.Anything, tx, uint64(5), uint64(0)).Return(nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(2), nil).Once()
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 10000}, nil).Once()
+				f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.Anything).Return(uint64(8), nil).Once()
+				f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(8), []model.OrderItem{{ProductID: 1, Quantity: 2, UnitPrice: 10000}}).Return(nil).Once()
+				f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.MatchedBy(func(req *model.ReserveRequest) bool {
+					return req.OrderID == 8 && req.CartID == 0 && req.Quantity == 2
+				})).Return(nil).Once()
+				f.cartRepo.On("ClearTx", mock.Anything, tx, uint64(1)).Return(nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
 			},
-			wantErr: true,
-			errCode: constant.ErrInternal,
+			want: &model.OrderResponse{OrderID: 8, Subtotal: 20000, GrandTotal: 20000},
 		},
 		{
-			name: "error: invalid order status (not pending)",
+			name: "error: empty cart",
 			fields: fields{
-				config:        &config.Config{},
 				txRepo:        txmocks.NewTxRepository(t),
 				orderRepo:     ordermocks.NewOrderRepository(t),
 				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+				cartRepo:      cartmocks.NewCartRepository(t),
 			},
-			args: args{
-				ctx:     context.Background(),
-				orderID: 1,
-			},
+			req: &model.OrderRequest{FromCart: true},
 			mockCall: func(f fields) {
 				tx := &sqlx.Tx{}
 				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.cartRepo.On("ListItemsTx", mock.Anything, tx, uint64(1)).Return([]model.OrderItemRequest{}, nil).Once()
 				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
-
-				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
-					ID:     1,
-					UserID: 1,
-					Status: constant.OrderStatusCompleted,
-				}, nil).Once()
 			},
 			wantErr: true,
-			errCode: constant.ErrInvalidOrderStatus,
+			errCode: constant.ErrInvalidRequest,
 		},
 		{
-			name: "error: ReleaseReservationsTx returns error",
+			name: "error: items and from_cart together",
 			fields: fields{
-				config:        &config.Config{},
 				txRepo:        txmocks.NewTxRepository(t),
 				orderRepo:     ordermocks.NewOrderRepository(t),
 				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+				cartRepo:      cartmocks.NewCartRepository(t),
 			},
-			args: args{
-				ctx:     context.Background(),
-				orderID: 1,
+			req: &model.OrderRequest{
+				FromCart: true,
+				Items:    []model.OrderItemRequest{{ProductID: 1, Quantity: 1}},
+			},
+			wantErr: true,
+			errCode: constant.ErrInvalidRequest,
+		},
+		{
+			name: "error: cart is kept when stock is insufficient",
+			fields: fields{
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+				cartRepo:      cartmocks.NewCartRepository(t),
 			},
+			req: &model.OrderRequest{FromCart: true},
 			mockCall: func(f fields) {
 				tx := &sqlx.Tx{}
 				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.cartRepo.On("ListItemsTx", mock.Anything, tx, uint64(1)).Return([]model.OrderItemRequest{{ProductID: 1, Quantity: 5}}, nil).Once()
+				f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 10000}, nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(2), nil).Once()
 				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
-
-				f.orderRepo.On("GetOrderDetailTx", mock.Anything, tx, uint64(1)).Return(&model.OrderDetail{
-					ID:     1,
-					UserID: 1,
-					Status: constant.OrderStatusPending,
-				}, nil).Once()
-
-				f.warehouseRepo.On("ReleaseReservationsTx", mock.Anything, tx, uint64(1)).Return(errors.New("release error")).Once()
 			},
 			wantErr: true,
-			errCode: constant.ErrInternal,
+			errCode: constant.ErrInsufficientStock,
 		},
 	}
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			if tt.mockCall != nil {
-				ttFields := tt.fields
-				tt.mockCall(ttFields)
+				tt.mockCall(tt.fields)
+			}
+			cfg := tt.config
+			if cfg == nil {
+				cfg = &config.Config{Order: config.OrderConfig{OrderExpiration: 30 * time.Minute}}
 			}
-			app := apporder.NewOrderApp(tt.fields.config, tt.fields.txRepo, tt.fields.orderRepo, tt.fields.warehouseRepo, nil)
+			app := apporder.NewOrderApp(cfg, tt.fields.txRepo, tt.fields.orderRepo, tt.fields.warehouseRepo, tt.fields.cartRepo, nil, nil)
 
-			err := app.CancelOrder(tt.args.ctx, tt.args.orderID)
+			got, err := app.CreateOrder(context.Background(), 1, tt.req)
 			if (err != nil) != tt.wantErr {
-				t.Fatalf("CancelOrder() error = %v, wantErr %v", err, tt.wantErr)
+				t.Fatalf("CreateOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+
+			if got.OrderID != tt.want.OrderID || got.Subtotal != tt.want.Subtotal || got.GrandTotal != tt.want.GrandTotal {
+				t.Fatalf("CreateOrder() = %+v, want %+v", got, tt.want)
 			}
+		})
+	}
+}
+
+func TestOrderApp_ListOrders(t *testing.T) {
+	pending := constant.OrderStatusPending
+	type args struct {
+		userID  uint64
+		status  *constant.OrderStatus
+		page    int
+		perPage int
+	}
+	tests := []struct {
+		name     string
+		args     args
+		mockCall func(orderRepo *ordermocks.OrderRepository)
+		want     *model.Paginated[model.OrderSummary]
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: filter by status keeps repo total",
+			args: args{userID: 7, status: &pending, page: 2, perPage: 2},
+			mockCall: func(orderRepo *ordermocks.OrderRepository) {
+				orderRepo.On("ListOrdersByUser", mock.Anything, model.OrderListFilter{UserID: 7, Status: &pending}, 2, 2).
+					Return([]model.OrderSummary{{ID: 3, Status: pending}}, int64(3), nil).Once()
+			},
+			want: &model.Paginated[model.OrderSummary]{
+				Items:      []model.OrderSummary{{ID: 3, Status: pending}},
+				TotalCount: 3,
+				Page:       2,
+				PerPage:    2,
+				PageMeta:   model.PageMeta{TotalPages: 2, HasPrev: true},
+			},
+		},
+		{
+			name: "success: defaults page and per_page",
+			args: args{userID: 7},
+			mockCall: func(orderRepo *ordermocks.OrderRepository) {
+				orderRepo.On("ListOrdersByUser", mock.Anything, model.OrderListFilter{UserID: 7}, 1, 10).
+					Return([]model.OrderSummary{}, int64(0), nil).Once()
+			},
+			want: &model.Paginated[model.OrderSummary]{
+				Items:   []model.OrderSummary{},
+				Page:    1,
+				PerPage: 10,
+			},
+		},
+		{
+			name: "error: repo failure",
+			args: args{userID: 7, page: 1, perPage: 10},
+			mockCall: func(orderRepo *ordermocks.OrderRepository) {
+				orderRepo.On("ListOrdersByUser", mock.Anything, model.OrderListFilter{UserID: 7}, 1, 10).
+					Return(nil, int64(0), errors.New("db down")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			orderRepo := ordermocks.NewOrderRepository(t)
+			tt.mockCall(orderRepo)
+			app := apporder.NewOrderApp(&config.Config{}, txmocks.NewTxRepository(t), orderRepo, warehousemocks.NewWarehouseRepository(t), nil, nil, nil)
 
+			got, err := app.ListOrders(context.Background(), tt.args.userID, tt.args.status, tt.args.page, tt.args.perPage)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListOrders() error = %v, wantErr %v", err, tt.wantErr)
+			}
 			if tt.wantErr {
 				var ce cerr.CustomError
 				if !errors.As(err, &ce) {
@@ -584,7 +2335,370 @@ func TestOrderApp_CancelOrder(t *testing.T) {
 				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
 					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
 				}
+				return
+			}
+			if got.TotalCount != tt.want.TotalCount || got.Page != tt.want.Page || got.PerPage != tt.want.PerPage || got.PageMeta != tt.want.PageMeta || len(got.Items) != len(tt.want.Items) {
+				t.Fatalf("ListOrders() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderApp_ListOrders_MaxPerPage(t *testing.T) {
+	orderRepo := ordermocks.NewOrderRepository(t)
+	orderRepo.On("ListOrdersByUser", mock.Anything, model.OrderListFilter{UserID: 7}, 1, 100).
+		Return([]model.OrderSummary{}, int64(0), nil).Once()
+	cfg := &config.Config{Server: config.ServerConfig{MaxPerPage: 100}}
+	app := apporder.NewOrderApp(cfg, txmocks.NewTxRepository(t), orderRepo, warehousemocks.NewWarehouseRepository(t), nil, nil, nil)
+
+	got, err := app.ListOrders(context.Background(), 7, nil, 1, 100000)
+	if err != nil {
+		t.Fatalf("ListOrders() error = %v", err)
+	}
+	if got.PerPage != 100 {
+		t.Fatalf("PerPage = %d, want 100", got.PerPage)
+	}
+}
+
+func TestOrderApp_CreateOrder_Limits(t *testing.T) {
+	cfg := &config.Config{
+		Order: config.OrderConfig{
+			OrderExpiration:    30 * time.Minute,
+			MaxItems:           2,
+			MaxQuantityPerItem: 10,
+		},
+	}
+	tests := []struct {
+		name  string
+		items []model.OrderItemRequest
+	}{
+		{"too many line items", []model.OrderItemRequest{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 1}, {ProductID: 3, Quantity: 1}}},
+		{"quantity above the per item limit", []model.OrderItemRequest{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 11}}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			txRepo := txmocks.NewTxRepository(t)
+			tx := &sqlx.Tx{}
+			txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+			txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+			// no repository call is expected past the limit check
+			app := apporder.NewOrderApp(cfg, txRepo, ordermocks.NewOrderRepository(t), warehousemocks.NewWarehouseRepository(t), nil, nil, nil)
+			_, err := app.CreateOrder(context.Background(), 1, &model.OrderRequest{Items: tt.items})
+			var ce cerr.CustomError
+			if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[constant.ErrInvalidRequest] {
+				t.Fatalf("CreateOrder() error = %v, want ErrInvalidRequest", err)
+			}
+		})
+	}
+
+	t.Run("at the limits is accepted", func(t *testing.T) {
+		txRepo := txmocks.NewTxRepository(t)
+		orderRepo := ordermocks.NewOrderRepository(t)
+		warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+		tx := &sqlx.Tx{}
+		txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+		txRepo.On("CommitTx", tx).Return(nil).Once()
+		orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1, 2}).Return(map[uint64]float64{1: 1000, 2: 1000}, nil).Once()
+		warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, mock.Anything).Return(int64(100), nil).Twice()
+		orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.Anything).Return(uint64(1), nil).Once()
+		orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Once()
+		warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil).Twice()
+
+		app := apporder.NewOrderApp(cfg, txRepo, orderRepo, warehouseRepo, nil, nil, nil)
+		_, err := app.CreateOrder(context.Background(), 1, &model.OrderRequest{Items: []model.OrderItemRequest{{ProductID: 1, Quantity: 10}, {ProductID: 2, Quantity: 10}}})
+		if err != nil {
+			t.Fatalf("CreateOrder() error = %v", err)
+		}
+	})
+}
+
+func TestOrderApp_CreateOrder_RequireVerifiedEmail(t *testing.T) {
+	cfg := &config.Config{
+		Auth:  config.AuthConfig{RequireVerifiedEmail: true},
+		Order: config.OrderConfig{OrderExpiration: 30 * time.Minute},
+	}
+	req := &model.OrderRequest{Items: []model.OrderItemRequest{{ProductID: 1, Quantity: 1}}}
+	tests := []struct {
+		name    string
+		mock    func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository)
+		wantErr constant.ErrorType
+	}{
+		{
+			name: "unverified user",
+			mock: func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository) {
+				orderRepo.On("IsUserVerified", mock.Anything, uint64(1)).Return(false, nil).Once()
+			},
+			wantErr: constant.ErrEmailNotVerified,
+		},
+		{
+			name: "lookup error",
+			mock: func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository) {
+				orderRepo.On("IsUserVerified", mock.Anything, uint64(1)).Return(false, errors.New("db down")).Once()
+			},
+			wantErr: constant.ErrInternal,
+		},
+		{
+			// the gate passes, the order then fails at the first step after it
+			name: "verified user",
+			mock: func(txRepo *txmocks.TxRepository, orderRepo *ordermocks.OrderRepository) {
+				orderRepo.On("IsUserVerified", mock.Anything, uint64(1)).Return(true, nil).Once()
+				txRepo.On("BeginTx", mock.Anything).Return(nil, errors.New("db down")).Once()
+			},
+			wantErr: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txRepo := txmocks.NewTxRepository(t)
+			orderRepo := ordermocks.NewOrderRepository(t)
+			tt.mock(txRepo, orderRepo)
+			app := apporder.NewOrderApp(cfg, txRepo, orderRepo, warehousemocks.NewWarehouseRepository(t), nil, nil, nil)
+
+			_, err := app.CreateOrder(context.Background(), 1, req)
+			var ce cerr.CustomError
+			if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[tt.wantErr] {
+				t.Fatalf("CreateOrder() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOrderApp_CreateOrder_ProductLock(t *testing.T) {
+	type fields struct {
+		txRepo        *txmocks.TxRepository
+		orderRepo     *ordermocks.OrderRepository
+		warehouseRepo *warehousemocks.WarehouseRepository
+		redisRepo     *redismocks.RedisRepository
+	}
+	cfg := &config.Config{
+		Order: config.OrderConfig{
+			OrderExpiration: 30 * time.Minute,
+			ProductLock:     true,
+			ProductLockTTL:  5 * time.Second,
+		},
+	}
+	req := &model.OrderRequest{
+		Items: []model.OrderItemRequest{{ProductID: 2, Quantity: 1}, {ProductID: 1, Quantity: 1}},
+	}
+	expectOrder := func(f fields) {
+		tx := &sqlx.Tx{}
+		f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+		f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, mock.Anything).Return(int64(10), nil).Twice()
+		f.orderRepo.On("GetProductPricesTx", mock.Anything, tx, mock.Anything).Return(map[uint64]float64{1: 1000, 2: 2000}, nil).Once()
+		f.orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.Anything).Return(uint64(7), nil).Once()
+		f.orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(7), mock.Anything).Return(nil).Once()
+		f.warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil).Twice()
+		f.txRepo.On("CommitTx", tx).Return(nil).Once()
+	}
+	tests := []struct {
+		name     string
+		config   *config.Config
+		mockCall func(f fields)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name:   "success: locks taken in product order and released",
+			config: cfg,
+			mockCall: func(f fields) {
+				var token string
+				f.redisRepo.On("AcquireLock", mock.Anything, "lock:product:1", mock.Anything, 5*time.Second).
+					Run(func(args mock.Arguments) { token = args.String(2) }).Return(true, nil).Once()
+				f.redisRepo.On("AcquireLock", mock.Anything, "lock:product:2", mock.MatchedBy(func(tok string) bool { return tok == token }), 5*time.Second).Return(true, nil).Once()
+				expectOrder(f)
+				f.redisRepo.On("ReleaseLock", mock.Anything, "lock:product:1", mock.MatchedBy(func(tok string) bool { return tok == token })).Return(nil).Once()
+				f.redisRepo.On("ReleaseLock", mock.Anything, "lock:product:2", mock.MatchedBy(func(tok string) bool { return tok == token })).Return(nil).Once()
+			},
+		},
+		{
+			name:   "error: lock still busy after the wait releases what was taken",
+			config: cfg,
+			mockCall: func(f fields) {
+				f.redisRepo.On("AcquireLock", mock.Anything, "lock:product:1", mock.Anything, 5*time.Second).Return(true, nil).Once()
+				f.redisRepo.On("AcquireLock", mock.Anything, "lock:product:2", mock.Anything, 5*time.Second).Return(false, nil).Once()
+				f.redisRepo.On("ReleaseLock", mock.Anything, "lock:product:1", mock.Anything).Return(nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrTooManyRequests,
+		},
+		{
+			name:   "success: redis down, order goes ahead unlocked",
+			config: cfg,
+			mockCall: func(f fields) {
+				f.redisRepo.On("AcquireLock", mock.Anything, "lock:product:1", mock.Anything, 5*time.Second).Return(false, fmt.Errorf("connection refused")).Once()
+				expectOrder(f)
+			},
+		},
+		{
+			name:   "success: disabled, redis untouched",
+			config: &config.Config{Order: config.OrderConfig{OrderExpiration: 30 * time.Minute}},
+			mockCall: func(f fields) {
+				expectOrder(f)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := fields{
+				txRepo:        txmocks.NewTxRepository(t),
+				orderRepo:     ordermocks.NewOrderRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+				redisRepo:     redismocks.NewRedisRepository(t),
+			}
+			tt.mockCall(f)
+
+			app := apporder.NewOrderApp(tt.config, f.txRepo, f.orderRepo, f.warehouseRepo, nil, f.redisRepo, nil)
+			_, err := app.CreateOrder(context.Background(), 1, req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("CreateOrder() error = %v, want %v", err, tt.errCode)
+				}
 			}
 		})
 	}
 }
+
+// lockTable is an in-memory stand-in for the Redis product locks
+type lockTable struct {
+	mu    sync.Mutex
+	locks map[string]string
+}
+
+func (l *lockTable) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, held := l.locks[key]; held {
+		return false, nil
+	}
+	l.locks[key] = token
+	return true, nil
+}
+
+func (l *lockTable) release(ctx context.Context, key, token string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.locks[key] == token {
+		delete(l.locks, key)
+	}
+	return nil
+}
+
+func TestOrderApp_CreateOrder_ConcurrentScarceProduct(t *testing.T) {
+	txRepo := txmocks.NewTxRepository(t)
+	orderRepo := ordermocks.NewOrderRepository(t)
+	warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+	redisRepo := redismocks.NewRedisRepository(t)
+	cfg := &config.Config{
+		Order: config.OrderConfig{
+			OrderExpiration: 30 * time.Minute,
+			ProductLock:     true,
+			ProductLockTTL:  5 * time.Second,
+			ProductLockWait: 5 * time.Second,
+		},
+	}
+
+	// the stock check and the reservation are deliberately not atomic here,
+	// only the product lock keeps the second buyer from reading stale stock
+	var (
+		stockMu sync.Mutex
+		stock   int64 = 1
+	)
+	tx := &sqlx.Tx{}
+	txRepo.On("BeginTx", mock.Anything).Return(tx, nil)
+	txRepo.On("CommitTx", tx).Return(nil).Maybe()
+	txRepo.On("RollbackTx", tx).Return(nil).Maybe()
+	warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(func(ctx context.Context, tx *sqlx.Tx, productID uint64) (int64, error) {
+		stockMu.Lock()
+		defer stockMu.Unlock()
+		return stock, nil
+	})
+	orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 10000}, nil).Maybe()
+	orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.Anything).Return(uint64(1), nil).Maybe()
+	orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil).Maybe()
+	warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(func(ctx context.Context, tx *sqlx.Tx, req *model.ReserveRequest) error {
+		time.Sleep(20 * time.Millisecond)
+		stockMu.Lock()
+		defer stockMu.Unlock()
+		stock -= int64(req.Quantity)
+		return nil
+	}).Maybe()
+	locks := &lockTable{locks: map[string]string{}}
+	redisRepo.On("AcquireLock", mock.Anything, "lock:product:1", mock.Anything, 5*time.Second).Return(locks.acquire)
+	redisRepo.On("ReleaseLock", mock.Anything, "lock:product:1", mock.Anything).Return(locks.release)
+
+	app := apporder.NewOrderApp(cfg, txRepo, orderRepo, warehouseRepo, nil, redisRepo, nil)
+
+	var (
+		wg        sync.WaitGroup
+		succeeded int32
+		soldOut   int32
+		start     = make(chan struct{})
+	)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := app.CreateOrder(context.Background(), 1, &model.OrderRequest{
+				Items: []model.OrderItemRequest{{ProductID: 1, Quantity: 1}},
+			})
+			if err == nil {
+				atomic.AddInt32(&succeeded, 1)
+				return
+			}
+			var ce cerr.CustomError
+			if errors.As(err, &ce) && ce.ErrorCode() == constant.ErrorTypeCode[constant.ErrInsufficientStock] {
+				atomic.AddInt32(&soldOut, 1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if succeeded != 1 || soldOut != 1 {
+		t.Fatalf("succeeded = %d, sold out = %d, want 1 each", succeeded, soldOut)
+	}
+	if stock != 0 {
+		t.Fatalf("stock left = %d, want 0", stock)
+	}
+}
+
+func BenchmarkOrderApp_CreateOrder(b *testing.B) {
+	txRepo := &txmocks.TxRepository{}
+	orderRepo := &ordermocks.OrderRepository{}
+	warehouseRepo := &warehousemocks.WarehouseRepository{}
+	cfg := &config.Config{
+		Order: config.OrderConfig{
+			OrderExpiration: 30 * time.Minute,
+		},
+	}
+
+	tx := &sqlx.Tx{}
+	txRepo.On("BeginTx", mock.Anything).Return(tx, nil)
+	txRepo.On("CommitTx", tx).Return(nil)
+	txRepo.On("RollbackTx", tx).Return(nil)
+	warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(1)).Return(int64(100), nil)
+	orderRepo.On("GetProductPricesTx", mock.Anything, tx, []uint64{1}).Return(map[uint64]float64{1: 10000}, nil)
+	orderRepo.On("InsertOrderTx", mock.Anything, tx, mock.Anything).Return(uint64(1), nil)
+	orderRepo.On("InsertOrderItemsTx", mock.Anything, tx, uint64(1), mock.Anything).Return(nil)
+	warehouseRepo.On("ReserveStockTx", mock.Anything, tx, mock.Anything).Return(nil)
+
+	app := apporder.NewOrderApp(cfg, txRepo, orderRepo, warehouseRepo, nil, nil, nil)
+	req := &model.OrderRequest{
+		Items: []model.OrderItemRequest{{ProductID: 1, Quantity: 1}},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := app.CreateOrder(context.Background(), 1, req); err != nil {
+			b.Fatalf("CreateOrder() error = %v", err)
+		}
+	}
+}
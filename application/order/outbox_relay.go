@@ -0,0 +1,83 @@
+package order
+
+import (
+	"context"
+	"time"
+
+	orderrepo "github.com/muhammadheryan/e-commerce/repository/order"
+	"github.com/muhammadheryan/e-commerce/thirdparty/rabbitmq"
+	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"go.uber.org/zap"
+)
+
+// outboxBatchSize caps how many outbox rows are relayed per scan
+const outboxBatchSize = 100
+
+// ExpirationPublisher publishes order expiration messages, implemented by *rabbitmq.Publisher
+type ExpirationPublisher interface {
+	PublishOrderExpiration(msg rabbitmq.OrderExpirationMessage) error
+}
+
+// OutboxRelay publishes expiration messages written to order_outbox by
+// CreateOrder that have not reached the broker yet, and marks them sent.
+type OutboxRelay struct {
+	orderRepo orderrepo.OrderRepository
+	publisher ExpirationPublisher
+	interval  time.Duration
+}
+
+func NewOutboxRelay(orderRepo orderrepo.OrderRepository, publisher ExpirationPublisher, interval time.Duration) *OutboxRelay {
+	return &OutboxRelay{orderRepo: orderRepo, publisher: publisher, interval: interval}
+}
+
+// Start runs the relay loop in the background until ctx is canceled
+func (r *OutboxRelay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.RunOnce(ctx)
+			}
+		}
+	}()
+}
+
+// RunOnce relays one batch of unsent messages and returns how many were sent.
+// It stops at the first publish failure since the broker is likely still down.
+func (r *OutboxRelay) RunOnce(ctx context.Context) int {
+	rows, err := r.orderRepo.ListUnsentOutbox(ctx, outboxBatchSize)
+	if err != nil {
+		logger.Error("[OutboxRelay] list unsent outbox", zap.String("error", err.Error()))
+		return 0
+	}
+
+	sent := 0
+	for _, row := range rows {
+		msg := rabbitmq.OrderExpirationMessage{
+			OrderID:   row.OrderID,
+			UserID:    row.UserID,
+			ExpiresAt: row.ExpiresAt,
+		}
+		if err := r.publisher.PublishOrderExpiration(msg); err != nil {
+			logger.Error("[OutboxRelay] publish order expiration", zap.Uint64("order_id", row.OrderID), zap.String("error", err.Error()))
+			if err := r.orderRepo.IncrementOutboxAttempts(ctx, row.ID); err != nil {
+				logger.Error("[OutboxRelay] increment attempts", zap.Uint64("outbox_id", row.ID), zap.String("error", err.Error()))
+			}
+			break
+		}
+		if err := r.orderRepo.MarkOutboxSent(ctx, row.ID); err != nil {
+			// the message will be published again, a duplicate cancel is rejected by the status check
+			logger.Error("[OutboxRelay] mark outbox sent", zap.Uint64("outbox_id", row.ID), zap.String("error", err.Error()))
+			continue
+		}
+		sent++
+	}
+	if sent > 0 {
+		logger.Info("[OutboxRelay] relayed expiration messages", zap.Int("count", sent))
+	}
+	return sent
+}
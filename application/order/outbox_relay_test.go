@@ -0,0 +1,94 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apporder "github.com/muhammadheryan/e-commerce/application/order"
+	ordermocks "github.com/muhammadheryan/e-commerce/mocks/repository/order"
+	"github.com/muhammadheryan/e-commerce/model"
+	"github.com/muhammadheryan/e-commerce/thirdparty/rabbitmq"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakePublisher records published messages and fails for the order ids in failFor
+type fakePublisher struct {
+	failFor   map[uint64]bool
+	published []rabbitmq.OrderExpirationMessage
+}
+
+func (p *fakePublisher) PublishOrderExpiration(msg rabbitmq.OrderExpirationMessage) error {
+	if p.failFor[msg.OrderID] {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, msg)
+	return nil
+}
+
+func TestOutboxRelay_RunOnce(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	tests := []struct {
+		name          string
+		orderRepo     *ordermocks.OrderRepository
+		publisher     *fakePublisher
+		mockCall      func(r *ordermocks.OrderRepository)
+		want          int
+		wantPublished int
+	}{
+		{
+			name:      "success: publishes unsent rows and marks them sent",
+			orderRepo: ordermocks.NewOrderRepository(t),
+			publisher: &fakePublisher{},
+			mockCall: func(r *ordermocks.OrderRepository) {
+				r.On("ListUnsentOutbox", mock.Anything, mock.Anything).Return([]model.OrderOutbox{
+					{ID: 1, OrderID: 10, UserID: 1, ExpiresAt: expiresAt},
+					{ID: 2, OrderID: 11, UserID: 2, ExpiresAt: expiresAt},
+				}, nil).Once()
+				r.On("MarkOutboxSent", mock.Anything, uint64(1)).Return(nil).Once()
+				r.On("MarkOutboxSent", mock.Anything, uint64(2)).Return(nil).Once()
+			},
+			want:          2,
+			wantPublished: 2,
+		},
+		{
+			name:      "error: stops at the first publish failure and records the attempt",
+			orderRepo: ordermocks.NewOrderRepository(t),
+			publisher: &fakePublisher{failFor: map[uint64]bool{10: true}},
+			mockCall: func(r *ordermocks.OrderRepository) {
+				r.On("ListUnsentOutbox", mock.Anything, mock.Anything).Return([]model.OrderOutbox{
+					{ID: 1, OrderID: 10, UserID: 1, ExpiresAt: expiresAt},
+					{ID: 2, OrderID: 11, UserID: 2, ExpiresAt: expiresAt},
+				}, nil).Once()
+				r.On("IncrementOutboxAttempts", mock.Anything, uint64(1)).Return(nil).Once()
+			},
+			want:          0,
+			wantPublished: 0,
+		},
+		{
+			name:      "error: listing outbox fails",
+			orderRepo: ordermocks.NewOrderRepository(t),
+			publisher: &fakePublisher{},
+			mockCall: func(r *ordermocks.OrderRepository) {
+				r.On("ListUnsentOutbox", mock.Anything, mock.Anything).Return(nil, errors.New("db error")).Once()
+			},
+			want:          0,
+			wantPublished: 0,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockCall(tt.orderRepo)
+			relay := apporder.NewOutboxRelay(tt.orderRepo, tt.publisher, 0)
+
+			if got := relay.RunOnce(context.Background()); got != tt.want {
+				t.Fatalf("RunOnce() = %d, want %d", got, tt.want)
+			}
+			if len(tt.publisher.published) != tt.wantPublished {
+				t.Fatalf("published = %d, want %d", len(tt.publisher.published), tt.wantPublished)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package order
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/model"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
+	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	productLockPrefix = "lock:product:"
+	// productLockPoll is how often a busy lock is retried
+	productLockPoll = 25 * time.Millisecond
+)
+
+func productLockKey(productID uint64) string {
+	return productLockPrefix + strconv.FormatUint(productID, 10)
+}
+
+// lockProducts takes the advisory lock of every product the order touches,
+// in id order so two orders sharing products can't deadlock. The row locks
+// taken while reserving still guard the stock, the advisory lock only keeps
+// buyers of a hot product from piling up on them. When Redis fails the order
+// goes ahead unlocked. The returned func releases whatever was taken.
+func (s *orderAppImpl) lockProducts(ctx context.Context, userID uint64, req *model.OrderRequest) (func(), error) {
+	noop := func() {}
+	if !s.config.Order.ProductLock || s.redisRepo == nil {
+		return noop, nil
+	}
+
+	productIDs, err := s.orderProductIDs(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := uuid.NewString()
+	held := make([]string, 0, len(productIDs))
+	release := func() {
+		// the order's context may be cancelled by now, the locks must still go
+		ctx := context.WithoutCancel(ctx)
+		for _, key := range held {
+			if err := s.redisRepo.ReleaseLock(ctx, key, token); err != nil {
+				logger.Error("[CreateOrder] release product lock", zap.String("error", err.Error()), zap.String("key", key))
+			}
+		}
+	}
+
+	deadline := time.Now().Add(s.config.Order.ProductLockWait)
+	for _, productID := range productIDs {
+		key := productLockKey(productID)
+		for {
+			ok, err := s.redisRepo.AcquireLock(ctx, key, token, s.config.Order.ProductLockTTL)
+			if err != nil {
+				logger.Warn("[CreateOrder] acquire product lock, continuing unlocked", zap.String("error", err.Error()), zap.String("key", key))
+				release()
+				return noop, nil
+			}
+			if ok {
+				held = append(held, key)
+				break
+			}
+			if !time.Now().Before(deadline) {
+				release()
+				return nil, errors.SetCustomError(constant.ErrTooManyRequests)
+			}
+			select {
+			case <-ctx.Done():
+				release()
+				return nil, ctx.Err()
+			case <-time.After(productLockPoll):
+			}
+		}
+	}
+	return release, nil
+}
+
+// orderProductIDs lists the distinct products of the order in ascending order.
+// A cart order is read outside the transaction, a product added meanwhile is
+// simply not locked.
+func (s *orderAppImpl) orderProductIDs(ctx context.Context, userID uint64, req *model.OrderRequest) ([]uint64, error) {
+	var productIDs []uint64
+	if req.FromCart {
+		items, err := s.cartRepo.ListItems(ctx, userID)
+		if err != nil {
+			logger.Error("[CreateOrder] list cart items", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+		for _, item := range items {
+			productIDs = append(productIDs, uint64(item.ProductID))
+		}
+	} else {
+		for _, item := range req.Items {
+			productIDs = append(productIDs, uint64(item.ProductID))
+		}
+	}
+	sort.Slice(productIDs, func(i, j int) bool { return productIDs[i] < productIDs[j] })
+
+	distinct := productIDs[:0]
+	for i, id := range productIDs {
+		if i == 0 || id != productIDs[i-1] {
+			distinct = append(distinct, id)
+		}
+	}
+	return distinct, nil
+}
@@ -2,56 +2,456 @@ package product
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/muhammadheryan/e-commerce/cmd/config"
 	"github.com/muhammadheryan/e-commerce/constant"
 	"github.com/muhammadheryan/e-commerce/model"
 	productRepo "github.com/muhammadheryan/e-commerce/repository/product"
+	redisRepo "github.com/muhammadheryan/e-commerce/repository/redis"
 	"github.com/muhammadheryan/e-commerce/utils/errors"
 	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"github.com/muhammadheryan/e-commerce/utils/money"
+	validatorx "github.com/muhammadheryan/e-commerce/utils/validator"
 	"go.uber.org/zap"
 )
 
 type ProductApp interface {
-	ListProducts(ctx context.Context, page, perPage int) (*model.ProductListResponse, error)
+	ListProducts(ctx context.Context, page, perPage int, sort constant.ProductSort) (*model.ProductListResponse, error)
 	GetProduct(ctx context.Context, id uint64) (*model.ProductDetail, error)
+	CreateReview(ctx context.Context, userID, productID uint64, req *model.ReviewRequest) (*model.ProductReview, error)
+	ListReviews(ctx context.Context, productID uint64, page, perPage int) (*model.ReviewListResponse, error)
+	ListFeed(ctx context.Context, cursor uint64, limit int) (*model.ProductFeedResponse, error)
+	SyncProducts(ctx context.Context, since time.Time, cursor string, limit int) (*model.ProductSyncResponse, error)
+	SubscribeBackInStock(ctx context.Context, userID, productID uint64) error
+	InvalidateProduct(ctx context.Context, productID uint64) error
+	DeleteProduct(ctx context.Context, productID uint64) error
+	CreateProduct(ctx context.Context, req *model.ProductRequest) (*model.ProductDetail, error)
+	UpdateProduct(ctx context.Context, productID uint64, req *model.ProductUpdateRequest) (*model.ProductDetail, error)
 }
 
+const (
+	defaultFeedLimit = 20
+	maxFeedLimit     = 50
+
+	defaultSyncLimit = 100
+	maxSyncLimit     = 500
+
+	productCacheKeyPrefix = "product:"
+)
+
 type productAppImpl struct {
+	config      *config.Config
 	productRepo productRepo.ProductRepository
+	redisRepo   redisRepo.RedisRepository
 }
 
-func NewProductApp(productRepo productRepo.ProductRepository) ProductApp {
-	return &productAppImpl{productRepo: productRepo}
+func NewProductApp(config *config.Config, productRepo productRepo.ProductRepository, redisRepo redisRepo.RedisRepository) ProductApp {
+	return &productAppImpl{config: config, productRepo: productRepo, redisRepo: redisRepo}
 }
 
-func (s *productAppImpl) ListProducts(ctx context.Context, page, perPage int) (*model.ProductListResponse, error) {
+// ListProducts corrects out of range pagination to the defaults and caps the
+// page size, but rejects filter and sort values it does not understand with
+// ErrInvalidRequest
+func (s *productAppImpl) ListProducts(ctx context.Context, page, perPage int, sort constant.ProductSort) (*model.ProductListResponse, error) {
+	if !sort.Valid() {
+		return nil, errors.SetCustomError(constant.ErrInvalidRequest)
+	}
 	if page <= 0 {
 		page = 1
 	}
 	if perPage <= 0 {
 		perPage = 10
 	}
+	perPage = s.config.Server.ClampPerPage(perPage)
 
-	items, total, err := s.productRepo.List(ctx, page, perPage)
+	items, total, err := s.productRepo.List(ctx, page, perPage, sort)
 	if err != nil {
 		logger.Error("[ListProducts] error productRepo.List", zap.String("error", err.Error()))
 		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
+	for i := range items {
+		items[i].AvailabilityStatus = s.availability(items[i].AvailableStock)
+	}
 
 	return &model.ProductListResponse{
 		Items:      items,
 		TotalCount: total,
 		Page:       page,
 		PerPage:    perPage,
+		PageMeta:   model.NewPageMeta(page, perPage, total),
 	}, nil
 }
 
+// GetProduct reads the product through the Redis cache when it is enabled.
+// Only the catalog fields are cached, stock and review stats are always read live.
 func (s *productAppImpl) GetProduct(ctx context.Context, id uint64) (*model.ProductDetail, error) {
-	result, err := s.productRepo.GetByID(ctx, id)
+	result, err := s.cachedProduct(ctx, id)
 	if err != nil {
-		logger.Error("[GetProduct] error productRepo.GetByID", zap.String("error", err.Error()))
+		logger.Error("[GetProduct] error productRepo.GetAvailableStock", zap.String("error", err.Error()))
 		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
+	if result == nil {
+		result, err = s.productRepo.GetByID(ctx, id)
+		if err == sql.ErrNoRows {
+			return nil, errors.SetCustomError(constant.ErrNotFound)
+		}
+		if err != nil {
+			logger.Error("[GetProduct] error productRepo.GetByID", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+		s.cacheProduct(ctx, result)
+	}
+
+	stats, err := s.productRepo.GetReviewStats(ctx, id)
+	if err != nil {
+		logger.Error("[GetProduct] error productRepo.GetReviewStats", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	result.ReviewCount = stats.ReviewCount
+	result.AverageRating = averageRating(stats)
+	result.AvailabilityStatus = s.availability(result.AvailableStock)
 
 	return result, nil
 }
+
+// InvalidateProduct drops the cached product, it must be called whenever the
+// cached catalog fields (name, description, price, shop) change
+func (s *productAppImpl) InvalidateProduct(ctx context.Context, productID uint64) error {
+	if err := s.redisRepo.Delete(ctx, productCacheKey(productID)); err != nil {
+		logger.Error("[InvalidateProduct] error redisRepo.Delete", zap.String("error", err.Error()), zap.Uint64("product_id", productID))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	return nil
+}
+
+// CreateProduct adds a product to a shop and returns it as GetProduct does.
+// The price is rounded to the store currency first. A missing shop is ErrNotFound.
+func (s *productAppImpl) CreateProduct(ctx context.Context, req *model.ProductRequest) (*model.ProductDetail, error) {
+	product := *req
+	product.Price = money.Round(req.Price, s.config.Store.Currency)
+	if product.Price <= 0 {
+		return nil, priceTooSmall()
+	}
+
+	id, err := s.productRepo.Create(ctx, &product)
+	if err == sql.ErrNoRows {
+		return nil, errors.SetCustomError(constant.ErrNotFound)
+	}
+	if err != nil {
+		logger.Error("[CreateProduct] error productRepo.Create", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	return s.GetProduct(ctx, id)
+}
+
+// UpdateProduct changes the fields set in req and returns the product as
+// GetProduct does. A missing or deleted product, or a missing new shop, is
+// ErrNotFound.
+func (s *productAppImpl) UpdateProduct(ctx context.Context, productID uint64, req *model.ProductUpdateRequest) (*model.ProductDetail, error) {
+	if req.Empty() {
+		return nil, errors.SetCustomError(constant.ErrInvalidRequest)
+	}
+	update := *req
+	if req.Price != nil {
+		price := money.Round(*req.Price, s.config.Store.Currency)
+		if price <= 0 {
+			return nil, priceTooSmall()
+		}
+		update.Price = &price
+	}
+
+	err := s.productRepo.Update(ctx, productID, &update)
+	if err == sql.ErrNoRows {
+		return nil, errors.SetCustomError(constant.ErrNotFound)
+	}
+	if err != nil {
+		logger.Error("[UpdateProduct] error productRepo.Update", zap.String("error", err.Error()), zap.Uint64("product_id", productID))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	if s.config.Product.CacheTTL > 0 {
+		// already logged, the old fields are served from the cache until its TTL at worst
+		_ = s.InvalidateProduct(ctx, productID)
+	}
+	return s.GetProduct(ctx, productID)
+}
+
+// priceTooSmall rejects a price that rounds to zero in the store currency,
+// reported like the gt=0 rule the request already passed
+func priceTooSmall() error {
+	return errors.WithDetails(constant.ErrInvalidRequest, []validatorx.FieldError{{Field: "price", Rule: "gt", Param: "0"}})
+}
+
+// DeleteProduct soft-deletes the product, it disappears from listings and can
+// no longer be ordered while orders already placed keep their items
+func (s *productAppImpl) DeleteProduct(ctx context.Context, productID uint64) error {
+	deleted, err := s.productRepo.SoftDelete(ctx, productID)
+	if err != nil {
+		logger.Error("[DeleteProduct] error productRepo.SoftDelete", zap.String("error", err.Error()), zap.Uint64("product_id", productID))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if !deleted {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+	if s.config.Product.CacheTTL > 0 {
+		// already logged, the product is served from the cache until its TTL at worst
+		_ = s.InvalidateProduct(ctx, productID)
+	}
+	return nil
+}
+
+func productCacheKey(id uint64) string {
+	return productCacheKeyPrefix + strconv.FormatUint(id, 10)
+}
+
+// cachedProductDetail is the part of a product detail that is cached, the
+// catalog fields that only change when the product is edited
+type cachedProductDetail struct {
+	ID          model.ID `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	ShopID      model.ID `json:"shop_id"`
+	ShopName    string   `json:"shop_name"`
+	Price       float64  `json:"price"`
+}
+
+// cachedProduct returns the cached product with its stock read live, nil on a
+// miss. An unreachable or corrupt cache counts as a miss.
+func (s *productAppImpl) cachedProduct(ctx context.Context, id uint64) (*model.ProductDetail, error) {
+	if s.config.Product.CacheTTL <= 0 {
+		return nil, nil
+	}
+	raw, err := s.redisRepo.Get(ctx, productCacheKey(id))
+	if err != nil || raw == "" {
+		return nil, nil
+	}
+	var cached cachedProductDetail
+	if err := json.Unmarshal([]byte(raw), &cached); err != nil {
+		logger.Warn("[GetProduct] unreadable cached product", zap.String("error", err.Error()), zap.Uint64("product_id", id))
+		return nil, nil
+	}
+
+	available, err := s.productRepo.GetAvailableStock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ProductDetail{
+		ID:             cached.ID,
+		Name:           cached.Name,
+		Description:    cached.Description,
+		ShopID:         cached.ShopID,
+		ShopName:       cached.ShopName,
+		Price:          cached.Price,
+		AvailableStock: available,
+	}, nil
+}
+
+// cacheProduct stores the catalog fields of detail, failing to is only logged
+func (s *productAppImpl) cacheProduct(ctx context.Context, detail *model.ProductDetail) {
+	if s.config.Product.CacheTTL <= 0 {
+		return
+	}
+	raw, err := json.Marshal(cachedProductDetail{
+		ID:          detail.ID,
+		Name:        detail.Name,
+		Description: detail.Description,
+		ShopID:      detail.ShopID,
+		ShopName:    detail.ShopName,
+		Price:       detail.Price,
+	})
+	if err != nil {
+		return
+	}
+	if err := s.redisRepo.SetWithTTL(ctx, productCacheKey(uint64(detail.ID)), string(raw), s.config.Product.CacheTTL); err != nil {
+		logger.Warn("[GetProduct] cache product failed", zap.String("error", err.Error()), zap.Uint64("product_id", uint64(detail.ID)))
+	}
+}
+
+func (s *productAppImpl) CreateReview(ctx context.Context, userID, productID uint64, req *model.ReviewRequest) (*model.ProductReview, error) {
+	// only buyers with a completed order of the product may review it
+	purchased, err := s.productRepo.HasCompletedPurchase(ctx, userID, productID)
+	if err != nil {
+		logger.Error("[CreateReview] error productRepo.HasCompletedPurchase", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	if !purchased {
+		return nil, errors.SetCustomError(constant.ErrReviewNotAllowed)
+	}
+
+	review, err := s.productRepo.CreateReview(ctx, &model.ProductReview{
+		UserID:    model.ID(userID),
+		ProductID: model.ID(productID),
+		Rating:    req.Rating,
+		Comment:   req.Comment,
+	})
+	if err != nil {
+		if errors.IsType(err, constant.ErrReviewExists) {
+			return nil, err
+		}
+		logger.Error("[CreateReview] error productRepo.CreateReview", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	return review, nil
+}
+
+func (s *productAppImpl) ListReviews(ctx context.Context, productID uint64, page, perPage int) (*model.ReviewListResponse, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if perPage <= 0 {
+		perPage = 10
+	}
+	perPage = s.config.Server.ClampPerPage(perPage)
+
+	items, total, err := s.productRepo.ListReviews(ctx, productID, page, perPage)
+	if err != nil {
+		logger.Error("[ListReviews] error productRepo.ListReviews", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	return &model.ReviewListResponse{
+		Items:      items,
+		TotalCount: total,
+		Page:       page,
+		PerPage:    perPage,
+		PageMeta:   model.NewPageMeta(page, perPage, total),
+	}, nil
+}
+
+// ListFeed returns the feed page after cursor, the last product id of the previous page
+func (s *productAppImpl) ListFeed(ctx context.Context, cursor uint64, limit int) (*model.ProductFeedResponse, error) {
+	if limit <= 0 {
+		limit = defaultFeedLimit
+	}
+	if limit > maxFeedLimit {
+		limit = maxFeedLimit
+	}
+
+	// fetch one extra row to know whether another page exists without counting
+	items, err := s.productRepo.ListFeed(ctx, cursor, limit+1)
+	if err != nil {
+		logger.Error("[ListFeed] error productRepo.ListFeed", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	res := &model.ProductFeedResponse{Items: items}
+	if len(items) > limit {
+		res.Items = items[:limit]
+		res.HasMore = true
+	}
+	if res.HasMore {
+		res.NextCursor = res.Items[len(res.Items)-1].ID
+	}
+	return res, nil
+}
+
+// SyncProducts returns catalog changes made at or after since, soft-deleted
+// products included. Once a cursor is given it takes over from since.
+func (s *productAppImpl) SyncProducts(ctx context.Context, since time.Time, cursor string, limit int) (*model.ProductSyncResponse, error) {
+	if limit <= 0 {
+		limit = defaultSyncLimit
+	}
+	if limit > maxSyncLimit {
+		limit = maxSyncLimit
+	}
+
+	after := model.ProductSyncCursor{UpdatedAt: since}
+	if cursor != "" {
+		c, err := decodeSyncCursor(cursor)
+		if err != nil {
+			return nil, errors.SetCustomError(constant.ErrInvalidRequest)
+		}
+		after = c
+	}
+
+	items, err := s.productRepo.ListChangedSince(ctx, after, limit+1)
+	if err != nil {
+		logger.Error("[SyncProducts] error productRepo.ListChangedSince", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	res := &model.ProductSyncResponse{Items: items}
+	if len(items) > limit {
+		res.Items = items[:limit]
+		res.HasMore = true
+		last := res.Items[len(res.Items)-1]
+		res.NextCursor = encodeSyncCursor(model.ProductSyncCursor{UpdatedAt: last.UpdatedAt, ID: uint64(last.ID)})
+	}
+	return res, nil
+}
+
+// SubscribeBackInStock asks to be notified once the product can be ordered
+// again, only products that are out of stock right now can be subscribed to
+func (s *productAppImpl) SubscribeBackInStock(ctx context.Context, userID, productID uint64) error {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err == sql.ErrNoRows {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+	if err != nil {
+		logger.Error("[SubscribeBackInStock] error productRepo.GetByID", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if product.AvailableStock > 0 {
+		return errors.SetCustomError(constant.ErrInvalidRequest)
+	}
+
+	if err := s.productRepo.AddStockSubscription(ctx, userID, productID); err != nil {
+		logger.Error("[SubscribeBackInStock] error productRepo.AddStockSubscription", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	return nil
+}
+
+// encodeSyncCursor makes an opaque cursor out of the last (updated_at, id) of a page
+func encodeSyncCursor(c model.ProductSyncCursor) string {
+	raw := strconv.FormatInt(c.UpdatedAt.UnixNano(), 10) + ":" + strconv.FormatUint(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSyncCursor(cursor string) (model.ProductSyncCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return model.ProductSyncCursor{}, err
+	}
+	ts, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return model.ProductSyncCursor{}, fmt.Errorf("malformed cursor")
+	}
+	nanos, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return model.ProductSyncCursor{}, err
+	}
+	productID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return model.ProductSyncCursor{}, err
+	}
+	return model.ProductSyncCursor{UpdatedAt: time.Unix(0, nanos).UTC(), ID: productID}, nil
+}
+
+// availability derives the display stock status, at or below the configured
+// low stock threshold a product is low on stock
+func (s *productAppImpl) availability(available int64) constant.ProductAvailability {
+	if available <= 0 {
+		return constant.ProductAvailabilityOutOfStock
+	}
+	if available <= s.config.Product.LowStockThreshold {
+		return constant.ProductAvailabilityLowStock
+	}
+	return constant.ProductAvailabilityInStock
+}
+
+// averageRating returns the mean rating rounded to 2 decimals, zero when there are no reviews
+func averageRating(stats *model.ReviewStats) float64 {
+	if stats == nil || stats.ReviewCount == 0 {
+		return 0
+	}
+	avg := float64(stats.RatingSum) / float64(stats.ReviewCount)
+	return math.Round(avg*100) / 100
+}
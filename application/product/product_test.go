@@ -2,13 +2,17 @@ package product_test
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	appproduct "github.com/muhammadheryan/e-commerce/application/product"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
 	"github.com/muhammadheryan/e-commerce/constant"
 	productmocks "github.com/muhammadheryan/e-commerce/mocks/repository/product"
+	redismocks "github.com/muhammadheryan/e-commerce/mocks/repository/redis"
 	"github.com/muhammadheryan/e-commerce/model"
 	cerr "github.com/muhammadheryan/e-commerce/utils/errors"
 	"github.com/stretchr/testify/mock"
@@ -22,6 +26,7 @@ func TestProductApp_ListProducts(t *testing.T) {
 		ctx     context.Context
 		page    int
 		perPage int
+		sort    constant.ProductSort
 	}
 	tests := []struct {
 		name     string
@@ -30,6 +35,7 @@ func TestProductApp_ListProducts(t *testing.T) {
 		mockCall func(f fields)
 		want     *model.ProductListResponse
 		wantErr  bool
+		errCode  constant.ErrorType
 	}{
 		{
 			name: "success: list products with pagination",
@@ -59,30 +65,33 @@ func TestProductApp_ListProducts(t *testing.T) {
 					},
 				}
 				f.productRepo.
-					On("List", mock.Anything, 1, 10).
+					On("List", mock.Anything, 1, 10, constant.ProductSortDefault).
 					Return(items, int64(2), nil).
 					Once()
 			},
 			want: &model.ProductListResponse{
 				Items: []model.ProductListItem{
 					{
-						ID:             1,
-						Name:           "Product 1",
-						ShopName:       "Shop A",
-						AvailableStock: 100,
-						Price:          50000.0,
+						ID:                 1,
+						Name:               "Product 1",
+						ShopName:           "Shop A",
+						AvailableStock:     100,
+						Price:              50000.0,
+						AvailabilityStatus: constant.ProductAvailabilityInStock,
 					},
 					{
-						ID:             2,
-						Name:           "Product 2",
-						ShopName:       "Shop B",
-						AvailableStock: 50,
-						Price:          75000.0,
+						ID:                 2,
+						Name:               "Product 2",
+						ShopName:           "Shop B",
+						AvailableStock:     50,
+						Price:              75000.0,
+						AvailabilityStatus: constant.ProductAvailabilityInStock,
 					},
 				},
 				TotalCount: 2,
 				Page:       1,
 				PerPage:    10,
+				PageMeta:   model.PageMeta{TotalPages: 1},
 			},
 			wantErr: false,
 		},
@@ -98,7 +107,7 @@ func TestProductApp_ListProducts(t *testing.T) {
 			},
 			mockCall: func(f fields) {
 				f.productRepo.
-					On("List", mock.Anything, 1, 10).
+					On("List", mock.Anything, 1, 10, constant.ProductSortDefault).
 					Return([]model.ProductListItem{}, int64(0), nil).
 					Once()
 			},
@@ -122,7 +131,7 @@ func TestProductApp_ListProducts(t *testing.T) {
 			},
 			mockCall: func(f fields) {
 				f.productRepo.
-					On("List", mock.Anything, 1, 5).
+					On("List", mock.Anything, 1, 5, constant.ProductSortDefault).
 					Return([]model.ProductListItem{}, int64(0), nil).
 					Once()
 			},
@@ -146,12 +155,53 @@ func TestProductApp_ListProducts(t *testing.T) {
 			},
 			mockCall: func(f fields) {
 				f.productRepo.
-					On("List", mock.Anything, 1, 10).
+					On("List", mock.Anything, 1, 10, constant.ProductSortDefault).
 					Return(nil, int64(0), errors.New("db error")).
 					Once()
 			},
 			want:    nil,
 			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name: "success: sort is passed to the repository",
+			fields: fields{
+				productRepo: productmocks.NewProductRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				page:    1,
+				perPage: 10,
+				sort:    constant.ProductSortPriceDesc,
+			},
+			mockCall: func(f fields) {
+				f.productRepo.
+					On("List", mock.Anything, 1, 10, constant.ProductSortPriceDesc).
+					Return([]model.ProductListItem{}, int64(0), nil).
+					Once()
+			},
+			want: &model.ProductListResponse{
+				Items:      []model.ProductListItem{},
+				TotalCount: 0,
+				Page:       1,
+				PerPage:    10,
+			},
+			wantErr: false,
+		},
+		{
+			name: "error: invalid sort is a bad request",
+			fields: fields{
+				productRepo: productmocks.NewProductRepository(t),
+			},
+			args: args{
+				ctx:     context.Background(),
+				page:    1,
+				perPage: 10,
+				sort:    constant.ProductSort("price; DROP TABLE product"),
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrInvalidRequest,
 		},
 	}
 	for _, tt := range tests {
@@ -161,9 +211,9 @@ func TestProductApp_ListProducts(t *testing.T) {
 				ttFields := tt.fields
 				tt.mockCall(ttFields)
 			}
-			app := appproduct.NewProductApp(tt.fields.productRepo)
+			app := appproduct.NewProductApp(&config.Config{}, tt.fields.productRepo, nil)
 
-			got, err := app.ListProducts(tt.args.ctx, tt.args.page, tt.args.perPage)
+			got, err := app.ListProducts(tt.args.ctx, tt.args.page, tt.args.perPage, tt.args.sort)
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("ListProducts() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -173,8 +223,8 @@ func TestProductApp_ListProducts(t *testing.T) {
 				if !errors.As(err, &ce) {
 					t.Fatalf("error type = %T, want CustomError", err)
 				}
-				if ce.ErrorCode() != constant.ErrorTypeCode[constant.ErrInternal] {
-					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[constant.ErrInternal])
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
 				}
 				return
 			}
@@ -186,6 +236,25 @@ func TestProductApp_ListProducts(t *testing.T) {
 	}
 }
 
+func TestProductApp_ListProducts_MaxPerPage(t *testing.T) {
+	cfg := &config.Config{Server: config.ServerConfig{MaxPerPage: 100}}
+	productRepo := productmocks.NewProductRepository(t)
+	productRepo.
+		On("List", mock.Anything, 1, 100, constant.ProductSortDefault).
+		Return([]model.ProductListItem{}, int64(250), nil).
+		Once()
+	app := appproduct.NewProductApp(cfg, productRepo, nil)
+
+	got, err := app.ListProducts(context.Background(), 1, 100000, constant.ProductSortDefault)
+	if err != nil {
+		t.Fatalf("ListProducts() error = %v", err)
+	}
+	// the response reports the page size actually served
+	if got.PerPage != 100 || got.TotalPages != 3 || !got.HasNext {
+		t.Fatalf("ListProducts() = %+v, want per_page 100 over 3 pages", got)
+	}
+}
+
 func TestProductApp_GetProduct(t *testing.T) {
 	type fields struct {
 		productRepo *productmocks.ProductRepository
@@ -201,6 +270,7 @@ func TestProductApp_GetProduct(t *testing.T) {
 		mockCall func(f fields)
 		want     *model.ProductDetail
 		wantErr  bool
+		errCode  constant.ErrorType
 	}{
 		{
 			name: "success: get product by id",
@@ -224,6 +294,10 @@ func TestProductApp_GetProduct(t *testing.T) {
 						Price:          50000.0,
 					}, nil).
 					Once()
+				f.productRepo.
+					On("GetReviewStats", mock.Anything, uint64(1)).
+					Return(&model.ReviewStats{RatingSum: 14, ReviewCount: 3}, nil).
+					Once()
 			},
 			want: &model.ProductDetail{
 				ID:             1,
@@ -233,9 +307,65 @@ func TestProductApp_GetProduct(t *testing.T) {
 				ShopName:       "Shop A",
 				AvailableStock: 100,
 				Price:          50000.0,
+				AverageRating:  4.67,
+				ReviewCount:    3,
+
+				AvailabilityStatus: constant.ProductAvailabilityInStock,
+			},
+			wantErr: false,
+		},
+		{
+			name: "success: product without reviews has zero average rating",
+			fields: fields{
+				productRepo: productmocks.NewProductRepository(t),
+			},
+			args: args{
+				ctx: context.Background(),
+				id:  2,
+			},
+			mockCall: func(f fields) {
+				f.productRepo.
+					On("GetByID", mock.Anything, uint64(2)).
+					Return(&model.ProductDetail{ID: 2, Name: "Product 2"}, nil).
+					Once()
+				f.productRepo.
+					On("GetReviewStats", mock.Anything, uint64(2)).
+					Return(&model.ReviewStats{}, nil).
+					Once()
+			},
+			want: &model.ProductDetail{
+				ID:            2,
+				Name:          "Product 2",
+				AverageRating: 0,
+				ReviewCount:   0,
+
+				AvailabilityStatus: constant.ProductAvailabilityOutOfStock,
 			},
 			wantErr: false,
 		},
+		{
+			name: "error: repository GetReviewStats returns error",
+			fields: fields{
+				productRepo: productmocks.NewProductRepository(t),
+			},
+			args: args{
+				ctx: context.Background(),
+				id:  1,
+			},
+			mockCall: func(f fields) {
+				f.productRepo.
+					On("GetByID", mock.Anything, uint64(1)).
+					Return(&model.ProductDetail{ID: 1}, nil).
+					Once()
+				f.productRepo.
+					On("GetReviewStats", mock.Anything, uint64(1)).
+					Return(nil, errors.New("db error")).
+					Once()
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
 		{
 			name: "error: repository GetByID returns error",
 			fields: fields{
@@ -253,6 +383,26 @@ func TestProductApp_GetProduct(t *testing.T) {
 			},
 			want:    nil,
 			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name: "error: product does not exist",
+			fields: fields{
+				productRepo: productmocks.NewProductRepository(t),
+			},
+			args: args{
+				ctx: context.Background(),
+				id:  404,
+			},
+			mockCall: func(f fields) {
+				f.productRepo.
+					On("GetByID", mock.Anything, uint64(404)).
+					Return(nil, sql.ErrNoRows).
+					Once()
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrNotFound,
 		},
 	}
 	for _, tt := range tests {
@@ -262,7 +412,7 @@ func TestProductApp_GetProduct(t *testing.T) {
 				ttFields := tt.fields
 				tt.mockCall(ttFields)
 			}
-			app := appproduct.NewProductApp(tt.fields.productRepo)
+			app := appproduct.NewProductApp(&config.Config{}, tt.fields.productRepo, nil)
 
 			got, err := app.GetProduct(tt.args.ctx, tt.args.id)
 			if (err != nil) != tt.wantErr {
@@ -274,8 +424,8 @@ func TestProductApp_GetProduct(t *testing.T) {
 				if !errors.As(err, &ce) {
 					t.Fatalf("error type = %T, want CustomError", err)
 				}
-				if ce.ErrorCode() != constant.ErrorTypeCode[constant.ErrInternal] {
-					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[constant.ErrInternal])
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
 				}
 				return
 			}
@@ -286,3 +436,716 @@ func TestProductApp_GetProduct(t *testing.T) {
 		})
 	}
 }
+
+func TestProductApp_GetProductCache(t *testing.T) {
+	cfg := &config.Config{Product: config.ProductConfig{CacheTTL: time.Minute}}
+	cached := `{"id":1,"name":"Cached","description":"d","shop_id":2,"shop_name":"Shop","price":1000}`
+	stats := &model.ReviewStats{ReviewCount: 0}
+
+	tests := []struct {
+		name     string
+		mockCall func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository)
+		want     *model.ProductDetail
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: hit reads only stock and reviews live",
+			mockCall: func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository) {
+				redisRepo.On("Get", mock.Anything, "product:1").Return(cached, nil).Once()
+				productRepo.On("GetAvailableStock", mock.Anything, uint64(1)).Return(int64(7), nil).Once()
+				productRepo.On("GetReviewStats", mock.Anything, uint64(1)).Return(stats, nil).Once()
+			},
+			want: &model.ProductDetail{
+				ID: 1, Name: "Cached", Description: "d", ShopID: 2, ShopName: "Shop", Price: 1000,
+				AvailableStock: 7, AvailabilityStatus: constant.ProductAvailabilityInStock,
+			},
+		},
+		{
+			name: "success: miss loads from the repository and caches without stock",
+			mockCall: func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository) {
+				redisRepo.On("Get", mock.Anything, "product:1").Return("", errors.New("redis: nil")).Once()
+				productRepo.On("GetByID", mock.Anything, uint64(1)).Return(&model.ProductDetail{
+					ID: 1, Name: "Cached", Description: "d", ShopID: 2, ShopName: "Shop", Price: 1000, AvailableStock: 7,
+				}, nil).Once()
+				redisRepo.On("SetWithTTL", mock.Anything, "product:1", cached, time.Minute).Return(nil).Once()
+				productRepo.On("GetReviewStats", mock.Anything, uint64(1)).Return(stats, nil).Once()
+			},
+			want: &model.ProductDetail{
+				ID: 1, Name: "Cached", Description: "d", ShopID: 2, ShopName: "Shop", Price: 1000,
+				AvailableStock: 7, AvailabilityStatus: constant.ProductAvailabilityInStock,
+			},
+		},
+		{
+			name: "success: failing to cache still serves the product",
+			mockCall: func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository) {
+				redisRepo.On("Get", mock.Anything, "product:1").Return("", nil).Once()
+				productRepo.On("GetByID", mock.Anything, uint64(1)).Return(&model.ProductDetail{ID: 1, Name: "Cached", AvailableStock: 7}, nil).Once()
+				redisRepo.On("SetWithTTL", mock.Anything, "product:1", mock.Anything, time.Minute).Return(errors.New("redis down")).Once()
+				productRepo.On("GetReviewStats", mock.Anything, uint64(1)).Return(stats, nil).Once()
+			},
+			want: &model.ProductDetail{ID: 1, Name: "Cached", AvailableStock: 7, AvailabilityStatus: constant.ProductAvailabilityInStock},
+		},
+		{
+			name: "error: live stock fails on a hit",
+			mockCall: func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository) {
+				redisRepo.On("Get", mock.Anything, "product:1").Return(cached, nil).Once()
+				productRepo.On("GetAvailableStock", mock.Anything, uint64(1)).Return(int64(0), errors.New("db error")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			productRepo := productmocks.NewProductRepository(t)
+			redisRepo := redismocks.NewRedisRepository(t)
+			tt.mockCall(productRepo, redisRepo)
+			app := appproduct.NewProductApp(cfg, productRepo, redisRepo)
+
+			got, err := app.GetProduct(context.Background(), 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetProduct() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("GetProduct() error = %v, want %s", err, constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("GetProduct() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProductApp_InvalidateProduct(t *testing.T) {
+	redisRepo := redismocks.NewRedisRepository(t)
+	redisRepo.On("Delete", mock.Anything, "product:9").Return(nil).Once()
+	app := appproduct.NewProductApp(&config.Config{}, productmocks.NewProductRepository(t), redisRepo)
+
+	if err := app.InvalidateProduct(context.Background(), 9); err != nil {
+		t.Fatalf("InvalidateProduct() error = %v", err)
+	}
+}
+
+func TestProductApp_CreateProduct(t *testing.T) {
+	stats := &model.ReviewStats{}
+	tests := []struct {
+		name     string
+		req      model.ProductRequest
+		mockCall func(productRepo *productmocks.ProductRepository)
+		want     *model.ProductDetail
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: price rounded before insert",
+			req:  model.ProductRequest{ShopID: 2, Name: "Kopi", Price: 15000.005},
+			mockCall: func(productRepo *productmocks.ProductRepository) {
+				productRepo.On("Create", mock.Anything, &model.ProductRequest{ShopID: 2, Name: "Kopi", Price: 15000.01}).Return(uint64(9), nil).Once()
+				productRepo.On("GetByID", mock.Anything, uint64(9)).Return(&model.ProductDetail{ID: 9, ShopID: 2, Name: "Kopi", Price: 15000.01}, nil).Once()
+				productRepo.On("GetReviewStats", mock.Anything, uint64(9)).Return(stats, nil).Once()
+			},
+			want: &model.ProductDetail{ID: 9, ShopID: 2, Name: "Kopi", Price: 15000.01, AvailabilityStatus: constant.ProductAvailabilityOutOfStock},
+		},
+		{
+			name: "error: unknown shop",
+			req:  model.ProductRequest{ShopID: 404, Name: "Kopi", Price: 1000},
+			mockCall: func(productRepo *productmocks.ProductRepository) {
+				productRepo.On("Create", mock.Anything, mock.Anything).Return(uint64(0), sql.ErrNoRows).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name:     "error: price rounds to zero",
+			req:      model.ProductRequest{ShopID: 2, Name: "Kopi", Price: 0.004},
+			mockCall: func(productRepo *productmocks.ProductRepository) {},
+			wantErr:  true,
+			errCode:  constant.ErrInvalidRequest,
+		},
+		{
+			name: "error: repo failure",
+			req:  model.ProductRequest{ShopID: 2, Name: "Kopi", Price: 1000},
+			mockCall: func(productRepo *productmocks.ProductRepository) {
+				productRepo.On("Create", mock.Anything, mock.Anything).Return(uint64(0), errors.New("db error")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			productRepo := productmocks.NewProductRepository(t)
+			tt.mockCall(productRepo)
+			app := appproduct.NewProductApp(&config.Config{}, productRepo, nil)
+
+			got, err := app.CreateProduct(context.Background(), &tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateProduct() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("CreateProduct() error = %v, want %s", err, constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("CreateProduct() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProductApp_UpdateProduct(t *testing.T) {
+	cfg := &config.Config{Product: config.ProductConfig{CacheTTL: time.Minute}}
+	name := "Kopi Susu"
+	tests := []struct {
+		name     string
+		req      model.ProductUpdateRequest
+		mockCall func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: cached product dropped before reading it back",
+			req:  model.ProductUpdateRequest{Name: &name},
+			mockCall: func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository) {
+				productRepo.On("Update", mock.Anything, uint64(4), &model.ProductUpdateRequest{Name: &name}).Return(nil).Once()
+				redisRepo.On("Delete", mock.Anything, "product:4").Return(nil).Once()
+				redisRepo.On("Get", mock.Anything, "product:4").Return("", nil).Once()
+				productRepo.On("GetByID", mock.Anything, uint64(4)).Return(&model.ProductDetail{ID: 4, Name: name}, nil).Once()
+				redisRepo.On("SetWithTTL", mock.Anything, "product:4", mock.Anything, time.Minute).Return(nil).Once()
+				productRepo.On("GetReviewStats", mock.Anything, uint64(4)).Return(&model.ReviewStats{}, nil).Once()
+			},
+		},
+		{
+			name: "error: missing product",
+			req:  model.ProductUpdateRequest{Name: &name},
+			mockCall: func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository) {
+				productRepo.On("Update", mock.Anything, uint64(4), mock.Anything).Return(sql.ErrNoRows).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name:     "error: nothing to change",
+			mockCall: func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository) {},
+			wantErr:  true,
+			errCode:  constant.ErrInvalidRequest,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			productRepo := productmocks.NewProductRepository(t)
+			redisRepo := redismocks.NewRedisRepository(t)
+			tt.mockCall(productRepo, redisRepo)
+			app := appproduct.NewProductApp(cfg, productRepo, redisRepo)
+
+			got, err := app.UpdateProduct(context.Background(), 4, &tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateProduct() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("UpdateProduct() error = %v, want %s", err, constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+			if got.Name != name {
+				t.Fatalf("UpdateProduct() name = %q, want %q", got.Name, name)
+			}
+		})
+	}
+}
+
+func TestProductApp_DeleteProduct(t *testing.T) {
+	cfg := &config.Config{Product: config.ProductConfig{CacheTTL: time.Minute}}
+	tests := []struct {
+		name     string
+		mockCall func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: deleted and dropped from the cache",
+			mockCall: func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository) {
+				productRepo.On("SoftDelete", mock.Anything, uint64(9)).Return(true, nil).Once()
+				redisRepo.On("Delete", mock.Anything, "product:9").Return(nil).Once()
+			},
+		},
+		{
+			name: "success: failing to drop the cache still deletes",
+			mockCall: func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository) {
+				productRepo.On("SoftDelete", mock.Anything, uint64(9)).Return(true, nil).Once()
+				redisRepo.On("Delete", mock.Anything, "product:9").Return(errors.New("redis down")).Once()
+			},
+		},
+		{
+			name: "error: missing or already deleted",
+			mockCall: func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository) {
+				productRepo.On("SoftDelete", mock.Anything, uint64(9)).Return(false, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name: "error: repo failure",
+			mockCall: func(productRepo *productmocks.ProductRepository, redisRepo *redismocks.RedisRepository) {
+				productRepo.On("SoftDelete", mock.Anything, uint64(9)).Return(false, errors.New("db error")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			productRepo := productmocks.NewProductRepository(t)
+			redisRepo := redismocks.NewRedisRepository(t)
+			tt.mockCall(productRepo, redisRepo)
+			app := appproduct.NewProductApp(cfg, productRepo, redisRepo)
+
+			err := app.DeleteProduct(context.Background(), 9)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeleteProduct() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("DeleteProduct() error = %v, want %s", err, constant.ErrorTypeCode[tt.errCode])
+				}
+			}
+		})
+	}
+}
+
+func TestProductApp_CreateReview(t *testing.T) {
+	type fields struct {
+		productRepo *productmocks.ProductRepository
+	}
+	type args struct {
+		ctx       context.Context
+		userID    uint64
+		productID uint64
+		req       *model.ReviewRequest
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		mockCall func(f fields)
+		want     *model.ProductReview
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: buyer with completed order can review",
+			fields: fields{
+				productRepo: productmocks.NewProductRepository(t),
+			},
+			args: args{
+				ctx:       context.Background(),
+				userID:    1,
+				productID: 5,
+				req:       &model.ReviewRequest{Rating: 5, Comment: "great"},
+			},
+			mockCall: func(f fields) {
+				f.productRepo.
+					On("HasCompletedPurchase", mock.Anything, uint64(1), uint64(5)).
+					Return(true, nil).
+					Once()
+				f.productRepo.
+					On("CreateReview", mock.Anything, &model.ProductReview{UserID: 1, ProductID: 5, Rating: 5, Comment: "great"}).
+					Return(&model.ProductReview{ID: 9, UserID: 1, ProductID: 5, Rating: 5, Comment: "great"}, nil).
+					Once()
+			},
+			want:    &model.ProductReview{ID: 9, UserID: 1, ProductID: 5, Rating: 5, Comment: "great"},
+			wantErr: false,
+		},
+		{
+			name: "error: user without completed order cannot review",
+			fields: fields{
+				productRepo: productmocks.NewProductRepository(t),
+			},
+			args: args{
+				ctx:       context.Background(),
+				userID:    1,
+				productID: 5,
+				req:       &model.ReviewRequest{Rating: 4},
+			},
+			mockCall: func(f fields) {
+				f.productRepo.
+					On("HasCompletedPurchase", mock.Anything, uint64(1), uint64(5)).
+					Return(false, nil).
+					Once()
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrReviewNotAllowed,
+		},
+		{
+			name: "error: product already reviewed by the user",
+			fields: fields{
+				productRepo: productmocks.NewProductRepository(t),
+			},
+			args: args{
+				ctx:       context.Background(),
+				userID:    1,
+				productID: 5,
+				req:       &model.ReviewRequest{Rating: 3},
+			},
+			mockCall: func(f fields) {
+				f.productRepo.
+					On("HasCompletedPurchase", mock.Anything, uint64(1), uint64(5)).
+					Return(true, nil).
+					Once()
+				f.productRepo.
+					On("CreateReview", mock.Anything, &model.ProductReview{UserID: 1, ProductID: 5, Rating: 3}).
+					Return(nil, cerr.SetCustomError(constant.ErrReviewExists)).
+					Once()
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrReviewExists,
+		},
+		{
+			name: "error: repository HasCompletedPurchase returns error",
+			fields: fields{
+				productRepo: productmocks.NewProductRepository(t),
+			},
+			args: args{
+				ctx:       context.Background(),
+				userID:    1,
+				productID: 5,
+				req:       &model.ReviewRequest{Rating: 4},
+			},
+			mockCall: func(f fields) {
+				f.productRepo.
+					On("HasCompletedPurchase", mock.Anything, uint64(1), uint64(5)).
+					Return(false, errors.New("db error")).
+					Once()
+			},
+			want:    nil,
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mockCall != nil {
+				ttFields := tt.fields
+				tt.mockCall(ttFields)
+			}
+			app := appproduct.NewProductApp(&config.Config{}, tt.fields.productRepo, nil)
+
+			got, err := app.CreateReview(tt.args.ctx, tt.args.userID, tt.args.productID, tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateReview() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("CreateReview() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProductApp_ListFeed(t *testing.T) {
+	feedItems := func(ids ...uint64) []model.ProductFeedItem {
+		items := make([]model.ProductFeedItem, 0, len(ids))
+		for _, id := range ids {
+			items = append(items, model.ProductFeedItem{ID: model.ID(id), Name: "p", Price: 1000, Available: id%2 == 0})
+		}
+		return items
+	}
+	tests := []struct {
+		name     string
+		cursor   uint64
+		limit    int
+		mockCall func(productRepo *productmocks.ProductRepository)
+		want     *model.ProductFeedResponse
+		wantErr  bool
+	}{
+		{
+			name:   "success: first page with more to come",
+			cursor: 0,
+			limit:  2,
+			mockCall: func(productRepo *productmocks.ProductRepository) {
+				productRepo.On("ListFeed", mock.Anything, uint64(0), 3).Return(feedItems(1, 2, 3), nil).Once()
+			},
+			want: &model.ProductFeedResponse{Items: feedItems(1, 2), NextCursor: 2, HasMore: true},
+		},
+		{
+			name:   "success: last page has no cursor",
+			cursor: 2,
+			limit:  2,
+			mockCall: func(productRepo *productmocks.ProductRepository) {
+				productRepo.On("ListFeed", mock.Anything, uint64(2), 3).Return(feedItems(3), nil).Once()
+			},
+			want: &model.ProductFeedResponse{Items: feedItems(3)},
+		},
+		{
+			name:   "success: limit defaults and is capped",
+			cursor: 0,
+			limit:  1000,
+			mockCall: func(productRepo *productmocks.ProductRepository) {
+				productRepo.On("ListFeed", mock.Anything, uint64(0), 51).Return(feedItems(), nil).Once()
+			},
+			want: &model.ProductFeedResponse{Items: feedItems()},
+		},
+		{
+			name:   "error: repository failure",
+			cursor: 0,
+			limit:  0,
+			mockCall: func(productRepo *productmocks.ProductRepository) {
+				productRepo.On("ListFeed", mock.Anything, uint64(0), 21).Return(nil, errors.New("db error")).Once()
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			productRepo := productmocks.NewProductRepository(t)
+			tt.mockCall(productRepo)
+			app := appproduct.NewProductApp(&config.Config{}, productRepo, nil)
+
+			got, err := app.ListFeed(context.Background(), tt.cursor, tt.limit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListFeed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[constant.ErrInternal] {
+					t.Fatalf("error = %v, want ErrInternal", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ListFeed() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProductApp_SyncProducts(t *testing.T) {
+	since := time.Date(2025, 11, 30, 0, 0, 0, 0, time.UTC)
+	changed := func(ids ...uint64) []model.ProductSyncItem {
+		items := make([]model.ProductSyncItem, 0, len(ids))
+		for _, id := range ids {
+			items = append(items, model.ProductSyncItem{ID: model.ID(id), Name: "p", Price: 1000, UpdatedAt: since.Add(time.Duration(id) * time.Minute), Deleted: id == 2})
+		}
+		return items
+	}
+
+	t.Run("success: cursor round-trips into the next page", func(t *testing.T) {
+		productRepo := productmocks.NewProductRepository(t)
+		productRepo.On("ListChangedSince", mock.Anything, model.ProductSyncCursor{UpdatedAt: since}, 3).Return(changed(1, 2, 3), nil).Once()
+		productRepo.On("ListChangedSince", mock.Anything, mock.MatchedBy(func(c model.ProductSyncCursor) bool {
+			return c.ID == 2 && c.UpdatedAt.Equal(since.Add(2*time.Minute))
+		}), 3).Return(changed(3), nil).Once()
+		app := appproduct.NewProductApp(&config.Config{}, productRepo, nil)
+
+		first, err := app.SyncProducts(context.Background(), since, "", 2)
+		if err != nil {
+			t.Fatalf("SyncProducts() error = %v", err)
+		}
+		if !reflect.DeepEqual(first.Items, changed(1, 2)) || !first.HasMore || first.NextCursor == "" {
+			t.Fatalf("first page = %+v, want products 1 and 2 with a cursor", first)
+		}
+		if !first.Items[1].Deleted {
+			t.Fatalf("deleted product not flagged: %+v", first.Items[1])
+		}
+
+		second, err := app.SyncProducts(context.Background(), time.Time{}, first.NextCursor, 2)
+		if err != nil {
+			t.Fatalf("SyncProducts() error = %v", err)
+		}
+		if !reflect.DeepEqual(second, &model.ProductSyncResponse{Items: changed(3)}) {
+			t.Fatalf("second page = %+v, want product 3 and no cursor", second)
+		}
+	})
+
+	t.Run("success: limit defaults and is capped", func(t *testing.T) {
+		productRepo := productmocks.NewProductRepository(t)
+		productRepo.On("ListChangedSince", mock.Anything, model.ProductSyncCursor{UpdatedAt: since}, 101).Return(changed(), nil).Once()
+		productRepo.On("ListChangedSince", mock.Anything, model.ProductSyncCursor{UpdatedAt: since}, 501).Return(changed(), nil).Once()
+		app := appproduct.NewProductApp(&config.Config{}, productRepo, nil)
+
+		if _, err := app.SyncProducts(context.Background(), since, "", 0); err != nil {
+			t.Fatalf("SyncProducts() error = %v", err)
+		}
+		if _, err := app.SyncProducts(context.Background(), since, "", 10000); err != nil {
+			t.Fatalf("SyncProducts() error = %v", err)
+		}
+	})
+
+	errTests := []struct {
+		name     string
+		cursor   string
+		mockCall func(productRepo *productmocks.ProductRepository)
+		wantErr  constant.ErrorType
+	}{
+		{
+			name:     "error: cursor is not base64",
+			cursor:   "%%%",
+			mockCall: func(productRepo *productmocks.ProductRepository) {},
+			wantErr:  constant.ErrInvalidRequest,
+		},
+		{
+			name:     "error: cursor is malformed",
+			cursor:   "bm90LWEtY3Vyc29y",
+			mockCall: func(productRepo *productmocks.ProductRepository) {},
+			wantErr:  constant.ErrInvalidRequest,
+		},
+		{
+			name:   "error: repository failure",
+			cursor: "",
+			mockCall: func(productRepo *productmocks.ProductRepository) {
+				productRepo.On("ListChangedSince", mock.Anything, model.ProductSyncCursor{UpdatedAt: since}, 101).Return(nil, errors.New("db error")).Once()
+			},
+			wantErr: constant.ErrInternal,
+		},
+	}
+	for _, tt := range errTests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			productRepo := productmocks.NewProductRepository(t)
+			tt.mockCall(productRepo)
+			app := appproduct.NewProductApp(&config.Config{}, productRepo, nil)
+
+			_, err := app.SyncProducts(context.Background(), since, tt.cursor, 0)
+			var ce cerr.CustomError
+			if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[tt.wantErr] {
+				t.Fatalf("SyncProducts() error = %v, want %v", err, constant.ErrorTypeCode[tt.wantErr])
+			}
+		})
+	}
+}
+
+func TestProductApp_AvailabilityStatus(t *testing.T) {
+	cfg := &config.Config{Product: config.ProductConfig{LowStockThreshold: 5}}
+	tests := []struct {
+		name      string
+		threshold int64
+		available int64
+		want      constant.ProductAvailability
+	}{
+		{"nothing available is out of stock", 5, 0, constant.ProductAvailabilityOutOfStock},
+		{"oversold stock is out of stock", 5, -2, constant.ProductAvailabilityOutOfStock},
+		{"a single unit is low stock", 5, 1, constant.ProductAvailabilityLowStock},
+		{"at the threshold is low stock", 5, 5, constant.ProductAvailabilityLowStock},
+		{"above the threshold is in stock", 5, 6, constant.ProductAvailabilityInStock},
+		{"zero threshold disables low stock", 0, 1, constant.ProductAvailabilityInStock},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Product.LowStockThreshold = tt.threshold
+
+			productRepo := productmocks.NewProductRepository(t)
+			productRepo.On("GetByID", mock.Anything, uint64(1)).Return(&model.ProductDetail{ID: 1, AvailableStock: tt.available}, nil).Once()
+			productRepo.On("GetReviewStats", mock.Anything, uint64(1)).Return(&model.ReviewStats{}, nil).Once()
+			productRepo.On("List", mock.Anything, 1, 10, constant.ProductSortDefault).Return([]model.ProductListItem{{ID: 1, AvailableStock: tt.available}}, int64(1), nil).Once()
+			app := appproduct.NewProductApp(cfg, productRepo, nil)
+
+			detail, err := app.GetProduct(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("GetProduct() error = %v", err)
+			}
+			if detail.AvailabilityStatus != tt.want {
+				t.Errorf("GetProduct() availability = %q, want %q", detail.AvailabilityStatus, tt.want)
+			}
+
+			list, err := app.ListProducts(context.Background(), 1, 10, constant.ProductSortDefault)
+			if err != nil {
+				t.Fatalf("ListProducts() error = %v", err)
+			}
+			if list.Items[0].AvailabilityStatus != tt.want {
+				t.Errorf("ListProducts() availability = %q, want %q", list.Items[0].AvailabilityStatus, tt.want)
+			}
+		})
+	}
+}
+
+func TestProductApp_SubscribeBackInStock(t *testing.T) {
+	tests := []struct {
+		name     string
+		mockCall func(productRepo *productmocks.ProductRepository)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: out of stock product is subscribed",
+			mockCall: func(productRepo *productmocks.ProductRepository) {
+				productRepo.On("GetByID", mock.Anything, uint64(1)).Return(&model.ProductDetail{ID: 1, AvailableStock: 0}, nil).Once()
+				productRepo.On("AddStockSubscription", mock.Anything, uint64(7), uint64(1)).Return(nil).Once()
+			},
+		},
+		{
+			name: "error: product in stock",
+			mockCall: func(productRepo *productmocks.ProductRepository) {
+				productRepo.On("GetByID", mock.Anything, uint64(1)).Return(&model.ProductDetail{ID: 1, AvailableStock: 3}, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInvalidRequest,
+		},
+		{
+			name: "error: product not found",
+			mockCall: func(productRepo *productmocks.ProductRepository) {
+				productRepo.On("GetByID", mock.Anything, uint64(1)).Return(nil, sql.ErrNoRows).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name: "error: repository failure",
+			mockCall: func(productRepo *productmocks.ProductRepository) {
+				productRepo.On("GetByID", mock.Anything, uint64(1)).Return(&model.ProductDetail{ID: 1}, nil).Once()
+				productRepo.On("AddStockSubscription", mock.Anything, uint64(7), uint64(1)).Return(errors.New("db error")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			productRepo := productmocks.NewProductRepository(t)
+			tt.mockCall(productRepo)
+			app := appproduct.NewProductApp(&config.Config{}, productRepo, nil)
+
+			err := app.SubscribeBackInStock(context.Background(), 7, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SubscribeBackInStock() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("SubscribeBackInStock() error = %v, want %v", err, constant.ErrorTypeCode[tt.errCode])
+				}
+			}
+		})
+	}
+}
@@ -2,8 +2,10 @@ package user
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -12,6 +14,7 @@ import (
 	"github.com/muhammadheryan/e-commerce/constant"
 	"github.com/muhammadheryan/e-commerce/model"
 	redisrepo "github.com/muhammadheryan/e-commerce/repository/redis"
+	txrepo "github.com/muhammadheryan/e-commerce/repository/tx"
 	userrepo "github.com/muhammadheryan/e-commerce/repository/user"
 	"github.com/muhammadheryan/e-commerce/utils/errors"
 	"github.com/muhammadheryan/e-commerce/utils/logger"
@@ -21,27 +24,80 @@ import (
 
 type UserApp interface {
 	Register(ctx context.Context, req *model.RegisterRequest) (*model.RegisterResponse, error)
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, userID uint64) (*model.ResendVerificationResponse, error)
 	Login(ctx context.Context, req *model.LoginRequest) (*model.LoginResponse, error)
 	ValidateToken(ctx context.Context, tokenString string) (uint64, error)
+	ListAddresses(ctx context.Context, userID uint64) ([]model.UserAddress, error)
+	AddAddress(ctx context.Context, userID uint64, req *model.ShippingAddress) (*model.UserAddress, error)
+	UpdateAddress(ctx context.Context, userID, addressID uint64, req *model.ShippingAddress) (*model.UserAddress, error)
+	DeleteAddress(ctx context.Context, userID, addressID uint64) error
+	GetNotificationPrefs(ctx context.Context, userID uint64) (*model.NotificationPrefs, error)
+	UpdateNotificationPrefs(ctx context.Context, userID uint64, req *model.UpdateNotificationPrefsRequest) (*model.NotificationPrefs, error)
+	ShouldNotify(ctx context.Context, userID uint64, notificationType constant.NotificationType) (bool, error)
+	ListSessions(ctx context.Context, userID uint64) ([]model.Session, error)
+	RevokeAllSessions(ctx context.Context, userID uint64) error
 }
 
 type UserAppImpl struct {
 	config    *config.Config
+	txRepo    txrepo.TxRepository
 	userRepo  userrepo.UserRepository
 	redisRepo redisrepo.RedisRepository
+	// keys and parser are derived from the config once, the config doesn't
+	// change after startup and both are only read afterwards
+	keys   jwtKeys
+	parser *jwt.Parser
 }
 
-func NewUserApp(config *config.Config, userRepo userrepo.UserRepository, redisRepo redisrepo.RedisRepository) UserApp {
-	return &UserAppImpl{
+func NewUserApp(config *config.Config, txRepo txrepo.TxRepository, userRepo userrepo.UserRepository, redisRepo redisrepo.RedisRepository) UserApp {
+	s := &UserAppImpl{
 		config:    config,
+		txRepo:    txRepo,
 		userRepo:  userRepo,
 		redisRepo: redisRepo,
+		parser:    jwt.NewParser(),
 	}
+	if config != nil {
+		s.keys = newJWTKeys(config.Auth)
+	}
+	return s
+}
+
+// jwtKeys holds the secrets of the auth config as the byte keys the jwt
+// package takes
+type jwtKeys struct {
+	signingKID string
+	signing    []byte
+	// accepted maps every known key id, the signing one included, to its key
+	accepted map[string][]byte
+	// legacy lists the keys a token without a kid is tried against, the
+	// signing one first
+	legacy [][]byte
+}
+
+func newJWTKeys(auth config.AuthConfig) jwtKeys {
+	keys := jwtKeys{
+		signingKID: auth.JWTKeyID,
+		signing:    []byte(auth.JWTSecret),
+		accepted:   make(map[string][]byte, len(auth.JWTAcceptedSecrets)+1),
+	}
+	keys.legacy = append(keys.legacy, keys.signing)
+	for kid, secret := range auth.JWTAcceptedSecrets {
+		key := []byte(secret)
+		keys.accepted[kid] = key
+		keys.legacy = append(keys.legacy, key)
+	}
+	keys.accepted[auth.JWTKeyID] = keys.signing
+	return keys
 }
 
 func (s *UserAppImpl) Register(ctx context.Context, req *model.RegisterRequest) (*model.RegisterResponse, error) {
+	email := normalizeEmail(req.Email)
+	phone := normalizePhone(req.Phone)
+
 	// Check if user exists by email or phone
-	existingUser, err := s.userRepo.Get(ctx, &model.UserFilter{Email: req.Email})
+	existingUser, err := s.userRepo.Get(ctx, &model.UserFilter{Email: email})
 	if err != nil {
 		logger.Error("[Register] err userRepo.Get email", zap.String("error", err.Error()))
 		return nil, errors.SetCustomError(constant.ErrInternal)
@@ -51,7 +107,7 @@ func (s *UserAppImpl) Register(ctx context.Context, req *model.RegisterRequest)
 		return nil, errors.SetCustomError(constant.ErrCredentialExists)
 	}
 
-	existingUser, err = s.userRepo.Get(ctx, &model.UserFilter{Phone: req.Phone})
+	existingUser, err = s.userRepo.Get(ctx, &model.UserFilter{Phone: phone})
 	if err != nil {
 		logger.Error("[Register] err userRepo.Get phone", zap.String("error", err.Error()))
 		return nil, errors.SetCustomError(constant.ErrInternal)
@@ -61,7 +117,7 @@ func (s *UserAppImpl) Register(ctx context.Context, req *model.RegisterRequest)
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), s.config.Auth.PasswordCost())
 	if err != nil {
 		logger.Error("[Register] err bcrypt.GenerateFromPassword", zap.String("error", err.Error()))
 		return nil, errors.SetCustomError(constant.ErrInternal)
@@ -70,31 +126,107 @@ func (s *UserAppImpl) Register(ctx context.Context, req *model.RegisterRequest)
 	// Create user entity
 	userEntity := &model.UserEntity{
 		Name:         req.Name,
-		Email:        req.Email,
-		Phone:        req.Phone,
+		Email:        email,
+		Phone:        phone,
 		PasswordHash: string(hashedPassword),
 	}
 
-	// Save to database
-	userEntity, err = s.userRepo.Create(ctx, userEntity)
+	// Save to database, whatever else registration writes belongs in the same
+	// transaction so a failure never leaves a half-created user behind
+	tx, err := s.txRepo.BeginTx(ctx)
 	if err != nil {
-		logger.Error("[Register] err userRepo.Create", zap.String("error", err.Error()))
+		logger.Error("[Register] err txRepo.BeginTx", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = s.txRepo.RollbackTx(tx)
+		}
+	}()
+
+	userEntity, err = s.userRepo.CreateTx(ctx, tx, userEntity)
+	if err != nil {
+		logger.Error("[Register] err userRepo.CreateTx", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	// the token is stored before committing, a user that couldn't be given one
+	// is rolled back instead of being left unable to ever verify
+	verificationToken := uuid.NewString()
+	if err := s.redisRepo.SetVerificationToken(ctx, verificationToken, uint64(userEntity.ID), s.config.Auth.VerificationTokenTTL); err != nil {
+		logger.Error("[Register] err redisRepo.SetVerificationToken", zap.String("error", err.Error()))
 		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
 
+	if err := s.txRepo.CommitTx(tx); err != nil {
+		logger.Error("[Register] err txRepo.CommitTx", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	committed = true
+
 	return &model.RegisterResponse{
-		Name:  userEntity.Name,
-		Email: userEntity.Email,
+		Name:              userEntity.Name,
+		Email:             userEntity.Email,
+		VerificationToken: verificationToken,
 	}, nil
 }
 
+// VerifyEmail confirms the email of the user the token was issued to. A token
+// works once, an unknown, used or expired one is ErrInvalidVerificationToken.
+func (s *UserAppImpl) VerifyEmail(ctx context.Context, token string) error {
+	userID, err := s.redisRepo.TakeVerificationToken(ctx, token)
+	if err != nil {
+		logger.Error("[VerifyEmail] err redisRepo.TakeVerificationToken", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if userID == 0 {
+		return errors.SetCustomError(constant.ErrInvalidVerificationToken)
+	}
+
+	if err := s.userRepo.MarkVerified(ctx, userID); err != nil {
+		logger.Error("[VerifyEmail] err userRepo.MarkVerified", zap.String("error", err.Error()), zap.Uint64("user_id", userID))
+		// give the token back so the user can retry with it
+		if err := s.redisRepo.SetVerificationToken(ctx, token, userID, s.config.Auth.VerificationTokenTTL); err != nil {
+			logger.Error("[VerifyEmail] err redisRepo.SetVerificationToken", zap.String("error", err.Error()), zap.Uint64("user_id", userID))
+		}
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	return nil
+}
+
+// ResendVerification issues a fresh verification token to a user whose email
+// isn't verified yet, e.g. because the one from register expired. Tokens issued
+// earlier stay valid until their own TTL.
+func (s *UserAppImpl) ResendVerification(ctx context.Context, userID uint64) (*model.ResendVerificationResponse, error) {
+	user, err := s.userRepo.Get(ctx, &model.UserFilter{ID: userID})
+	if err != nil {
+		logger.Error("[ResendVerification] err userRepo.Get", zap.String("error", err.Error()), zap.Uint64("user_id", userID))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	if user == nil {
+		return nil, errors.SetCustomError(constant.ErrNotFound)
+	}
+	if user.IsVerified {
+		return nil, errors.SetCustomError(constant.ErrEmailAlreadyVerified)
+	}
+
+	verificationToken := uuid.NewString()
+	if err := s.redisRepo.SetVerificationToken(ctx, verificationToken, userID, s.config.Auth.VerificationTokenTTL); err != nil {
+		logger.Error("[ResendVerification] err redisRepo.SetVerificationToken", zap.String("error", err.Error()), zap.Uint64("user_id", userID))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	return &model.ResendVerificationResponse{VerificationToken: verificationToken}, nil
+}
+
 func (s *UserAppImpl) Login(ctx context.Context, req *model.LoginRequest) (*model.LoginResponse, error) {
 	// Find user by email or phone
 	filter := &model.UserFilter{}
-	if isEmail(req.Identifier) {
-		filter.Email = req.Identifier
+	identifier := strings.TrimSpace(req.Identifier)
+	if isEmail(identifier) {
+		filter.Email = normalizeEmail(identifier)
 	} else {
-		filter.Phone = req.Identifier
+		filter.Phone = normalizePhone(identifier)
 	}
 
 	user, err := s.userRepo.Get(ctx, filter)
@@ -114,14 +246,22 @@ func (s *UserAppImpl) Login(ctx context.Context, req *model.LoginRequest) (*mode
 	}
 
 	// Generate JWT token
-	token, jti, err := s.generateJWT(user.ID)
+	token, jti, err := s.generateJWT(uint64(user.ID))
 	if err != nil {
 		logger.Error("[Login] err generateJWT", zap.String("error", err.Error()))
 		return nil, errors.SetCustomError(constant.ErrInternal)
 	}
 
+	// Single-session mode: a new login revokes every earlier session
+	if s.config.Auth.SingleSession {
+		if err := s.redisRepo.DeleteAllSessions(ctx, uint64(user.ID)); err != nil {
+			logger.Error("[Login] err DeleteAllSessions", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+	}
+
 	// Store session in Redis
-	err = s.redisRepo.SetSession(ctx, jti, user.ID, s.config.Auth.SessionExpTime)
+	err = s.redisRepo.SetSession(ctx, jti, uint64(user.ID), s.config.Auth.SessionExpTime)
 	if err != nil {
 		logger.Error("[Login] err SetSession", zap.String("error", err.Error()))
 		return nil, errors.SetCustomError(constant.ErrInternal)
@@ -136,9 +276,7 @@ func (s *UserAppImpl) Login(ctx context.Context, req *model.LoginRequest) (*mode
 
 func (s *UserAppImpl) ValidateToken(ctx context.Context, tokenString string) (uint64, error) {
 	// Parse token
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.config.Auth.JWTSecret), nil
-	})
+	token, err := s.parseJWT(tokenString)
 	if err != nil {
 		return 0, fmt.Errorf("invalid token: %w", err)
 	}
@@ -176,6 +314,149 @@ func (s *UserAppImpl) ValidateToken(ctx context.Context, tokenString string) (ui
 	return userID, nil
 }
 
+func (s *UserAppImpl) ListAddresses(ctx context.Context, userID uint64) ([]model.UserAddress, error) {
+	addresses, err := s.userRepo.ListAddresses(ctx, userID)
+	if err != nil {
+		logger.Error("[ListAddresses] err userRepo.ListAddresses", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	return addresses, nil
+}
+
+func (s *UserAppImpl) AddAddress(ctx context.Context, userID uint64, req *model.ShippingAddress) (*model.UserAddress, error) {
+	addr, err := s.userRepo.CreateAddress(ctx, &model.UserAddress{
+		UserID:          userID,
+		ShippingAddress: *req,
+	})
+	if err != nil {
+		logger.Error("[AddAddress] err userRepo.CreateAddress", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	return addr, nil
+}
+
+func (s *UserAppImpl) UpdateAddress(ctx context.Context, userID, addressID uint64, req *model.ShippingAddress) (*model.UserAddress, error) {
+	addr, err := s.getOwnedAddress(ctx, userID, addressID)
+	if err != nil {
+		return nil, err
+	}
+
+	addr.ShippingAddress = *req
+	if err := s.userRepo.UpdateAddress(ctx, addr); err != nil {
+		logger.Error("[UpdateAddress] err userRepo.UpdateAddress", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	return addr, nil
+}
+
+func (s *UserAppImpl) DeleteAddress(ctx context.Context, userID, addressID uint64) error {
+	if _, err := s.getOwnedAddress(ctx, userID, addressID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.DeleteAddress(ctx, userID, addressID); err != nil {
+		logger.Error("[DeleteAddress] err userRepo.DeleteAddress", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	return nil
+}
+
+// getOwnedAddress loads an address and makes sure it belongs to the user.
+// Addresses of other users are reported as not found.
+func (s *UserAppImpl) getOwnedAddress(ctx context.Context, userID, addressID uint64) (*model.UserAddress, error) {
+	addr, err := s.userRepo.GetAddress(ctx, addressID)
+	if err != nil {
+		logger.Error("[getOwnedAddress] err userRepo.GetAddress", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	if addr == nil || addr.UserID != userID {
+		return nil, errors.SetCustomError(constant.ErrNotFound)
+	}
+	return addr, nil
+}
+
+// defaultNotificationPrefs applies to users who never saved preferences:
+// order updates on, everything promotional off
+var defaultNotificationPrefs = model.NotificationPrefs{
+	OrderUpdates:     true,
+	Marketing:        false,
+	WishlistLowStock: false,
+}
+
+func (s *UserAppImpl) GetNotificationPrefs(ctx context.Context, userID uint64) (*model.NotificationPrefs, error) {
+	prefs, err := s.userRepo.GetNotificationPrefs(ctx, userID)
+	if err != nil {
+		logger.Error("[GetNotificationPrefs] err userRepo.GetNotificationPrefs", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	if prefs == nil {
+		defaults := defaultNotificationPrefs
+		return &defaults, nil
+	}
+	return prefs, nil
+}
+
+func (s *UserAppImpl) UpdateNotificationPrefs(ctx context.Context, userID uint64, req *model.UpdateNotificationPrefsRequest) (*model.NotificationPrefs, error) {
+	prefs, err := s.GetNotificationPrefs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.OrderUpdates != nil {
+		prefs.OrderUpdates = *req.OrderUpdates
+	}
+	if req.Marketing != nil {
+		prefs.Marketing = *req.Marketing
+	}
+	if req.WishlistLowStock != nil {
+		prefs.WishlistLowStock = *req.WishlistLowStock
+	}
+
+	if err := s.userRepo.UpsertNotificationPrefs(ctx, userID, prefs); err != nil {
+		logger.Error("[UpdateNotificationPrefs] err userRepo.UpsertNotificationPrefs", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	return prefs, nil
+}
+
+// ShouldNotify reports whether the user opted in to a notification type.
+// Anything that emits user-facing email or push messages must check it first.
+func (s *UserAppImpl) ShouldNotify(ctx context.Context, userID uint64, notificationType constant.NotificationType) (bool, error) {
+	prefs, err := s.GetNotificationPrefs(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	switch notificationType {
+	case constant.NotificationOrderUpdates:
+		return prefs.OrderUpdates, nil
+	case constant.NotificationMarketing:
+		return prefs.Marketing, nil
+	case constant.NotificationWishlistLowStock:
+		return prefs.WishlistLowStock, nil
+	}
+	return false, nil
+}
+
+// ListSessions lists the user's active sessions, soonest to expire first
+func (s *UserAppImpl) ListSessions(ctx context.Context, userID uint64) ([]model.Session, error) {
+	sessions, err := s.redisRepo.ListSessions(ctx, userID)
+	if err != nil {
+		logger.Error("[ListSessions] err redisRepo.ListSessions", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	return sessions, nil
+}
+
+// RevokeAllSessions logs the user out everywhere, including the session making the call
+func (s *UserAppImpl) RevokeAllSessions(ctx context.Context, userID uint64) error {
+	if err := s.redisRepo.DeleteAllSessions(ctx, userID); err != nil {
+		logger.Error("[RevokeAllSessions] err redisRepo.DeleteAllSessions", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	return nil
+}
+
 // generateJWT creates a JWT token for the user
 func (s *UserAppImpl) generateJWT(userID uint64) (string, string, error) {
 	newUUID, _ := uuid.NewRandom()
@@ -187,7 +468,8 @@ func (s *UserAppImpl) generateJWT(userID uint64) (string, string, error) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.config.Auth.JWTSecret))
+	token.Header["kid"] = s.keys.signingKID
+	tokenString, err := token.SignedString(s.keys.signing)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -195,6 +477,69 @@ func (s *UserAppImpl) generateJWT(userID uint64) (string, string, error) {
 	return tokenString, claims.ID, nil
 }
 
+// parseJWT verifies the token with the secret named by its kid header. Tokens
+// issued before key ids were added have none, they are tried against every
+// known secret, the signing one first.
+func (s *UserAppImpl) parseJWT(tokenString string) (*jwt.Token, error) {
+	var legacy bool
+	token, err := s.parser.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if err := requireHMAC(token); err != nil {
+			return nil, err
+		}
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			legacy = true
+			return s.keys.signing, nil
+		}
+		key, ok := s.keys.accepted[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	})
+	if err == nil || !legacy || !goerrors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		return token, err
+	}
+
+	for _, key := range s.keys.legacy[1:] {
+		token, retryErr := s.parser.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+			if err := requireHMAC(token); err != nil {
+				return nil, err
+			}
+			return key, nil
+		})
+		if retryErr == nil {
+			return token, nil
+		}
+	}
+	return nil, err
+}
+
+// requireHMAC rejects tokens signed with anything but the HMAC family the
+// secrets are for, a token claiming alg=none or an asymmetric algorithm must
+// never be checked against them
+func requireHMAC(token *jwt.Token) error {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+	return nil
+}
+
+// normalizeEmail is the form emails are stored and looked up in, so the same
+// address in a different case is the same user
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// normalizePhone stores Indonesian numbers in local form, +628... becomes 08...
+func normalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	if rest, ok := strings.CutPrefix(phone, "+62"); ok {
+		return "0" + rest
+	}
+	return phone
+}
+
 // isEmail checks if identifier looks like an email
 func isEmail(identifier string) bool {
 	for _, r := range identifier {
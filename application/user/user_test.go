@@ -4,23 +4,30 @@ import (
 	"context"
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
 	appuser "github.com/muhammadheryan/e-commerce/application/user"
 	"github.com/muhammadheryan/e-commerce/cmd/config"
 	"github.com/muhammadheryan/e-commerce/constant"
 	redismocks "github.com/muhammadheryan/e-commerce/mocks/repository/redis"
+	txmocks "github.com/muhammadheryan/e-commerce/mocks/repository/tx"
 	usermocks "github.com/muhammadheryan/e-commerce/mocks/repository/user"
 	"github.com/muhammadheryan/e-commerce/model"
+	redisrepo "github.com/muhammadheryan/e-commerce/repository/redis"
 	cerr "github.com/muhammadheryan/e-commerce/utils/errors"
 	"github.com/stretchr/testify/mock"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func TestUserApp_Register(t *testing.T) {
+	tx := &sqlx.Tx{}
 	type fields struct {
 		config    *config.Config
+		txRepo    *txmocks.TxRepository
 		userRepo  *usermocks.UserRepository
 		redisRepo *redismocks.RedisRepository
 	}
@@ -42,11 +49,13 @@ func TestUserApp_Register(t *testing.T) {
 			fields: fields{
 				config: &config.Config{
 					Auth: config.AuthConfig{
-						JWTSecret:      "test-secret",
-						JWTExpiration:  time.Hour,
-						SessionExpTime: time.Hour,
+						JWTSecret:            "test-secret",
+						JWTExpiration:        time.Hour,
+						SessionExpTime:       time.Hour,
+						VerificationTokenTTL: 24 * time.Hour,
 					},
 				},
+				txRepo:    txmocks.NewTxRepository(t),
 				userRepo:  usermocks.NewUserRepository(t),
 				redisRepo: redismocks.NewRedisRepository(t),
 			},
@@ -73,8 +82,9 @@ func TestUserApp_Register(t *testing.T) {
 					Once()
 
 				// Create user
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
 				f.userRepo.
-					On("Create", mock.Anything, mock.MatchedBy(func(ent *model.UserEntity) bool {
+					On("CreateTx", mock.Anything, tx, mock.MatchedBy(func(ent *model.UserEntity) bool {
 						return ent.Name == "Test User" &&
 							ent.Email == "test@example.com" &&
 							ent.Phone == "081234567890" &&
@@ -89,6 +99,8 @@ func TestUserApp_Register(t *testing.T) {
 						CreatedAt:    time.Now(),
 					}, nil).
 					Once()
+				f.redisRepo.On("SetVerificationToken", mock.Anything, mock.AnythingOfType("string"), uint64(1), 24*time.Hour).Return(nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
 			},
 			want: &model.RegisterResponse{
 				Name:  "Test User",
@@ -106,6 +118,7 @@ func TestUserApp_Register(t *testing.T) {
 						SessionExpTime: time.Hour,
 					},
 				},
+				txRepo:    txmocks.NewTxRepository(t),
 				userRepo:  usermocks.NewUserRepository(t),
 				redisRepo: redismocks.NewRedisRepository(t),
 			},
@@ -141,6 +154,7 @@ func TestUserApp_Register(t *testing.T) {
 						SessionExpTime: time.Hour,
 					},
 				},
+				txRepo:    txmocks.NewTxRepository(t),
 				userRepo:  usermocks.NewUserRepository(t),
 				redisRepo: redismocks.NewRedisRepository(t),
 			},
@@ -181,6 +195,7 @@ func TestUserApp_Register(t *testing.T) {
 						SessionExpTime: time.Hour,
 					},
 				},
+				txRepo:    txmocks.NewTxRepository(t),
 				userRepo:  usermocks.NewUserRepository(t),
 				redisRepo: redismocks.NewRedisRepository(t),
 			},
@@ -213,6 +228,7 @@ func TestUserApp_Register(t *testing.T) {
 						SessionExpTime: time.Hour,
 					},
 				},
+				txRepo:    txmocks.NewTxRepository(t),
 				userRepo:  usermocks.NewUserRepository(t),
 				redisRepo: redismocks.NewRedisRepository(t),
 			},
@@ -236,10 +252,12 @@ func TestUserApp_Register(t *testing.T) {
 					Return(nil, nil).
 					Once()
 
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
 				f.userRepo.
-					On("Create", mock.Anything, mock.AnythingOfType("*model.UserEntity")).
+					On("CreateTx", mock.Anything, tx, mock.AnythingOfType("*model.UserEntity")).
 					Return(nil, errors.New("create failed")).
 					Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
 			},
 			want:    nil,
 			wantErr: true,
@@ -253,7 +271,7 @@ func TestUserApp_Register(t *testing.T) {
 				ttFields := tt.fields
 				tt.mockCall(ttFields)
 			}
-			app := appuser.NewUserApp(tt.fields.config, tt.fields.userRepo, tt.fields.redisRepo)
+			app := appuser.NewUserApp(tt.fields.config, tt.fields.txRepo, tt.fields.userRepo, tt.fields.redisRepo)
 
 			got, err := app.Register(tt.args.ctx, tt.args.req)
 			if (err != nil) != tt.wantErr {
@@ -271,6 +289,11 @@ func TestUserApp_Register(t *testing.T) {
 				return
 			}
 
+			// the token is random, it only has to be there
+			if got.VerificationToken == "" {
+				t.Fatal("Register() returned no verification token")
+			}
+			tt.want.VerificationToken = got.VerificationToken
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Fatalf("Register() = %+v, want %+v", got, tt.want)
 			}
@@ -278,6 +301,184 @@ func TestUserApp_Register(t *testing.T) {
 	}
 }
 
+func TestUserApp_RegisterRollsBackOnFailedWrite(t *testing.T) {
+	cfg := &config.Config{Auth: config.AuthConfig{BcryptCost: bcrypt.MinCost}}
+	txRepo := txmocks.NewTxRepository(t)
+	userRepo := usermocks.NewUserRepository(t)
+	redisRepo := redismocks.NewRedisRepository(t)
+	app := appuser.NewUserApp(cfg, txRepo, userRepo, redisRepo)
+
+	// the user row is written, then a later write of the registration fails,
+	// simulated by the commit: the insert must be rolled back, not kept
+	tx := &sqlx.Tx{}
+	userRepo.On("Get", mock.Anything, mock.Anything).Return(nil, nil).Twice()
+	txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+	userRepo.On("CreateTx", mock.Anything, tx, mock.Anything).Return(&model.UserEntity{ID: 1}, nil).Once()
+	redisRepo.On("SetVerificationToken", mock.Anything, mock.Anything, uint64(1), mock.Anything).Return(nil).Once()
+	txRepo.On("CommitTx", tx).Return(errors.New("write failed")).Once()
+	txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+	_, err := app.Register(context.Background(), &model.RegisterRequest{
+		Name:     "Test User",
+		Email:    "test@example.com",
+		Phone:    "081234567890",
+		Password: "password123",
+	})
+	var ce cerr.CustomError
+	if !errors.As(err, &ce) || ce.ErrorType() != constant.ErrInternal {
+		t.Fatalf("Register() error = %v, want ErrInternal", err)
+	}
+}
+
+func TestUserApp_RegisterRollsBackWhenTokenNotStored(t *testing.T) {
+	cfg := &config.Config{Auth: config.AuthConfig{BcryptCost: bcrypt.MinCost, VerificationTokenTTL: 24 * time.Hour}}
+	txRepo := txmocks.NewTxRepository(t)
+	userRepo := usermocks.NewUserRepository(t)
+	redisRepo := redismocks.NewRedisRepository(t)
+	app := appuser.NewUserApp(cfg, txRepo, userRepo, redisRepo)
+
+	// a user without a way to verify could never order, so no user is kept
+	tx := &sqlx.Tx{}
+	userRepo.On("Get", mock.Anything, mock.Anything).Return(nil, nil).Twice()
+	txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+	userRepo.On("CreateTx", mock.Anything, tx, mock.Anything).Return(&model.UserEntity{ID: 1}, nil).Once()
+	redisRepo.On("SetVerificationToken", mock.Anything, mock.Anything, uint64(1), 24*time.Hour).Return(errors.New("redis down")).Once()
+	txRepo.On("RollbackTx", tx).Return(nil).Once()
+
+	_, err := app.Register(context.Background(), &model.RegisterRequest{
+		Name:     "Test User",
+		Email:    "test@example.com",
+		Phone:    "081234567890",
+		Password: "password123",
+	})
+	var ce cerr.CustomError
+	if !errors.As(err, &ce) || ce.ErrorType() != constant.ErrInternal {
+		t.Fatalf("Register() error = %v, want ErrInternal", err)
+	}
+}
+
+func TestUserApp_VerifyEmail(t *testing.T) {
+	cfg := &config.Config{Auth: config.AuthConfig{VerificationTokenTTL: 24 * time.Hour}}
+	tests := []struct {
+		name    string
+		mock    func(userRepo *usermocks.UserRepository, redisRepo *redismocks.RedisRepository)
+		wantErr constant.ErrorType
+	}{
+		{
+			name: "success",
+			mock: func(userRepo *usermocks.UserRepository, redisRepo *redismocks.RedisRepository) {
+				redisRepo.On("TakeVerificationToken", mock.Anything, "tok").Return(uint64(3), nil).Once()
+				userRepo.On("MarkVerified", mock.Anything, uint64(3)).Return(nil).Once()
+			},
+		},
+		{
+			name: "unknown or used token",
+			mock: func(userRepo *usermocks.UserRepository, redisRepo *redismocks.RedisRepository) {
+				redisRepo.On("TakeVerificationToken", mock.Anything, "tok").Return(uint64(0), nil).Once()
+			},
+			wantErr: constant.ErrInvalidVerificationToken,
+		},
+		{
+			name: "redis error",
+			mock: func(userRepo *usermocks.UserRepository, redisRepo *redismocks.RedisRepository) {
+				redisRepo.On("TakeVerificationToken", mock.Anything, "tok").Return(uint64(0), errors.New("redis down")).Once()
+			},
+			wantErr: constant.ErrInternal,
+		},
+		{
+			name: "mark verified fails, token is given back",
+			mock: func(userRepo *usermocks.UserRepository, redisRepo *redismocks.RedisRepository) {
+				redisRepo.On("TakeVerificationToken", mock.Anything, "tok").Return(uint64(3), nil).Once()
+				userRepo.On("MarkVerified", mock.Anything, uint64(3)).Return(errors.New("db down")).Once()
+				redisRepo.On("SetVerificationToken", mock.Anything, "tok", uint64(3), 24*time.Hour).Return(nil).Once()
+			},
+			wantErr: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := usermocks.NewUserRepository(t)
+			redisRepo := redismocks.NewRedisRepository(t)
+			tt.mock(userRepo, redisRepo)
+			app := appuser.NewUserApp(cfg, txmocks.NewTxRepository(t), userRepo, redisRepo)
+
+			err := app.VerifyEmail(context.Background(), "tok")
+			if tt.wantErr == constant.Successful {
+				if err != nil {
+					t.Fatalf("VerifyEmail() error = %v", err)
+				}
+				return
+			}
+			var ce cerr.CustomError
+			if !errors.As(err, &ce) || ce.ErrorType() != tt.wantErr {
+				t.Fatalf("VerifyEmail() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUserApp_ResendVerification(t *testing.T) {
+	cfg := &config.Config{Auth: config.AuthConfig{VerificationTokenTTL: 24 * time.Hour}}
+	tests := []struct {
+		name    string
+		mock    func(userRepo *usermocks.UserRepository, redisRepo *redismocks.RedisRepository)
+		wantErr constant.ErrorType
+	}{
+		{
+			name: "unverified user gets a fresh token",
+			mock: func(userRepo *usermocks.UserRepository, redisRepo *redismocks.RedisRepository) {
+				userRepo.On("Get", mock.Anything, &model.UserFilter{ID: 3}).Return(&model.UserEntity{ID: 3}, nil).Once()
+				redisRepo.On("SetVerificationToken", mock.Anything, mock.AnythingOfType("string"), uint64(3), 24*time.Hour).Return(nil).Once()
+			},
+		},
+		{
+			name: "already verified",
+			mock: func(userRepo *usermocks.UserRepository, redisRepo *redismocks.RedisRepository) {
+				userRepo.On("Get", mock.Anything, &model.UserFilter{ID: 3}).Return(&model.UserEntity{ID: 3, IsVerified: true}, nil).Once()
+			},
+			wantErr: constant.ErrEmailAlreadyVerified,
+		},
+		{
+			name: "unknown user",
+			mock: func(userRepo *usermocks.UserRepository, redisRepo *redismocks.RedisRepository) {
+				userRepo.On("Get", mock.Anything, &model.UserFilter{ID: 3}).Return(nil, nil).Once()
+			},
+			wantErr: constant.ErrNotFound,
+		},
+		{
+			name: "token not stored",
+			mock: func(userRepo *usermocks.UserRepository, redisRepo *redismocks.RedisRepository) {
+				userRepo.On("Get", mock.Anything, &model.UserFilter{ID: 3}).Return(&model.UserEntity{ID: 3}, nil).Once()
+				redisRepo.On("SetVerificationToken", mock.Anything, mock.AnythingOfType("string"), uint64(3), 24*time.Hour).Return(errors.New("redis down")).Once()
+			},
+			wantErr: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := usermocks.NewUserRepository(t)
+			redisRepo := redismocks.NewRedisRepository(t)
+			tt.mock(userRepo, redisRepo)
+			app := appuser.NewUserApp(cfg, txmocks.NewTxRepository(t), userRepo, redisRepo)
+
+			got, err := app.ResendVerification(context.Background(), 3)
+			if tt.wantErr == constant.Successful {
+				if err != nil {
+					t.Fatalf("ResendVerification() error = %v", err)
+				}
+				if got.VerificationToken == "" {
+					t.Fatal("ResendVerification() returned an empty token")
+				}
+				return
+			}
+			var ce cerr.CustomError
+			if !errors.As(err, &ce) || ce.ErrorType() != tt.wantErr {
+				t.Fatalf("ResendVerification() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestUserApp_Login(t *testing.T) {
 	type fields struct {
 		config    *config.Config
@@ -532,7 +733,7 @@ func TestUserApp_Login(t *testing.T) {
 				ttFields := tt.fields
 				tt.mockCall(ttFields)
 			}
-			app := appuser.NewUserApp(tt.fields.config, tt.fields.userRepo, tt.fields.redisRepo)
+			app := appuser.NewUserApp(tt.fields.config, nil, tt.fields.userRepo, tt.fields.redisRepo)
 
 			got, err := app.Login(tt.args.ctx, tt.args.req)
 			if (err != nil) != tt.wantErr {
@@ -560,6 +761,84 @@ func TestUserApp_Login(t *testing.T) {
 	}
 }
 
+func TestUserApp_Login_SingleSession(t *testing.T) {
+	tests := []struct {
+		name            string
+		singleSession   bool
+		wantFirstActive bool
+	}{
+		{name: "single-session: second login revokes the first", singleSession: true, wantFirstActive: false},
+		{name: "multi-session: both logins stay valid", singleSession: false, wantFirstActive: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Auth: config.AuthConfig{
+					JWTSecret:      "test-secret-key-for-jwt-signing",
+					JWTExpiration:  time.Hour,
+					SessionExpTime: time.Hour,
+					SingleSession:  tt.singleSession,
+				},
+			}
+			userRepo := usermocks.NewUserRepository(t)
+			redisRepo := redismocks.NewRedisRepository(t)
+
+			hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+			userRepo.On("Get", mock.Anything, &model.UserFilter{Email: "test@example.com"}).
+				Return(&model.UserEntity{ID: 1, Name: "Test User", Email: "test@example.com", PasswordHash: string(hashedPassword)}, nil).
+				Twice()
+
+			// sessions backs the redis mock so validity can be checked after both logins
+			sessions := map[string]uint64{}
+			redisRepo.On("SetSession", mock.Anything, mock.AnythingOfType("string"), uint64(1), time.Hour).
+				Run(func(args mock.Arguments) { sessions[args.String(1)] = args.Get(2).(uint64) }).
+				Return(nil).
+				Twice()
+			if tt.singleSession {
+				redisRepo.On("DeleteAllSessions", mock.Anything, uint64(1)).
+					Run(func(args mock.Arguments) {
+						for id, uid := range sessions {
+							if uid == args.Get(1).(uint64) {
+								delete(sessions, id)
+							}
+						}
+					}).
+					Return(nil).
+					Twice()
+			}
+			redisRepo.On("GetSession", mock.Anything, mock.AnythingOfType("string")).
+				Return(func(ctx context.Context, id string) (uint64, error) {
+					uid, ok := sessions[id]
+					if !ok {
+						return 0, errors.New("redis: nil")
+					}
+					return uid, nil
+				}, nil)
+
+			app := appuser.NewUserApp(cfg, nil, userRepo, redisRepo)
+			req := &model.LoginRequest{Identifier: "test@example.com", Password: "password123"}
+
+			first, err := app.Login(context.Background(), req)
+			if err != nil {
+				t.Fatalf("first Login() error = %v", err)
+			}
+			second, err := app.Login(context.Background(), req)
+			if err != nil {
+				t.Fatalf("second Login() error = %v", err)
+			}
+
+			if _, err := app.ValidateToken(context.Background(), second.Token); err != nil {
+				t.Fatalf("ValidateToken(second) error = %v, want valid", err)
+			}
+			_, err = app.ValidateToken(context.Background(), first.Token)
+			if gotActive := err == nil; gotActive != tt.wantFirstActive {
+				t.Fatalf("first session active = %v, want %v (err = %v)", gotActive, tt.wantFirstActive, err)
+			}
+		})
+	}
+}
+
 func TestUserApp_ValidateToken(t *testing.T) {
 	type fields struct {
 		config    *config.Config
@@ -655,7 +934,7 @@ func TestUserApp_ValidateToken(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Generate a valid token for success case
 			if tt.name == "success: valid token" || tt.name == "error: session not found in redis" {
-				app := appuser.NewUserApp(tt.fields.config, tt.fields.userRepo, tt.fields.redisRepo)
+				app := appuser.NewUserApp(tt.fields.config, nil, tt.fields.userRepo, tt.fields.redisRepo)
 				// Create a valid token by logging in first
 				hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
 				tt.fields.userRepo.On("Get", mock.Anything, mock.Anything).Return(&model.UserEntity{
@@ -678,7 +957,7 @@ func TestUserApp_ValidateToken(t *testing.T) {
 				tt.mockCall(ttFields, tt.args.tokenString)
 			}
 
-			app := appuser.NewUserApp(tt.fields.config, tt.fields.userRepo, tt.fields.redisRepo)
+			app := appuser.NewUserApp(tt.fields.config, nil, tt.fields.userRepo, tt.fields.redisRepo)
 
 			got, err := app.ValidateToken(tt.args.ctx, tt.args.tokenString)
 			if (err != nil) != tt.wantErr {
@@ -691,3 +970,626 @@ func TestUserApp_ValidateToken(t *testing.T) {
 		})
 	}
 }
+
+func TestUserApp_ValidateToken_KeyRotation(t *testing.T) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			JWTSecret:          "new-secret",
+			JWTKeyID:           "2025-02",
+			JWTAcceptedSecrets: map[string]string{"2025-01": "old-secret"},
+			JWTExpiration:      time.Hour,
+			SessionExpTime:     time.Hour,
+		},
+	}
+	sign := func(kid, secret string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Subject:   "1",
+			ID:        "session-1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString([]byte(secret))
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "signed with the primary key", token: sign("2025-02", "new-secret")},
+		{name: "signed with a now secondary key", token: sign("2025-01", "old-secret")},
+		{name: "legacy token without kid, old secret", token: sign("", "old-secret")},
+		{name: "legacy token without kid, primary secret", token: sign("", "new-secret")},
+		{name: "unknown kid", token: sign("2024-12", "old-secret"), wantErr: true},
+		{name: "kid of one key, signed by another", token: sign("2025-02", "old-secret"), wantErr: true},
+		{name: "legacy token signed by a retired secret", token: sign("", "retired-secret"), wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			redisRepo := redismocks.NewRedisRepository(t)
+			if !tt.wantErr {
+				redisRepo.On("GetSession", mock.Anything, "session-1").Return(uint64(1), nil).Once()
+			}
+			app := appuser.NewUserApp(cfg, nil, usermocks.NewUserRepository(t), redisRepo)
+
+			got, err := app.ValidateToken(context.Background(), tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != 1 {
+				t.Fatalf("ValidateToken() = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestUserApp_ValidateToken_RejectsAlgNone(t *testing.T) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			JWTSecret:          "new-secret",
+			JWTKeyID:           "2025-02",
+			JWTAcceptedSecrets: map[string]string{"2025-01": "old-secret"},
+			JWTExpiration:      time.Hour,
+			SessionExpTime:     time.Hour,
+		},
+	}
+	forge := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{
+			Subject:   "1",
+			ID:        "session-1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		if err != nil {
+			t.Fatalf("forge token: %v", err)
+		}
+		return signed
+	}
+
+	for name, token := range map[string]string{
+		"with the primary kid": forge("2025-02"),
+		"without kid":          forge(""),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// no session lookup is expected, the mock fails on any call
+			app := appuser.NewUserApp(cfg, nil, usermocks.NewUserRepository(t), redismocks.NewRedisRepository(t))
+
+			_, err := app.ValidateToken(context.Background(), token)
+			if err == nil {
+				t.Fatal("ValidateToken() accepted an alg=none token")
+			}
+			if !strings.Contains(err.Error(), "unexpected signing method") {
+				t.Fatalf("ValidateToken() error = %v, want unexpected signing method", err)
+			}
+		})
+	}
+}
+
+func TestUserApp_Login_SignsWithPrimaryKeyID(t *testing.T) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			JWTSecret:          "new-secret",
+			JWTKeyID:           "2025-02",
+			JWTAcceptedSecrets: map[string]string{"2025-01": "old-secret"},
+			JWTExpiration:      time.Hour,
+			SessionExpTime:     time.Hour,
+		},
+	}
+	userRepo := usermocks.NewUserRepository(t)
+	redisRepo := redismocks.NewRedisRepository(t)
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	userRepo.On("Get", mock.Anything, mock.Anything).Return(&model.UserEntity{ID: 1, PasswordHash: string(hashedPassword)}, nil).Once()
+	redisRepo.On("SetSession", mock.Anything, mock.AnythingOfType("string"), uint64(1), time.Hour).Return(nil).Once()
+
+	app := appuser.NewUserApp(cfg, nil, userRepo, redisRepo)
+	resp, err := app.Login(context.Background(), &model.LoginRequest{Identifier: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	token, err := jwt.Parse(resp.Token, func(*jwt.Token) (interface{}, error) { return []byte("new-secret"), nil })
+	if err != nil {
+		t.Fatalf("token not signed with the primary secret: %v", err)
+	}
+	if kid := token.Header["kid"]; kid != "2025-02" {
+		t.Fatalf("kid = %v, want 2025-02", kid)
+	}
+}
+
+func TestUserApp_Addresses(t *testing.T) {
+	addrReq := &model.ShippingAddress{
+		RecipientName: "Budi",
+		Phone:         "081234567890",
+		AddressLine:   "Jl. Sudirman No. 1",
+		City:          "Jakarta",
+		Province:      "DKI Jakarta",
+		PostalCode:    "10220",
+	}
+	owned := &model.UserAddress{ID: 10, UserID: 1, ShippingAddress: *addrReq}
+	foreign := &model.UserAddress{ID: 11, UserID: 2, ShippingAddress: *addrReq}
+
+	tests := []struct {
+		name     string
+		mockCall func(userRepo *usermocks.UserRepository)
+		call     func(app appuser.UserApp) (interface{}, error)
+		want     interface{}
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: list addresses",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("ListAddresses", mock.Anything, uint64(1)).Return([]model.UserAddress{*owned}, nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return app.ListAddresses(context.Background(), 1)
+			},
+			want: []model.UserAddress{*owned},
+		},
+		{
+			name: "success: add address",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("CreateAddress", mock.Anything, mock.MatchedBy(func(addr *model.UserAddress) bool {
+					return addr.UserID == 1 && addr.City == "Jakarta"
+				})).Return(owned, nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return app.AddAddress(context.Background(), 1, addrReq)
+			},
+			want: owned,
+		},
+		{
+			name: "success: update own address",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("GetAddress", mock.Anything, uint64(10)).Return(&model.UserAddress{ID: 10, UserID: 1}, nil).Once()
+				userRepo.On("UpdateAddress", mock.Anything, mock.MatchedBy(func(addr *model.UserAddress) bool {
+					return addr.ID == 10 && addr.UserID == 1 && addr.City == "Jakarta"
+				})).Return(nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return app.UpdateAddress(context.Background(), 1, 10, addrReq)
+			},
+			want: owned,
+		},
+		{
+			name: "error: update address owned by another user",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("GetAddress", mock.Anything, uint64(11)).Return(foreign, nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return app.UpdateAddress(context.Background(), 1, 11, addrReq)
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name: "success: delete own address",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("GetAddress", mock.Anything, uint64(10)).Return(owned, nil).Once()
+				userRepo.On("DeleteAddress", mock.Anything, uint64(1), uint64(10)).Return(nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return nil, app.DeleteAddress(context.Background(), 1, 10)
+			},
+		},
+		{
+			name: "error: delete address owned by another user",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("GetAddress", mock.Anything, uint64(11)).Return(foreign, nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return nil, app.DeleteAddress(context.Background(), 1, 11)
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name: "error: delete missing address",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("GetAddress", mock.Anything, uint64(99)).Return(nil, nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return nil, app.DeleteAddress(context.Background(), 1, 99)
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := usermocks.NewUserRepository(t)
+			tt.mockCall(userRepo)
+			app := appuser.NewUserApp(&config.Config{}, nil, userRepo, redismocks.NewRedisRepository(t))
+
+			got, err := tt.call(app)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserApp_NotificationPrefs(t *testing.T) {
+	on, off := true, false
+
+	tests := []struct {
+		name     string
+		mockCall func(userRepo *usermocks.UserRepository)
+		call     func(app appuser.UserApp) (interface{}, error)
+		want     interface{}
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: defaults when never saved",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("GetNotificationPrefs", mock.Anything, uint64(1)).Return(nil, nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return app.GetNotificationPrefs(context.Background(), 1)
+			},
+			want: &model.NotificationPrefs{OrderUpdates: true, Marketing: false, WishlistLowStock: false},
+		},
+		{
+			name: "success: saved preferences",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("GetNotificationPrefs", mock.Anything, uint64(1)).Return(&model.NotificationPrefs{Marketing: true}, nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return app.GetNotificationPrefs(context.Background(), 1)
+			},
+			want: &model.NotificationPrefs{Marketing: true},
+		},
+		{
+			name: "success: toggle only the sent preferences on top of defaults",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("GetNotificationPrefs", mock.Anything, uint64(1)).Return(nil, nil).Once()
+				userRepo.On("UpsertNotificationPrefs", mock.Anything, uint64(1), &model.NotificationPrefs{
+					OrderUpdates: false, Marketing: true, WishlistLowStock: false,
+				}).Return(nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return app.UpdateNotificationPrefs(context.Background(), 1, &model.UpdateNotificationPrefsRequest{
+					OrderUpdates: &off,
+					Marketing:    &on,
+				})
+			},
+			want: &model.NotificationPrefs{OrderUpdates: false, Marketing: true, WishlistLowStock: false},
+		},
+		{
+			name: "error: update fails to save",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("GetNotificationPrefs", mock.Anything, uint64(1)).Return(nil, nil).Once()
+				userRepo.On("UpsertNotificationPrefs", mock.Anything, uint64(1), mock.Anything).Return(errors.New("db error")).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return app.UpdateNotificationPrefs(context.Background(), 1, &model.UpdateNotificationPrefsRequest{Marketing: &on})
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name: "success: order updates are sent by default",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("GetNotificationPrefs", mock.Anything, uint64(1)).Return(nil, nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return app.ShouldNotify(context.Background(), 1, constant.NotificationOrderUpdates)
+			},
+			want: true,
+		},
+		{
+			name: "success: marketing is not sent by default",
+			mockCall: func(userRepo *usermocks.UserRepository) {
+				userRepo.On("GetNotificationPrefs", mock.Anything, uint64(1)).Return(nil, nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return app.ShouldNotify(context.Background(), 1, constant.NotificationMarketing)
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := usermocks.NewUserRepository(t)
+			tt.mockCall(userRepo)
+			app := appuser.NewUserApp(&config.Config{}, nil, userRepo, redismocks.NewRedisRepository(t))
+
+			got, err := tt.call(app)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserApp_Sessions(t *testing.T) {
+	expiresAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		mockCall func(redisRepo *redismocks.RedisRepository)
+		call     func(app appuser.UserApp) (interface{}, error)
+		want     interface{}
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: list sessions",
+			mockCall: func(redisRepo *redismocks.RedisRepository) {
+				redisRepo.On("ListSessions", mock.Anything, uint64(1)).Return([]model.Session{{ID: "jti-1", ExpiresAt: expiresAt}}, nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return app.ListSessions(context.Background(), 1)
+			},
+			want: []model.Session{{ID: "jti-1", ExpiresAt: expiresAt}},
+		},
+		{
+			name: "error: list sessions fails",
+			mockCall: func(redisRepo *redismocks.RedisRepository) {
+				redisRepo.On("ListSessions", mock.Anything, uint64(1)).Return(nil, errors.New("redis down")).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return app.ListSessions(context.Background(), 1)
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name: "success: revoke all sessions",
+			mockCall: func(redisRepo *redismocks.RedisRepository) {
+				redisRepo.On("DeleteAllSessions", mock.Anything, uint64(1)).Return(nil).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return nil, app.RevokeAllSessions(context.Background(), 1)
+			},
+		},
+		{
+			name: "error: revoke all sessions fails",
+			mockCall: func(redisRepo *redismocks.RedisRepository) {
+				redisRepo.On("DeleteAllSessions", mock.Anything, uint64(1)).Return(errors.New("redis down")).Once()
+			},
+			call: func(app appuser.UserApp) (interface{}, error) {
+				return nil, app.RevokeAllSessions(context.Background(), 1)
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			redisRepo := redismocks.NewRedisRepository(t)
+			tt.mockCall(redisRepo)
+			app := appuser.NewUserApp(&config.Config{}, nil, usermocks.NewUserRepository(t), redisRepo)
+
+			got, err := tt.call(app)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserApp_RegisterMixedCaseThenLoginLowercase(t *testing.T) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			JWTSecret:      "test-secret-key-for-jwt-signing",
+			JWTExpiration:  time.Hour,
+			SessionExpTime: time.Hour,
+			BcryptCost:     bcrypt.MinCost,
+		},
+	}
+	userRepo := usermocks.NewUserRepository(t)
+	redisRepo := redismocks.NewRedisRepository(t)
+	txRepo := txmocks.NewTxRepository(t)
+	app := appuser.NewUserApp(cfg, txRepo, userRepo, redisRepo)
+
+	var stored *model.UserEntity
+	tx := &sqlx.Tx{}
+	userRepo.On("Get", mock.Anything, &model.UserFilter{Email: "test@example.com"}).Return(nil, nil).Once()
+	userRepo.On("Get", mock.Anything, &model.UserFilter{Phone: "081234567890"}).Return(nil, nil).Once()
+	txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+	userRepo.On("CreateTx", mock.Anything, tx, mock.Anything).
+		Run(func(args mock.Arguments) {
+			stored = args.Get(2).(*model.UserEntity)
+			stored.ID = 1
+		}).
+		Return(func(ctx context.Context, tx *sqlx.Tx, ent *model.UserEntity) *model.UserEntity { return ent }, nil).
+		Once()
+	redisRepo.On("SetVerificationToken", mock.Anything, mock.Anything, uint64(1), mock.Anything).Return(nil).Once()
+	txRepo.On("CommitTx", tx).Return(nil).Once()
+
+	res, err := app.Register(context.Background(), &model.RegisterRequest{
+		Name:     "Test User",
+		Email:    " Test@Example.COM ",
+		Phone:    "081234567890",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if stored.Email != "test@example.com" || res.Email != "test@example.com" {
+		t.Fatalf("stored email = %q, response email = %q, want test@example.com", stored.Email, res.Email)
+	}
+	if cost, err := bcrypt.Cost([]byte(stored.PasswordHash)); err != nil || cost != bcrypt.MinCost {
+		t.Fatalf("password hashed with cost %d (%v), want the configured %d", cost, err, bcrypt.MinCost)
+	}
+
+	// the lookup is by the normalized address whichever case the user types
+	for _, identifier := range []string{"test@example.com", "TEST@example.com "} {
+		userRepo.On("Get", mock.Anything, &model.UserFilter{Email: "test@example.com"}).Return(stored, nil).Once()
+		redisRepo.On("SetSession", mock.Anything, mock.AnythingOfType("string"), uint64(1), time.Hour).Return(nil).Once()
+
+		login, err := app.Login(context.Background(), &model.LoginRequest{Identifier: identifier, Password: "password123"})
+		if err != nil {
+			t.Fatalf("Login(%q) error = %v", identifier, err)
+		}
+		if login.Token == "" {
+			t.Fatalf("Login(%q) returned no token", identifier)
+		}
+	}
+}
+
+func TestUserApp_PhoneStoredInLocalForm(t *testing.T) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			JWTSecret:      "test-secret-key-for-jwt-signing",
+			JWTExpiration:  time.Hour,
+			SessionExpTime: time.Hour,
+			BcryptCost:     bcrypt.MinCost,
+		},
+	}
+	userRepo := usermocks.NewUserRepository(t)
+	redisRepo := redismocks.NewRedisRepository(t)
+	txRepo := txmocks.NewTxRepository(t)
+	app := appuser.NewUserApp(cfg, txRepo, userRepo, redisRepo)
+
+	var stored *model.UserEntity
+	tx := &sqlx.Tx{}
+	userRepo.On("Get", mock.Anything, &model.UserFilter{Email: "test@example.com"}).Return(nil, nil).Once()
+	userRepo.On("Get", mock.Anything, &model.UserFilter{Phone: "081234567890"}).Return(nil, nil).Once()
+	txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+	userRepo.On("CreateTx", mock.Anything, tx, mock.Anything).
+		Run(func(args mock.Arguments) {
+			stored = args.Get(2).(*model.UserEntity)
+			stored.ID = 1
+		}).
+		Return(func(ctx context.Context, tx *sqlx.Tx, ent *model.UserEntity) *model.UserEntity { return ent }, nil).
+		Once()
+	redisRepo.On("SetVerificationToken", mock.Anything, mock.Anything, uint64(1), mock.Anything).Return(nil).Once()
+	txRepo.On("CommitTx", tx).Return(nil).Once()
+
+	_, err := app.Register(context.Background(), &model.RegisterRequest{
+		Name:     "Test User",
+		Email:    "test@example.com",
+		Phone:    "+6281234567890",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if stored.Phone != "081234567890" {
+		t.Fatalf("stored phone = %q, want 081234567890", stored.Phone)
+	}
+
+	for _, identifier := range []string{"081234567890", "+6281234567890"} {
+		userRepo.On("Get", mock.Anything, &model.UserFilter{Phone: "081234567890"}).Return(stored, nil).Once()
+		redisRepo.On("SetSession", mock.Anything, mock.AnythingOfType("string"), uint64(1), time.Hour).Return(nil).Once()
+
+		if _, err := app.Login(context.Background(), &model.LoginRequest{Identifier: identifier, Password: "password123"}); err != nil {
+			t.Fatalf("Login(%q) error = %v", identifier, err)
+		}
+	}
+}
+
+// sessionStub answers session lookups without the bookkeeping of a mock, so
+// the benchmark measures the token check itself
+type sessionStub struct {
+	redisrepo.RedisRepository
+}
+
+func (sessionStub) GetSession(ctx context.Context, sessionID string) (uint64, error) {
+	return 1, nil
+}
+
+// BenchmarkUserApp_ValidateToken measures the token check every authenticated
+// request, order creation included, goes through
+func BenchmarkUserApp_ValidateToken(b *testing.B) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			JWTSecret:          "new-secret",
+			JWTKeyID:           "2025-02",
+			JWTAcceptedSecrets: map[string]string{"2025-01": "old-secret"},
+			JWTExpiration:      time.Hour,
+			SessionExpTime:     time.Hour,
+		},
+	}
+	sign := func(kid, secret string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Subject:   "1",
+			ID:        "session-1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString([]byte(secret))
+		if err != nil {
+			b.Fatalf("sign token: %v", err)
+		}
+		return signed
+	}
+	app := appuser.NewUserApp(cfg, nil, nil, sessionStub{})
+
+	for name, token := range map[string]string{
+		"primary key":      sign("2025-02", "new-secret"),
+		"legacy secondary": sign("", "old-secret"),
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := app.ValidateToken(context.Background(), token); err != nil {
+					b.Fatalf("ValidateToken() error = %v", err)
+				}
+			}
+		})
+	}
+}
@@ -3,11 +3,14 @@ package warehouse
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/muhammadheryan/e-commerce/constant"
 	"github.com/muhammadheryan/e-commerce/model"
+	productrepo "github.com/muhammadheryan/e-commerce/repository/product"
 	txrepo "github.com/muhammadheryan/e-commerce/repository/tx"
 	warehouserepo "github.com/muhammadheryan/e-commerce/repository/warehouse"
+	"github.com/muhammadheryan/e-commerce/thirdparty/rabbitmq"
 	"github.com/muhammadheryan/e-commerce/utils/errors"
 	"github.com/muhammadheryan/e-commerce/utils/logger"
 	"go.uber.org/zap"
@@ -15,22 +18,49 @@ import (
 
 type WarehouseApp interface {
 	ActivateWarehouse(ctx context.Context, warehouseID uint64) error
-	DeactivateWarehouse(ctx context.Context, warehouseID uint64) error
+	DeactivateWarehouse(ctx context.Context, warehouseID, transferTo uint64) error
 	TransferStock(ctx context.Context, req *model.TransferStockRequest) error
+	ListWarehouses(ctx context.Context, shopID uint64) ([]model.WarehouseSummary, error)
+	LowStockReport(ctx context.Context, threshold int64) ([]model.LowStockItem, error)
+	ReconcileReserved(ctx context.Context, warehouseID uint64) ([]model.ReservedDiscrepancy, error)
+	AdjustStock(ctx context.Context, req *model.StockAdjustmentRequest) error
+	GetAvailableStock(ctx context.Context, productID uint64) (int64, error)
+	GetWarehouse(ctx context.Context, warehouseID uint64) (*model.WarehouseEntity, error)
+}
+
+// BackInStockPublisher sends back-in-stock notifications, implemented by *rabbitmq.Publisher
+type BackInStockPublisher interface {
+	PublishBackInStock(msg rabbitmq.BackInStockMessage) error
+}
+
+// NotificationPrefs tells whether a user opted in to a notification type, implemented by the user app
+type NotificationPrefs interface {
+	ShouldNotify(ctx context.Context, userID uint64, notificationType constant.NotificationType) (bool, error)
 }
 
 type warehouseAppImpl struct {
 	txRepo        txrepo.TxRepository
 	warehouseRepo warehouserepo.WarehouseRepository
+	productRepo   productrepo.ProductRepository
+	backInStock   BackInStockPublisher
+	prefs         NotificationPrefs
 }
 
-func NewWarehouseApp(txRepo txrepo.TxRepository, warehouseRepo warehouserepo.WarehouseRepository) WarehouseApp {
+// NewWarehouseApp builds the warehouse app, backInStock may be nil in which
+// case subscribers are kept until a publisher is configured. prefs decides who
+// of them is sent a notification.
+func NewWarehouseApp(txRepo txrepo.TxRepository, warehouseRepo warehouserepo.WarehouseRepository, productRepo productrepo.ProductRepository, backInStock BackInStockPublisher, prefs NotificationPrefs) WarehouseApp {
 	return &warehouseAppImpl{
 		txRepo:        txRepo,
 		warehouseRepo: warehouseRepo,
+		productRepo:   productRepo,
+		backInStock:   backInStock,
+		prefs:         prefs,
 	}
 }
 
+// ActivateWarehouse makes the warehouse's stock available again. Subscribers
+// of a product that had no stock elsewhere are notified that it is back.
 func (s *warehouseAppImpl) ActivateWarehouse(ctx context.Context, warehouseID uint64) error {
 	// Check if warehouse exists
 	warehouse, err := s.warehouseRepo.GetWarehouseByID(ctx, warehouseID)
@@ -42,8 +72,38 @@ func (s *warehouseAppImpl) ActivateWarehouse(ctx context.Context, warehouseID ui
 		return errors.SetCustomError(constant.ErrNotFound)
 	}
 
+	tx, err := s.txRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("[ActivateWarehouse] begin tx failed", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = s.txRepo.RollbackTx(tx)
+		}
+	}()
+
+	stocks, err := s.warehouseRepo.GetWarehouseStocksTx(ctx, tx, warehouseID)
+	if err != nil {
+		logger.Error("[ActivateWarehouse] get warehouse stocks failed", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	// only products this warehouse has available stock of can come back
+	before := make(map[uint64]int64)
+	for _, stock := range stocks {
+		if stock.Stock-stock.Reserved <= 0 {
+			continue
+		}
+		productID := uint64(stock.ProductID)
+		if before[productID], err = s.warehouseRepo.GetTotalAvailableStockTx(ctx, tx, productID); err != nil {
+			logger.Error("[ActivateWarehouse] get available stock failed", zap.String("error", err.Error()))
+			return errors.SetCustomError(constant.ErrInternal)
+		}
+	}
+
 	// Update status to active
-	err = s.warehouseRepo.UpdateWarehouseStatus(ctx, warehouseID, constant.WarehouseStatusActive)
+	err = s.warehouseRepo.UpdateWarehouseStatusTx(ctx, tx, warehouseID, constant.WarehouseStatusActive)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return errors.SetCustomError(constant.ErrNotFound)
@@ -52,10 +112,36 @@ func (s *warehouseAppImpl) ActivateWarehouse(ctx context.Context, warehouseID ui
 		return errors.SetCustomError(constant.ErrInternal)
 	}
 
+	after := make(map[uint64]int64, len(before))
+	for productID := range before {
+		if after[productID], err = s.warehouseRepo.GetTotalAvailableStockTx(ctx, tx, productID); err != nil {
+			logger.Error("[ActivateWarehouse] get available stock failed", zap.String("error", err.Error()))
+			return errors.SetCustomError(constant.ErrInternal)
+		}
+	}
+
+	if err := s.txRepo.CommitTx(tx); err != nil {
+		logger.Error("[ActivateWarehouse] commit tx failed", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed = true
+
+	for productID, available := range after {
+		if before[productID] <= 0 && available > 0 {
+			go s.notifyBackInStock(context.WithoutCancel(ctx), productID, available)
+		}
+	}
 	return nil
 }
 
-func (s *warehouseAppImpl) DeactivateWarehouse(ctx context.Context, warehouseID uint64) error {
+// DeactivateWarehouse deactivates a warehouse without reserved stock. When
+// transferTo is set, the warehouse's stock is first moved there in the same
+// transaction, so the warehouse is emptied and deactivated or neither.
+func (s *warehouseAppImpl) DeactivateWarehouse(ctx context.Context, warehouseID, transferTo uint64) error {
+	if transferTo == warehouseID {
+		return errors.SetCustomError(constant.ErrInvalidRequest)
+	}
+
 	// Check if warehouse exists
 	warehouse, err := s.warehouseRepo.GetWarehouseByID(ctx, warehouseID)
 	if err != nil {
@@ -65,6 +151,21 @@ func (s *warehouseAppImpl) DeactivateWarehouse(ctx context.Context, warehouseID
 	if warehouse == nil {
 		return errors.SetCustomError(constant.ErrNotFound)
 	}
+	if transferTo != 0 {
+		// checked up front too, an empty warehouse never reaches the transfer's check
+		target, err := s.warehouseRepo.GetWarehouseByID(ctx, transferTo)
+		if err != nil {
+			logger.Error("[DeactivateWarehouse] get target warehouse failed", zap.String("error", err.Error()))
+			return errors.SetCustomError(constant.ErrInternal)
+		}
+		if target == nil {
+			return errors.SetCustomError(constant.ErrNotFound)
+		}
+		if target.Status != constant.WarehouseStatusActive {
+			return errors.SetCustomError(constant.ErrWarehouseInactive)
+		}
+		return s.transferOutAndDeactivate(ctx, warehouseID, transferTo)
+	}
 
 	// Check if theres any reserved stock
 	reservedStock, err := s.warehouseRepo.CheckReservedStock(ctx, warehouseID)
@@ -73,7 +174,7 @@ func (s *warehouseAppImpl) DeactivateWarehouse(ctx context.Context, warehouseID
 		return errors.SetCustomError(constant.ErrInternal)
 	}
 	if reservedStock > 0 {
-		return errors.SetCustomError(constant.ErrWarehouseHasReservedStock)
+		return s.reservedStockError(ctx, warehouseID, reservedStock)
 	}
 
 	// Update status to inactive
@@ -89,6 +190,87 @@ func (s *warehouseAppImpl) DeactivateWarehouse(ctx context.Context, warehouseID
 	return nil
 }
 
+// transferOutAndDeactivate moves all stock of warehouseID to transferTo and
+// deactivates it in one transaction. The stock rows stay locked throughout, so
+// nothing can be reserved in between.
+func (s *warehouseAppImpl) transferOutAndDeactivate(ctx context.Context, warehouseID, transferTo uint64) error {
+	// Start transaction
+	tx, err := s.txRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("[transferOutAndDeactivate] begin tx failed", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = s.txRepo.RollbackTx(tx)
+		}
+	}()
+
+	stocks, err := s.warehouseRepo.GetWarehouseStocksTx(ctx, tx, warehouseID)
+	if err != nil {
+		logger.Error("[transferOutAndDeactivate] get stocks failed", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	var reserved int64
+	for _, stock := range stocks {
+		reserved += stock.Reserved
+	}
+	if reserved > 0 {
+		return s.reservedStockError(ctx, warehouseID, reserved)
+	}
+
+	for _, stock := range stocks {
+		if stock.Stock <= 0 {
+			continue
+		}
+		req := &model.TransferStockRequest{
+			ProductID:       uint64(stock.ProductID),
+			FromWarehouseID: warehouseID,
+			ToWarehouseID:   transferTo,
+			Quantity:        int(stock.Stock),
+		}
+		if err := s.warehouseRepo.TransferStockTx(ctx, tx, req); err != nil {
+			logger.Error("[transferOutAndDeactivate] transfer stock failed", zap.String("error", err.Error()), zap.Uint64("product_id", uint64(stock.ProductID)))
+			switch {
+			case errors.IsType(err, constant.ErrNotFound):
+				return errors.SetCustomError(constant.ErrNotFound)
+			case errors.IsType(err, constant.ErrWarehouseInactive):
+				return errors.SetCustomError(constant.ErrWarehouseInactive)
+			}
+			return errors.SetCustomError(constant.ErrInternal)
+		}
+	}
+
+	if err := s.warehouseRepo.UpdateWarehouseStatusTx(ctx, tx, warehouseID, constant.WarehouseStatusInactive); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.SetCustomError(constant.ErrNotFound)
+		}
+		logger.Error("[transferOutAndDeactivate] update status failed", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+
+	// Commit transaction
+	if err := s.txRepo.CommitTx(tx); err != nil {
+		logger.Error("[transferOutAndDeactivate] commit tx failed", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed = true
+	return nil
+}
+
+// reservedStockError refuses a deactivation, with what is reserved and by whom
+func (s *warehouseAppImpl) reservedStockError(ctx context.Context, warehouseID uint64, reserved int64) error {
+	details := model.WarehouseReservedDetails{ReservedQuantity: reserved}
+	// the holders only make the rejection easier to act on, it stands without them
+	var err error
+	details.OrderIDs, details.CartIDs, err = s.warehouseRepo.ListReservationHolders(ctx, warehouseID)
+	if err != nil {
+		logger.Error("[reservedStockError] list reservation holders failed", zap.String("error", err.Error()))
+	}
+	return errors.WithDetails(constant.ErrWarehouseHasReservedStock, details)
+}
+
 func (s *warehouseAppImpl) TransferStock(ctx context.Context, req *model.TransferStockRequest) error {
 	// Validate request
 	if req.FromWarehouseID == req.ToWarehouseID {
@@ -111,15 +293,19 @@ func (s *warehouseAppImpl) TransferStock(ctx context.Context, req *model.Transfe
 		}
 	}()
 
-	// Transfer stock
+	// Transfer stock, both warehouses must be active
 	err = s.warehouseRepo.TransferStockTx(ctx, tx, req)
 	if err != nil {
 		logger.Error("[TransferStock] transfer stock failed", zap.String("error", err.Error()))
-		if err.Error() == errors.SetCustomError(constant.ErrNotFound).Error() {
+		switch {
+		case errors.IsType(err, constant.ErrNotFound):
 			return errors.SetCustomError(constant.ErrNotFound)
-		}
-		if err.Error() == errors.SetCustomError(constant.ErrInsufficientStock).Error() {
+		case errors.IsType(err, constant.ErrWarehouseInactive):
+			return errors.SetCustomError(constant.ErrWarehouseInactive)
+		case errors.IsType(err, constant.ErrInsufficientStock):
 			return errors.SetCustomError(constant.ErrInsufficientStock)
+		case errors.IsType(err, constant.ErrInvalidRequest):
+			return errors.SetCustomError(constant.ErrInvalidRequest)
 		}
 		return errors.SetCustomError(constant.ErrInternal)
 	}
@@ -130,6 +316,205 @@ func (s *warehouseAppImpl) TransferStock(ctx context.Context, req *model.Transfe
 		return errors.SetCustomError(constant.ErrInternal)
 	}
 	committed = true
+	return nil
+}
+
+func (s *warehouseAppImpl) ListWarehouses(ctx context.Context, shopID uint64) ([]model.WarehouseSummary, error) {
+	warehouses, err := s.warehouseRepo.ListWarehouseSummaries(ctx, shopID)
+	if err != nil {
+		logger.Error("[ListWarehouses] list warehouse summaries failed", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	return warehouses, nil
+}
+
+// LowStockReport lists products with at most threshold units available across active warehouses
+func (s *warehouseAppImpl) LowStockReport(ctx context.Context, threshold int64) ([]model.LowStockItem, error) {
+	if threshold < 0 {
+		return nil, errors.SetCustomError(constant.ErrInvalidRequest)
+	}
+	items, err := s.warehouseRepo.ListLowStockProducts(ctx, threshold)
+	if err != nil {
+		logger.Error("[LowStockReport] list low stock products failed", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	return items, nil
+}
+
+// ReconcileReserved corrects reserved counts that drifted from the reservation
+// rows, in one warehouse or in all of them when warehouseID is zero
+func (s *warehouseAppImpl) ReconcileReserved(ctx context.Context, warehouseID uint64) ([]model.ReservedDiscrepancy, error) {
+	if warehouseID != 0 {
+		warehouse, err := s.warehouseRepo.GetWarehouseByID(ctx, warehouseID)
+		if err != nil {
+			logger.Error("[ReconcileReserved] get warehouse failed", zap.String("error", err.Error()))
+			return nil, errors.SetCustomError(constant.ErrInternal)
+		}
+		if warehouse == nil {
+			return nil, errors.SetCustomError(constant.ErrNotFound)
+		}
+	}
+
+	// Start transaction
+	tx, err := s.txRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("[ReconcileReserved] begin tx failed", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = s.txRepo.RollbackTx(tx)
+		}
+	}()
+
+	discrepancies, err := s.warehouseRepo.ReconcileReservedTx(ctx, tx, warehouseID)
+	if err != nil {
+		logger.Error("[ReconcileReserved] reconcile failed", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+
+	// Commit transaction
+	if err := s.txRepo.CommitTx(tx); err != nil {
+		logger.Error("[ReconcileReserved] commit tx failed", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	committed = true
+
+	for _, d := range discrepancies {
+		logger.Warn("[ReconcileReserved] corrected reserved count", zap.Uint64("warehouse_id", uint64(d.WarehouseID)), zap.Uint64("product_id", uint64(d.ProductID)), zap.Int64("reserved", d.Reserved), zap.Int64("actual", d.Actual))
+	}
+	return discrepancies, nil
+}
+
+func (s *warehouseAppImpl) AdjustStock(ctx context.Context, req *model.StockAdjustmentRequest) error {
+	if req.Quantity == 0 {
+		return errors.SetCustomError(constant.ErrInvalidRequest)
+	}
+
+	// Check if warehouse exists
+	warehouse, err := s.warehouseRepo.GetWarehouseByID(ctx, req.WarehouseID)
+	if err != nil {
+		logger.Error("[AdjustStock] get warehouse failed", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if warehouse == nil {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+
+	// Start transaction
+	tx, err := s.txRepo.BeginTx(ctx)
+	if err != nil {
+		logger.Error("[AdjustStock] begin tx failed", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = s.txRepo.RollbackTx(tx)
+		}
+	}()
+
+	// only an increase can bring a product back in stock
+	var before int64
+	if req.Quantity > 0 {
+		before, err = s.warehouseRepo.GetTotalAvailableStockTx(ctx, tx, req.ProductID)
+		if err != nil {
+			logger.Error("[AdjustStock] get available stock failed", zap.String("error", err.Error()))
+			return errors.SetCustomError(constant.ErrInternal)
+		}
+	}
+
+	if err := s.warehouseRepo.AdjustStockTx(ctx, tx, req); err != nil {
+		if errors.IsType(err, constant.ErrInsufficientStock) {
+			return errors.SetCustomError(constant.ErrInsufficientStock)
+		}
+		logger.Error("[AdjustStock] adjust stock failed", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+
+	var after int64
+	if req.Quantity > 0 {
+		after, err = s.warehouseRepo.GetTotalAvailableStockTx(ctx, tx, req.ProductID)
+		if err != nil {
+			logger.Error("[AdjustStock] get available stock failed", zap.String("error", err.Error()))
+			return errors.SetCustomError(constant.ErrInternal)
+		}
+	}
+
+	// Commit transaction
+	if err := s.txRepo.CommitTx(tx); err != nil {
+		logger.Error("[AdjustStock] commit tx failed", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	committed = true
 
+	if before <= 0 && after > 0 {
+		// one publish per subscriber, the adjustment doesn't wait for them and
+		// they aren't cut short when its request ends
+		go s.notifyBackInStock(context.WithoutCancel(ctx), req.ProductID, after)
+	}
 	return nil
 }
+
+// notifyBackInStock notifies and unsubscribes everyone waiting for the product.
+// Subscribers who opted out of stock notifications are unsubscribed without a
+// message. The stock change is already committed, so failures are only logged
+// and a subscriber whose notification could not be sent stays subscribed.
+func (s *warehouseAppImpl) notifyBackInStock(ctx context.Context, productID uint64, available int64) {
+	if s.backInStock == nil {
+		return
+	}
+	userIDs, err := s.productRepo.ListStockSubscribers(ctx, productID)
+	if err != nil {
+		logger.Error("[notifyBackInStock] list subscribers failed", zap.String("error", err.Error()), zap.Uint64("product_id", productID))
+		return
+	}
+	for _, userID := range userIDs {
+		notify, err := s.prefs.ShouldNotify(ctx, userID, constant.NotificationWishlistLowStock)
+		if err != nil {
+			logger.Error("[notifyBackInStock] get notification prefs failed", zap.String("error", err.Error()), zap.Uint64("product_id", productID), zap.Uint64("user_id", userID))
+			continue
+		}
+		if !notify {
+			if err := s.productRepo.DeleteStockSubscription(ctx, userID, productID); err != nil {
+				logger.Error("[notifyBackInStock] unsubscribe failed", zap.String("error", err.Error()), zap.Uint64("product_id", productID), zap.Uint64("user_id", userID))
+			}
+			continue
+		}
+		msg := rabbitmq.BackInStockMessage{
+			UserID:         userID,
+			ProductID:      productID,
+			AvailableStock: available,
+			OccurredAt:     time.Now(),
+		}
+		if err := s.backInStock.PublishBackInStock(msg); err != nil {
+			logger.Error("[notifyBackInStock] publish failed", zap.String("error", err.Error()), zap.Uint64("product_id", productID), zap.Uint64("user_id", userID))
+			continue
+		}
+		if err := s.productRepo.DeleteStockSubscription(ctx, userID, productID); err != nil {
+			logger.Error("[notifyBackInStock] unsubscribe failed", zap.String("error", err.Error()), zap.Uint64("product_id", productID), zap.Uint64("user_id", userID))
+		}
+	}
+}
+
+func (s *warehouseAppImpl) GetAvailableStock(ctx context.Context, productID uint64) (int64, error) {
+	total, err := s.warehouseRepo.GetTotalAvailableStock(ctx, productID)
+	if err != nil {
+		logger.Error("[GetAvailableStock] get total available stock failed", zap.String("error", err.Error()))
+		return 0, errors.SetCustomError(constant.ErrInternal)
+	}
+	return total, nil
+}
+
+func (s *warehouseAppImpl) GetWarehouse(ctx context.Context, warehouseID uint64) (*model.WarehouseEntity, error) {
+	warehouse, err := s.warehouseRepo.GetWarehouseByID(ctx, warehouseID)
+	if err != nil {
+		logger.Error("[GetWarehouse] get warehouse failed", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	if warehouse == nil {
+		return nil, errors.SetCustomError(constant.ErrNotFound)
+	}
+	return warehouse, nil
+}
@@ -0,0 +1,641 @@
+package warehouse_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	appwarehouse "github.com/muhammadheryan/e-commerce/application/warehouse"
+	"github.com/muhammadheryan/e-commerce/constant"
+	productmocks "github.com/muhammadheryan/e-commerce/mocks/repository/product"
+	txmocks "github.com/muhammadheryan/e-commerce/mocks/repository/tx"
+	warehousemocks "github.com/muhammadheryan/e-commerce/mocks/repository/warehouse"
+	"github.com/muhammadheryan/e-commerce/model"
+	"github.com/muhammadheryan/e-commerce/thirdparty/rabbitmq"
+	cerr "github.com/muhammadheryan/e-commerce/utils/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeBackInStock records published notifications, failFor makes publishing
+// fail for a user and a non-nil block holds every publish until it is closed
+type fakeBackInStock struct {
+	mu      sync.Mutex
+	sent    []rabbitmq.BackInStockMessage
+	failFor uint64
+	block   chan struct{}
+}
+
+func (f *fakeBackInStock) PublishBackInStock(msg rabbitmq.BackInStockMessage) error {
+	if f.block != nil {
+		<-f.block
+	}
+	if msg.UserID == f.failFor {
+		return errors.New("broker unavailable")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func (f *fakeBackInStock) sentMessages() []rabbitmq.BackInStockMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]rabbitmq.BackInStockMessage(nil), f.sent...)
+}
+
+// fakePrefs opts every user in to notifications except optedOut
+type fakePrefs struct {
+	optedOut uint64
+}
+
+func (f fakePrefs) ShouldNotify(ctx context.Context, userID uint64, notificationType constant.NotificationType) (bool, error) {
+	return userID != f.optedOut || notificationType != constant.NotificationWishlistLowStock, nil
+}
+
+func TestWarehouseApp_AdjustStock_BackInStock(t *testing.T) {
+	tests := []struct {
+		name      string
+		quantity  int
+		before    int64
+		after     int64
+		failFor   uint64
+		wantSent  []uint64
+		wantUnsub []uint64
+		optedOut  uint64
+		// slow holds the publisher until AdjustStock returned
+		slow bool
+	}{
+		{
+			name:      "stock rising above zero notifies and unsubscribes",
+			quantity:  5,
+			before:    0,
+			after:     5,
+			wantSent:  []uint64{10, 11},
+			wantUnsub: []uint64{10, 11},
+		},
+		{
+			name:     "stock already available notifies nobody",
+			quantity: 5,
+			before:   2,
+			after:    7,
+		},
+		{
+			name:     "restock still below reserved notifies nobody",
+			quantity: 1,
+			before:   -3,
+			after:    -2,
+		},
+		{
+			name:      "failed notification keeps the subscription",
+			quantity:  5,
+			before:    0,
+			after:     5,
+			failFor:   10,
+			wantSent:  []uint64{11},
+			wantUnsub: []uint64{11},
+		},
+		{
+			name:      "user who opted out gets no message but is unsubscribed",
+			quantity:  5,
+			before:    0,
+			after:     5,
+			optedOut:  10,
+			wantSent:  []uint64{11},
+			wantUnsub: []uint64{10, 11},
+		},
+		{
+			name:      "slow broker doesn't hold up the adjustment",
+			quantity:  5,
+			before:    0,
+			after:     5,
+			wantSent:  []uint64{10, 11},
+			wantUnsub: []uint64{10, 11},
+			slow:      true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			txRepo := txmocks.NewTxRepository(t)
+			warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+			productRepo := productmocks.NewProductRepository(t)
+			publisher := &fakeBackInStock{failFor: tt.failFor}
+			if tt.slow {
+				publisher.block = make(chan struct{})
+			}
+
+			req := &model.StockAdjustmentRequest{WarehouseID: 1, ProductID: 2, Quantity: tt.quantity}
+			tx := &sqlx.Tx{}
+			warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(1)).Return(&model.WarehouseEntity{ID: 1}, nil).Once()
+			txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+			warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(2)).Return(tt.before, nil).Once()
+			warehouseRepo.On("AdjustStockTx", mock.Anything, tx, req).Return(nil).Once()
+			warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(2)).Return(tt.after, nil).Once()
+			txRepo.On("CommitTx", tx).Return(nil).Once()
+			if tt.wantSent != nil {
+				productRepo.On("ListStockSubscribers", mock.Anything, uint64(2)).Return([]uint64{10, 11}, nil).Once()
+			}
+			// notifications are sent in the background, the last unsubscribe ends them
+			var unsubscribed sync.WaitGroup
+			unsubscribed.Add(len(tt.wantUnsub))
+			for _, userID := range tt.wantUnsub {
+				productRepo.On("DeleteStockSubscription", mock.Anything, userID, uint64(2)).Return(nil).Once().
+					Run(func(mock.Arguments) { unsubscribed.Done() })
+			}
+
+			app := appwarehouse.NewWarehouseApp(txRepo, warehouseRepo, productRepo, publisher, fakePrefs{optedOut: tt.optedOut})
+			if err := app.AdjustStock(context.Background(), req); err != nil {
+				t.Fatalf("AdjustStock() error = %v", err)
+			}
+			if tt.slow {
+				close(publisher.block)
+			}
+
+			done := make(chan struct{})
+			go func() {
+				unsubscribed.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("subscribers were not unsubscribed")
+			}
+
+			sent := publisher.sentMessages()
+			if len(sent) != len(tt.wantSent) {
+				t.Fatalf("sent %d notifications, want %d", len(sent), len(tt.wantSent))
+			}
+			for i, msg := range sent {
+				if msg.UserID != tt.wantSent[i] || msg.ProductID != 2 || msg.AvailableStock != tt.after {
+					t.Errorf("notification %d = %+v, want user %d product 2 stock %d", i, msg, tt.wantSent[i], tt.after)
+				}
+			}
+		})
+	}
+}
+
+func TestWarehouseApp_ActivateWarehouse_BackInStock(t *testing.T) {
+	txRepo := txmocks.NewTxRepository(t)
+	warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+	productRepo := productmocks.NewProductRepository(t)
+	publisher := &fakeBackInStock{}
+
+	tx := &sqlx.Tx{}
+	warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(1)).Return(&model.WarehouseEntity{ID: 1, Status: constant.WarehouseStatusInactive}, nil).Once()
+	txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+	warehouseRepo.On("GetWarehouseStocksTx", mock.Anything, tx, uint64(1)).Return([]model.WarehouseStock{
+		{WarehouseID: 1, ProductID: 2, Stock: 5, Reserved: 0}, // sold out elsewhere, comes back
+		{WarehouseID: 1, ProductID: 3, Stock: 4, Reserved: 1}, // still available elsewhere
+		{WarehouseID: 1, ProductID: 4, Stock: 2, Reserved: 2}, // nothing to offer here
+	}, nil).Once()
+	warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(2)).Return(int64(0), nil).Once()
+	warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(3)).Return(int64(6), nil).Once()
+	warehouseRepo.On("UpdateWarehouseStatusTx", mock.Anything, tx, uint64(1), constant.WarehouseStatusActive).Return(nil).Once()
+	warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(2)).Return(int64(5), nil).Once()
+	warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(3)).Return(int64(9), nil).Once()
+	txRepo.On("CommitTx", tx).Return(nil).Once()
+
+	productRepo.On("ListStockSubscribers", mock.Anything, uint64(2)).Return([]uint64{10}, nil).Once()
+	unsubscribed := make(chan struct{})
+	productRepo.On("DeleteStockSubscription", mock.Anything, uint64(10), uint64(2)).Return(nil).Once().
+		Run(func(mock.Arguments) { close(unsubscribed) })
+
+	app := appwarehouse.NewWarehouseApp(txRepo, warehouseRepo, productRepo, publisher, fakePrefs{})
+	if err := app.ActivateWarehouse(context.Background(), 1); err != nil {
+		t.Fatalf("ActivateWarehouse() error = %v", err)
+	}
+
+	select {
+	case <-unsubscribed:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber was not notified")
+	}
+	sent := publisher.sentMessages()
+	if len(sent) != 1 || sent[0].UserID != 10 || sent[0].ProductID != 2 || sent[0].AvailableStock != 5 {
+		t.Fatalf("sent = %+v, want one notification to user 10 for product 2 with stock 5", sent)
+	}
+}
+
+func TestWarehouseApp_TransferStock(t *testing.T) {
+	req := &model.TransferStockRequest{ProductID: 2, FromWarehouseID: 1, ToWarehouseID: 3, Quantity: 4}
+
+	tests := []struct {
+		name    string
+		req     *model.TransferStockRequest
+		repoErr error
+		noRepo  bool
+		wantErr bool
+		errCode constant.ErrorType
+	}{
+		{name: "success", req: req},
+		{name: "success: moving reservations", req: &model.TransferStockRequest{ProductID: 2, FromWarehouseID: 1, ToWarehouseID: 3, Quantity: 4, MoveReservations: true}},
+		{name: "error: quantity short of the moved reservations", req: &model.TransferStockRequest{ProductID: 2, FromWarehouseID: 1, ToWarehouseID: 3, Quantity: 4, MoveReservations: true}, repoErr: cerr.SetCustomError(constant.ErrInvalidRequest), wantErr: true, errCode: constant.ErrInvalidRequest},
+		{name: "error: from inactive warehouse", req: req, repoErr: cerr.SetCustomError(constant.ErrWarehouseInactive), wantErr: true, errCode: constant.ErrWarehouseInactive},
+		{name: "error: to inactive warehouse", req: &model.TransferStockRequest{ProductID: 2, FromWarehouseID: 3, ToWarehouseID: 1, Quantity: 4}, repoErr: cerr.SetCustomError(constant.ErrWarehouseInactive), wantErr: true, errCode: constant.ErrWarehouseInactive},
+		{name: "error: unknown warehouse", req: req, repoErr: cerr.SetCustomError(constant.ErrNotFound), wantErr: true, errCode: constant.ErrNotFound},
+		{name: "error: insufficient stock", req: req, repoErr: cerr.SetCustomError(constant.ErrInsufficientStock), wantErr: true, errCode: constant.ErrInsufficientStock},
+		{name: "error: wrapped insufficient stock", req: req, repoErr: fmt.Errorf("transfer: %w", cerr.SetCustomError(constant.ErrInsufficientStock)), wantErr: true, errCode: constant.ErrInsufficientStock},
+		{name: "error: wrapped unknown warehouse", req: req, repoErr: fmt.Errorf("transfer: %w", cerr.SetCustomError(constant.ErrNotFound)), wantErr: true, errCode: constant.ErrNotFound},
+		{name: "error: database failure", req: req, repoErr: errors.New("connection reset"), wantErr: true, errCode: constant.ErrInternal},
+		{name: "error: same warehouse", req: &model.TransferStockRequest{ProductID: 2, FromWarehouseID: 1, ToWarehouseID: 1, Quantity: 4}, noRepo: true, wantErr: true, errCode: constant.ErrInvalidRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			txRepo := txmocks.NewTxRepository(t)
+			warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+			if !tt.noRepo {
+				tx := &sqlx.Tx{}
+				txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				warehouseRepo.On("TransferStockTx", mock.Anything, tx, tt.req).Return(tt.repoErr).Once()
+				if tt.repoErr == nil {
+					txRepo.On("CommitTx", tx).Return(nil).Once()
+				} else {
+					txRepo.On("RollbackTx", tx).Return(nil).Once()
+				}
+			}
+
+			app := appwarehouse.NewWarehouseApp(txRepo, warehouseRepo, productmocks.NewProductRepository(t), &fakeBackInStock{}, fakePrefs{})
+			err := app.TransferStock(context.Background(), tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TransferStock() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+			}
+		})
+	}
+}
+
+func TestWarehouseApp_AdjustStock(t *testing.T) {
+	type fields struct {
+		txRepo        *txmocks.TxRepository
+		warehouseRepo *warehousemocks.WarehouseRepository
+	}
+	tests := []struct {
+		name     string
+		req      *model.StockAdjustmentRequest
+		mockCall func(f fields, req *model.StockAdjustmentRequest)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name: "success: restock",
+			req:  &model.StockAdjustmentRequest{WarehouseID: 1, ProductID: 2, Quantity: 5},
+			mockCall: func(f fields, req *model.StockAdjustmentRequest) {
+				tx := &sqlx.Tx{}
+				f.warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(1)).Return(&model.WarehouseEntity{ID: 1}, nil).Once()
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(2)).Return(int64(3), nil).Once()
+				f.warehouseRepo.On("AdjustStockTx", mock.Anything, tx, req).Return(nil).Once()
+				f.warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(2)).Return(int64(8), nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+			},
+		},
+		{
+			name: "success: write-off down to the reserved stock",
+			req:  &model.StockAdjustmentRequest{WarehouseID: 1, ProductID: 2, Quantity: -5},
+			mockCall: func(f fields, req *model.StockAdjustmentRequest) {
+				tx := &sqlx.Tx{}
+				f.warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(1)).Return(&model.WarehouseEntity{ID: 1}, nil).Once()
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.warehouseRepo.On("AdjustStockTx", mock.Anything, tx, req).Return(nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(nil).Once()
+			},
+		},
+		{
+			name:    "error: zero quantity",
+			req:     &model.StockAdjustmentRequest{WarehouseID: 1, ProductID: 2, Quantity: 0},
+			wantErr: true,
+			errCode: constant.ErrInvalidRequest,
+		},
+		{
+			name: "error: unknown warehouse",
+			req:  &model.StockAdjustmentRequest{WarehouseID: 9, ProductID: 2, Quantity: 5},
+			mockCall: func(f fields, req *model.StockAdjustmentRequest) {
+				f.warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(9)).Return(nil, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name: "error: get warehouse fails",
+			req:  &model.StockAdjustmentRequest{WarehouseID: 1, ProductID: 2, Quantity: 5},
+			mockCall: func(f fields, req *model.StockAdjustmentRequest) {
+				f.warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(1)).Return(nil, errors.New("db error")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name: "error: write-off below the reserved stock",
+			req:  &model.StockAdjustmentRequest{WarehouseID: 1, ProductID: 2, Quantity: -6},
+			mockCall: func(f fields, req *model.StockAdjustmentRequest) {
+				tx := &sqlx.Tx{}
+				f.warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(1)).Return(&model.WarehouseEntity{ID: 1}, nil).Once()
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.warehouseRepo.On("AdjustStockTx", mock.Anything, tx, req).Return(cerr.SetCustomError(constant.ErrInsufficientStock)).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInsufficientStock,
+		},
+		{
+			name: "error: adjust stock fails",
+			req:  &model.StockAdjustmentRequest{WarehouseID: 1, ProductID: 2, Quantity: -1},
+			mockCall: func(f fields, req *model.StockAdjustmentRequest) {
+				tx := &sqlx.Tx{}
+				f.warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(1)).Return(&model.WarehouseEntity{ID: 1}, nil).Once()
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.warehouseRepo.On("AdjustStockTx", mock.Anything, tx, req).Return(errors.New("db error")).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name: "error: commit fails",
+			req:  &model.StockAdjustmentRequest{WarehouseID: 1, ProductID: 2, Quantity: -1},
+			mockCall: func(f fields, req *model.StockAdjustmentRequest) {
+				tx := &sqlx.Tx{}
+				f.warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(1)).Return(&model.WarehouseEntity{ID: 1}, nil).Once()
+				f.txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				f.warehouseRepo.On("AdjustStockTx", mock.Anything, tx, req).Return(nil).Once()
+				f.txRepo.On("CommitTx", tx).Return(errors.New("db error")).Once()
+				f.txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			f := fields{
+				txRepo:        txmocks.NewTxRepository(t),
+				warehouseRepo: warehousemocks.NewWarehouseRepository(t),
+			}
+			if tt.mockCall != nil {
+				tt.mockCall(f, tt.req)
+			}
+
+			app := appwarehouse.NewWarehouseApp(f.txRepo, f.warehouseRepo, productmocks.NewProductRepository(t), &fakeBackInStock{}, fakePrefs{})
+			err := app.AdjustStock(context.Background(), tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AdjustStock() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+			}
+		})
+	}
+}
+
+func TestWarehouseApp_AdjustStock_NoPublisherKeepsSubscriptions(t *testing.T) {
+	txRepo := txmocks.NewTxRepository(t)
+	warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+	productRepo := productmocks.NewProductRepository(t)
+
+	req := &model.StockAdjustmentRequest{WarehouseID: 1, ProductID: 2, Quantity: 5}
+	tx := &sqlx.Tx{}
+	warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(1)).Return(&model.WarehouseEntity{ID: 1}, nil).Once()
+	txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+	warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(2)).Return(int64(0), nil).Once()
+	warehouseRepo.On("AdjustStockTx", mock.Anything, tx, req).Return(nil).Once()
+	warehouseRepo.On("GetTotalAvailableStockTx", mock.Anything, tx, uint64(2)).Return(int64(5), nil).Once()
+	txRepo.On("CommitTx", tx).Return(nil).Once()
+
+	// productRepo has no expectations, subscribers must not be touched
+	app := appwarehouse.NewWarehouseApp(txRepo, warehouseRepo, productRepo, nil, nil)
+	if err := app.AdjustStock(context.Background(), req); err != nil {
+		t.Fatalf("AdjustStock() error = %v", err)
+	}
+}
+
+func TestWarehouseApp_LowStockReport(t *testing.T) {
+	items := []model.LowStockItem{{ProductID: 7, Name: "Keyboard", Available: 0}, {ProductID: 3, Name: "Mouse", Available: 4}}
+
+	tests := []struct {
+		name      string
+		threshold int64
+		repoItems []model.LowStockItem
+		repoErr   error
+		noRepo    bool
+		want      []model.LowStockItem
+		wantErr   bool
+		errCode   constant.ErrorType
+	}{
+		{name: "success", threshold: 5, repoItems: items, want: items},
+		{name: "success: nothing low", threshold: 0, repoItems: []model.LowStockItem{}, want: []model.LowStockItem{}},
+		{name: "error: negative threshold", threshold: -1, noRepo: true, wantErr: true, errCode: constant.ErrInvalidRequest},
+		{name: "error: query failed", threshold: 5, repoErr: errors.New("db error"), wantErr: true, errCode: constant.ErrInternal},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+			if !tt.noRepo {
+				warehouseRepo.On("ListLowStockProducts", mock.Anything, tt.threshold).Return(tt.repoItems, tt.repoErr).Once()
+			}
+
+			app := appwarehouse.NewWarehouseApp(txmocks.NewTxRepository(t), warehouseRepo, productmocks.NewProductRepository(t), nil, nil)
+			got, err := app.LowStockReport(context.Background(), tt.threshold)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LowStockReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("LowStockReport() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarehouseApp_ReconcileReserved(t *testing.T) {
+	corrected := []model.ReservedDiscrepancy{{WarehouseID: 1, ProductID: 2, Reserved: 5, Actual: 3}}
+
+	tests := []struct {
+		name        string
+		warehouseID uint64
+		warehouse   *model.WarehouseEntity
+		repoRes     []model.ReservedDiscrepancy
+		repoErr     error
+		noTx        bool
+		want        []model.ReservedDiscrepancy
+		wantErr     bool
+		errCode     constant.ErrorType
+	}{
+		{name: "success: one warehouse", warehouseID: 1, warehouse: &model.WarehouseEntity{ID: 1}, repoRes: corrected, want: corrected},
+		{name: "success: all warehouses", repoRes: corrected, want: corrected},
+		{name: "error: unknown warehouse", warehouseID: 9, noTx: true, wantErr: true, errCode: constant.ErrNotFound},
+		{name: "error: reconcile failed", repoErr: errors.New("db error"), wantErr: true, errCode: constant.ErrInternal},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			txRepo := txmocks.NewTxRepository(t)
+			warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+			if tt.warehouseID != 0 {
+				warehouseRepo.On("GetWarehouseByID", mock.Anything, tt.warehouseID).Return(tt.warehouse, nil).Once()
+			}
+			if !tt.noTx {
+				tx := &sqlx.Tx{}
+				txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				warehouseRepo.On("ReconcileReservedTx", mock.Anything, tx, tt.warehouseID).Return(tt.repoRes, tt.repoErr).Once()
+				if tt.repoErr == nil {
+					txRepo.On("CommitTx", tx).Return(nil).Once()
+				} else {
+					txRepo.On("RollbackTx", tx).Return(nil).Once()
+				}
+			}
+
+			app := appwarehouse.NewWarehouseApp(txRepo, warehouseRepo, productmocks.NewProductRepository(t), nil, nil)
+			got, err := app.ReconcileReserved(context.Background(), tt.warehouseID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReconcileReserved() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ReconcileReserved() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarehouseApp_DeactivateWarehouse_TransferOut(t *testing.T) {
+	active := &model.WarehouseEntity{ID: 2, Status: constant.WarehouseStatusActive}
+	stocks := []model.WarehouseStock{
+		{ID: 10, WarehouseID: 1, ProductID: 5, Stock: 8},
+		{ID: 11, WarehouseID: 1, ProductID: 6, Stock: 0},
+		{ID: 12, WarehouseID: 1, ProductID: 7, Stock: 3},
+	}
+
+	tests := []struct {
+		name     string
+		target   *model.WarehouseEntity
+		mockCall func(tx *sqlx.Tx, txRepo *txmocks.TxRepository, warehouseRepo *warehousemocks.WarehouseRepository)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name:   "success: stock moved then deactivated",
+			target: active,
+			mockCall: func(tx *sqlx.Tx, txRepo *txmocks.TxRepository, warehouseRepo *warehousemocks.WarehouseRepository) {
+				txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				warehouseRepo.On("GetWarehouseStocksTx", mock.Anything, tx, uint64(1)).Return(stocks, nil).Once()
+				// the empty row is skipped
+				warehouseRepo.On("TransferStockTx", mock.Anything, tx, &model.TransferStockRequest{ProductID: 5, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 8}).Return(nil).Once()
+				warehouseRepo.On("TransferStockTx", mock.Anything, tx, &model.TransferStockRequest{ProductID: 7, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: 3}).Return(nil).Once()
+				warehouseRepo.On("UpdateWarehouseStatusTx", mock.Anything, tx, uint64(1), constant.WarehouseStatusInactive).Return(nil).Once()
+				txRepo.On("CommitTx", tx).Return(nil).Once()
+			},
+		},
+		{
+			name:   "error: reserved stock blocks, nothing moved",
+			target: active,
+			mockCall: func(tx *sqlx.Tx, txRepo *txmocks.TxRepository, warehouseRepo *warehousemocks.WarehouseRepository) {
+				txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				warehouseRepo.On("GetWarehouseStocksTx", mock.Anything, tx, uint64(1)).
+					Return([]model.WarehouseStock{{ID: 10, WarehouseID: 1, ProductID: 5, Stock: 8, Reserved: 2}}, nil).Once()
+				warehouseRepo.On("ListReservationHolders", mock.Anything, uint64(1)).Return([]model.ID{40}, []model.ID{}, nil).Once()
+				txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrWarehouseHasReservedStock,
+		},
+		{
+			name:   "error: transfer failure rolls back",
+			target: active,
+			mockCall: func(tx *sqlx.Tx, txRepo *txmocks.TxRepository, warehouseRepo *warehousemocks.WarehouseRepository) {
+				txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				warehouseRepo.On("GetWarehouseStocksTx", mock.Anything, tx, uint64(1)).Return(stocks, nil).Once()
+				warehouseRepo.On("TransferStockTx", mock.Anything, tx, mock.Anything).Return(errors.New("db error")).Once()
+				txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+		{
+			name:    "error: target inactive",
+			target:  &model.WarehouseEntity{ID: 2, Status: constant.WarehouseStatusInactive},
+			wantErr: true,
+			errCode: constant.ErrWarehouseInactive,
+		},
+		{
+			name:    "error: target unknown",
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			txRepo := txmocks.NewTxRepository(t)
+			warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+			warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(1)).Return(&model.WarehouseEntity{ID: 1, Status: constant.WarehouseStatusActive}, nil).Once()
+			warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(2)).Return(tt.target, nil).Once()
+			if tt.mockCall != nil {
+				tt.mockCall(&sqlx.Tx{}, txRepo, warehouseRepo)
+			}
+
+			app := appwarehouse.NewWarehouseApp(txRepo, warehouseRepo, productmocks.NewProductRepository(t), nil, nil)
+			err := app.DeactivateWarehouse(context.Background(), 1, 2)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeactivateWarehouse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) {
+					t.Fatalf("error type = %T, want CustomError", err)
+				}
+				if ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("error code = %s, want %s", ce.ErrorCode(), constant.ErrorTypeCode[tt.errCode])
+				}
+			}
+		})
+	}
+}
+
+func TestWarehouseApp_DeactivateWarehouse_TransferToItself(t *testing.T) {
+	app := appwarehouse.NewWarehouseApp(txmocks.NewTxRepository(t), warehousemocks.NewWarehouseRepository(t), productmocks.NewProductRepository(t), nil, nil)
+	if err := app.DeactivateWarehouse(context.Background(), 1, 1); !cerr.IsType(err, constant.ErrInvalidRequest) {
+		t.Fatalf("DeactivateWarehouse() error = %v, want invalid request", err)
+	}
+}
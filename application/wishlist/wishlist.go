@@ -0,0 +1,69 @@
+package wishlist
+
+import (
+	"context"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/model"
+	wishlistrepo "github.com/muhammadheryan/e-commerce/repository/wishlist"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
+	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"go.uber.org/zap"
+)
+
+type WishlistApp interface {
+	AddToWishlist(ctx context.Context, userID, productID uint64) error
+	RemoveFromWishlist(ctx context.Context, userID, productID uint64) error
+	ListWishlist(ctx context.Context, userID uint64) ([]model.WishlistItem, error)
+}
+
+type wishlistAppImpl struct {
+	wishlistRepo wishlistrepo.WishlistRepository
+}
+
+func NewWishlistApp(wishlistRepo wishlistrepo.WishlistRepository) WishlistApp {
+	return &wishlistAppImpl{wishlistRepo: wishlistRepo}
+}
+
+func (s *wishlistAppImpl) AddToWishlist(ctx context.Context, userID, productID uint64) error {
+	// adding a product that is already saved is a no-op
+	exists, err := s.wishlistRepo.Exists(ctx, userID, productID)
+	if err != nil {
+		logger.Error("[AddToWishlist] error wishlistRepo.Exists", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if exists {
+		return nil
+	}
+
+	added, err := s.wishlistRepo.Add(ctx, userID, productID)
+	if err != nil {
+		logger.Error("[AddToWishlist] error wishlistRepo.Add", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if !added {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *wishlistAppImpl) RemoveFromWishlist(ctx context.Context, userID, productID uint64) error {
+	removed, err := s.wishlistRepo.Remove(ctx, userID, productID)
+	if err != nil {
+		logger.Error("[RemoveFromWishlist] error wishlistRepo.Remove", zap.String("error", err.Error()))
+		return errors.SetCustomError(constant.ErrInternal)
+	}
+	if !removed {
+		return errors.SetCustomError(constant.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *wishlistAppImpl) ListWishlist(ctx context.Context, userID uint64) ([]model.WishlistItem, error) {
+	items, err := s.wishlistRepo.List(ctx, userID)
+	if err != nil {
+		logger.Error("[ListWishlist] error wishlistRepo.List", zap.String("error", err.Error()))
+		return nil, errors.SetCustomError(constant.ErrInternal)
+	}
+	return items, nil
+}
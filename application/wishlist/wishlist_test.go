@@ -0,0 +1,157 @@
+package wishlist_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	appwishlist "github.com/muhammadheryan/e-commerce/application/wishlist"
+	"github.com/muhammadheryan/e-commerce/constant"
+	wishlistmocks "github.com/muhammadheryan/e-commerce/mocks/repository/wishlist"
+	"github.com/muhammadheryan/e-commerce/model"
+	cerr "github.com/muhammadheryan/e-commerce/utils/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWishlistApp_AddToWishlist(t *testing.T) {
+	type fields struct {
+		wishlistRepo *wishlistmocks.WishlistRepository
+	}
+	type args struct {
+		ctx       context.Context
+		userID    uint64
+		productID uint64
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		args     args
+		mockCall func(f fields)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name:   "success: add new product",
+			fields: fields{wishlistRepo: wishlistmocks.NewWishlistRepository(t)},
+			args:   args{ctx: context.Background(), userID: 1, productID: 10},
+			mockCall: func(f fields) {
+				f.wishlistRepo.On("Exists", mock.Anything, uint64(1), uint64(10)).Return(false, nil).Once()
+				f.wishlistRepo.On("Add", mock.Anything, uint64(1), uint64(10)).Return(true, nil).Once()
+			},
+		},
+		{
+			name:   "success: already saved is a no-op",
+			fields: fields{wishlistRepo: wishlistmocks.NewWishlistRepository(t)},
+			args:   args{ctx: context.Background(), userID: 1, productID: 10},
+			mockCall: func(f fields) {
+				f.wishlistRepo.On("Exists", mock.Anything, uint64(1), uint64(10)).Return(true, nil).Once()
+			},
+		},
+		{
+			name:   "error: product not found",
+			fields: fields{wishlistRepo: wishlistmocks.NewWishlistRepository(t)},
+			args:   args{ctx: context.Background(), userID: 1, productID: 99},
+			mockCall: func(f fields) {
+				f.wishlistRepo.On("Exists", mock.Anything, uint64(1), uint64(99)).Return(false, nil).Once()
+				f.wishlistRepo.On("Add", mock.Anything, uint64(1), uint64(99)).Return(false, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+		{
+			name:   "error: repository failure",
+			fields: fields{wishlistRepo: wishlistmocks.NewWishlistRepository(t)},
+			args:   args{ctx: context.Background(), userID: 1, productID: 10},
+			mockCall: func(f fields) {
+				f.wishlistRepo.On("Exists", mock.Anything, uint64(1), uint64(10)).Return(false, errors.New("db error")).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mockCall != nil {
+				tt.mockCall(tt.fields)
+			}
+			s := appwishlist.NewWishlistApp(tt.fields.wishlistRepo)
+			err := s.AddToWishlist(tt.args.ctx, tt.args.userID, tt.args.productID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AddToWishlist() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("AddToWishlist() error = %v, want code %v", err, tt.errCode)
+				}
+			}
+		})
+	}
+}
+
+func TestWishlistApp_RemoveFromWishlist(t *testing.T) {
+	type fields struct {
+		wishlistRepo *wishlistmocks.WishlistRepository
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		mockCall func(f fields)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name:   "success: remove saved product",
+			fields: fields{wishlistRepo: wishlistmocks.NewWishlistRepository(t)},
+			mockCall: func(f fields) {
+				f.wishlistRepo.On("Remove", mock.Anything, uint64(1), uint64(10)).Return(true, nil).Once()
+			},
+		},
+		{
+			name:   "error: product not in wishlist",
+			fields: fields{wishlistRepo: wishlistmocks.NewWishlistRepository(t)},
+			mockCall: func(f fields) {
+				f.wishlistRepo.On("Remove", mock.Anything, uint64(1), uint64(10)).Return(false, nil).Once()
+			},
+			wantErr: true,
+			errCode: constant.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mockCall != nil {
+				tt.mockCall(tt.fields)
+			}
+			s := appwishlist.NewWishlistApp(tt.fields.wishlistRepo)
+			err := s.RemoveFromWishlist(context.Background(), 1, 10)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RemoveFromWishlist() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var ce cerr.CustomError
+				if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[tt.errCode] {
+					t.Fatalf("RemoveFromWishlist() error = %v, want code %v", err, tt.errCode)
+				}
+			}
+		})
+	}
+}
+
+func TestWishlistApp_ListWishlist(t *testing.T) {
+	repo := wishlistmocks.NewWishlistRepository(t)
+	items := []model.WishlistItem{
+		{ProductID: 10, Name: "Keyboard", Price: 250000, AvailableStock: 3},
+		{ProductID: 11, Name: "Mouse", Price: 100000, AvailableStock: 0},
+	}
+	repo.On("List", mock.Anything, uint64(1)).Return(items, nil).Once()
+
+	s := appwishlist.NewWishlistApp(repo)
+	got, err := s.ListWishlist(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("ListWishlist() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, items) {
+		t.Fatalf("ListWishlist() = %v, want %v", got, items)
+	}
+}
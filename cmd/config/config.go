@@ -1,12 +1,19 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/muhammadheryan/e-commerce/model"
+	"github.com/muhammadheryan/e-commerce/utils/money"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Config holds all configuration for our application
@@ -30,12 +37,83 @@ type Config struct {
 	// RabbitMQ configuration
 	RabbitMQ RabbitMQConfig
 
+	// Store (billing) configuration
+	Store StoreConfig
+
+	// Cart configuration
+	Cart CartConfig
+
+	// Warehouse configuration
+	Warehouse WarehouseConfig
+
+	// Product catalog configuration
+	Product ProductConfig
+
 	ProjectName    string
 	InternalAPIKey string
 }
 
 type OrderConfig struct {
 	OrderExpiration time.Duration
+	// MaxOrderValue is the highest grand total accepted without intervention, zero disables the check
+	MaxOrderValue float64
+	// HighValueAction is what happens above MaxOrderValue: "reject" or "review"
+	HighValueAction string
+	// ExpirationStrategy selects how pending orders expire: "rabbitmq" or "poller"
+	ExpirationStrategy string
+	// ExpirationPollInterval is how often the poller scans for expired orders
+	ExpirationPollInterval time.Duration
+	// OutboxRelayInterval is how often unsent expiration messages are retried
+	OutboxRelayInterval time.Duration
+	// MaxItems caps the line items of one order, zero disables the check
+	MaxItems int
+	// MaxQuantityPerItem caps the quantity of a single line item, zero disables the check
+	MaxQuantityPerItem int
+	// MaxBodyBytes bounds the create order request body before it is decoded, zero disables the check
+	MaxBodyBytes int64
+	// MaxDecodedItems rejects an items array longer than this right after decoding,
+	// before validation walks it, zero disables the check
+	MaxDecodedItems int
+	// ProductLock serializes order creation per product with an advisory Redis lock
+	ProductLock bool
+	// ProductLockTTL bounds how long a lock outlives a crashed holder
+	ProductLockTTL time.Duration
+	// ProductLockWait is how long CreateOrder waits for a busy lock before giving up
+	ProductLockWait time.Duration
+}
+
+// CartConfig holds server-side cart configuration
+type CartConfig struct {
+	// ReserveOnAdd reserves stock when an item is added to the cart instead of at order creation
+	ReserveOnAdd bool
+	// ReservationTTL is how long a cart reservation holds stock
+	ReservationTTL time.Duration
+	// ReservationSweepInterval is how often expired cart reservations are released
+	ReservationSweepInterval time.Duration
+}
+
+// WarehouseConfig holds stock allocation configuration
+type WarehouseConfig struct {
+	// AllocationStrategy decides which warehouses reservations draw from first: "priority" or "available"
+	AllocationStrategy string
+}
+
+// ProductConfig holds product catalog display configuration
+type ProductConfig struct {
+	// LowStockThreshold marks products with this many or fewer available units as low stock, zero disables it
+	LowStockThreshold int64
+	// CacheTTL keeps product details in Redis for this long, zero disables the cache
+	CacheTTL time.Duration
+}
+
+// StoreConfig holds store-wide billing configuration
+type StoreConfig struct {
+	// TaxRate is a fraction, e.g. 0.11 for 11%
+	TaxRate float64
+	// TaxInclusive means product prices already include tax
+	TaxInclusive bool
+	// Currency is the ISO 4217 code of prices, amounts are rounded half-up to its minor unit
+	Currency string
 }
 
 type RabbitMQConfig struct {
@@ -43,6 +121,14 @@ type RabbitMQConfig struct {
 	Port     int
 	User     string
 	Password string
+	// MaxRedeliveries is how many failed cancel attempts an expiration message gets before it is dead-lettered
+	MaxRedeliveries int
+	// ConsumerWorkers is how many expiration messages the consumer handles at once
+	ConsumerWorkers int
+	// MaxConcurrentCancels caps the cancel API calls the consumer has in flight, zero disables the cap
+	MaxConcurrentCancels int
+	// DeadLetterAlert logs a warning and counts every expiration message that is dead-lettered
+	DeadLetterAlert bool
 }
 
 // DatabaseConfig holds database configuration
@@ -56,6 +142,12 @@ type DatabaseConfig struct {
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
+	// QueryTimeout bounds each repository query, zero leaves queries unbounded
+	QueryTimeout time.Duration
+	// ConnectRetries is how many times startup retries a failed MySQL, Redis or RabbitMQ connection
+	ConnectRetries int
+	// ConnectBackoff is the wait before the first retry, doubled after each one
+	ConnectBackoff time.Duration
 }
 
 // ServerConfig holds server configuration
@@ -64,6 +156,56 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	// ShutdownTimeout is how long in-flight requests get to complete on shutdown
+	ShutdownTimeout time.Duration
+	// RequestTimeout is the deadline of each request's context, the outer
+	// bound of the database query timeout. Zero leaves requests unbounded.
+	RequestTimeout time.Duration
+	CORS           CORSConfig
+	RateLimit      RateLimitConfig
+	Compression    CompressionConfig
+	BodyLog        BodyLogConfig
+	// MaxPerPage caps the page size of list endpoints, zero leaves it uncapped
+	MaxPerPage int
+}
+
+// ClampPerPage lowers perPage to MaxPerPage, a larger page is served at the
+// maximum instead of being rejected
+func (s ServerConfig) ClampPerPage(perPage int) int {
+	if s.MaxPerPage > 0 && perPage > s.MaxPerPage {
+		return s.MaxPerPage
+	}
+	return perPage
+}
+
+// BodyLogConfig logs request and response bodies for debugging. It is off by
+// default, Paths limits it to routes under those prefixes (empty means all),
+// bodies are cut at MaxBytes and values of RedactFields are masked.
+type BodyLogConfig struct {
+	Enabled      bool
+	Paths        []string
+	MaxBytes     int
+	RedactFields []string
+}
+
+// CompressionConfig gzips responses of at least MinSize bytes for clients that accept it
+type CompressionConfig struct {
+	Enabled bool
+	MinSize int
+}
+
+// RateLimitConfig throttles public requests per client IP, a zero rate disables it
+type RateLimitConfig struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// CORSConfig lists what cross-origin browser clients may do, no origins means
+// cross-origin requests are denied
+type CORSConfig struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
 }
 
 // RedisConfig holds Redis connection configuration
@@ -76,12 +218,38 @@ type RedisConfig struct {
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
-	JWTSecret      string
-	JWTExpiration  time.Duration
-	SessionExpTime time.Duration
+	// JWTSecret signs new tokens, they carry JWTKeyID as their kid header
+	JWTSecret string
+	JWTKeyID  string
+	// JWTAcceptedSecrets are earlier secrets by kid, tokens they signed stay
+	// valid until they expire so the signing secret can be rotated without
+	// logging everyone out
+	JWTAcceptedSecrets map[string]string
+	JWTExpiration      time.Duration
+	SessionExpTime     time.Duration
+	// SingleSession revokes a user's earlier sessions when they log in again
+	SingleSession bool
+	// BcryptCost is the work factor passwords are hashed with, see PasswordCost
+	BcryptCost int
+	// VerificationTokenTTL is how long the email verification token issued on
+	// register stays valid
+	VerificationTokenTTL time.Duration
+	// RequireVerifiedEmail keeps users who haven't verified their email from ordering
+	RequireVerifiedEmail bool
 }
 
-// Load reads configuration from environment variables
+// PasswordCost is BcryptCost, or bcrypt's default when it is outside the range
+// bcrypt accepts (including unset)
+func (a AuthConfig) PasswordCost() int {
+	if a.BcryptCost < bcrypt.MinCost || a.BcryptCost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return a.BcryptCost
+}
+
+// Load reads configuration from environment variables, then from the file
+// named by CONFIG_FILE for whatever they leave unset, see loadFile. It exits
+// when the file can't be read, the values are checked by Validate.
 func Load() *Config {
 	// Load .env file
 	err := godotenv.Load()
@@ -89,23 +257,59 @@ func Load() *Config {
 		log.Printf("Warning: Error loading .env file: %v", err)
 	}
 
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadFile(path); err != nil {
+			log.Fatalf("Error loading config file: %v", err)
+		}
+	}
+
+	return fromEnv()
+}
+
+func fromEnv() *Config {
 	return &Config{
 		Database: DatabaseConfig{
-			Host:            getEnv("DB_HOST", "127.0.0.1"),
+			Host:            getEnv("DB_HOST", ""),
 			Port:            getEnvAsInt("DB_PORT", 3306),
-			User:            getEnv("DB_USER", "user"),
+			User:            getEnv("DB_USER", ""),
 			Password:        getEnv("DB_PASSWORD", ""),
-			Name:            getEnv("DB_NAME", "tests"),
+			Name:            getEnv("DB_NAME", ""),
 			URL:             getEnv("DATABASE_URL", "mysql://root:@tcp(127.0.0.1:3306)/tests?parseTime=true"),
 			MaxOpenConns:    getEnvAsInt("DB_MAX_OPEN_CONNS", 10),
 			MaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 5),
 			ConnMaxLifetime: time.Duration(getEnvAsInt("DB_CONN_MAX_LIFETIME", 3600)) * time.Second,
+			QueryTimeout:    time.Duration(getEnvAsInt("DB_QUERY_TIMEOUT_SECONDS", 10)) * time.Second,
+			ConnectRetries:  getEnvAsInt("DB_CONNECT_RETRIES", 5),
+			ConnectBackoff:  time.Duration(getEnvAsInt("DB_CONNECT_BACKOFF_SECONDS", 1)) * time.Second,
 		},
 		Server: ServerConfig{
 			Port:         getEnv("SERVER_PORT", "8080"),
 			ReadTimeout:  time.Duration(getEnvAsInt("SERVER_READ_TIMEOUT", 5)) * time.Second,
 			WriteTimeout: time.Duration(getEnvAsInt("SERVER_WRITE_TIMEOUT", 10)) * time.Second,
 			IdleTimeout:  time.Duration(getEnvAsInt("SERVER_IDLE_TIMEOUT", 30)) * time.Second,
+
+			ShutdownTimeout: time.Duration(getEnvAsInt("SERVER_SHUTDOWN_TIMEOUT", 20)) * time.Second,
+			RequestTimeout:  time.Duration(getEnvAsInt("SERVER_REQUEST_TIMEOUT", 8)) * time.Second,
+			MaxPerPage:      getEnvAsInt("SERVER_MAX_PER_PAGE", 100),
+			CORS: CORSConfig{
+				AllowedOrigins: getEnvAsSlice("CORS_ALLOWED_ORIGINS", nil),
+				AllowedMethods: getEnvAsSlice("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE"}),
+				AllowedHeaders: getEnvAsSlice("CORS_ALLOWED_HEADERS", []string{"Authorization", "Content-Type", "X-Request-ID"}),
+			},
+			RateLimit: RateLimitConfig{
+				RequestsPerSecond: getEnvAsFloat("RATE_LIMIT_RPS", 10),
+				Burst:             getEnvAsInt("RATE_LIMIT_BURST", 20),
+			},
+			Compression: CompressionConfig{
+				Enabled: getEnvAsBool("COMPRESSION_ENABLED", true),
+				MinSize: getEnvAsInt("COMPRESSION_MIN_BYTES", 1024),
+			},
+			BodyLog: BodyLogConfig{
+				Enabled:      getEnvAsBool("BODY_LOG_ENABLED", false),
+				Paths:        getEnvAsSlice("BODY_LOG_PATHS", nil),
+				MaxBytes:     getEnvAsInt("BODY_LOG_MAX_BYTES", 4096),
+				RedactFields: getEnvAsSlice("BODY_LOG_REDACT_FIELDS", []string{"password", "token", "secret", "phone", "authorization"}),
+			},
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "127.0.0.1"),
@@ -114,22 +318,66 @@ func Load() *Config {
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
 		Auth: AuthConfig{
-			JWTSecret:      getEnv("JWT_SECRET", "SECRET"),
-			JWTExpiration:  time.Duration(getEnvAsInt("JWT_EXPIRATION", 86400)) * time.Second,
-			SessionExpTime: time.Duration(getEnvAsInt("SESSION_EXPIRATION", 86400)) * time.Second,
+			JWTSecret:          getEnv("JWT_SECRET", ""),
+			JWTKeyID:           getEnv("JWT_KEY_ID", "default"),
+			JWTAcceptedSecrets: getEnvAsKeyedSecrets("JWT_ACCEPTED_SECRETS"),
+			JWTExpiration:      time.Duration(getEnvAsInt("JWT_EXPIRATION", 86400)) * time.Second,
+			SessionExpTime:     time.Duration(getEnvAsInt("SESSION_EXPIRATION", 86400)) * time.Second,
+			SingleSession:      getEnvAsBool("AUTH_SINGLE_SESSION", false),
+			BcryptCost:         getEnvAsInt("AUTH_BCRYPT_COST", bcrypt.DefaultCost),
+
+			VerificationTokenTTL: time.Duration(getEnvAsInt("AUTH_VERIFICATION_TOKEN_SECONDS", 86400)) * time.Second,
+			RequireVerifiedEmail: getEnvAsBool("AUTH_REQUIRE_VERIFIED_EMAIL", true),
 		},
 		Order: OrderConfig{
 			OrderExpiration: time.Duration(getEnvAsInt("ORDER_EXPIRES_SECONDS", 3600)) * time.Second,
+			MaxOrderValue:   getEnvAsFloat("ORDER_MAX_VALUE", 0),
+			HighValueAction: getEnv("ORDER_HIGH_VALUE_ACTION", "reject"),
+
+			ExpirationStrategy:     getEnv("ORDER_EXPIRATION_STRATEGY", "rabbitmq"),
+			ExpirationPollInterval: time.Duration(getEnvAsInt("ORDER_EXPIRATION_POLL_SECONDS", 30)) * time.Second,
+			OutboxRelayInterval:    time.Duration(getEnvAsInt("ORDER_OUTBOX_RELAY_SECONDS", 10)) * time.Second,
+
+			MaxItems:           getEnvAsInt("ORDER_MAX_ITEMS", 50),
+			MaxQuantityPerItem: getEnvAsInt("ORDER_MAX_QUANTITY_PER_ITEM", 1000),
+			MaxBodyBytes:       int64(getEnvAsInt("ORDER_MAX_BODY_BYTES", 64<<10)),
+			MaxDecodedItems:    getEnvAsInt("ORDER_MAX_DECODED_ITEMS", 500),
+
+			ProductLock:     getEnvAsBool("ORDER_PRODUCT_LOCK_ENABLED", false),
+			ProductLockTTL:  time.Duration(getEnvAsInt("ORDER_PRODUCT_LOCK_TTL_SECONDS", 5)) * time.Second,
+			ProductLockWait: time.Duration(getEnvAsInt("ORDER_PRODUCT_LOCK_WAIT_SECONDS", 2)) * time.Second,
 		},
 		RabbitMQ: RabbitMQConfig{
 			Host:     getEnv("RABBITMQ_HOST", "127.0.0.1"),
 			Port:     getEnvAsInt("RABBITMQ_PORT", 5672),
 			User:     getEnv("RABBITMQ_USER", "guest"),
 			Password: getEnv("RABBITMQ_PASSWORD", "guest"),
+
+			MaxRedeliveries:      getEnvAsInt("RABBITMQ_MAX_REDELIVERIES", 5),
+			ConsumerWorkers:      getEnvAsInt("RABBITMQ_CONSUMER_WORKERS", 1),
+			MaxConcurrentCancels: getEnvAsInt("RABBITMQ_MAX_CONCURRENT_CANCELS", 4),
+			DeadLetterAlert:      getEnvAsBool("RABBITMQ_DEAD_LETTER_ALERT_ENABLED", true),
+		},
+		Store: StoreConfig{
+			TaxRate:      getEnvAsFloat("STORE_TAX_RATE", 0),
+			TaxInclusive: getEnvAsBool("STORE_TAX_INCLUSIVE", false),
+			Currency:     strings.ToUpper(getEnv("STORE_CURRENCY", money.DefaultCurrency)),
+		},
+		Cart: CartConfig{
+			ReserveOnAdd:             getEnvAsBool("CART_RESERVE_ON_ADD", false),
+			ReservationTTL:           time.Duration(getEnvAsInt("CART_RESERVATION_TTL_SECONDS", 900)) * time.Second,
+			ReservationSweepInterval: time.Duration(getEnvAsInt("CART_RESERVATION_SWEEP_SECONDS", 60)) * time.Second,
+		},
+		Product: ProductConfig{
+			LowStockThreshold: int64(getEnvAsInt("PRODUCT_LOW_STOCK_THRESHOLD", 5)),
+			CacheTTL:          time.Duration(getEnvAsInt("PRODUCT_CACHE_TTL_SECONDS", 300)) * time.Second,
+		},
+		Warehouse: WarehouseConfig{
+			AllocationStrategy: getEnv("STOCK_ALLOCATION_STRATEGY", "priority"),
 		},
 		Environment:    getEnv("ENV", "development"),
 		ProjectName:    getEnv("PROJECT_NAME", "project-name-test"),
-		InternalAPIKey: getEnv("INTERNAL_API_KEY", "internal-key"),
+		InternalAPIKey: getEnv("INTERNAL_API_KEY", ""),
 	}
 }
 
@@ -152,6 +400,110 @@ func getEnvAsInt(key string, fallback int) int {
 	return fallback
 }
 
+// getEnvAsFloat gets an environment variable as float with a fallback value
+func getEnvAsFloat(key string, fallback float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+		log.Printf("Warning: Invalid float value for %s: %s, using fallback: %v", key, value, fallback)
+	}
+	return fallback
+}
+
+// getEnvAsBool gets an environment variable as boolean with a fallback value
+func getEnvAsBool(key string, fallback bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+		log.Printf("Warning: Invalid boolean value for %s: %s, using fallback: %t", key, value, fallback)
+	}
+	return fallback
+}
+
+// getEnvAsSlice gets a comma separated environment variable as a slice with a fallback value
+func getEnvAsSlice(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parts := strings.Split(value, ",")
+	result := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// getEnvAsKeyedSecrets reads a comma separated list of id:secret pairs, malformed
+// entries are skipped with a warning that leaves the secret out
+func getEnvAsKeyedSecrets(key string) map[string]string {
+	result := map[string]string{}
+	for i, pair := range getEnvAsSlice(key, nil) {
+		id, secret, ok := strings.Cut(pair, ":")
+		id, secret = strings.TrimSpace(id), strings.TrimSpace(secret)
+		if !ok || id == "" || secret == "" {
+			log.Printf("Warning: Invalid entry %d in %s, expected id:secret, skipping", i, key)
+			continue
+		}
+		result[id] = secret
+	}
+	return result
+}
+
+// Validate reports every setting the service can't safely run with. An empty
+// JWT secret would verify tokens anyone can forge and an empty internal API key
+// would open the internal endpoints, so the service must not start with either.
+func (c *Config) Validate() error {
+	var errs []error
+	required := []struct {
+		key   string
+		value string
+	}{
+		{"JWT_SECRET", c.Auth.JWTSecret},
+		{"INTERNAL_API_KEY", c.InternalAPIKey},
+		{"DB_HOST", c.Database.Host},
+		{"DB_USER", c.Database.User},
+		{"DB_NAME", c.Database.Name},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", r.key))
+		}
+	}
+
+	positive := []struct {
+		key   string
+		value int
+	}{
+		{"DB_PORT", c.Database.Port},
+		{"DB_MAX_OPEN_CONNS", c.Database.MaxOpenConns},
+		{"DB_MAX_IDLE_CONNS", c.Database.MaxIdleConns},
+		// tickers panic on a non-positive interval
+		{"ORDER_EXPIRATION_POLL_SECONDS", int(c.Order.ExpirationPollInterval / time.Second)},
+		{"ORDER_OUTBOX_RELAY_SECONDS", int(c.Order.OutboxRelayInterval / time.Second)},
+		{"CART_RESERVATION_SWEEP_SECONDS", int(c.Cart.ReservationSweepInterval / time.Second)},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %d", p.key, p.value))
+		}
+	}
+	// the server drops the connection at the write timeout, a request deadline
+	// past it would never get its 504 out
+	if c.Server.RequestTimeout > 0 && c.Server.WriteTimeout > 0 && c.Server.RequestTimeout >= c.Server.WriteTimeout {
+		errs = append(errs, fmt.Errorf("SERVER_REQUEST_TIMEOUT (%s) must be shorter than SERVER_WRITE_TIMEOUT (%s)", c.Server.RequestTimeout, c.Server.WriteTimeout))
+	}
+	if _, ok := money.MinorUnits(c.Store.Currency); !ok {
+		errs = append(errs, fmt.Errorf("STORE_CURRENCY %q is not a supported currency", c.Store.Currency))
+	}
+
+	return errors.Join(errs...)
+}
+
 // GetDSN returns database connection string for Go applications
 // Includes timeout parameters to handle local-to-docker network latency
 func (c *Config) GetDSN() string {
@@ -172,3 +524,95 @@ func (c *Config) IsDevelopment() bool {
 func (c *Config) IsProduction() bool {
 	return c.Environment == "production"
 }
+
+// Effective returns the non-secret settings the server is running with.
+// Credentials (JWT secret, database/redis/rabbitmq passwords, database URL,
+// internal API key) are deliberately left out.
+func (c *Config) Effective() model.EffectiveConfig {
+	return model.EffectiveConfig{
+		Environment: c.Environment,
+		ProjectName: c.ProjectName,
+		Server: model.EffectiveServerConfig{
+			Port:                   c.Server.Port,
+			ReadTimeoutSeconds:     int64(c.Server.ReadTimeout.Seconds()),
+			WriteTimeoutSeconds:    int64(c.Server.WriteTimeout.Seconds()),
+			IdleTimeoutSeconds:     int64(c.Server.IdleTimeout.Seconds()),
+			ShutdownTimeoutSeconds: int64(c.Server.ShutdownTimeout.Seconds()),
+			RequestTimeoutSeconds:  int64(c.Server.RequestTimeout.Seconds()),
+			CORSAllowedOrigins:     c.Server.CORS.AllowedOrigins,
+			RateLimitRPS:           c.Server.RateLimit.RequestsPerSecond,
+			RateLimitBurst:         c.Server.RateLimit.Burst,
+			CompressionEnabled:     c.Server.Compression.Enabled,
+			CompressionMinBytes:    c.Server.Compression.MinSize,
+			BodyLogEnabled:         c.Server.BodyLog.Enabled,
+			BodyLogPaths:           c.Server.BodyLog.Paths,
+			MaxPerPage:             c.Server.MaxPerPage,
+		},
+		Database: model.EffectiveDatabaseConfig{
+			MaxOpenConns:           c.Database.MaxOpenConns,
+			MaxIdleConns:           c.Database.MaxIdleConns,
+			ConnMaxLifetimeSeconds: int64(c.Database.ConnMaxLifetime.Seconds()),
+			QueryTimeoutSeconds:    int64(c.Database.QueryTimeout.Seconds()),
+			ConnectRetries:         c.Database.ConnectRetries,
+			ConnectBackoffSeconds:  int64(c.Database.ConnectBackoff.Seconds()),
+		},
+		Auth: model.EffectiveAuthConfig{
+			JWTExpirationSeconds:     int64(c.Auth.JWTExpiration.Seconds()),
+			SessionExpirationSeconds: int64(c.Auth.SessionExpTime.Seconds()),
+			SingleSession:            c.Auth.SingleSession,
+			JWTKeyID:                 c.Auth.JWTKeyID,
+			JWTAcceptedKeyIDs:        c.Auth.acceptedKeyIDs(),
+			BcryptCost:               c.Auth.PasswordCost(),
+			VerificationTokenSeconds: int64(c.Auth.VerificationTokenTTL.Seconds()),
+			RequireVerifiedEmail:     c.Auth.RequireVerifiedEmail,
+		},
+		Order: model.EffectiveOrderConfig{
+			OrderExpirationSeconds:        int64(c.Order.OrderExpiration.Seconds()),
+			MaxOrderValue:                 c.Order.MaxOrderValue,
+			HighValueAction:               c.Order.HighValueAction,
+			ExpirationStrategy:            c.Order.ExpirationStrategy,
+			ExpirationPollIntervalSeconds: int64(c.Order.ExpirationPollInterval.Seconds()),
+			OutboxRelayIntervalSeconds:    int64(c.Order.OutboxRelayInterval.Seconds()),
+			MaxItems:                      c.Order.MaxItems,
+			MaxQuantityPerItem:            c.Order.MaxQuantityPerItem,
+			MaxBodyBytes:                  c.Order.MaxBodyBytes,
+			MaxDecodedItems:               c.Order.MaxDecodedItems,
+			ProductLock:                   c.Order.ProductLock,
+			ProductLockTTLSeconds:         int64(c.Order.ProductLockTTL.Seconds()),
+			ProductLockWaitSeconds:        int64(c.Order.ProductLockWait.Seconds()),
+		},
+		RabbitMQ: model.EffectiveRabbitMQConfig{
+			MaxRedeliveries:      c.RabbitMQ.MaxRedeliveries,
+			ConsumerWorkers:      c.RabbitMQ.ConsumerWorkers,
+			MaxConcurrentCancels: c.RabbitMQ.MaxConcurrentCancels,
+			DeadLetterAlert:      c.RabbitMQ.DeadLetterAlert,
+		},
+		Store: model.EffectiveStoreConfig{
+			TaxRate:      c.Store.TaxRate,
+			TaxInclusive: c.Store.TaxInclusive,
+			Currency:     c.Store.Currency,
+		},
+		Cart: model.EffectiveCartConfig{
+			ReserveOnAdd:                    c.Cart.ReserveOnAdd,
+			ReservationTTLSeconds:           int64(c.Cart.ReservationTTL.Seconds()),
+			ReservationSweepIntervalSeconds: int64(c.Cart.ReservationSweepInterval.Seconds()),
+		},
+		Product: model.EffectiveProductConfig{
+			LowStockThreshold: c.Product.LowStockThreshold,
+			CacheTTLSeconds:   int64(c.Product.CacheTTL.Seconds()),
+		},
+		Warehouse: model.EffectiveWarehouseConfig{
+			AllocationStrategy: c.Warehouse.AllocationStrategy,
+		},
+	}
+}
+
+// acceptedKeyIDs lists the kids of the accepted secrets, sorted, without the secrets
+func (a AuthConfig) acceptedKeyIDs() []string {
+	ids := make([]string, 0, len(a.JWTAcceptedSecrets))
+	for id := range a.JWTAcceptedSecrets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,184 @@
+package config_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestConfig_EffectiveExcludesSecrets(t *testing.T) {
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{
+			User:         "db-user-x",
+			Password:     "db-password-secret",
+			URL:          "mysql://db-user-x:db-password-secret@db:3306/shop",
+			MaxOpenConns: 25,
+		},
+		Redis:    config.RedisConfig{Password: "redis-password-secret"},
+		Auth:     config.AuthConfig{JWTSecret: "jwt-secret-value", JWTKeyID: "2025-02", JWTAcceptedSecrets: map[string]string{"2025-01": "jwt-old-secret-value"}, JWTExpiration: time.Hour},
+		RabbitMQ: config.RabbitMQConfig{User: "mq-user-x", Password: "mq-password-secret", MaxRedeliveries: 5},
+		Order:    config.OrderConfig{OrderExpiration: 30 * time.Minute, ExpirationStrategy: "rabbitmq"},
+		Cart:     config.CartConfig{ReserveOnAdd: true},
+
+		InternalAPIKey: "internal-api-key-secret",
+	}
+
+	raw, err := json.Marshal(cfg.Effective())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	out := string(raw)
+
+	secrets := []string{
+		"db-password-secret",
+		"db-user-x",
+		"redis-password-secret",
+		"jwt-secret-value",
+		"jwt-old-secret-value",
+		"mq-user-x",
+		"mq-password-secret",
+		"internal-api-key-secret",
+	}
+	for _, s := range secrets {
+		if strings.Contains(out, s) {
+			t.Errorf("effective config leaks %q: %s", s, out)
+		}
+	}
+
+	eff := cfg.Effective()
+	if eff.Auth.JWTKeyID != "2025-02" || len(eff.Auth.JWTAcceptedKeyIDs) != 1 || eff.Auth.JWTAcceptedKeyIDs[0] != "2025-01" {
+		t.Errorf("effective config missing key ids: %s", out)
+	}
+	if eff.Database.MaxOpenConns != 25 || eff.Order.OrderExpirationSeconds != 1800 || !eff.Cart.ReserveOnAdd || eff.RabbitMQ.MaxRedeliveries != 5 {
+		t.Errorf("effective config missing non-secret settings: %s", out)
+	}
+}
+
+func TestAuthConfig_PasswordCost(t *testing.T) {
+	tests := []struct {
+		name string
+		cost int
+		want int
+	}{
+		{"unset", 0, bcrypt.DefaultCost},
+		{"minimum", bcrypt.MinCost, bcrypt.MinCost},
+		{"production", 12, 12},
+		{"maximum", bcrypt.MaxCost, bcrypt.MaxCost},
+		{"below minimum", bcrypt.MinCost - 1, bcrypt.DefaultCost},
+		{"above maximum", bcrypt.MaxCost + 1, bcrypt.DefaultCost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (config.AuthConfig{BcryptCost: tt.cost}).PasswordCost(); got != tt.want {
+				t.Fatalf("PasswordCost() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() *config.Config {
+		return &config.Config{
+			Database:       config.DatabaseConfig{Host: "db", Port: 3306, User: "root", Name: "shop", MaxOpenConns: 10, MaxIdleConns: 5},
+			Auth:           config.AuthConfig{JWTSecret: "secret"},
+			InternalAPIKey: "internal-key",
+			Order:          config.OrderConfig{ExpirationPollInterval: 30 * time.Second, OutboxRelayInterval: 10 * time.Second},
+			Cart:           config.CartConfig{ReservationSweepInterval: time.Minute},
+		}
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *config.Config)
+		want   []string
+	}{
+		{
+			name:   "empty secrets",
+			modify: func(c *config.Config) { c.Auth.JWTSecret = " "; c.InternalAPIKey = "" },
+			want:   []string{"JWT_SECRET", "INTERNAL_API_KEY"},
+		},
+		{
+			name:   "missing database credentials",
+			modify: func(c *config.Config) { c.Database.User = ""; c.Database.Name = ""; c.Database.Port = 0 },
+			want:   []string{"DB_USER", "DB_NAME", "DB_PORT"},
+		},
+		{
+			name:   "non-positive pool sizes",
+			modify: func(c *config.Config) { c.Database.MaxOpenConns = 0; c.Database.MaxIdleConns = -1 },
+			want:   []string{"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS"},
+		},
+		{
+			name:   "expiration poller without an interval",
+			modify: func(c *config.Config) { c.Order.ExpirationPollInterval = 0 },
+			want:   []string{"ORDER_EXPIRATION_POLL_SECONDS"},
+		},
+		{
+			name:   "outbox relay without an interval",
+			modify: func(c *config.Config) { c.Order.OutboxRelayInterval = -10 * time.Second },
+			want:   []string{"ORDER_OUTBOX_RELAY_SECONDS"},
+		},
+		{
+			name:   "reservation sweeper without an interval",
+			modify: func(c *config.Config) { c.Cart.ReservationSweepInterval = 0 },
+			want:   []string{"CART_RESERVATION_SWEEP_SECONDS"},
+		},
+		{
+			name:   "currency with more decimals than amounts store",
+			modify: func(c *config.Config) { c.Store.Currency = "KWD" },
+			want:   []string{"STORE_CURRENCY"},
+		},
+		{
+			name: "request timeout past the write timeout",
+			modify: func(c *config.Config) {
+				c.Server.RequestTimeout = 10 * time.Second
+				c.Server.WriteTimeout = 10 * time.Second
+			},
+			want: []string{"SERVER_REQUEST_TIMEOUT"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if err == nil {
+				t.Fatal("Validate() error = nil, want an error")
+			}
+			// every problem is reported at once, not just the first
+			for _, key := range tt.want {
+				if !strings.Contains(err.Error(), key) {
+					t.Errorf("Validate() error %q doesn't name %s", err, key)
+				}
+			}
+			if strings.Contains(err.Error(), "DB_HOST") {
+				t.Errorf("Validate() error %q names DB_HOST, which is set", err)
+			}
+		})
+	}
+}
+
+func TestLoad_RequiredSettingsHaveNoDefaults(t *testing.T) {
+	required := []string{"JWT_SECRET", "INTERNAL_API_KEY", "DB_HOST", "DB_USER", "DB_NAME"}
+	t.Setenv("CONFIG_FILE", "")
+	for _, key := range required {
+		t.Setenv(key, "")
+	}
+
+	// a deployment that forgets them must not boot with a publicly known value
+	err := config.Load().Validate()
+	if err == nil {
+		t.Fatal("Validate() of a config loaded without the required settings = nil, want an error")
+	}
+	for _, key := range required {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Validate() error %q doesn't name %s", err, key)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile sets the variables of the YAML or JSON file at path that aren't set
+// in the environment already, so the environment always takes precedence. The
+// file is a flat mapping of the same keys the environment uses:
+//
+//	DB_HOST: mysql-ecommerce
+//	CORS_ALLOWED_ORIGINS: [https://shop.example.com]
+//	JWT_ACCEPTED_SECRETS: {"2025-01": old-secret}
+//
+// Lists are read like comma separated values and mappings like id:secret pairs.
+func loadFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &values)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		// keep large integers as written instead of going through float64
+		dec.UseNumber()
+		err = dec.Decode(&values)
+	default:
+		return fmt.Errorf("unsupported config file extension %q, expected .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	for key, v := range values {
+		if _, set := os.LookupEnv(key); set || v == nil {
+			continue
+		}
+		value, err := fileValue(v)
+		if err != nil {
+			return fmt.Errorf("%s in %s: %w", key, path, err)
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileValue renders a file value the way the variable would be written in the environment
+func fileValue(v any) (string, error) {
+	switch t := v.(type) {
+	case []any:
+		parts := make([]string, 0, len(t))
+		for _, item := range t {
+			part, err := scalarValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]any:
+		ids := make([]string, 0, len(t))
+		for id := range t {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		pairs := make([]string, 0, len(t))
+		for _, id := range ids {
+			value, err := scalarValue(t[id])
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, id+":"+value)
+		}
+		return strings.Join(pairs, ","), nil
+	default:
+		return scalarValue(v)
+	}
+}
+
+func scalarValue(v any) (string, error) {
+	switch v.(type) {
+	case string, bool, int, int64, uint64, float64, json.Number:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unsetEnv clears keys a config file is about to set and restores their absence afterwards
+func unsetEnv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+DB_HOST: file-db
+DB_PORT: 3307
+JWT_EXPIRATION: 600
+COMPRESSION_ENABLED: false
+STORE_TAX_RATE: 0.11
+CORS_ALLOWED_ORIGINS: [https://a.example, https://b.example]
+JWT_ACCEPTED_SECRETS: {"2025-01": old-secret}
+`,
+		},
+		{
+			name: "json",
+			file: "config.json",
+			content: `{
+	"DB_HOST": "file-db",
+	"DB_PORT": 3307,
+	"JWT_EXPIRATION": 600,
+	"COMPRESSION_ENABLED": false,
+	"STORE_TAX_RATE": 0.11,
+	"CORS_ALLOWED_ORIGINS": ["https://a.example", "https://b.example"],
+	"JWT_ACCEPTED_SECRETS": {"2025-01": "old-secret"}
+}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "DB_PORT", "JWT_EXPIRATION", "COMPRESSION_ENABLED", "STORE_TAX_RATE", "CORS_ALLOWED_ORIGINS", "JWT_ACCEPTED_SECRETS")
+			// the environment wins over the file
+			t.Setenv("DB_HOST", "env-db")
+
+			if err := loadFile(writeConfigFile(t, tt.file, tt.content)); err != nil {
+				t.Fatalf("loadFile() error = %v", err)
+			}
+			cfg := fromEnv()
+
+			if cfg.Database.Host != "env-db" {
+				t.Errorf("Database.Host = %q, want the environment's env-db", cfg.Database.Host)
+			}
+			if cfg.Database.Port != 3307 {
+				t.Errorf("Database.Port = %d, want 3307", cfg.Database.Port)
+			}
+			if cfg.Auth.JWTExpiration != 10*time.Minute {
+				t.Errorf("Auth.JWTExpiration = %v, want 10m", cfg.Auth.JWTExpiration)
+			}
+			if cfg.Server.Compression.Enabled {
+				t.Error("Server.Compression.Enabled = true, want false")
+			}
+			if cfg.Store.TaxRate != 0.11 {
+				t.Errorf("Store.TaxRate = %v, want 0.11", cfg.Store.TaxRate)
+			}
+			if got := strings.Join(cfg.Server.CORS.AllowedOrigins, " "); got != "https://a.example https://b.example" {
+				t.Errorf("Server.CORS.AllowedOrigins = %q", got)
+			}
+			if got := cfg.Auth.JWTAcceptedSecrets["2025-01"]; got != "old-secret" {
+				t.Errorf("Auth.JWTAcceptedSecrets[2025-01] = %q, want old-secret", got)
+			}
+		})
+	}
+}
+
+func TestLoadFile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"unsupported extension", "config.toml", `DB_HOST = "db"`},
+		{"malformed yaml", "config.yaml", "DB_HOST: [unterminated"},
+		{"nested value", "config.yaml", "DB_HOST:\n  primary: [a, b]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "DB_HOST")
+			if err := loadFile(writeConfigFile(t, tt.file, tt.content)); err == nil {
+				t.Fatal("loadFile() error = nil, want an error")
+			}
+		})
+	}
+
+	if err := loadFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("loadFile() of a missing file error = nil, want an error")
+	}
+}
@@ -2,32 +2,44 @@ package main
 
 import (
 	"context"
+	"errors"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	cartapp "github.com/muhammadheryan/e-commerce/application/cart"
 	orderapp "github.com/muhammadheryan/e-commerce/application/order"
 	productapp "github.com/muhammadheryan/e-commerce/application/product"
 	userapp "github.com/muhammadheryan/e-commerce/application/user"
 	warehouseapp "github.com/muhammadheryan/e-commerce/application/warehouse"
+	wishlistapp "github.com/muhammadheryan/e-commerce/application/wishlist"
 	"github.com/muhammadheryan/e-commerce/cmd/config"
 	redisclient "github.com/muhammadheryan/e-commerce/cmd/redis"
+	"github.com/muhammadheryan/e-commerce/constant"
 	_ "github.com/muhammadheryan/e-commerce/docs"
+	cartRepo "github.com/muhammadheryan/e-commerce/repository/cart"
 	orderRepo "github.com/muhammadheryan/e-commerce/repository/order"
 	productRepo "github.com/muhammadheryan/e-commerce/repository/product"
 	redisRepo "github.com/muhammadheryan/e-commerce/repository/redis"
 	txRepo "github.com/muhammadheryan/e-commerce/repository/tx"
 	userRepo "github.com/muhammadheryan/e-commerce/repository/user"
 	warehouse "github.com/muhammadheryan/e-commerce/repository/warehouse"
+	wishlistRepo "github.com/muhammadheryan/e-commerce/repository/wishlist"
 	"github.com/muhammadheryan/e-commerce/thirdparty/rabbitmq"
 	"github.com/muhammadheryan/e-commerce/transport"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
 	"github.com/muhammadheryan/e-commerce/utils/logger"
 	"go.uber.org/zap"
 )
 
+// consumerDrainTimeout bounds how long shutdown waits for an in-flight expiration message
+const consumerDrainTimeout = 15 * time.Second
+
 // @title E-COMMERCE API
 // @version 1.0
 // @description E-COMMERCE API Documentation
@@ -55,23 +67,37 @@ func main() {
 	}
 	defer logger.Close()
 
+	if err := cfg.Validate(); err != nil {
+		logger.Fatal("invalid config", zap.Error(err))
+	}
+
 	logger.Info(cfg.ProjectName)
 	logger.Info("Starting server", zap.String("env", cfg.Environment))
 
+	// dependencies started alongside this container may not be ready yet
+	retries, backoff := cfg.Database.ConnectRetries, cfg.Database.ConnectBackoff
+
 	// Connect to database
-	db, err := sqlx.Connect("mysql", cfg.GetDSN())
+	var db *sqlx.DB
+	err := connectWithRetry("mysql", retries, backoff, func() (err error) {
+		db, err = sqlx.Connect("mysql", cfg.GetDSN())
+		return err
+	})
 	if err != nil {
 		logger.Fatal("err connect db", zap.Error(err))
 	}
 
 	// Initialize Redis client
-	if err := redisclient.New(cfg); err != nil {
+	if err := connectWithRetry("redis", retries, backoff, func() error { return redisclient.New(cfg) }); err != nil {
 		logger.Fatal("err connect redis", zap.Error(err))
 	}
 	defer func() {
 		_ = redisclient.Close()
 	}()
 
+	// bound every repository query, handlers pass request contexts without a deadline
+	utilsContext.SetQueryTimeout(cfg.Database.QueryTimeout)
+
 	// Set database connection pool settings
 	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
 	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
@@ -84,52 +110,125 @@ func main() {
 	OrderRepo := orderRepo.NewOrderRepository(db)
 	txRepo := txRepo.NewTxRepository(db)
 	warehouseRepo := warehouse.NewWarehouseRepository(db)
+	WishlistRepo := wishlistRepo.NewWishlistRepository(db)
+	CartRepo := cartRepo.NewCartRepository(db)
 
-	// Initialize RabbitMQ publisher
-	publisher, err := rabbitmq.NewPublisher(
-		cfg.RabbitMQ.Host,
-		cfg.RabbitMQ.Port,
-		cfg.RabbitMQ.User,
-		cfg.RabbitMQ.Password,
-	)
-	if err != nil {
-		logger.Fatal("failed to connect rabbitmq publisher", zap.Error(err))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// closed once the HTTP listener is bound, the consumer calls back into this server
+	httpReady := make(chan struct{})
+
+	// RabbitMQ is only needed when order expiration goes through the broker
+	var publisher *rabbitmq.Publisher
+	var consumer *rabbitmq.Consumer
+	var deadLetterMonitor *rabbitmq.DeadLetterMonitor
+	usePoller := cfg.Order.ExpirationStrategy == constant.OrderExpirationStrategyPoller
+	if !usePoller {
+		// Initialize RabbitMQ publisher
+		err = connectWithRetry("rabbitmq", retries, backoff, func() (err error) {
+			publisher, err = rabbitmq.NewPublisher(
+				cfg.RabbitMQ.Host,
+				cfg.RabbitMQ.Port,
+				cfg.RabbitMQ.User,
+				cfg.RabbitMQ.Password,
+			)
+			return err
+		})
+		if err != nil {
+			logger.Fatal("failed to connect rabbitmq publisher", zap.Error(err))
+		}
+		defer func() {
+			_ = publisher.Close()
+		}()
+
+		// Initialize RabbitMQ consumer
+		consumer, err = rabbitmq.NewConsumer(
+			cfg.RabbitMQ.Host,
+			cfg.RabbitMQ.Port,
+			cfg.RabbitMQ.User,
+			cfg.RabbitMQ.Password,
+			"http://localhost:"+cfg.Server.Port,
+			cfg.InternalAPIKey,
+			cfg.RabbitMQ.MaxRedeliveries,
+			cfg.RabbitMQ.ConsumerWorkers,
+			cfg.RabbitMQ.MaxConcurrentCancels,
+		)
+		if err != nil {
+			logger.Fatal("failed to connect rabbitmq consumer", zap.Error(err))
+		}
+
+		// Start consumer in background, it handles messages once the API is listening
+		if err := consumer.Start(ctx, httpReady); err != nil {
+			logger.Fatal("failed to start rabbitmq consumer", zap.Error(err))
+		}
+
+		if cfg.RabbitMQ.DeadLetterAlert {
+			deadLetterMonitor, err = rabbitmq.NewDeadLetterMonitor(
+				cfg.RabbitMQ.Host,
+				cfg.RabbitMQ.Port,
+				cfg.RabbitMQ.User,
+				cfg.RabbitMQ.Password,
+			)
+			if err != nil {
+				logger.Fatal("failed to connect rabbitmq dead-letter monitor", zap.Error(err))
+			}
+			if err := deadLetterMonitor.Start(ctx); err != nil {
+				logger.Fatal("failed to start rabbitmq dead-letter monitor", zap.Error(err))
+			}
+		}
 	}
-	defer func() {
-		_ = publisher.Close()
-	}()
 
-	// Initialize RabbitMQ consumer
-	consumer, err := rabbitmq.NewConsumer(
-		cfg.RabbitMQ.Host,
-		cfg.RabbitMQ.Port,
-		cfg.RabbitMQ.User,
-		cfg.RabbitMQ.Password,
-		"http://localhost:"+cfg.Server.Port,
-		cfg.InternalAPIKey,
-	)
-	if err != nil {
-		logger.Fatal("failed to connect rabbitmq consumer", zap.Error(err))
+	// Initialize application layers
+	UserApp := userapp.NewUserApp(cfg, txRepo, UserRepo, RedisRepo)
+	ProductApp := productapp.NewProductApp(cfg, ProductRepo, RedisRepo)
+	OrderApp := orderapp.NewOrderApp(cfg, txRepo, OrderRepo, warehouseRepo, CartRepo, RedisRepo, publisher)
+	// back-in-stock notifications go through the broker, without it subscriptions wait
+	var backInStock warehouseapp.BackInStockPublisher
+	if publisher != nil {
+		backInStock = publisher
 	}
-	defer func() {
-		_ = consumer.Close()
-	}()
+	WarehouseApp := warehouseapp.NewWarehouseApp(txRepo, warehouseRepo, ProductRepo, backInStock, UserApp)
+	WishlistApp := wishlistapp.NewWishlistApp(WishlistRepo)
+	CartApp := cartapp.NewCartApp(cfg, txRepo, CartRepo, warehouseRepo)
 
-	// Start consumer in background
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// both can also be run on demand through the internal sweep endpoint
+	orderExpirer := orderapp.NewOrderExpirer(OrderApp, OrderRepo, cfg.Order.ExpirationPollInterval)
+	reservationSweeper := cartapp.NewReservationSweeper(txRepo, warehouseRepo, cfg.Cart.ReservationSweepInterval)
 
-	if err := consumer.Start(ctx); err != nil {
-		logger.Fatal("failed to start rabbitmq consumer", zap.Error(err))
+	// Start in-process order expiration when running without the broker
+	if usePoller {
+		logger.Info("Order expiration using poller", zap.Duration("interval", cfg.Order.ExpirationPollInterval))
+		orderExpirer.Start(ctx)
+	} else {
+		// retry expiration messages that could not be published when the order was created
+		orderapp.NewOutboxRelay(OrderRepo, publisher, cfg.Order.OutboxRelayInterval).Start(ctx)
 	}
 
-	// Initialize application layers
-	UserApp := userapp.NewUserApp(cfg, UserRepo, RedisRepo)
-	ProductApp := productapp.NewProductApp(ProductRepo)
-	OrderApp := orderapp.NewOrderApp(cfg, txRepo, OrderRepo, warehouseRepo, publisher)
-	WarehouseApp := warehouseapp.NewWarehouseApp(txRepo, warehouseRepo)
+	if cfg.Cart.ReserveOnAdd {
+		logger.Info("Cart reserve-on-add enabled", zap.Duration("ttl", cfg.Cart.ReservationTTL))
+		reservationSweeper.Start(ctx)
+	}
+
+	healthChecks := transport.HealthChecks{
+		"mysql": db.PingContext,
+		"redis": func(ctx context.Context) error {
+			client := redisclient.Get()
+			if client == nil {
+				return errors.New("redis client not initialized")
+			}
+			return client.Ping(ctx).Err()
+		},
+	}
 
-	httpTransport := transport.NewTransport(UserApp, ProductApp, OrderApp, WarehouseApp, cfg.InternalAPIKey)
+	var consumerStatus transport.ConsumerStatus
+	if consumer != nil {
+		consumerStatus = consumer.Status
+	}
+	httpTransport := transport.NewTransport(UserApp, ProductApp, OrderApp, WarehouseApp, WishlistApp, CartApp, cfg, healthChecks, transport.Sweeps{
+		ReleaseExpiredReservations: reservationSweeper.Sweep,
+		ExpireOrders:               orderExpirer.Sweep,
+	}, consumerStatus)
 
 	// Create HTTP server
 	server := &http.Server{
@@ -141,20 +240,54 @@ func main() {
 	}
 
 	// Graceful shutdown handling
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
-		logger.Info("Shutting down server...")
+		logger.Info("Shutting down server...", zap.Int64("in_flight_requests", transport.InFlightRequests()))
+
+		// stop accepting and let in-flight requests complete
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Server shutdown timed out, closing remaining connections", zap.Error(err), zap.Int64("in_flight_requests", transport.InFlightRequests()))
+			_ = server.Close()
+		}
+		shutdownCancel()
+
+		// background workers stop once no request can create orders anymore. A
+		// cancel the consumer sends from here on fails and its message goes to
+		// the retry queue for the next instance.
 		cancel()
-		if err := server.Close(); err != nil {
-			logger.Error("Server close error", zap.Error(err))
+		if consumer != nil {
+			drainCtx, drainCancel := context.WithTimeout(context.Background(), consumerDrainTimeout)
+			if err := consumer.Shutdown(drainCtx); err != nil {
+				logger.Error("Consumer drain error", zap.Error(err))
+			}
+			drainCancel()
+		}
+		if deadLetterMonitor != nil {
+			drainCtx, drainCancel := context.WithTimeout(context.Background(), consumerDrainTimeout)
+			if err := deadLetterMonitor.Shutdown(drainCtx); err != nil {
+				logger.Error("Dead-letter monitor shutdown error", zap.Error(err))
+			}
+			drainCancel()
 		}
 	}()
 
+	// bind before signaling ready, connections made from here on queue until Serve accepts them
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		logger.Fatal("failed to listen", zap.Error(err))
+	}
+	close(httpReady)
+
 	logger.Info("HTTP server running", zap.String("port", cfg.Server.Port))
-	err = server.ListenAndServe()
+	err = server.Serve(listener)
 	if err != nil && err != http.ErrServerClosed {
 		logger.Fatal("failed server", zap.Error(err))
 	}
+	// Serve returns as soon as shutdown starts, wait for the drain to finish
+	<-shutdownDone
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"time"
+
+	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"go.uber.org/zap"
+)
+
+// maxConnectBackoff caps the wait between two connection attempts
+const maxConnectBackoff = 30 * time.Second
+
+// connectWithRetry calls connect until it succeeds, retrying up to retries
+// times with a backoff that doubles after each failure. connect must confirm
+// the dependency answers (a ping), not only that it could be dialed, so a
+// container started before its dependencies waits for them instead of crashing.
+func connectWithRetry(name string, retries int, backoff time.Duration, connect func() error) error {
+	err := connect()
+	for attempt := 1; err != nil && attempt <= retries; attempt++ {
+		logger.Warn("connect failed, retrying",
+			zap.String("dependency", name),
+			zap.Int("attempt", attempt),
+			zap.Int("retries", retries),
+			zap.Duration("backoff", backoff),
+			zap.String("error", err.Error()),
+		)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+		err = connect()
+	}
+	return err
+}
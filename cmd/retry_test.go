@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnectWithRetry(t *testing.T) {
+	errDown := errors.New("connection refused")
+	tests := []struct {
+		name      string
+		retries   int
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "first attempt succeeds", retries: 3, failures: 0, wantCalls: 1},
+		{name: "succeeds after retries", retries: 3, failures: 2, wantCalls: 3},
+		{name: "gives up after the last retry", retries: 3, failures: 10, wantCalls: 4, wantErr: true},
+		{name: "no retries", retries: 0, failures: 1, wantCalls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := connectWithRetry("test", tt.retries, time.Millisecond, func() error {
+				calls++
+				if calls <= tt.failures {
+					return errDown
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("connectWithRetry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Fatalf("connect called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
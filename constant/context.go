@@ -3,3 +3,9 @@ package constant
 type ctxKey string
 
 const UserIDKey ctxKey = "userID"
+
+// RequestIDKey holds the correlation id of the current request
+const RequestIDKey ctxKey = "requestID"
+
+// RequestIDHeader carries the correlation id between services and back to clients
+const RequestIDHeader = "X-Request-ID"
@@ -15,6 +15,19 @@ const (
 	ErrInsufficientStock
 	ErrInvalidOrderStatus
 	ErrWarehouseHasReservedStock
+	ErrVoucherExhausted
+	ErrOrderValueTooHigh
+	ErrReviewNotAllowed
+	ErrForbidden
+	ErrMethodNotAllowed
+	ErrServiceUnavailable
+	ErrTooManyRequests
+	ErrWarehouseInactive
+	ErrEmailNotVerified
+	ErrInvalidVerificationToken
+	ErrRequestTimeout
+	ErrEmailAlreadyVerified
+	ErrReviewExists
 )
 
 var ErrorTypeMessage = map[ErrorType]string{
@@ -28,6 +41,19 @@ var ErrorTypeMessage = map[ErrorType]string{
 	ErrInsufficientStock:         "insufficient stock",
 	ErrInvalidOrderStatus:        "invalid order status",
 	ErrWarehouseHasReservedStock: "warehouse has reserved stock, cannot deactivate",
+	ErrVoucherExhausted:          "voucher is invalid or has been fully redeemed",
+	ErrOrderValueTooHigh:         "order value exceeds the allowed maximum",
+	ErrReviewNotAllowed:          "only buyers with a completed order can review this product",
+	ErrForbidden:                 "forbidden",
+	ErrMethodNotAllowed:          "method not allowed",
+	ErrServiceUnavailable:        "service unavailable",
+	ErrTooManyRequests:           "too many requests",
+	ErrWarehouseInactive:         "warehouse is inactive",
+	ErrEmailNotVerified:          "email is not verified",
+	ErrInvalidVerificationToken:  "verification token is invalid or expired",
+	ErrRequestTimeout:            "request timed out",
+	ErrEmailAlreadyVerified:      "email is already verified",
+	ErrReviewExists:              "product has already been reviewed by this user",
 }
 
 var ErrorTypeHTTPCode = map[ErrorType]int{
@@ -41,6 +67,19 @@ var ErrorTypeHTTPCode = map[ErrorType]int{
 	ErrInsufficientStock:         http.StatusBadRequest,
 	ErrInvalidOrderStatus:        http.StatusBadRequest,
 	ErrWarehouseHasReservedStock: http.StatusBadRequest,
+	ErrVoucherExhausted:          http.StatusBadRequest,
+	ErrOrderValueTooHigh:         http.StatusBadRequest,
+	ErrReviewNotAllowed:          http.StatusForbidden,
+	ErrForbidden:                 http.StatusForbidden,
+	ErrMethodNotAllowed:          http.StatusMethodNotAllowed,
+	ErrServiceUnavailable:        http.StatusServiceUnavailable,
+	ErrTooManyRequests:           http.StatusTooManyRequests,
+	ErrWarehouseInactive:         http.StatusBadRequest,
+	ErrEmailNotVerified:          http.StatusForbidden,
+	ErrInvalidVerificationToken:  http.StatusBadRequest,
+	ErrRequestTimeout:            http.StatusGatewayTimeout,
+	ErrEmailAlreadyVerified:      http.StatusBadRequest,
+	ErrReviewExists:              http.StatusBadRequest,
 }
 
 var ErrorTypeCode = map[ErrorType]string{
@@ -54,4 +93,17 @@ var ErrorTypeCode = map[ErrorType]string{
 	ErrInsufficientStock:         "0007",
 	ErrInvalidOrderStatus:        "0008",
 	ErrWarehouseHasReservedStock: "0009",
+	ErrVoucherExhausted:          "0010",
+	ErrOrderValueTooHigh:         "0011",
+	ErrReviewNotAllowed:          "0012",
+	ErrForbidden:                 "0013",
+	ErrMethodNotAllowed:          "0014",
+	ErrServiceUnavailable:        "0015",
+	ErrTooManyRequests:           "0016",
+	ErrWarehouseInactive:         "0017",
+	ErrEmailNotVerified:          "0018",
+	ErrInvalidVerificationToken:  "0019",
+	ErrRequestTimeout:            "0020",
+	ErrEmailAlreadyVerified:      "0021",
+	ErrReviewExists:              "0022",
 }
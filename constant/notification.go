@@ -0,0 +1,10 @@
+package constant
+
+// NotificationType is a category of user-facing notification a user can opt in or out of
+type NotificationType string
+
+const (
+	NotificationOrderUpdates     NotificationType = "order_updates"
+	NotificationMarketing        NotificationType = "marketing"
+	NotificationWishlistLowStock NotificationType = "wishlist_low_stock"
+)
@@ -6,4 +6,37 @@ const (
 	OrderStatusPending   OrderStatus = 1
 	OrderStatusCompleted OrderStatus = 2
 	OrderStatusCanceled  OrderStatus = 3
+	// OrderStatusPendingReview holds orders above the max order value for manual review
+	OrderStatusPendingReview OrderStatus = 5
+	// OrderStatusRefunded is a paid order whose stock went back to the warehouses
+	OrderStatusRefunded OrderStatus = 6
+)
+
+// orderStatusTransitions lists, per status, the statuses an order may move to.
+// Canceled and refunded are final, completed orders can only be refunded. Orders held for review are approved back
+// to pending or canceled, they keep their reservations until then.
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:       {OrderStatusCompleted, OrderStatusCanceled},
+	OrderStatusCompleted:     {OrderStatusRefunded},
+	OrderStatusPendingReview: {OrderStatusPending, OrderStatusCanceled},
+}
+
+// CanTransition reports whether an order in status from may be moved to status to
+func CanTransition(from, to OrderStatus) bool {
+	for _, next := range orderStatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	OrderHighValueActionReject = "reject"
+	OrderHighValueActionReview = "review"
+)
+
+const (
+	OrderExpirationStrategyRabbitMQ = "rabbitmq"
+	OrderExpirationStrategyPoller   = "poller"
 )
@@ -0,0 +1,42 @@
+package constant_test
+
+import (
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		name string
+		from constant.OrderStatus
+		to   constant.OrderStatus
+		want bool
+	}{
+		{"pending to completed", constant.OrderStatusPending, constant.OrderStatusCompleted, true},
+		{"pending to canceled", constant.OrderStatusPending, constant.OrderStatusCanceled, true},
+		{"review approved", constant.OrderStatusPendingReview, constant.OrderStatusPending, true},
+		{"review rejected", constant.OrderStatusPendingReview, constant.OrderStatusCanceled, true},
+		{"completed refunded", constant.OrderStatusCompleted, constant.OrderStatusRefunded, true},
+
+		{"pending to pending", constant.OrderStatusPending, constant.OrderStatusPending, false},
+		{"pending to review", constant.OrderStatusPending, constant.OrderStatusPendingReview, false},
+		{"review paid directly", constant.OrderStatusPendingReview, constant.OrderStatusCompleted, false},
+		{"completed to canceled", constant.OrderStatusCompleted, constant.OrderStatusCanceled, false},
+		{"completed to pending", constant.OrderStatusCompleted, constant.OrderStatusPending, false},
+		{"canceled to completed", constant.OrderStatusCanceled, constant.OrderStatusCompleted, false},
+		{"canceled to pending", constant.OrderStatusCanceled, constant.OrderStatusPending, false},
+		{"pending refunded", constant.OrderStatusPending, constant.OrderStatusRefunded, false},
+		{"canceled refunded", constant.OrderStatusCanceled, constant.OrderStatusRefunded, false},
+		{"refunded twice", constant.OrderStatusRefunded, constant.OrderStatusRefunded, false},
+		{"refunded to completed", constant.OrderStatusRefunded, constant.OrderStatusCompleted, false},
+		{"unknown status", constant.OrderStatus(99), constant.OrderStatusCanceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := constant.CanTransition(tt.from, tt.to); got != tt.want {
+				t.Fatalf("CanTransition(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package constant
+
+// ProductSort is the sort order accepted by the product list
+type ProductSort string
+
+const (
+	ProductSortDefault   ProductSort = ""
+	ProductSortPriceAsc  ProductSort = "price_asc"
+	ProductSortPriceDesc ProductSort = "price_desc"
+	ProductSortNameAsc   ProductSort = "name_asc"
+	ProductSortNewest    ProductSort = "newest"
+)
+
+// Valid reports whether s is one of the supported sort orders
+func (s ProductSort) Valid() bool {
+	switch s {
+	case ProductSortDefault, ProductSortPriceAsc, ProductSortPriceDesc, ProductSortNameAsc, ProductSortNewest:
+		return true
+	}
+	return false
+}
+
+// ProductAvailability is the stock status shown to shoppers, derived from the available stock
+type ProductAvailability string
+
+const (
+	ProductAvailabilityInStock    ProductAvailability = "in_stock"
+	ProductAvailabilityLowStock   ProductAvailability = "low_stock"
+	ProductAvailabilityOutOfStock ProductAvailability = "out_of_stock"
+)
@@ -6,3 +6,11 @@ const (
 	WarehouseStatusInactive WarehouseStatus = 0
 	WarehouseStatusActive   WarehouseStatus = 1
 )
+
+// Stock allocation strategies, the order warehouses are drawn from when reserving
+const (
+	// StockAllocationPriority takes from the highest priority warehouse first, most available breaks ties
+	StockAllocationPriority = "priority"
+	// StockAllocationAvailable takes from the warehouse with the most available stock first, priority breaks ties
+	StockAllocationAvailable = "available"
+)
@@ -0,0 +1,13 @@
+-- migrate:up
+CREATE TABLE `voucher` (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE,
+    max_uses INT NOT NULL DEFAULT 0,
+    used INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+
+-- migrate:down
+DROP TABLE IF EXISTS `voucher`;
@@ -0,0 +1,12 @@
+-- migrate:up
+ALTER TABLE `order`
+    ADD COLUMN subtotal DECIMAL(12,2) NOT NULL DEFAULT 0 AFTER status,
+    ADD COLUMN tax_amount DECIMAL(12,2) NOT NULL DEFAULT 0 AFTER subtotal,
+    ADD COLUMN grand_total DECIMAL(12,2) NOT NULL DEFAULT 0 AFTER tax_amount;
+
+
+-- migrate:down
+ALTER TABLE `order`
+    DROP COLUMN subtotal,
+    DROP COLUMN tax_amount,
+    DROP COLUMN grand_total;
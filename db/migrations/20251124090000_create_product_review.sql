@@ -0,0 +1,16 @@
+-- migrate:up
+CREATE TABLE `product_review` (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    product_id BIGINT NOT NULL,
+    rating TINYINT NOT NULL COMMENT '1 - 5',
+    comment TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_product_review_product ON product_review(product_id);
+
+
+-- migrate:down
+DROP TABLE IF EXISTS `product_review`;
@@ -0,0 +1,12 @@
+-- migrate:up
+CREATE TABLE `wishlist` (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    product_id BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_wishlist_user_product (user_id, product_id)
+);
+
+
+-- migrate:down
+DROP TABLE IF EXISTS `wishlist`;
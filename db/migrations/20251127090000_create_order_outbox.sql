@@ -0,0 +1,15 @@
+-- migrate:up
+CREATE TABLE `order_outbox` (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    expires_at TIMESTAMP NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    sent_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_order_outbox_sent_at (sent_at)
+);
+
+
+-- migrate:down
+DROP TABLE IF EXISTS `order_outbox`;
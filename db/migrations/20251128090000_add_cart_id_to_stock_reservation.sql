@@ -0,0 +1,14 @@
+-- migrate:up
+ALTER TABLE `stock_reservation`
+    MODIFY order_id BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN cart_id BIGINT NULL AFTER order_id,
+    ADD INDEX idx_stock_reservation_cart (cart_id),
+    ADD INDEX idx_stock_reservation_expires_at (expires_at);
+
+
+-- migrate:down
+ALTER TABLE `stock_reservation`
+    DROP INDEX idx_stock_reservation_expires_at,
+    DROP INDEX idx_stock_reservation_cart,
+    DROP COLUMN cart_id,
+    MODIFY order_id BIGINT NOT NULL;
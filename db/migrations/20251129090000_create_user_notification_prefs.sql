@@ -0,0 +1,13 @@
+-- migrate:up
+CREATE TABLE `user_notification_prefs` (
+    user_id BIGINT PRIMARY KEY,
+    order_updates TINYINT(1) NOT NULL DEFAULT 1,
+    marketing TINYINT(1) NOT NULL DEFAULT 0,
+    wishlist_low_stock TINYINT(1) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL ON UPDATE CURRENT_TIMESTAMP
+);
+
+
+-- migrate:down
+DROP TABLE IF EXISTS `user_notification_prefs`;
@@ -0,0 +1,8 @@
+-- migrate:up
+ALTER TABLE `product`
+    ADD COLUMN image_url VARCHAR(500) NULL AFTER price;
+
+
+-- migrate:down
+ALTER TABLE `product`
+    DROP COLUMN image_url;
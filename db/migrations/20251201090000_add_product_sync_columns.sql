@@ -0,0 +1,14 @@
+-- migrate:up
+UPDATE `product` SET updated_at = created_at WHERE updated_at IS NULL;
+
+ALTER TABLE `product`
+    MODIFY COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    ADD COLUMN deleted_at TIMESTAMP NULL AFTER updated_at,
+    ADD INDEX idx_product_updated_at_id (updated_at, id);
+
+
+-- migrate:down
+ALTER TABLE `product`
+    DROP INDEX idx_product_updated_at_id,
+    DROP COLUMN deleted_at,
+    MODIFY COLUMN updated_at TIMESTAMP NULL ON UPDATE CURRENT_TIMESTAMP;
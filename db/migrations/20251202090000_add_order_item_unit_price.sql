@@ -0,0 +1,13 @@
+-- migrate:up
+ALTER TABLE `order_item`
+    ADD COLUMN unit_price DECIMAL(12,2) NOT NULL DEFAULT 0 AFTER quantity;
+
+-- backfill existing items with the current price, the best guess left for them
+UPDATE `order_item` oi
+    JOIN product p ON p.id = oi.product_id
+SET oi.unit_price = p.price;
+
+
+-- migrate:down
+ALTER TABLE `order_item`
+    DROP COLUMN unit_price;
@@ -0,0 +1,31 @@
+-- migrate:up
+-- rows never updated have a NULL updated_at, start them at created_at
+UPDATE `user` SET updated_at = created_at WHERE updated_at IS NULL;
+UPDATE `shop` SET updated_at = created_at WHERE updated_at IS NULL;
+UPDATE `warehouse` SET updated_at = created_at WHERE updated_at IS NULL;
+UPDATE `warehouse_stock` SET updated_at = created_at WHERE updated_at IS NULL;
+UPDATE `order` SET updated_at = created_at WHERE updated_at IS NULL;
+
+ALTER TABLE `user`
+    MODIFY COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP;
+ALTER TABLE `shop`
+    MODIFY COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP;
+ALTER TABLE `warehouse`
+    MODIFY COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP;
+ALTER TABLE `warehouse_stock`
+    MODIFY COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP;
+ALTER TABLE `order`
+    MODIFY COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP;
+
+
+-- migrate:down
+ALTER TABLE `user`
+    MODIFY COLUMN updated_at TIMESTAMP NULL ON UPDATE CURRENT_TIMESTAMP;
+ALTER TABLE `shop`
+    MODIFY COLUMN updated_at TIMESTAMP NULL ON UPDATE CURRENT_TIMESTAMP;
+ALTER TABLE `warehouse`
+    MODIFY COLUMN updated_at TIMESTAMP NULL ON UPDATE CURRENT_TIMESTAMP;
+ALTER TABLE `warehouse_stock`
+    MODIFY COLUMN updated_at TIMESTAMP NULL ON UPDATE CURRENT_TIMESTAMP;
+ALTER TABLE `order`
+    MODIFY COLUMN updated_at TIMESTAMP NULL ON UPDATE CURRENT_TIMESTAMP;
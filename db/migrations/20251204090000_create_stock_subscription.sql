@@ -0,0 +1,13 @@
+-- migrate:up
+CREATE TABLE `stock_subscription` (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    product_id BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_stock_subscription_user_product (user_id, product_id),
+    INDEX idx_stock_subscription_product_id (product_id)
+);
+
+
+-- migrate:down
+DROP TABLE IF EXISTS `stock_subscription`;
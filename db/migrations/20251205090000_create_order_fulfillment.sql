@@ -0,0 +1,14 @@
+-- migrate:up
+CREATE TABLE `order_fulfillment` (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    order_id BIGINT NOT NULL,
+    warehouse_id BIGINT NOT NULL,
+    product_id BIGINT NOT NULL,
+    quantity INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_order_fulfillment_order_id (order_id)
+);
+
+
+-- migrate:down
+DROP TABLE IF EXISTS `order_fulfillment`;
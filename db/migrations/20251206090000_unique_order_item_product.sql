@@ -0,0 +1,27 @@
+-- migrate:up
+-- orders created before duplicate products were rejected may list a product twice,
+-- fold those rows into the first one so the unique key can be added
+UPDATE `order_item` oi
+JOIN (
+    SELECT MIN(id) AS keep_id, SUM(quantity) AS quantity
+    FROM `order_item`
+    GROUP BY order_id, product_id
+    HAVING COUNT(*) > 1
+) d ON oi.id = d.keep_id
+SET oi.quantity = d.quantity;
+
+DELETE oi FROM `order_item` oi
+JOIN (
+    SELECT order_id, product_id, MIN(id) AS keep_id
+    FROM `order_item`
+    GROUP BY order_id, product_id
+    HAVING COUNT(*) > 1
+) d ON oi.order_id = d.order_id AND oi.product_id = d.product_id AND oi.id <> d.keep_id;
+
+ALTER TABLE `order_item`
+    ADD UNIQUE KEY uq_order_item_order_product (order_id, product_id);
+
+
+-- migrate:down
+ALTER TABLE `order_item`
+    DROP INDEX uq_order_item_order_product;
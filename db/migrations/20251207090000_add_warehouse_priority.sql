@@ -0,0 +1,9 @@
+-- migrate:up
+-- reservations draw from higher priority warehouses first, existing ones start equal
+ALTER TABLE `warehouse`
+    ADD COLUMN priority INT NOT NULL DEFAULT 0 AFTER status;
+
+
+-- migrate:down
+ALTER TABLE `warehouse`
+    DROP COLUMN priority;
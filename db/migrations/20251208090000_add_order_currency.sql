@@ -0,0 +1,9 @@
+-- migrate:up
+-- existing orders were all placed in the default store currency
+ALTER TABLE `order`
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR' AFTER grand_total;
+
+
+-- migrate:down
+ALTER TABLE `order`
+    DROP COLUMN currency;
@@ -0,0 +1,11 @@
+-- migrate:up
+ALTER TABLE `user`
+    ADD COLUMN is_verified TINYINT(1) NOT NULL DEFAULT 0 AFTER password_hash;
+
+-- users registered before verification existed keep ordering as they did
+UPDATE `user` SET is_verified = 1;
+
+
+-- migrate:down
+ALTER TABLE `user`
+    DROP COLUMN is_verified;
@@ -0,0 +1,9 @@
+-- migrate:up
+-- set once a refund put the quantity back, so it can't be restocked twice
+ALTER TABLE `order_fulfillment`
+    ADD COLUMN restocked_at TIMESTAMP NULL DEFAULT NULL;
+
+
+-- migrate:down
+ALTER TABLE `order_fulfillment`
+    DROP COLUMN restocked_at;
@@ -0,0 +1,13 @@
+-- migrate:up
+-- one review per buyer and product, only the latest of any earlier duplicates is kept
+DELETE older FROM `product_review` older
+JOIN `product_review` newer
+    ON newer.user_id = older.user_id AND newer.product_id = older.product_id AND newer.id > older.id;
+
+ALTER TABLE `product_review`
+    ADD CONSTRAINT uq_product_review_user_product UNIQUE (user_id, product_id);
+
+
+-- migrate:down
+ALTER TABLE `product_review`
+    DROP INDEX uq_product_review_user_product;
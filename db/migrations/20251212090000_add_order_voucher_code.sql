@@ -0,0 +1,9 @@
+-- migrate:up
+-- kept so a canceled order can give its voucher usage back
+ALTER TABLE `order`
+    ADD COLUMN voucher_code VARCHAR(50) NOT NULL DEFAULT '' AFTER currency;
+
+
+-- migrate:down
+ALTER TABLE `order`
+    DROP COLUMN voucher_code;
@@ -15,47 +15,53 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/internal/v1/warehouses/transfer": {
-            "post": {
+        "/healthz": {
+            "get": {
+                "description": "Returns 200 as long as the process is serving requests",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/v1/config": {
+            "get": {
                 "security": [
                     {
                         "InternalAPIKey": []
                     }
                 ],
-                "description": "Transfer stock from one warehouse to another. Only available stock (stock - reserved) can be transferred",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Return the non-secret settings the server is running with. Credentials are never included",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Warehouse"
-                ],
-                "summary": "Transfer stock between warehouses",
-                "parameters": [
-                    {
-                        "description": "Transfer Stock Request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.TransferStockHTTPRequest"
-                        }
-                    }
+                    "System"
                 ],
+                "summary": "Get effective configuration",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/model.EffectiveConfig"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/errors.CustomError"
                         }
@@ -63,41 +69,26 @@ const docTemplate = `{
                 }
             }
         },
-        "/internal/v1/warehouses/{id}/activate": {
-            "patch": {
+        "/internal/v1/consumer/status": {
+            "get": {
                 "security": [
                     {
                         "InternalAPIKey": []
                     }
                 ],
-                "description": "Activate a warehouse",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Whether the RabbitMQ expiration consumer is connected, when it last handled a message and how many messages wait in its queue. Not found when orders expire without RabbitMQ",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Warehouse"
-                ],
-                "summary": "Activate warehouse",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Warehouse ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
+                    "Order"
                 ],
+                "summary": "Order expiration consumer status",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/model.ConsumerStatus"
                         }
                     },
                     "400": {
@@ -105,32 +96,35 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/errors.CustomError"
                         }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
                     }
                 }
             }
         },
-        "/internal/v1/warehouses/{id}/deactivate": {
-            "patch": {
+        "/internal/v1/order/{id}/approve": {
+            "post": {
                 "security": [
                     {
                         "InternalAPIKey": []
                     }
                 ],
-                "description": "Deactivate a warehouse. Cannot deactivate if there's reserved stock",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Release an order held for review (above the max order value) to pending. It keeps its reservations and gets a full expiration window from now",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Warehouse"
+                    "Order"
                 ],
-                "summary": "Deactivate warehouse",
+                "summary": "Approve order",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Warehouse ID",
+                        "description": "Order ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -140,10 +134,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/model.OrderActionResponse"
                         }
                     },
                     "400": {
@@ -155,35 +146,35 @@ const docTemplate = `{
                 }
             }
         },
-        "/public/v1/login": {
+        "/internal/v1/order/{id}/reject": {
             "post": {
-                "description": "Login with email or phone and receive JWT token",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "InternalAPIKey": []
+                    }
                 ],
+                "description": "Cancel an order held for review and release its reservations",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Order"
                 ],
-                "summary": "Login user",
+                "summary": "Reject order",
                 "parameters": [
                     {
-                        "description": "Login Request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.LoginRequest"
-                        }
+                        "type": "integer",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.LoginResponse"
+                            "$ref": "#/definitions/model.OrderActionResponse"
                         }
                     },
                     "400": {
@@ -195,14 +186,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/public/v1/order": {
+        "/internal/v1/product": {
             "post": {
                 "security": [
                     {
-                        "BearerAuth": []
+                        "InternalAPIKey": []
                     }
                 ],
-                "description": "Create a new order and reserve stock",
+                "description": "Add a product to a shop. The price is rounded to the store currency and must stay above 0. An unknown shop is not found",
                 "consumes": [
                     "application/json"
                 ],
@@ -210,17 +201,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Order"
+                    "Product"
                 ],
-                "summary": "Create order",
+                "summary": "Create product",
                 "parameters": [
                     {
-                        "description": "Order Request",
+                        "description": "Product Request",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.OrderRequest"
+                            "$ref": "#/definitions/model.ProductRequest"
                         }
                     }
                 ],
@@ -228,7 +219,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.OrderResponse"
+                            "$ref": "#/definitions/model.ProductDetail"
                         }
                     },
                     "400": {
@@ -240,14 +231,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/public/v1/order/{id}/cancel": {
-            "post": {
+        "/internal/v1/product/{id}": {
+            "delete": {
                 "security": [
                     {
-                        "BearerAuth": []
+                        "InternalAPIKey": []
                     }
                 ],
-                "description": "Cancel order and release reservations",
+                "description": "Soft-delete a discontinued product. It disappears from listings and can no longer be ordered, orders already placed keep their items",
                 "consumes": [
                     "application/json"
                 ],
@@ -255,13 +246,13 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Order"
+                    "Product"
                 ],
-                "summary": "Cancel order",
+                "summary": "Delete product",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Order ID",
+                        "description": "Product ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -284,16 +275,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/public/v1/order/{id}/pay": {
-            "post": {
+            },
+            "patch": {
                 "security": [
                     {
-                        "BearerAuth": []
+                        "InternalAPIKey": []
                     }
                 ],
-                "description": "Mark order as paid and adjust stock",
+                "description": "Change the fields given, at least one. A missing or deleted product, or an unknown new shop, is not found",
                 "consumes": [
                     "application/json"
                 ],
@@ -301,26 +290,32 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Order"
+                    "Product"
                 ],
-                "summary": "Pay order",
+                "summary": "Update product",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Order ID",
+                        "description": "Product ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Product Update Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ProductUpdateRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/model.ProductDetail"
                         }
                     },
                     "400": {
@@ -332,36 +327,75 @@ const docTemplate = `{
                 }
             }
         },
-        "/public/v1/product": {
-            "get": {
+        "/internal/v1/reservations/sweep": {
+            "post": {
                 "security": [
                     {
-                        "BearerAuth": []
+                        "InternalAPIKey": []
                     }
                 ],
-                "description": "Get paginated list of products with shop and available stock",
-                "consumes": [
+                "description": "Run one pass of the expired reservation cleanup now instead of waiting for the next interval: release expired cart reservations, then cancel expired pending orders. Each pass handles one batch, call again while counts come back non-zero",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "Warehouse"
+                ],
+                "summary": "Run the reservation expiry sweep",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SweepResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/v1/users/{id}/orders": {
+            "get": {
+                "security": [
+                    {
+                        "InternalAPIKey": []
+                    }
+                ],
+                "description": "Support view of any customer's orders newest first, optionally filtered by status. Requires the internal API key",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Product"
+                    "Order"
                 ],
-                "summary": "List products",
+                "summary": "List a user's orders",
                 "parameters": [
                     {
                         "type": "integer",
-                        "default": 1,
-                        "description": "Page number",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Order status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page",
                         "name": "page",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "default": 10,
-                        "description": "Items per page",
+                        "description": "Items per page, capped at the server maximum (per_page of the response)",
                         "name": "per_page",
                         "in": "query"
                     }
@@ -370,7 +404,13 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.ProductListResponse"
+                            "$ref": "#/definitions/model.OrderListResponse"
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "next, prev and last pages (RFC 5988)"
+                            }
                         }
                     },
                     "400": {
@@ -378,18 +418,24 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/errors.CustomError"
                         }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
                     }
                 }
             }
         },
-        "/public/v1/product/{id}": {
+        "/internal/v1/warehouses": {
             "get": {
                 "security": [
                     {
-                        "BearerAuth": []
+                        "InternalAPIKey": []
                     }
                 ],
-                "description": "Get product detail by id",
+                "description": "List warehouses with their total stock and reserved quantity. Returns all warehouses when shop_id is empty or zero",
                 "consumes": [
                     "application/json"
                 ],
@@ -397,23 +443,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Product"
+                    "Warehouse"
                 ],
-                "summary": "Get product detail",
+                "summary": "List warehouses",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Product ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Shop ID",
+                        "name": "shop_id",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.ProductDetail"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.WarehouseSummary"
+                            }
                         }
                     },
                     "400": {
@@ -425,9 +473,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/public/v1/register": {
-            "post": {
-                "description": "Register a new user",
+        "/internal/v1/warehouses/low-stock": {
+            "get": {
+                "security": [
+                    {
+                        "InternalAPIKey": []
+                    }
+                ],
+                "description": "List products whose available stock (stock - reserved) across active warehouses is at or below threshold, lowest first",
                 "consumes": [
                     "application/json"
                 ],
@@ -435,25 +488,26 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Warehouse"
                 ],
-                "summary": "Register user",
+                "summary": "Low stock report",
                 "parameters": [
                     {
-                        "description": "Register Request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.RegisterRequest"
-                        }
+                        "type": "integer",
+                        "description": "Maximum available quantity to report",
+                        "name": "threshold",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.RegisterResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.LowStockItem"
+                            }
                         }
                     },
                     "400": {
@@ -464,29 +518,2749 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "errors.CustomError": {
-            "type": "object"
         },
-        "model.LoginRequest": {
-            "type": "object",
-            "required": [
-                "identifier",
-                "password"
-            ],
-            "properties": {
-                "identifier": {
-                    "description": "email or phone",
+        "/internal/v1/warehouses/reconcile": {
+            "post": {
+                "security": [
+                    {
+                        "InternalAPIKey": []
+                    }
+                ],
+                "description": "Recompute each warehouse_stock reserved count from its reservation rows and correct the ones that drifted. Runs for every warehouse when warehouse_id is empty or zero, and returns the rows corrected",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Warehouse"
+                ],
+                "summary": "Reconcile reserved stock",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Warehouse ID",
+                        "name": "warehouse_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.ReservedDiscrepancy"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/v1/warehouses/transfer": {
+            "post": {
+                "security": [
+                    {
+                        "InternalAPIKey": []
+                    }
+                ],
+                "description": "Transfer stock from one warehouse to another, both warehouses must be active. Only available stock (stock - reserved) can be transferred, unless move_reservations is set: the product's reservations then move to the destination with their stock, and quantity must be at least the reserved amount",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Warehouse"
+                ],
+                "summary": "Transfer stock between warehouses",
+                "parameters": [
+                    {
+                        "description": "Transfer Stock Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.TransferStockHTTPRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/v1/warehouses/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "InternalAPIKey": []
+                    }
+                ],
+                "description": "Get a warehouse and its current status",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Warehouse"
+                ],
+                "summary": "Get warehouse",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Warehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.WarehouseEntity"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/v1/warehouses/{id}/activate": {
+            "patch": {
+                "security": [
+                    {
+                        "InternalAPIKey": []
+                    }
+                ],
+                "description": "Activate a warehouse",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Warehouse"
+                ],
+                "summary": "Activate warehouse",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Warehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/v1/warehouses/{id}/deactivate": {
+            "patch": {
+                "security": [
+                    {
+                        "InternalAPIKey": []
+                    }
+                ],
+                "description": "Deactivate a warehouse. Cannot deactivate if there's reserved stock, the rejection's details then list the reserved quantity and the orders and carts holding it. With transfer_to, all stock is first moved to that warehouse in the same transaction",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Warehouse"
+                ],
+                "summary": "Deactivate warehouse",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Warehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Active warehouse to move the stock to before deactivating",
+                        "name": "transfer_to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/v1/warehouses/{id}/stock": {
+            "post": {
+                "security": [
+                    {
+                        "InternalAPIKey": []
+                    }
+                ],
+                "description": "Add (restock) or remove stock of a product in a warehouse. Stock cannot be reduced below the reserved quantity",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Warehouse"
+                ],
+                "summary": "Adjust warehouse stock",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Warehouse ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Stock Adjustment Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.StockAdjustmentHTTPRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/addresses": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List saved addresses of the authenticated user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Address"
+                ],
+                "summary": "List addresses",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.UserAddress"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Save a new address for the authenticated user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Address"
+                ],
+                "summary": "Add address",
+                "parameters": [
+                    {
+                        "description": "Address Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ShippingAddress"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.UserAddress"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/addresses/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update a saved address of the authenticated user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Address"
+                ],
+                "summary": "Update address",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Address ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Address Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ShippingAddress"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.UserAddress"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a saved address of the authenticated user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Address"
+                ],
+                "summary": "Delete address",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Address ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/cart": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the authenticated user's saved cart, each item flags insufficient_stock when availability dropped below its quantity",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Cart"
+                ],
+                "summary": "Get cart",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.CartResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/cart/items": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Add a product to the cart, increasing the quantity when it is already there. Stock is only reserved when reserve-on-add is enabled.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Cart"
+                ],
+                "summary": "Add cart item",
+                "parameters": [
+                    {
+                        "description": "Cart Item Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.CartItemRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/cart/items/{product_id}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Set the quantity of a product in the cart",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Cart"
+                ],
+                "summary": "Update cart item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "product_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Cart Item Update Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.CartItemUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove a product from the cart",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Cart"
+                ],
+                "summary": "Remove cart item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "product_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/login": {
+            "post": {
+                "description": "Login with email or phone and receive JWT token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Login user",
+                "parameters": [
+                    {
+                        "description": "Login Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.LoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.LoginResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/me/sessions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the authenticated user's active sessions, soonest to expire first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "List sessions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Session"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Log out everywhere, every session of the authenticated user is revoked including the current one",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Revoke all sessions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/notification-preferences": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the authenticated user's notification preferences, defaults apply until they are changed",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notification"
+                ],
+                "summary": "Get notification preferences",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.NotificationPrefs"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Opt in or out of notification types, omitted fields keep their current value",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notification"
+                ],
+                "summary": "Update notification preferences",
+                "parameters": [
+                    {
+                        "description": "Notification Preferences Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UpdateNotificationPrefsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.NotificationPrefs"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/order": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the current user's orders newest first, optionally filtered by status",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "List my orders",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Order status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Items per page, capped at the server maximum (per_page of the response)",
+                        "name": "per_page",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.OrderListResponse"
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "next, prev and last pages (RFC 5988)"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new order and reserve stock. Set from_cart to order the items saved in the user's cart instead of sending items; the cart is cleared once the order is created. Each product may appear only once in items, a repeated product_id is rejected with 400.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Create order",
+                "parameters": [
+                    {
+                        "description": "Order Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.OrderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.OrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/order/preview": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Quote the subtotal, tax and grand total an order for the items would be charged, priced like Create order. Nothing is reserved and stock is not checked.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Preview order",
+                "parameters": [
+                    {
+                        "description": "Order Preview Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.OrderPreviewRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.OrderPreviewResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/order/{id}/cancel": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Cancel order and release reservations",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Cancel order",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.OrderActionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/order/{id}/pay": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Mark order as paid and adjust stock",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Pay order",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.OrderActionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/order/{id}/refund": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Refund a paid order and return its stock to the warehouses it was fulfilled from",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Order"
+                ],
+                "summary": "Refund order",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/product": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get paginated list of products with shop and available stock. Authentication is optional",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Product"
+                ],
+                "summary": "List products",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Items per page, capped at the server maximum (per_page of the response)",
+                        "name": "per_page",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "price_asc",
+                            "price_desc",
+                            "name_asc",
+                            "newest"
+                        ],
+                        "type": "string",
+                        "description": "Sort order",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProductListResponse"
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "next, prev and last pages (RFC 5988)"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/product/feed": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Lightweight product projection for infinite scroll, paged by cursor. Availability is a boolean instead of the exact stock. Authentication is optional",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Product"
+                ],
+                "summary": "Product feed",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "next_cursor of the previous page, empty for the first page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Items per page",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProductFeedResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/product/sync": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Products created, updated or deleted at or after since, oldest change first. Deleted products are flagged and must be removed from the mirror. Follow next_cursor while has_more is true. Authentication is optional",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Product"
+                ],
+                "summary": "Product catalog sync",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "RFC3339 timestamp, e.g. 2025-11-30T00:00:00Z",
+                        "name": "since",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Items per page",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProductSyncResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/product/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get product detail by id. Authentication is optional",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Product"
+                ],
+                "summary": "Get product detail",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProductDetail"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/product/{id}/reviews": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get paginated reviews of a product, newest first. Authentication is optional",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Product"
+                ],
+                "summary": "List product reviews",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Items per page, capped at the server maximum (per_page of the response)",
+                        "name": "per_page",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ReviewListResponse"
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "next, prev and last pages (RFC 5988)"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Review a product. Only users with a completed order of the product can review it, once per product",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Product"
+                ],
+                "summary": "Create product review",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Review Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ReviewRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProductReview"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/product/{id}/stock": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get live available stock (stock - reserved) of a product across active warehouses. Authentication is optional",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Product"
+                ],
+                "summary": "Get product available stock",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ProductStockResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/product/{id}/stock-subscription": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get notified once an out-of-stock product can be ordered again. The subscription ends with the notification. Subscribing to a product that is in stock is rejected",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Product"
+                ],
+                "summary": "Subscribe to back in stock",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/register": {
+            "post": {
+                "description": "Register a new user. The email starts unverified, the returned verification_token confirms it through /public/v1/verify",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Register user",
+                "parameters": [
+                    {
+                        "description": "Register Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.RegisterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.RegisterResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/verify": {
+            "post": {
+                "description": "Confirm the email of a registered user with the token issued on register. A token works once, users who haven't verified can't place orders",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Verify email",
+                "parameters": [
+                    {
+                        "description": "Verify Email Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.VerifyEmailRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/verify/resend": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Issue a fresh verification token to the logged in user, for when the one from register expired or was lost. Fails once the email is verified",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Resend verification token",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ResendVerificationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/wishlist": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the authenticated user's wishlist with current price and available stock",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Wishlist"
+                ],
+                "summary": "List wishlist",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.WishlistItem"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Save a product to the authenticated user's wishlist. Adding a saved product again is a no-op",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Wishlist"
+                ],
+                "summary": "Add to wishlist",
+                "parameters": [
+                    {
+                        "description": "Wishlist Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.WishlistRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/public/v1/wishlist/{product_id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove a product from the authenticated user's wishlist",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Wishlist"
+                ],
+                "summary": "Remove from wishlist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "product_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/errors.CustomError"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Checks every dependency (MySQL, Redis) and returns 503 with the per-dependency status when any of them fails",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "constant.OrderStatus": {
+            "type": "integer",
+            "enum": [
+                1,
+                2,
+                3,
+                5,
+                6
+            ],
+            "x-enum-varnames": [
+                "OrderStatusPending",
+                "OrderStatusCompleted",
+                "OrderStatusCanceled",
+                "OrderStatusPendingReview",
+                "OrderStatusRefunded"
+            ]
+        },
+        "constant.ProductAvailability": {
+            "type": "string",
+            "enum": [
+                "in_stock",
+                "low_stock",
+                "out_of_stock"
+            ],
+            "x-enum-varnames": [
+                "ProductAvailabilityInStock",
+                "ProductAvailabilityLowStock",
+                "ProductAvailabilityOutOfStock"
+            ]
+        },
+        "constant.WarehouseStatus": {
+            "type": "integer",
+            "enum": [
+                0,
+                1
+            ],
+            "x-enum-varnames": [
+                "WarehouseStatusInactive",
+                "WarehouseStatusActive"
+            ]
+        },
+        "errors.CustomError": {
+            "type": "object"
+        },
+        "model.CartItem": {
+            "type": "object",
+            "properties": {
+                "available_stock": {
+                    "description": "availability at read time, nothing is reserved for cart items",
+                    "type": "integer"
+                },
+                "insufficient_stock": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "product_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.CartItemRequest": {
+            "type": "object",
+            "required": [
+                "product_id",
+                "quantity"
+            ],
+            "properties": {
+                "product_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.CartItemUpdateRequest": {
+            "type": "object",
+            "required": [
+                "quantity"
+            ],
+            "properties": {
+                "quantity": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.CartResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.CartItem"
+                    }
+                },
+                "subtotal": {
+                    "type": "number"
+                }
+            }
+        },
+        "model.ConsumerStatus": {
+            "type": "object",
+            "properties": {
+                "connected": {
+                    "description": "Connected is whether the consumer's connection and channel are open",
+                    "type": "boolean"
+                },
+                "last_processed_at": {
+                    "description": "LastProcessedAt is when the last message was handled, nil before the first one",
+                    "type": "string"
+                },
+                "queue_consumers": {
+                    "description": "QueueConsumers is how many consumers the broker sees on the queue",
+                    "type": "integer"
+                },
+                "queue_depth": {
+                    "description": "QueueDepth is the backlog of expiration messages ready for delivery",
+                    "type": "integer"
+                }
+            }
+        },
+        "model.EffectiveAuthConfig": {
+            "type": "object",
+            "properties": {
+                "bcrypt_cost": {
+                    "description": "the cost in use, after falling back from an out of range setting",
+                    "type": "integer"
+                },
+                "jwt_accepted_key_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "jwt_expiration_seconds": {
+                    "type": "integer"
+                },
+                "jwt_key_id": {
+                    "description": "key ids only, the secrets themselves are never exposed",
+                    "type": "string"
+                },
+                "require_verified_email": {
+                    "type": "boolean"
+                },
+                "session_expiration_seconds": {
+                    "type": "integer"
+                },
+                "single_session": {
+                    "type": "boolean"
+                },
+                "verification_token_seconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.EffectiveCartConfig": {
+            "type": "object",
+            "properties": {
+                "reservation_sweep_interval_seconds": {
+                    "type": "integer"
+                },
+                "reservation_ttl_seconds": {
+                    "type": "integer"
+                },
+                "reserve_on_add": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "model.EffectiveConfig": {
+            "type": "object",
+            "properties": {
+                "auth": {
+                    "$ref": "#/definitions/model.EffectiveAuthConfig"
+                },
+                "cart": {
+                    "$ref": "#/definitions/model.EffectiveCartConfig"
+                },
+                "database": {
+                    "$ref": "#/definitions/model.EffectiveDatabaseConfig"
+                },
+                "environment": {
+                    "type": "string"
+                },
+                "order": {
+                    "$ref": "#/definitions/model.EffectiveOrderConfig"
+                },
+                "product": {
+                    "$ref": "#/definitions/model.EffectiveProductConfig"
+                },
+                "project_name": {
+                    "type": "string"
+                },
+                "rabbitmq": {
+                    "$ref": "#/definitions/model.EffectiveRabbitMQConfig"
+                },
+                "server": {
+                    "$ref": "#/definitions/model.EffectiveServerConfig"
+                },
+                "store": {
+                    "$ref": "#/definitions/model.EffectiveStoreConfig"
+                },
+                "warehouse": {
+                    "$ref": "#/definitions/model.EffectiveWarehouseConfig"
+                }
+            }
+        },
+        "model.EffectiveDatabaseConfig": {
+            "type": "object",
+            "properties": {
+                "conn_max_lifetime_seconds": {
+                    "type": "integer"
+                },
+                "connect_backoff_seconds": {
+                    "type": "integer"
+                },
+                "connect_retries": {
+                    "type": "integer"
+                },
+                "max_idle_conns": {
+                    "type": "integer"
+                },
+                "max_open_conns": {
+                    "type": "integer"
+                },
+                "query_timeout_seconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.EffectiveOrderConfig": {
+            "type": "object",
+            "properties": {
+                "expiration_poll_interval_seconds": {
+                    "type": "integer"
+                },
+                "expiration_strategy": {
+                    "type": "string"
+                },
+                "high_value_action": {
+                    "type": "string"
+                },
+                "max_body_bytes": {
+                    "type": "integer"
+                },
+                "max_decoded_items": {
+                    "type": "integer"
+                },
+                "max_items": {
+                    "type": "integer"
+                },
+                "max_order_value": {
+                    "type": "number"
+                },
+                "max_quantity_per_item": {
+                    "type": "integer"
+                },
+                "order_expiration_seconds": {
+                    "type": "integer"
+                },
+                "outbox_relay_interval_seconds": {
+                    "type": "integer"
+                },
+                "product_lock": {
+                    "type": "boolean"
+                },
+                "product_lock_ttl_seconds": {
+                    "type": "integer"
+                },
+                "product_lock_wait_seconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.EffectiveProductConfig": {
+            "type": "object",
+            "properties": {
+                "cache_ttl_seconds": {
+                    "type": "integer"
+                },
+                "low_stock_threshold": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.EffectiveRabbitMQConfig": {
+            "type": "object",
+            "properties": {
+                "consumer_workers": {
+                    "type": "integer"
+                },
+                "dead_letter_alert": {
+                    "type": "boolean"
+                },
+                "max_concurrent_cancels": {
+                    "type": "integer"
+                },
+                "max_redeliveries": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.EffectiveServerConfig": {
+            "type": "object",
+            "properties": {
+                "body_log_enabled": {
+                    "type": "boolean"
+                },
+                "body_log_paths": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "compression_enabled": {
+                    "type": "boolean"
+                },
+                "compression_min_bytes": {
+                    "type": "integer"
+                },
+                "cors_allowed_origins": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "idle_timeout_seconds": {
+                    "type": "integer"
+                },
+                "max_per_page": {
+                    "type": "integer"
+                },
+                "port": {
+                    "type": "string"
+                },
+                "rate_limit_burst": {
+                    "type": "integer"
+                },
+                "rate_limit_rps": {
+                    "type": "number"
+                },
+                "read_timeout_seconds": {
+                    "type": "integer"
+                },
+                "request_timeout_seconds": {
+                    "type": "integer"
+                },
+                "shutdown_timeout_seconds": {
+                    "type": "integer"
+                },
+                "write_timeout_seconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.EffectiveStoreConfig": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "tax_inclusive": {
+                    "type": "boolean"
+                },
+                "tax_rate": {
+                    "type": "number"
+                }
+            }
+        },
+        "model.EffectiveWarehouseConfig": {
+            "type": "object",
+            "properties": {
+                "allocation_strategy": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.LoginRequest": {
+            "type": "object",
+            "required": [
+                "identifier",
+                "password"
+            ],
+            "properties": {
+                "identifier": {
+                    "description": "email or phone",
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.LoginResponse": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.LowStockItem": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "product_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.NotificationPrefs": {
+            "type": "object",
+            "properties": {
+                "marketing": {
+                    "type": "boolean"
+                },
+                "order_updates": {
+                    "type": "boolean"
+                },
+                "wishlist_low_stock": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "model.OrderActionResponse": {
+            "type": "object",
+            "properties": {
+                "already_applied": {
+                    "description": "AlreadyApplied tells a retried request apart from the one that changed the order",
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.OrderItem"
+                    }
+                },
+                "order_id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.OrderItem": {
+            "type": "object",
+            "properties": {
+                "product_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "unit_price": {
+                    "type": "number"
+                }
+            }
+        },
+        "model.OrderItemRequest": {
+            "type": "object",
+            "required": [
+                "product_id",
+                "quantity"
+            ],
+            "properties": {
+                "product_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.OrderListResponse": {
+            "type": "object",
+            "properties": {
+                "has_next": {
+                    "type": "boolean"
+                },
+                "has_prev": {
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.OrderSummary"
+                    }
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "per_page": {
+                    "type": "integer"
+                },
+                "total_count": {
+                    "type": "integer"
+                },
+                "total_pages": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.OrderPreviewRequest": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.OrderItemRequest"
+                    }
+                }
+            }
+        },
+        "model.OrderPreviewResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "grand_total": {
+                    "type": "number"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.OrderItem"
+                    }
+                },
+                "subtotal": {
+                    "type": "number"
+                },
+                "tax_amount": {
+                    "type": "number"
+                }
+            }
+        },
+        "model.OrderRequest": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "address_id": {
+                    "type": "integer"
+                },
+                "from_cart": {
+                    "type": "boolean"
+                },
+                "items": {
+                    "description": "Items lists each product once, duplicates are rejected instead of merged",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.OrderItemRequest"
+                    }
+                },
+                "shipping_address": {
+                    "$ref": "#/definitions/model.ShippingAddress"
+                },
+                "voucher_code": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.OrderResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "grand_total": {
+                    "type": "number"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.OrderItem"
+                    }
+                },
+                "order_id": {
+                    "type": "integer"
+                },
+                "shipping_address": {
+                    "$ref": "#/definitions/model.ShippingAddress"
+                },
+                "status": {
+                    "$ref": "#/definitions/constant.OrderStatus"
+                },
+                "subtotal": {
+                    "type": "number"
+                },
+                "tax_amount": {
+                    "type": "number"
+                }
+            }
+        },
+        "model.OrderSummary": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "grand_total": {
+                    "type": "number"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/constant.OrderStatus"
+                },
+                "subtotal": {
+                    "type": "number"
+                },
+                "tax_amount": {
+                    "type": "number"
+                }
+            }
+        },
+        "model.ProductDetail": {
+            "type": "object",
+            "properties": {
+                "availability_status": {
+                    "$ref": "#/definitions/constant.ProductAvailability"
+                },
+                "available_stock": {
+                    "type": "integer"
+                },
+                "average_rating": {
+                    "type": "number"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "review_count": {
+                    "type": "integer"
+                },
+                "shop_id": {
+                    "type": "integer"
+                },
+                "shop_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ProductFeedItem": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "image_url": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                }
+            }
+        },
+        "model.ProductFeedResponse": {
+            "type": "object",
+            "properties": {
+                "has_more": {
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ProductFeedItem"
+                    }
+                },
+                "next_cursor": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ProductListItem": {
+            "type": "object",
+            "properties": {
+                "availability_status": {
+                    "$ref": "#/definitions/constant.ProductAvailability"
+                },
+                "available_stock": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "shop_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ProductListResponse": {
+            "type": "object",
+            "properties": {
+                "has_next": {
+                    "type": "boolean"
+                },
+                "has_prev": {
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ProductListItem"
+                    }
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "per_page": {
+                    "type": "integer"
+                },
+                "total_count": {
+                    "type": "integer"
+                },
+                "total_pages": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ProductRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "shop_id"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "price": {
+                    "type": "number"
+                },
+                "shop_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ProductReview": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "product_id": {
+                    "type": "integer"
+                },
+                "rating": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ProductStockResponse": {
+            "type": "object",
+            "properties": {
+                "available_stock": {
+                    "type": "integer"
+                },
+                "product_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ProductSyncItem": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "boolean"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "image_url": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "shop_id": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ProductSyncResponse": {
+            "type": "object",
+            "properties": {
+                "has_more": {
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ProductSyncItem"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ProductUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "minLength": 1
+                },
+                "price": {
+                    "type": "number"
+                },
+                "shop_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.RegisterRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "name",
+                "password",
+                "phone"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "name": {
                     "type": "string"
                 },
                 "password": {
+                    "type": "string",
+                    "minLength": 6
+                },
+                "phone": {
                     "type": "string"
                 }
             }
         },
-        "model.LoginResponse": {
+        "model.RegisterResponse": {
             "type": "object",
             "properties": {
                 "email": {
@@ -495,171 +3269,315 @@ const docTemplate = `{
                 "name": {
                     "type": "string"
                 },
-                "token": {
+                "verification_token": {
+                    "description": "VerificationToken confirms the email through POST /public/v1/verify. It is\nreturned here until verification emails are sent.",
                     "type": "string"
                 }
             }
         },
-        "model.OrderItemRequest": {
+        "model.ResendVerificationResponse": {
             "type": "object",
-            "required": [
-                "product_id",
-                "quantity"
-            ],
             "properties": {
+                "verification_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ReservedDiscrepancy": {
+            "type": "object",
+            "properties": {
+                "actual": {
+                    "type": "integer"
+                },
                 "product_id": {
                     "type": "integer"
                 },
-                "quantity": {
+                "reserved": {
+                    "type": "integer"
+                },
+                "warehouse_id": {
                     "type": "integer"
                 }
             }
         },
-        "model.OrderRequest": {
+        "model.ReviewListResponse": {
             "type": "object",
-            "required": [
-                "items"
-            ],
             "properties": {
+                "has_next": {
+                    "type": "boolean"
+                },
+                "has_prev": {
+                    "type": "boolean"
+                },
                 "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/model.OrderItemRequest"
+                        "$ref": "#/definitions/model.ProductReview"
                     }
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "per_page": {
+                    "type": "integer"
+                },
+                "total_count": {
+                    "type": "integer"
+                },
+                "total_pages": {
+                    "type": "integer"
                 }
             }
         },
-        "model.OrderResponse": {
+        "model.ReviewRequest": {
+            "type": "object",
+            "required": [
+                "rating"
+            ],
+            "properties": {
+                "comment": {
+                    "type": "string",
+                    "maxLength": 1000
+                },
+                "rating": {
+                    "type": "integer",
+                    "maximum": 5,
+                    "minimum": 1
+                }
+            }
+        },
+        "model.Session": {
             "type": "object",
             "properties": {
                 "expires_at": {
                     "type": "string"
                 },
-                "order_id": {
-                    "type": "integer"
+                "id": {
+                    "type": "string"
                 }
             }
         },
-        "model.ProductDetail": {
+        "model.ShippingAddress": {
             "type": "object",
+            "required": [
+                "address_line",
+                "city",
+                "phone",
+                "postal_code",
+                "province",
+                "recipient_name"
+            ],
             "properties": {
-                "available_stock": {
-                    "type": "integer"
-                },
-                "description": {
+                "address_line": {
                     "type": "string"
                 },
-                "id": {
-                    "type": "integer"
+                "city": {
+                    "type": "string"
                 },
-                "name": {
+                "phone": {
                     "type": "string"
                 },
-                "price": {
-                    "type": "number"
+                "postal_code": {
+                    "type": "string"
                 },
-                "shop_id": {
-                    "type": "integer"
+                "province": {
+                    "type": "string"
                 },
-                "shop_name": {
+                "recipient_name": {
                     "type": "string"
                 }
             }
         },
-        "model.ProductListItem": {
+        "model.StockAdjustmentHTTPRequest": {
             "type": "object",
+            "required": [
+                "product_id",
+                "quantity"
+            ],
             "properties": {
-                "available_stock": {
+                "product_id": {
                     "type": "integer"
                 },
-                "id": {
+                "quantity": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.SweepResponse": {
+            "type": "object",
+            "properties": {
+                "expired_orders": {
                     "type": "integer"
                 },
-                "name": {
-                    "type": "string"
-                },
-                "price": {
-                    "type": "number"
-                },
-                "shop_name": {
-                    "type": "string"
+                "released_reservations": {
+                    "type": "integer"
                 }
             }
         },
-        "model.ProductListResponse": {
+        "model.TransferStockHTTPRequest": {
             "type": "object",
+            "required": [
+                "from_warehouse_id",
+                "product_id",
+                "quantity",
+                "to_warehouse_id"
+            ],
             "properties": {
-                "items": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/model.ProductListItem"
-                    }
+                "from_warehouse_id": {
+                    "type": "integer"
                 },
-                "page": {
+                "move_reservations": {
+                    "type": "boolean"
+                },
+                "product_id": {
                     "type": "integer"
                 },
-                "per_page": {
+                "quantity": {
                     "type": "integer"
                 },
-                "total_count": {
+                "to_warehouse_id": {
                     "type": "integer"
                 }
             }
         },
-        "model.RegisterRequest": {
+        "model.UpdateNotificationPrefsRequest": {
+            "type": "object",
+            "properties": {
+                "marketing": {
+                    "type": "boolean"
+                },
+                "order_updates": {
+                    "type": "boolean"
+                },
+                "wishlist_low_stock": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "model.UserAddress": {
             "type": "object",
             "required": [
-                "email",
-                "name",
-                "password",
-                "phone"
+                "address_line",
+                "city",
+                "phone",
+                "postal_code",
+                "province",
+                "recipient_name"
             ],
             "properties": {
-                "email": {
+                "address_line": {
                     "type": "string"
                 },
-                "name": {
+                "city": {
                     "type": "string"
                 },
-                "password": {
-                    "type": "string",
-                    "minLength": 6
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
                 },
                 "phone": {
                     "type": "string"
+                },
+                "postal_code": {
+                    "type": "string"
+                },
+                "province": {
+                    "type": "string"
+                },
+                "recipient_name": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
-        "model.RegisterResponse": {
+        "model.VerifyEmailRequest": {
             "type": "object",
+            "required": [
+                "token"
+            ],
             "properties": {
-                "email": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.WarehouseEntity": {
+            "type": "object",
+            "properties": {
+                "created_at": {
                     "type": "string"
                 },
+                "id": {
+                    "type": "integer"
+                },
                 "name": {
                     "type": "string"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "shop_id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/constant.WarehouseStatus"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
-        "model.TransferStockHTTPRequest": {
+        "model.WarehouseSummary": {
             "type": "object",
-            "required": [
-                "from_warehouse_id",
-                "product_id",
-                "quantity",
-                "to_warehouse_id"
-            ],
             "properties": {
-                "from_warehouse_id": {
+                "id": {
                     "type": "integer"
                 },
-                "product_id": {
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/constant.WarehouseStatus"
+                },
+                "total_reserved": {
                     "type": "integer"
                 },
-                "quantity": {
+                "total_stock": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.WishlistItem": {
+            "type": "object",
+            "properties": {
+                "added_at": {
+                    "type": "string"
+                },
+                "available_stock": {
                     "type": "integer"
                 },
-                "to_warehouse_id": {
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "product_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.WishlistRequest": {
+            "type": "object",
+            "required": [
+                "product_id"
+            ],
+            "properties": {
+                "product_id": {
                     "type": "integer"
                 }
             }
@@ -0,0 +1,351 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	model "github.com/muhammadheryan/e-commerce/model"
+	mock "github.com/stretchr/testify/mock"
+
+	sqlx "github.com/jmoiron/sqlx"
+)
+
+// CartRepository is an autogenerated mock type for the CartRepository type
+type CartRepository struct {
+	mock.Mock
+}
+
+// AddItem provides a mock function with given fields: ctx, userID, productID, quantity
+func (_m *CartRepository) AddItem(ctx context.Context, userID uint64, productID uint64, quantity int) error {
+	ret := _m.Called(ctx, userID, productID, quantity)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddItem")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, int) error); ok {
+		r0 = rf(ctx, userID, productID, quantity)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AddItemTx provides a mock function with given fields: ctx, tx, cartID, productID, quantity
+func (_m *CartRepository) AddItemTx(ctx context.Context, tx *sqlx.Tx, cartID uint64, productID uint64, quantity int) (int, error) {
+	ret := _m.Called(ctx, tx, cartID, productID, quantity)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddItemTx")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64, uint64, int) (int, error)); ok {
+		return rf(ctx, tx, cartID, productID, quantity)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64, uint64, int) int); ok {
+		r0 = rf(ctx, tx, cartID, productID, quantity)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, uint64, uint64, int) error); ok {
+		r1 = rf(ctx, tx, cartID, productID, quantity)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClearTx provides a mock function with given fields: ctx, tx, userID
+func (_m *CartRepository) ClearTx(ctx context.Context, tx *sqlx.Tx, userID uint64) error {
+	ret := _m.Called(ctx, tx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClearTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) error); ok {
+		r0 = rf(ctx, tx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EnsureCartTx provides a mock function with given fields: ctx, tx, userID
+func (_m *CartRepository) EnsureCartTx(ctx context.Context, tx *sqlx.Tx, userID uint64) (uint64, error) {
+	ret := _m.Called(ctx, tx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnsureCartTx")
+	}
+
+	var r0 uint64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) (uint64, error)); ok {
+		return rf(ctx, tx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) uint64); ok {
+		r0 = rf(ctx, tx, userID)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, uint64) error); ok {
+		r1 = rf(ctx, tx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCartIDTx provides a mock function with given fields: ctx, tx, userID
+func (_m *CartRepository) GetCartIDTx(ctx context.Context, tx *sqlx.Tx, userID uint64) (uint64, error) {
+	ret := _m.Called(ctx, tx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCartIDTx")
+	}
+
+	var r0 uint64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) (uint64, error)); ok {
+		return rf(ctx, tx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) uint64); ok {
+		r0 = rf(ctx, tx, userID)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, uint64) error); ok {
+		r1 = rf(ctx, tx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListItems provides a mock function with given fields: ctx, userID
+func (_m *CartRepository) ListItems(ctx context.Context, userID uint64) ([]model.CartItem, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListItems")
+	}
+
+	var r0 []model.CartItem
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) ([]model.CartItem, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) []model.CartItem); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.CartItem)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListItemsTx provides a mock function with given fields: ctx, tx, userID
+func (_m *CartRepository) ListItemsTx(ctx context.Context, tx *sqlx.Tx, userID uint64) ([]model.OrderItemRequest, error) {
+	ret := _m.Called(ctx, tx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListItemsTx")
+	}
+
+	var r0 []model.OrderItemRequest
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) ([]model.OrderItemRequest, error)); ok {
+		return rf(ctx, tx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) []model.OrderItemRequest); ok {
+		r0 = rf(ctx, tx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.OrderItemRequest)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, uint64) error); ok {
+		r1 = rf(ctx, tx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ProductExists provides a mock function with given fields: ctx, productID
+func (_m *CartRepository) ProductExists(ctx context.Context, productID uint64) (bool, error) {
+	ret := _m.Called(ctx, productID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ProductExists")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) (bool, error)); ok {
+		return rf(ctx, productID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) bool); ok {
+		r0 = rf(ctx, productID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, productID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoveItem provides a mock function with given fields: ctx, userID, productID
+func (_m *CartRepository) RemoveItem(ctx context.Context, userID uint64, productID uint64) (bool, error) {
+	ret := _m.Called(ctx, userID, productID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveItem")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64) (bool, error)); ok {
+		return rf(ctx, userID, productID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64) bool); ok {
+		r0 = rf(ctx, userID, productID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, uint64) error); ok {
+		r1 = rf(ctx, userID, productID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoveItemTx provides a mock function with given fields: ctx, tx, cartID, productID
+func (_m *CartRepository) RemoveItemTx(ctx context.Context, tx *sqlx.Tx, cartID uint64, productID uint64) (bool, error) {
+	ret := _m.Called(ctx, tx, cartID, productID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveItemTx")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64, uint64) (bool, error)); ok {
+		return rf(ctx, tx, cartID, productID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64, uint64) bool); ok {
+		r0 = rf(ctx, tx, cartID, productID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, uint64, uint64) error); ok {
+		r1 = rf(ctx, tx, cartID, productID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateItem provides a mock function with given fields: ctx, userID, productID, quantity
+func (_m *CartRepository) UpdateItem(ctx context.Context, userID uint64, productID uint64, quantity int) (bool, error) {
+	ret := _m.Called(ctx, userID, productID, quantity)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateItem")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, int) (bool, error)); ok {
+		return rf(ctx, userID, productID, quantity)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, int) bool); ok {
+		r0 = rf(ctx, userID, productID, quantity)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, uint64, int) error); ok {
+		r1 = rf(ctx, userID, productID, quantity)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateItemTx provides a mock function with given fields: ctx, tx, cartID, productID, quantity
+func (_m *CartRepository) UpdateItemTx(ctx context.Context, tx *sqlx.Tx, cartID uint64, productID uint64, quantity int) (bool, error) {
+	ret := _m.Called(ctx, tx, cartID, productID, quantity)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateItemTx")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64, uint64, int) (bool, error)); ok {
+		return rf(ctx, tx, cartID, productID, quantity)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64, uint64, int) bool); ok {
+		r0 = rf(ctx, tx, cartID, productID, quantity)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, uint64, uint64, int) error); ok {
+		r1 = rf(ctx, tx, cartID, productID, quantity)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewCartRepository creates a new instance of CartRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewCartRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *CartRepository {
+	mock := &CartRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -9,6 +9,8 @@ import (
 	mock "github.com/stretchr/testify/mock"
 
 	sqlx "github.com/jmoiron/sqlx"
+
+	time "time"
 )
 
 // OrderRepository is an autogenerated mock type for the OrderRepository type
@@ -46,8 +48,134 @@ func (_m *OrderRepository) GetOrderDetailTx(ctx context.Context, tx *sqlx.Tx, or
 	return r0, r1
 }
 
+// GetOrderItemsTx provides a mock function with given fields: ctx, tx, orderID
+func (_m *OrderRepository) GetOrderItemsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) ([]model.OrderItem, error) {
+	ret := _m.Called(ctx, tx, orderID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetOrderItemsTx")
+	}
+
+	var r0 []model.OrderItem
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) ([]model.OrderItem, error)); ok {
+		return rf(ctx, tx, orderID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) []model.OrderItem); ok {
+		r0 = rf(ctx, tx, orderID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.OrderItem)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, uint64) error); ok {
+		r1 = rf(ctx, tx, orderID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetProductPricesTx provides a mock function with given fields: ctx, tx, productIDs
+func (_m *OrderRepository) GetProductPricesTx(ctx context.Context, tx *sqlx.Tx, productIDs []uint64) (map[uint64]float64, error) {
+	ret := _m.Called(ctx, tx, productIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetProductPricesTx")
+	}
+
+	var r0 map[uint64]float64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, []uint64) (map[uint64]float64, error)); ok {
+		return rf(ctx, tx, productIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, []uint64) map[uint64]float64); ok {
+		r0 = rf(ctx, tx, productIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[uint64]float64)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, []uint64) error); ok {
+		r1 = rf(ctx, tx, productIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserAddressTx provides a mock function with given fields: ctx, tx, addressID
+func (_m *OrderRepository) GetUserAddressTx(ctx context.Context, tx *sqlx.Tx, addressID uint64) (*model.UserAddress, error) {
+	ret := _m.Called(ctx, tx, addressID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserAddressTx")
+	}
+
+	var r0 *model.UserAddress
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) (*model.UserAddress, error)); ok {
+		return rf(ctx, tx, addressID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) *model.UserAddress); ok {
+		r0 = rf(ctx, tx, addressID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserAddress)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, uint64) error); ok {
+		r1 = rf(ctx, tx, addressID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IncrementOutboxAttempts provides a mock function with given fields: ctx, id
+func (_m *OrderRepository) IncrementOutboxAttempts(ctx context.Context, id uint64) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IncrementOutboxAttempts")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// InsertOrderAddressTx provides a mock function with given fields: ctx, tx, orderID, addr
+func (_m *OrderRepository) InsertOrderAddressTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, addr *model.ShippingAddress) error {
+	ret := _m.Called(ctx, tx, orderID, addr)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InsertOrderAddressTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64, *model.ShippingAddress) error); ok {
+		r0 = rf(ctx, tx, orderID, addr)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // InsertOrderItemsTx provides a mock function with given fields: ctx, tx, orderID, items
-func (_m *OrderRepository) InsertOrderItemsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, items []model.OrderItemRequest) error {
+func (_m *OrderRepository) InsertOrderItemsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, items []model.OrderItem) error {
 	ret := _m.Called(ctx, tx, orderID, items)
 
 	if len(ret) == 0 {
@@ -55,7 +183,7 @@ func (_m *OrderRepository) InsertOrderItemsTx(ctx context.Context, tx *sqlx.Tx,
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64, []model.OrderItemRequest) error); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64, []model.OrderItem) error); ok {
 		r0 = rf(ctx, tx, orderID, items)
 	} else {
 		r0 = ret.Error(0)
@@ -92,6 +220,213 @@ func (_m *OrderRepository) InsertOrderTx(ctx context.Context, tx *sqlx.Tx, req *
 	return r0, r1
 }
 
+// InsertOutboxTx provides a mock function with given fields: ctx, tx, msg
+func (_m *OrderRepository) InsertOutboxTx(ctx context.Context, tx *sqlx.Tx, msg *model.OrderOutbox) (uint64, error) {
+	ret := _m.Called(ctx, tx, msg)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InsertOutboxTx")
+	}
+
+	var r0 uint64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, *model.OrderOutbox) (uint64, error)); ok {
+		return rf(ctx, tx, msg)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, *model.OrderOutbox) uint64); ok {
+		r0 = rf(ctx, tx, msg)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, *model.OrderOutbox) error); ok {
+		r1 = rf(ctx, tx, msg)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsUserVerified provides a mock function with given fields: ctx, userID
+func (_m *OrderRepository) IsUserVerified(ctx context.Context, userID uint64) (bool, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IsUserVerified")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) (bool, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) bool); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListExpiredPendingOrderIDs provides a mock function with given fields: ctx, limit
+func (_m *OrderRepository) ListExpiredPendingOrderIDs(ctx context.Context, limit int) ([]uint64, error) {
+	ret := _m.Called(ctx, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListExpiredPendingOrderIDs")
+	}
+
+	var r0 []uint64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]uint64, error)); ok {
+		return rf(ctx, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []uint64); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]uint64)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListOrdersByUser provides a mock function with given fields: ctx, filter, page, perPage
+func (_m *OrderRepository) ListOrdersByUser(ctx context.Context, filter model.OrderListFilter, page int, perPage int) ([]model.OrderSummary, int64, error) {
+	ret := _m.Called(ctx, filter, page, perPage)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListOrdersByUser")
+	}
+
+	var r0 []model.OrderSummary
+	var r1 int64
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.OrderListFilter, int, int) ([]model.OrderSummary, int64, error)); ok {
+		return rf(ctx, filter, page, perPage)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.OrderListFilter, int, int) []model.OrderSummary); ok {
+		r0 = rf(ctx, filter, page, perPage)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.OrderSummary)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.OrderListFilter, int, int) int64); ok {
+		r1 = rf(ctx, filter, page, perPage)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, model.OrderListFilter, int, int) error); ok {
+		r2 = rf(ctx, filter, page, perPage)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListUnsentOutbox provides a mock function with given fields: ctx, limit
+func (_m *OrderRepository) ListUnsentOutbox(ctx context.Context, limit int) ([]model.OrderOutbox, error) {
+	ret := _m.Called(ctx, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListUnsentOutbox")
+	}
+
+	var r0 []model.OrderOutbox
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]model.OrderOutbox, error)); ok {
+		return rf(ctx, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []model.OrderOutbox); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.OrderOutbox)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkOutboxSent provides a mock function with given fields: ctx, id
+func (_m *OrderRepository) MarkOutboxSent(ctx context.Context, id uint64) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkOutboxSent")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReleaseVoucherTx provides a mock function with given fields: ctx, tx, code
+func (_m *OrderRepository) ReleaseVoucherTx(ctx context.Context, tx *sqlx.Tx, code string) error {
+	ret := _m.Called(ctx, tx, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReleaseVoucherTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, string) error); ok {
+		r0 = rf(ctx, tx, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateOrderExpiresAtTx provides a mock function with given fields: ctx, tx, orderID, expiresAt
+func (_m *OrderRepository) UpdateOrderExpiresAtTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, expiresAt time.Time) error {
+	ret := _m.Called(ctx, tx, orderID, expiresAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateOrderExpiresAtTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64, time.Time) error); ok {
+		r0 = rf(ctx, tx, orderID, expiresAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateOrderStatusTx provides a mock function with given fields: ctx, tx, orderID, status
 func (_m *OrderRepository) UpdateOrderStatusTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, status int) error {
 	ret := _m.Called(ctx, tx, orderID, status)
@@ -110,6 +445,24 @@ func (_m *OrderRepository) UpdateOrderStatusTx(ctx context.Context, tx *sqlx.Tx,
 	return r0
 }
 
+// UseVoucherTx provides a mock function with given fields: ctx, tx, code
+func (_m *OrderRepository) UseVoucherTx(ctx context.Context, tx *sqlx.Tx, code string) error {
+	ret := _m.Called(ctx, tx, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UseVoucherTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, string) error); ok {
+		r0 = rf(ctx, tx, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewOrderRepository creates a new instance of OrderRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewOrderRepository(t interface {
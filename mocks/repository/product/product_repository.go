@@ -5,8 +5,11 @@ package mocks
 import (
 	context "context"
 
-	model "github.com/muhammadheryan/e-commerce/model"
+	constant "github.com/muhammadheryan/e-commerce/constant"
+
 	mock "github.com/stretchr/testify/mock"
+
+	model "github.com/muhammadheryan/e-commerce/model"
 )
 
 // ProductRepository is an autogenerated mock type for the ProductRepository type
@@ -14,6 +17,128 @@ type ProductRepository struct {
 	mock.Mock
 }
 
+// AddStockSubscription provides a mock function with given fields: ctx, userID, productID
+func (_m *ProductRepository) AddStockSubscription(ctx context.Context, userID uint64, productID uint64) error {
+	ret := _m.Called(ctx, userID, productID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddStockSubscription")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64) error); ok {
+		r0 = rf(ctx, userID, productID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Create provides a mock function with given fields: ctx, req
+func (_m *ProductRepository) Create(ctx context.Context, req *model.ProductRequest) (uint64, error) {
+	ret := _m.Called(ctx, req)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 uint64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ProductRequest) (uint64, error)); ok {
+		return rf(ctx, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ProductRequest) uint64); ok {
+		r0 = rf(ctx, req)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *model.ProductRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateReview provides a mock function with given fields: ctx, review
+func (_m *ProductRepository) CreateReview(ctx context.Context, review *model.ProductReview) (*model.ProductReview, error) {
+	ret := _m.Called(ctx, review)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateReview")
+	}
+
+	var r0 *model.ProductReview
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ProductReview) (*model.ProductReview, error)); ok {
+		return rf(ctx, review)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ProductReview) *model.ProductReview); ok {
+		r0 = rf(ctx, review)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ProductReview)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *model.ProductReview) error); ok {
+		r1 = rf(ctx, review)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteStockSubscription provides a mock function with given fields: ctx, userID, productID
+func (_m *ProductRepository) DeleteStockSubscription(ctx context.Context, userID uint64, productID uint64) error {
+	ret := _m.Called(ctx, userID, productID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteStockSubscription")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64) error); ok {
+		r0 = rf(ctx, userID, productID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetAvailableStock provides a mock function with given fields: ctx, id
+func (_m *ProductRepository) GetAvailableStock(ctx context.Context, id uint64) (int64, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAvailableStock")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) (int64, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) int64); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetByID provides a mock function with given fields: ctx, id
 func (_m *ProductRepository) GetByID(ctx context.Context, id uint64) (*model.ProductDetail, error) {
 	ret := _m.Called(ctx, id)
@@ -44,9 +169,67 @@ func (_m *ProductRepository) GetByID(ctx context.Context, id uint64) (*model.Pro
 	return r0, r1
 }
 
-// List provides a mock function with given fields: ctx, page, perPage
-func (_m *ProductRepository) List(ctx context.Context, page int, perPage int) ([]model.ProductListItem, int64, error) {
-	ret := _m.Called(ctx, page, perPage)
+// GetReviewStats provides a mock function with given fields: ctx, productID
+func (_m *ProductRepository) GetReviewStats(ctx context.Context, productID uint64) (*model.ReviewStats, error) {
+	ret := _m.Called(ctx, productID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetReviewStats")
+	}
+
+	var r0 *model.ReviewStats
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) (*model.ReviewStats, error)); ok {
+		return rf(ctx, productID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) *model.ReviewStats); ok {
+		r0 = rf(ctx, productID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ReviewStats)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, productID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HasCompletedPurchase provides a mock function with given fields: ctx, userID, productID
+func (_m *ProductRepository) HasCompletedPurchase(ctx context.Context, userID uint64, productID uint64) (bool, error) {
+	ret := _m.Called(ctx, userID, productID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for HasCompletedPurchase")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64) (bool, error)); ok {
+		return rf(ctx, userID, productID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64) bool); ok {
+		r0 = rf(ctx, userID, productID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, uint64) error); ok {
+		r1 = rf(ctx, userID, productID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, page, perPage, sort
+func (_m *ProductRepository) List(ctx context.Context, page int, perPage int, sort constant.ProductSort) ([]model.ProductListItem, int64, error) {
+	ret := _m.Called(ctx, page, perPage, sort)
 
 	if len(ret) == 0 {
 		panic("no return value specified for List")
@@ -55,25 +238,122 @@ func (_m *ProductRepository) List(ctx context.Context, page int, perPage int) ([
 	var r0 []model.ProductListItem
 	var r1 int64
 	var r2 error
-	if rf, ok := ret.Get(0).(func(context.Context, int, int) ([]model.ProductListItem, int64, error)); ok {
-		return rf(ctx, page, perPage)
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, constant.ProductSort) ([]model.ProductListItem, int64, error)); ok {
+		return rf(ctx, page, perPage, sort)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int, int) []model.ProductListItem); ok {
-		r0 = rf(ctx, page, perPage)
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, constant.ProductSort) []model.ProductListItem); ok {
+		r0 = rf(ctx, page, perPage, sort)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]model.ProductListItem)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, int, int) int64); ok {
-		r1 = rf(ctx, page, perPage)
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, constant.ProductSort) int64); ok {
+		r1 = rf(ctx, page, perPage, sort)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, int, int, constant.ProductSort) error); ok {
+		r2 = rf(ctx, page, perPage, sort)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListChangedSince provides a mock function with given fields: ctx, after, limit
+func (_m *ProductRepository) ListChangedSince(ctx context.Context, after model.ProductSyncCursor, limit int) ([]model.ProductSyncItem, error) {
+	ret := _m.Called(ctx, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListChangedSince")
+	}
+
+	var r0 []model.ProductSyncItem
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.ProductSyncCursor, int) ([]model.ProductSyncItem, error)); ok {
+		return rf(ctx, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.ProductSyncCursor, int) []model.ProductSyncItem); ok {
+		r0 = rf(ctx, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.ProductSyncItem)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.ProductSyncCursor, int) error); ok {
+		r1 = rf(ctx, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListFeed provides a mock function with given fields: ctx, afterID, limit
+func (_m *ProductRepository) ListFeed(ctx context.Context, afterID uint64, limit int) ([]model.ProductFeedItem, error) {
+	ret := _m.Called(ctx, afterID, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListFeed")
+	}
+
+	var r0 []model.ProductFeedItem
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, int) ([]model.ProductFeedItem, error)); ok {
+		return rf(ctx, afterID, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, int) []model.ProductFeedItem); ok {
+		r0 = rf(ctx, afterID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.ProductFeedItem)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, int) error); ok {
+		r1 = rf(ctx, afterID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListReviews provides a mock function with given fields: ctx, productID, page, perPage
+func (_m *ProductRepository) ListReviews(ctx context.Context, productID uint64, page int, perPage int) ([]model.ProductReview, int64, error) {
+	ret := _m.Called(ctx, productID, page, perPage)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListReviews")
+	}
+
+	var r0 []model.ProductReview
+	var r1 int64
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, int, int) ([]model.ProductReview, int64, error)); ok {
+		return rf(ctx, productID, page, perPage)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, int, int) []model.ProductReview); ok {
+		r0 = rf(ctx, productID, page, perPage)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.ProductReview)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, int, int) int64); ok {
+		r1 = rf(ctx, productID, page, perPage)
 	} else {
 		r1 = ret.Get(1).(int64)
 	}
 
-	if rf, ok := ret.Get(2).(func(context.Context, int, int) error); ok {
-		r2 = rf(ctx, page, perPage)
+	if rf, ok := ret.Get(2).(func(context.Context, uint64, int, int) error); ok {
+		r2 = rf(ctx, productID, page, perPage)
 	} else {
 		r2 = ret.Error(2)
 	}
@@ -81,6 +361,82 @@ func (_m *ProductRepository) List(ctx context.Context, page int, perPage int) ([
 	return r0, r1, r2
 }
 
+// ListStockSubscribers provides a mock function with given fields: ctx, productID
+func (_m *ProductRepository) ListStockSubscribers(ctx context.Context, productID uint64) ([]uint64, error) {
+	ret := _m.Called(ctx, productID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListStockSubscribers")
+	}
+
+	var r0 []uint64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) ([]uint64, error)); ok {
+		return rf(ctx, productID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) []uint64); ok {
+		r0 = rf(ctx, productID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]uint64)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, productID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SoftDelete provides a mock function with given fields: ctx, id
+func (_m *ProductRepository) SoftDelete(ctx context.Context, id uint64) (bool, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SoftDelete")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) (bool, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) bool); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Update provides a mock function with given fields: ctx, id, req
+func (_m *ProductRepository) Update(ctx context.Context, id uint64, req *model.ProductUpdateRequest) error {
+	ret := _m.Called(ctx, id, req)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Update")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, *model.ProductUpdateRequest) error); ok {
+		r0 = rf(ctx, id, req)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewProductRepository creates a new instance of ProductRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewProductRepository(t interface {
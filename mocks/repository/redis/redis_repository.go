@@ -5,6 +5,7 @@ package mocks
 import (
 	context "context"
 
+	model "github.com/muhammadheryan/e-commerce/model"
 	mock "github.com/stretchr/testify/mock"
 
 	time "time"
@@ -15,6 +16,34 @@ type RedisRepository struct {
 	mock.Mock
 }
 
+// AcquireLock provides a mock function with given fields: ctx, key, token, ttl
+func (_m *RedisRepository) AcquireLock(ctx context.Context, key string, token string, ttl time.Duration) (bool, error) {
+	ret := _m.Called(ctx, key, token, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AcquireLock")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Duration) (bool, error)); ok {
+		return rf(ctx, key, token, ttl)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Duration) bool); ok {
+		r0 = rf(ctx, key, token, ttl)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Duration) error); ok {
+		r1 = rf(ctx, key, token, ttl)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Delete provides a mock function with given fields: ctx, key
 func (_m *RedisRepository) Delete(ctx context.Context, key string) error {
 	ret := _m.Called(ctx, key)
@@ -33,6 +62,24 @@ func (_m *RedisRepository) Delete(ctx context.Context, key string) error {
 	return r0
 }
 
+// DeleteAllSessions provides a mock function with given fields: ctx, userID
+func (_m *RedisRepository) DeleteAllSessions(ctx context.Context, userID uint64) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteAllSessions")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteSession provides a mock function with given fields: ctx, sessionID
 func (_m *RedisRepository) DeleteSession(ctx context.Context, sessionID string) error {
 	ret := _m.Called(ctx, sessionID)
@@ -107,6 +154,54 @@ func (_m *RedisRepository) GetSession(ctx context.Context, sessionID string) (ui
 	return r0, r1
 }
 
+// ListSessions provides a mock function with given fields: ctx, userID
+func (_m *RedisRepository) ListSessions(ctx context.Context, userID uint64) ([]model.Session, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListSessions")
+	}
+
+	var r0 []model.Session
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) ([]model.Session, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) []model.Session); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Session)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReleaseLock provides a mock function with given fields: ctx, key, token
+func (_m *RedisRepository) ReleaseLock(ctx context.Context, key string, token string) error {
+	ret := _m.Called(ctx, key, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReleaseLock")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, key, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Set provides a mock function with given fields: ctx, key, value
 func (_m *RedisRepository) Set(ctx context.Context, key string, value interface{}) error {
 	ret := _m.Called(ctx, key, value)
@@ -143,6 +238,24 @@ func (_m *RedisRepository) SetSession(ctx context.Context, sessionID string, use
 	return r0
 }
 
+// SetVerificationToken provides a mock function with given fields: ctx, token, userID, ttl
+func (_m *RedisRepository) SetVerificationToken(ctx context.Context, token string, userID uint64, ttl time.Duration) error {
+	ret := _m.Called(ctx, token, userID, ttl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetVerificationToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint64, time.Duration) error); ok {
+		r0 = rf(ctx, token, userID, ttl)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetWithTTL provides a mock function with given fields: ctx, key, value, ttl
 func (_m *RedisRepository) SetWithTTL(ctx context.Context, key string, value string, ttl time.Duration) error {
 	ret := _m.Called(ctx, key, value, ttl)
@@ -161,6 +274,34 @@ func (_m *RedisRepository) SetWithTTL(ctx context.Context, key string, value str
 	return r0
 }
 
+// TakeVerificationToken provides a mock function with given fields: ctx, token
+func (_m *RedisRepository) TakeVerificationToken(ctx context.Context, token string) (uint64, error) {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TakeVerificationToken")
+	}
+
+	var r0 uint64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (uint64, error)); ok {
+		return rf(ctx, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) uint64); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewRedisRepository creates a new instance of RedisRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRedisRepository(t interface {
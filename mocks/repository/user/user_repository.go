@@ -7,6 +7,8 @@ import (
 
 	model "github.com/muhammadheryan/e-commerce/model"
 	mock "github.com/stretchr/testify/mock"
+
+	sqlx "github.com/jmoiron/sqlx"
 )
 
 // UserRepository is an autogenerated mock type for the UserRepository type
@@ -14,29 +16,59 @@ type UserRepository struct {
 	mock.Mock
 }
 
-// Create provides a mock function with given fields: ctx, req
-func (_m *UserRepository) Create(ctx context.Context, req *model.UserEntity) (*model.UserEntity, error) {
-	ret := _m.Called(ctx, req)
+// CreateAddress provides a mock function with given fields: ctx, addr
+func (_m *UserRepository) CreateAddress(ctx context.Context, addr *model.UserAddress) (*model.UserAddress, error) {
+	ret := _m.Called(ctx, addr)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateAddress")
+	}
+
+	var r0 *model.UserAddress
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.UserAddress) (*model.UserAddress, error)); ok {
+		return rf(ctx, addr)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *model.UserAddress) *model.UserAddress); ok {
+		r0 = rf(ctx, addr)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserAddress)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *model.UserAddress) error); ok {
+		r1 = rf(ctx, addr)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateTx provides a mock function with given fields: ctx, tx, req
+func (_m *UserRepository) CreateTx(ctx context.Context, tx *sqlx.Tx, req *model.UserEntity) (*model.UserEntity, error) {
+	ret := _m.Called(ctx, tx, req)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Create")
+		panic("no return value specified for CreateTx")
 	}
 
 	var r0 *model.UserEntity
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, *model.UserEntity) (*model.UserEntity, error)); ok {
-		return rf(ctx, req)
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, *model.UserEntity) (*model.UserEntity, error)); ok {
+		return rf(ctx, tx, req)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, *model.UserEntity) *model.UserEntity); ok {
-		r0 = rf(ctx, req)
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, *model.UserEntity) *model.UserEntity); ok {
+		r0 = rf(ctx, tx, req)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.UserEntity)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, *model.UserEntity) error); ok {
-		r1 = rf(ctx, req)
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, *model.UserEntity) error); ok {
+		r1 = rf(ctx, tx, req)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -44,6 +76,24 @@ func (_m *UserRepository) Create(ctx context.Context, req *model.UserEntity) (*m
 	return r0, r1
 }
 
+// DeleteAddress provides a mock function with given fields: ctx, userID, addressID
+func (_m *UserRepository) DeleteAddress(ctx context.Context, userID uint64, addressID uint64) error {
+	ret := _m.Called(ctx, userID, addressID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteAddress")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64) error); ok {
+		r0 = rf(ctx, userID, addressID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Get provides a mock function with given fields: ctx, filter
 func (_m *UserRepository) Get(ctx context.Context, filter *model.UserFilter) (*model.UserEntity, error) {
 	ret := _m.Called(ctx, filter)
@@ -74,6 +124,150 @@ func (_m *UserRepository) Get(ctx context.Context, filter *model.UserFilter) (*m
 	return r0, r1
 }
 
+// GetAddress provides a mock function with given fields: ctx, addressID
+func (_m *UserRepository) GetAddress(ctx context.Context, addressID uint64) (*model.UserAddress, error) {
+	ret := _m.Called(ctx, addressID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAddress")
+	}
+
+	var r0 *model.UserAddress
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) (*model.UserAddress, error)); ok {
+		return rf(ctx, addressID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) *model.UserAddress); ok {
+		r0 = rf(ctx, addressID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.UserAddress)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, addressID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetNotificationPrefs provides a mock function with given fields: ctx, userID
+func (_m *UserRepository) GetNotificationPrefs(ctx context.Context, userID uint64) (*model.NotificationPrefs, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetNotificationPrefs")
+	}
+
+	var r0 *model.NotificationPrefs
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) (*model.NotificationPrefs, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) *model.NotificationPrefs); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.NotificationPrefs)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListAddresses provides a mock function with given fields: ctx, userID
+func (_m *UserRepository) ListAddresses(ctx context.Context, userID uint64) ([]model.UserAddress, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListAddresses")
+	}
+
+	var r0 []model.UserAddress
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) ([]model.UserAddress, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) []model.UserAddress); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.UserAddress)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkVerified provides a mock function with given fields: ctx, userID
+func (_m *UserRepository) MarkVerified(ctx context.Context, userID uint64) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkVerified")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAddress provides a mock function with given fields: ctx, addr
+func (_m *UserRepository) UpdateAddress(ctx context.Context, addr *model.UserAddress) error {
+	ret := _m.Called(ctx, addr)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateAddress")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.UserAddress) error); ok {
+		r0 = rf(ctx, addr)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpsertNotificationPrefs provides a mock function with given fields: ctx, userID, prefs
+func (_m *UserRepository) UpsertNotificationPrefs(ctx context.Context, userID uint64, prefs *model.NotificationPrefs) error {
+	ret := _m.Called(ctx, userID, prefs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpsertNotificationPrefs")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, *model.NotificationPrefs) error); ok {
+		r0 = rf(ctx, userID, prefs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewUserRepository creates a new instance of UserRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUserRepository(t interface {
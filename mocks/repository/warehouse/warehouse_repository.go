@@ -19,6 +19,24 @@ type WarehouseRepository struct {
 	mock.Mock
 }
 
+// AdjustStockTx provides a mock function with given fields: ctx, tx, req
+func (_m *WarehouseRepository) AdjustStockTx(ctx context.Context, tx *sqlx.Tx, req *model.StockAdjustmentRequest) error {
+	ret := _m.Called(ctx, tx, req)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AdjustStockTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, *model.StockAdjustmentRequest) error); ok {
+		r0 = rf(ctx, tx, req)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CheckReservedStock provides a mock function with given fields: ctx, warehouseID
 func (_m *WarehouseRepository) CheckReservedStock(ctx context.Context, warehouseID uint64) (int64, error) {
 	ret := _m.Called(ctx, warehouseID)
@@ -65,6 +83,66 @@ func (_m *WarehouseRepository) CommitReservationsTx(ctx context.Context, tx *sql
 	return r0
 }
 
+// GetCartReservedQuantities provides a mock function with given fields: ctx, userID
+func (_m *WarehouseRepository) GetCartReservedQuantities(ctx context.Context, userID uint64) (map[uint64]int64, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCartReservedQuantities")
+	}
+
+	var r0 map[uint64]int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) (map[uint64]int64, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) map[uint64]int64); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[uint64]int64)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFulfillmentByOrderTx provides a mock function with given fields: ctx, tx, orderID
+func (_m *WarehouseRepository) GetFulfillmentByOrderTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) ([]model.OrderFulfillment, error) {
+	ret := _m.Called(ctx, tx, orderID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetFulfillmentByOrderTx")
+	}
+
+	var r0 []model.OrderFulfillment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) ([]model.OrderFulfillment, error)); ok {
+		return rf(ctx, tx, orderID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) []model.OrderFulfillment); ok {
+		r0 = rf(ctx, tx, orderID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.OrderFulfillment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, uint64) error); ok {
+		r1 = rf(ctx, tx, orderID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetReservationsByOrderTx provides a mock function with given fields: ctx, tx, orderID
 func (_m *WarehouseRepository) GetReservationsByOrderTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) ([]model.Reservation, error) {
 	ret := _m.Called(ctx, tx, orderID)
@@ -95,6 +173,34 @@ func (_m *WarehouseRepository) GetReservationsByOrderTx(ctx context.Context, tx
 	return r0, r1
 }
 
+// GetTotalAvailableStock provides a mock function with given fields: ctx, productID
+func (_m *WarehouseRepository) GetTotalAvailableStock(ctx context.Context, productID uint64) (int64, error) {
+	ret := _m.Called(ctx, productID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTotalAvailableStock")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) (int64, error)); ok {
+		return rf(ctx, productID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) int64); ok {
+		r0 = rf(ctx, productID)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, productID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetTotalAvailableStockTx provides a mock function with given fields: ctx, tx, productID
 func (_m *WarehouseRepository) GetTotalAvailableStockTx(ctx context.Context, tx *sqlx.Tx, productID uint64) (int64, error) {
 	ret := _m.Called(ctx, tx, productID)
@@ -183,6 +289,211 @@ func (_m *WarehouseRepository) GetWarehouseStock(ctx context.Context, warehouseI
 	return r0, r1
 }
 
+// GetWarehouseStocksTx provides a mock function with given fields: ctx, tx, warehouseID
+func (_m *WarehouseRepository) GetWarehouseStocksTx(ctx context.Context, tx *sqlx.Tx, warehouseID uint64) ([]model.WarehouseStock, error) {
+	ret := _m.Called(ctx, tx, warehouseID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetWarehouseStocksTx")
+	}
+
+	var r0 []model.WarehouseStock
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) ([]model.WarehouseStock, error)); ok {
+		return rf(ctx, tx, warehouseID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) []model.WarehouseStock); ok {
+		r0 = rf(ctx, tx, warehouseID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.WarehouseStock)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, uint64) error); ok {
+		r1 = rf(ctx, tx, warehouseID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListLowStockProducts provides a mock function with given fields: ctx, threshold
+func (_m *WarehouseRepository) ListLowStockProducts(ctx context.Context, threshold int64) ([]model.LowStockItem, error) {
+	ret := _m.Called(ctx, threshold)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListLowStockProducts")
+	}
+
+	var r0 []model.LowStockItem
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) ([]model.LowStockItem, error)); ok {
+		return rf(ctx, threshold)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []model.LowStockItem); ok {
+		r0 = rf(ctx, threshold)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.LowStockItem)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, threshold)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListReservationHolders provides a mock function with given fields: ctx, warehouseID
+func (_m *WarehouseRepository) ListReservationHolders(ctx context.Context, warehouseID uint64) ([]model.ID, []model.ID, error) {
+	ret := _m.Called(ctx, warehouseID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListReservationHolders")
+	}
+
+	var r0 []model.ID
+	var r1 []model.ID
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) ([]model.ID, []model.ID, error)); ok {
+		return rf(ctx, warehouseID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) []model.ID); ok {
+		r0 = rf(ctx, warehouseID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.ID)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) []model.ID); ok {
+		r1 = rf(ctx, warehouseID)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]model.ID)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, uint64) error); ok {
+		r2 = rf(ctx, warehouseID)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ListWarehouseSummaries provides a mock function with given fields: ctx, shopID
+func (_m *WarehouseRepository) ListWarehouseSummaries(ctx context.Context, shopID uint64) ([]model.WarehouseSummary, error) {
+	ret := _m.Called(ctx, shopID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListWarehouseSummaries")
+	}
+
+	var r0 []model.WarehouseSummary
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) ([]model.WarehouseSummary, error)); ok {
+		return rf(ctx, shopID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64) []model.WarehouseSummary); ok {
+		r0 = rf(ctx, shopID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.WarehouseSummary)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
+		r1 = rf(ctx, shopID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReconcileReservedTx provides a mock function with given fields: ctx, tx, warehouseID
+func (_m *WarehouseRepository) ReconcileReservedTx(ctx context.Context, tx *sqlx.Tx, warehouseID uint64) ([]model.ReservedDiscrepancy, error) {
+	ret := _m.Called(ctx, tx, warehouseID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReconcileReservedTx")
+	}
+
+	var r0 []model.ReservedDiscrepancy
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) ([]model.ReservedDiscrepancy, error)); ok {
+		return rf(ctx, tx, warehouseID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64) []model.ReservedDiscrepancy); ok {
+		r0 = rf(ctx, tx, warehouseID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.ReservedDiscrepancy)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, uint64) error); ok {
+		r1 = rf(ctx, tx, warehouseID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReleaseCartReservationsTx provides a mock function with given fields: ctx, tx, cartID, productID
+func (_m *WarehouseRepository) ReleaseCartReservationsTx(ctx context.Context, tx *sqlx.Tx, cartID uint64, productID uint64) error {
+	ret := _m.Called(ctx, tx, cartID, productID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReleaseCartReservationsTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64, uint64) error); ok {
+		r0 = rf(ctx, tx, cartID, productID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReleaseExpiredCartReservationsTx provides a mock function with given fields: ctx, tx, limit
+func (_m *WarehouseRepository) ReleaseExpiredCartReservationsTx(ctx context.Context, tx *sqlx.Tx, limit int) (int, error) {
+	ret := _m.Called(ctx, tx, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReleaseExpiredCartReservationsTx")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, int) (int, error)); ok {
+		return rf(ctx, tx, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, int) int); ok {
+		r0 = rf(ctx, tx, limit)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *sqlx.Tx, int) error); ok {
+		r1 = rf(ctx, tx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ReleaseReservationsTx provides a mock function with given fields: ctx, tx, orderID
 func (_m *WarehouseRepository) ReleaseReservationsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) error {
 	ret := _m.Called(ctx, tx, orderID)
@@ -219,6 +530,24 @@ func (_m *WarehouseRepository) ReserveStockTx(ctx context.Context, tx *sqlx.Tx,
 	return r0
 }
 
+// RestockFulfillmentTx provides a mock function with given fields: ctx, tx, fulfillment
+func (_m *WarehouseRepository) RestockFulfillmentTx(ctx context.Context, tx *sqlx.Tx, fulfillment []model.OrderFulfillment) error {
+	ret := _m.Called(ctx, tx, fulfillment)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestockFulfillmentTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, []model.OrderFulfillment) error); ok {
+		r0 = rf(ctx, tx, fulfillment)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // TransferStockTx provides a mock function with given fields: ctx, tx, req
 func (_m *WarehouseRepository) TransferStockTx(ctx context.Context, tx *sqlx.Tx, req *model.TransferStockRequest) error {
 	ret := _m.Called(ctx, tx, req)
@@ -255,6 +584,24 @@ func (_m *WarehouseRepository) UpdateWarehouseStatus(ctx context.Context, wareho
 	return r0
 }
 
+// UpdateWarehouseStatusTx provides a mock function with given fields: ctx, tx, warehouseID, status
+func (_m *WarehouseRepository) UpdateWarehouseStatusTx(ctx context.Context, tx *sqlx.Tx, warehouseID uint64, status constant.WarehouseStatus) error {
+	ret := _m.Called(ctx, tx, warehouseID, status)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateWarehouseStatusTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *sqlx.Tx, uint64, constant.WarehouseStatus) error); ok {
+		r0 = rf(ctx, tx, warehouseID, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewWarehouseRepository creates a new instance of WarehouseRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewWarehouseRepository(t interface {
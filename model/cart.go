@@ -0,0 +1,26 @@
+package model
+
+type CartItemRequest struct {
+	ProductID ID  `json:"product_id" validate:"required"`
+	Quantity  int `json:"quantity" validate:"required,gt=0"`
+}
+
+type CartItemUpdateRequest struct {
+	Quantity int `json:"quantity" validate:"required,gt=0"`
+}
+
+type CartItem struct {
+	ProductID ID      `db:"product_id" json:"product_id"`
+	Name      string  `db:"name" json:"name"`
+	Price     float64 `db:"price" json:"price"`
+	Quantity  int     `db:"quantity" json:"quantity"`
+
+	// availability at read time, nothing is reserved for cart items
+	AvailableStock    int64 `db:"-" json:"available_stock"`
+	InsufficientStock bool  `db:"-" json:"insufficient_stock"`
+}
+
+type CartResponse struct {
+	Items    []CartItem `json:"items"`
+	Subtotal float64    `json:"subtotal"`
+}
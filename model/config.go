@@ -0,0 +1,100 @@
+package model
+
+// EffectiveConfig is the non-secret subset of the running configuration.
+// Fields are listed explicitly so secrets never leak in by accident.
+type EffectiveConfig struct {
+	Environment string                   `json:"environment"`
+	ProjectName string                   `json:"project_name"`
+	Server      EffectiveServerConfig    `json:"server"`
+	Database    EffectiveDatabaseConfig  `json:"database"`
+	Auth        EffectiveAuthConfig      `json:"auth"`
+	Order       EffectiveOrderConfig     `json:"order"`
+	RabbitMQ    EffectiveRabbitMQConfig  `json:"rabbitmq"`
+	Store       EffectiveStoreConfig     `json:"store"`
+	Cart        EffectiveCartConfig      `json:"cart"`
+	Product     EffectiveProductConfig   `json:"product"`
+	Warehouse   EffectiveWarehouseConfig `json:"warehouse"`
+}
+
+type EffectiveServerConfig struct {
+	Port                   string   `json:"port"`
+	ReadTimeoutSeconds     int64    `json:"read_timeout_seconds"`
+	WriteTimeoutSeconds    int64    `json:"write_timeout_seconds"`
+	IdleTimeoutSeconds     int64    `json:"idle_timeout_seconds"`
+	ShutdownTimeoutSeconds int64    `json:"shutdown_timeout_seconds"`
+	RequestTimeoutSeconds  int64    `json:"request_timeout_seconds"`
+	CORSAllowedOrigins     []string `json:"cors_allowed_origins"`
+	RateLimitRPS           float64  `json:"rate_limit_rps"`
+	RateLimitBurst         int      `json:"rate_limit_burst"`
+	CompressionEnabled     bool     `json:"compression_enabled"`
+	CompressionMinBytes    int      `json:"compression_min_bytes"`
+	BodyLogEnabled         bool     `json:"body_log_enabled"`
+	BodyLogPaths           []string `json:"body_log_paths"`
+	MaxPerPage             int      `json:"max_per_page"`
+}
+
+type EffectiveDatabaseConfig struct {
+	MaxOpenConns           int   `json:"max_open_conns"`
+	MaxIdleConns           int   `json:"max_idle_conns"`
+	ConnMaxLifetimeSeconds int64 `json:"conn_max_lifetime_seconds"`
+	QueryTimeoutSeconds    int64 `json:"query_timeout_seconds"`
+	ConnectRetries         int   `json:"connect_retries"`
+	ConnectBackoffSeconds  int64 `json:"connect_backoff_seconds"`
+}
+
+type EffectiveAuthConfig struct {
+	JWTExpirationSeconds     int64 `json:"jwt_expiration_seconds"`
+	SessionExpirationSeconds int64 `json:"session_expiration_seconds"`
+	SingleSession            bool  `json:"single_session"`
+	// key ids only, the secrets themselves are never exposed
+	JWTKeyID          string   `json:"jwt_key_id"`
+	JWTAcceptedKeyIDs []string `json:"jwt_accepted_key_ids"`
+	// the cost in use, after falling back from an out of range setting
+	BcryptCost               int   `json:"bcrypt_cost"`
+	VerificationTokenSeconds int64 `json:"verification_token_seconds"`
+	RequireVerifiedEmail     bool  `json:"require_verified_email"`
+}
+
+type EffectiveOrderConfig struct {
+	OrderExpirationSeconds        int64   `json:"order_expiration_seconds"`
+	MaxOrderValue                 float64 `json:"max_order_value"`
+	HighValueAction               string  `json:"high_value_action"`
+	ExpirationStrategy            string  `json:"expiration_strategy"`
+	ExpirationPollIntervalSeconds int64   `json:"expiration_poll_interval_seconds"`
+	OutboxRelayIntervalSeconds    int64   `json:"outbox_relay_interval_seconds"`
+	MaxItems                      int     `json:"max_items"`
+	MaxQuantityPerItem            int     `json:"max_quantity_per_item"`
+	MaxBodyBytes                  int64   `json:"max_body_bytes"`
+	MaxDecodedItems               int     `json:"max_decoded_items"`
+	ProductLock                   bool    `json:"product_lock"`
+	ProductLockTTLSeconds         int64   `json:"product_lock_ttl_seconds"`
+	ProductLockWaitSeconds        int64   `json:"product_lock_wait_seconds"`
+}
+
+type EffectiveRabbitMQConfig struct {
+	MaxRedeliveries      int  `json:"max_redeliveries"`
+	ConsumerWorkers      int  `json:"consumer_workers"`
+	MaxConcurrentCancels int  `json:"max_concurrent_cancels"`
+	DeadLetterAlert      bool `json:"dead_letter_alert"`
+}
+
+type EffectiveStoreConfig struct {
+	TaxRate      float64 `json:"tax_rate"`
+	TaxInclusive bool    `json:"tax_inclusive"`
+	Currency     string  `json:"currency"`
+}
+
+type EffectiveCartConfig struct {
+	ReserveOnAdd                    bool  `json:"reserve_on_add"`
+	ReservationTTLSeconds           int64 `json:"reservation_ttl_seconds"`
+	ReservationSweepIntervalSeconds int64 `json:"reservation_sweep_interval_seconds"`
+}
+
+type EffectiveProductConfig struct {
+	LowStockThreshold int64 `json:"low_stock_threshold"`
+	CacheTTLSeconds   int64 `json:"cache_ttl_seconds"`
+}
+
+type EffectiveWarehouseConfig struct {
+	AllocationStrategy string `json:"allocation_strategy"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// ConsumerStatus reports whether the order expiration consumer is alive and keeping up
+type ConsumerStatus struct {
+	// Connected is whether the consumer's connection and channel are open
+	Connected bool `json:"connected"`
+	// LastProcessedAt is when the last message was handled, nil before the first one
+	LastProcessedAt *time.Time `json:"last_processed_at"`
+	// QueueDepth is the backlog of expiration messages ready for delivery
+	QueueDepth int `json:"queue_depth"`
+	// QueueConsumers is how many consumers the broker sees on the queue
+	QueueConsumers int `json:"queue_consumers"`
+}
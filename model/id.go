@@ -0,0 +1,38 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// maxSafeID is the largest integer a JavaScript number holds exactly (2^53-1)
+const maxSafeID = 1<<53 - 1
+
+// ID is a user, product, order or warehouse id. It is written as a JSON number
+// while a JavaScript client can hold it exactly and as a string above that, so
+// large ids survive JSON.parse. Both forms are accepted when decoding.
+type ID uint64
+
+func (id ID) MarshalJSON() ([]byte, error) {
+	if id > maxSafeID {
+		return []byte(`"` + strconv.FormatUint(uint64(id), 10) + `"`), nil
+	}
+	return strconv.AppendUint(nil, uint64(id), 10), nil
+}
+
+func (id *ID) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	raw := string(data)
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		raw = string(data[1 : len(data)-1])
+	}
+	v, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid id %s", data)
+	}
+	*id = ID(v)
+	return nil
+}
@@ -0,0 +1,89 @@
+package model_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/model"
+)
+
+func TestID_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		id   model.ID
+		want string
+	}{
+		{"zero", 0, `0`},
+		{"small", 42, `42`},
+		{"largest safe integer", 1<<53 - 1, `9007199254740991`},
+		{"past safe integer", 1<<53 + 1, `"9007199254740993"`},
+		{"max uint64", math.MaxUint64, `"18446744073709551615"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.id)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestID_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    model.ID
+		wantErr bool
+	}{
+		{"number", `42`, 42, false},
+		{"string", `"42"`, 42, false},
+		{"large number", `18446744073709551615`, math.MaxUint64, false},
+		{"large string", `"9007199254740993"`, 1<<53 + 1, false},
+		{"negative", `-1`, 0, true},
+		{"fraction", `1.5`, 0, true},
+		{"empty string", `""`, 0, true},
+		{"not a number", `"abc"`, 0, true},
+		{"overflow", `"18446744073709551616"`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got model.ID
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("Unmarshal() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestID_RoundTrip(t *testing.T) {
+	in := model.OrderResponse{OrderID: math.MaxUint64 - 1}
+	raw, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var out model.OrderResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal(%s) error = %v", raw, err)
+	}
+	if out.OrderID != in.OrderID {
+		t.Fatalf("round trip order_id = %d, want %d (json %s)", out.OrderID, in.OrderID, raw)
+	}
+
+	var req model.TransferStockHTTPRequest
+	if err := json.Unmarshal([]byte(`{"product_id":"18446744073709551615","from_warehouse_id":1,"to_warehouse_id":"2","quantity":3}`), &req); err != nil {
+		t.Fatalf("Unmarshal() mixed id forms error = %v", err)
+	}
+	if req.ProductID != math.MaxUint64 || req.FromWarehouseID != 1 || req.ToWarehouseID != 2 {
+		t.Fatalf("mixed id forms decoded to %+v", req)
+	}
+}
@@ -7,27 +7,125 @@ import (
 )
 
 type OrderItemRequest struct {
-	ProductID uint64 `json:"product_id" validate:"required"`
-	Quantity  int    `json:"quantity" validate:"required,gt=0"`
+	ProductID ID  `json:"product_id" validate:"required"`
+	Quantity  int `json:"quantity" validate:"required,gt=0"`
+}
+
+// OrderItem is an ordered product with the unit price captured when the order
+// was placed, later product price changes don't affect it
+type OrderItem struct {
+	ProductID ID      `json:"product_id" db:"product_id"`
+	Quantity  int     `json:"quantity" db:"quantity"`
+	UnitPrice float64 `json:"unit_price" db:"unit_price"`
+}
+
+type ShippingAddress struct {
+	RecipientName string `json:"recipient_name" db:"recipient_name" validate:"required"`
+	Phone         string `json:"phone" db:"phone" validate:"required,id_phone"`
+	AddressLine   string `json:"address_line" db:"address_line" validate:"required"`
+	City          string `json:"city" db:"city" validate:"required"`
+	Province      string `json:"province" db:"province" validate:"required"`
+	PostalCode    string `json:"postal_code" db:"postal_code" validate:"required"`
 }
 
 type OrderRequest struct {
-	Items []OrderItemRequest `json:"items" validate:"required,dive,required"`
+	// Items lists each product once, duplicates are rejected instead of merged
+	Items           []OrderItemRequest `json:"items" validate:"required_without=FromCart,dive,required"`
+	VoucherCode     string             `json:"voucher_code,omitempty"`
+	ShippingAddress *ShippingAddress   `json:"shipping_address,omitempty"`
+	AddressID       ID                 `json:"address_id,omitempty"`
+	FromCart        bool               `json:"from_cart,omitempty"`
 }
 
 type OrderResponse struct {
-	OrderID   uint64    `json:"order_id"`
-	ExpiresAt time.Time `json:"expires_at"`
+	OrderID    ID                   `json:"order_id"`
+	Status     constant.OrderStatus `json:"status"`
+	Subtotal   float64              `json:"subtotal"`
+	TaxAmount  float64              `json:"tax_amount"`
+	GrandTotal float64              `json:"grand_total"`
+	Currency   string               `json:"currency"`
+	ExpiresAt  time.Time            `json:"expires_at"`
+	Items      []OrderItem          `json:"items"`
+
+	ShippingAddress *ShippingAddress `json:"shipping_address,omitempty"`
+}
+
+// OrderPreviewRequest lists the items to quote, like the items of an OrderRequest
+type OrderPreviewRequest struct {
+	Items []OrderItemRequest `json:"items" validate:"required,dive,required"`
+}
+
+// OrderPreviewResponse is the quote of an order that isn't placed
+type OrderPreviewResponse struct {
+	Subtotal   float64     `json:"subtotal"`
+	TaxAmount  float64     `json:"tax_amount"`
+	GrandTotal float64     `json:"grand_total"`
+	Currency   string      `json:"currency"`
+	Items      []OrderItem `json:"items"`
 }
 
 type InsertOrderTxItem struct {
-	UserID    uint64
-	Status    constant.OrderStatus
-	ExpiresAT time.Time
+	UserID     uint64
+	Status     constant.OrderStatus
+	Subtotal   float64
+	TaxAmount  float64
+	GrandTotal float64
+	Currency   string
+	// VoucherCode is the voucher redeemed by the order, empty when none was
+	VoucherCode string
+	ExpiresAT   time.Time
 }
 
 type OrderDetail struct {
 	ID     uint64               `db:"id"`
 	UserID uint64               `db:"user_id"`
 	Status constant.OrderStatus `db:"status"`
+	// VoucherCode is the voucher the order redeemed, its usage is given back on cancel
+	VoucherCode string `db:"voucher_code"`
+	// Items is loaded separately, see OrderRepository.GetOrderItemsTx
+	Items []OrderItem `db:"-"`
+	// AlreadyApplied is set by a pay or cancel that found the order in the
+	// state it asked for and changed nothing
+	AlreadyApplied bool `db:"-"`
+}
+
+// OrderActionResponse echoes the order a pay or cancel acted on
+type OrderActionResponse struct {
+	OrderID ID          `json:"order_id"`
+	Status  string      `json:"status"`
+	Items   []OrderItem `json:"items"`
+	// AlreadyApplied tells a retried request apart from the one that changed the order
+	AlreadyApplied bool `json:"already_applied"`
+}
+
+// OrderOutbox is an expiration message waiting to be relayed to the broker
+type OrderOutbox struct {
+	ID        uint64    `db:"id"`
+	OrderID   uint64    `db:"order_id"`
+	UserID    uint64    `db:"user_id"`
+	ExpiresAt time.Time `db:"expires_at"`
+	Attempts  int       `db:"attempts"`
+}
+
+// OrderListFilter narrows a user's order list, a nil Status returns every status
+type OrderListFilter struct {
+	UserID uint64
+	Status *constant.OrderStatus
+}
+
+type OrderSummary struct {
+	ID         ID                   `db:"id" json:"id"`
+	Status     constant.OrderStatus `db:"status" json:"status"`
+	Subtotal   float64              `db:"subtotal" json:"subtotal"`
+	TaxAmount  float64              `db:"tax_amount" json:"tax_amount"`
+	GrandTotal float64              `db:"grand_total" json:"grand_total"`
+	Currency   string               `db:"currency" json:"currency"`
+	CreatedAt  time.Time            `db:"created_at" json:"created_at"`
+	ExpiresAt  *time.Time           `db:"expires_at" json:"expires_at,omitempty"`
+}
+
+// OrderListResponse mirrors Paginated[OrderSummary] for the swagger docs,
+// which can't resolve generic instantiations in this setup
+type OrderListResponse struct {
+	Paginated[OrderSummary]
 }
@@ -0,0 +1,33 @@
+package model
+
+// Paginated is a page of items together with the total count of the
+// unpaginated result, computed with the same filter as the page.
+type Paginated[T any] struct {
+	Items      []T   `json:"items"`
+	TotalCount int64 `json:"total_count"`
+	Page       int   `json:"page"`
+	PerPage    int   `json:"per_page"`
+	PageMeta
+}
+
+// PageMeta spares clients the page arithmetic of a paginated list
+type PageMeta struct {
+	TotalPages int  `json:"total_pages"`
+	HasNext    bool `json:"has_next"`
+	HasPrev    bool `json:"has_prev"`
+}
+
+// NewPageMeta describes page of a list of total items, perPage items a page.
+// An empty list has no pages, a page past the last one still has a previous
+// page but no next one.
+func NewPageMeta(page, perPage int, total int64) PageMeta {
+	if perPage <= 0 || total <= 0 {
+		return PageMeta{HasPrev: page > 1}
+	}
+	totalPages := int((total + int64(perPage) - 1) / int64(perPage))
+	return PageMeta{
+		TotalPages: totalPages,
+		HasNext:    page < totalPages,
+		HasPrev:    page > 1,
+	}
+}
@@ -0,0 +1,34 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/model"
+)
+
+func TestNewPageMeta(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    int
+		perPage int
+		total   int64
+		want    model.PageMeta
+	}{
+		{"first page", 1, 10, 25, model.PageMeta{TotalPages: 3, HasNext: true}},
+		{"middle page", 2, 10, 25, model.PageMeta{TotalPages: 3, HasNext: true, HasPrev: true}},
+		{"last page", 3, 10, 25, model.PageMeta{TotalPages: 3, HasPrev: true}},
+		{"last page exactly full", 3, 10, 30, model.PageMeta{TotalPages: 3, HasPrev: true}},
+		{"beyond last page", 5, 10, 25, model.PageMeta{TotalPages: 3, HasPrev: true}},
+		{"only page", 1, 10, 10, model.PageMeta{TotalPages: 1}},
+		{"empty list", 1, 10, 0, model.PageMeta{}},
+		{"empty list past the first page", 2, 10, 0, model.PageMeta{HasPrev: true}},
+		{"zero per page", 1, 0, 25, model.PageMeta{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := model.NewPageMeta(tt.page, tt.perPage, tt.total); got != tt.want {
+				t.Fatalf("NewPageMeta(%d, %d, %d) = %+v, want %+v", tt.page, tt.perPage, tt.total, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,21 +1,33 @@
 package model
 
+import (
+	"time"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+)
+
 type ProductListItem struct {
-	ID             uint64  `db:"id" json:"id"`
+	ID             ID      `db:"id" json:"id"`
 	Name           string  `db:"name" json:"name"`
 	ShopName       string  `db:"shop_name" json:"shop_name"`
 	AvailableStock int64   `db:"available_stock" json:"available_stock"`
 	Price          float64 `db:"price" json:"price"`
+
+	AvailabilityStatus constant.ProductAvailability `db:"-" json:"availability_status"`
 }
 
 type ProductDetail struct {
-	ID             uint64  `db:"id" json:"id"`
+	ID             ID      `db:"id" json:"id"`
 	Name           string  `db:"name" json:"name"`
 	Description    string  `db:"description" json:"description,omitempty"`
-	ShopID         uint64  `db:"shop_id" json:"shop_id"`
+	ShopID         ID      `db:"shop_id" json:"shop_id"`
 	ShopName       string  `db:"shop_name" json:"shop_name"`
 	AvailableStock int64   `db:"available_stock" json:"available_stock"`
 	Price          float64 `db:"price" json:"price"`
+	AverageRating  float64 `db:"-" json:"average_rating"`
+	ReviewCount    int64   `db:"-" json:"review_count"`
+
+	AvailabilityStatus constant.ProductAvailability `db:"-" json:"availability_status"`
 }
 
 type ProductListResponse struct {
@@ -23,4 +35,102 @@ type ProductListResponse struct {
 	TotalCount int64             `json:"total_count"`
 	Page       int               `json:"page"`
 	PerPage    int               `json:"per_page"`
+	PageMeta
+}
+
+type ProductStockResponse struct {
+	ProductID      ID    `json:"product_id"`
+	AvailableStock int64 `json:"available_stock"`
+}
+
+type ProductReview struct {
+	ID        ID        `db:"id" json:"id"`
+	UserID    ID        `db:"user_id" json:"user_id"`
+	ProductID ID        `db:"product_id" json:"product_id"`
+	Rating    int       `db:"rating" json:"rating"`
+	Comment   string    `db:"comment" json:"comment,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// ReviewStats holds raw rating aggregates of a product
+type ReviewStats struct {
+	RatingSum   int64 `db:"rating_sum"`
+	ReviewCount int64 `db:"review_count"`
+}
+
+// ProductRequest creates a product, the name is limited like the column
+type ProductRequest struct {
+	ShopID      ID      `json:"shop_id" validate:"required"`
+	Name        string  `json:"name" validate:"required,max=100"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price" validate:"gt=0"`
+}
+
+// ProductUpdateRequest changes only the fields that are set, at least one must be
+type ProductUpdateRequest struct {
+	ShopID      *ID      `json:"shop_id,omitempty" validate:"omitempty,gt=0"`
+	Name        *string  `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Description *string  `json:"description,omitempty"`
+	Price       *float64 `json:"price,omitempty" validate:"omitempty,gt=0"`
+}
+
+// Empty reports whether the update changes nothing
+func (r *ProductUpdateRequest) Empty() bool {
+	return r.ShopID == nil && r.Name == nil && r.Description == nil && r.Price == nil
+}
+
+type ReviewRequest struct {
+	Rating  int    `json:"rating" validate:"required,min=1,max=5"`
+	Comment string `json:"comment" validate:"max=1000"`
+}
+
+type ReviewListResponse struct {
+	Items      []ProductReview `json:"items"`
+	TotalCount int64           `json:"total_count"`
+	Page       int             `json:"page"`
+	PerPage    int             `json:"per_page"`
+	PageMeta
+}
+
+// ProductFeedItem is the lean product projection of the mobile feed, it only
+// says whether the product can be bought instead of the exact stock
+type ProductFeedItem struct {
+	ID        ID      `db:"id" json:"id"`
+	Name      string  `db:"name" json:"name"`
+	Price     float64 `db:"price" json:"price"`
+	ImageURL  string  `db:"image_url" json:"image_url,omitempty"`
+	Available bool    `db:"available" json:"available"`
+}
+
+// ProductFeedResponse is a keyset page of the feed, pass NextCursor as the
+// cursor of the next request while HasMore is true
+type ProductFeedResponse struct {
+	Items      []ProductFeedItem `json:"items"`
+	NextCursor ID                `json:"next_cursor,omitempty"`
+	HasMore    bool              `json:"has_more"`
+}
+
+// ProductSyncItem is a catalog change for mirrors, Deleted products must be
+// removed from the mirror
+type ProductSyncItem struct {
+	ID          ID        `db:"id" json:"id"`
+	ShopID      ID        `db:"shop_id" json:"shop_id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description,omitempty"`
+	Price       float64   `db:"price" json:"price"`
+	ImageURL    string    `db:"image_url" json:"image_url,omitempty"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+	Deleted     bool      `db:"deleted" json:"deleted"`
+}
+
+// ProductSyncCursor is the position in the (updated_at, id) ordered change stream
+type ProductSyncCursor struct {
+	UpdatedAt time.Time
+	ID        uint64
+}
+
+type ProductSyncResponse struct {
+	Items      []ProductSyncItem `json:"items"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	HasMore    bool              `json:"has_more"`
 }
@@ -4,11 +4,12 @@ import "time"
 
 // UserEntity represents the user table entity
 type UserEntity struct {
-	ID           uint64     `db:"id" json:"id"`
+	ID           ID         `db:"id" json:"id"`
 	Name         string     `db:"name" json:"name"`
 	Email        string     `db:"email" json:"email"`
 	Phone        string     `db:"phone" json:"phone"`
 	PasswordHash string     `db:"password_hash" json:"-"`
+	IsVerified   bool       `db:"is_verified" json:"is_verified"`
 	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
 	UpdatedAt    *time.Time `db:"updated_at" json:"updated_at,omitempty"`
 }
@@ -24,7 +25,7 @@ type UserFilter struct {
 type RegisterRequest struct {
 	Name     string `json:"name" validate:"required"`
 	Email    string `json:"email" validate:"required,email"`
-	Phone    string `json:"phone" validate:"required"`
+	Phone    string `json:"phone" validate:"required,id_phone"`
 	Password string `json:"password" validate:"required,min=6"`
 }
 
@@ -43,4 +44,47 @@ type LoginResponse struct {
 type RegisterResponse struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	// VerificationToken confirms the email through POST /public/v1/verify. It is
+	// returned here until verification emails are sent.
+	VerificationToken string `json:"verification_token"`
+}
+
+// ResendVerificationResponse carries a fresh token for POST /public/v1/verify,
+// returned until verification emails are sent
+type ResendVerificationResponse struct {
+	VerificationToken string `json:"verification_token"`
+}
+
+// VerifyEmailRequest carries the token issued on register
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// UserAddress represents the user_address table entity
+type UserAddress struct {
+	ID     ID     `db:"id" json:"id"`
+	UserID uint64 `db:"user_id" json:"-"`
+	ShippingAddress
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt *time.Time `db:"updated_at" json:"updated_at,omitempty"`
+}
+
+// NotificationPrefs represents the user_notification_prefs table entity
+type NotificationPrefs struct {
+	OrderUpdates     bool `db:"order_updates" json:"order_updates"`
+	Marketing        bool `db:"marketing" json:"marketing"`
+	WishlistLowStock bool `db:"wishlist_low_stock" json:"wishlist_low_stock"`
+}
+
+// UpdateNotificationPrefsRequest only changes the preferences that are sent
+type UpdateNotificationPrefsRequest struct {
+	OrderUpdates     *bool `json:"order_updates,omitempty"`
+	Marketing        *bool `json:"marketing,omitempty"`
+	WishlistLowStock *bool `json:"wishlist_low_stock,omitempty"`
+}
+
+// Session is one live login of a user, ID is the jti of its token
+type Session struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
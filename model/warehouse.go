@@ -7,10 +7,14 @@ import (
 )
 
 type ReserveRequest struct {
-	OrderID   uint64
+	OrderID uint64
+	// CartID is set instead of OrderID when stock is held for a cart item
+	CartID    uint64
 	ProductID uint64
 	Quantity  int
 	ExpiresAt time.Time
+	// Allocation orders the warehouses stock is taken from, one of constant.StockAllocation*
+	Allocation string
 }
 
 type Reservation struct {
@@ -20,21 +24,31 @@ type Reservation struct {
 	Quantity    int64  `db:"quantity"`
 }
 
+// OrderFulfillment is the quantity of a paid order taken from one warehouse
+type OrderFulfillment struct {
+	ID          int64  `db:"id"`
+	OrderID     uint64 `db:"order_id"`
+	WarehouseID int64  `db:"warehouse_id"`
+	ProductID   uint64 `db:"product_id"`
+	Quantity    int64  `db:"quantity"`
+}
+
 type WarehouseEntity struct {
-	ID        uint64                   `db:"id" json:"id"`
-	ShopID    uint64                   `db:"shop_id" json:"shop_id"`
+	ID        ID                       `db:"id" json:"id"`
+	ShopID    ID                       `db:"shop_id" json:"shop_id"`
 	Name      string                   `db:"name" json:"name"`
 	Status    constant.WarehouseStatus `db:"status" json:"status"`
+	Priority  int                      `db:"priority" json:"priority"`
 	CreatedAt time.Time                `db:"created_at" json:"created_at"`
 	UpdatedAt *time.Time               `db:"updated_at" json:"updated_at,omitempty"`
 }
 
 type WarehouseStock struct {
-	ID          uint64 `db:"id" json:"id"`
-	WarehouseID uint64 `db:"warehouse_id" json:"warehouse_id"`
-	ProductID   uint64 `db:"product_id" json:"product_id"`
-	Stock       int64  `db:"stock" json:"stock"`
-	Reserved    int64  `db:"reserved" json:"reserved"`
+	ID          ID    `db:"id" json:"id"`
+	WarehouseID ID    `db:"warehouse_id" json:"warehouse_id"`
+	ProductID   ID    `db:"product_id" json:"product_id"`
+	Stock       int64 `db:"stock" json:"stock"`
+	Reserved    int64 `db:"reserved" json:"reserved"`
 }
 
 type TransferStockRequest struct {
@@ -42,11 +56,63 @@ type TransferStockRequest struct {
 	FromWarehouseID uint64
 	ToWarehouseID   uint64
 	Quantity        int
+	// MoveReservations moves the product's reservations along with the stock,
+	// Quantity then counts the reserved units too and must cover them
+	MoveReservations bool
 }
 
 type TransferStockHTTPRequest struct {
-	ProductID       uint64 `json:"product_id" validate:"required"`
-	FromWarehouseID uint64 `json:"from_warehouse_id" validate:"required"`
-	ToWarehouseID   uint64 `json:"to_warehouse_id" validate:"required"`
-	Quantity        int    `json:"quantity" validate:"required,gt=0"`
+	ProductID        ID   `json:"product_id" validate:"required"`
+	FromWarehouseID  ID   `json:"from_warehouse_id" validate:"required"`
+	ToWarehouseID    ID   `json:"to_warehouse_id" validate:"required"`
+	Quantity         int  `json:"quantity" validate:"required,gt=0"`
+	MoveReservations bool `json:"move_reservations"`
+}
+
+type StockAdjustmentRequest struct {
+	WarehouseID uint64
+	ProductID   uint64
+	Quantity    int
+}
+
+type StockAdjustmentHTTPRequest struct {
+	ProductID ID  `json:"product_id" validate:"required"`
+	Quantity  int `json:"quantity" validate:"required"`
+}
+
+// LowStockItem is a product whose available stock across active warehouses is low
+type LowStockItem struct {
+	ProductID ID     `db:"product_id" json:"product_id"`
+	Name      string `db:"name" json:"name"`
+	Available int64  `db:"available" json:"available"`
+}
+
+// ReservedDiscrepancy is a warehouse_stock row whose reserved count disagreed with
+// its reservation rows. Reserved is the stored count, Actual what the rows add up to.
+type ReservedDiscrepancy struct {
+	WarehouseID ID    `json:"warehouse_id"`
+	ProductID   ID    `json:"product_id"`
+	Reserved    int64 `json:"reserved"`
+	Actual      int64 `json:"actual"`
+}
+
+// SweepResponse reports what an on-demand reservation expiry sweep cleaned up
+type SweepResponse struct {
+	ReleasedReservations int `json:"released_reservations"`
+	ExpiredOrders        int `json:"expired_orders"`
+}
+
+// WarehouseReservedDetails explains a refused deactivation: what is reserved and by whom
+type WarehouseReservedDetails struct {
+	ReservedQuantity int64 `json:"reserved_quantity"`
+	OrderIDs         []ID  `json:"order_ids"`
+	CartIDs          []ID  `json:"cart_ids"`
+}
+
+type WarehouseSummary struct {
+	ID            ID                       `db:"id" json:"id"`
+	Name          string                   `db:"name" json:"name"`
+	Status        constant.WarehouseStatus `db:"status" json:"status"`
+	TotalStock    int64                    `db:"total_stock" json:"total_stock"`
+	TotalReserved int64                    `db:"total_reserved" json:"total_reserved"`
 }
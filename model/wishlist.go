@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+type WishlistRequest struct {
+	ProductID ID `json:"product_id" validate:"required"`
+}
+
+type WishlistItem struct {
+	ProductID      ID        `db:"product_id" json:"product_id"`
+	Name           string    `db:"name" json:"name"`
+	Price          float64   `db:"price" json:"price"`
+	AvailableStock int64     `db:"available_stock" json:"available_stock"`
+	AddedAt        time.Time `db:"added_at" json:"added_at"`
+}
@@ -0,0 +1,256 @@
+package cart
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/muhammadheryan/e-commerce/model"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
+)
+
+type SQL struct {
+	conn *sqlx.DB
+}
+
+type CartRepository interface {
+	ProductExists(ctx context.Context, productID uint64) (bool, error)
+	AddItem(ctx context.Context, userID, productID uint64, quantity int) error
+	UpdateItem(ctx context.Context, userID, productID uint64, quantity int) (bool, error)
+	RemoveItem(ctx context.Context, userID, productID uint64) (bool, error)
+	ListItems(ctx context.Context, userID uint64) ([]model.CartItem, error)
+	ListItemsTx(ctx context.Context, tx *sqlx.Tx, userID uint64) ([]model.OrderItemRequest, error)
+	ClearTx(ctx context.Context, tx *sqlx.Tx, userID uint64) error
+	EnsureCartTx(ctx context.Context, tx *sqlx.Tx, userID uint64) (uint64, error)
+	GetCartIDTx(ctx context.Context, tx *sqlx.Tx, userID uint64) (uint64, error)
+	AddItemTx(ctx context.Context, tx *sqlx.Tx, cartID, productID uint64, quantity int) (int, error)
+	UpdateItemTx(ctx context.Context, tx *sqlx.Tx, cartID, productID uint64, quantity int) (bool, error)
+	RemoveItemTx(ctx context.Context, tx *sqlx.Tx, cartID, productID uint64) (bool, error)
+}
+
+func NewCartRepository(conn *sqlx.DB) CartRepository {
+	return &SQL{conn: conn}
+}
+
+const (
+	productExistsQuery = `SELECT EXISTS(SELECT 1 FROM product WHERE id = ? AND deleted_at IS NULL)`
+
+	// one cart per user, IGNORE keeps concurrent first adds from failing
+	ensureCartQuery = `INSERT IGNORE INTO cart (user_id, created_at, updated_at) VALUES (?, NOW(), NOW())`
+
+	// adding a product already in the cart increases its quantity
+	addCartItemQuery = `INSERT INTO cart_item (cart_id, product_id, quantity, created_at, updated_at)
+SELECT id, ?, ?, NOW(), NOW() FROM cart WHERE user_id = ?
+ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity), updated_at = NOW()`
+
+	updateCartItemQuery = `UPDATE cart_item ci JOIN cart c ON c.id = ci.cart_id SET ci.quantity = ?, ci.updated_at = NOW() WHERE c.user_id = ? AND ci.product_id = ?`
+
+	cartItemExistsQuery = `SELECT EXISTS(SELECT 1 FROM cart_item ci JOIN cart c ON c.id = ci.cart_id WHERE c.user_id = ? AND ci.product_id = ?)`
+
+	removeCartItemQuery = `DELETE ci FROM cart_item ci JOIN cart c ON c.id = ci.cart_id WHERE c.user_id = ? AND ci.product_id = ?`
+
+	listCartItemsQuery = `SELECT ci.product_id, p.name, p.price, ci.quantity
+FROM cart_item ci
+JOIN cart c ON c.id = ci.cart_id
+JOIN product p ON p.id = ci.product_id
+WHERE c.user_id = ?
+ORDER BY ci.created_at, ci.id`
+
+	listCartItemsTxQuery = `SELECT ci.product_id, ci.quantity FROM cart_item ci JOIN cart c ON c.id = ci.cart_id WHERE c.user_id = ? ORDER BY ci.id FOR UPDATE`
+
+	clearCartQuery = `DELETE ci FROM cart_item ci JOIN cart c ON c.id = ci.cart_id WHERE c.user_id = ?`
+
+	// locking the cart row serializes mutations of the same cart
+	getCartIDForUpdateQuery = `SELECT id FROM cart WHERE user_id = ? FOR UPDATE`
+
+	upsertCartItemByCartQuery = `INSERT INTO cart_item (cart_id, product_id, quantity, created_at, updated_at) VALUES (?, ?, ?, NOW(), NOW())
+ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity), updated_at = NOW()`
+
+	getCartItemQuantityQuery = `SELECT quantity FROM cart_item WHERE cart_id = ? AND product_id = ?`
+
+	updateCartItemByCartQuery = `UPDATE cart_item SET quantity = ?, updated_at = NOW() WHERE cart_id = ? AND product_id = ?`
+
+	removeCartItemByCartQuery = `DELETE FROM cart_item WHERE cart_id = ? AND product_id = ?`
+)
+
+func (s *SQL) ProductExists(ctx context.Context, productID uint64) (bool, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	if err := s.conn.GetContext(ctx, &exists, productExistsQuery, productID); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+func (s *SQL) AddItem(ctx context.Context, userID, productID uint64, quantity int) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.conn.ExecContext(ctx, ensureCartQuery, userID); err != nil {
+		return err
+	}
+	_, err := s.conn.ExecContext(ctx, addCartItemQuery, productID, quantity, userID)
+	return err
+}
+
+func (s *SQL) UpdateItem(ctx context.Context, userID, productID uint64, quantity int) (bool, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := s.conn.ExecContext(ctx, updateCartItemQuery, quantity, userID, productID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		return true, nil
+	}
+
+	// MySQL reports zero affected rows when nothing changed, so tell apart an
+	// unchanged item from a missing one
+	var exists bool
+	if err := s.conn.GetContext(ctx, &exists, cartItemExistsQuery, userID, productID); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+func (s *SQL) RemoveItem(ctx context.Context, userID, productID uint64) (bool, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := s.conn.ExecContext(ctx, removeCartItemQuery, userID, productID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (s *SQL) ListItems(ctx context.Context, userID uint64) ([]model.CartItem, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	items := make([]model.CartItem, 0)
+	if err := s.conn.SelectContext(ctx, &items, listCartItemsQuery, userID); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ListItemsTx locks the user's cart items so the cart cannot change while it
+// is being converted into an order
+func (s *SQL) ListItemsTx(ctx context.Context, tx *sqlx.Tx, userID uint64) ([]model.OrderItemRequest, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := tx.QueryxContext(ctx, listCartItemsTxQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]model.OrderItemRequest, 0)
+	for rows.Next() {
+		var item model.OrderItemRequest
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *SQL) ClearTx(ctx context.Context, tx *sqlx.Tx, userID uint64) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, clearCartQuery, userID)
+	return err
+}
+
+// EnsureCartTx creates the user's cart when missing and returns its locked id
+func (s *SQL) EnsureCartTx(ctx context.Context, tx *sqlx.Tx, userID uint64) (uint64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, ensureCartQuery, userID); err != nil {
+		return 0, err
+	}
+	var cartID uint64
+	if err := tx.GetContext(ctx, &cartID, getCartIDForUpdateQuery, userID); err != nil {
+		return 0, err
+	}
+	return cartID, nil
+}
+
+// GetCartIDTx returns the user's locked cart id, zero when the user has no cart
+func (s *SQL) GetCartIDTx(ctx context.Context, tx *sqlx.Tx, userID uint64) (uint64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var cartID uint64
+	if err := tx.GetContext(ctx, &cartID, getCartIDForUpdateQuery, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return cartID, nil
+}
+
+// AddItemTx adds quantity to the cart item and returns the resulting quantity
+func (s *SQL) AddItemTx(ctx context.Context, tx *sqlx.Tx, cartID, productID uint64, quantity int) (int, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, upsertCartItemByCartQuery, cartID, productID, quantity); err != nil {
+		return 0, err
+	}
+	var total int
+	if err := tx.GetContext(ctx, &total, getCartItemQuantityQuery, cartID, productID); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (s *SQL) UpdateItemTx(ctx context.Context, tx *sqlx.Tx, cartID, productID uint64, quantity int) (bool, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	// the row is read first since MySQL reports zero affected rows when nothing changed
+	var current int
+	if err := tx.GetContext(ctx, &current, getCartItemQuantityQuery, cartID, productID); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, updateCartItemByCartQuery, quantity, cartID, productID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *SQL) RemoveItemTx(ctx context.Context, tx *sqlx.Tx, cartID, productID uint64) (bool, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := tx.ExecContext(ctx, removeCartItemByCartQuery, cartID, productID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -2,9 +2,16 @@ package order
 
 import (
 	"context"
+	"database/sql"
+	goerrors "errors"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"github.com/muhammadheryan/e-commerce/constant"
 	"github.com/muhammadheryan/e-commerce/model"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
 )
 
 type SQL struct {
@@ -13,9 +20,23 @@ type SQL struct {
 
 type OrderRepository interface {
 	InsertOrderTx(ctx context.Context, tx *sqlx.Tx, req *model.InsertOrderTxItem) (uint64, error)
-	InsertOrderItemsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, items []model.OrderItemRequest) error
+	InsertOrderItemsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, items []model.OrderItem) error
 	UpdateOrderStatusTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, status int) error
+	UpdateOrderExpiresAtTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, expiresAt time.Time) error
 	GetOrderDetailTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) (*model.OrderDetail, error)
+	GetOrderItemsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) ([]model.OrderItem, error)
+	UseVoucherTx(ctx context.Context, tx *sqlx.Tx, code string) error
+	ReleaseVoucherTx(ctx context.Context, tx *sqlx.Tx, code string) error
+	GetProductPricesTx(ctx context.Context, tx *sqlx.Tx, productIDs []uint64) (map[uint64]float64, error)
+	IsUserVerified(ctx context.Context, userID uint64) (bool, error)
+	InsertOrderAddressTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, addr *model.ShippingAddress) error
+	GetUserAddressTx(ctx context.Context, tx *sqlx.Tx, addressID uint64) (*model.UserAddress, error)
+	ListExpiredPendingOrderIDs(ctx context.Context, limit int) ([]uint64, error)
+	InsertOutboxTx(ctx context.Context, tx *sqlx.Tx, msg *model.OrderOutbox) (uint64, error)
+	ListUnsentOutbox(ctx context.Context, limit int) ([]model.OrderOutbox, error)
+	MarkOutboxSent(ctx context.Context, id uint64) error
+	IncrementOutboxAttempts(ctx context.Context, id uint64) error
+	ListOrdersByUser(ctx context.Context, filter model.OrderListFilter, page, perPage int) ([]model.OrderSummary, int64, error)
 }
 
 func NewOrderRepository(conn *sqlx.DB) OrderRepository {
@@ -23,7 +44,10 @@ func NewOrderRepository(conn *sqlx.DB) OrderRepository {
 }
 
 func (r *SQL) InsertOrderTx(ctx context.Context, tx *sqlx.Tx, req *model.InsertOrderTxItem) (uint64, error) {
-	res, err := tx.ExecContext(ctx, "INSERT INTO `order` (user_id, status, expires_at) VALUES (?, ?, ?)", req.UserID, req.Status, req.ExpiresAT)
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO `order` (user_id, status, subtotal, tax_amount, grand_total, currency, voucher_code, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", req.UserID, req.Status, req.Subtotal, req.TaxAmount, req.GrandTotal, req.Currency, req.VoucherCode, req.ExpiresAT)
 	if err != nil {
 		return 0, err
 	}
@@ -34,26 +58,261 @@ func (r *SQL) InsertOrderTx(ctx context.Context, tx *sqlx.Tx, req *model.InsertO
 	return uint64(id), nil
 }
 
-func (r *SQL) InsertOrderItemsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, items []model.OrderItemRequest) error {
-	q := "INSERT INTO order_item (order_id, product_id, quantity) VALUES (?, ?, ?)"
+// InsertOrderItemsTx inserts the line items of an order. A product listed twice
+// hits the (order_id, product_id) unique key and is reported as ErrInvalidRequest.
+func (r *SQL) InsertOrderItemsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, items []model.OrderItem) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	q := "INSERT INTO order_item (order_id, product_id, quantity, unit_price) VALUES (?, ?, ?, ?)"
 	for _, it := range items {
-		if _, err := tx.ExecContext(ctx, q, orderID, it.ProductID, it.Quantity); err != nil {
+		if _, err := tx.ExecContext(ctx, q, orderID, it.ProductID, it.Quantity, it.UnitPrice); err != nil {
+			if isDuplicateKey(err) {
+				return errors.SetCustomError(constant.ErrInvalidRequest)
+			}
 			return err
 		}
 	}
 	return nil
 }
 
+// mysqlErrDuplicateEntry is ER_DUP_ENTRY, raised when a unique key is violated
+const mysqlErrDuplicateEntry = 1062
+
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return goerrors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry
+}
+
 func (r *SQL) UpdateOrderStatusTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, status int) error {
-	_, err := tx.ExecContext(ctx, "UPDATE `order` SET status = ? WHERE id = ?", status, orderID)
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, "UPDATE `order` SET status = ?, updated_at = NOW() WHERE id = ?", status, orderID)
+	return err
+}
+
+// UpdateOrderExpiresAtTx moves the deadline the poller expires a pending order by
+func (r *SQL) UpdateOrderExpiresAtTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, expiresAt time.Time) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, "UPDATE `order` SET expires_at = ?, updated_at = NOW() WHERE id = ?", expiresAt, orderID)
 	return err
 }
 
 func (r *SQL) GetOrderDetailTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) (*model.OrderDetail, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	// the row stays locked until the tx ends, so a status checked against it
+	// can't change before the transition is written: concurrent pay, cancel
+	// and refund of one order run one after the other
 	var detail model.OrderDetail
-	row := tx.QueryRowxContext(ctx, "SELECT id, user_id, status FROM `order` WHERE id = ?", orderID)
+	row := tx.QueryRowxContext(ctx, "SELECT id, user_id, status, voucher_code FROM `order` WHERE id = ? FOR UPDATE", orderID)
 	if err := row.StructScan(&detail); err != nil {
 		return nil, err
 	}
 	return &detail, nil
 }
+
+// GetOrderItemsTx lists the line items of an order, empty when it has none
+func (r *SQL) GetOrderItemsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) ([]model.OrderItem, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	items := make([]model.OrderItem, 0)
+	if err := tx.SelectContext(ctx, &items, "SELECT product_id, quantity, unit_price FROM order_item WHERE order_id = ? ORDER BY product_id", orderID); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// UseVoucherTx redeems one usage of the voucher. The conditional update keeps
+// concurrent orders from redeeming a voucher past its max_uses.
+func (r *SQL) UseVoucherTx(ctx context.Context, tx *sqlx.Tx, code string) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, "UPDATE voucher SET used = used + 1, updated_at = NOW() WHERE code = ? AND used < max_uses", code)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.SetCustomError(constant.ErrVoucherExhausted)
+	}
+	return nil
+}
+
+// ReleaseVoucherTx gives back one usage of the voucher, used never goes below zero
+func (r *SQL) ReleaseVoucherTx(ctx context.Context, tx *sqlx.Tx, code string) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, "UPDATE voucher SET used = used - 1, updated_at = NOW() WHERE code = ? AND used > 0", code)
+	return err
+}
+
+// IsUserVerified reports whether the user confirmed their email, an unknown user isn't
+func (r *SQL) IsUserVerified(ctx context.Context, userID uint64) (bool, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var verified bool
+	if err := r.conn.GetContext(ctx, &verified, "SELECT EXISTS(SELECT 1 FROM user WHERE id = ? AND is_verified = 1)", userID); err != nil {
+		return false, err
+	}
+	return verified, nil
+}
+
+func (r *SQL) GetProductPricesTx(ctx context.Context, tx *sqlx.Tx, productIDs []uint64) (map[uint64]float64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query, args, err := sqlx.In("SELECT id, price FROM product WHERE id IN (?) AND deleted_at IS NULL", productIDs)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.QueryxContext(ctx, tx.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := make(map[uint64]float64, len(productIDs))
+	for rows.Next() {
+		var (
+			id    uint64
+			price float64
+		)
+		if err := rows.Scan(&id, &price); err != nil {
+			return nil, err
+		}
+		prices[id] = price
+	}
+	return prices, rows.Err()
+}
+
+func (r *SQL) InsertOrderAddressTx(ctx context.Context, tx *sqlx.Tx, orderID uint64, addr *model.ShippingAddress) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	q := "INSERT INTO order_address (order_id, recipient_name, phone, address_line, city, province, postal_code) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	_, err := tx.ExecContext(ctx, q, orderID, addr.RecipientName, addr.Phone, addr.AddressLine, addr.City, addr.Province, addr.PostalCode)
+	return err
+}
+
+func (r *SQL) GetUserAddressTx(ctx context.Context, tx *sqlx.Tx, addressID uint64) (*model.UserAddress, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var addr model.UserAddress
+	q := "SELECT id, user_id, recipient_name, phone, address_line, city, province, postal_code, created_at, updated_at FROM user_address WHERE id = ?"
+	if err := tx.QueryRowxContext(ctx, q, addressID).StructScan(&addr); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &addr, nil
+}
+
+func (r *SQL) ListExpiredPendingOrderIDs(ctx context.Context, limit int) ([]uint64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	ids := make([]uint64, 0)
+	q := "SELECT id FROM `order` WHERE status = ? AND expires_at < NOW() ORDER BY expires_at LIMIT ?"
+	if err := r.conn.SelectContext(ctx, &ids, q, constant.OrderStatusPending, limit); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *SQL) InsertOutboxTx(ctx context.Context, tx *sqlx.Tx, msg *model.OrderOutbox) (uint64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO order_outbox (order_id, user_id, expires_at) VALUES (?, ?, ?)", msg.OrderID, msg.UserID, msg.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+func (r *SQL) ListUnsentOutbox(ctx context.Context, limit int) ([]model.OrderOutbox, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows := make([]model.OrderOutbox, 0)
+	q := "SELECT id, order_id, user_id, expires_at, attempts FROM order_outbox WHERE sent_at IS NULL ORDER BY id LIMIT ?"
+	if err := r.conn.SelectContext(ctx, &rows, q, limit); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *SQL) MarkOutboxSent(ctx context.Context, id uint64) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := r.conn.ExecContext(ctx, "UPDATE order_outbox SET sent_at = NOW(), attempts = attempts + 1 WHERE id = ?", id)
+	return err
+}
+
+func (r *SQL) IncrementOutboxAttempts(ctx context.Context, id uint64) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := r.conn.ExecContext(ctx, "UPDATE order_outbox SET attempts = attempts + 1 WHERE id = ?", id)
+	return err
+}
+
+// orderListWhere builds the predicate shared by the page and count queries so
+// the total always describes the same set of rows as the items
+func orderListWhere(filter model.OrderListFilter) (string, []interface{}) {
+	where := " WHERE user_id = ?"
+	args := []interface{}{filter.UserID}
+	if filter.Status != nil {
+		where += " AND status = ?"
+		args = append(args, *filter.Status)
+	}
+	return where, args
+}
+
+func (r *SQL) ListOrdersByUser(ctx context.Context, filter model.OrderListFilter, page, perPage int) ([]model.OrderSummary, int64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	offset := (page - 1) * perPage
+	where, args := orderListWhere(filter)
+
+	// read both queries from one snapshot so an order created in between
+	// can't make the total disagree with the page
+	tx, err := r.conn.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	items := make([]model.OrderSummary, 0)
+	q := "SELECT id, status, subtotal, tax_amount, grand_total, currency, created_at, expires_at FROM `order`" + where + " ORDER BY id DESC LIMIT ? OFFSET ?"
+	if err := tx.SelectContext(ctx, &items, q, append(args, perPage, offset)...); err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := tx.GetContext(ctx, &total, "SELECT COUNT(*) FROM `order`"+where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	return items, total, tx.Commit()
+}
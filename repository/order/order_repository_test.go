@@ -0,0 +1,434 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/model"
+	orderrepo "github.com/muhammadheryan/e-commerce/repository/order"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
+	cerr "github.com/muhammadheryan/e-commerce/utils/errors"
+)
+
+// openTestDB connects to a migrated MySQL database given by TEST_DB_DSN.
+// Tests are skipped when it is not set.
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set, skipping repository integration test")
+	}
+	db, err := sqlx.Connect("mysql", dsn)
+	if err != nil {
+		t.Fatalf("connect db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestOrderRepository_ListOrdersByUserTotalMatchesFilter(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	// an id far from real users so counts only see this test's rows
+	const userID = uint64(987654321)
+	const otherUserID = uint64(987654322)
+	statuses := []constant.OrderStatus{
+		constant.OrderStatusPending,
+		constant.OrderStatusPending,
+		constant.OrderStatusPending,
+		constant.OrderStatusCanceled,
+		constant.OrderStatusCompleted,
+	}
+	for _, st := range statuses {
+		if _, err := db.Exec("INSERT INTO `order` (user_id, status) VALUES (?, ?)", userID, st); err != nil {
+			t.Fatalf("insert order: %v", err)
+		}
+	}
+	if _, err := db.Exec("INSERT INTO `order` (user_id, status) VALUES (?, ?)", otherUserID, constant.OrderStatusPending); err != nil {
+		t.Fatalf("insert order: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM `order` WHERE user_id IN (?, ?)", userID, otherUserID)
+	})
+
+	repo := orderrepo.NewOrderRepository(db)
+	pending := constant.OrderStatusPending
+
+	tests := []struct {
+		name      string
+		filter    model.OrderListFilter
+		wantTotal int64
+	}{
+		{"all statuses", model.OrderListFilter{UserID: userID}, 5},
+		{"pending only", model.OrderListFilter{UserID: userID, Status: &pending}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := map[uint64]bool{}
+			for page := 1; page <= 3; page++ {
+				items, total, err := repo.ListOrdersByUser(ctx, tt.filter, page, 2)
+				if err != nil {
+					t.Fatalf("ListOrdersByUser() page %d error = %v", page, err)
+				}
+				if total != tt.wantTotal {
+					t.Fatalf("page %d total = %d, want %d", page, total, tt.wantTotal)
+				}
+				for _, it := range items {
+					if tt.filter.Status != nil && it.Status != *tt.filter.Status {
+						t.Fatalf("page %d returned status %d outside filter", page, it.Status)
+					}
+					seen[uint64(it.ID)] = true
+				}
+			}
+			if int64(len(seen)) != tt.wantTotal {
+				t.Fatalf("pages returned %d distinct orders, want %d", len(seen), tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestOrderRepository_OrderItemKeepsPriceSnapshot(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	res, err := db.Exec("INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", 0, "test-product-price-snapshot", "", 15000)
+	if err != nil {
+		t.Fatalf("insert product: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	productID := uint64(id)
+	var orderID uint64
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM order_item WHERE order_id = ?", orderID)
+		_, _ = db.Exec("DELETE FROM `order` WHERE id = ?", orderID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+	})
+
+	repo := orderrepo.NewOrderRepository(db)
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	prices, err := repo.GetProductPricesTx(ctx, tx, []uint64{productID})
+	if err != nil {
+		t.Fatalf("GetProductPricesTx() error = %v", err)
+	}
+	orderID, err = repo.InsertOrderTx(ctx, tx, &model.InsertOrderTxItem{UserID: 987654321, Status: constant.OrderStatusPending, Subtotal: 2 * prices[productID], GrandTotal: 2 * prices[productID], ExpiresAT: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("InsertOrderTx() error = %v", err)
+	}
+	if err := repo.InsertOrderItemsTx(ctx, tx, orderID, []model.OrderItem{{ProductID: model.ID(productID), Quantity: 2, UnitPrice: prices[productID]}}); err != nil {
+		t.Fatalf("InsertOrderItemsTx() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	if _, err := db.Exec("UPDATE product SET price = 99000 WHERE id = ?", productID); err != nil {
+		t.Fatalf("update price: %v", err)
+	}
+
+	var unitPrice float64
+	if err := db.Get(&unitPrice, "SELECT unit_price FROM order_item WHERE order_id = ?", orderID); err != nil {
+		t.Fatalf("read order item: %v", err)
+	}
+	if unitPrice != 15000 {
+		t.Fatalf("unit_price = %v, want the 15000 charged at order time", unitPrice)
+	}
+
+	readTx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	defer func() { _ = readTx.Rollback() }()
+	items, err := repo.GetOrderItemsTx(ctx, readTx, orderID)
+	if err != nil {
+		t.Fatalf("GetOrderItemsTx() error = %v", err)
+	}
+	if want := []model.OrderItem{{ProductID: model.ID(productID), Quantity: 2, UnitPrice: 15000}}; !reflect.DeepEqual(items, want) {
+		t.Fatalf("GetOrderItemsTx() = %+v, want %+v", items, want)
+	}
+	var grandTotal float64
+	if err := db.Get(&grandTotal, "SELECT grand_total FROM `order` WHERE id = ?", orderID); err != nil {
+		t.Fatalf("read order: %v", err)
+	}
+	if grandTotal != 30000 {
+		t.Fatalf("grand_total = %v, want 30000", grandTotal)
+	}
+}
+
+func TestOrderRepository_DeletedProductStaysInOrderHistory(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	res, err := db.Exec("INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", 0, "test-product-soft-deleted", "", 2500)
+	if err != nil {
+		t.Fatalf("insert product: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	productID := uint64(id)
+	res, err = db.Exec("INSERT INTO `order` (user_id, status) VALUES (?, ?)", 987654321, constant.OrderStatusCompleted)
+	if err != nil {
+		t.Fatalf("insert order: %v", err)
+	}
+	id, _ = res.LastInsertId()
+	orderID := uint64(id)
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM order_item WHERE order_id = ?", orderID)
+		_, _ = db.Exec("DELETE FROM `order` WHERE id = ?", orderID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+	})
+	if _, err := db.Exec("INSERT INTO order_item (order_id, product_id, quantity, unit_price) VALUES (?, ?, ?, ?)", orderID, productID, 1, 2500); err != nil {
+		t.Fatalf("insert order item: %v", err)
+	}
+	if _, err := db.Exec("UPDATE product SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?", productID); err != nil {
+		t.Fatalf("delete product: %v", err)
+	}
+
+	repo := orderrepo.NewOrderRepository(db)
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	items, err := repo.GetOrderItemsTx(ctx, tx, orderID)
+	if err != nil {
+		t.Fatalf("GetOrderItemsTx() error = %v", err)
+	}
+	if want := []model.OrderItem{{ProductID: model.ID(productID), Quantity: 1, UnitPrice: 2500}}; !reflect.DeepEqual(items, want) {
+		t.Fatalf("GetOrderItemsTx() = %+v, want %+v", items, want)
+	}
+	// but it can't be priced, so new orders reject it
+	prices, err := repo.GetProductPricesTx(ctx, tx, []uint64{productID})
+	if err != nil {
+		t.Fatalf("GetProductPricesTx() error = %v", err)
+	}
+	if _, ok := prices[productID]; ok {
+		t.Fatalf("GetProductPricesTx() priced deleted product %d", productID)
+	}
+}
+
+func TestOrderRepository_InsertOrderItemsRejectsDuplicateProduct(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	productID := uint64(987654320)
+	var orderID uint64
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM order_item WHERE order_id = ?", orderID)
+		_, _ = db.Exec("DELETE FROM `order` WHERE id = ?", orderID)
+	})
+
+	repo := orderrepo.NewOrderRepository(db)
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	orderID, err = repo.InsertOrderTx(ctx, tx, &model.InsertOrderTxItem{UserID: 987654321, Status: constant.OrderStatusPending, ExpiresAT: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("InsertOrderTx() error = %v", err)
+	}
+
+	err = repo.InsertOrderItemsTx(ctx, tx, orderID, []model.OrderItem{
+		{ProductID: model.ID(productID), Quantity: 1, UnitPrice: 1000},
+		{ProductID: model.ID(productID), Quantity: 2, UnitPrice: 1000},
+	})
+	var ce cerr.CustomError
+	if !errors.As(err, &ce) || ce.ErrorCode() != constant.ErrorTypeCode[constant.ErrInvalidRequest] {
+		t.Fatalf("InsertOrderItemsTx() error = %v, want ErrInvalidRequest", err)
+	}
+}
+
+func TestOrderRepository_UpdateOrderStatusAdvancesUpdatedAt(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	stale := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	res, err := db.Exec("INSERT INTO `order` (user_id, status, updated_at) VALUES (?, ?, ?)", 987654321, constant.OrderStatusPending, stale)
+	if err != nil {
+		t.Fatalf("insert order: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	orderID := uint64(id)
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM `order` WHERE id = ?", orderID)
+	})
+
+	repo := orderrepo.NewOrderRepository(db)
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	if err := repo.UpdateOrderStatusTx(ctx, tx, orderID, int(constant.OrderStatusCompleted)); err != nil {
+		_ = tx.Rollback()
+		t.Fatalf("UpdateOrderStatusTx() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	var updatedAt time.Time
+	if err := db.Get(&updatedAt, "SELECT updated_at FROM `order` WHERE id = ?", orderID); err != nil {
+		t.Fatalf("read order: %v", err)
+	}
+	if !updatedAt.After(stale) {
+		t.Fatalf("updated_at = %v, want after %v", updatedAt, stale)
+	}
+}
+
+func TestOrderRepository_GetOrderDetailLocksOrder(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	res, err := db.Exec("INSERT INTO `order` (user_id, status) VALUES (?, ?)", 987654321, constant.OrderStatusPending)
+	if err != nil {
+		t.Fatalf("insert order: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	orderID := uint64(id)
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM `order` WHERE id = ?", orderID)
+	})
+
+	repo := orderrepo.NewOrderRepository(db)
+
+	// a pay holds the order between reading its status and writing the new one
+	payTx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	defer func() { _ = payTx.Rollback() }()
+	if _, err := repo.GetOrderDetailTx(ctx, payTx, orderID); err != nil {
+		t.Fatalf("GetOrderDetailTx() error = %v", err)
+	}
+
+	// a concurrent cancel can't read the pending status until the pay is done
+	utilsContext.SetQueryTimeout(300 * time.Millisecond)
+	t.Cleanup(func() { utilsContext.SetQueryTimeout(0) })
+	cancelTx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	_, err = repo.GetOrderDetailTx(ctx, cancelTx, orderID)
+	_ = cancelTx.Rollback()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetOrderDetailTx() while locked error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if err := repo.UpdateOrderStatusTx(ctx, payTx, orderID, int(constant.OrderStatusCompleted)); err != nil {
+		t.Fatalf("UpdateOrderStatusTx() error = %v", err)
+	}
+	if err := payTx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	cancelTx, err = db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	defer func() { _ = cancelTx.Rollback() }()
+	detail, err := repo.GetOrderDetailTx(ctx, cancelTx, orderID)
+	if err != nil {
+		t.Fatalf("GetOrderDetailTx() error = %v", err)
+	}
+	if detail.Status != constant.OrderStatusCompleted {
+		t.Fatalf("status after pay = %d, want completed", detail.Status)
+	}
+}
+
+// newMockTx opens a transaction on a sqlmock connection that matches queries
+// exactly, so a test pins the statement a method sends
+func newMockTx(t *testing.T) (*sqlx.Tx, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("open sqlmock: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	mock.ExpectBegin()
+	tx, err := sqlx.NewDb(db, "mysql").Beginx()
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	return tx, mock
+}
+
+func TestOrderRepository_UseVoucherTx(t *testing.T) {
+	const q = "UPDATE voucher SET used = used + 1, updated_at = NOW() WHERE code = ? AND used < max_uses"
+	tests := []struct {
+		name     string
+		affected int64
+		execErr  error
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name:     "success: usage left",
+			affected: 1,
+		},
+		{
+			name:     "error: no usage left or unknown code",
+			affected: 0,
+			wantErr:  true,
+			errCode:  constant.ErrVoucherExhausted,
+		},
+		{
+			name:    "error: update fails",
+			execErr: errors.New("db error"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, mock := newMockTx(t)
+			exp := mock.ExpectExec(q).WithArgs("PROMO10")
+			if tt.execErr != nil {
+				exp.WillReturnError(tt.execErr)
+			} else {
+				exp.WillReturnResult(sqlmock.NewResult(0, tt.affected))
+			}
+
+			err := orderrepo.NewOrderRepository(nil).UseVoucherTx(context.Background(), tx, "PROMO10")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UseVoucherTx() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.errCode != 0 && !cerr.IsType(err, tt.errCode) {
+				t.Fatalf("UseVoucherTx() error = %v, want %s", err, constant.ErrorTypeCode[tt.errCode])
+			}
+			if tt.execErr != nil && !errors.Is(err, tt.execErr) {
+				t.Fatalf("UseVoucherTx() error = %v, want %v", err, tt.execErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestOrderRepository_ReleaseVoucherTx(t *testing.T) {
+	tx, mock := newMockTx(t)
+	mock.ExpectExec("UPDATE voucher SET used = used - 1, updated_at = NOW() WHERE code = ? AND used > 0").
+		WithArgs("PROMO10").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := orderrepo.NewOrderRepository(nil).ReleaseVoucherTx(context.Background(), tx, "PROMO10"); err != nil {
+		t.Fatalf("ReleaseVoucherTx() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
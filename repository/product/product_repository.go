@@ -2,10 +2,17 @@ package product
 
 import (
 	"context"
+	"database/sql"
+	goerrors "errors"
 	"fmt"
+	"strings"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"github.com/muhammadheryan/e-commerce/constant"
 	"github.com/muhammadheryan/e-commerce/model"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
 )
 
 type SQL struct {
@@ -13,36 +20,128 @@ type SQL struct {
 }
 
 type ProductRepository interface {
-	List(ctx context.Context, page, perPage int) ([]model.ProductListItem, int64, error)
+	List(ctx context.Context, page, perPage int, sort constant.ProductSort) ([]model.ProductListItem, int64, error)
 	GetByID(ctx context.Context, id uint64) (*model.ProductDetail, error)
+	GetAvailableStock(ctx context.Context, id uint64) (int64, error)
+	HasCompletedPurchase(ctx context.Context, userID, productID uint64) (bool, error)
+	CreateReview(ctx context.Context, review *model.ProductReview) (*model.ProductReview, error)
+	ListReviews(ctx context.Context, productID uint64, page, perPage int) ([]model.ProductReview, int64, error)
+	GetReviewStats(ctx context.Context, productID uint64) (*model.ReviewStats, error)
+	ListFeed(ctx context.Context, afterID uint64, limit int) ([]model.ProductFeedItem, error)
+	ListChangedSince(ctx context.Context, after model.ProductSyncCursor, limit int) ([]model.ProductSyncItem, error)
+	AddStockSubscription(ctx context.Context, userID, productID uint64) error
+	ListStockSubscribers(ctx context.Context, productID uint64) ([]uint64, error)
+	DeleteStockSubscription(ctx context.Context, userID, productID uint64) error
+	SoftDelete(ctx context.Context, id uint64) (bool, error)
+	Create(ctx context.Context, req *model.ProductRequest) (uint64, error)
+	Update(ctx context.Context, id uint64, req *model.ProductUpdateRequest) error
 }
 
 func NewProductRepository(conn *sqlx.DB) ProductRepository {
 	return &SQL{conn: conn}
 }
 
+// Available stock only counts warehouse_stock rows whose warehouse is active,
+// consistent with the stock check used when reserving for an order. A row with
+// more reserved than stock counts as 0 so it can't hide other warehouses' stock.
+// Soft-deleted products are left out of every read except the sync feed, which
+// has to tell mirrors about the deletion.
 const (
-	listProductsBase = `SELECT p.id, p.name, p.price, s.name as shop_name, COALESCE(SUM(ws.stock - ws.reserved),0) as available_stock
+	listProductsBase = `SELECT p.id, p.name, p.price, s.name as shop_name, COALESCE(SUM(CASE WHEN w.status = ? THEN GREATEST(ws.stock - ws.reserved, 0) ELSE 0 END),0) as available_stock
 FROM product p
 JOIN shop s ON p.shop_id = s.id
 LEFT JOIN warehouse_stock ws ON ws.product_id = p.id
+LEFT JOIN warehouse w ON ws.warehouse_id = w.id
+WHERE p.deleted_at IS NULL
 GROUP BY p.id, p.name, p.price, s.name`
 
-	countProductsQuery = `SELECT COUNT(*) FROM product`
+	countProductsQuery = `SELECT COUNT(*) FROM product WHERE deleted_at IS NULL`
 
-	getProductDetail = `SELECT p.id, p.name, p.description, p.price, s.id as shop_id, s.name as shop_name, COALESCE(SUM(ws.stock - ws.reserved),0) as available_stock
+	getProductDetail = `SELECT p.id, p.name, p.description, p.price, s.id as shop_id, s.name as shop_name, COALESCE(SUM(CASE WHEN w.status = ? THEN GREATEST(ws.stock - ws.reserved, 0) ELSE 0 END),0) as available_stock
 FROM product p
 JOIN shop s ON p.shop_id = s.id
 LEFT JOIN warehouse_stock ws ON ws.product_id = p.id
-WHERE p.id = ?
+LEFT JOIN warehouse w ON ws.warehouse_id = w.id
+WHERE p.id = ? AND p.deleted_at IS NULL
 GROUP BY p.id, p.name, p.description, p.price, s.id, s.name`
+
+	getAvailableStockQuery = `SELECT COALESCE(SUM(GREATEST(ws.stock - ws.reserved, 0)),0)
+FROM warehouse_stock ws
+JOIN warehouse w ON ws.warehouse_id = w.id
+WHERE ws.product_id = ? AND w.status = ?`
+
+	hasCompletedPurchaseQuery = `SELECT EXISTS(
+SELECT 1 FROM ` + "`order`" + ` o
+JOIN order_item oi ON oi.order_id = o.id
+WHERE o.user_id = ? AND oi.product_id = ? AND o.status = ?)`
+
+	insertReviewQuery = `INSERT INTO product_review (user_id, product_id, rating, comment, created_at) VALUES (?, ?, ?, ?, NOW())`
+
+	listReviewsQuery = `SELECT id, user_id, product_id, rating, comment, created_at FROM product_review WHERE product_id = ? ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	countReviewsQuery = `SELECT COUNT(*) FROM product_review WHERE product_id = ?`
+
+	// the feed only needs to know whether any active warehouse still has stock,
+	// EXISTS stops at the first such row instead of summing every warehouse
+	listFeedQuery = `SELECT p.id, p.name, p.price, COALESCE(p.image_url, '') as image_url,
+EXISTS(SELECT 1 FROM warehouse_stock ws JOIN warehouse w ON w.id = ws.warehouse_id
+WHERE ws.product_id = p.id AND w.status = ? AND ws.stock - ws.reserved > 0) as available
+FROM product p
+WHERE p.id > ? AND p.deleted_at IS NULL
+ORDER BY p.id
+LIMIT ?`
+
+	// rows at exactly the cursor time are compared by id, so products sharing an
+	// updated_at second are neither skipped nor repeated across pages
+	listChangedSinceQuery = `SELECT p.id, p.shop_id, p.name, COALESCE(p.description, '') as description, p.price,
+COALESCE(p.image_url, '') as image_url, p.updated_at, p.deleted_at IS NOT NULL as deleted
+FROM product p
+WHERE p.updated_at > ? OR (p.updated_at = ? AND p.id > ?)
+ORDER BY p.updated_at, p.id
+LIMIT ?`
+
+	// IGNORE makes subscribing again a no-op
+	addStockSubscriptionQuery = `INSERT IGNORE INTO stock_subscription (user_id, product_id, created_at) VALUES (?, ?, NOW())`
+
+	listStockSubscribersQuery = `SELECT user_id FROM stock_subscription WHERE product_id = ? ORDER BY id`
+
+	deleteStockSubscriptionQuery = `DELETE FROM stock_subscription WHERE user_id = ? AND product_id = ?`
+
+	// the product is only inserted when its shop exists, a product of an unknown
+	// shop would never show up in the listings joining shop
+	insertProductQuery = `INSERT INTO product (shop_id, name, description, price) SELECT id, ?, ?, ? FROM shop WHERE id = ?`
+
+	lockProductQuery = `SELECT id FROM product WHERE id = ? AND deleted_at IS NULL FOR UPDATE`
+
+	shopExistsQuery = `SELECT EXISTS(SELECT 1 FROM shop WHERE id = ?)`
+
+	// a product deleted already is left as it is, keeping its original deleted_at
+	softDeleteProductQuery = `UPDATE product SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`
+
+	reviewStatsQuery = `SELECT COALESCE(SUM(rating),0) as rating_sum, COUNT(*) as review_count FROM product_review WHERE product_id = ?`
 )
 
-func (s *SQL) List(ctx context.Context, page, perPage int) ([]model.ProductListItem, int64, error) {
+// productSortClauses whitelists the ORDER BY of each sort, p.id breaks ties so pages are stable
+var productSortClauses = map[constant.ProductSort]string{
+	constant.ProductSortDefault:   " ORDER BY p.id",
+	constant.ProductSortPriceAsc:  " ORDER BY p.price ASC, p.id",
+	constant.ProductSortPriceDesc: " ORDER BY p.price DESC, p.id",
+	constant.ProductSortNameAsc:   " ORDER BY p.name ASC, p.id",
+	constant.ProductSortNewest:    " ORDER BY p.id DESC",
+}
+
+func (s *SQL) List(ctx context.Context, page, perPage int, sort constant.ProductSort) ([]model.ProductListItem, int64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
 	offset := (page - 1) * perPage
 
-	query := listProductsBase + " ORDER BY p.id LIMIT ? OFFSET ?"
-	rows, err := s.conn.QueryxContext(ctx, query, perPage, offset)
+	orderBy, ok := productSortClauses[sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("unsupported product sort %q", sort)
+	}
+	query := listProductsBase + orderBy + " LIMIT ? OFFSET ?"
+	rows, err := s.conn.QueryxContext(ctx, query, constant.WarehouseStatusActive, perPage, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -67,9 +166,245 @@ func (s *SQL) List(ctx context.Context, page, perPage int) ([]model.ProductListI
 }
 
 func (s *SQL) GetByID(ctx context.Context, id uint64) (*model.ProductDetail, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
 	var detail model.ProductDetail
-	if err := s.conn.QueryRowxContext(ctx, getProductDetail, id).StructScan(&detail); err != nil {
+	if err := s.conn.QueryRowxContext(ctx, getProductDetail, constant.WarehouseStatusActive, id).StructScan(&detail); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
 		return nil, fmt.Errorf("%w", err)
 	}
 	return &detail, nil
 }
+
+// GetAvailableStock is the available stock GetByID reports, on its own
+func (s *SQL) GetAvailableStock(ctx context.Context, id uint64) (int64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var available int64
+	if err := s.conn.GetContext(ctx, &available, getAvailableStockQuery, id, constant.WarehouseStatusActive); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
+
+func (s *SQL) HasCompletedPurchase(ctx context.Context, userID, productID uint64) (bool, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	if err := s.conn.GetContext(ctx, &exists, hasCompletedPurchaseQuery, userID, productID, constant.OrderStatusCompleted); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// CreateReview stores a review. A user reviewing the same product again hits the
+// (user_id, product_id) unique key and is reported as ErrReviewExists.
+func (s *SQL) CreateReview(ctx context.Context, review *model.ProductReview) (*model.ProductReview, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := s.conn.ExecContext(ctx, insertReviewQuery, review.UserID, review.ProductID, review.Rating, review.Comment)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, errors.SetCustomError(constant.ErrReviewExists)
+		}
+		return nil, err
+	}
+
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	review.ID = model.ID(lastID)
+	return review, nil
+}
+
+// mysqlErrDuplicateEntry is ER_DUP_ENTRY, raised when a unique key is violated
+const mysqlErrDuplicateEntry = 1062
+
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return goerrors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry
+}
+
+func (s *SQL) ListReviews(ctx context.Context, productID uint64, page, perPage int) ([]model.ProductReview, int64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	offset := (page - 1) * perPage
+
+	items := make([]model.ProductReview, 0)
+	if err := s.conn.SelectContext(ctx, &items, listReviewsQuery, productID, perPage, offset); err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := s.conn.GetContext(ctx, &total, countReviewsQuery, productID); err != nil {
+		return nil, 0, err
+	}
+
+	return items, total, nil
+}
+
+func (s *SQL) GetReviewStats(ctx context.Context, productID uint64) (*model.ReviewStats, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var stats model.ReviewStats
+	if err := s.conn.GetContext(ctx, &stats, reviewStatsQuery, productID); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (s *SQL) ListFeed(ctx context.Context, afterID uint64, limit int) ([]model.ProductFeedItem, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	items := make([]model.ProductFeedItem, 0)
+	if err := s.conn.SelectContext(ctx, &items, listFeedQuery, constant.WarehouseStatusActive, afterID, limit); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *SQL) ListChangedSince(ctx context.Context, after model.ProductSyncCursor, limit int) ([]model.ProductSyncItem, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	items := make([]model.ProductSyncItem, 0)
+	if err := s.conn.SelectContext(ctx, &items, listChangedSinceQuery, after.UpdatedAt, after.UpdatedAt, after.ID, limit); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *SQL) AddStockSubscription(ctx context.Context, userID, productID uint64) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := s.conn.ExecContext(ctx, addStockSubscriptionQuery, userID, productID)
+	return err
+}
+
+func (s *SQL) ListStockSubscribers(ctx context.Context, productID uint64) ([]uint64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	userIDs := make([]uint64, 0)
+	if err := s.conn.SelectContext(ctx, &userIDs, listStockSubscribersQuery, productID); err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+func (s *SQL) DeleteStockSubscription(ctx context.Context, userID, productID uint64) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := s.conn.ExecContext(ctx, deleteStockSubscriptionQuery, userID, productID)
+	return err
+}
+
+// SoftDelete hides the product from listings and new orders, its rows stay so
+// existing orders keep resolving. It reports false when there is no product
+// left to delete.
+func (s *SQL) SoftDelete(ctx context.Context, id uint64) (bool, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := s.conn.ExecContext(ctx, softDeleteProductQuery, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Create inserts the product and returns its id, sql.ErrNoRows when the shop
+// doesn't exist
+func (s *SQL) Create(ctx context.Context, req *model.ProductRequest) (uint64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := s.conn.ExecContext(ctx, insertProductQuery, req.Name, req.Description, req.Price, req.ShopID)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return 0, sql.ErrNoRows
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+// Update sets the fields of req that are set. It returns sql.ErrNoRows when the
+// product doesn't exist or is deleted, or when it is moved to a missing shop.
+func (s *SQL) Update(ctx context.Context, id uint64, req *model.ProductUpdateRequest) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var (
+		sets []string
+		args []any
+	)
+	if req.ShopID != nil {
+		sets, args = append(sets, "shop_id = ?"), append(args, *req.ShopID)
+	}
+	if req.Name != nil {
+		sets, args = append(sets, "name = ?"), append(args, *req.Name)
+	}
+	if req.Description != nil {
+		sets, args = append(sets, "description = ?"), append(args, *req.Description)
+	}
+	if req.Price != nil {
+		sets, args = append(sets, "price = ?"), append(args, *req.Price)
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+
+	// MySQL reports no affected rows when nothing changed, so the product is
+	// looked up first instead of trusting the update's row count
+	tx, err := s.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lockedID uint64
+	if err := tx.GetContext(ctx, &lockedID, lockProductQuery, id); err != nil {
+		return err
+	}
+	if req.ShopID != nil {
+		var exists bool
+		if err := tx.GetContext(ctx, &exists, shopExistsQuery, *req.ShopID); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+	}
+
+	q := "UPDATE product SET " + strings.Join(sets, ", ") + " WHERE id = ?"
+	if _, err := tx.ExecContext(ctx, q, append(args, id)...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,359 @@
+package product_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/model"
+	productrepo "github.com/muhammadheryan/e-commerce/repository/product"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
+)
+
+// openTestDB connects to a migrated MySQL database given by TEST_DB_DSN.
+// Tests are skipped when it is not set.
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set, skipping repository integration test")
+	}
+	db, err := sqlx.Connect("mysql", dsn)
+	if err != nil {
+		t.Fatalf("connect db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func mustInsert(t *testing.T, db *sqlx.DB, query string, args ...any) uint64 {
+	t.Helper()
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatalf("last insert id: %v", err)
+	}
+	return uint64(id)
+}
+
+func TestProductRepository_AvailableStockExcludesInactiveWarehouse(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-inactive-wh")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-inactive-wh", "", 1000)
+	activeWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "active-wh", constant.WarehouseStatusActive)
+	inactiveWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "inactive-wh", constant.WarehouseStatusInactive)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", activeWH, productID, 10, 2)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", inactiveWH, productID, 50, 0)
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := productrepo.NewProductRepository(db)
+
+	detail, err := repo.GetByID(ctx, productID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if detail.AvailableStock != 8 {
+		t.Fatalf("GetByID() AvailableStock = %d, want 8", detail.AvailableStock)
+	}
+	available, err := repo.GetAvailableStock(ctx, productID)
+	if err != nil {
+		t.Fatalf("GetAvailableStock() error = %v", err)
+	}
+	if available != 8 {
+		t.Fatalf("GetAvailableStock() = %d, want 8", available)
+	}
+
+	var total int64
+	if err := db.Get(&total, "SELECT COUNT(*) FROM product"); err != nil {
+		t.Fatalf("count products: %v", err)
+	}
+	items, _, err := repo.List(ctx, 1, int(total), constant.ProductSortDefault)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	found := false
+	for _, it := range items {
+		if uint64(it.ID) == productID {
+			found = true
+			if it.AvailableStock != 8 {
+				t.Fatalf("List() AvailableStock = %d, want 8", it.AvailableStock)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("List() did not return product %d", productID)
+	}
+}
+
+func TestProductRepository_AvailableStockIgnoresDriftedRow(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-drifted")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-drifted", "", 1000)
+	driftedWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "drifted-wh", constant.WarehouseStatusActive)
+	healthyWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "healthy-wh", constant.WarehouseStatusActive)
+	// reserved > stock, summed as is this row would eat 3 of the healthy row's 5
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", driftedWH, productID, 2, 5)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", healthyWH, productID, 5, 0)
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := productrepo.NewProductRepository(db)
+	detail, err := repo.GetByID(ctx, productID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if detail.AvailableStock != 5 {
+		t.Fatalf("GetByID() AvailableStock = %d, want 5", detail.AvailableStock)
+	}
+	if available, err := repo.GetAvailableStock(ctx, productID); err != nil || available != 5 {
+		t.Fatalf("GetAvailableStock() = %d, %v, want 5", available, err)
+	}
+}
+
+func TestProductRepository_ListFeedAvailabilityAndPaging(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-feed")
+	inStock := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price, image_url) VALUES (?, ?, ?, ?, ?)", shopID, "feed-in-stock", "", 1000, "https://img.example.com/1.jpg")
+	allReserved := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "feed-all-reserved", "", 1000)
+	inactiveOnly := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "feed-inactive-only", "", 1000)
+	activeWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "feed-active-wh", constant.WarehouseStatusActive)
+	inactiveWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "feed-inactive-wh", constant.WarehouseStatusInactive)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", activeWH, inStock, 5, 1)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", activeWH, allReserved, 3, 3)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", inactiveWH, inactiveOnly, 9, 0)
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id IN (?, ?, ?)", inStock, allReserved, inactiveOnly)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := productrepo.NewProductRepository(db)
+
+	first, err := repo.ListFeed(ctx, inStock-1, 2)
+	if err != nil {
+		t.Fatalf("ListFeed() error = %v", err)
+	}
+	if len(first) != 2 || uint64(first[0].ID) != inStock || uint64(first[1].ID) != allReserved {
+		t.Fatalf("first page = %+v, want products %d and %d", first, inStock, allReserved)
+	}
+	if !first[0].Available || first[0].ImageURL != "https://img.example.com/1.jpg" {
+		t.Fatalf("in-stock item = %+v, want available with image", first[0])
+	}
+	if first[1].Available {
+		t.Fatalf("fully reserved product reported available")
+	}
+
+	second, err := repo.ListFeed(ctx, uint64(first[1].ID), 2)
+	if err != nil {
+		t.Fatalf("ListFeed() error = %v", err)
+	}
+	if len(second) == 0 || uint64(second[0].ID) != inactiveOnly || second[0].Available {
+		t.Fatalf("second page = %+v, want unavailable product %d first", second, inactiveOnly)
+	}
+}
+
+func TestProductRepository_ListChangedSince(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-sync")
+	old := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)", shopID, "sync-old", "", 1000, "2020-01-01 00:00:00", "2020-01-01 00:00:00")
+	updated := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)", shopID, "sync-updated", "", 1000, "2020-01-01 00:00:00", "2020-01-01 00:00:00")
+	deleted := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)", shopID, "sync-deleted", "", 1000, "2020-01-01 00:00:00", "2020-01-01 00:00:00")
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM product WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	var since time.Time
+	if err := db.Get(&since, "SELECT CURRENT_TIMESTAMP"); err != nil {
+		t.Fatalf("read db clock: %v", err)
+	}
+	created := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "sync-new", "", 1000)
+	if _, err := db.Exec("UPDATE product SET price = 2000 WHERE id = ?", updated); err != nil {
+		t.Fatalf("update product: %v", err)
+	}
+	if _, err := db.Exec("UPDATE product SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?", deleted); err != nil {
+		t.Fatalf("delete product: %v", err)
+	}
+
+	repo := productrepo.NewProductRepository(db)
+
+	var items []model.ProductSyncItem
+	after := model.ProductSyncCursor{UpdatedAt: since}
+	for {
+		page, err := repo.ListChangedSince(ctx, after, 1)
+		if err != nil {
+			t.Fatalf("ListChangedSince() error = %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		items = append(items, page...)
+		last := page[len(page)-1]
+		after = model.ProductSyncCursor{UpdatedAt: last.UpdatedAt, ID: uint64(last.ID)}
+	}
+
+	got := map[uint64]model.ProductSyncItem{}
+	for _, it := range items {
+		got[uint64(it.ID)] = it
+	}
+	if _, ok := got[old]; ok {
+		t.Fatalf("unchanged product %d returned", old)
+	}
+	if it, ok := got[created]; !ok || it.Deleted {
+		t.Fatalf("new product %d = %+v, want returned and not deleted", created, it)
+	}
+	if it, ok := got[updated]; !ok || it.Deleted || it.Price != 2000 {
+		t.Fatalf("updated product %d = %+v, want returned with new price", updated, it)
+	}
+	if it, ok := got[deleted]; !ok || !it.Deleted {
+		t.Fatalf("deleted product %d = %+v, want returned and flagged deleted", deleted, it)
+	}
+}
+
+func TestProductRepository_SoftDeleteHidesProduct(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-soft-delete")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-soft-delete", "", 1000)
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := productrepo.NewProductRepository(db)
+	_, totalBefore, err := repo.List(ctx, 1, 1, constant.ProductSortDefault)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	deleted, err := repo.SoftDelete(ctx, productID)
+	if err != nil || !deleted {
+		t.Fatalf("SoftDelete() = %v, %v, want true", deleted, err)
+	}
+	// deleting again finds nothing left to delete
+	if deleted, err := repo.SoftDelete(ctx, productID); err != nil || deleted {
+		t.Fatalf("second SoftDelete() = %v, %v, want false", deleted, err)
+	}
+
+	if _, err := repo.GetByID(ctx, productID); err != sql.ErrNoRows {
+		t.Fatalf("GetByID() error = %v, want sql.ErrNoRows", err)
+	}
+	items, total, err := repo.List(ctx, 1, 1000, constant.ProductSortNewest)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if total != totalBefore-1 {
+		t.Fatalf("List() total = %d, want %d", total, totalBefore-1)
+	}
+	for _, it := range items {
+		if uint64(it.ID) == productID {
+			t.Fatalf("List() returned deleted product %d", productID)
+		}
+	}
+}
+
+func TestProductRepository_CreateAndUpdate(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-write")
+	var productID uint64
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := productrepo.NewProductRepository(db)
+	if _, err := repo.Create(ctx, &model.ProductRequest{ShopID: 987654321, Name: "orphan", Price: 1000}); err != sql.ErrNoRows {
+		t.Fatalf("Create() of an unknown shop error = %v, want sql.ErrNoRows", err)
+	}
+	productID, err := repo.Create(ctx, &model.ProductRequest{ShopID: model.ID(shopID), Name: "test-product-write", Description: "d", Price: 1000})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	price := 1500.0
+	if err := repo.Update(ctx, productID, &model.ProductUpdateRequest{Price: &price}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	// setting the same value again changes no row but still finds the product
+	if err := repo.Update(ctx, productID, &model.ProductUpdateRequest{Price: &price}); err != nil {
+		t.Fatalf("Update() with unchanged values error = %v", err)
+	}
+	detail, err := repo.GetByID(ctx, productID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if detail.Name != "test-product-write" || detail.Description != "d" || detail.Price != 1500 {
+		t.Fatalf("GetByID() = %+v, want only the price changed", detail)
+	}
+
+	missingShop := model.ID(987654321)
+	if err := repo.Update(ctx, productID, &model.ProductUpdateRequest{ShopID: &missingShop}); err != sql.ErrNoRows {
+		t.Fatalf("Update() to an unknown shop error = %v, want sql.ErrNoRows", err)
+	}
+	if err := repo.Update(ctx, 987654321, &model.ProductUpdateRequest{Price: &price}); err != sql.ErrNoRows {
+		t.Fatalf("Update() of a missing product error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestProductRepository_CreateReviewOncePerUser(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-review")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-review", "", 1000)
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM product_review WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := productrepo.NewProductRepository(db)
+	review := func(userID uint64) *model.ProductReview {
+		return &model.ProductReview{UserID: model.ID(userID), ProductID: model.ID(productID), Rating: 4}
+	}
+	if _, err := repo.CreateReview(ctx, review(987654321)); err != nil {
+		t.Fatalf("CreateReview() error = %v", err)
+	}
+	if _, err := repo.CreateReview(ctx, review(987654321)); !errors.IsType(err, constant.ErrReviewExists) {
+		t.Fatalf("second CreateReview() by the same user error = %v, want ErrReviewExists", err)
+	}
+	if _, err := repo.CreateReview(ctx, review(987654322)); err != nil {
+		t.Fatalf("CreateReview() by another user error = %v", err)
+	}
+	if _, total, err := repo.ListReviews(ctx, productID, 1, 10); err != nil || total != 2 {
+		t.Fatalf("ListReviews() total = %d, err = %v, want 2 reviews", total, err)
+	}
+}
@@ -2,9 +2,13 @@ package redis
 
 import (
 	"context"
+	"sort"
+	"strconv"
 	"time"
 
 	redisclient "github.com/muhammadheryan/e-commerce/cmd/redis"
+	"github.com/muhammadheryan/e-commerce/model"
+	goredis "github.com/redis/go-redis/v9"
 )
 
 // Repository defines methods for interacting with Redis key-values
@@ -16,8 +20,34 @@ type RedisRepository interface {
 	SetSession(ctx context.Context, sessionID string, userID uint64, ttl time.Duration) error
 	GetSession(ctx context.Context, sessionID string) (uint64, error)
 	DeleteSession(ctx context.Context, sessionID string) error
+	DeleteAllSessions(ctx context.Context, userID uint64) error
+	ListSessions(ctx context.Context, userID uint64) ([]model.Session, error)
+	AcquireLock(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	ReleaseLock(ctx context.Context, key, token string) error
+	SetVerificationToken(ctx context.Context, token string, userID uint64, ttl time.Duration) error
+	TakeVerificationToken(ctx context.Context, token string) (uint64, error)
 }
 
+const (
+	sessionKeyPrefix      = "session:"
+	userSessionKeyPrefix  = "user_sessions:"
+	verificationKeyPrefix = "email_verification:"
+)
+
+// userSessionsKey is the set of session ids issued to a user
+func userSessionsKey(userID uint64) string {
+	return userSessionKeyPrefix + strconv.FormatUint(userID, 10)
+}
+
+// releaseLockScript deletes the lock only while it still holds the caller's
+// token, so a holder whose lock expired can't release the next holder's
+var releaseLockScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
 type redis struct {
 	// *redis.Client
 }
@@ -67,14 +97,23 @@ func (r *redis) Delete(ctx context.Context, key string) error {
 	return client.Del(ctx, key).Err()
 }
 
-// SetSession stores a session with userID and TTL
+// SetSession stores a session with userID and TTL, and indexes it under the
+// user so all of their sessions can be revoked at once
 func (r *redis) SetSession(ctx context.Context, sessionID string, userID uint64, ttl time.Duration) error {
 	client := redisclient.Get()
 	if client == nil {
 		return nil
 	}
-	key := "session:" + sessionID
-	return client.Set(ctx, key, userID, ttl).Err()
+	key := sessionKeyPrefix + sessionID
+	setKey := userSessionsKey(userID)
+	pipe := client.TxPipeline()
+	pipe.Set(ctx, key, userID, ttl)
+	pipe.SAdd(ctx, setKey, sessionID)
+	// the index lives as long as the newest session, stale members are
+	// harmless since their session keys have already expired
+	pipe.Expire(ctx, setKey, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
 // GetSession retrieves userID from session
@@ -83,7 +122,7 @@ func (r *redis) GetSession(ctx context.Context, sessionID string) (uint64, error
 	if client == nil {
 		return 0, nil
 	}
-	key := "session:" + sessionID
+	key := sessionKeyPrefix + sessionID
 	val, err := client.Get(ctx, key).Uint64()
 	if err != nil {
 		return 0, err
@@ -97,6 +136,126 @@ func (r *redis) DeleteSession(ctx context.Context, sessionID string) error {
 	if client == nil {
 		return nil
 	}
-	key := "session:" + sessionID
-	return client.Del(ctx, key).Err()
+	key := sessionKeyPrefix + sessionID
+	userID, err := client.Get(ctx, key).Uint64()
+	if err != nil && err != goredis.Nil {
+		return err
+	}
+	pipe := client.TxPipeline()
+	pipe.Del(ctx, key)
+	if userID != 0 {
+		pipe.SRem(ctx, userSessionsKey(userID), sessionID)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// DeleteAllSessions removes every session issued to a user
+func (r *redis) DeleteAllSessions(ctx context.Context, userID uint64) error {
+	client := redisclient.Get()
+	if client == nil {
+		return nil
+	}
+	setKey := userSessionsKey(userID)
+	sessionIDs, err := client.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(sessionIDs)+1)
+	for _, id := range sessionIDs {
+		keys = append(keys, sessionKeyPrefix+id)
+	}
+	keys = append(keys, setKey)
+	return client.Del(ctx, keys...).Err()
+}
+
+// ListSessions returns the user's live sessions. Ids whose session key has
+// expired are pruned from the user's set on the way.
+func (r *redis) ListSessions(ctx context.Context, userID uint64) ([]model.Session, error) {
+	sessions := make([]model.Session, 0)
+	client := redisclient.Get()
+	if client == nil {
+		return sessions, nil
+	}
+	setKey := userSessionsKey(userID)
+	sessionIDs, err := client.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessionIDs) == 0 {
+		return sessions, nil
+	}
+
+	pipe := client.Pipeline()
+	ttls := make([]*goredis.DurationCmd, len(sessionIDs))
+	for i, id := range sessionIDs {
+		ttls[i] = pipe.TTL(ctx, sessionKeyPrefix+id)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expired := make([]interface{}, 0)
+	for i, id := range sessionIDs {
+		// a missing key reports a negative ttl, -1 (no expiry) is never set by SetSession
+		ttl := ttls[i].Val()
+		if ttl < 0 {
+			expired = append(expired, id)
+			continue
+		}
+		sessions = append(sessions, model.Session{ID: id, ExpiresAt: now.Add(ttl)})
+	}
+	if len(expired) > 0 {
+		if err := client.SRem(ctx, setKey, expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ExpiresAt.Before(sessions[j].ExpiresAt) })
+	return sessions, nil
+}
+
+// AcquireLock takes the lock at key for token when nobody holds it. The lock
+// expires after ttl in case its holder never releases it.
+func (r *redis) AcquireLock(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	client := redisclient.Get()
+	if client == nil {
+		return true, nil
+	}
+	return client.SetNX(ctx, key, token, ttl).Result()
+}
+
+// ReleaseLock frees the lock at key if token still holds it
+func (r *redis) ReleaseLock(ctx context.Context, key, token string) error {
+	client := redisclient.Get()
+	if client == nil {
+		return nil
+	}
+	return releaseLockScript.Run(ctx, client, []string{key}, token).Err()
+}
+
+// SetVerificationToken stores the user an email verification token was issued to
+func (r *redis) SetVerificationToken(ctx context.Context, token string, userID uint64, ttl time.Duration) error {
+	client := redisclient.Get()
+	if client == nil {
+		return nil
+	}
+	return client.Set(ctx, verificationKeyPrefix+token, userID, ttl).Err()
+}
+
+// TakeVerificationToken returns the user of the token and removes it in the
+// same step, so a token verifies once. It returns 0 for an unknown or expired token.
+func (r *redis) TakeVerificationToken(ctx context.Context, token string) (uint64, error) {
+	client := redisclient.Get()
+	if client == nil {
+		return 0, nil
+	}
+	userID, err := client.GetDel(ctx, verificationKeyPrefix+token).Uint64()
+	if err == goredis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
 }
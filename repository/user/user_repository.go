@@ -6,6 +6,7 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	"github.com/muhammadheryan/e-commerce/model"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
 )
 
 type SQL struct {
@@ -13,8 +14,16 @@ type SQL struct {
 }
 
 type UserRepository interface {
-	Create(ctx context.Context, req *model.UserEntity) (*model.UserEntity, error)
+	CreateTx(ctx context.Context, tx *sqlx.Tx, req *model.UserEntity) (*model.UserEntity, error)
 	Get(ctx context.Context, filter *model.UserFilter) (*model.UserEntity, error)
+	ListAddresses(ctx context.Context, userID uint64) ([]model.UserAddress, error)
+	GetAddress(ctx context.Context, addressID uint64) (*model.UserAddress, error)
+	CreateAddress(ctx context.Context, addr *model.UserAddress) (*model.UserAddress, error)
+	UpdateAddress(ctx context.Context, addr *model.UserAddress) error
+	DeleteAddress(ctx context.Context, userID, addressID uint64) error
+	GetNotificationPrefs(ctx context.Context, userID uint64) (*model.NotificationPrefs, error)
+	UpsertNotificationPrefs(ctx context.Context, userID uint64, prefs *model.NotificationPrefs) error
+	MarkVerified(ctx context.Context, userID uint64) error
 }
 
 func NewUserRepository(conn *sqlx.DB) UserRepository {
@@ -22,12 +31,28 @@ func NewUserRepository(conn *sqlx.DB) UserRepository {
 }
 
 const (
-	insertUserQuery = `INSERT INTO user (name, email, phone, password_hash, created_at) VALUES (?, ?, ?, ?, NOW())`
-	getUserBase     = `SELECT id, name, email, phone, password_hash, created_at, updated_at FROM user WHERE true`
+	insertUserQuery   = `INSERT INTO user (name, email, phone, password_hash, created_at) VALUES (?, ?, ?, ?, NOW())`
+	getUserBase       = `SELECT id, name, email, phone, password_hash, is_verified, created_at, updated_at FROM user WHERE true`
+	markVerifiedQuery = `UPDATE user SET is_verified = 1 WHERE id = ?`
+
+	addressColumns     = `id, user_id, recipient_name, phone, address_line, city, province, postal_code, created_at, updated_at`
+	listAddressesQuery = `SELECT ` + addressColumns + ` FROM user_address WHERE user_id = ? ORDER BY id`
+	getAddressQuery    = `SELECT ` + addressColumns + ` FROM user_address WHERE id = ?`
+	insertAddressQuery = `INSERT INTO user_address (user_id, recipient_name, phone, address_line, city, province, postal_code, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, NOW())`
+	updateAddressQuery = `UPDATE user_address SET recipient_name = ?, phone = ?, address_line = ?, city = ?, province = ?, postal_code = ?, updated_at = NOW() WHERE id = ? AND user_id = ?`
+	deleteAddressQuery = `DELETE FROM user_address WHERE id = ? AND user_id = ?`
+
+	getNotificationPrefsQuery    = `SELECT order_updates, marketing, wishlist_low_stock FROM user_notification_prefs WHERE user_id = ?`
+	upsertNotificationPrefsQuery = `INSERT INTO user_notification_prefs (user_id, order_updates, marketing, wishlist_low_stock, created_at) VALUES (?, ?, ?, ?, NOW())
+ON DUPLICATE KEY UPDATE order_updates = VALUES(order_updates), marketing = VALUES(marketing), wishlist_low_stock = VALUES(wishlist_low_stock), updated_at = NOW()`
 )
 
-func (s *SQL) Create(ctx context.Context, data *model.UserEntity) (*model.UserEntity, error) {
-	result, err := s.conn.ExecContext(ctx, insertUserQuery, data.Name, data.Email, data.Phone, data.PasswordHash)
+// CreateTx inserts the user within tx, so the rows registration adds alongside it commit or roll back together
+func (s *SQL) CreateTx(ctx context.Context, tx *sqlx.Tx, data *model.UserEntity) (*model.UserEntity, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := tx.ExecContext(ctx, insertUserQuery, data.Name, data.Email, data.Phone, data.PasswordHash)
 	if err != nil {
 		return nil, err
 	}
@@ -37,11 +62,14 @@ func (s *SQL) Create(ctx context.Context, data *model.UserEntity) (*model.UserEn
 		return nil, err
 	}
 
-	data.ID = uint64(lastID)
+	data.ID = model.ID(lastID)
 	return data, nil
 }
 
 func (s *SQL) Get(ctx context.Context, filter *model.UserFilter) (*model.UserEntity, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
 	query := getUserBase
 	args := make([]any, 0, 3)
 
@@ -50,7 +78,8 @@ func (s *SQL) Get(ctx context.Context, filter *model.UserFilter) (*model.UserEnt
 		args = append(args, filter.ID)
 	}
 	if filter.Email != "" {
-		query += " AND email = ?"
+		// accounts registered before emails were lowercased may be stored mixed case
+		query += " AND LOWER(email) = LOWER(?)"
 		args = append(args, filter.Email)
 	}
 	if filter.Phone != "" {
@@ -67,3 +96,94 @@ func (s *SQL) Get(ctx context.Context, filter *model.UserFilter) (*model.UserEnt
 	}
 	return &entity, nil
 }
+
+func (s *SQL) ListAddresses(ctx context.Context, userID uint64) ([]model.UserAddress, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	addresses := make([]model.UserAddress, 0)
+	if err := s.conn.SelectContext(ctx, &addresses, listAddressesQuery, userID); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+func (s *SQL) GetAddress(ctx context.Context, addressID uint64) (*model.UserAddress, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var addr model.UserAddress
+	if err := s.conn.QueryRowxContext(ctx, getAddressQuery, addressID).StructScan(&addr); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &addr, nil
+}
+
+func (s *SQL) CreateAddress(ctx context.Context, addr *model.UserAddress) (*model.UserAddress, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := s.conn.ExecContext(ctx, insertAddressQuery, addr.UserID, addr.RecipientName, addr.Phone, addr.AddressLine, addr.City, addr.Province, addr.PostalCode)
+	if err != nil {
+		return nil, err
+	}
+
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	addr.ID = model.ID(lastID)
+	return addr, nil
+}
+
+func (s *SQL) UpdateAddress(ctx context.Context, addr *model.UserAddress) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := s.conn.ExecContext(ctx, updateAddressQuery, addr.RecipientName, addr.Phone, addr.AddressLine, addr.City, addr.Province, addr.PostalCode, addr.ID, addr.UserID)
+	return err
+}
+
+func (s *SQL) DeleteAddress(ctx context.Context, userID, addressID uint64) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := s.conn.ExecContext(ctx, deleteAddressQuery, addressID, userID)
+	return err
+}
+
+// GetNotificationPrefs returns nil when the user never saved preferences
+func (s *SQL) GetNotificationPrefs(ctx context.Context, userID uint64) (*model.NotificationPrefs, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var prefs model.NotificationPrefs
+	if err := s.conn.QueryRowxContext(ctx, getNotificationPrefsQuery, userID).StructScan(&prefs); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+func (s *SQL) UpsertNotificationPrefs(ctx context.Context, userID uint64, prefs *model.NotificationPrefs) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := s.conn.ExecContext(ctx, upsertNotificationPrefsQuery, userID, prefs.OrderUpdates, prefs.Marketing, prefs.WishlistLowStock)
+	return err
+}
+
+// MarkVerified records that the user confirmed their email, doing it again changes nothing
+func (s *SQL) MarkVerified(ctx context.Context, userID uint64) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := s.conn.ExecContext(ctx, markVerifiedQuery, userID)
+	return err
+}
@@ -0,0 +1,13 @@
+package warehouse
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	negativeAvailableStock = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "warehouse_stock_negative_available_total",
+		Help: "Warehouse stock rows seen with more reserved than in stock, by where they were seen.",
+	}, []string{"source"})
+)
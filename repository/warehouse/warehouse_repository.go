@@ -7,6 +7,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/muhammadheryan/e-commerce/constant"
 	"github.com/muhammadheryan/e-commerce/model"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
 	"github.com/muhammadheryan/e-commerce/utils/errors"
 	"github.com/muhammadheryan/e-commerce/utils/logger"
 	"go.uber.org/zap"
@@ -14,15 +15,28 @@ import (
 
 type WarehouseRepository interface {
 	GetTotalAvailableStockTx(ctx context.Context, tx *sqlx.Tx, productID uint64) (int64, error)
+	GetTotalAvailableStock(ctx context.Context, productID uint64) (int64, error)
 	ReserveStockTx(ctx context.Context, tx *sqlx.Tx, req *model.ReserveRequest) error
 	GetReservationsByOrderTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) ([]model.Reservation, error)
 	CommitReservationsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) error
 	ReleaseReservationsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) error
+	GetFulfillmentByOrderTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) ([]model.OrderFulfillment, error)
+	RestockFulfillmentTx(ctx context.Context, tx *sqlx.Tx, fulfillment []model.OrderFulfillment) error
 	GetWarehouseByID(ctx context.Context, warehouseID uint64) (*model.WarehouseEntity, error)
 	CheckReservedStock(ctx context.Context, warehouseID uint64) (int64, error)
+	ListReservationHolders(ctx context.Context, warehouseID uint64) (orderIDs, cartIDs []model.ID, err error)
 	UpdateWarehouseStatus(ctx context.Context, warehouseID uint64, status constant.WarehouseStatus) error
+	UpdateWarehouseStatusTx(ctx context.Context, tx *sqlx.Tx, warehouseID uint64, status constant.WarehouseStatus) error
+	GetWarehouseStocksTx(ctx context.Context, tx *sqlx.Tx, warehouseID uint64) ([]model.WarehouseStock, error)
 	GetWarehouseStock(ctx context.Context, warehouseID uint64, productID uint64) (*model.WarehouseStock, error)
 	TransferStockTx(ctx context.Context, tx *sqlx.Tx, req *model.TransferStockRequest) error
+	ListWarehouseSummaries(ctx context.Context, shopID uint64) ([]model.WarehouseSummary, error)
+	ListLowStockProducts(ctx context.Context, threshold int64) ([]model.LowStockItem, error)
+	ReconcileReservedTx(ctx context.Context, tx *sqlx.Tx, warehouseID uint64) ([]model.ReservedDiscrepancy, error)
+	AdjustStockTx(ctx context.Context, tx *sqlx.Tx, req *model.StockAdjustmentRequest) error
+	ReleaseCartReservationsTx(ctx context.Context, tx *sqlx.Tx, cartID, productID uint64) error
+	ReleaseExpiredCartReservationsTx(ctx context.Context, tx *sqlx.Tx, limit int) (int, error)
+	GetCartReservedQuantities(ctx context.Context, userID uint64) (map[uint64]int64, error)
 }
 
 type SQL struct {
@@ -34,20 +48,53 @@ func NewWarehouseRepository(conn *sqlx.DB) WarehouseRepository {
 }
 
 func (r *SQL) GetTotalAvailableStockTx(ctx context.Context, tx *sqlx.Tx, productID uint64) (int64, error) {
-	var total sql.NullInt64
-	q := "SELECT COALESCE(SUM(ws.stock - ws.reserved),0) as total FROM warehouse_stock ws JOIN warehouse w ON ws.warehouse_id = w.id WHERE ws.product_id = ? AND w.status = ?"
-	if err := tx.GetContext(ctx, &total, q, productID, constant.WarehouseStatusActive); err != nil {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	return getTotalAvailableStock(ctx, tx, productID)
+}
+
+func (r *SQL) GetTotalAvailableStock(ctx context.Context, productID uint64) (int64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	total, err := getTotalAvailableStock(ctx, r.conn, productID)
+	if err != nil {
+		logger.Error("[GetTotalAvailableStock] query failed", zap.String("error", err.Error()), zap.Uint64("product_id", productID))
 		return 0, err
 	}
-	if !total.Valid {
+	return total, nil
+}
+
+// getTotalAvailableStock sums stock - reserved of a product across active warehouses.
+// A drifted row with more reserved than stock counts as 0 rather than taking
+// availability from the other warehouses, and is reported.
+func getTotalAvailableStock(ctx context.Context, q sqlx.QueryerContext, productID uint64) (int64, error) {
+	var res struct {
+		Total   sql.NullInt64 `db:"total"`
+		Drifted int64         `db:"drifted"`
+	}
+	query := "SELECT COALESCE(SUM(GREATEST(ws.stock - ws.reserved, 0)),0) as total, COALESCE(SUM(ws.reserved > ws.stock),0) as drifted FROM warehouse_stock ws JOIN warehouse w ON ws.warehouse_id = w.id WHERE ws.product_id = ? AND w.status = ?"
+	if err := sqlx.GetContext(ctx, q, &res, query, productID, constant.WarehouseStatusActive); err != nil {
+		return 0, err
+	}
+	if res.Drifted > 0 {
+		negativeAvailableStock.WithLabelValues("availability").Add(float64(res.Drifted))
+		logger.Warn("[getTotalAvailableStock] reserved exceeds stock", zap.Uint64("product_id", productID), zap.Int64("rows", res.Drifted))
+	}
+	if !res.Total.Valid {
 		return 0, nil
 	}
-	return total.Int64, nil
+	return res.Total.Int64, nil
 }
 
 func (r *SQL) ReserveStockTx(ctx context.Context, tx *sqlx.Tx, req *model.ReserveRequest) error {
-	// Lock rows for this product to avoid races
-	rows, err := tx.QueryxContext(ctx, "SELECT ws.id, ws.warehouse_id, ws.stock, ws.reserved FROM warehouse_stock ws JOIN warehouse w ON ws.warehouse_id = w.id WHERE ws.product_id = ? AND w.status = ? FOR UPDATE", req.ProductID, constant.WarehouseStatusActive)
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	// Lock rows for this product to avoid races, in the order stock is allocated
+	query := "SELECT ws.id, ws.warehouse_id, ws.stock, ws.reserved FROM warehouse_stock ws JOIN warehouse w ON ws.warehouse_id = w.id WHERE ws.product_id = ? AND w.status = ? ORDER BY " + allocationOrder(req.Allocation) + " FOR UPDATE"
+	rows, err := tx.QueryxContext(ctx, query, req.ProductID, constant.WarehouseStatusActive)
 	if err != nil {
 		logger.Error("[ReserveStockTx] query failed", zap.String("error", err.Error()), zap.Uint64("product_id", req.ProductID))
 		return err
@@ -76,6 +123,10 @@ func (r *SQL) ReserveStockTx(ctx context.Context, tx *sqlx.Tx, req *model.Reserv
 
 	for _, w := range rowsList {
 		avail := w.Stock - w.Reserved
+		if avail < 0 {
+			negativeAvailableStock.WithLabelValues("reserve").Inc()
+			logger.Warn("[ReserveStockTx] reserved exceeds stock", zap.Int64("warehouse_id", w.WarehouseID), zap.Uint64("product_id", req.ProductID), zap.Int64("stock", w.Stock), zap.Int64("reserved", w.Reserved))
+		}
 		if avail <= 0 {
 			continue
 		}
@@ -85,12 +136,16 @@ func (r *SQL) ReserveStockTx(ctx context.Context, tx *sqlx.Tx, req *model.Reserv
 		}
 
 		// update reserved
-		if _, err := tx.ExecContext(ctx, "UPDATE warehouse_stock SET reserved = reserved + ? WHERE id = ?", alloc, w.ID); err != nil {
+		if _, err := tx.ExecContext(ctx, "UPDATE warehouse_stock SET reserved = reserved + ?, updated_at = NOW() WHERE id = ?", alloc, w.ID); err != nil {
 			logger.Error("[ReserveStockTx] update reserved failed", zap.String("error", err.Error()), zap.Int64("warehouse_stock_id", w.ID), zap.Int64("alloc", alloc))
 			return err
 		}
 		// insert reservation record with expires_at
-		if _, err := tx.ExecContext(ctx, "INSERT INTO stock_reservation (order_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, ?)", req.OrderID, w.WarehouseID, req.ProductID, alloc, req.ExpiresAt); err != nil {
+		var cartID *uint64
+		if req.CartID != 0 {
+			cartID = &req.CartID
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO stock_reservation (order_id, cart_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, ?, ?)", req.OrderID, cartID, w.WarehouseID, req.ProductID, alloc, req.ExpiresAt); err != nil {
 			logger.Error("[ReserveStockTx] insert reservation failed", zap.String("error", err.Error()), zap.Uint64("order_id", req.OrderID), zap.Int64("warehouse_id", w.WarehouseID), zap.Uint64("product_id", req.ProductID), zap.Int64("alloc", alloc))
 			return err
 		}
@@ -107,7 +162,19 @@ func (r *SQL) ReserveStockTx(ctx context.Context, tx *sqlx.Tx, req *model.Reserv
 	return nil
 }
 
+// allocationOrder is the ORDER BY for a stock allocation strategy, priority unless
+// available is asked for. The warehouse id keeps the order deterministic on ties.
+func allocationOrder(strategy string) string {
+	if strategy == constant.StockAllocationAvailable {
+		return "(ws.stock - ws.reserved) DESC, w.priority DESC, ws.warehouse_id"
+	}
+	return "w.priority DESC, (ws.stock - ws.reserved) DESC, ws.warehouse_id"
+}
+
 func (r *SQL) GetReservationsByOrderTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) ([]model.Reservation, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
 	rows, err := tx.QueryxContext(ctx, "SELECT id, warehouse_id, product_id, quantity FROM stock_reservation WHERE order_id = ? FOR UPDATE", orderID)
 	if err != nil {
 		logger.Error("[GetReservationsByOrderTx] query failed", zap.String("error", err.Error()), zap.Uint64("order_id", orderID))
@@ -128,16 +195,24 @@ func (r *SQL) GetReservationsByOrderTx(ctx context.Context, tx *sqlx.Tx, orderID
 }
 
 func (r *SQL) CommitReservationsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
 	reservations, err := r.GetReservationsByOrderTx(ctx, tx, orderID)
 	if err != nil {
 		return err
 	}
 	for _, reservation := range reservations {
 		// decrease stock and reserved
-		if _, err := tx.ExecContext(ctx, "UPDATE warehouse_stock SET stock = stock - ?, reserved = reserved - ? WHERE warehouse_id = ? AND product_id = ?", reservation.Quantity, reservation.Quantity, reservation.WarehouseID, reservation.ProductID); err != nil {
+		if _, err := tx.ExecContext(ctx, "UPDATE warehouse_stock SET stock = stock - ?, reserved = reserved - ?, updated_at = NOW() WHERE warehouse_id = ? AND product_id = ?", reservation.Quantity, reservation.Quantity, reservation.WarehouseID, reservation.ProductID); err != nil {
 			logger.Error("[CommitReservationsTx] update stock failed", zap.String("error", err.Error()), zap.Uint64("order_id", orderID), zap.Int64("warehouse_id", reservation.WarehouseID), zap.Uint64("product_id", reservation.ProductID))
 			return err
 		}
+		// keep where the quantity came from, refunds put it back there
+		if _, err := tx.ExecContext(ctx, "INSERT INTO order_fulfillment (order_id, warehouse_id, product_id, quantity) VALUES (?, ?, ?, ?)", orderID, reservation.WarehouseID, reservation.ProductID, reservation.Quantity); err != nil {
+			logger.Error("[CommitReservationsTx] insert fulfillment failed", zap.String("error", err.Error()), zap.Uint64("order_id", orderID), zap.Int64("warehouse_id", reservation.WarehouseID), zap.Uint64("product_id", reservation.ProductID))
+			return err
+		}
 		// delete reservation row
 		if _, err := tx.ExecContext(ctx, "DELETE FROM stock_reservation WHERE id = ?", reservation.ID); err != nil {
 			logger.Error("[CommitReservationsTx] delete reservation failed", zap.String("error", err.Error()), zap.Int64("reservation_id", reservation.ID))
@@ -147,20 +222,101 @@ func (r *SQL) CommitReservationsTx(ctx context.Context, tx *sqlx.Tx, orderID uin
 	return nil
 }
 
+// checkWarehousesActiveTx makes sure every warehouse exists and is active. The
+// rows are share locked so neither can be deactivated before the tx ends.
+func checkWarehousesActiveTx(ctx context.Context, tx *sqlx.Tx, warehouseIDs ...uint64) error {
+	query, args, err := sqlx.In("SELECT id, status FROM warehouse WHERE id IN (?) LOCK IN SHARE MODE", warehouseIDs)
+	if err != nil {
+		return err
+	}
+	var rows []struct {
+		ID     uint64                   `db:"id"`
+		Status constant.WarehouseStatus `db:"status"`
+	}
+	if err := tx.SelectContext(ctx, &rows, tx.Rebind(query), args...); err != nil {
+		logger.Error("[checkWarehousesActiveTx] query failed", zap.String("error", err.Error()))
+		return err
+	}
+	status := make(map[uint64]constant.WarehouseStatus, len(rows))
+	for _, row := range rows {
+		status[row.ID] = row.Status
+	}
+	for _, id := range warehouseIDs {
+		st, ok := status[id]
+		if !ok {
+			return errors.SetCustomError(constant.ErrNotFound)
+		}
+		if st != constant.WarehouseStatusActive {
+			return errors.SetCustomError(constant.ErrWarehouseInactive)
+		}
+	}
+	return nil
+}
+
+// GetFulfillmentByOrderTx returns the per warehouse allocation committed for a paid order
+func (r *SQL) GetFulfillmentByOrderTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) ([]model.OrderFulfillment, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var fulfillment []model.OrderFulfillment
+	query := "SELECT id, order_id, warehouse_id, product_id, quantity FROM order_fulfillment WHERE order_id = ? AND restocked_at IS NULL ORDER BY id FOR UPDATE"
+	if err := tx.SelectContext(ctx, &fulfillment, query, orderID); err != nil {
+		logger.Error("[GetFulfillmentByOrderTx] query failed", zap.String("error", err.Error()), zap.Uint64("order_id", orderID))
+		return nil, err
+	}
+	return fulfillment, nil
+}
+
+// RestockFulfillmentTx puts committed quantities back into the warehouses they
+// were taken from. Each row is marked restocked first, one already marked is
+// skipped, so the same fulfillment never goes back twice.
+func (r *SQL) RestockFulfillmentTx(ctx context.Context, tx *sqlx.Tx, fulfillment []model.OrderFulfillment) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	for _, f := range fulfillment {
+		res, err := tx.ExecContext(ctx, "UPDATE order_fulfillment SET restocked_at = NOW() WHERE id = ? AND restocked_at IS NULL", f.ID)
+		if err != nil {
+			logger.Error("[RestockFulfillmentTx] mark restocked failed", zap.String("error", err.Error()), zap.Int64("fulfillment_id", f.ID))
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE warehouse_stock SET stock = stock + ?, updated_at = NOW() WHERE warehouse_id = ? AND product_id = ?", f.Quantity, f.WarehouseID, f.ProductID); err != nil {
+			logger.Error("[RestockFulfillmentTx] update stock failed", zap.String("error", err.Error()), zap.Uint64("order_id", f.OrderID), zap.Int64("warehouse_id", f.WarehouseID), zap.Uint64("product_id", f.ProductID))
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *SQL) ReleaseReservationsTx(ctx context.Context, tx *sqlx.Tx, orderID uint64) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
 	reservations, err := r.GetReservationsByOrderTx(ctx, tx, orderID)
 	if err != nil {
 		return err
 	}
+	return releaseReservations(ctx, tx, reservations)
+}
+
+// releaseReservations gives reserved quantity back to warehouse_stock and deletes the reservation rows
+func releaseReservations(ctx context.Context, tx *sqlx.Tx, reservations []model.Reservation) error {
 	for _, rr := range reservations {
 		// decrease reserved only
-		if _, err := tx.ExecContext(ctx, "UPDATE warehouse_stock SET reserved = reserved - ? WHERE warehouse_id = ? AND product_id = ?", rr.Quantity, rr.WarehouseID, rr.ProductID); err != nil {
-			logger.Error("[ReleaseReservationsTx] update reserved failed", zap.String("error", err.Error()), zap.Int64("warehouse_id", rr.WarehouseID), zap.Uint64("product_id", rr.ProductID))
+		if _, err := tx.ExecContext(ctx, "UPDATE warehouse_stock SET reserved = reserved - ?, updated_at = NOW() WHERE warehouse_id = ? AND product_id = ?", rr.Quantity, rr.WarehouseID, rr.ProductID); err != nil {
+			logger.Error("[releaseReservations] update reserved failed", zap.String("error", err.Error()), zap.Int64("warehouse_id", rr.WarehouseID), zap.Uint64("product_id", rr.ProductID))
 			return err
 		}
 		// delete reservation row
 		if _, err := tx.ExecContext(ctx, "DELETE FROM stock_reservation WHERE id = ?", rr.ID); err != nil {
-			logger.Error("[ReleaseReservationsTx] delete reservation failed", zap.String("error", err.Error()), zap.Int64("reservation_id", rr.ID))
+			logger.Error("[releaseReservations] delete reservation failed", zap.String("error", err.Error()), zap.Int64("reservation_id", rr.ID))
 			return err
 		}
 	}
@@ -168,8 +324,11 @@ func (r *SQL) ReleaseReservationsTx(ctx context.Context, tx *sqlx.Tx, orderID ui
 }
 
 func (r *SQL) GetWarehouseByID(ctx context.Context, warehouseID uint64) (*model.WarehouseEntity, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
 	var warehouse model.WarehouseEntity
-	query := "SELECT id, shop_id, name, status, created_at, updated_at FROM warehouse WHERE id = ?"
+	query := "SELECT id, shop_id, name, status, priority, created_at, updated_at FROM warehouse WHERE id = ?"
 	err := r.conn.QueryRowxContext(ctx, query, warehouseID).StructScan(&warehouse)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -182,6 +341,9 @@ func (r *SQL) GetWarehouseByID(ctx context.Context, warehouseID uint64) (*model.
 }
 
 func (r *SQL) CheckReservedStock(ctx context.Context, warehouseID uint64) (int64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
 	var total sql.NullInt64
 	query := "SELECT COALESCE(SUM(reserved), 0) as total FROM warehouse_stock WHERE warehouse_id = ?"
 	err := r.conn.GetContext(ctx, &total, query, warehouseID)
@@ -195,11 +357,52 @@ func (r *SQL) CheckReservedStock(ctx context.Context, warehouseID uint64) (int64
 	return total.Int64, nil
 }
 
+// ListReservationHolders lists the orders and carts holding reservations in a warehouse
+func (r *SQL) ListReservationHolders(ctx context.Context, warehouseID uint64) (orderIDs, cartIDs []model.ID, err error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var holders []struct {
+		OrderID model.ID      `db:"order_id"`
+		CartID  sql.NullInt64 `db:"cart_id"`
+	}
+	query := "SELECT DISTINCT order_id, cart_id FROM stock_reservation WHERE warehouse_id = ? ORDER BY order_id, cart_id"
+	if err := r.conn.SelectContext(ctx, &holders, query, warehouseID); err != nil {
+		logger.Error("[ListReservationHolders] query failed", zap.String("error", err.Error()), zap.Uint64("warehouse_id", warehouseID))
+		return nil, nil, err
+	}
+
+	orderIDs, cartIDs = make([]model.ID, 0), make([]model.ID, 0)
+	for _, h := range holders {
+		// cart reservations are stored with order_id 0
+		if h.CartID.Valid {
+			cartIDs = append(cartIDs, model.ID(h.CartID.Int64))
+			continue
+		}
+		orderIDs = append(orderIDs, h.OrderID)
+	}
+	return orderIDs, cartIDs, nil
+}
+
 func (r *SQL) UpdateWarehouseStatus(ctx context.Context, warehouseID uint64, status constant.WarehouseStatus) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	return updateWarehouseStatus(ctx, r.conn, warehouseID, status)
+}
+
+func (r *SQL) UpdateWarehouseStatusTx(ctx context.Context, tx *sqlx.Tx, warehouseID uint64, status constant.WarehouseStatus) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	return updateWarehouseStatus(ctx, tx, warehouseID, status)
+}
+
+func updateWarehouseStatus(ctx context.Context, e sqlx.ExecerContext, warehouseID uint64, status constant.WarehouseStatus) error {
 	query := "UPDATE warehouse SET status = ?, updated_at = NOW() WHERE id = ?"
-	result, err := r.conn.ExecContext(ctx, query, status, warehouseID)
+	result, err := e.ExecContext(ctx, query, status, warehouseID)
 	if err != nil {
-		logger.Error("[UpdateWarehouseStatus] update failed", zap.String("error", err.Error()), zap.Uint64("warehouse_id", warehouseID), zap.Int("status", int(status)))
+		logger.Error("[updateWarehouseStatus] update failed", zap.String("error", err.Error()), zap.Uint64("warehouse_id", warehouseID), zap.Int("status", int(status)))
 		return err
 	}
 	rowsAffected, err := result.RowsAffected()
@@ -212,7 +415,24 @@ func (r *SQL) UpdateWarehouseStatus(ctx context.Context, warehouseID uint64, sta
 	return nil
 }
 
+// GetWarehouseStocksTx locks and returns every stock row of a warehouse
+func (r *SQL) GetWarehouseStocksTx(ctx context.Context, tx *sqlx.Tx, warehouseID uint64) ([]model.WarehouseStock, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	stocks := make([]model.WarehouseStock, 0)
+	query := "SELECT id, warehouse_id, product_id, stock, reserved FROM warehouse_stock WHERE warehouse_id = ? ORDER BY id FOR UPDATE"
+	if err := tx.SelectContext(ctx, &stocks, query, warehouseID); err != nil {
+		logger.Error("[GetWarehouseStocksTx] query failed", zap.String("error", err.Error()), zap.Uint64("warehouse_id", warehouseID))
+		return nil, err
+	}
+	return stocks, nil
+}
+
 func (r *SQL) GetWarehouseStock(ctx context.Context, warehouseID uint64, productID uint64) (*model.WarehouseStock, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
 	var stock model.WarehouseStock
 	query := "SELECT id, warehouse_id, product_id, stock, reserved FROM warehouse_stock WHERE warehouse_id = ? AND product_id = ?"
 	err := r.conn.QueryRowxContext(ctx, query, warehouseID, productID).StructScan(&stock)
@@ -226,7 +446,18 @@ func (r *SQL) GetWarehouseStock(ctx context.Context, warehouseID uint64, product
 	return &stock, nil
 }
 
+// TransferStockTx moves stock between two active warehouses. A missing warehouse
+// is ErrNotFound, an inactive one ErrWarehouseInactive. With MoveReservations
+// every reservation of the product moves too, pending orders and carts are then
+// served from the destination, and a quantity short of them is ErrInvalidRequest.
 func (r *SQL) TransferStockTx(ctx context.Context, tx *sqlx.Tx, req *model.TransferStockRequest) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	if err := checkWarehousesActiveTx(ctx, tx, req.FromWarehouseID, req.ToWarehouseID); err != nil {
+		return err
+	}
+
 	// Get source warehouse stock with lock
 	var fromStock model.WarehouseStock
 	query := "SELECT id, warehouse_id, product_id, stock, reserved FROM warehouse_stock WHERE warehouse_id = ? AND product_id = ? FOR UPDATE"
@@ -239,14 +470,30 @@ func (r *SQL) TransferStockTx(ctx context.Context, tx *sqlx.Tx, req *model.Trans
 		return err
 	}
 
-	// Check available stock (stock - reserved)
-	available := fromStock.Stock - fromStock.Reserved
-	if available < int64(req.Quantity) {
+	// The reservation rows are counted rather than the reserved column, they
+	// are what moves and a drifted count is left to ReconcileReservedTx
+	var moved int64
+	if req.MoveReservations {
+		err = tx.GetContext(ctx, &moved, "SELECT COALESCE(SUM(quantity), 0) FROM stock_reservation WHERE warehouse_id = ? AND product_id = ? FOR UPDATE", req.FromWarehouseID, req.ProductID)
+		if err != nil {
+			logger.Error("[TransferStockTx] sum reservations failed", zap.String("error", err.Error()))
+			return err
+		}
+		if int64(req.Quantity) < moved {
+			return errors.SetCustomError(constant.ErrInvalidRequest)
+		}
+	}
+
+	// Check available stock (stock - reserved) for the unreserved part. A
+	// reserved count drifted below the moved rows would overstate it, the rows
+	// are what the source has to give up.
+	available := fromStock.Stock - max(fromStock.Reserved, moved)
+	if available < int64(req.Quantity)-moved {
 		return errors.SetCustomError(constant.ErrInsufficientStock)
 	}
 
-	// Decrease stock from source warehouse
-	_, err = tx.ExecContext(ctx, "UPDATE warehouse_stock SET stock = stock - ? WHERE id = ?", req.Quantity, fromStock.ID)
+	// Decrease stock from source warehouse, reserved stops at zero when drifted
+	_, err = tx.ExecContext(ctx, "UPDATE warehouse_stock SET stock = stock - ?, reserved = GREATEST(reserved - ?, 0), updated_at = NOW() WHERE id = ?", req.Quantity, moved, fromStock.ID)
 	if err != nil {
 		logger.Error("[TransferStockTx] decrease from stock failed", zap.String("error", err.Error()))
 		return err
@@ -262,7 +509,7 @@ func (r *SQL) TransferStockTx(ctx context.Context, tx *sqlx.Tx, req *model.Trans
 
 	if err == sql.ErrNoRows {
 		// Create new warehouse_stock record
-		result, err := tx.ExecContext(ctx, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, 0)", req.ToWarehouseID, req.ProductID, req.Quantity)
+		result, err := tx.ExecContext(ctx, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", req.ToWarehouseID, req.ProductID, req.Quantity, moved)
 		if err != nil {
 			logger.Error("[TransferStockTx] insert to stock failed", zap.String("error", err.Error()))
 			return err
@@ -271,19 +518,231 @@ func (r *SQL) TransferStockTx(ctx context.Context, tx *sqlx.Tx, req *model.Trans
 		if err != nil {
 			return err
 		}
-		toStock.ID = uint64(lastID)
-		toStock.WarehouseID = req.ToWarehouseID
-		toStock.ProductID = req.ProductID
+		toStock.ID = model.ID(lastID)
+		toStock.WarehouseID = model.ID(req.ToWarehouseID)
+		toStock.ProductID = model.ID(req.ProductID)
 		toStock.Stock = int64(req.Quantity)
-		toStock.Reserved = 0
+		toStock.Reserved = moved
 	} else {
 		// Increase stock in destination warehouse
-		_, err = tx.ExecContext(ctx, "UPDATE warehouse_stock SET stock = stock + ? WHERE id = ?", req.Quantity, toStock.ID)
+		_, err = tx.ExecContext(ctx, "UPDATE warehouse_stock SET stock = stock + ?, reserved = reserved + ?, updated_at = NOW() WHERE id = ?", req.Quantity, moved, toStock.ID)
 		if err != nil {
 			logger.Error("[TransferStockTx] increase to stock failed", zap.String("error", err.Error()))
 			return err
 		}
 	}
 
+	if moved > 0 {
+		// commits and releases follow the row, so the order is fulfilled from the destination
+		_, err = tx.ExecContext(ctx, "UPDATE stock_reservation SET warehouse_id = ? WHERE warehouse_id = ? AND product_id = ?", req.ToWarehouseID, req.FromWarehouseID, req.ProductID)
+		if err != nil {
+			logger.Error("[TransferStockTx] move reservations failed", zap.String("error", err.Error()))
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *SQL) ListWarehouseSummaries(ctx context.Context, shopID uint64) ([]model.WarehouseSummary, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT w.id, w.name, w.status, COALESCE(SUM(ws.stock), 0) as total_stock, COALESCE(SUM(ws.reserved), 0) as total_reserved FROM warehouse w LEFT JOIN warehouse_stock ws ON ws.warehouse_id = w.id"
+	args := make([]any, 0, 1)
+	if shopID != 0 {
+		query += " WHERE w.shop_id = ?"
+		args = append(args, shopID)
+	}
+	query += " GROUP BY w.id, w.name, w.status ORDER BY w.id"
+
+	rows, err := r.conn.QueryxContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("[ListWarehouseSummaries] query failed", zap.String("error", err.Error()), zap.Uint64("shop_id", shopID))
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]model.WarehouseSummary, 0)
+	for rows.Next() {
+		var summary model.WarehouseSummary
+		if err := rows.StructScan(&summary); err != nil {
+			logger.Error("[ListWarehouseSummaries] rows scan failed", zap.String("error", err.Error()))
+			return nil, err
+		}
+		res = append(res, summary)
+	}
+	return res, nil
+}
+
+// ListLowStockProducts lists products whose stock - reserved summed across active
+// warehouses is at most threshold, lowest first. Products without any stock in an
+// active warehouse are included with 0 available.
+func (r *SQL) ListLowStockProducts(ctx context.Context, threshold int64) ([]model.LowStockItem, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT p.id as product_id, p.name, COALESCE(SUM(GREATEST(ws.stock - ws.reserved, 0)), 0) as available FROM product p LEFT JOIN warehouse_stock ws ON ws.product_id = p.id AND ws.warehouse_id IN (SELECT id FROM warehouse WHERE status = ?) GROUP BY p.id, p.name HAVING available <= ? ORDER BY available, p.id"
+
+	res := make([]model.LowStockItem, 0)
+	if err := r.conn.SelectContext(ctx, &res, query, constant.WarehouseStatusActive, threshold); err != nil {
+		logger.Error("[ListLowStockProducts] query failed", zap.String("error", err.Error()), zap.Int64("threshold", threshold))
+		return nil, err
+	}
+	return res, nil
+}
+
+// ReconcileReservedTx sets warehouse_stock.reserved to the sum of the row's
+// stock_reservation quantities wherever the two disagree, for one warehouse or
+// for all of them when warehouseID is zero, and returns what it corrected.
+func (r *SQL) ReconcileReservedTx(ctx context.Context, tx *sqlx.Tx, warehouseID uint64) ([]model.ReservedDiscrepancy, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	stockQuery := "SELECT id, warehouse_id, product_id, stock, reserved FROM warehouse_stock"
+	reservationQuery := "SELECT warehouse_id, product_id, SUM(quantity) as quantity FROM stock_reservation"
+	args := make([]any, 0, 1)
+	if warehouseID != 0 {
+		stockQuery += " WHERE warehouse_id = ?"
+		reservationQuery += " WHERE warehouse_id = ?"
+		args = append(args, warehouseID)
+	}
+	reservationQuery += " GROUP BY warehouse_id, product_id"
+
+	// Reservations are only added or removed together with an update of their
+	// stock row, so once the rows are locked the sums below can't move
+	var stocks []model.WarehouseStock
+	if err := tx.SelectContext(ctx, &stocks, stockQuery+" ORDER BY id FOR UPDATE", args...); err != nil {
+		logger.Error("[ReconcileReservedTx] lock stock failed", zap.String("error", err.Error()), zap.Uint64("warehouse_id", warehouseID))
+		return nil, err
+	}
+
+	var sums []model.Reservation
+	if err := tx.SelectContext(ctx, &sums, reservationQuery, args...); err != nil {
+		logger.Error("[ReconcileReservedTx] sum reservations failed", zap.String("error", err.Error()), zap.Uint64("warehouse_id", warehouseID))
+		return nil, err
+	}
+	type stockKey struct{ warehouseID, productID uint64 }
+	actual := make(map[stockKey]int64, len(sums))
+	for _, s := range sums {
+		actual[stockKey{uint64(s.WarehouseID), s.ProductID}] = s.Quantity
+	}
+
+	discrepancies := make([]model.ReservedDiscrepancy, 0)
+	for _, s := range stocks {
+		want := actual[stockKey{uint64(s.WarehouseID), uint64(s.ProductID)}]
+		if s.Reserved == want {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE warehouse_stock SET reserved = ?, updated_at = NOW() WHERE id = ?", want, s.ID); err != nil {
+			logger.Error("[ReconcileReservedTx] update reserved failed", zap.String("error", err.Error()), zap.Uint64("warehouse_stock_id", uint64(s.ID)))
+			return nil, err
+		}
+		discrepancies = append(discrepancies, model.ReservedDiscrepancy{
+			WarehouseID: s.WarehouseID,
+			ProductID:   s.ProductID,
+			Reserved:    s.Reserved,
+			Actual:      want,
+		})
+	}
+	return discrepancies, nil
+}
+
+func (r *SQL) AdjustStockTx(ctx context.Context, tx *sqlx.Tx, req *model.StockAdjustmentRequest) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var current model.WarehouseStock
+	query := "SELECT id, warehouse_id, product_id, stock, reserved FROM warehouse_stock WHERE warehouse_id = ? AND product_id = ? FOR UPDATE"
+	err := tx.QueryRowxContext(ctx, query, req.WarehouseID, req.ProductID).StructScan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Error("[AdjustStockTx] get stock failed", zap.String("error", err.Error()), zap.Uint64("warehouse_id", req.WarehouseID), zap.Uint64("product_id", req.ProductID))
+		return err
+	}
+
+	if err == sql.ErrNoRows {
+		// Nothing to take away from a row that does not exist yet
+		if req.Quantity < 0 {
+			return errors.SetCustomError(constant.ErrInsufficientStock)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, 0)", req.WarehouseID, req.ProductID, req.Quantity); err != nil {
+			logger.Error("[AdjustStockTx] insert stock failed", zap.String("error", err.Error()), zap.Uint64("warehouse_id", req.WarehouseID), zap.Uint64("product_id", req.ProductID))
+			return err
+		}
+		return nil
+	}
+
+	// Stock can never drop below what is already reserved. A restock is always
+	// accepted, it is how a row that drifted below its reservations is repaired.
+	if req.Quantity < 0 && current.Stock+int64(req.Quantity) < current.Reserved {
+		return errors.SetCustomError(constant.ErrInsufficientStock)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE warehouse_stock SET stock = stock + ?, updated_at = NOW() WHERE id = ?", req.Quantity, current.ID); err != nil {
+		logger.Error("[AdjustStockTx] update stock failed", zap.String("error", err.Error()), zap.Uint64("warehouse_stock_id", uint64(current.ID)), zap.Int("quantity", req.Quantity))
+		return err
+	}
 	return nil
 }
+
+// ReleaseCartReservationsTx releases what a cart holds for a product, or for
+// every product when productID is zero
+func (r *SQL) ReleaseCartReservationsTx(ctx context.Context, tx *sqlx.Tx, cartID, productID uint64) error {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT id, warehouse_id, product_id, quantity FROM stock_reservation WHERE cart_id = ?"
+	args := []any{cartID}
+	if productID != 0 {
+		query += " AND product_id = ?"
+		args = append(args, productID)
+	}
+	query += " FOR UPDATE"
+
+	reservations := make([]model.Reservation, 0)
+	if err := tx.SelectContext(ctx, &reservations, query, args...); err != nil {
+		logger.Error("[ReleaseCartReservationsTx] query failed", zap.String("error", err.Error()), zap.Uint64("cart_id", cartID), zap.Uint64("product_id", productID))
+		return err
+	}
+	return releaseReservations(ctx, tx, reservations)
+}
+
+// ReleaseExpiredCartReservationsTx releases up to limit cart reservations past
+// their expires_at and returns how many were released
+func (r *SQL) ReleaseExpiredCartReservationsTx(ctx context.Context, tx *sqlx.Tx, limit int) (int, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	reservations := make([]model.Reservation, 0)
+	query := "SELECT id, warehouse_id, product_id, quantity FROM stock_reservation WHERE cart_id IS NOT NULL AND expires_at < NOW() ORDER BY expires_at LIMIT ? FOR UPDATE"
+	if err := tx.SelectContext(ctx, &reservations, query, limit); err != nil {
+		logger.Error("[ReleaseExpiredCartReservationsTx] query failed", zap.String("error", err.Error()))
+		return 0, err
+	}
+	if err := releaseReservations(ctx, tx, reservations); err != nil {
+		return 0, err
+	}
+	return len(reservations), nil
+}
+
+// GetCartReservedQuantities returns the quantity a user's cart still holds per product
+func (r *SQL) GetCartReservedQuantities(ctx context.Context, userID uint64) (map[uint64]int64, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows := make([]struct {
+		ProductID uint64 `db:"product_id"`
+		Quantity  int64  `db:"quantity"`
+	}, 0)
+	query := "SELECT sr.product_id, SUM(sr.quantity) as quantity FROM stock_reservation sr JOIN cart c ON c.id = sr.cart_id WHERE c.user_id = ? AND sr.expires_at > NOW() GROUP BY sr.product_id"
+	if err := r.conn.SelectContext(ctx, &rows, query, userID); err != nil {
+		logger.Error("[GetCartReservedQuantities] query failed", zap.String("error", err.Error()), zap.Uint64("user_id", userID))
+		return nil, err
+	}
+
+	res := make(map[uint64]int64, len(rows))
+	for _, row := range rows {
+		res[row.ProductID] = row.Quantity
+	}
+	return res, nil
+}
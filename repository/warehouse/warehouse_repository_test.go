@@ -0,0 +1,1032 @@
+package warehouse_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	goerrors "errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/model"
+	warehouserepo "github.com/muhammadheryan/e-commerce/repository/warehouse"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// openTestDB connects to a migrated MySQL database given by TEST_DB_DSN.
+// Tests are skipped when it is not set.
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set, skipping repository integration test")
+	}
+	db, err := sqlx.Connect("mysql", dsn)
+	if err != nil {
+		t.Fatalf("connect db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func mustInsert(t *testing.T, db *sqlx.DB, query string, args ...any) uint64 {
+	t.Helper()
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatalf("last insert id: %v", err)
+	}
+	return uint64(id)
+}
+
+// staleUpdatedAt is far enough in the past that any write must move updated_at forward
+var staleUpdatedAt = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func updatedAt(t *testing.T, db *sqlx.DB, table string, id uint64) time.Time {
+	t.Helper()
+	var ts time.Time
+	if err := db.Get(&ts, "SELECT updated_at FROM `"+table+"` WHERE id = ?", id); err != nil {
+		t.Fatalf("read %s.updated_at: %v", table, err)
+	}
+	return ts
+}
+
+func TestWarehouseRepository_UpdatesAdvanceUpdatedAt(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-updated-at")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-updated-at", "", 1000)
+	fromWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status, updated_at) VALUES (?, ?, ?, ?)", shopID, "from-wh", constant.WarehouseStatusActive, staleUpdatedAt)
+	toWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "to-wh", constant.WarehouseStatusActive)
+	fromStock := mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved, updated_at) VALUES (?, ?, ?, ?, ?)", fromWH, productID, 10, 0, staleUpdatedAt)
+	toStock := mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved, updated_at) VALUES (?, ?, ?, ?, ?)", toWH, productID, 0, 0, staleUpdatedAt)
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := warehouserepo.NewWarehouseRepository(db)
+
+	if err := repo.UpdateWarehouseStatus(ctx, fromWH, constant.WarehouseStatusInactive); err != nil {
+		t.Fatalf("UpdateWarehouseStatus() error = %v", err)
+	}
+	if got := updatedAt(t, db, "warehouse", fromWH); !got.After(staleUpdatedAt) {
+		t.Fatalf("warehouse updated_at = %v, want after %v", got, staleUpdatedAt)
+	}
+	// transfers need both warehouses active
+	if err := repo.UpdateWarehouseStatus(ctx, fromWH, constant.WarehouseStatusActive); err != nil {
+		t.Fatalf("UpdateWarehouseStatus() error = %v", err)
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	if err := repo.TransferStockTx(ctx, tx, &model.TransferStockRequest{ProductID: productID, FromWarehouseID: fromWH, ToWarehouseID: toWH, Quantity: 3}); err != nil {
+		_ = tx.Rollback()
+		t.Fatalf("TransferStockTx() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	for _, id := range []uint64{fromStock, toStock} {
+		if got := updatedAt(t, db, "warehouse_stock", id); !got.After(staleUpdatedAt) {
+			t.Fatalf("warehouse_stock %d updated_at = %v, want after %v", id, got, staleUpdatedAt)
+		}
+	}
+}
+
+func TestWarehouseRepository_RefundRestocksFulfilledWarehouses(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-fulfillment")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-fulfillment", "", 1000)
+	whA := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "wh-a", constant.WarehouseStatusActive)
+	whB := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "wh-b", constant.WarehouseStatusActive)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", whA, productID, 5, 2)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", whB, productID, 8, 3)
+
+	// no foreign keys, so an order id far from real ones keeps the rows apart
+	orderID := uint64(900000000) + productID
+	mustInsert(t, db, "INSERT INTO stock_reservation (order_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, NOW() + INTERVAL 1 HOUR)", orderID, whA, productID, 2)
+	mustInsert(t, db, "INSERT INTO stock_reservation (order_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, NOW() + INTERVAL 1 HOUR)", orderID, whB, productID, 3)
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM order_fulfillment WHERE order_id = ?", orderID)
+		_, _ = db.Exec("DELETE FROM stock_reservation WHERE order_id = ?", orderID)
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := warehouserepo.NewWarehouseRepository(db)
+
+	stockOf := func(warehouseID uint64) (stock, reserved int64) {
+		t.Helper()
+		row := db.QueryRow("SELECT stock, reserved FROM warehouse_stock WHERE warehouse_id = ? AND product_id = ?", warehouseID, productID)
+		if err := row.Scan(&stock, &reserved); err != nil {
+			t.Fatalf("read stock: %v", err)
+		}
+		return stock, reserved
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	if err := repo.CommitReservationsTx(ctx, tx, orderID); err != nil {
+		_ = tx.Rollback()
+		t.Fatalf("CommitReservationsTx() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if stock, reserved := stockOf(whA); stock != 3 || reserved != 0 {
+		t.Fatalf("wh-a after commit = (%d, %d), want (3, 0)", stock, reserved)
+	}
+
+	tx, err = db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	fulfillment, err := repo.GetFulfillmentByOrderTx(ctx, tx, orderID)
+	if err != nil {
+		t.Fatalf("GetFulfillmentByOrderTx() error = %v", err)
+	}
+	if len(fulfillment) != 2 || uint64(fulfillment[0].WarehouseID) != whA || fulfillment[0].Quantity != 2 || uint64(fulfillment[1].WarehouseID) != whB || fulfillment[1].Quantity != 3 {
+		t.Fatalf("fulfillment = %+v, want 2 from wh-a and 3 from wh-b", fulfillment)
+	}
+	if err := repo.RestockFulfillmentTx(ctx, tx, fulfillment); err != nil {
+		t.Fatalf("RestockFulfillmentTx() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if stock, _ := stockOf(whA); stock != 5 {
+		t.Fatalf("wh-a stock after refund = %d, want 5", stock)
+	}
+	if stock, _ := stockOf(whB); stock != 8 {
+		t.Fatalf("wh-b stock after refund = %d, want 8", stock)
+	}
+
+	// a repeated refund finds nothing left to restock, and replaying the rows it
+	// read before the first one committed doesn't put the stock back twice
+	tx, err = db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	again, err := repo.GetFulfillmentByOrderTx(ctx, tx, orderID)
+	if err != nil {
+		t.Fatalf("GetFulfillmentByOrderTx() error = %v", err)
+	}
+	if len(again) != 0 {
+		t.Fatalf("fulfillment after refund = %+v, want none left", again)
+	}
+	if err := repo.RestockFulfillmentTx(ctx, tx, fulfillment); err != nil {
+		t.Fatalf("RestockFulfillmentTx() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if stock, _ := stockOf(whA); stock != 5 {
+		t.Fatalf("wh-a stock after a second refund = %d, want still 5", stock)
+	}
+	if stock, _ := stockOf(whB); stock != 8 {
+		t.Fatalf("wh-b stock after a second refund = %d, want still 8", stock)
+	}
+}
+
+func TestWarehouseRepository_TransferStockRequiresActiveWarehouses(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-transfer-status")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-transfer-status", "", 1000)
+	activeWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "active-wh", constant.WarehouseStatusActive)
+	inactiveWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "inactive-wh", constant.WarehouseStatusInactive)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", activeWH, productID, 10, 0)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", inactiveWH, productID, 10, 0)
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := warehouserepo.NewWarehouseRepository(db)
+
+	tests := []struct {
+		name    string
+		from    uint64
+		to      uint64
+		errCode constant.ErrorType
+	}{
+		{"from inactive warehouse", inactiveWH, activeWH, constant.ErrWarehouseInactive},
+		{"to inactive warehouse", activeWH, inactiveWH, constant.ErrWarehouseInactive},
+		{"to unknown warehouse", activeWH, inactiveWH + 1000000, constant.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := db.BeginTxx(ctx, nil)
+			if err != nil {
+				t.Fatalf("begin tx: %v", err)
+			}
+			defer func() { _ = tx.Rollback() }()
+
+			err = repo.TransferStockTx(ctx, tx, &model.TransferStockRequest{ProductID: productID, FromWarehouseID: tt.from, ToWarehouseID: tt.to, Quantity: 1})
+			if err == nil || err.Error() != errors.SetCustomError(tt.errCode).Error() {
+				t.Fatalf("TransferStockTx() error = %v, want %s", err, constant.ErrorTypeMessage[tt.errCode])
+			}
+		})
+	}
+
+	var stock int64
+	if err := db.Get(&stock, "SELECT stock FROM warehouse_stock WHERE warehouse_id = ? AND product_id = ?", activeWH, productID); err != nil {
+		t.Fatalf("read stock: %v", err)
+	}
+	if stock != 10 {
+		t.Fatalf("active warehouse stock = %d, want it untouched at 10", stock)
+	}
+}
+
+func TestWarehouseRepository_TransferStockMovesReservations(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-transfer-reservations")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-transfer-reservations", "", 1000)
+	fromWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "from-wh", constant.WarehouseStatusActive)
+	toWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "to-wh", constant.WarehouseStatusActive)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", fromWH, productID, 10, 4)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", toWH, productID, 2, 1)
+
+	orderID := uint64(900000000) + productID
+	mustInsert(t, db, "INSERT INTO stock_reservation (order_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, NOW() + INTERVAL 1 HOUR)", orderID, fromWH, productID, 3)
+	mustInsert(t, db, "INSERT INTO stock_reservation (order_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, NOW() + INTERVAL 1 HOUR)", orderID+1, fromWH, productID, 1)
+	mustInsert(t, db, "INSERT INTO stock_reservation (order_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, NOW() + INTERVAL 1 HOUR)", orderID+2, toWH, productID, 1)
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM order_fulfillment WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM stock_reservation WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := warehouserepo.NewWarehouseRepository(db)
+
+	stockOf := func(warehouseID uint64) (stock, reserved int64) {
+		t.Helper()
+		row := db.QueryRow("SELECT stock, reserved FROM warehouse_stock WHERE warehouse_id = ? AND product_id = ?", warehouseID, productID)
+		if err := row.Scan(&stock, &reserved); err != nil {
+			t.Fatalf("read stock: %v", err)
+		}
+		return stock, reserved
+	}
+	reservationsAt := func(warehouseID uint64) (sum int64) {
+		t.Helper()
+		if err := db.Get(&sum, "SELECT COALESCE(SUM(quantity), 0) FROM stock_reservation WHERE warehouse_id = ? AND product_id = ?", warehouseID, productID); err != nil {
+			t.Fatalf("read reservations: %v", err)
+		}
+		return sum
+	}
+	transfer := func(quantity int) error {
+		t.Helper()
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			t.Fatalf("begin tx: %v", err)
+		}
+		defer func() { _ = tx.Rollback() }()
+		err = repo.TransferStockTx(ctx, tx, &model.TransferStockRequest{ProductID: productID, FromWarehouseID: fromWH, ToWarehouseID: toWH, Quantity: quantity, MoveReservations: true})
+		if err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+		return nil
+	}
+
+	// 3 units short of the 4 reserved, and 7 past the 6 unreserved
+	for _, quantity := range []int{3, 11} {
+		err := transfer(quantity)
+		if err == nil {
+			t.Fatalf("TransferStockTx(%d) error = nil, want a rejection", quantity)
+		}
+	}
+	if stock, reserved := stockOf(fromWH); stock != 10 || reserved != 4 {
+		t.Fatalf("from-wh after rejected transfers = (%d, %d), want untouched (10, 4)", stock, reserved)
+	}
+
+	// the 4 reserved units and 3 of the free ones
+	if err := transfer(7); err != nil {
+		t.Fatalf("TransferStockTx() error = %v", err)
+	}
+	if stock, reserved := stockOf(fromWH); stock != 3 || reserved != 0 {
+		t.Fatalf("from-wh = (%d, %d), want (3, 0)", stock, reserved)
+	}
+	if stock, reserved := stockOf(toWH); stock != 9 || reserved != 5 {
+		t.Fatalf("to-wh = (%d, %d), want (9, 5)", stock, reserved)
+	}
+	// the reserved counts still match the rows they stand for
+	if got := reservationsAt(fromWH); got != 0 {
+		t.Fatalf("reservations left at from-wh = %d, want 0", got)
+	}
+	if got := reservationsAt(toWH); got != 5 {
+		t.Fatalf("reservations at to-wh = %d, want 5", got)
+	}
+
+	// paying a moved order takes its stock from the destination
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	if err := repo.CommitReservationsTx(ctx, tx, orderID); err != nil {
+		_ = tx.Rollback()
+		t.Fatalf("CommitReservationsTx() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if stock, reserved := stockOf(toWH); stock != 6 || reserved != 2 {
+		t.Fatalf("to-wh after paying = (%d, %d), want (6, 2)", stock, reserved)
+	}
+	if stock, reserved := stockOf(fromWH); stock != 3 || reserved != 0 {
+		t.Fatalf("from-wh after paying = (%d, %d), want untouched (3, 0)", stock, reserved)
+	}
+}
+
+func TestWarehouseRepository_ListLowStockProductsCountsActiveWarehousesOnly(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-low-stock")
+	lowID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-low", "", 1000)
+	plentyID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-plenty", "", 1000)
+	activeWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "active-wh", constant.WarehouseStatusActive)
+	inactiveWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "inactive-wh", constant.WarehouseStatusInactive)
+	// 2 available where it can be sold, the inactive stock must not hide that
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", activeWH, lowID, 5, 3)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", inactiveWH, lowID, 100, 0)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", activeWH, plentyID, 50, 0)
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id IN (?, ?)", lowID, plentyID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	items, err := warehouserepo.NewWarehouseRepository(db).ListLowStockProducts(ctx, 2)
+	if err != nil {
+		t.Fatalf("ListLowStockProducts() error = %v", err)
+	}
+	found := map[uint64]model.LowStockItem{}
+	for _, item := range items {
+		found[uint64(item.ProductID)] = item
+	}
+	if got, ok := found[lowID]; !ok || got.Available != 2 || got.Name != "test-product-low" {
+		t.Fatalf("low product = %+v (listed %v), want 2 available", got, ok)
+	}
+	if _, ok := found[plentyID]; ok {
+		t.Fatal("product with 50 available was reported as low")
+	}
+}
+
+func TestWarehouseRepository_ReserveStockAllocationOrder(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-allocation")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-allocation", "", 1000)
+	// the primary center holds the least stock, the overflow one the most
+	primaryWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status, priority) VALUES (?, ?, ?, ?)", shopID, "primary-wh", constant.WarehouseStatusActive, 10)
+	secondaryWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status, priority) VALUES (?, ?, ?, ?)", shopID, "secondary-wh", constant.WarehouseStatusActive, 5)
+	overflowWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status, priority) VALUES (?, ?, ?, ?)", shopID, "overflow-wh", constant.WarehouseStatusActive, 0)
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM stock_reservation WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	tests := []struct {
+		name       string
+		allocation string
+		want       map[uint64]int64
+	}{
+		{
+			name:       "priority first",
+			allocation: constant.StockAllocationPriority,
+			want:       map[uint64]int64{primaryWH: 3, secondaryWH: 4, overflowWH: 1},
+		},
+		{
+			name:       "default is priority",
+			allocation: "",
+			want:       map[uint64]int64{primaryWH: 3, secondaryWH: 4, overflowWH: 1},
+		},
+		{
+			name:       "most available first",
+			allocation: constant.StockAllocationAvailable,
+			want:       map[uint64]int64{overflowWH: 8},
+		},
+	}
+
+	repo := warehouserepo.NewWarehouseRepository(db)
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _ = db.Exec("DELETE FROM stock_reservation WHERE product_id = ?", productID)
+			_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+			mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", primaryWH, productID, 3, 0)
+			mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", secondaryWH, productID, 4, 0)
+			mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", overflowWH, productID, 20, 0)
+
+			tx, err := db.BeginTxx(ctx, nil)
+			if err != nil {
+				t.Fatalf("begin tx: %v", err)
+			}
+			req := &model.ReserveRequest{OrderID: uint64(990000100 + i), ProductID: productID, Quantity: 8, ExpiresAt: time.Now().Add(time.Hour), Allocation: tt.allocation}
+			if err := repo.ReserveStockTx(ctx, tx, req); err != nil {
+				_ = tx.Rollback()
+				t.Fatalf("ReserveStockTx() error = %v", err)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("commit: %v", err)
+			}
+
+			var rows []struct {
+				WarehouseID uint64 `db:"warehouse_id"`
+				Quantity    int64  `db:"quantity"`
+			}
+			if err := db.Select(&rows, "SELECT warehouse_id, quantity FROM stock_reservation WHERE product_id = ?", productID); err != nil {
+				t.Fatalf("read reservations: %v", err)
+			}
+			got := map[uint64]int64{}
+			for _, r := range rows {
+				got[r.WarehouseID] += r.Quantity
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("reserved from %v, want %v", got, tt.want)
+			}
+			for wh, qty := range tt.want {
+				if got[wh] != qty {
+					t.Fatalf("reserved from %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// negativeAvailableSeen reads the drift counter for source from the default registry
+func negativeAvailableSeen(t *testing.T, source string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "warehouse_stock_negative_available_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "source" && label.GetValue() == source {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestWarehouseRepository_DriftedRowCountsAsZero(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-drift")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-drift", "", 1000)
+	driftedWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "drifted-wh", constant.WarehouseStatusActive)
+	healthyWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "healthy-wh", constant.WarehouseStatusActive)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", driftedWH, productID, 2, 5)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", healthyWH, productID, 4, 0)
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM stock_reservation WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := warehouserepo.NewWarehouseRepository(db)
+
+	seen := negativeAvailableSeen(t, "availability")
+	total, err := repo.GetTotalAvailableStock(ctx, productID)
+	if err != nil {
+		t.Fatalf("GetTotalAvailableStock() error = %v", err)
+	}
+	if total != 4 {
+		t.Fatalf("GetTotalAvailableStock() = %d, want 4", total)
+	}
+	if got := negativeAvailableSeen(t, "availability"); got != seen+1 {
+		t.Fatalf("drift counter = %v, want %v", got, seen+1)
+	}
+
+	items, err := repo.ListLowStockProducts(ctx, 4)
+	if err != nil {
+		t.Fatalf("ListLowStockProducts() error = %v", err)
+	}
+	for _, item := range items {
+		if uint64(item.ProductID) == productID && item.Available != 4 {
+			t.Fatalf("ListLowStockProducts() available = %d, want 4", item.Available)
+		}
+	}
+
+	// all of the healthy row can be reserved, nothing from the drifted one
+	seen = negativeAvailableSeen(t, "reserve")
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	err = repo.ReserveStockTx(ctx, tx, &model.ReserveRequest{OrderID: 990000200, ProductID: productID, Quantity: 4, ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		_ = tx.Rollback()
+		t.Fatalf("ReserveStockTx() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if got := negativeAvailableSeen(t, "reserve"); got != seen+1 {
+		t.Fatalf("drift counter = %v, want %v", got, seen+1)
+	}
+
+	// a restock smaller than the shortfall still goes through
+	tx, err = db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	if err := repo.AdjustStockTx(ctx, tx, &model.StockAdjustmentRequest{WarehouseID: driftedWH, ProductID: productID, Quantity: 1}); err != nil {
+		_ = tx.Rollback()
+		t.Fatalf("AdjustStockTx() restock error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	stock, err := repo.GetWarehouseStock(ctx, driftedWH, productID)
+	if err != nil {
+		t.Fatalf("GetWarehouseStock() error = %v", err)
+	}
+	if stock.Stock != 3 {
+		t.Fatalf("stock after restock = %d, want 3", stock.Stock)
+	}
+}
+
+func TestWarehouseRepository_ReconcileReservedCorrectsDrift(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-reconcile")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-reconcile", "", 1000)
+	driftedWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "drifted-wh", constant.WarehouseStatusActive)
+	orphanedWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "orphaned-wh", constant.WarehouseStatusActive)
+	healthyWH := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "healthy-wh", constant.WarehouseStatusActive)
+	// reservations add up to 3 but 5 is recorded
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", driftedWH, productID, 10, 5)
+	// reserved with no reservation rows left
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", orphanedWH, productID, 10, 4)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", healthyWH, productID, 10, 2)
+	expires := time.Now().Add(time.Hour)
+	mustInsert(t, db, "INSERT INTO stock_reservation (order_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, ?)", 990000300, driftedWH, productID, 1, expires)
+	mustInsert(t, db, "INSERT INTO stock_reservation (order_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, ?)", 990000301, driftedWH, productID, 2, expires)
+	mustInsert(t, db, "INSERT INTO stock_reservation (order_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, ?)", 990000302, healthyWH, productID, 2, expires)
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM stock_reservation WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := warehouserepo.NewWarehouseRepository(db)
+	reconcile := func(warehouseID uint64) []model.ReservedDiscrepancy {
+		t.Helper()
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			t.Fatalf("begin tx: %v", err)
+		}
+		res, err := repo.ReconcileReservedTx(ctx, tx, warehouseID)
+		if err != nil {
+			_ = tx.Rollback()
+			t.Fatalf("ReconcileReservedTx() error = %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+		return res
+	}
+	reserved := func(warehouseID uint64) int64 {
+		t.Helper()
+		stock, err := repo.GetWarehouseStock(ctx, warehouseID, productID)
+		if err != nil {
+			t.Fatalf("GetWarehouseStock() error = %v", err)
+		}
+		return stock.Reserved
+	}
+
+	// scoped to one warehouse, the others are left alone
+	got := reconcile(driftedWH)
+	want := []model.ReservedDiscrepancy{{WarehouseID: model.ID(driftedWH), ProductID: model.ID(productID), Reserved: 5, Actual: 3}}
+	if len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("ReconcileReservedTx(drifted) = %+v, want %+v", got, want)
+	}
+	if r := reserved(driftedWH); r != 3 {
+		t.Fatalf("drifted reserved = %d, want 3", r)
+	}
+	if r := reserved(orphanedWH); r != 4 {
+		t.Fatalf("orphaned reserved = %d, want untouched 4", r)
+	}
+
+	// globally, other shops' rows may be reported too, ours must be among them
+	found := false
+	for _, d := range reconcile(0) {
+		if uint64(d.ProductID) != productID {
+			continue
+		}
+		if uint64(d.WarehouseID) != orphanedWH || d.Reserved != 4 || d.Actual != 0 {
+			t.Fatalf("unexpected correction %+v", d)
+		}
+		found = true
+	}
+	if !found {
+		t.Fatal("orphaned reserved count was not reported")
+	}
+	if r := reserved(orphanedWH); r != 0 {
+		t.Fatalf("orphaned reserved = %d, want 0", r)
+	}
+	if r := reserved(healthyWH); r != 2 {
+		t.Fatalf("healthy reserved = %d, want 2", r)
+	}
+}
+
+func TestWarehouseRepository_ReleaseExpiredCartReservations(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-sweep")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-sweep", "", 1000)
+	whID := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "sweep-wh", constant.WarehouseStatusActive)
+	// 2 + 3 held by expired cart reservations, 4 by a live one
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, ?)", whID, productID, 20, 9)
+	expired := time.Now().Add(-time.Hour)
+	mustInsert(t, db, "INSERT INTO stock_reservation (cart_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, ?)", 990000400, whID, productID, 2, expired)
+	mustInsert(t, db, "INSERT INTO stock_reservation (cart_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, ?)", 990000401, whID, productID, 3, expired)
+	liveID := mustInsert(t, db, "INSERT INTO stock_reservation (cart_id, warehouse_id, product_id, quantity, expires_at) VALUES (?, ?, ?, ?, ?)", 990000402, whID, productID, 4, time.Now().Add(time.Hour))
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM stock_reservation WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	repo := warehouserepo.NewWarehouseRepository(db)
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	released, err := repo.ReleaseExpiredCartReservationsTx(ctx, tx, 1000)
+	if err != nil {
+		_ = tx.Rollback()
+		t.Fatalf("ReleaseExpiredCartReservationsTx() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	// the sweep is global, expired rows left by other tests count too
+	if released < 2 {
+		t.Fatalf("released = %d, want at least the 2 seeded expired reservations", released)
+	}
+	var left []uint64
+	if err := db.Select(&left, "SELECT id FROM stock_reservation WHERE product_id = ?", productID); err != nil {
+		t.Fatalf("read reservations: %v", err)
+	}
+	if len(left) != 1 || left[0] != liveID {
+		t.Fatalf("reservations left = %v, want only the live one %d", left, liveID)
+	}
+	stock, err := repo.GetWarehouseStock(ctx, whID, productID)
+	if err != nil {
+		t.Fatalf("GetWarehouseStock() error = %v", err)
+	}
+	if stock.Reserved != 4 {
+		t.Fatalf("reserved = %d, want 4 held by the live reservation", stock.Reserved)
+	}
+}
+
+func TestWarehouseRepository_LockedReservationRespectsQueryTimeout(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	shopID := mustInsert(t, db, "INSERT INTO shop (name) VALUES (?)", "test-shop-timeout")
+	productID := mustInsert(t, db, "INSERT INTO product (shop_id, name, description, price) VALUES (?, ?, ?, ?)", shopID, "test-product-timeout", "", 1000)
+	whID := mustInsert(t, db, "INSERT INTO warehouse (shop_id, name, status) VALUES (?, ?, ?)", shopID, "timeout-wh", constant.WarehouseStatusActive)
+	mustInsert(t, db, "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, 0)", whID, productID, 10)
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM stock_reservation WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse_stock WHERE product_id = ?", productID)
+		_, _ = db.Exec("DELETE FROM warehouse WHERE shop_id = ?", shopID)
+		_, _ = db.Exec("DELETE FROM product WHERE id = ?", productID)
+		_, _ = db.Exec("DELETE FROM shop WHERE id = ?", shopID)
+	})
+
+	// another transaction holds the stock rows the reservation needs to lock
+	blocker, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin blocker tx: %v", err)
+	}
+	defer func() { _ = blocker.Rollback() }()
+	if _, err := blocker.ExecContext(ctx, "SELECT id FROM warehouse_stock WHERE product_id = ? FOR UPDATE", productID); err != nil {
+		t.Fatalf("lock stock rows: %v", err)
+	}
+
+	utilsContext.SetQueryTimeout(300 * time.Millisecond)
+	t.Cleanup(func() { utilsContext.SetQueryTimeout(0) })
+
+	repo := warehouserepo.NewWarehouseRepository(db)
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	start := time.Now()
+	err = repo.ReserveStockTx(ctx, tx, &model.ReserveRequest{OrderID: 990000500, ProductID: productID, Quantity: 1, ExpiresAt: time.Now().Add(time.Hour)})
+	elapsed := time.Since(start)
+
+	if !goerrors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ReserveStockTx() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	// well before innodb_lock_wait_timeout would have ended the wait
+	if elapsed > 5*time.Second {
+		t.Fatalf("ReserveStockTx() returned after %v, want about the 300ms query timeout", elapsed)
+	}
+}
+
+// newMockTx opens a transaction on a sqlmock connection that matches queries
+// exactly, so a test pins the statements a method sends
+func newMockTx(t *testing.T) (*sqlx.Tx, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("open sqlmock: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	mock.ExpectBegin()
+	tx, err := sqlx.NewDb(db, "mysql").Beginx()
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	return tx, mock
+}
+
+func TestWarehouseRepository_AdjustStockTx(t *testing.T) {
+	const (
+		selectQ = "SELECT id, warehouse_id, product_id, stock, reserved FROM warehouse_stock WHERE warehouse_id = ? AND product_id = ? FOR UPDATE"
+		insertQ = "INSERT INTO warehouse_stock (warehouse_id, product_id, stock, reserved) VALUES (?, ?, ?, 0)"
+		updateQ = "UPDATE warehouse_stock SET stock = stock + ?, updated_at = NOW() WHERE id = ?"
+	)
+	stockRow := func(stock, reserved int64) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "warehouse_id", "product_id", "stock", "reserved"}).AddRow(7, 1, 2, stock, reserved)
+	}
+
+	tests := []struct {
+		name     string
+		quantity int
+		mockCall func(mock sqlmock.Sqlmock, quantity int)
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{
+			name:     "success: restock",
+			quantity: 5,
+			mockCall: func(mock sqlmock.Sqlmock, quantity int) {
+				mock.ExpectQuery(selectQ).WithArgs(1, 2).WillReturnRows(stockRow(10, 4))
+				mock.ExpectExec(updateQ).WithArgs(quantity, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:     "success: restock of a row drifted below its reservations",
+			quantity: 1,
+			mockCall: func(mock sqlmock.Sqlmock, quantity int) {
+				mock.ExpectQuery(selectQ).WithArgs(1, 2).WillReturnRows(stockRow(2, 5))
+				mock.ExpectExec(updateQ).WithArgs(quantity, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:     "success: write-off down to the reserved stock",
+			quantity: -6,
+			mockCall: func(mock sqlmock.Sqlmock, quantity int) {
+				mock.ExpectQuery(selectQ).WithArgs(1, 2).WillReturnRows(stockRow(10, 4))
+				mock.ExpectExec(updateQ).WithArgs(quantity, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:     "success: first stock of the product in the warehouse",
+			quantity: 5,
+			mockCall: func(mock sqlmock.Sqlmock, quantity int) {
+				mock.ExpectQuery(selectQ).WithArgs(1, 2).WillReturnError(sql.ErrNoRows)
+				mock.ExpectExec(insertQ).WithArgs(1, 2, quantity).WillReturnResult(sqlmock.NewResult(7, 1))
+			},
+		},
+		{
+			name:     "error: write-off below the reserved stock",
+			quantity: -7,
+			mockCall: func(mock sqlmock.Sqlmock, quantity int) {
+				mock.ExpectQuery(selectQ).WithArgs(1, 2).WillReturnRows(stockRow(10, 4))
+			},
+			wantErr: true,
+			errCode: constant.ErrInsufficientStock,
+		},
+		{
+			name:     "error: write-off without a stock row",
+			quantity: -1,
+			mockCall: func(mock sqlmock.Sqlmock, quantity int) {
+				mock.ExpectQuery(selectQ).WithArgs(1, 2).WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: true,
+			errCode: constant.ErrInsufficientStock,
+		},
+		{
+			name:     "error: update fails",
+			quantity: 5,
+			mockCall: func(mock sqlmock.Sqlmock, quantity int) {
+				mock.ExpectQuery(selectQ).WithArgs(1, 2).WillReturnRows(stockRow(10, 4))
+				mock.ExpectExec(updateQ).WithArgs(quantity, 7).WillReturnError(goerrors.New("db error"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, mock := newMockTx(t)
+			tt.mockCall(mock, tt.quantity)
+
+			req := &model.StockAdjustmentRequest{WarehouseID: 1, ProductID: 2, Quantity: tt.quantity}
+			err := warehouserepo.NewWarehouseRepository(nil).AdjustStockTx(context.Background(), tx, req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AdjustStockTx() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.errCode != 0 && !errors.IsType(err, tt.errCode) {
+				t.Fatalf("AdjustStockTx() error = %v, want %s", err, constant.ErrorTypeCode[tt.errCode])
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestWarehouseRepository_ListWarehouseSummaries(t *testing.T) {
+	const q = "SELECT w.id, w.name, w.status, COALESCE(SUM(ws.stock), 0) as total_stock, COALESCE(SUM(ws.reserved), 0) as total_reserved FROM warehouse w LEFT JOIN warehouse_stock ws ON ws.warehouse_id = w.id"
+	const group = " GROUP BY w.id, w.name, w.status ORDER BY w.id"
+	columns := []string{"id", "name", "status", "total_stock", "total_reserved"}
+
+	tests := []struct {
+		name   string
+		shopID uint64
+		query  string
+		args   []driver.Value
+		rows   *sqlmock.Rows
+		want   []model.WarehouseSummary
+	}{
+		{
+			name:  "zero shop lists every warehouse",
+			query: q + group,
+			rows: sqlmock.NewRows(columns).
+				AddRow(1, "Jakarta", constant.WarehouseStatusActive, 12, 4).
+				AddRow(2, "Bandung", constant.WarehouseStatusInactive, 0, 0),
+			want: []model.WarehouseSummary{
+				{ID: 1, Name: "Jakarta", Status: constant.WarehouseStatusActive, TotalStock: 12, TotalReserved: 4},
+				{ID: 2, Name: "Bandung", Status: constant.WarehouseStatusInactive},
+			},
+		},
+		{
+			name:   "shop filter",
+			shopID: 7,
+			query:  q + " WHERE w.shop_id = ?" + group,
+			args:   []driver.Value{7},
+			rows:   sqlmock.NewRows(columns).AddRow(3, "Surabaya", constant.WarehouseStatusActive, 5, 1),
+			want: []model.WarehouseSummary{
+				{ID: 3, Name: "Surabaya", Status: constant.WarehouseStatusActive, TotalStock: 5, TotalReserved: 1},
+			},
+		},
+		{
+			name:   "shop without warehouses is an empty list",
+			shopID: 8,
+			query:  q + " WHERE w.shop_id = ?" + group,
+			args:   []driver.Value{8},
+			rows:   sqlmock.NewRows(columns),
+			want:   []model.WarehouseSummary{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatalf("open sqlmock: %v", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery(tt.query).WithArgs(tt.args...).WillReturnRows(tt.rows)
+
+			got, err := warehouserepo.NewWarehouseRepository(sqlx.NewDb(db, "mysql")).ListWarehouseSummaries(context.Background(), tt.shopID)
+			if err != nil {
+				t.Fatalf("ListWarehouseSummaries() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ListWarehouseSummaries() = %+v, want %+v", got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestWarehouseRepository_TransferStockTxDriftedReserved(t *testing.T) {
+	const (
+		activeQ   = "SELECT id, status FROM warehouse WHERE id IN (?, ?) LOCK IN SHARE MODE"
+		stockQ    = "SELECT id, warehouse_id, product_id, stock, reserved FROM warehouse_stock WHERE warehouse_id = ? AND product_id = ? FOR UPDATE"
+		sumQ      = "SELECT COALESCE(SUM(quantity), 0) FROM stock_reservation WHERE warehouse_id = ? AND product_id = ? FOR UPDATE"
+		decreaseQ = "UPDATE warehouse_stock SET stock = stock - ?, reserved = GREATEST(reserved - ?, 0), updated_at = NOW() WHERE id = ?"
+		increaseQ = "UPDATE warehouse_stock SET stock = stock + ?, reserved = reserved + ?, updated_at = NOW() WHERE id = ?"
+		moveQ     = "UPDATE stock_reservation SET warehouse_id = ? WHERE warehouse_id = ? AND product_id = ?"
+	)
+	stockColumns := []string{"id", "warehouse_id", "product_id", "stock", "reserved"}
+
+	tests := []struct {
+		name     string
+		stock    int64
+		reserved int64
+		moved    int64
+		quantity int
+		wantErr  bool
+		errCode  constant.ErrorType
+	}{
+		{name: "reserved in line with the rows", stock: 10, reserved: 5, moved: 5, quantity: 10},
+		{name: "reserved drifted below the rows stops at zero", stock: 10, reserved: 2, moved: 5, quantity: 10},
+		{name: "drifted reserved doesn't overstate what is available", stock: 6, reserved: 2, moved: 5, quantity: 9, wantErr: true, errCode: constant.ErrInsufficientStock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, mock := newMockTx(t)
+			mock.ExpectQuery(activeQ).WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).
+				AddRow(1, constant.WarehouseStatusActive).
+				AddRow(2, constant.WarehouseStatusActive))
+			mock.ExpectQuery(stockQ).WithArgs(1, 3).WillReturnRows(sqlmock.NewRows(stockColumns).AddRow(10, 1, 3, tt.stock, tt.reserved))
+			mock.ExpectQuery(sumQ).WithArgs(1, 3).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(tt.moved))
+			if !tt.wantErr {
+				mock.ExpectExec(decreaseQ).WithArgs(tt.quantity, tt.moved, 10).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(stockQ).WithArgs(2, 3).WillReturnRows(sqlmock.NewRows(stockColumns).AddRow(20, 2, 3, 0, 0))
+				mock.ExpectExec(increaseQ).WithArgs(tt.quantity, tt.moved, 20).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(moveQ).WithArgs(2, 1, 3).WillReturnResult(sqlmock.NewResult(0, tt.moved))
+			}
+
+			req := &model.TransferStockRequest{ProductID: 3, FromWarehouseID: 1, ToWarehouseID: 2, Quantity: tt.quantity, MoveReservations: true}
+			err := warehouserepo.NewWarehouseRepository(nil).TransferStockTx(context.Background(), tx, req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TransferStockTx() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.errCode != 0 && !errors.IsType(err, tt.errCode) {
+				t.Fatalf("TransferStockTx() error = %v, want %s", err, constant.ErrorTypeCode[tt.errCode])
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package wishlist
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/model"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
+)
+
+type SQL struct {
+	conn *sqlx.DB
+}
+
+type WishlistRepository interface {
+	Exists(ctx context.Context, userID, productID uint64) (bool, error)
+	Add(ctx context.Context, userID, productID uint64) (bool, error)
+	Remove(ctx context.Context, userID, productID uint64) (bool, error)
+	List(ctx context.Context, userID uint64) ([]model.WishlistItem, error)
+}
+
+func NewWishlistRepository(conn *sqlx.DB) WishlistRepository {
+	return &SQL{conn: conn}
+}
+
+const (
+	existsWishlistQuery = `SELECT EXISTS(SELECT 1 FROM wishlist WHERE user_id = ? AND product_id = ?)`
+
+	// only inserts when the product exists, IGNORE keeps concurrent adds deduplicated
+	addWishlistQuery = `INSERT IGNORE INTO wishlist (user_id, product_id, created_at) SELECT ?, id, NOW() FROM product WHERE id = ? AND deleted_at IS NULL`
+
+	removeWishlistQuery = `DELETE FROM wishlist WHERE user_id = ? AND product_id = ?`
+
+	listWishlistQuery = `SELECT p.id as product_id, p.name, p.price, COALESCE(SUM(CASE WHEN w.status = ? THEN GREATEST(ws.stock - ws.reserved, 0) ELSE 0 END),0) as available_stock, wl.created_at as added_at
+FROM wishlist wl
+JOIN product p ON p.id = wl.product_id
+LEFT JOIN warehouse_stock ws ON ws.product_id = p.id
+LEFT JOIN warehouse w ON ws.warehouse_id = w.id
+WHERE wl.user_id = ?
+GROUP BY p.id, p.name, p.price, wl.created_at
+ORDER BY wl.created_at DESC`
+)
+
+func (s *SQL) Exists(ctx context.Context, userID, productID uint64) (bool, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	if err := s.conn.GetContext(ctx, &exists, existsWishlistQuery, userID, productID); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// Add returns false when nothing was inserted, either because the product
+// does not exist or it is already in the wishlist
+func (s *SQL) Add(ctx context.Context, userID, productID uint64) (bool, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := s.conn.ExecContext(ctx, addWishlistQuery, userID, productID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (s *SQL) Remove(ctx context.Context, userID, productID uint64) (bool, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := s.conn.ExecContext(ctx, removeWishlistQuery, userID, productID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (s *SQL) List(ctx context.Context, userID uint64) ([]model.WishlistItem, error) {
+	ctx, cancel := utilsContext.WithQueryTimeout(ctx)
+	defer cancel()
+
+	items := make([]model.WishlistItem, 0)
+	if err := s.conn.SelectContext(ctx, &items, listWishlistQuery, constant.WarehouseStatusActive, userID); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
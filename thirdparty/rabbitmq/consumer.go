@@ -7,8 +7,12 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/muhammadheryan/e-commerce/constant"
 	"github.com/rabbitmq/amqp091-go"
 )
 
@@ -17,9 +21,26 @@ type Consumer struct {
 	channel *amqp091.Channel
 	apiURL  string
 	apiKey  string
+	// maxRedeliveries is how many failed cancel attempts a message gets before it is dead-lettered
+	maxRedeliveries int
+	// workers is how many deliveries are handled at once, below one means one
+	workers int
+	// cancelSlots bounds the cancel API calls in flight however many workers
+	// handle deliveries, nil leaves them unbounded
+	cancelSlots chan struct{}
+	// publish sends a message to an exchange, the channel's Publish outside of tests
+	publish func(exchange, key string, msg amqp091.Publishing) error
+	// inspector is the channel Status reads the queue off, see Status
+	inspector channelInspector
+	// lastProcessed is the unix nano time the last message was handled at
+	lastProcessed atomic.Int64
+	// done is closed once the consume loop has exited and no message is in flight
+	done chan struct{}
 }
 
-func NewConsumer(host string, port int, user, password, apiURL, apiKey string) (*Consumer, error) {
+const consumerTag = "order_expiration_consumer"
+
+func NewConsumer(host string, port int, user, password, apiURL, apiKey string, maxRedeliveries, workers, maxConcurrentCancels int) (*Consumer, error) {
 	dsn := fmt.Sprintf("amqp://%s:%s@%s:%d/", user, password, host, port)
 	conn, err := amqp091.Dial(dsn)
 	if err != nil {
@@ -32,117 +53,249 @@ func NewConsumer(host string, port int, user, password, apiURL, apiKey string) (
 		return nil, err
 	}
 
-	// Declare the delayed exchange
-	err = channel.ExchangeDeclare(
-		"order_expiration_exchange",
-		"x-delayed-message",
-		true,
-		false,
-		false,
-		false,
-		amqp091.Table{"x-delayed-type": "direct"},
-	)
-	if err != nil {
+	if err := declareTopology(channel); err != nil {
 		channel.Close()
 		conn.Close()
 		return nil, err
 	}
 
-	// Declare the queue
-	_, err = channel.QueueDeclare(
-		"order_expiration_queue",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, err
-	}
+	return &Consumer{
+		conn:            conn,
+		channel:         channel,
+		apiURL:          apiURL,
+		apiKey:          apiKey,
+		maxRedeliveries: maxRedeliveries,
+		workers:         workers,
+		cancelSlots:     newCancelSlots(maxConcurrentCancels),
+		publish: func(exchange, key string, msg amqp091.Publishing) error {
+			return channel.Publish(exchange, key, false, false, msg)
+		},
+		inspector: channel,
+	}, nil
+}
 
-	// Bind queue to exchange
-	err = channel.QueueBind(
-		"order_expiration_queue",
-		"order_expiration",
-		"order_expiration_exchange",
-		false,
-		nil,
-	)
-	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, err
+// newCancelSlots returns the semaphore for at most n concurrent cancel calls, nil when n is not positive
+func newCancelSlots(n int) chan struct{} {
+	if n <= 0 {
+		return nil
 	}
+	return make(chan struct{}, n)
+}
 
-	return &Consumer{
-		conn:    conn,
-		channel: channel,
-		apiURL:  apiURL,
-		apiKey:  apiKey,
-	}, nil
+// workerCount is the number of deliveries handled at once
+func (c *Consumer) workerCount() int {
+	if c.workers < 1 {
+		return 1
+	}
+	return c.workers
 }
 
-func (c *Consumer) Start(ctx context.Context) error {
-	// Set QoS to 1 - process one message at a time
-	err := c.channel.Qos(1, 0, false)
+// Start begins consuming expiration messages. Handling waits until ready is
+// closed, so cancels aren't sent to an API that isn't listening yet; a nil
+// ready channel means the API is already up.
+func (c *Consumer) Start(ctx context.Context, ready <-chan struct{}) error {
+	// prefetch one message per worker, each is acked before its worker takes the next
+	err := c.channel.Qos(c.workerCount(), 0, false)
 	if err != nil {
 		return err
 	}
 
 	msgs, err := c.channel.Consume(
-		"order_expiration_queue",
-		"",    // consumer tag
-		false, // auto-ack
-		false, // exclusive
-		false, // no-local
-		false, // no-wait
-		nil,   // arguments
+		expirationQueue,
+		consumerTag, // consumer tag
+		false,       // auto-ack
+		false,       // exclusive
+		false,       // no-local
+		false,       // no-wait
+		nil,         // arguments
 	)
 	if err != nil {
 		return err
 	}
 
+	c.done = make(chan struct{})
 	go func() {
-		for {
-			select {
-			case <-ctx.Done():
+		defer close(c.done)
+		c.consume(ctx, msgs, ready)
+	}()
+
+	return nil
+}
+
+// consume handles deliveries on workerCount workers, once ready is closed,
+// until ctx is canceled or msgs is closed
+func (c *Consumer) consume(ctx context.Context, msgs <-chan amqp091.Delivery, ready <-chan struct{}) {
+	if ready != nil {
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			// nothing was handled yet, the prefetched message goes back to the queue
+			if err := c.channel.Cancel(consumerTag, false); err != nil {
+				log.Printf("Failed to cancel consumer: %v", err)
+			}
+			return
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.workerCount(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.work(ctx, msgs)
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		// stop new deliveries, prefetched messages no worker took are still
+		// unacked and go back to the queue when the channel closes
+		if err := c.channel.Cancel(consumerTag, false); err != nil {
+			log.Printf("Failed to cancel consumer: %v", err)
+		}
+	}
+}
+
+// work handles deliveries one at a time until ctx is canceled or msgs is
+// closed. Cancellation is only observed between messages, so the one in
+// flight always gets its ack/nack.
+func (c *Consumer) work(ctx context.Context, msgs <-chan amqp091.Delivery) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-msgs:
+			if !ok { // channel closed
 				return
-			case msg := <-msgs:
-				if msg.DeliveryTag == 0 { // channel closed
-					return
-				}
-
-				var orderMsg OrderExpirationMessage
-				err := json.Unmarshal(msg.Body, &orderMsg)
-				if err != nil {
-					log.Printf("Failed to unmarshal message: %v", err)
-					msg.Ack(false)
-					continue
-				}
-
-				// Call cancel order API
-				err = c.callCancelOrderAPI(orderMsg.OrderID, orderMsg.UserID)
-				if err != nil {
-					log.Printf("Failed to cancel order %d: %v", orderMsg.OrderID, err)
-					// Negative ack to requeue
-					msg.Nack(false, true)
-					continue
-				}
-
-				// Success - acknowledge the message
-				msg.Ack(false)
-				log.Printf("Order %d cancelled successfully", orderMsg.OrderID)
 			}
+			consumerMessagesProcessed.Inc()
+			c.handleDelivery(msg)
+			c.lastProcessed.Store(time.Now().UnixNano())
 		}
-	}()
+	}
+}
 
-	return nil
+// Shutdown blocks until the consume loop has drained after the Start context
+// is canceled, then closes the channel and connection. If ctx expires first
+// the connection is closed anyway and ctx.Err() is returned; an unacked
+// message is then redelivered by the broker.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	if c.done != nil {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			_ = c.Close()
+			return ctx.Err()
+		}
+	}
+	return c.Close()
+}
+
+func (c *Consumer) handleDelivery(msg amqp091.Delivery) {
+	var orderMsg OrderExpirationMessage
+	err := json.Unmarshal(msg.Body, &orderMsg)
+	if err == nil && orderMsg.OrderID == 0 {
+		err = fmt.Errorf("missing order_id")
+	}
+	if err != nil {
+		// the order it was meant to expire would stay pending for good, keep
+		// the message where someone can look at it instead of dropping it
+		log.Printf("Malformed expiration message, dead-lettering it: %v, body: %q", err, msg.Body)
+		consumerMessagesMalformed.Inc()
+		c.deadLetter(msg, deadLetterReasonMalformed)
+		return
+	}
+
+	// messages relayed from the outbox carry no request id, start a new one
+	requestID := orderMsg.RequestID
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+
+	// Call cancel order API
+	err = c.callCancelOrderAPI(orderMsg.OrderID, orderMsg.UserID, requestID)
+	if err != nil {
+		consumerCancelFailures.Inc()
+		log.Printf("Failed to cancel order %d (request_id=%s): %v", orderMsg.OrderID, requestID, err)
+		c.handleFailure(msg, orderMsg.OrderID)
+		return
+	}
+
+	// Success - acknowledge the message
+	consumerCancelSuccess.Inc()
+	msg.Ack(false)
+	log.Printf("Order %d cancelled successfully (request_id=%s)", orderMsg.OrderID, requestID)
+}
+
+// handleFailure sends a failed message to the retry queue, or to the
+// dead-letter queue once it has been redelivered maxRedeliveries times
+func (c *Consumer) handleFailure(msg amqp091.Delivery, orderID uint64) {
+	attempts := deathCount(msg.Headers, expirationQueue) + 1
+	if attempts < int64(c.maxRedeliveries) {
+		// reject without requeue, the queue dead-letters it to the retry queue
+		consumerMessagesRequeued.Inc()
+		msg.Nack(false, false)
+		return
+	}
+
+	if c.deadLetter(msg, deadLetterReasonRetriesExhausted) {
+		log.Printf("Order %d cancel failed %d times, moved to dead-letter queue %s", orderID, attempts, deadLetterQueue)
+	}
+}
+
+// deadLetter moves msg to the dead-letter queue and reports whether it did.
+// Rejecting it would route it to the retry queue, so a copy is published to
+// the dead-letter exchange and the original acked. When publishing fails the
+// message goes to the retry queue and is dead-lettered on a later delivery.
+func (c *Consumer) deadLetter(msg amqp091.Delivery, reason string) bool {
+	headers := amqp091.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[deadLetterReasonHeader] = reason
+
+	err := c.publish(deadLetterExchange, expirationRoutingKey, amqp091.Publishing{
+		ContentType: msg.ContentType,
+		Body:        msg.Body,
+		Headers:     headers,
+	})
+	if err != nil {
+		log.Printf("Failed to dead-letter message (%s), will retry: %v", reason, err)
+		consumerMessagesRequeued.Inc()
+		msg.Nack(false, false)
+		return false
+	}
+	consumerMessagesDeadLettered.Inc()
+	msg.Ack(false)
+	return true
 }
 
-func (c *Consumer) callCancelOrderAPI(orderID, userID uint64) error {
+// deathCount returns how many times the message was rejected from queue,
+// based on the x-death header RabbitMQ maintains when dead-lettering
+func deathCount(headers amqp091.Table, queue string) int64 {
+	deaths, ok := headers["x-death"].([]interface{})
+	if !ok {
+		return 0
+	}
+	for _, d := range deaths {
+		death, ok := d.(amqp091.Table)
+		if !ok || death["queue"] != queue || death["reason"] != "rejected" {
+			continue
+		}
+		if count, ok := death["count"].(int64); ok {
+			return count
+		}
+	}
+	return 0
+}
+
+func (c *Consumer) callCancelOrderAPI(orderID, userID uint64, requestID string) error {
+	// the cancel API's capacity, not the number of workers, sets the pace
+	if c.cancelSlots != nil {
+		c.cancelSlots <- struct{}{}
+		defer func() { <-c.cancelSlots }()
+	}
+
 	url := fmt.Sprintf("%s/internal/v1/order/%d/cancel", c.apiURL, orderID)
 
 	req, err := http.NewRequest("POST", url, nil)
@@ -154,6 +307,7 @@ func (c *Consumer) callCancelOrderAPI(orderID, userID uint64) error {
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Internal-Service", "order-expiration-consumer")
+	req.Header.Set(constant.RequestIDHeader, requestID)
 
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
@@ -164,11 +318,21 @@ func (c *Consumer) callCancelOrderAPI(orderID, userID uint64) error {
 
 	body, _ := io.ReadAll(resp.Body)
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 500 {
-		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
 	}
 
-	return nil
+	// an order that was paid or moved on in the meantime cannot expire anymore,
+	// that is an expected outcome rather than a failure worth retrying
+	var apiErr struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Code == constant.ErrorTypeCode[constant.ErrInvalidOrderStatus] {
+		log.Printf("Order %d is no longer pending, skipping cancel", orderID)
+		return nil
+	}
+
+	return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
 }
 
 func (c *Consumer) Close() error {
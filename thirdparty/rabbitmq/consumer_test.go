@@ -0,0 +1,212 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// fakeAcknowledger records how a delivery was settled
+type fakeAcknowledger struct {
+	acks, nacks int
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error { a.acks++; return nil }
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error { a.nacks++; return nil }
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error { a.nacks++; return nil }
+
+func TestConsumer_MetricsOnDeliveries(t *testing.T) {
+	// order 1 cancels fine, order 2 keeps failing
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal/v1/order/1/cancel" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer api.Close()
+
+	c := &Consumer{apiURL: api.URL, apiKey: "key", maxRedeliveries: 5}
+	ack := &fakeAcknowledger{}
+	delivery := func(orderID uint64) amqp091.Delivery {
+		body, _ := json.Marshal(OrderExpirationMessage{OrderID: orderID, UserID: 9})
+		return amqp091.Delivery{Acknowledger: ack, Body: body}
+	}
+
+	before := map[string]float64{
+		"processed": testutil.ToFloat64(consumerMessagesProcessed),
+		"success":   testutil.ToFloat64(consumerCancelSuccess),
+		"failures":  testutil.ToFloat64(consumerCancelFailures),
+		"requeued":  testutil.ToFloat64(consumerMessagesRequeued),
+	}
+
+	msgs := make(chan amqp091.Delivery, 3)
+	msgs <- delivery(1)
+	msgs <- delivery(2)
+	msgs <- delivery(1)
+	close(msgs)
+	c.consume(context.Background(), msgs, nil)
+
+	got := map[string]float64{
+		"processed": testutil.ToFloat64(consumerMessagesProcessed) - before["processed"],
+		"success":   testutil.ToFloat64(consumerCancelSuccess) - before["success"],
+		"failures":  testutil.ToFloat64(consumerCancelFailures) - before["failures"],
+		"requeued":  testutil.ToFloat64(consumerMessagesRequeued) - before["requeued"],
+	}
+	want := map[string]float64{"processed": 3, "success": 2, "failures": 1, "requeued": 1}
+	for k, w := range want {
+		if got[k] != w {
+			t.Errorf("%s increased by %v, want %v", k, got[k], w)
+		}
+	}
+	if ack.acks != 2 || ack.nacks != 1 {
+		t.Errorf("acks = %d, nacks = %d, want 2 and 1", ack.acks, ack.nacks)
+	}
+}
+
+func TestConsumer_WaitsForReady(t *testing.T) {
+	calls := make(chan string, 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls <- r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer api.Close()
+
+	c := &Consumer{apiURL: api.URL, apiKey: "key", maxRedeliveries: 5}
+	body, _ := json.Marshal(OrderExpirationMessage{OrderID: 7, UserID: 9})
+	msgs := make(chan amqp091.Delivery, 1)
+	msgs <- amqp091.Delivery{Acknowledger: &fakeAcknowledger{}, Body: body}
+	close(msgs)
+
+	ready := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.consume(context.Background(), msgs, ready)
+	}()
+
+	select {
+	case path := <-calls:
+		t.Fatalf("cancel API called before ready: %s", path)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(ready)
+	select {
+	case path := <-calls:
+		if path != "/internal/v1/order/7/cancel" {
+			t.Fatalf("called %s, want /internal/v1/order/7/cancel", path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancel API not called after ready")
+	}
+	<-done
+}
+
+func TestConsumer_CancelConcurrencyCap(t *testing.T) {
+	const (
+		workers    = 8
+		limit      = 3
+		deliveries = 20
+	)
+	var inFlight, peak int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer api.Close()
+
+	c := &Consumer{apiURL: api.URL, apiKey: "key", maxRedeliveries: 5, workers: workers, cancelSlots: newCancelSlots(limit)}
+
+	// a burst of deliveries handled by more workers than the cap allows calls
+	acks := make([]*fakeAcknowledger, deliveries)
+	msgs := make(chan amqp091.Delivery, deliveries)
+	for i := range acks {
+		acks[i] = &fakeAcknowledger{}
+		body, _ := json.Marshal(OrderExpirationMessage{OrderID: uint64(i + 1), UserID: 9})
+		msgs <- amqp091.Delivery{Acknowledger: acks[i], Body: body}
+	}
+	close(msgs)
+	c.consume(context.Background(), msgs, nil)
+
+	if peak > limit {
+		t.Fatalf("peak concurrent cancel calls = %d, want at most %d", peak, limit)
+	}
+	if peak < limit {
+		t.Fatalf("peak concurrent cancel calls = %d, the burst should have reached the cap of %d", peak, limit)
+	}
+	for i, ack := range acks {
+		if ack.acks != 1 {
+			t.Errorf("delivery %d acks = %d, want 1", i+1, ack.acks)
+		}
+	}
+}
+
+func TestConsumer_DeadLettersMalformedMessages(t *testing.T) {
+	type published struct {
+		exchange string
+		msg      amqp091.Publishing
+	}
+	tests := []struct {
+		name       string
+		body       string
+		publishErr error
+		wantAcks   int
+		wantNacks  int
+	}{
+		{name: "not json", body: `{"order_id":`, wantAcks: 1},
+		{name: "no order id", body: `{"user_id":9}`, wantAcks: 1},
+		{name: "dead-letter publish fails, sent to retry", body: `not json`, publishErr: errors.New("channel closed"), wantNacks: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("cancel API called for a malformed message: %s", r.URL.Path)
+			}))
+			defer api.Close()
+
+			var got []published
+			c := &Consumer{apiURL: api.URL, apiKey: "key", maxRedeliveries: 5,
+				publish: func(exchange, key string, msg amqp091.Publishing) error {
+					got = append(got, published{exchange: exchange, msg: msg})
+					return tt.publishErr
+				},
+			}
+			ack := &fakeAcknowledger{}
+			before := testutil.ToFloat64(consumerMessagesMalformed)
+
+			c.handleDelivery(amqp091.Delivery{Acknowledger: ack, Body: []byte(tt.body)})
+
+			if got := testutil.ToFloat64(consumerMessagesMalformed) - before; got != 1 {
+				t.Errorf("malformed messages increased by %v, want 1", got)
+			}
+			if len(got) != 1 {
+				t.Fatalf("published %d messages, want 1 to the dead-letter exchange", len(got))
+			}
+			if p := got[0]; p.exchange != deadLetterExchange || string(p.msg.Body) != tt.body || p.msg.Headers[deadLetterReasonHeader] != deadLetterReasonMalformed {
+				t.Errorf("published to %s body %q reason %v, want %s %q %s", p.exchange, p.msg.Body, p.msg.Headers[deadLetterReasonHeader], deadLetterExchange, tt.body, deadLetterReasonMalformed)
+			}
+			if ack.acks != tt.wantAcks || ack.nacks != tt.wantNacks {
+				t.Errorf("acks = %d, nacks = %d, want %d and %d", ack.acks, ack.nacks, tt.wantAcks, tt.wantNacks)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// deadLetterAlertQueue gets a copy of every dead-lettered message, the
+	// dead-letter queue itself is left untouched for manual inspection
+	deadLetterAlertQueue = "order_expiration_dlq_alerts"
+	// an alert queue nobody consumes anymore is dropped instead of filling up
+	deadLetterAlertQueueExpiry = 24 * time.Hour
+	deadLetterMonitorTag       = "order_expiration_dlq_monitor"
+)
+
+// DeadLetterMonitor raises an alert for every expiration message moved to the
+// dead-letter queue, an order that stays pending until someone cancels it by hand
+type DeadLetterMonitor struct {
+	conn    *amqp091.Connection
+	channel *amqp091.Channel
+	warn    func(msg string, fields ...zap.Field)
+	// done is closed once the consume loop has exited
+	done chan struct{}
+}
+
+func NewDeadLetterMonitor(host string, port int, user, password string) (*DeadLetterMonitor, error) {
+	dsn := fmt.Sprintf("amqp://%s:%s@%s:%d/", user, password, host, port)
+	conn, err := amqp091.Dial(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := declareTopology(channel); err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, err
+	}
+	_, err = channel.QueueDeclare(
+		deadLetterAlertQueue, // name
+		true,                 // durable
+		false,                // auto-delete
+		false,                // exclusive
+		false,                // no-wait
+		amqp091.Table{"x-expires": deadLetterAlertQueueExpiry.Milliseconds()}, // arguments
+	)
+	if err == nil {
+		err = channel.QueueBind(deadLetterAlertQueue, expirationRoutingKey, deadLetterExchange, false, nil)
+	}
+	if err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, err
+	}
+
+	return &DeadLetterMonitor{conn: conn, channel: channel, warn: logger.Warn}, nil
+}
+
+// Start begins alerting on dead-lettered messages until ctx is canceled
+func (m *DeadLetterMonitor) Start(ctx context.Context) error {
+	msgs, err := m.channel.Consume(
+		deadLetterAlertQueue,
+		deadLetterMonitorTag, // consumer tag
+		false,                // auto-ack
+		false,                // exclusive
+		false,                // no-local
+		false,                // no-wait
+		nil,                  // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	m.done = make(chan struct{})
+	go func() {
+		defer close(m.done)
+		m.consume(ctx, msgs)
+	}()
+	return nil
+}
+
+func (m *DeadLetterMonitor) consume(ctx context.Context, msgs <-chan amqp091.Delivery) {
+	for {
+		select {
+		case <-ctx.Done():
+			if err := m.channel.Cancel(deadLetterMonitorTag, false); err != nil {
+				log.Printf("Failed to cancel dead-letter monitor: %v", err)
+			}
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			m.handleDelivery(msg)
+		}
+	}
+}
+
+func (m *DeadLetterMonitor) handleDelivery(msg amqp091.Delivery) {
+	deadLetterAlerts.Inc()
+
+	fields := []zap.Field{
+		zap.String("queue", deadLetterQueue),
+		zap.Int64("attempts", deathCount(msg.Headers, expirationQueue)),
+	}
+	if reason, ok := msg.Headers[deadLetterReasonHeader].(string); ok {
+		fields = append(fields, zap.String("reason", reason))
+	}
+	var orderMsg OrderExpirationMessage
+	if err := json.Unmarshal(msg.Body, &orderMsg); err == nil {
+		fields = append(fields,
+			zap.Uint64("order_id", orderMsg.OrderID),
+			zap.Uint64("user_id", orderMsg.UserID),
+			zap.String("request_id", orderMsg.RequestID),
+		)
+	}
+	m.warn("order expiration dead-lettered, the order is not auto-canceled and needs manual handling", fields...)
+	msg.Ack(false)
+}
+
+// Shutdown waits for the consume loop to exit after the Start context is
+// canceled, then closes the channel and connection
+func (m *DeadLetterMonitor) Shutdown(ctx context.Context) error {
+	if m.done != nil {
+		select {
+		case <-m.done:
+		case <-ctx.Done():
+			_ = m.Close()
+			return ctx.Err()
+		}
+	}
+	return m.Close()
+}
+
+func (m *DeadLetterMonitor) Close() error {
+	if m.channel != nil {
+		m.channel.Close()
+	}
+	if m.conn != nil {
+		m.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+func TestDeadLetterMonitor_AlertsOnDeadLetteredMessage(t *testing.T) {
+	type warning struct {
+		msg    string
+		fields map[string]zap.Field
+	}
+	var warnings []warning
+	m := &DeadLetterMonitor{warn: func(msg string, fields ...zap.Field) {
+		w := warning{msg: msg, fields: make(map[string]zap.Field, len(fields))}
+		for _, f := range fields {
+			w.fields[f.Key] = f
+		}
+		warnings = append(warnings, w)
+	}}
+
+	body, _ := json.Marshal(OrderExpirationMessage{OrderID: 42, UserID: 9, RequestID: "req-1"})
+	headers := amqp091.Table{"x-death": []interface{}{
+		amqp091.Table{"queue": expirationQueue, "reason": "rejected", "count": int64(5)},
+	}}
+	ack := &fakeAcknowledger{}
+	before := testutil.ToFloat64(deadLetterAlerts)
+
+	m.handleDelivery(amqp091.Delivery{Acknowledger: ack, Body: body, Headers: headers})
+
+	if got := testutil.ToFloat64(deadLetterAlerts) - before; got != 1 {
+		t.Fatalf("dead letter alerts increased by %v, want 1", got)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("logged %d warnings, want 1", len(warnings))
+	}
+	w := warnings[0]
+	if got := w.fields["order_id"].Integer; got != 42 {
+		t.Errorf("order_id = %d, want 42", got)
+	}
+	if got := w.fields["attempts"].Integer; got != 5 {
+		t.Errorf("attempts = %d, want 5", got)
+	}
+	if got := w.fields["request_id"].String; got != "req-1" {
+		t.Errorf("request_id = %q, want req-1", got)
+	}
+	if ack.acks != 1 || ack.nacks != 0 {
+		t.Errorf("acks = %d, nacks = %d, want 1 and 0", ack.acks, ack.nacks)
+	}
+}
@@ -0,0 +1,42 @@
+package rabbitmq
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Consumer counters, together they show whether auto-cancellation is healthy:
+// processed should roughly equal cancel successes, growing failures mean the
+// cancel API is unhealthy
+var (
+	consumerMessagesProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_expiration_messages_processed_total",
+		Help: "Order expiration messages received by the consumer.",
+	})
+	consumerCancelSuccess = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_expiration_cancel_success_total",
+		Help: "Order expiration messages whose cancel API call succeeded.",
+	})
+	consumerCancelFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_expiration_cancel_failures_total",
+		Help: "Order expiration messages whose cancel API call failed.",
+	})
+	consumerMessagesRequeued = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_expiration_messages_requeued_total",
+		Help: "Failed order expiration messages sent to the retry queue.",
+	})
+	consumerMessagesDeadLettered = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_expiration_messages_dead_lettered_total",
+		Help: "Order expiration messages moved to the dead-letter queue after exhausting retries.",
+	})
+	consumerMessagesMalformed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_expiration_messages_malformed_total",
+		Help: "Order expiration messages that could not be parsed and were dead-lettered.",
+	})
+	// deadLetterAlerts is counted by the DeadLetterMonitor from the broker's
+	// side, it also catches messages dead-lettered by another instance
+	deadLetterAlerts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_expiration_dead_letter_alerts_total",
+		Help: "Dead-lettered order expiration messages seen by the dead-letter monitor.",
+	})
+)
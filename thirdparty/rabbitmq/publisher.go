@@ -2,82 +2,160 @@ package rabbitmq
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"sync"
 	"time"
 
 	"github.com/rabbitmq/amqp091-go"
 )
 
+const (
+	reconnectMaxAttempts  = 5
+	reconnectInitialDelay = 200 * time.Millisecond
+	reconnectMaxDelay     = 5 * time.Second
+)
+
+// ErrDisconnected is returned by a publish while the broker connection is down.
+// The reconnect runs in the background, callers keep the message in their
+// outbox and retry it later instead of waiting for the broker.
+var ErrDisconnected = errors.New("rabbitmq publisher is disconnected")
+
 type Publisher struct {
+	dsn     string
+	mu      sync.Mutex
 	conn    *amqp091.Connection
 	channel *amqp091.Channel
+	// reconnecting is set while the single background reconnect runs, closed
+	// once Close was called so it doesn't bring the connection back
+	reconnecting bool
+	closed       bool
 }
 
 type OrderExpirationMessage struct {
 	OrderID   uint64    `json:"order_id"`
 	UserID    uint64    `json:"user_id"`
 	ExpiresAt time.Time `json:"expires_at"`
+	// RequestID correlates the expiration cancel with the request that created the order
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// BackInStockMessage tells a subscriber that a product they waited for can be ordered again
+type BackInStockMessage struct {
+	UserID         uint64    `json:"user_id"`
+	ProductID      uint64    `json:"product_id"`
+	AvailableStock int64     `json:"available_stock"`
+	OccurredAt     time.Time `json:"occurred_at"`
 }
 
 func NewPublisher(host string, port int, user, password string) (*Publisher, error) {
-	dsn := fmt.Sprintf("amqp://%s:%s@%s:%d/", user, password, host, port)
-	conn, err := amqp091.Dial(dsn)
-	if err != nil {
+	p := &Publisher{dsn: fmt.Sprintf("amqp://%s:%s@%s:%d/", user, password, host, port)}
+	if err := p.connect(); err != nil {
 		return nil, err
 	}
+	return p, nil
+}
 
-	channel, err := conn.Channel()
+// connect dials the broker, opens a channel and declares the topology.
+// Caller must hold p.mu (or own p exclusively).
+func (p *Publisher) connect() error {
+	conn, channel, err := dial(p.dsn)
 	if err != nil {
-		conn.Close()
-		return nil, err
+		return err
 	}
+	p.conn = conn
+	p.channel = channel
+	return nil
+}
 
-	// Declare the delayed exchange
-	err = channel.ExchangeDeclare(
-		"order_expiration_exchange", // name
-		"x-delayed-message",         // type
-		true,                        // durable
-		false,                       // auto-delete
-		false,                       // internal
-		false,                       // no-wait
-		amqp091.Table{"x-delayed-type": "direct"}, // arguments
-	)
+// dial opens a connection and a channel with the topology declared
+func dial(dsn string) (*amqp091.Connection, *amqp091.Channel, error) {
+	conn, err := amqp091.Dial(dsn)
 	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Declare the queue
-	_, err = channel.QueueDeclare(
-		"order_expiration_queue", // name
-		true,                     // durable
-		false,                    // auto-delete
-		false,                    // exclusive
-		false,                    // no-wait
-		nil,                      // arguments
-	)
+	channel, err := conn.Channel()
 	if err != nil {
-		channel.Close()
 		conn.Close()
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Bind queue to exchange
-	err = channel.QueueBind(
-		"order_expiration_queue",    // queue name
-		"order_expiration",          // routing key
-		"order_expiration_exchange", // exchange
-		false,                       // no-wait
-		nil,                         // arguments
-	)
-	if err != nil {
+	if err := declareTopology(channel); err != nil {
 		channel.Close()
 		conn.Close()
-		return nil, err
+		return nil, nil, err
+	}
+	return conn, channel, nil
+}
+
+// healthy reports whether both the connection and the channel are open.
+func (p *Publisher) healthy() bool {
+	return p.conn != nil && !p.conn.IsClosed() && p.channel != nil && !p.channel.IsClosed()
+}
+
+// startReconnect tears down the current connection and starts the background
+// reconnect, unless one is already running. Caller must hold p.mu.
+func (p *Publisher) startReconnect() {
+	if p.reconnecting || p.closed {
+		return
+	}
+	p.reconnecting = true
+	p.closeConn()
+	go p.reconnect()
+}
+
+// reconnect dials again with exponential backoff, re-declaring the topology on
+// success. It dials without holding p.mu, publishes meanwhile fail fast with
+// ErrDisconnected. After the last attempt it gives up until the next publish
+// starts it again.
+func (p *Publisher) reconnect() {
+	delay := reconnectInitialDelay
+	for attempt := 1; ; attempt++ {
+		conn, channel, err := dial(p.dsn)
+		if err == nil {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.reconnecting = false
+			if p.closed {
+				channel.Close()
+				conn.Close()
+				return
+			}
+			p.conn = conn
+			p.channel = channel
+			log.Printf("RabbitMQ publisher reconnected after %d attempt(s)", attempt)
+			return
+		}
+		log.Printf("RabbitMQ publisher reconnect attempt %d failed: %v", attempt, err)
+
+		p.mu.Lock()
+		if attempt == reconnectMaxAttempts || p.closed {
+			p.reconnecting = false
+			p.mu.Unlock()
+			log.Printf("RabbitMQ publisher gave up reconnecting after %d attempt(s)", attempt)
+			return
+		}
+		p.mu.Unlock()
+
+		time.Sleep(delay)
+		delay *= 2
+		if delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
 	}
+}
 
-	return &Publisher{conn: conn, channel: channel}, nil
+func (p *Publisher) closeConn() {
+	if p.channel != nil {
+		p.channel.Close()
+		p.channel = nil
+	}
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
 }
 
 func (p *Publisher) PublishOrderExpiration(msg OrderExpirationMessage) error {
@@ -91,27 +169,64 @@ func (p *Publisher) PublishOrderExpiration(msg OrderExpirationMessage) error {
 		delayMs = 0
 	}
 
-	return p.channel.Publish(
-		"order_expiration_exchange", // exchange
-		"order_expiration",          // routing key
-		false,                       // mandatory
-		false,                       // immediate
-		amqp091.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-			Headers: amqp091.Table{
-				"x-delay": delayMs,
-			},
+	publishing := amqp091.Publishing{
+		ContentType: "application/json",
+		Body:        body,
+		Headers: amqp091.Table{
+			"x-delay": delayMs,
 		},
-	)
+	}
+	return p.publishWithReconnect(expirationExchange, expirationRoutingKey, publishing)
 }
 
-func (p *Publisher) Close() error {
-	if p.channel != nil {
-		p.channel.Close()
+func (p *Publisher) PublishBackInStock(msg BackInStockMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
 	}
-	if p.conn != nil {
-		p.conn.Close()
+
+	publishing := amqp091.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp091.Persistent,
+		Body:         body,
+	}
+	return p.publishWithReconnect(productEventsExchange, backInStockRoutingKey, publishing)
+}
+
+// publishWithReconnect publishes on the current channel. While disconnected it
+// starts the background reconnect and fails with ErrDisconnected right away, a
+// failed publish does the same, so a broker outage never blocks the caller.
+func (p *Publisher) publishWithReconnect(exchange, routingKey string, msg amqp091.Publishing) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.healthy() {
+		p.startReconnect()
+		return ErrDisconnected
+	}
+
+	if err := p.publish(exchange, routingKey, msg); err != nil {
+		log.Printf("RabbitMQ publish failed, reconnecting: %v", err)
+		p.startReconnect()
+		return err
 	}
 	return nil
 }
+
+func (p *Publisher) publish(exchange, routingKey string, msg amqp091.Publishing) error {
+	return p.channel.Publish(
+		exchange,   // exchange
+		routingKey, // routing key
+		false,      // mandatory
+		false,      // immediate
+		msg,
+	)
+}
+
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.closeConn()
+	return nil
+}
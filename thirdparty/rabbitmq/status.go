@@ -0,0 +1,39 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/muhammadheryan/e-commerce/model"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// channelInspector is the part of the channel Status reads, faked in tests
+type channelInspector interface {
+	IsClosed() bool
+	QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp091.Table) (amqp091.Queue, error)
+}
+
+// Status reports whether the consumer is connected, when it last handled a
+// message and the backlog of its queue. The queue is only inspected while
+// the channel is open, a closed connection closes its channels too.
+func (c *Consumer) Status() (*model.ConsumerStatus, error) {
+	status := &model.ConsumerStatus{}
+	if nanos := c.lastProcessed.Load(); nanos != 0 {
+		at := time.Unix(0, nanos).UTC()
+		status.LastProcessedAt = &at
+	}
+	if c.inspector == nil || c.inspector.IsClosed() {
+		return status, nil
+	}
+	status.Connected = true
+
+	// passive, the queue's arguments are neither checked nor changed
+	queue, err := c.inspector.QueueDeclarePassive(expirationQueue, true, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("inspect %s: %w", expirationQueue, err)
+	}
+	status.QueueDepth = queue.Messages
+	status.QueueConsumers = queue.Consumers
+	return status, nil
+}
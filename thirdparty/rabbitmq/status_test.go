@@ -0,0 +1,91 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// fakeChannel reports a fixed queue state
+type fakeChannel struct {
+	closed    bool
+	queue     amqp091.Queue
+	err       error
+	inspected string
+}
+
+func (f *fakeChannel) IsClosed() bool { return f.closed }
+
+func (f *fakeChannel) QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp091.Table) (amqp091.Queue, error) {
+	f.inspected = name
+	return f.queue, f.err
+}
+
+func TestConsumer_Status(t *testing.T) {
+	t.Run("open channel reports the queue depth", func(t *testing.T) {
+		ch := &fakeChannel{queue: amqp091.Queue{Name: expirationQueue, Messages: 42, Consumers: 1}}
+		c := &Consumer{inspector: ch}
+
+		status, err := c.Status()
+		if err != nil {
+			t.Fatalf("Status() error = %v", err)
+		}
+		if ch.inspected != expirationQueue {
+			t.Errorf("inspected queue %q, want %q", ch.inspected, expirationQueue)
+		}
+		if !status.Connected || status.QueueDepth != 42 || status.QueueConsumers != 1 {
+			t.Errorf("Status() = %+v, want connected with depth 42 and 1 consumer", status)
+		}
+		if status.LastProcessedAt != nil {
+			t.Errorf("LastProcessedAt = %v before any message, want nil", status.LastProcessedAt)
+		}
+	})
+
+	t.Run("closed channel is not inspected", func(t *testing.T) {
+		ch := &fakeChannel{closed: true}
+		status, err := (&Consumer{inspector: ch}).Status()
+		if err != nil {
+			t.Fatalf("Status() error = %v", err)
+		}
+		if status.Connected || ch.inspected != "" {
+			t.Errorf("Status() = %+v, inspected %q, want disconnected and no inspection", status, ch.inspected)
+		}
+	})
+
+	t.Run("failed inspection", func(t *testing.T) {
+		c := &Consumer{inspector: &fakeChannel{err: errors.New("channel closed")}}
+		if _, err := c.Status(); err == nil {
+			t.Fatal("Status() error = nil, want the inspection error")
+		}
+	})
+
+	t.Run("last processed time follows deliveries", func(t *testing.T) {
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer api.Close()
+
+		c := &Consumer{apiURL: api.URL, apiKey: "key", maxRedeliveries: 5, inspector: &fakeChannel{}}
+		body, _ := json.Marshal(OrderExpirationMessage{OrderID: 1, UserID: 9})
+		msgs := make(chan amqp091.Delivery, 1)
+		msgs <- amqp091.Delivery{Acknowledger: &fakeAcknowledger{}, Body: body}
+		close(msgs)
+
+		before := time.Now()
+		c.consume(context.Background(), msgs, nil)
+
+		status, err := c.Status()
+		if err != nil {
+			t.Fatalf("Status() error = %v", err)
+		}
+		if status.LastProcessedAt == nil || status.LastProcessedAt.Before(before) {
+			t.Fatalf("LastProcessedAt = %v, want after %v", status.LastProcessedAt, before)
+		}
+	})
+}
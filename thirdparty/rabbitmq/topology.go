@@ -0,0 +1,121 @@
+package rabbitmq
+
+import (
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	expirationExchange   = "order_expiration_exchange"
+	expirationQueue      = "order_expiration_queue"
+	expirationRoutingKey = "order_expiration"
+
+	// failed cancels are dead-lettered to the retry queue and come back to
+	// expirationQueue after retryDelay, each round adds to the x-death count
+	retryExchange = "order_expiration_retry_exchange"
+	retryQueue    = "order_expiration_retry_queue"
+	retryDelay    = 10 * time.Second
+
+	// messages that exceeded the max redelivery count end up here
+	deadLetterExchange = "order_expiration_dlx"
+	deadLetterQueue    = "order_expiration_dlq"
+	// deadLetterReasonHeader tells why a message was dead-lettered
+	deadLetterReasonHeader           = "x-dead-letter-reason"
+	deadLetterReasonRetriesExhausted = "retries_exhausted"
+	deadLetterReasonMalformed        = "malformed"
+
+	// product events are published for other services (e.g. the mailer) to
+	// bind their own queues to, this service declares no queue for them
+	productEventsExchange = "product_events_exchange"
+	backInStockRoutingKey = "product.back_in_stock"
+)
+
+// declareTopology declares the exchanges, queues and bindings shared by the
+// publisher and the consumer. Both sides must declare identical arguments.
+func declareTopology(channel *amqp091.Channel) error {
+	// Declare the delayed exchange
+	err := channel.ExchangeDeclare(
+		expirationExchange,  // name
+		"x-delayed-message", // type
+		true,                // durable
+		false,               // auto-delete
+		false,               // internal
+		false,               // no-wait
+		amqp091.Table{"x-delayed-type": "direct"}, // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	// Declare the queue, rejected messages go to the retry exchange
+	_, err = channel.QueueDeclare(
+		expirationQueue, // name
+		true,            // durable
+		false,           // auto-delete
+		false,           // exclusive
+		false,           // no-wait
+		amqp091.Table{
+			"x-dead-letter-exchange":    retryExchange,
+			"x-dead-letter-routing-key": expirationRoutingKey,
+		}, // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	// Bind queue to exchange
+	err = channel.QueueBind(
+		expirationQueue,      // queue name
+		expirationRoutingKey, // routing key
+		expirationExchange,   // exchange
+		false,                // no-wait
+		nil,                  // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	// Declare the retry exchange and queue, expired messages go back to the main queue
+	// through the default exchange
+	err = channel.ExchangeDeclare(retryExchange, "direct", true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	_, err = channel.QueueDeclare(
+		retryQueue, // name
+		true,       // durable
+		false,      // auto-delete
+		false,      // exclusive
+		false,      // no-wait
+		amqp091.Table{
+			"x-message-ttl":             retryDelay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": expirationQueue,
+		}, // arguments
+	)
+	if err != nil {
+		return err
+	}
+	err = channel.QueueBind(retryQueue, expirationRoutingKey, retryExchange, false, nil)
+	if err != nil {
+		return err
+	}
+
+	// Declare the product events exchange
+	err = channel.ExchangeDeclare(productEventsExchange, "topic", true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	// Declare the dead-letter exchange and queue
+	err = channel.ExchangeDeclare(deadLetterExchange, "direct", true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	_, err = channel.QueueDeclare(deadLetterQueue, true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	return channel.QueueBind(deadLetterQueue, expirationRoutingKey, deadLetterExchange, false, nil)
+}
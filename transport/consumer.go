@@ -0,0 +1,38 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/model"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
+	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"go.uber.org/zap"
+)
+
+// ConsumerStatus reports the state of the order expiration consumer, nil when
+// orders expire some other way and no consumer runs
+type ConsumerStatus func() (*model.ConsumerStatus, error)
+
+// @Summary Order expiration consumer status
+// @Description Whether the RabbitMQ expiration consumer is connected, when it last handled a message and how many messages wait in its queue. Not found when orders expire without RabbitMQ
+// @Tags Order
+// @Produce json
+// @Success 200 {object} model.ConsumerStatus
+// @Failure 400 {object} errors.CustomError
+// @Failure 503 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/consumer/status [get]
+func (s *RestHandler) GetConsumerStatus(w http.ResponseWriter, r *http.Request) {
+	if s.ConsumerStatus == nil {
+		writeError(w, errors.SetCustomError(constant.ErrNotFound))
+		return
+	}
+	status, err := s.ConsumerStatus()
+	if err != nil {
+		logger.Error("[GetConsumerStatus] inspect consumer", zap.String("error", err.Error()))
+		writeError(w, errors.SetCustomError(constant.ErrServiceUnavailable))
+		return
+	}
+	writeSuccess(w, status)
+}
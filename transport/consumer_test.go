@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	"github.com/muhammadheryan/e-commerce/model"
+)
+
+func TestGetConsumerStatus(t *testing.T) {
+	cfg := &config.Config{InternalAPIKey: "internal-key"}
+	tests := []struct {
+		name       string
+		status     ConsumerStatus
+		wantStatus int
+		wantDepth  int
+	}{
+		{
+			name: "reports the consumer",
+			status: func() (*model.ConsumerStatus, error) {
+				return &model.ConsumerStatus{Connected: true, QueueDepth: 7, QueueConsumers: 1}, nil
+			},
+			wantStatus: http.StatusOK,
+			wantDepth:  7,
+		},
+		{
+			name:       "no consumer running",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "broker unreachable",
+			status: func() (*model.ConsumerStatus, error) {
+				return nil, errors.New("channel closed")
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewTransport(nil, nil, nil, nil, nil, nil, cfg, nil, Sweeps{}, tt.status)
+
+			req := httptest.NewRequest(http.MethodGet, "/internal/v1/consumer/status", nil)
+			req.Header.Set("Authorization", "Bearer internal-key")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			env := decodeEnvelope(t, rec)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got model.ConsumerStatus
+			if err := json.Unmarshal(env["data"], &got); err != nil {
+				t.Fatalf("decode data %s: %v", env["data"], err)
+			}
+			if !got.Connected || got.QueueDepth != tt.wantDepth {
+				t.Fatalf("data = %+v, want connected with depth %d", got, tt.wantDepth)
+			}
+		})
+	}
+}
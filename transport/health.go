@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"go.uber.org/zap"
+)
+
+// readinessCheckTimeout bounds each dependency check so a hung dependency
+// fails the probe instead of stalling it
+const readinessCheckTimeout = 2 * time.Second
+
+// HealthCheck reports whether a dependency is reachable
+type HealthCheck func(ctx context.Context) error
+
+// HealthChecks are the readiness checks keyed by dependency name
+type HealthChecks map[string]HealthCheck
+
+// @Summary Liveness probe
+// @Description Returns 200 as long as the process is serving requests
+// @Tags Health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func (s *RestHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeSuccess(w, map[string]string{"status": "ok"})
+}
+
+// @Summary Readiness probe
+// @Description Checks every dependency (MySQL, Redis) and returns 503 with the per-dependency status when any of them fails
+// @Tags Health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /readyz [get]
+func (s *RestHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	statuses := make(map[string]string, len(s.HealthChecks))
+	ready := true
+	for name, check := range s.HealthChecks {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+		err := check(ctx)
+		cancel()
+		if err != nil {
+			logger.Error("[Readyz] dependency check failed", zap.String("dependency", name), zap.String("error", err.Error()))
+			statuses[name] = "down"
+			ready = false
+			continue
+		}
+		statuses[name] = "ok"
+	}
+
+	if !ready {
+		writeJson(w, constant.ErrorTypeHTTPCode[constant.ErrServiceUnavailable], body{
+			Code:    constant.ErrorTypeCode[constant.ErrServiceUnavailable],
+			Message: constant.ErrorTypeMessage[constant.ErrServiceUnavailable],
+			Data:    statuses,
+		})
+		return
+	}
+	writeSuccess(w, statuses)
+}
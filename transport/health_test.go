@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+)
+
+func TestHealthz(t *testing.T) {
+	rh := &RestHandler{}
+	rec := httptest.NewRecorder()
+	rh.Healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name       string
+		checks     HealthChecks
+		wantStatus int
+		wantCode   constant.ErrorType
+		wantDeps   map[string]string
+	}{
+		{
+			name:       "all dependencies up",
+			checks:     HealthChecks{"mysql": ok, "redis": ok},
+			wantStatus: http.StatusOK,
+			wantCode:   constant.Successful,
+			wantDeps:   map[string]string{"mysql": "ok", "redis": "ok"},
+		},
+		{
+			name:       "redis down",
+			checks:     HealthChecks{"mysql": ok, "redis": down},
+			wantStatus: http.StatusServiceUnavailable,
+			wantCode:   constant.ErrServiceUnavailable,
+			wantDeps:   map[string]string{"mysql": "ok", "redis": "down"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rh := &RestHandler{HealthChecks: tt.checks}
+			rec := httptest.NewRecorder()
+			rh.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var got struct {
+				Code string            `json:"code"`
+				Data map[string]string `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if got.Code != constant.ErrorTypeCode[tt.wantCode] {
+				t.Fatalf("code = %s, want %s", got.Code, constant.ErrorTypeCode[tt.wantCode])
+			}
+			for dep, want := range tt.wantDeps {
+				if got.Data[dep] != want {
+					t.Fatalf("%s = %q, want %q", dep, got.Data[dep], want)
+				}
+			}
+		})
+	}
+}
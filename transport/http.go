@@ -4,75 +4,164 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
+	cartapp "github.com/muhammadheryan/e-commerce/application/cart"
 	orderapp "github.com/muhammadheryan/e-commerce/application/order"
 	prodapp "github.com/muhammadheryan/e-commerce/application/product"
 	userapp "github.com/muhammadheryan/e-commerce/application/user"
 	warehouseapp "github.com/muhammadheryan/e-commerce/application/warehouse"
+	wishlistapp "github.com/muhammadheryan/e-commerce/application/wishlist"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
 	"github.com/muhammadheryan/e-commerce/constant"
 	"github.com/muhammadheryan/e-commerce/model"
 	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
 	"github.com/muhammadheryan/e-commerce/utils/errors"
 	validatorx "github.com/muhammadheryan/e-commerce/utils/validator"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
 type RestHandler struct {
-	UserApp      userapp.UserApp
-	ProductApp   prodapp.ProductApp
-	OrderApp     orderapp.OrderApp
-	WarehouseApp warehouseapp.WarehouseApp
+	UserApp        userapp.UserApp
+	ProductApp     prodapp.ProductApp
+	OrderApp       orderapp.OrderApp
+	WarehouseApp   warehouseapp.WarehouseApp
+	WishlistApp    wishlistapp.WishlistApp
+	CartApp        cartapp.CartApp
+	Config         *config.Config
+	HealthChecks   HealthChecks
+	Sweeps         Sweeps
+	ConsumerStatus ConsumerStatus
 }
 
-func NewTransport(UserApp userapp.UserApp, ProductApp prodapp.ProductApp, OrderApp orderapp.OrderApp, WarehouseApp warehouseapp.WarehouseApp, internalAPIKey string) http.Handler {
+func NewTransport(UserApp userapp.UserApp, ProductApp prodapp.ProductApp, OrderApp orderapp.OrderApp, WarehouseApp warehouseapp.WarehouseApp, WishlistApp wishlistapp.WishlistApp, CartApp cartapp.CartApp, cfg *config.Config, healthChecks HealthChecks, sweeps Sweeps, consumerStatus ConsumerStatus) http.Handler {
 	router := mux.NewRouter()
+	router.NotFoundHandler = notFoundHandler()
+	router.MethodNotAllowedHandler = methodNotAllowedHandler()
 
 	rh := &RestHandler{
-		UserApp:      UserApp,
-		ProductApp:   ProductApp,
-		OrderApp:     OrderApp,
-		WarehouseApp: WarehouseApp,
+		UserApp:        UserApp,
+		ProductApp:     ProductApp,
+		OrderApp:       OrderApp,
+		WarehouseApp:   WarehouseApp,
+		WishlistApp:    WishlistApp,
+		CartApp:        CartApp,
+		Config:         cfg,
+		HealthChecks:   healthChecks,
+		Sweeps:         sweeps,
+		ConsumerStatus: consumerStatus,
 	}
 
 	// Swagger UI
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
+	// Probes
+	router.HandleFunc("/healthz", rh.Healthz).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", rh.Readyz).Methods(http.MethodGet)
+	router.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
+
 	// Public routes
 	router.HandleFunc("/public/v1/register", rh.Register).Methods(http.MethodPost)
 	router.HandleFunc("/public/v1/login", rh.Login).Methods(http.MethodPost)
+	router.HandleFunc("/public/v1/verify", rh.VerifyEmail).Methods(http.MethodPost)
+	router.HandleFunc("/public/v1/verify/resend", rh.ResendVerification).Methods(http.MethodPost)
 
 	// Product routes
 	router.HandleFunc("/public/v1/product", rh.GetProducts).Methods(http.MethodGet)
-	router.HandleFunc("/public/v1//product/{id}", rh.GetProduct).Methods(http.MethodGet)
+	router.HandleFunc("/public/v1/product/feed", rh.GetProductFeed).Methods(http.MethodGet)
+	router.HandleFunc("/public/v1/product/sync", rh.SyncProducts).Methods(http.MethodGet)
+	router.HandleFunc("/public/v1/product/{id}", rh.GetProduct).Methods(http.MethodGet)
+	router.HandleFunc("/public/v1/product/{id}/stock", rh.GetProductStock).Methods(http.MethodGet)
+	router.HandleFunc("/public/v1/product/{id}/reviews", rh.ListProductReviews).Methods(http.MethodGet)
+	router.HandleFunc("/public/v1/product/{id}/reviews", rh.CreateProductReview).Methods(http.MethodPost)
+	router.HandleFunc("/public/v1/product/{id}/stock-subscription", rh.SubscribeBackInStock).Methods(http.MethodPost)
 
 	// Order
 	router.HandleFunc("/public/v1/order", rh.CreateOrder).Methods(http.MethodPost)
+	router.HandleFunc("/public/v1/order", rh.ListOrders).Methods(http.MethodGet)
+	router.HandleFunc("/public/v1/order/preview", rh.PreviewOrder).Methods(http.MethodPost)
 	router.HandleFunc("/public/v1/order/{id}/pay", rh.PayOrder).Methods(http.MethodPost)
 	router.HandleFunc("/public/v1/order/{id}/cancel", rh.CancelOrder).Methods(http.MethodPost)
+	router.HandleFunc("/public/v1/order/{id}/refund", rh.RefundOrder).Methods(http.MethodPost)
+
+	// Address
+	router.HandleFunc("/public/v1/addresses", rh.ListAddresses).Methods(http.MethodGet)
+	router.HandleFunc("/public/v1/addresses", rh.AddAddress).Methods(http.MethodPost)
+	router.HandleFunc("/public/v1/addresses/{id}", rh.UpdateAddress).Methods(http.MethodPut)
+	router.HandleFunc("/public/v1/addresses/{id}", rh.DeleteAddress).Methods(http.MethodDelete)
+
+	// Notification preferences
+	router.HandleFunc("/public/v1/notification-preferences", rh.GetNotificationPrefs).Methods(http.MethodGet)
+	router.HandleFunc("/public/v1/notification-preferences", rh.UpdateNotificationPrefs).Methods(http.MethodPut)
+
+	// Sessions
+	router.HandleFunc("/public/v1/me/sessions", rh.ListSessions).Methods(http.MethodGet)
+	router.HandleFunc("/public/v1/me/sessions", rh.RevokeAllSessions).Methods(http.MethodDelete)
 
-	// middleware
+	// Wishlist
+	router.HandleFunc("/public/v1/wishlist", rh.ListWishlist).Methods(http.MethodGet)
+	router.HandleFunc("/public/v1/wishlist", rh.AddToWishlist).Methods(http.MethodPost)
+	router.HandleFunc("/public/v1/wishlist/{product_id}", rh.RemoveFromWishlist).Methods(http.MethodDelete)
+
+	// Cart
+	router.HandleFunc("/public/v1/cart", rh.GetCart).Methods(http.MethodGet)
+	router.HandleFunc("/public/v1/cart/items", rh.AddCartItem).Methods(http.MethodPost)
+	router.HandleFunc("/public/v1/cart/items/{product_id}", rh.UpdateCartItem).Methods(http.MethodPut)
+	router.HandleFunc("/public/v1/cart/items/{product_id}", rh.RemoveCartItem).Methods(http.MethodDelete)
+
+	// middleware, the request id goes first so every later layer can log it
+	router.Use(RequestIDMiddleware())
 	router.Use(LoggingMiddleware())
+	router.Use(MetricsMiddleware())
+	router.Use(TimeoutMiddleware(cfg.Server.RequestTimeout))
+	router.Use(CompressionMiddleware(cfg.Server.Compression))
+	router.Use(BodyLogMiddleware(cfg.Server.BodyLog))
+	router.Use(RateLimitMiddleware(cfg.Server.RateLimit))
 	router.Use(AuthMiddleware(UserApp))
 
 	// Internal route for MQ cancel (no auth, just API key)
 	internal := mux.NewRouter()
+	internal.NotFoundHandler = notFoundHandler()
+	internal.MethodNotAllowedHandler = methodNotAllowedHandler()
 	internal.HandleFunc("/internal/v1/order/{id}/cancel", rh.InternalCancelOrder).Methods(http.MethodPost)
+	internal.HandleFunc("/internal/v1/order/{id}/approve", rh.ApproveOrder).Methods(http.MethodPost)
+	internal.HandleFunc("/internal/v1/order/{id}/reject", rh.RejectOrder).Methods(http.MethodPost)
+
+	// Product internal routes
+	internal.HandleFunc("/internal/v1/product", rh.CreateProduct).Methods(http.MethodPost)
+	internal.HandleFunc("/internal/v1/product/{id}", rh.UpdateProduct).Methods(http.MethodPatch)
+	internal.HandleFunc("/internal/v1/product/{id}", rh.DeleteProduct).Methods(http.MethodDelete)
 
 	// Warehouse internal routes
+	internal.HandleFunc("/internal/v1/warehouses", rh.ListWarehouses).Methods(http.MethodGet)
+	// registered before {id} so "low-stock" isn't taken for a warehouse id
+	internal.HandleFunc("/internal/v1/warehouses/low-stock", rh.LowStockReport).Methods(http.MethodGet)
+	internal.HandleFunc("/internal/v1/warehouses/{id}", rh.GetWarehouse).Methods(http.MethodGet)
 	internal.HandleFunc("/internal/v1/warehouses/{id}/activate", rh.ActivateWarehouse).Methods(http.MethodPatch)
 	internal.HandleFunc("/internal/v1/warehouses/{id}/deactivate", rh.DeactivateWarehouse).Methods(http.MethodPatch)
 	internal.HandleFunc("/internal/v1/warehouses/transfer", rh.TransferStock).Methods(http.MethodPost)
+	internal.HandleFunc("/internal/v1/warehouses/reconcile", rh.ReconcileReserved).Methods(http.MethodPost)
+	internal.HandleFunc("/internal/v1/warehouses/{id}/stock", rh.AdjustStock).Methods(http.MethodPost)
+	internal.HandleFunc("/internal/v1/reservations/sweep", rh.SweepReservations).Methods(http.MethodPost)
+	internal.HandleFunc("/internal/v1/consumer/status", rh.GetConsumerStatus).Methods(http.MethodGet)
+
+	// Support views
+	internal.HandleFunc("/internal/v1/users/{id}/orders", rh.AdminListUserOrders).Methods(http.MethodGet)
 
-	internal.Use(InternalMiddleware(internalAPIKey))
+	// Effective (non-secret) configuration
+	internal.HandleFunc("/internal/v1/config", rh.GetEffectiveConfig).Methods(http.MethodGet)
+
+	internal.Use(InternalMiddleware(cfg.InternalAPIKey))
 	router.PathPrefix("/internal/").Handler(internal)
 
-	return router
+	return CORSMiddleware(cfg.Server.CORS)(router)
 }
 
 // Register handler
 // @Summary Register user
-// @Description Register a new user
+// @Description Register a new user. The email starts unverified, the returned verification_token confirms it through /public/v1/verify
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -89,8 +178,8 @@ func (s *RestHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := validatorx.ValidateStruct(&req); err != nil {
-		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
 		return
 	}
 
@@ -108,6 +197,71 @@ func (s *RestHandler) Register(w http.ResponseWriter, r *http.Request) {
 	writeSuccess(w, res)
 }
 
+// @Summary Verify email
+// @Description Confirm the email of a registered user with the token issued on register. A token works once, users who haven't verified can't place orders
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body model.VerifyEmailRequest true "Verify Email Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Router /public/v1/verify [post]
+func (s *RestHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
+		return
+	}
+
+	if s.UserApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+
+	if err := s.UserApp.VerifyEmail(ctx, req.Token); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "verified"})
+}
+
+// @Summary Resend verification token
+// @Description Issue a fresh verification token to the logged in user, for when the one from register expired or was lost. Fails once the email is verified
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.ResendVerificationResponse
+// @Failure 400 {object} errors.CustomError
+// @Failure 401 {object} errors.CustomError
+// @Router /public/v1/verify/resend [post]
+func (s *RestHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if s.UserApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	res, err := s.UserApp.ResendVerification(ctx, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
 // Login handler
 // @Summary Login user
 // @Description Login with email or phone and receive JWT token
@@ -127,8 +281,8 @@ func (s *RestHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := validatorx.ValidateStruct(&req); err != nil {
-		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
 		return
 	}
 
@@ -147,13 +301,15 @@ func (s *RestHandler) Login(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary List products
-// @Description Get paginated list of products with shop and available stock
+// @Description Get paginated list of products with shop and available stock. Authentication is optional
 // @Tags Product
 // @Accept json
 // @Produce json
 // @Param page query int false "Page number" default(1)
-// @Param per_page query int false "Items per page" default(10)
+// @Param per_page query int false "Items per page, capped at the server maximum (per_page of the response)" default(10)
+// @Param sort query string false "Sort order" Enums(price_asc, price_desc, name_asc, newest)
 // @Success 200 {object} model.ProductListResponse
+// @Header 200 {string} Link "next, prev and last pages (RFC 5988)"
 // @Failure 400 {object} errors.CustomError
 // @Security BearerAuth
 // @Router /public/v1/product [get]
@@ -174,40 +330,48 @@ func (s *RestHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	res, err := s.ProductApp.ListProducts(ctx, page, perPage)
+	sort := constant.ProductSort(qs.Get("sort"))
+
+	res, err := s.ProductApp.ListProducts(ctx, page, perPage, sort)
 	if err != nil {
 		writeError(w, err)
 		return
 	}
+	setPaginationLinks(w, r, res.Page, res.PerPage, res.TotalCount)
 	writeSuccess(w, res)
 }
 
-// @Summary Get product detail
-// @Description Get product detail by id
+// @Summary Product feed
+// @Description Lightweight product projection for infinite scroll, paged by cursor. Availability is a boolean instead of the exact stock. Authentication is optional
 // @Tags Product
-// @Accept json
 // @Produce json
-// @Param id path int true "Product ID"
-// @Success 200 {object} model.ProductDetail
+// @Param cursor query int false "next_cursor of the previous page, empty for the first page"
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} model.ProductFeedResponse
 // @Failure 400 {object} errors.CustomError
 // @Security BearerAuth
-// @Router /public/v1/product/{id} [get]
-func (s *RestHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
+// @Router /public/v1/product/feed [get]
+func (s *RestHandler) GetProductFeed(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-	if idStr == "" {
-		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
-		return
+	qs := r.URL.Query()
+	var cursor uint64
+	if v := qs.Get("cursor"); v != "" {
+		c, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+			return
+		}
+		cursor = c
 	}
-	id, err := strconv.ParseUint(idStr, 10, 64)
-	if err != nil {
-		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
-		return
+	limit := 0
+	if v := qs.Get("limit"); v != "" {
+		if l, err := strconv.Atoi(v); err == nil && l > 0 {
+			limit = l
+		}
 	}
 
-	res, err := s.ProductApp.GetProduct(ctx, id)
+	res, err := s.ProductApp.ListFeed(ctx, cursor, limit)
 	if err != nil {
 		writeError(w, err)
 		return
@@ -215,61 +379,62 @@ func (s *RestHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	writeSuccess(w, res)
 }
 
-// @Summary Create order
-// @Description Create a new order and reserve stock
-// @Tags Order
-// @Accept json
+// @Summary Product catalog sync
+// @Description Products created, updated or deleted at or after since, oldest change first. Deleted products are flagged and must be removed from the mirror. Follow next_cursor while has_more is true. Authentication is optional
+// @Tags Product
 // @Produce json
-// @Param request body model.OrderRequest true "Order Request"
-// @Success 200 {object} model.OrderResponse
+// @Param since query string true "RFC3339 timestamp, e.g. 2025-11-30T00:00:00Z"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param limit query int false "Items per page" default(100)
+// @Success 200 {object} model.ProductSyncResponse
 // @Failure 400 {object} errors.CustomError
 // @Security BearerAuth
-// @Router /public/v1/order [post]
-func (s *RestHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+// @Router /public/v1/product/sync [get]
+func (s *RestHandler) SyncProducts(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	var req model.OrderRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
-		return
-	}
-
-	if err := validatorx.ValidateStruct(&req); err != nil {
+	qs := r.URL.Query()
+	cursor := qs.Get("cursor")
+	var since time.Time
+	if v := qs.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+			return
+		}
+		since = t
+	} else if cursor == "" {
 		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
 		return
 	}
-
-	userID, ok := utilsContext.GetUserID(ctx)
-	if !ok || userID == 0 {
-		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
-		return
+	limit := 0
+	if v := qs.Get("limit"); v != "" {
+		if l, err := strconv.Atoi(v); err == nil && l > 0 {
+			limit = l
+		}
 	}
 
-	res, err := s.OrderApp.CreateOrder(ctx, userID, &req)
+	res, err := s.ProductApp.SyncProducts(ctx, since, cursor, limit)
 	if err != nil {
 		writeError(w, err)
 		return
 	}
-
 	writeSuccess(w, res)
 }
 
-// @Summary Pay order
-// @Description Mark order as paid and adjust stock
-// @Tags Order
+// @Summary Get product detail
+// @Description Get product detail by id. Authentication is optional
+// @Tags Product
 // @Accept json
 // @Produce json
-// @Param id path int true "Order ID"
-// @Success 200 {object} map[string]string
+// @Param id path int true "Product ID"
+// @Success 200 {object} model.ProductDetail
 // @Failure 400 {object} errors.CustomError
 // @Security BearerAuth
-// @Router /public/v1/order/{id}/pay [post]
-func (s *RestHandler) PayOrder(w http.ResponseWriter, r *http.Request) {
+// @Router /public/v1/product/{id} [get]
+func (s *RestHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	if s.OrderApp == nil {
-		writeError(w, errors.SetCustomError(constant.ErrInternal))
-		return
-	}
+
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 	if idStr == "" {
@@ -281,126 +446,121 @@ func (s *RestHandler) PayOrder(w http.ResponseWriter, r *http.Request) {
 		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
 		return
 	}
-	userID, ok := utilsContext.GetUserID(ctx)
-	if !ok || userID == 0 {
-		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
-		return
-	}
 
-	if err := s.OrderApp.PayOrder(ctx, id); err != nil {
+	res, err := s.ProductApp.GetProduct(ctx, id)
+	if err != nil {
 		writeError(w, err)
 		return
 	}
-	writeSuccess(w, map[string]string{"status": "paid"})
+	writeSuccess(w, res)
 }
 
-// @Summary Cancel order
-// @Description Cancel order and release reservations
-// @Tags Order
+// @Summary Create product
+// @Description Add a product to a shop. The price is rounded to the store currency and must stay above 0. An unknown shop is not found
+// @Tags Product
 // @Accept json
 // @Produce json
-// @Param id path int true "Order ID"
-// @Success 200 {object} map[string]string
+// @Param request body model.ProductRequest true "Product Request"
+// @Success 200 {object} model.ProductDetail
 // @Failure 400 {object} errors.CustomError
-// @Security BearerAuth
-// @Router /public/v1/order/{id}/cancel [post]
-func (s *RestHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+// @Security InternalAPIKey
+// @Router /internal/v1/product [post]
+func (s *RestHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	if s.OrderApp == nil {
-		writeError(w, errors.SetCustomError(constant.ErrInternal))
-		return
-	}
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-	if idStr == "" {
-		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
-		return
-	}
-	id, err := strconv.ParseUint(idStr, 10, 64)
-	if err != nil {
+	var req model.ProductRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
 		return
 	}
-	userID, ok := utilsContext.GetUserID(ctx)
-	if !ok || userID == 0 {
-		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
 		return
 	}
 
-	if err := s.OrderApp.CancelOrder(ctx, id); err != nil {
+	res, err := s.ProductApp.CreateProduct(ctx, &req)
+	if err != nil {
 		writeError(w, err)
 		return
 	}
-	writeSuccess(w, map[string]string{"status": "cancelled"})
+	writeSuccess(w, res)
 }
 
-// InternalCancelOrder handles MQ-triggered cancel with API key only
-func (s *RestHandler) InternalCancelOrder(w http.ResponseWriter, r *http.Request) {
+// @Summary Update product
+// @Description Change the fields given, at least one. A missing or deleted product, or an unknown new shop, is not found
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param request body model.ProductUpdateRequest true "Product Update Request"
+// @Success 200 {object} model.ProductDetail
+// @Failure 400 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/product/{id} [patch]
+func (s *RestHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	vars := mux.Vars(r)
-	idStr := vars["id"]
-	if idStr == "" {
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
 		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
 		return
 	}
-	id, err := strconv.ParseUint(idStr, 10, 64)
-	if err != nil {
+
+	var req model.ProductUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
 		return
 	}
-	if err := s.OrderApp.CancelOrder(ctx, id); err != nil {
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
+		return
+	}
+
+	res, err := s.ProductApp.UpdateProduct(ctx, id, &req)
+	if err != nil {
 		writeError(w, err)
 		return
 	}
-	writeSuccess(w, map[string]string{"status": "cancelled"})
+	writeSuccess(w, res)
 }
 
-// @Summary Activate warehouse
-// @Description Activate a warehouse
-// @Tags Warehouse
+// @Summary Delete product
+// @Description Soft-delete a discontinued product. It disappears from listings and can no longer be ordered, orders already placed keep their items
+// @Tags Product
 // @Accept json
 // @Produce json
-// @Param id path int true "Warehouse ID"
+// @Param id path int true "Product ID"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} errors.CustomError
 // @Security InternalAPIKey
-// @Router /internal/v1/warehouses/{id}/activate [patch]
-func (s *RestHandler) ActivateWarehouse(w http.ResponseWriter, r *http.Request) {
+// @Router /internal/v1/product/{id} [delete]
+func (s *RestHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	vars := mux.Vars(r)
-	idStr := vars["id"]
-	if idStr == "" {
-		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
-		return
-	}
-	id, err := strconv.ParseUint(idStr, 10, 64)
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
 	if err != nil {
 		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
 		return
 	}
-	if s.WarehouseApp == nil {
-		writeError(w, errors.SetCustomError(constant.ErrInternal))
-		return
-	}
-	if err := s.WarehouseApp.ActivateWarehouse(ctx, id); err != nil {
+	if err := s.ProductApp.DeleteProduct(ctx, id); err != nil {
 		writeError(w, err)
 		return
 	}
-	writeSuccess(w, map[string]string{"status": "activated"})
+	writeSuccess(w, map[string]string{"status": "deleted"})
 }
 
-// @Summary Deactivate warehouse
-// @Description Deactivate a warehouse. Cannot deactivate if there's reserved stock
-// @Tags Warehouse
+// @Summary Get product available stock
+// @Description Get live available stock (stock - reserved) of a product across active warehouses. Authentication is optional
+// @Tags Product
 // @Accept json
 // @Produce json
-// @Param id path int true "Warehouse ID"
-// @Success 200 {object} map[string]string
+// @Param id path int true "Product ID"
+// @Success 200 {object} model.ProductStockResponse
 // @Failure 400 {object} errors.CustomError
-// @Security InternalAPIKey
-// @Router /internal/v1/warehouses/{id}/deactivate [patch]
-func (s *RestHandler) DeactivateWarehouse(w http.ResponseWriter, r *http.Request) {
+// @Security BearerAuth
+// @Router /public/v1/product/{id}/stock [get]
+func (s *RestHandler) GetProductStock(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 	if idStr == "" {
@@ -416,47 +576,1335 @@ func (s *RestHandler) DeactivateWarehouse(w http.ResponseWriter, r *http.Request
 		writeError(w, errors.SetCustomError(constant.ErrInternal))
 		return
 	}
-	if err := s.WarehouseApp.DeactivateWarehouse(ctx, id); err != nil {
+
+	total, err := s.WarehouseApp.GetAvailableStock(ctx, id)
+	if err != nil {
 		writeError(w, err)
 		return
 	}
-	writeSuccess(w, map[string]string{"status": "deactivated"})
+	writeSuccess(w, model.ProductStockResponse{
+		ProductID:      model.ID(id),
+		AvailableStock: total,
+	})
 }
 
-// @Summary Transfer stock between warehouses
-// @Description Transfer stock from one warehouse to another. Only available stock (stock - reserved) can be transferred
-// @Tags Warehouse
+// @Summary List product reviews
+// @Description Get paginated reviews of a product, newest first. Authentication is optional
+// @Tags Product
 // @Accept json
 // @Produce json
-// @Param request body model.TransferStockHTTPRequest true "Transfer Stock Request"
-// @Success 200 {object} map[string]string
+// @Param id path int true "Product ID"
+// @Param page query int false "Page number" default(1)
+// @Param per_page query int false "Items per page, capped at the server maximum (per_page of the response)" default(10)
+// @Success 200 {object} model.ReviewListResponse
+// @Header 200 {string} Link "next, prev and last pages (RFC 5988)"
 // @Failure 400 {object} errors.CustomError
-// @Security InternalAPIKey
-// @Router /internal/v1/warehouses/transfer [post]
-func (s *RestHandler) TransferStock(w http.ResponseWriter, r *http.Request) {
+// @Security BearerAuth
+// @Router /public/v1/product/{id}/reviews [get]
+func (s *RestHandler) ListProductReviews(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	var req model.TransferStockHTTPRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
-		return
-	}
-	if err := validatorx.ValidateStruct(&req); err != nil {
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
 		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
 		return
 	}
-	if s.WarehouseApp == nil {
-		writeError(w, errors.SetCustomError(constant.ErrInternal))
-		return
+
+	qs := r.URL.Query()
+	page := 1
+	perPage := 10
+	if v := qs.Get("page"); v != "" {
+		if p, err := strconv.Atoi(v); err == nil && p > 0 {
+			page = p
+		}
 	}
-	transferReq := &model.TransferStockRequest{
-		ProductID:       req.ProductID,
-		FromWarehouseID: req.FromWarehouseID,
-		ToWarehouseID:   req.ToWarehouseID,
-		Quantity:        req.Quantity,
+	if v := qs.Get("per_page"); v != "" {
+		if p, err := strconv.Atoi(v); err == nil && p > 0 {
+			perPage = p
+		}
 	}
-	if err := s.WarehouseApp.TransferStock(ctx, transferReq); err != nil {
+
+	res, err := s.ProductApp.ListReviews(ctx, id, page, perPage)
+	if err != nil {
 		writeError(w, err)
 		return
 	}
-	writeSuccess(w, map[string]string{"status": "transferred"})
+	setPaginationLinks(w, r, res.Page, res.PerPage, res.TotalCount)
+	writeSuccess(w, res)
+}
+
+// @Summary Create product review
+// @Description Review a product. Only users with a completed order of the product can review it, once per product
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param request body model.ReviewRequest true "Review Request"
+// @Success 200 {object} model.ProductReview
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/product/{id}/reviews [post]
+func (s *RestHandler) CreateProductReview(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+
+	var req model.ReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	res, err := s.ProductApp.CreateReview(ctx, userID, id, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary Subscribe to back in stock
+// @Description Get notified once an out-of-stock product can be ordered again. The subscription ends with the notification. Subscribing to a product that is in stock is rejected
+// @Tags Product
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/product/{id}/stock-subscription [post]
+func (s *RestHandler) SubscribeBackInStock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	if err := s.ProductApp.SubscribeBackInStock(ctx, userID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "subscribed"})
+}
+
+// @Summary Create order
+// @Description Create a new order and reserve stock. Set from_cart to order the items saved in the user's cart instead of sending items; the cart is cleared once the order is created. Each product may appear only once in items, a repeated product_id is rejected with 400.
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Param request body model.OrderRequest true "Order Request"
+// @Success 200 {object} model.OrderResponse
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/order [post]
+func (s *RestHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// bound what the decoder allocates, the item limits of the order app only
+	// run once the whole request is in memory
+	var maxBody int64
+	var maxItems int
+	if s.Config != nil {
+		maxBody, maxItems = s.Config.Order.MaxBodyBytes, s.Config.Order.MaxDecodedItems
+	}
+	if maxBody > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	}
+
+	var req model.OrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if maxItems > 0 && len(req.Items) > maxItems {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	res, err := s.OrderApp.CreateOrder(ctx, userID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeSuccess(w, res)
+}
+
+// @Summary Preview order
+// @Description Quote the subtotal, tax and grand total an order for the items would be charged, priced like Create order. Nothing is reserved and stock is not checked.
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Param request body model.OrderPreviewRequest true "Order Preview Request"
+// @Success 200 {object} model.OrderPreviewResponse
+// @Failure 400 {object} errors.CustomError
+// @Failure 404 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/order/preview [post]
+func (s *RestHandler) PreviewOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var maxBody int64
+	var maxItems int
+	if s.Config != nil {
+		maxBody, maxItems = s.Config.Order.MaxBodyBytes, s.Config.Order.MaxDecodedItems
+	}
+	if maxBody > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	}
+
+	var req model.OrderPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if maxItems > 0 && len(req.Items) > maxItems {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	res, err := s.OrderApp.PreviewOrder(ctx, userID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeSuccess(w, res)
+}
+
+// @Summary Pay order
+// @Description Mark order as paid and adjust stock
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} model.OrderActionResponse
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/order/{id}/pay [post]
+func (s *RestHandler) PayOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if s.OrderApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	if idStr == "" {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	detail, err := s.OrderApp.PayOrder(ctx, userID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, orderActionResponse(detail, "paid"))
+}
+
+// orderActionResponse echoes the items of an order a pay or cancel acted on
+func orderActionResponse(detail *model.OrderDetail, status string) model.OrderActionResponse {
+	return model.OrderActionResponse{
+		OrderID:        model.ID(detail.ID),
+		Status:         status,
+		Items:          detail.Items,
+		AlreadyApplied: detail.AlreadyApplied,
+	}
+}
+
+// @Summary Refund order
+// @Description Refund a paid order and return its stock to the warehouses it was fulfilled from
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Failure 404 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/order/{id}/refund [post]
+func (s *RestHandler) RefundOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if s.OrderApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	if err := s.OrderApp.RefundOrder(ctx, userID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "refunded"})
+}
+
+// @Summary Cancel order
+// @Description Cancel order and release reservations
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} model.OrderActionResponse
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/order/{id}/cancel [post]
+func (s *RestHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if s.OrderApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	if idStr == "" {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	detail, err := s.OrderApp.CancelOrder(ctx, userID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, orderActionResponse(detail, "cancelled"))
+}
+
+// @Summary List my orders
+// @Description List the current user's orders newest first, optionally filtered by status
+// @Tags Order
+// @Produce json
+// @Param status query int false "Order status"
+// @Param page query int false "Page"
+// @Param per_page query int false "Items per page, capped at the server maximum (per_page of the response)"
+// @Success 200 {object} model.OrderListResponse
+// @Header 200 {string} Link "next, prev and last pages (RFC 5988)"
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/order [get]
+func (s *RestHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if s.OrderApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	page, perPage, status, err := parseOrderListQuery(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	res, err := s.OrderApp.ListOrders(ctx, userID, status, page, perPage)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	setPaginationLinks(w, r, res.Page, res.PerPage, res.TotalCount)
+	writeSuccess(w, res)
+}
+
+// @Summary List a user's orders
+// @Description Support view of any customer's orders newest first, optionally filtered by status. Requires the internal API key
+// @Tags Order
+// @Produce json
+// @Param id path int true "User ID"
+// @Param status query int false "Order status"
+// @Param page query int false "Page"
+// @Param per_page query int false "Items per page, capped at the server maximum (per_page of the response)"
+// @Success 200 {object} model.OrderListResponse
+// @Header 200 {string} Link "next, prev and last pages (RFC 5988)"
+// @Failure 400 {object} errors.CustomError
+// @Failure 403 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/users/{id}/orders [get]
+func (s *RestHandler) AdminListUserOrders(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	userID, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+
+	page, perPage, status, err := parseOrderListQuery(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// admin scoped, the user id comes from the path instead of the session
+	res, err := s.OrderApp.ListOrders(ctx, userID, status, page, perPage)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	setPaginationLinks(w, r, res.Page, res.PerPage, res.TotalCount)
+	writeSuccess(w, res)
+}
+
+// parseOrderListQuery reads page, per_page and status of the order list endpoints,
+// invalid paging falls back to the defaults while an invalid status is rejected
+func parseOrderListQuery(r *http.Request) (int, int, *constant.OrderStatus, error) {
+	qs := r.URL.Query()
+	page := 1
+	perPage := 10
+	if v := qs.Get("page"); v != "" {
+		if p, err := strconv.Atoi(v); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if v := qs.Get("per_page"); v != "" {
+		if p, err := strconv.Atoi(v); err == nil && p > 0 {
+			perPage = p
+		}
+	}
+	var status *constant.OrderStatus
+	if v := qs.Get("status"); v != "" {
+		st, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, 0, nil, errors.SetCustomError(constant.ErrInvalidRequest)
+		}
+		orderStatus := constant.OrderStatus(st)
+		status = &orderStatus
+	}
+	return page, perPage, status, nil
+}
+
+// InternalCancelOrder handles MQ-triggered cancel with API key only
+func (s *RestHandler) InternalCancelOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	if idStr == "" {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	detail, err := s.OrderApp.InternalCancelOrder(ctx, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, orderActionResponse(detail, "cancelled"))
+}
+
+// @Summary Approve order
+// @Description Release an order held for review (above the max order value) to pending. It keeps its reservations and gets a full expiration window from now
+// @Tags Order
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} model.OrderActionResponse
+// @Failure 400 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/order/{id}/approve [post]
+func (s *RestHandler) ApproveOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	detail, err := s.OrderApp.ApproveOrder(ctx, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, orderActionResponse(detail, "approved"))
+}
+
+// @Summary Reject order
+// @Description Cancel an order held for review and release its reservations
+// @Tags Order
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} model.OrderActionResponse
+// @Failure 400 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/order/{id}/reject [post]
+func (s *RestHandler) RejectOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	detail, err := s.OrderApp.RejectOrder(ctx, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, orderActionResponse(detail, "rejected"))
+}
+
+// @Summary Get warehouse
+// @Description Get a warehouse and its current status
+// @Tags Warehouse
+// @Accept json
+// @Produce json
+// @Param id path int true "Warehouse ID"
+// @Success 200 {object} model.WarehouseEntity
+// @Failure 400 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/warehouses/{id} [get]
+func (s *RestHandler) GetWarehouse(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	if idStr == "" {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if s.WarehouseApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	res, err := s.WarehouseApp.GetWarehouse(ctx, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary Activate warehouse
+// @Description Activate a warehouse
+// @Tags Warehouse
+// @Accept json
+// @Produce json
+// @Param id path int true "Warehouse ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/warehouses/{id}/activate [patch]
+func (s *RestHandler) ActivateWarehouse(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	if idStr == "" {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if s.WarehouseApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	if err := s.WarehouseApp.ActivateWarehouse(ctx, id); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "activated"})
+}
+
+// @Summary Deactivate warehouse
+// @Description Deactivate a warehouse. Cannot deactivate if there's reserved stock, the rejection's details then list the reserved quantity and the orders and carts holding it. With transfer_to, all stock is first moved to that warehouse in the same transaction
+// @Tags Warehouse
+// @Accept json
+// @Produce json
+// @Param id path int true "Warehouse ID"
+// @Param transfer_to query int false "Active warehouse to move the stock to before deactivating"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/warehouses/{id}/deactivate [patch]
+func (s *RestHandler) DeactivateWarehouse(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	if idStr == "" {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	var transferTo uint64
+	if v := r.URL.Query().Get("transfer_to"); v != "" {
+		transferTo, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+			return
+		}
+	}
+	if s.WarehouseApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	if err := s.WarehouseApp.DeactivateWarehouse(ctx, id, transferTo); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "deactivated"})
+}
+
+// @Summary Transfer stock between warehouses
+// @Description Transfer stock from one warehouse to another, both warehouses must be active. Only available stock (stock - reserved) can be transferred, unless move_reservations is set: the product's reservations then move to the destination with their stock, and quantity must be at least the reserved amount
+// @Tags Warehouse
+// @Accept json
+// @Produce json
+// @Param request body model.TransferStockHTTPRequest true "Transfer Stock Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/warehouses/transfer [post]
+func (s *RestHandler) TransferStock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req model.TransferStockHTTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
+		return
+	}
+	if s.WarehouseApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	transferReq := &model.TransferStockRequest{
+		ProductID:        uint64(req.ProductID),
+		FromWarehouseID:  uint64(req.FromWarehouseID),
+		ToWarehouseID:    uint64(req.ToWarehouseID),
+		Quantity:         req.Quantity,
+		MoveReservations: req.MoveReservations,
+	}
+	if err := s.WarehouseApp.TransferStock(ctx, transferReq); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "transferred"})
+}
+
+// @Summary Reconcile reserved stock
+// @Description Recompute each warehouse_stock reserved count from its reservation rows and correct the ones that drifted. Runs for every warehouse when warehouse_id is empty or zero, and returns the rows corrected
+// @Tags Warehouse
+// @Accept json
+// @Produce json
+// @Param warehouse_id query int false "Warehouse ID"
+// @Success 200 {array} model.ReservedDiscrepancy
+// @Failure 400 {object} errors.CustomError
+// @Failure 404 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/warehouses/reconcile [post]
+func (s *RestHandler) ReconcileReserved(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var warehouseID uint64
+	if v := r.URL.Query().Get("warehouse_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+			return
+		}
+		warehouseID = id
+	}
+	if s.WarehouseApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	res, err := s.WarehouseApp.ReconcileReserved(ctx, warehouseID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary List warehouses
+// @Description List warehouses with their total stock and reserved quantity. Returns all warehouses when shop_id is empty or zero
+// @Tags Warehouse
+// @Accept json
+// @Produce json
+// @Param shop_id query int false "Shop ID"
+// @Success 200 {array} model.WarehouseSummary
+// @Failure 400 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/warehouses [get]
+func (s *RestHandler) ListWarehouses(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var shopID uint64
+	if v := r.URL.Query().Get("shop_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+			return
+		}
+		shopID = id
+	}
+	if s.WarehouseApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	res, err := s.WarehouseApp.ListWarehouses(ctx, shopID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary Low stock report
+// @Description List products whose available stock (stock - reserved) across active warehouses is at or below threshold, lowest first
+// @Tags Warehouse
+// @Accept json
+// @Produce json
+// @Param threshold query int true "Maximum available quantity to report"
+// @Success 200 {array} model.LowStockItem
+// @Failure 400 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/warehouses/low-stock [get]
+func (s *RestHandler) LowStockReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	threshold, err := strconv.ParseInt(r.URL.Query().Get("threshold"), 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if s.WarehouseApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	res, err := s.WarehouseApp.LowStockReport(ctx, threshold)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary Adjust warehouse stock
+// @Description Add (restock) or remove stock of a product in a warehouse. Stock cannot be reduced below the reserved quantity
+// @Tags Warehouse
+// @Accept json
+// @Produce json
+// @Param id path int true "Warehouse ID"
+// @Param request body model.StockAdjustmentHTTPRequest true "Stock Adjustment Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/warehouses/{id}/stock [post]
+func (s *RestHandler) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	if idStr == "" {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	var req model.StockAdjustmentHTTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
+		return
+	}
+	if s.WarehouseApp == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	adjustReq := &model.StockAdjustmentRequest{
+		WarehouseID: id,
+		ProductID:   uint64(req.ProductID),
+		Quantity:    req.Quantity,
+	}
+	if err := s.WarehouseApp.AdjustStock(ctx, adjustReq); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "adjusted"})
+}
+
+// @Summary List addresses
+// @Description List saved addresses of the authenticated user
+// @Tags Address
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.UserAddress
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/addresses [get]
+func (s *RestHandler) ListAddresses(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	res, err := s.UserApp.ListAddresses(ctx, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary Add address
+// @Description Save a new address for the authenticated user
+// @Tags Address
+// @Accept json
+// @Produce json
+// @Param request body model.ShippingAddress true "Address Request"
+// @Success 200 {object} model.UserAddress
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/addresses [post]
+func (s *RestHandler) AddAddress(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.ShippingAddress
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	res, err := s.UserApp.AddAddress(ctx, userID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary Update address
+// @Description Update a saved address of the authenticated user
+// @Tags Address
+// @Accept json
+// @Produce json
+// @Param id path int true "Address ID"
+// @Param request body model.ShippingAddress true "Address Request"
+// @Success 200 {object} model.UserAddress
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/addresses/{id} [put]
+func (s *RestHandler) UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+
+	var req model.ShippingAddress
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	res, err := s.UserApp.UpdateAddress(ctx, userID, id, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary Delete address
+// @Description Delete a saved address of the authenticated user
+// @Tags Address
+// @Accept json
+// @Produce json
+// @Param id path int true "Address ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/addresses/{id} [delete]
+func (s *RestHandler) DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	if err := s.UserApp.DeleteAddress(ctx, userID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "deleted"})
+}
+
+// @Summary List wishlist
+// @Description List the authenticated user's wishlist with current price and available stock
+// @Tags Wishlist
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.WishlistItem
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/wishlist [get]
+func (s *RestHandler) ListWishlist(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	res, err := s.WishlistApp.ListWishlist(ctx, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary Add to wishlist
+// @Description Save a product to the authenticated user's wishlist. Adding a saved product again is a no-op
+// @Tags Wishlist
+// @Accept json
+// @Produce json
+// @Param request body model.WishlistRequest true "Wishlist Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/wishlist [post]
+func (s *RestHandler) AddToWishlist(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.WishlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	if err := s.WishlistApp.AddToWishlist(ctx, userID, uint64(req.ProductID)); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "added"})
+}
+
+// @Summary Remove from wishlist
+// @Description Remove a product from the authenticated user's wishlist
+// @Tags Wishlist
+// @Accept json
+// @Produce json
+// @Param product_id path int true "Product ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/wishlist/{product_id} [delete]
+func (s *RestHandler) RemoveFromWishlist(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	productID, err := strconv.ParseUint(vars["product_id"], 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	if err := s.WishlistApp.RemoveFromWishlist(ctx, userID, productID); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "removed"})
+}
+
+// @Summary Get cart
+// @Description Get the authenticated user's saved cart, each item flags insufficient_stock when availability dropped below its quantity
+// @Tags Cart
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.CartResponse
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/cart [get]
+func (s *RestHandler) GetCart(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	res, err := s.CartApp.GetCart(ctx, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary Add cart item
+// @Description Add a product to the cart, increasing the quantity when it is already there. Stock is only reserved when reserve-on-add is enabled.
+// @Tags Cart
+// @Accept json
+// @Produce json
+// @Param request body model.CartItemRequest true "Cart Item Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/cart/items [post]
+func (s *RestHandler) AddCartItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.CartItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	if err := s.CartApp.AddItem(ctx, userID, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "added"})
+}
+
+// @Summary Update cart item
+// @Description Set the quantity of a product in the cart
+// @Tags Cart
+// @Accept json
+// @Produce json
+// @Param product_id path int true "Product ID"
+// @Param request body model.CartItemUpdateRequest true "Cart Item Update Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/cart/items/{product_id} [put]
+func (s *RestHandler) UpdateCartItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	productID, err := strconv.ParseUint(vars["product_id"], 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+
+	var req model.CartItemUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+	if fieldErrs := validatorx.ValidateStructDetailed(&req); fieldErrs != nil {
+		writeError(w, errors.WithDetails(constant.ErrInvalidRequest, fieldErrs))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	if err := s.CartApp.UpdateItem(ctx, userID, productID, req.Quantity); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "updated"})
+}
+
+// @Summary Remove cart item
+// @Description Remove a product from the cart
+// @Tags Cart
+// @Accept json
+// @Produce json
+// @Param product_id path int true "Product ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/cart/items/{product_id} [delete]
+func (s *RestHandler) RemoveCartItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	productID, err := strconv.ParseUint(vars["product_id"], 10, 64)
+	if err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	if err := s.CartApp.RemoveItem(ctx, userID, productID); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "removed"})
+}
+
+// @Summary List sessions
+// @Description List the authenticated user's active sessions, soonest to expire first
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Success 200 {array} model.Session
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/me/sessions [get]
+func (s *RestHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	res, err := s.UserApp.ListSessions(ctx, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary Revoke all sessions
+// @Description Log out everywhere, every session of the authenticated user is revoked including the current one
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/me/sessions [delete]
+func (s *RestHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	if err := s.UserApp.RevokeAllSessions(ctx, userID); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, map[string]string{"status": "revoked"})
+}
+
+// @Summary Get notification preferences
+// @Description Get the authenticated user's notification preferences, defaults apply until they are changed
+// @Tags Notification
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.NotificationPrefs
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/notification-preferences [get]
+func (s *RestHandler) GetNotificationPrefs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	res, err := s.UserApp.GetNotificationPrefs(ctx, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary Update notification preferences
+// @Description Opt in or out of notification types, omitted fields keep their current value
+// @Tags Notification
+// @Accept json
+// @Produce json
+// @Param request body model.UpdateNotificationPrefsRequest true "Notification Preferences Request"
+// @Success 200 {object} model.NotificationPrefs
+// @Failure 400 {object} errors.CustomError
+// @Security BearerAuth
+// @Router /public/v1/notification-preferences [put]
+func (s *RestHandler) UpdateNotificationPrefs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.UpdateNotificationPrefsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.SetCustomError(constant.ErrInvalidRequest))
+		return
+	}
+
+	userID, ok := utilsContext.GetUserID(ctx)
+	if !ok || userID == 0 {
+		writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+		return
+	}
+
+	res, err := s.UserApp.UpdateNotificationPrefs(ctx, userID, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSuccess(w, res)
+}
+
+// @Summary Get effective configuration
+// @Description Return the non-secret settings the server is running with. Credentials are never included
+// @Tags System
+// @Produce json
+// @Success 200 {object} model.EffectiveConfig
+// @Failure 500 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/config [get]
+func (s *RestHandler) GetEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	if s.Config == nil {
+		writeError(w, errors.SetCustomError(constant.ErrInternal))
+		return
+	}
+	writeSuccess(w, s.Config.Effective())
 }
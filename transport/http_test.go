@@ -0,0 +1,530 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	apporder "github.com/muhammadheryan/e-commerce/application/order"
+	appproduct "github.com/muhammadheryan/e-commerce/application/product"
+	appwarehouse "github.com/muhammadheryan/e-commerce/application/warehouse"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	"github.com/muhammadheryan/e-commerce/constant"
+	ordermocks "github.com/muhammadheryan/e-commerce/mocks/repository/order"
+	productmocks "github.com/muhammadheryan/e-commerce/mocks/repository/product"
+	warehousemocks "github.com/muhammadheryan/e-commerce/mocks/repository/warehouse"
+	"github.com/muhammadheryan/e-commerce/model"
+	validatorx "github.com/muhammadheryan/e-commerce/utils/validator"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAdminListUserOrders(t *testing.T) {
+	cfg := &config.Config{InternalAPIKey: "internal-key"}
+	completed := constant.OrderStatusCompleted
+
+	tests := []struct {
+		name       string
+		url        string
+		auth       string
+		mock       func(orderRepo *ordermocks.OrderRepository)
+		wantStatus int
+	}{
+		{
+			name: "lists the target user's orders",
+			url:  "/internal/v1/users/42/orders?status=2&page=2&per_page=5",
+			auth: "Bearer internal-key",
+			mock: func(orderRepo *ordermocks.OrderRepository) {
+				orderRepo.On("ListOrdersByUser", mock.Anything, model.OrderListFilter{UserID: 42, Status: &completed}, 2, 5).
+					Return([]model.OrderSummary{{ID: 7}}, int64(1), nil).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid user id",
+			url:        "/internal/v1/users/abc/orders",
+			auth:       "Bearer internal-key",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing internal key",
+			url:        "/internal/v1/users/42/orders",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "user token instead of internal key",
+			url:        "/internal/v1/users/42/orders",
+			auth:       "Bearer some-user-jwt",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderRepo := ordermocks.NewOrderRepository(t)
+			if tt.mock != nil {
+				tt.mock(orderRepo)
+			}
+			orderApp := apporder.NewOrderApp(cfg, nil, orderRepo, nil, nil, nil, nil)
+			h := NewTransport(nil, nil, orderApp, nil, nil, nil, cfg, nil, Sweeps{}, nil)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			decodeEnvelope(t, rec)
+		})
+	}
+}
+
+func TestLowStockReport(t *testing.T) {
+	cfg := &config.Config{InternalAPIKey: "internal-key"}
+
+	tests := []struct {
+		name       string
+		url        string
+		mock       func(warehouseRepo *warehousemocks.WarehouseRepository)
+		wantStatus int
+	}{
+		{
+			name: "reports low stock, not routed as a warehouse id",
+			url:  "/internal/v1/warehouses/low-stock?threshold=5",
+			mock: func(warehouseRepo *warehousemocks.WarehouseRepository) {
+				warehouseRepo.On("ListLowStockProducts", mock.Anything, int64(5)).
+					Return([]model.LowStockItem{{ProductID: 3, Name: "Mouse", Available: 4}}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing threshold",
+			url:        "/internal/v1/warehouses/low-stock",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "negative threshold",
+			url:        "/internal/v1/warehouses/low-stock?threshold=-1",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+			if tt.mock != nil {
+				tt.mock(warehouseRepo)
+			}
+			warehouseApp := appwarehouse.NewWarehouseApp(nil, warehouseRepo, nil, nil, nil)
+			h := NewTransport(nil, nil, nil, warehouseApp, nil, nil, cfg, nil, Sweeps{}, nil)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Header.Set("Authorization", "Bearer internal-key")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			decodeEnvelope(t, rec)
+		})
+	}
+}
+
+func TestGetProductStock(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		mock       func(warehouseRepo *warehousemocks.WarehouseRepository)
+		wantStatus int
+		want       model.ProductStockResponse
+	}{
+		{
+			name: "available stock",
+			url:  "/public/v1/product/4/stock",
+			mock: func(warehouseRepo *warehousemocks.WarehouseRepository) {
+				warehouseRepo.On("GetTotalAvailableStock", mock.Anything, uint64(4)).Return(int64(7), nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			want:       model.ProductStockResponse{ProductID: 4, AvailableStock: 7},
+		},
+		{
+			name: "no warehouse rows is zero, not an error",
+			url:  "/public/v1/product/5/stock",
+			mock: func(warehouseRepo *warehousemocks.WarehouseRepository) {
+				warehouseRepo.On("GetTotalAvailableStock", mock.Anything, uint64(5)).Return(int64(0), nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			want:       model.ProductStockResponse{ProductID: 5, AvailableStock: 0},
+		},
+		{
+			name:       "invalid product id",
+			url:        "/public/v1/product/abc/stock",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+			if tt.mock != nil {
+				tt.mock(warehouseRepo)
+			}
+			warehouseApp := appwarehouse.NewWarehouseApp(nil, warehouseRepo, nil, nil, nil)
+			h := NewTransport(nil, nil, nil, warehouseApp, nil, nil, &config.Config{}, nil, Sweeps{}, nil)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			env := decodeEnvelope(t, rec)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			// decoded into a map so a missing available_stock isn't read as zero
+			var got map[string]int64
+			if err := json.Unmarshal(env["data"], &got); err != nil {
+				t.Fatalf("decode data %s: %v", env["data"], err)
+			}
+			want := map[string]int64{"product_id": int64(tt.want.ProductID), "available_stock": tt.want.AvailableStock}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("data = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestGetWarehouse(t *testing.T) {
+	cfg := &config.Config{InternalAPIKey: "internal-key"}
+
+	tests := []struct {
+		name       string
+		url        string
+		mock       func(warehouseRepo *warehousemocks.WarehouseRepository)
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name: "found",
+			url:  "/internal/v1/warehouses/3",
+			mock: func(warehouseRepo *warehousemocks.WarehouseRepository) {
+				warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(3)).
+					Return(&model.WarehouseEntity{ID: 3, ShopID: 1, Name: "Jakarta", Status: constant.WarehouseStatusInactive}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "unknown warehouse",
+			url:  "/internal/v1/warehouses/9",
+			mock: func(warehouseRepo *warehousemocks.WarehouseRepository) {
+				warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(9)).Return(nil, nil).Once()
+			},
+			wantStatus: constant.ErrorTypeHTTPCode[constant.ErrNotFound],
+			wantCode:   constant.ErrorTypeCode[constant.ErrNotFound],
+		},
+		{
+			name: "lookup fails",
+			url:  "/internal/v1/warehouses/3",
+			mock: func(warehouseRepo *warehousemocks.WarehouseRepository) {
+				warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(3)).Return(nil, fmt.Errorf("db error")).Once()
+			},
+			wantStatus: constant.ErrorTypeHTTPCode[constant.ErrInternal],
+			wantCode:   constant.ErrorTypeCode[constant.ErrInternal],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+			if tt.mock != nil {
+				tt.mock(warehouseRepo)
+			}
+			warehouseApp := appwarehouse.NewWarehouseApp(nil, warehouseRepo, nil, nil, nil)
+			h := NewTransport(nil, nil, nil, warehouseApp, nil, nil, cfg, nil, Sweeps{}, nil)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Header.Set("Authorization", "Bearer internal-key")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			env := decodeEnvelope(t, rec)
+			if tt.wantCode != "" {
+				if string(env["code"]) != `"`+tt.wantCode+`"` {
+					t.Fatalf("code = %s, want %q", env["code"], tt.wantCode)
+				}
+				return
+			}
+			var got model.WarehouseEntity
+			if err := json.Unmarshal(env["data"], &got); err != nil {
+				t.Fatalf("decode data %s: %v", env["data"], err)
+			}
+			if got.ID != 3 || got.Name != "Jakarta" || got.Status != constant.WarehouseStatusInactive {
+				t.Fatalf("warehouse = %+v", got)
+			}
+		})
+	}
+}
+
+func TestDeactivateWarehouse_ReservedStockDetails(t *testing.T) {
+	cfg := &config.Config{InternalAPIKey: "internal-key"}
+	warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+	warehouseRepo.On("GetWarehouseByID", mock.Anything, uint64(3)).Return(&model.WarehouseEntity{ID: 3}, nil).Once()
+	warehouseRepo.On("CheckReservedStock", mock.Anything, uint64(3)).Return(int64(7), nil).Once()
+	warehouseRepo.On("ListReservationHolders", mock.Anything, uint64(3)).Return([]model.ID{11, 12}, []model.ID{5}, nil).Once()
+
+	warehouseApp := appwarehouse.NewWarehouseApp(nil, warehouseRepo, nil, nil, nil)
+	h := NewTransport(nil, nil, nil, warehouseApp, nil, nil, cfg, nil, Sweeps{}, nil)
+
+	req := httptest.NewRequest(http.MethodPatch, "/internal/v1/warehouses/3/deactivate", nil)
+	req.Header.Set("Authorization", "Bearer internal-key")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != constant.ErrorTypeHTTPCode[constant.ErrWarehouseHasReservedStock] {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	env := decodeEnvelope(t, rec)
+	if string(env["code"]) != `"`+constant.ErrorTypeCode[constant.ErrWarehouseHasReservedStock]+`"` {
+		t.Fatalf("code = %s, want %q", env["code"], constant.ErrorTypeCode[constant.ErrWarehouseHasReservedStock])
+	}
+	var details model.WarehouseReservedDetails
+	if err := json.Unmarshal(env["details"], &details); err != nil {
+		t.Fatalf("decode details %s: %v", env["details"], err)
+	}
+	want := model.WarehouseReservedDetails{ReservedQuantity: 7, OrderIDs: []model.ID{11, 12}, CartIDs: []model.ID{5}}
+	if !reflect.DeepEqual(details, want) {
+		t.Fatalf("details = %+v, want %+v", details, want)
+	}
+}
+
+func TestCreateOrder_ValidationDetails(t *testing.T) {
+	h := NewTransport(fakeUserApp{}, nil, &countingOrderApp{}, nil, nil, nil, &config.Config{}, nil, Sweeps{}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/public/v1/order", strings.NewReader(`{"items":[{"product_id":1,"quantity":2},{"product_id":0,"quantity":-1}]}`))
+	req.Header.Set("Authorization", "Bearer valid-token")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+	env := decodeEnvelope(t, rec)
+	if string(env["code"]) != `"`+constant.ErrorTypeCode[constant.ErrInvalidRequest]+`"` {
+		t.Fatalf("code = %s, want %q", env["code"], constant.ErrorTypeCode[constant.ErrInvalidRequest])
+	}
+	var details []validatorx.FieldError
+	if err := json.Unmarshal(env["details"], &details); err != nil {
+		t.Fatalf("decode details %s: %v", env["details"], err)
+	}
+	want := []validatorx.FieldError{
+		{Field: "items[1].product_id", Rule: "required"},
+		{Field: "items[1].quantity", Rule: "gt", Param: "0"},
+	}
+	if !reflect.DeepEqual(details, want) {
+		t.Fatalf("details = %+v, want %+v", details, want)
+	}
+}
+
+// createCountingOrderApp counts the orders that got past the handler's checks
+type createCountingOrderApp struct {
+	apporder.OrderApp
+	created int
+}
+
+func (a *createCountingOrderApp) CreateOrder(ctx context.Context, userID uint64, req *model.OrderRequest) (*model.OrderResponse, error) {
+	a.created++
+	return &model.OrderResponse{OrderID: 1}, nil
+}
+
+func TestCreateOrder_DecodeLimits(t *testing.T) {
+	const maxItems = 3
+	items := func(n int) string {
+		parts := make([]string, n)
+		for i := range parts {
+			parts[i] = fmt.Sprintf(`{"product_id":%d,"quantity":1}`, i+1)
+		}
+		return `{"items":[` + strings.Join(parts, ",") + `]}`
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantCreated int
+	}{
+		{"items at the max", items(maxItems), http.StatusOK, 1},
+		{"items above the max", items(maxItems + 1), http.StatusBadRequest, 0},
+		{"body above the max bytes", `{"items":[],"voucher_code":"` + strings.Repeat("x", 1024) + `"}`, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Order: config.OrderConfig{MaxBodyBytes: 512, MaxDecodedItems: maxItems}}
+			app := &createCountingOrderApp{}
+			h := NewTransport(fakeUserApp{}, nil, app, nil, nil, nil, cfg, nil, Sweeps{}, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/public/v1/order", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer valid-token")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if app.created != tt.wantCreated {
+				t.Fatalf("orders created = %d, want %d", app.created, tt.wantCreated)
+			}
+			env := decodeEnvelope(t, rec)
+			if tt.wantStatus == http.StatusBadRequest && string(env["code"]) != `"`+constant.ErrorTypeCode[constant.ErrInvalidRequest]+`"` {
+				t.Fatalf("code = %s, want %q", env["code"], constant.ErrorTypeCode[constant.ErrInvalidRequest])
+			}
+		})
+	}
+}
+
+func TestProductWrite_ValidationDetails(t *testing.T) {
+	cfg := &config.Config{InternalAPIKey: "internal-key"}
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		want   []validatorx.FieldError
+	}{
+		{
+			name:   "create without name or price",
+			method: http.MethodPost,
+			url:    "/internal/v1/product",
+			body:   `{"shop_id":1,"price":0}`,
+			want: []validatorx.FieldError{
+				{Field: "name", Rule: "required"},
+				{Field: "price", Rule: "gt", Param: "0"},
+			},
+		},
+		{
+			name:   "create with a negative price",
+			method: http.MethodPost,
+			url:    "/internal/v1/product",
+			body:   `{"shop_id":1,"name":"Kopi","price":-5}`,
+			want:   []validatorx.FieldError{{Field: "price", Rule: "gt", Param: "0"}},
+		},
+		{
+			name:   "update blanking the name",
+			method: http.MethodPatch,
+			url:    "/internal/v1/product/4",
+			body:   `{"name":"","price":0}`,
+			want: []validatorx.FieldError{
+				{Field: "name", Rule: "min", Param: "1"},
+				{Field: "price", Rule: "gt", Param: "0"},
+			},
+		},
+		{
+			name:   "price rounding to zero",
+			method: http.MethodPost,
+			url:    "/internal/v1/product",
+			body:   `{"shop_id":1,"name":"Kopi","price":0.001}`,
+			want:   []validatorx.FieldError{{Field: "price", Rule: "gt", Param: "0"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the repository mock fails the test if a rejected request reaches it
+			productApp := appproduct.NewProductApp(cfg, productmocks.NewProductRepository(t), nil)
+			h := NewTransport(nil, productApp, nil, nil, nil, nil, cfg, nil, Sweeps{}, nil)
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer internal-key")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+			}
+			env := decodeEnvelope(t, rec)
+			if string(env["code"]) != `"`+constant.ErrorTypeCode[constant.ErrInvalidRequest]+`"` {
+				t.Fatalf("code = %s, want %q", env["code"], constant.ErrorTypeCode[constant.ErrInvalidRequest])
+			}
+			var details []validatorx.FieldError
+			if err := json.Unmarshal(env["details"], &details); err != nil {
+				t.Fatalf("decode details %s: %v", env["details"], err)
+			}
+			if !reflect.DeepEqual(details, tt.want) {
+				t.Fatalf("details = %+v, want %+v", details, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyEmail_MissingToken(t *testing.T) {
+	// no Authorization header: the verify link is opened before the first login
+	h := NewTransport(fakeUserApp{}, nil, nil, nil, nil, nil, &config.Config{}, nil, Sweeps{}, nil)
+	req := httptest.NewRequest(http.MethodPost, "/public/v1/verify", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+	env := decodeEnvelope(t, rec)
+	var details []validatorx.FieldError
+	if err := json.Unmarshal(env["details"], &details); err != nil {
+		t.Fatalf("decode details %s: %v", env["details"], err)
+	}
+	want := []validatorx.FieldError{{Field: "token", Rule: "required"}}
+	if !reflect.DeepEqual(details, want) {
+		t.Fatalf("details = %+v, want %+v", details, want)
+	}
+}
+
+type resendUserApp struct {
+	fakeUserApp
+	gotUserID uint64
+}
+
+func (a *resendUserApp) ResendVerification(ctx context.Context, userID uint64) (*model.ResendVerificationResponse, error) {
+	a.gotUserID = userID
+	return &model.ResendVerificationResponse{VerificationToken: "fresh"}, nil
+}
+
+func TestResendVerification(t *testing.T) {
+	tests := []struct {
+		name       string
+		auth       string
+		wantStatus int
+		wantUserID uint64
+	}{
+		{"logged in user", "Bearer " + validToken, http.StatusOK, 42},
+		{"anonymous", "", http.StatusUnauthorized, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &resendUserApp{}
+			h := NewTransport(app, nil, nil, nil, nil, nil, &config.Config{}, nil, Sweeps{}, nil)
+			req := httptest.NewRequest(http.MethodPost, "/public/v1/verify/resend", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if app.gotUserID != tt.wantUserID {
+				t.Fatalf("ResendVerification called for user %d, want %d", app.gotUserID, tt.wantUserID)
+			}
+		})
+	}
+}
@@ -13,8 +13,12 @@ import (
 
 // AuthMiddleware returns a middleware that validates JWT sessions using UserApp.
 // It allows public endpoints (like /login, /register, /swagger/) without token.
+// Optionally authenticated endpoints (product browsing) are handed to
+// OptionalAuthMiddleware, every other route requires a valid session.
 func AuthMiddleware(userApp user.UserApp) mux.MiddlewareFunc {
+	optional := OptionalAuthMiddleware(userApp)
 	return func(next http.Handler) http.Handler {
+		lenient := optional(next)
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Public paths
 			path := r.URL.Path
@@ -23,17 +27,13 @@ func AuthMiddleware(userApp user.UserApp) mux.MiddlewareFunc {
 				return
 			}
 
-			// Check Authorization header
-			auth := r.Header.Get("Authorization")
-			if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
-				writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
+			if isOptionalAuthPath(r.Method, path) {
+				lenient.ServeHTTP(w, r)
 				return
 			}
-			token := strings.TrimPrefix(auth, "Bearer ")
 
-			// Validate token via UserApp
-			userID, err := userApp.ValidateToken(r.Context(), token)
-			if err != nil {
+			userID, ok := authenticate(r, userApp)
+			if !ok {
 				writeError(w, errors.SetCustomError(constant.ErrUnauthorize))
 				return
 			}
@@ -45,15 +45,84 @@ func AuthMiddleware(userApp user.UserApp) mux.MiddlewareFunc {
 	}
 }
 
-// isPublicPath defines which endpoints are public (no auth required)
-func isPublicPath(path string) bool {
-	allowed := []string{"swagger", "internal", "login", "register"}
+// OptionalAuthMiddleware validates the token when one is sent and embeds the
+// user id, but never rejects the request. It is meant for endpoints that
+// personalize for a logged in user and still work anonymously. A missing,
+// malformed or expired token falls back to an anonymous request instead of
+// breaking the page for the caller.
+func OptionalAuthMiddleware(userApp user.UserApp) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if userID, ok := authenticate(r, userApp); ok {
+				ctx := context.WithValue(r.Context(), constant.UserIDKey, userID)
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authenticate validates the bearer token of r via UserApp
+func authenticate(r *http.Request, userApp user.UserApp) (uint64, bool) {
+	// Check Authorization header
+	auth := r.Header.Get("Authorization")
+	if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
+		return 0, false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+
+	// Validate token via UserApp
+	userID, err := userApp.ValidateToken(r.Context(), token)
+	if err != nil {
+		return 0, false
+	}
+	return userID, true
+}
 
-	for _, a := range allowed {
-		if strings.Contains(path, a) {
+// publicPaths are served without a user session
+var publicPaths = map[string]bool{
+	"/public/v1/login":    true,
+	"/public/v1/register": true,
+	"/public/v1/verify":   true,
+	"/healthz":            true,
+	"/readyz":             true,
+	"/metrics":            true,
+}
+
+// sessionExemptPrefixes skip the user session check. /internal/ is not public:
+// InternalMiddleware guards it with the internal API key instead of a JWT.
+var sessionExemptPrefixes = []string{"/swagger/", "/internal/"}
+
+// optionalAuthTrees are browsable with or without a session, the product
+// catalog including its feed and sync. Only reads are optional, writes under
+// them (reviews, stock subscriptions) still need a user.
+var optionalAuthTrees = []string{"/public/v1/product"}
+
+// isOptionalAuthPath reports whether the request may be served anonymously while
+// still picking up the user when a valid token is present
+func isOptionalAuthPath(method, path string) bool {
+	if method != http.MethodGet && method != http.MethodHead {
+		return false
+	}
+	for _, tree := range optionalAuthTrees {
+		if path == tree || strings.HasPrefix(path, tree+"/") {
 			return true
 		}
 	}
+	return false
+}
 
+// isPublicPath defines which endpoints are public (no auth required). Paths are
+// matched exactly, or by prefix for whole trees, so a route that merely
+// contains "login" is not exempted by accident.
+func isPublicPath(path string) bool {
+	if publicPaths[path] {
+		return true
+	}
+	for _, prefix := range sessionExemptPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
 	return false
 }
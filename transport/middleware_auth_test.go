@@ -0,0 +1,169 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/application/user"
+	"github.com/muhammadheryan/e-commerce/constant"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
+)
+
+func TestIsPublicPath(t *testing.T) {
+	tests := []struct {
+		path       string
+		wantPublic bool
+	}{
+		{"/public/v1/login", true},
+		{"/public/v1/register", true},
+		{"/public/v1/verify", true},
+		{"/healthz", true},
+		{"/readyz", true},
+		{"/metrics", true},
+		{"/swagger/index.html", true},
+		// guarded by the internal API key, not by a user session
+		{"/internal/v1/order/1/cancel", true},
+		{"/internal/v1/config", true},
+
+		{"/public/v1/product", false},
+		{"/public/v1/order", false},
+		{"/public/v1/cart", false},
+		{"/public/v1/wishlist", false},
+		{"/public/v1/notification-preferences", false},
+		{"/login", false},
+		{"/public/v1/login/extra", false},
+		// resending needs to know whose email it is
+		{"/public/v1/verify/resend", false},
+		{"/public/v1/product/register", false},
+		{"/public/v1/internal", false},
+		{"/public/v1/healthz-ish", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := isPublicPath(tt.path); got != tt.wantPublic {
+				t.Fatalf("isPublicPath(%q) = %v, want %v", tt.path, got, tt.wantPublic)
+			}
+		})
+	}
+}
+
+func TestIsOptionalAuthPath(t *testing.T) {
+	tests := []struct {
+		method       string
+		path         string
+		wantOptional bool
+	}{
+		{http.MethodGet, "/public/v1/product", true},
+		{http.MethodGet, "/public/v1/product/7", true},
+		{http.MethodGet, "/public/v1/product/7/reviews", true},
+		{http.MethodHead, "/public/v1/product/feed", true},
+
+		{http.MethodPost, "/public/v1/product/7/reviews", false},
+		{http.MethodPost, "/public/v1/product/7/stock-subscription", false},
+		{http.MethodGet, "/public/v1/products", false},
+		{http.MethodGet, "/public/v1/order", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if got := isOptionalAuthPath(tt.method, tt.path); got != tt.wantOptional {
+				t.Fatalf("isOptionalAuthPath(%q, %q) = %v, want %v", tt.method, tt.path, got, tt.wantOptional)
+			}
+		})
+	}
+}
+
+// fakeUserApp accepts only validToken, any other UserApp call panics
+type fakeUserApp struct {
+	user.UserApp
+}
+
+const validToken = "valid-token"
+
+func (fakeUserApp) ValidateToken(ctx context.Context, token string) (uint64, error) {
+	if token != validToken {
+		return 0, errors.SetCustomError(constant.ErrUnauthorize)
+	}
+	return 42, nil
+}
+
+func TestAuthMiddleware_OptionalAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		auth       string
+		wantStatus int
+		wantUserID uint64
+	}{
+		{"anonymous product list", http.MethodGet, "/public/v1/product", "", http.StatusOK, 0},
+		{"authenticated product list", http.MethodGet, "/public/v1/product", "Bearer " + validToken, http.StatusOK, 42},
+		{"invalid token browses anonymously", http.MethodGet, "/public/v1/product/7", "Bearer expired", http.StatusOK, 0},
+		{"anonymous review rejected", http.MethodPost, "/public/v1/product/7/reviews", "", http.StatusUnauthorized, 0},
+		{"authenticated review", http.MethodPost, "/public/v1/product/7/reviews", "Bearer " + validToken, http.StatusOK, 42},
+		{"anonymous order rejected", http.MethodGet, "/public/v1/order", "", http.StatusUnauthorized, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUserID uint64
+			h := AuthMiddleware(fakeUserApp{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserID, _ = utilsContext.GetUserID(r.Context())
+				writeSuccess(w, nil)
+			}))
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if gotUserID != tt.wantUserID {
+				t.Errorf("user id = %d, want %d", gotUserID, tt.wantUserID)
+			}
+		})
+	}
+}
+
+func TestOptionalAuthMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		auth       string
+		wantUserID uint64
+		wantAuthed bool
+	}{
+		{"present valid token", "Bearer " + validToken, 42, true},
+		{"present invalid token", "Bearer expired", 0, false},
+		{"malformed header", validToken, 0, false},
+		{"absent token", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUserID uint64
+			var gotAuthed bool
+			h := OptionalAuthMiddleware(fakeUserApp{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserID, gotAuthed = utilsContext.GetUserID(r.Context())
+				writeSuccess(w, nil)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/public/v1/product/feed", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if gotUserID != tt.wantUserID || gotAuthed != tt.wantAuthed {
+				t.Errorf("user = (%d, %v), want (%d, %v)", gotUserID, gotAuthed, tt.wantUserID, tt.wantAuthed)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
+	"github.com/muhammadheryan/e-commerce/utils/logger"
+	"go.uber.org/zap"
+)
+
+const redactedValue = "[REDACTED]"
+
+// BodyLogMiddleware logs the request and response bodies of the routes allowed
+// by cfg, for reproducing client reported issues. It must stay disabled outside
+// of debugging, see config.BodyLogConfig.
+func BodyLogMiddleware(cfg config.BodyLogConfig) mux.MiddlewareFunc {
+	return newBodyLogMiddleware(cfg, logger.Info)
+}
+
+func newBodyLogMiddleware(cfg config.BodyLogConfig, log func(msg string, fields ...zap.Field)) mux.MiddlewareFunc {
+	if !cfg.Enabled || cfg.MaxBytes <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	redactor := newBodyRedactor(cfg.RedactFields)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !bodyLogAllowed(cfg.Paths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// peek at the head of the body and hand the handler all of it
+			var reqBody []byte
+			if r.Body != nil {
+				head, err := io.ReadAll(io.LimitReader(r.Body, int64(cfg.MaxBytes)+1))
+				if err != nil {
+					logger.Error("[BodyLogMiddleware] read request body", zap.String("error", err.Error()))
+				}
+				reqBody = head
+				r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(head), r.Body), Closer: r.Body}
+			}
+
+			captured := &bodyCaptureWriter{ResponseWriter: w, statusCode: http.StatusOK, max: cfg.MaxBytes}
+			next.ServeHTTP(captured, r)
+
+			reqTruncated := len(reqBody) > cfg.MaxBytes
+			if reqTruncated {
+				reqBody = reqBody[:cfg.MaxBytes]
+			}
+			log(
+				"HTTP body",
+				zap.String("request_id", utilsContext.GetRequestID(r.Context())),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", captured.statusCode),
+				zap.String("request_body", redactor.redact(reqBody)),
+				zap.Bool("request_body_truncated", reqTruncated),
+				zap.String("response_body", redactor.redact(captured.buf)),
+				zap.Bool("response_body_truncated", captured.truncated),
+			)
+		})
+	}
+}
+
+// bodyLogAllowed reports whether path is under one of the prefixes, any path when there are none
+func bodyLogAllowed(prefixes []string, path string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyCaptureWriter keeps a copy of the first max bytes of the response
+type bodyCaptureWriter struct {
+	http.ResponseWriter
+	statusCode int
+	max        int
+	buf        []byte
+	truncated  bool
+}
+
+func (w *bodyCaptureWriter) WriteHeader(code int) {
+	w.statusCode = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *bodyCaptureWriter) Write(p []byte) (int, error) {
+	if room := w.max - len(w.buf); room > 0 {
+		w.buf = append(w.buf, p[:min(room, len(p))]...)
+	}
+	if len(w.buf)+len(p) > w.max {
+		w.truncated = true
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// bodyRedactor masks the values of sensitive JSON fields, matched case-insensitively
+type bodyRedactor struct {
+	fields map[string]bool
+	// raw catches the fields in bodies that don't parse, a truncated one for instance
+	raw *regexp.Regexp
+}
+
+func newBodyRedactor(fields []string) *bodyRedactor {
+	b := &bodyRedactor{fields: make(map[string]bool, len(fields))}
+	quoted := make([]string, 0, len(fields))
+	for _, f := range fields {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		b.fields[f] = true
+		quoted = append(quoted, regexp.QuoteMeta(f))
+	}
+	if len(quoted) > 0 {
+		// the value may be cut short, an unterminated string runs to the end
+		b.raw = regexp.MustCompile(`(?i)("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]*)`)
+	}
+	return b
+}
+
+func (b *bodyRedactor) redact(body []byte) string {
+	if len(body) == 0 || b.raw == nil {
+		return string(body)
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err == nil {
+		if out, err := json.Marshal(b.walk(v)); err == nil {
+			return string(out)
+		}
+	}
+	return b.raw.ReplaceAllString(string(body), `${1}"`+redactedValue+`"`)
+}
+
+func (b *bodyRedactor) walk(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if b.fields[strings.ToLower(k)] {
+				t[k] = redactedValue
+				continue
+			}
+			t[k] = b.walk(child)
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = b.walk(child)
+		}
+	}
+	return v
+}
@@ -0,0 +1,122 @@
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	"go.uber.org/zap"
+)
+
+// loggedBodies records the fields of every body log line
+type loggedBodies struct {
+	entries []map[string]zap.Field
+}
+
+func (l *loggedBodies) log(msg string, fields ...zap.Field) {
+	entry := make(map[string]zap.Field, len(fields))
+	for _, f := range fields {
+		entry[f.Key] = f
+	}
+	l.entries = append(l.entries, entry)
+}
+
+func echoHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("handler read body: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	})
+}
+
+func TestBodyLogMiddleware(t *testing.T) {
+	enabled := config.BodyLogConfig{
+		Enabled:      true,
+		Paths:        []string{"/public/v1/user"},
+		MaxBytes:     1024,
+		RedactFields: []string{"password", "token"},
+	}
+	tests := []struct {
+		name     string
+		cfg      config.BodyLogConfig
+		path     string
+		body     string
+		wantLog  bool
+		wantBody string
+	}{
+		{
+			name:     "allowed route, sensitive fields redacted",
+			cfg:      enabled,
+			path:     "/public/v1/user/login",
+			body:     `{"Password":"hunter2","identifier":"a@b.c","nested":{"token":"abc"}}`,
+			wantLog:  true,
+			wantBody: `{"Password":"[REDACTED]","identifier":"a@b.c","nested":{"token":"[REDACTED]"}}`,
+		},
+		{
+			name: "route outside the allowlist",
+			cfg:  enabled,
+			path: "/public/v1/order",
+			body: `{"items":[]}`,
+		},
+		{
+			name: "disabled",
+			cfg:  config.BodyLogConfig{Paths: enabled.Paths, MaxBytes: 1024, RedactFields: enabled.RedactFields},
+			path: "/public/v1/user/login",
+			body: `{"password":"hunter2"}`,
+		},
+		{
+			name:     "cut body is still redacted",
+			cfg:      config.BodyLogConfig{Enabled: true, MaxBytes: 40, RedactFields: []string{"password"}},
+			path:     "/public/v1/user/register",
+			body:     `{"name":"someone","password":"a-very-long-secret-value"}`,
+			wantLog:  true,
+			wantBody: `{"name":"someone","password":"[REDACTED]"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := &loggedBodies{}
+			h := newBodyLogMiddleware(tt.cfg, logs.log)(echoHandler(t))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+
+			// the handler sees the whole body whether or not it was logged
+			if rec.Code != http.StatusCreated || rec.Body.String() != tt.body {
+				t.Fatalf("response = %d %q, want %d %q", rec.Code, rec.Body.String(), http.StatusCreated, tt.body)
+			}
+			if !tt.wantLog {
+				if len(logs.entries) != 0 {
+					t.Fatalf("bodies logged: %v", logs.entries)
+				}
+				return
+			}
+			if len(logs.entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(logs.entries))
+			}
+			entry := logs.entries[0]
+			if got := entry["request_body"].String; got != tt.wantBody {
+				t.Errorf("request_body = %s, want %s", got, tt.wantBody)
+			}
+			if got := entry["response_body"].String; got != tt.wantBody {
+				t.Errorf("response_body = %s, want %s", got, tt.wantBody)
+			}
+			truncated := len(tt.body) > tt.cfg.MaxBytes
+			if got := entry["request_body_truncated"].Integer == 1; got != truncated {
+				t.Errorf("request_body_truncated = %v, want %v", got, truncated)
+			}
+			if got := entry["response_body_truncated"].Integer == 1; got != truncated {
+				t.Errorf("response_body_truncated = %v, want %v", got, truncated)
+			}
+			if got := entry["status"].Integer; got != http.StatusCreated {
+				t.Errorf("status = %d, want %d", got, http.StatusCreated)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package transport
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+)
+
+// CompressionMiddleware gzips responses for clients sending Accept-Encoding: gzip.
+// Bodies are buffered up to cfg.MinSize bytes first, anything smaller is sent as
+// is since compressing it costs more than it saves.
+func CompressionMiddleware(cfg config.CompressionConfig) mux.MiddlewareFunc {
+	if !cfg.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the body depends on Accept-Encoding, caches must key on it
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method == http.MethodHead || !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: cfg.MinSize, statusCode: http.StatusOK}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether gzip is listed in Accept-Encoding and not refused with q=0
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter holds back the status and the first minSize bytes until it
+// knows whether the body is worth compressing
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize    int
+	statusCode int
+
+	buf         []byte
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.statusCode = code
+	}
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	if w.wroteHeader {
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.start(w.compressible()); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// compressible is false for bodiless statuses and bodies a handler already encoded
+func (w *gzipResponseWriter) compressible() bool {
+	if w.statusCode == http.StatusNoContent || w.statusCode == http.StatusNotModified {
+		return false
+	}
+	return w.Header().Get("Content-Encoding") == ""
+}
+
+// start sends the headers and the buffered bytes, through gzip when compress is set
+func (w *gzipResponseWriter) start(compress bool) error {
+	w.wroteHeader = true
+	buf := w.buf
+	w.buf = nil
+
+	if !compress {
+		w.ResponseWriter.WriteHeader(w.statusCode)
+		_, err := w.ResponseWriter.Write(buf)
+		return err
+	}
+
+	h := w.Header()
+	h.Set("Content-Encoding", "gzip")
+	// the length set by the handler is of the uncompressed body
+	h.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+	_, err := w.gz.Write(buf)
+	return err
+}
+
+// close flushes a body that stayed under minSize uncompressed and finishes the gzip stream
+func (w *gzipResponseWriter) close() {
+	if !w.wroteHeader {
+		_ = w.start(false)
+	}
+	if w.gz != nil {
+		_ = w.gz.Close()
+	}
+}
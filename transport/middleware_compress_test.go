@@ -0,0 +1,117 @@
+package transport
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"id":1,"name":"product"},`, 100)
+	small := `{"id":1}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           string
+		status         int
+		wantGzip       bool
+	}{
+		{"large body with gzip accepted", "gzip, deflate", large, http.StatusOK, true},
+		{"error status is compressed too", "gzip", large, http.StatusBadRequest, true},
+		{"below threshold", "gzip", small, http.StatusOK, false},
+		{"no accept-encoding", "", large, http.StatusOK, false},
+		{"gzip refused", "gzip;q=0, deflate", large, http.StatusOK, false},
+		{"other encodings only", "br", large, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := CompressionMiddleware(config.CompressionConfig{Enabled: true, MinSize: 512})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				// written in pieces so the threshold is crossed mid-body
+				for i := 0; i < len(tt.body); i += 100 {
+					end := min(i+100, len(tt.body))
+					_, _ = w.Write([]byte(tt.body[i:end]))
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/public/v1/product", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+
+			body := rec.Body.String()
+			if tt.wantGzip {
+				if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+					t.Fatalf("Content-Encoding = %q, want gzip", got)
+				}
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("gzip reader: %v", err)
+				}
+				raw, err := io.ReadAll(zr)
+				if err != nil {
+					t.Fatalf("decompress: %v", err)
+				}
+				body = string(raw)
+			} else if got := rec.Header().Get("Content-Encoding"); got != "" {
+				t.Fatalf("Content-Encoding = %q, want none", got)
+			}
+			if body != tt.body {
+				t.Fatalf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestCompressionMiddleware_ResponseWriterCapture(t *testing.T) {
+	body := strings.Repeat("a", 4096)
+	h := CompressionMiddleware(config.CompressionConfig{Enabled: true, MinSize: 1024})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(body))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/public/v1/order", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	// the logging/metrics wrapper sits outside the compressor
+	wrapped := &responseWriter{ResponseWriter: rec, statusCode: http.StatusOK}
+	h.ServeHTTP(wrapped, req)
+
+	if wrapped.statusCode != http.StatusCreated {
+		t.Fatalf("captured status = %d, want %d", wrapped.statusCode, http.StatusCreated)
+	}
+	if wrapped.size != rec.Body.Len() || wrapped.size >= len(body) {
+		t.Fatalf("captured size = %d, want the %d compressed bytes sent", wrapped.size, rec.Body.Len())
+	}
+}
+
+func TestCompressionMiddleware_Disabled(t *testing.T) {
+	h := CompressionMiddleware(config.CompressionConfig{Enabled: false})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("a", 4096)))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/public/v1/product", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Fatalf("Content-Encoding = %q, want none", got)
+	}
+}
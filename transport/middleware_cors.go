@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
+)
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight answer
+const corsMaxAge = "600"
+
+// CORSMiddleware sets CORS headers for allowed origins and answers preflight
+// requests. With no allowed origins every cross-origin request is denied.
+//
+// It wraps the whole router instead of going through router.Use: mux only runs
+// middlewares for matched routes, and an OPTIONS preflight matches none of them.
+func CORSMiddleware(cfg config.CORSConfig) func(http.Handler) http.Handler {
+	allowAny := false
+	allowed := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" {
+			allowAny = true
+		}
+		allowed[o] = true
+	}
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				// not a cross-origin browser request
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			originAllowed := allowAny || allowed[origin]
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if !originAllowed {
+				if preflight {
+					writeError(w, errors.SetCustomError(constant.ErrForbidden))
+					return
+				}
+				// without CORS headers the browser hides the response from the caller
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", constant.RequestIDHeader)
+			if preflight {
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
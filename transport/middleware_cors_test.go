@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	allowList := config.CORSConfig{
+		AllowedOrigins: []string{"https://shop.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+	}
+	tests := []struct {
+		name          string
+		cfg           config.CORSConfig
+		method        string
+		origin        string
+		preflight     bool
+		wantStatus    int
+		wantAllowOrig string
+		wantNext      bool
+	}{
+		{name: "no origin passes through", cfg: allowList, method: http.MethodGet, wantStatus: http.StatusOK, wantNext: true},
+		{name: "allowed origin is echoed", cfg: allowList, method: http.MethodGet, origin: "https://shop.example.com", wantStatus: http.StatusOK, wantAllowOrig: "https://shop.example.com", wantNext: true},
+		{name: "unknown origin gets no cors headers", cfg: allowList, method: http.MethodGet, origin: "https://evil.example.com", wantStatus: http.StatusOK, wantNext: true},
+		{name: "allowed preflight is answered", cfg: allowList, method: http.MethodOptions, origin: "https://shop.example.com", preflight: true, wantStatus: http.StatusNoContent, wantAllowOrig: "https://shop.example.com"},
+		{name: "unknown preflight is forbidden", cfg: allowList, method: http.MethodOptions, origin: "https://evil.example.com", preflight: true, wantStatus: http.StatusForbidden},
+		{name: "empty config denies every origin", cfg: config.CORSConfig{}, method: http.MethodOptions, origin: "https://shop.example.com", preflight: true, wantStatus: http.StatusForbidden},
+		{name: "wildcard allows any origin", cfg: config.CORSConfig{AllowedOrigins: []string{"*"}}, method: http.MethodGet, origin: "https://any.example.com", wantStatus: http.StatusOK, wantAllowOrig: "https://any.example.com", wantNext: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := CORSMiddleware(tt.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tt.method, "/public/v1/product", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrig {
+				t.Fatalf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowOrig)
+			}
+			if called != tt.wantNext {
+				t.Fatalf("next called = %v, want %v", called, tt.wantNext)
+			}
+			if tt.preflight && tt.wantStatus == http.StatusNoContent && rec.Header().Get("Access-Control-Allow-Methods") != "GET, POST" {
+				t.Fatalf("Access-Control-Allow-Methods = %q", rec.Header().Get("Access-Control-Allow-Methods"))
+			}
+		})
+	}
+}
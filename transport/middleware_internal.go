@@ -1,15 +1,23 @@
 package transport
 
 import (
+	"crypto/subtle"
 	"net/http"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
 )
 
-// InternalMiddleware checks for static API key in header
+// InternalMiddleware checks for static API key in header. An empty key locks
+// the internal routes instead of opening them to "Bearer ".
 func InternalMiddleware(apiKey string) func(http.Handler) http.Handler {
+	want := []byte("Bearer " + apiKey)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Header.Get("Authorization") != "Bearer "+apiKey {
-				http.Error(w, "Forbidden", http.StatusForbidden)
+			// compared in constant time so the key can't be guessed byte by byte
+			got := []byte(r.Header.Get("Authorization"))
+			if apiKey == "" || subtle.ConstantTimeCompare(got, want) != 1 {
+				writeError(w, errors.SetCustomError(constant.ErrForbidden))
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -0,0 +1,53 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+)
+
+func TestInternalMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		auth       string
+		wantStatus int
+	}{
+		{"matching key", "internal-key", "Bearer internal-key", http.StatusOK},
+		{"wrong key", "internal-key", "Bearer internal-kez", http.StatusForbidden},
+		{"key prefix", "internal-key", "Bearer internal", http.StatusForbidden},
+		{"missing scheme", "internal-key", "internal-key", http.StatusForbidden},
+		{"no header", "internal-key", "", http.StatusForbidden},
+		{"empty configured key, empty bearer", "", "Bearer ", http.StatusForbidden},
+		{"empty configured key, no header", "", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := InternalMiddleware(tt.apiKey)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				writeSuccess(w, nil)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/internal/v1/config", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if called != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("handler called = %v", called)
+			}
+			env := decodeEnvelope(t, rec)
+			if tt.wantStatus == http.StatusForbidden && string(env["code"]) != `"`+constant.ErrorTypeCode[constant.ErrForbidden]+`"` {
+				t.Errorf("code = %s, want %q", env["code"], constant.ErrorTypeCode[constant.ErrForbidden])
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
 	"github.com/muhammadheryan/e-commerce/utils/logger"
 	"go.uber.org/zap"
 )
@@ -25,19 +26,29 @@ func LoggingMiddleware() mux.MiddlewareFunc {
 			duration := time.Since(start)
 			logger.Info(
 				"HTTP request",
+				zap.String("request_id", utilsContext.GetRequestID(r.Context())),
 				zap.String("method", r.Method),
 				zap.String("path", r.URL.Path),
 				zap.Int("status", wrapped.statusCode),
+				zap.Int("bytes", wrapped.size),
 				zap.Duration("duration", duration),
 			)
 		})
 	}
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code
+// responseWriter wraps http.ResponseWriter to capture status code and the
+// number of bytes sent, after compression when it applies
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	size       int
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(p)
+	rw.size += n
+	return n, err
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
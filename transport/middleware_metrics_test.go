@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// observations returns how many latencies were recorded for the method and route
+func observations(t *testing.T, method, route string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := httpRequestDuration.WithLabelValues(method, route).(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatalf("read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestMetricsMiddleware_LabelsByRouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/test/v1/order/{id}/pay", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}).Methods(http.MethodPost)
+	router.Use(MetricsMiddleware())
+
+	const route = "/test/v1/order/{id}/pay"
+	before := testutil.ToFloat64(httpRequestsTotal.WithLabelValues(http.MethodPost, route, "400"))
+	beforeCount := observations(t, http.MethodPost, route)
+
+	for _, path := range []string{"/test/v1/order/1/pay", "/test/v1/order/2/pay"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, nil))
+	}
+
+	if got := testutil.ToFloat64(httpRequestsTotal.WithLabelValues(http.MethodPost, route, "400")) - before; got != 2 {
+		t.Fatalf("requests counted under route template = %v, want 2", got)
+	}
+	if got := observations(t, http.MethodPost, route) - beforeCount; got != 2 {
+		t.Fatalf("latency observations = %d, want 2", got)
+	}
+}
+
+func TestMetricsMiddleware_InFlightRequests(t *testing.T) {
+	var during int64
+	h := MetricsMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		during = InFlightRequests()
+	}))
+
+	before := InFlightRequests()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if during != before+1 {
+		t.Fatalf("in flight while handling = %d, want %d", during, before+1)
+	}
+	if got := InFlightRequests(); got != before {
+		t.Fatalf("in flight after the request = %d, want %d", got, before)
+	}
+}
+
+func TestMetricsHandler_ServesRecordedSeries(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/test/v1/exposed", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	router.Use(MetricsMiddleware())
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test/v1/exposed", nil))
+
+	rec := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/test/v1/exposed",status="200"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/test/v1/exposed"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("/metrics missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
+)
+
+// rateLimitSweepInterval is how often buckets of clients that went quiet are dropped
+const rateLimitSweepInterval = time.Minute
+
+// RateLimitMiddleware throttles each client, keyed by remote IP, with a token
+// bucket of cfg.Burst tokens refilled at cfg.RequestsPerSecond. A zero rate
+// disables it.
+//
+// Only public traffic is limited. The internal tree is called by the expiration
+// consumer in bursts when many orders expire together, throttling it would leave
+// those orders uncanceled. Probes are left alone so a busy pod isn't restarted.
+func RateLimitMiddleware(cfg config.RateLimitConfig) mux.MiddlewareFunc {
+	if cfg.RequestsPerSecond <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	limiter := newRateLimiter(cfg.RequestsPerSecond, cfg.Burst, time.Now)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isRateLimitExempt(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !limiter.allow(clientIP(r)) {
+				w.Header().Set("Retry-After", "1")
+				writeError(w, errors.SetCustomError(constant.ErrTooManyRequests))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitExemptPrefixes are never throttled, see RateLimitMiddleware
+var rateLimitExemptPrefixes = []string{"/internal/", "/healthz", "/readyz", "/metrics"}
+
+func isRateLimitExempt(path string) bool {
+	for _, prefix := range rateLimitExemptPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the host part of the remote address, the whole address when it has no port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps one token bucket per client
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(rate float64, burst int, now func() time.Time) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		now:       now,
+		buckets:   map[string]*rateBucket{},
+		lastSweep: now(),
+	}
+}
+
+// allow takes a token from the client's bucket, false when it is empty
+func (l *rateLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep drops buckets that have refilled completely, a new bucket starts full
+// anyway so forgetting them changes nothing but memory use
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
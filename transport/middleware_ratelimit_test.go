@@ -0,0 +1,110 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	orderapp "github.com/muhammadheryan/e-commerce/application/order"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/model"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(2, 3, func() time.Time { return now })
+
+	for i := 0; i < 3; i++ {
+		if !l.allow("a") {
+			t.Fatalf("request %d within burst was limited", i+1)
+		}
+	}
+	if l.allow("a") {
+		t.Fatal("request past burst was allowed")
+	}
+	if !l.allow("b") {
+		t.Fatal("other client was limited by a's bucket")
+	}
+
+	// 2 tokens per second, half a second refills one
+	now = now.Add(500 * time.Millisecond)
+	if !l.allow("a") {
+		t.Fatal("refilled token was not granted")
+	}
+	if l.allow("a") {
+		t.Fatal("more than the refilled token was granted")
+	}
+}
+
+func TestRateLimiter_SweepsIdleClients(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(1, 1, func() time.Time { return now })
+
+	l.allow("idle")
+	now = now.Add(rateLimitSweepInterval)
+	l.allow("active")
+
+	if _, ok := l.buckets["idle"]; ok {
+		t.Fatal("refilled bucket of an idle client was kept")
+	}
+	if _, ok := l.buckets["active"]; !ok {
+		t.Fatal("bucket of the active client was dropped")
+	}
+}
+
+// countingOrderApp counts internal cancels, any other OrderApp call panics
+type countingOrderApp struct {
+	orderapp.OrderApp
+	canceled atomic.Int64
+}
+
+func (a *countingOrderApp) InternalCancelOrder(ctx context.Context, orderID uint64) (*model.OrderDetail, error) {
+	a.canceled.Add(1)
+	return &model.OrderDetail{ID: orderID, Status: constant.OrderStatusCanceled}, nil
+}
+
+func TestRateLimitMiddleware_InternalRoutesExempt(t *testing.T) {
+	cfg := &config.Config{
+		InternalAPIKey: "internal-key",
+		Server:         config.ServerConfig{RateLimit: config.RateLimitConfig{RequestsPerSecond: 1, Burst: 2}},
+	}
+	app := &countingOrderApp{}
+	h := NewTransport(nil, nil, app, nil, nil, nil, cfg, nil, Sweeps{}, nil)
+
+	// a mass expiration fires many cancels from the consumer's single address
+	const burst = 50
+	for i := 0; i < burst; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/internal/v1/order/1/cancel", nil)
+		req.Header.Set("Authorization", "Bearer internal-key")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("internal cancel %d: status = %d, want %d: %s", i+1, rec.Code, http.StatusOK, rec.Body.String())
+		}
+	}
+	if got := app.canceled.Load(); got != burst {
+		t.Fatalf("canceled = %d, want %d", got, burst)
+	}
+
+	// the same client is still throttled on public routes
+	limited := false
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/public/v1/order", nil))
+		if rec.Code == constant.ErrorTypeHTTPCode[constant.ErrTooManyRequests] {
+			limited = true
+			env := decodeEnvelope(t, rec)
+			if string(env["code"]) != `"`+constant.ErrorTypeCode[constant.ErrTooManyRequests]+`"` {
+				t.Errorf("code = %s, want %q", env["code"], constant.ErrorTypeCode[constant.ErrTooManyRequests])
+			}
+			break
+		}
+	}
+	if !limited {
+		t.Fatal("public burst was never rate limited")
+	}
+}
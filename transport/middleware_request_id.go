@@ -0,0 +1,43 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/muhammadheryan/e-commerce/constant"
+)
+
+// maxRequestIDLength bounds client supplied ids before they end up in logs
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware reuses the caller's X-Request-ID, or generates one, so
+// every log line of a request (and of the internal hops it causes) can be correlated
+func RequestIDMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(constant.RequestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = uuid.NewString()
+			}
+
+			w.Header().Set(constant.RequestIDHeader, requestID)
+			ctx := context.WithValue(r.Context(), constant.RequestIDKey, requestID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// validRequestID accepts non-empty, bounded, printable ASCII ids
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
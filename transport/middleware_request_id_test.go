@@ -0,0 +1,47 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool
+	}{
+		{name: "reuses incoming id", incoming: "abc-123", wantSame: true},
+		{name: "generates id when missing", incoming: ""},
+		{name: "replaces id with control characters", incoming: "abc\n123"},
+		{name: "replaces oversized id", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctxID string
+			h := RequestIDMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID = utilsContext.GetRequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/public/v1/product", nil)
+			if tt.incoming != "" {
+				req.Header.Set(constant.RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			echoed := rec.Header().Get(constant.RequestIDHeader)
+			if echoed == "" || echoed != ctxID {
+				t.Fatalf("response id = %q, context id = %q, want equal and non-empty", echoed, ctxID)
+			}
+			if (echoed == tt.incoming) != tt.wantSame {
+				t.Fatalf("response id = %q, incoming = %q, wantSame %v", echoed, tt.incoming, tt.wantSame)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	t.Run("slow handler gets a 504 and its query is cancelled", func(t *testing.T) {
+		// a query timeout longer than the request one, the request deadline still wins
+		utilsContext.SetQueryTimeout(time.Minute)
+		t.Cleanup(func() { utilsContext.SetQueryTimeout(0) })
+
+		queryErr := make(chan error, 1)
+		h := TimeoutMiddleware(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// stands in for a repository call blocked on the database
+			ctx, cancel := utilsContext.WithQueryTimeout(r.Context())
+			defer cancel()
+			select {
+			case <-ctx.Done():
+				queryErr <- ctx.Err()
+			case <-time.After(5 * time.Second):
+				queryErr <- nil
+			}
+			writeSuccess(w, "too late")
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/public/v1/order", nil))
+
+		if rec.Code != http.StatusGatewayTimeout {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusGatewayTimeout)
+		}
+		env := decodeEnvelope(t, rec)
+		if string(env["code"]) != `"`+constant.ErrorTypeCode[constant.ErrRequestTimeout]+`"` {
+			t.Fatalf("code = %s, want %q", env["code"], constant.ErrorTypeCode[constant.ErrRequestTimeout])
+		}
+		select {
+		case err := <-queryErr:
+			if err != context.DeadlineExceeded {
+				t.Fatalf("query context error = %v, want %v", err, context.DeadlineExceeded)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("query context was not cancelled")
+		}
+	})
+
+	t.Run("fast handler response is passed through", func(t *testing.T) {
+		h := TimeoutMiddleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); !ok {
+				t.Error("request context has no deadline")
+			}
+			w.Header().Set("X-Test", "1")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("created"))
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/public/v1/order", nil))
+
+		if rec.Code != http.StatusCreated || rec.Body.String() != "created" || rec.Header().Get("X-Test") != "1" {
+			t.Fatalf("got %d %q X-Test=%q, want 201 \"created\" X-Test=1", rec.Code, rec.Body.String(), rec.Header().Get("X-Test"))
+		}
+	})
+
+	t.Run("zero disables it", func(t *testing.T) {
+		h := TimeoutMiddleware(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); ok {
+				t.Error("request context has a deadline, want none")
+			}
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/public/v1/order", nil))
+	})
+}
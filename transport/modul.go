@@ -2,16 +2,21 @@ package transport
 
 import (
 	"encoding/json"
+	goerrors "errors"
 	"net/http"
 
 	"github.com/muhammadheryan/e-commerce/constant"
 	"github.com/muhammadheryan/e-commerce/utils/errors"
 )
 
+// body is the envelope every response is written in. Data is always present
+// (null on errors) so clients can rely on the same shape for both branches.
+// Details is only set on errors that explain their cause.
 type body struct {
 	Code    string      `json:"code"`
 	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
+	Data    interface{} `json:"data"`
+	Details interface{} `json:"details,omitempty"`
 }
 
 func writeJson(w http.ResponseWriter, statusCode int, data interface{}) {
@@ -21,8 +26,10 @@ func writeJson(w http.ResponseWriter, statusCode int, data interface{}) {
 }
 
 func writeError(w http.ResponseWriter, err error) {
-	customError, ok := err.(errors.CustomError)
-	if !ok {
+	// unknown errors, and custom errors of a type missing from the constant
+	// maps, are reported as internal errors with a 500
+	var customError errors.CustomError
+	if !goerrors.As(err, &customError) || customError.ErrorHTTPCode() == 0 {
 		customError = errors.SetCustomError(constant.ErrInternal)
 	}
 
@@ -30,6 +37,10 @@ func writeError(w http.ResponseWriter, err error) {
 		Code:    customError.ErrorCode(),
 		Message: customError.Error(),
 	}
+	var detailed *errors.DetailedError
+	if goerrors.As(err, &detailed) {
+		data.Details = detailed.Details()
+	}
 	writeJson(w, customError.ErrorHTTPCode(), data)
 }
 
@@ -40,3 +51,16 @@ func writeSuccess(w http.ResponseWriter, data interface{}) {
 		Data:    data,
 	})
 }
+
+// notFoundHandler and methodNotAllowedHandler keep router-level failures in the envelope
+func notFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, errors.SetCustomError(constant.ErrNotFound))
+	})
+}
+
+func methodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, errors.SetCustomError(constant.ErrMethodNotAllowed))
+	})
+}
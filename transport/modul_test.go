@@ -0,0 +1,123 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+	"github.com/muhammadheryan/e-commerce/utils/errors"
+)
+
+// decodeEnvelope decodes into a raw map so a missing "data" key is caught
+func decodeEnvelope(t *testing.T, rec *httptest.ResponseRecorder) map[string]json.RawMessage {
+	t.Helper()
+	var env map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+		t.Fatalf("decode body %q: %v", rec.Body.String(), err)
+	}
+	for _, k := range []string{"code", "message", "data"} {
+		if _, ok := env[k]; !ok {
+			t.Fatalf("envelope missing %q: %s", k, rec.Body.String())
+		}
+	}
+	return env
+}
+
+func TestWriteSuccess_Envelope(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeSuccess(rec, map[string]int{"id": 7})
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	env := decodeEnvelope(t, rec)
+	if string(env["code"]) != `"0000"` || string(env["message"]) != `"success"` {
+		t.Errorf("unexpected envelope: %s", rec.Body.String())
+	}
+	if string(env["data"]) != `{"id":7}` {
+		t.Errorf("data = %s, want {\"id\":7}", env["data"])
+	}
+}
+
+func TestWriteError_Envelope(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		errCode constant.ErrorType
+	}{
+		{"insufficient stock", errors.SetCustomError(constant.ErrInsufficientStock), constant.ErrInsufficientStock},
+		{"not found", errors.SetCustomError(constant.ErrNotFound), constant.ErrNotFound},
+		{"unauthorize", errors.SetCustomError(constant.ErrUnauthorize), constant.ErrUnauthorize},
+		{"wrapped custom error", fmt.Errorf("load: %w", errors.SetCustomError(constant.ErrInvalidOrderStatus)), constant.ErrInvalidOrderStatus},
+		{"internal", errors.SetCustomError(constant.ErrInternal), constant.ErrInternal},
+		{"invalid request", errors.SetCustomError(constant.ErrInvalidRequest), constant.ErrInvalidRequest},
+		{"plain error", fmt.Errorf("boom"), constant.ErrInternal},
+		{"unmapped error type", errors.SetCustomError(constant.ErrorType(9999)), constant.ErrInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeError(rec, tt.err)
+
+			if rec.Code != constant.ErrorTypeHTTPCode[tt.errCode] {
+				t.Fatalf("status = %d, want %d", rec.Code, constant.ErrorTypeHTTPCode[tt.errCode])
+			}
+			env := decodeEnvelope(t, rec)
+			if string(env["code"]) != `"`+constant.ErrorTypeCode[tt.errCode]+`"` {
+				t.Errorf("code = %s, want %q", env["code"], constant.ErrorTypeCode[tt.errCode])
+			}
+			if string(env["message"]) != `"`+constant.ErrorTypeMessage[tt.errCode]+`"` {
+				t.Errorf("message = %s, want %q", env["message"], constant.ErrorTypeMessage[tt.errCode])
+			}
+			if string(env["data"]) != "null" {
+				t.Errorf("data = %s, want null", env["data"])
+			}
+		})
+	}
+}
+
+func TestWriteError_StatusCode(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"unauthorize is 401", errors.SetCustomError(constant.ErrUnauthorize), http.StatusUnauthorized},
+		{"insufficient stock is 400", errors.SetCustomError(constant.ErrInsufficientStock), http.StatusBadRequest},
+		{"internal is 500", errors.SetCustomError(constant.ErrInternal), http.StatusInternalServerError},
+		{"wrapped unauthorize is 401", fmt.Errorf("auth: %w", errors.SetCustomError(constant.ErrUnauthorize)), http.StatusUnauthorized},
+		{"unknown error is 500", fmt.Errorf("boom"), http.StatusInternalServerError},
+		{"unmapped error type is 500", errors.SetCustomError(constant.ErrorType(9999)), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeError(rec, tt.err)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestInternalMiddleware_ForbiddenEnvelope(t *testing.T) {
+	h := InternalMiddleware("key")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeSuccess(w, nil)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/internal/v1/config", nil))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	env := decodeEnvelope(t, rec)
+	if string(env["code"]) != `"`+constant.ErrorTypeCode[constant.ErrForbidden]+`"` {
+		t.Errorf("code = %s, want %q", env["code"], constant.ErrorTypeCode[constant.ErrForbidden])
+	}
+}
@@ -0,0 +1,42 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// setPaginationLinks adds an RFC 5988 Link header with the next, prev and last
+// pages of a paginated list, for clients that follow links instead of reading
+// the envelope. The links keep the request's path and query and are relative
+// to it, so they stay valid behind a proxy rewriting the host.
+func setPaginationLinks(w http.ResponseWriter, r *http.Request, page, perPage int, total int64) {
+	if page < 1 || perPage < 1 {
+		return
+	}
+	last := int((total + int64(perPage) - 1) / int64(perPage))
+	if last < 1 {
+		last = 1
+	}
+
+	var links []string
+	if page < last {
+		links = append(links, pageLink(r.URL, page+1, perPage, "next"))
+	}
+	if page > 1 {
+		// a page past the end points back at the last one there is
+		links = append(links, pageLink(r.URL, min(page-1, last), perPage, "prev"))
+	}
+	links = append(links, pageLink(r.URL, last, perPage, "last"))
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+func pageLink(u *url.URL, page, perPage int, rel string) string {
+	qs := u.Query()
+	qs.Set("page", strconv.Itoa(page))
+	qs.Set("per_page", strconv.Itoa(perPage))
+	target := url.URL{Path: u.Path, RawQuery: qs.Encode()}
+	return fmt.Sprintf(`<%s>; rel="%s"`, target.String(), rel)
+}
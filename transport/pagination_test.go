@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apporder "github.com/muhammadheryan/e-commerce/application/order"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	ordermocks "github.com/muhammadheryan/e-commerce/mocks/repository/order"
+	"github.com/muhammadheryan/e-commerce/model"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSetPaginationLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		page    int
+		perPage int
+		total   int64
+		want    string
+	}{
+		{
+			name: "middle page", url: "/public/v1/product?sort=newest&page=2&per_page=10",
+			page: 2, perPage: 10, total: 35,
+			want: `</public/v1/product?page=3&per_page=10&sort=newest>; rel="next", ` +
+				`</public/v1/product?page=1&per_page=10&sort=newest>; rel="prev", ` +
+				`</public/v1/product?page=4&per_page=10&sort=newest>; rel="last"`,
+		},
+		{
+			name: "first page", url: "/public/v1/product",
+			page: 1, perPage: 10, total: 20,
+			want: `</public/v1/product?page=2&per_page=10>; rel="next", </public/v1/product?page=2&per_page=10>; rel="last"`,
+		},
+		{
+			name: "last page", url: "/public/v1/product?page=2",
+			page: 2, perPage: 10, total: 20,
+			want: `</public/v1/product?page=1&per_page=10>; rel="prev", </public/v1/product?page=2&per_page=10>; rel="last"`,
+		},
+		{
+			name: "empty list", url: "/public/v1/order",
+			page: 1, perPage: 10, total: 0,
+			want: `</public/v1/order?page=1&per_page=10>; rel="last"`,
+		},
+		{
+			name: "past the end", url: "/public/v1/order?page=9",
+			page: 9, perPage: 10, total: 15,
+			want: `</public/v1/order?page=2&per_page=10>; rel="prev", </public/v1/order?page=2&per_page=10>; rel="last"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			setPaginationLinks(rec, httptest.NewRequest(http.MethodGet, tt.url, nil), tt.page, tt.perPage, tt.total)
+			if got := rec.Header().Get("Link"); got != tt.want {
+				t.Fatalf("Link = %s\nwant   %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminListUserOrders_LinkHeader(t *testing.T) {
+	cfg := &config.Config{InternalAPIKey: "internal-key"}
+	orderRepo := ordermocks.NewOrderRepository(t)
+	orderRepo.On("ListOrdersByUser", mock.Anything, model.OrderListFilter{UserID: 42}, 3, 5).
+		Return([]model.OrderSummary{{ID: 11}}, int64(25), nil).Once()
+	orderApp := apporder.NewOrderApp(cfg, nil, orderRepo, nil, nil, nil, nil)
+	h := NewTransport(nil, nil, orderApp, nil, nil, nil, cfg, nil, Sweeps{}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/internal/v1/users/42/orders?page=3&per_page=5", nil)
+	req.Header.Set("Authorization", "Bearer internal-key")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	link := rec.Header().Get("Link")
+	for _, want := range []string{
+		`</internal/v1/users/42/orders?page=4&per_page=5>; rel="next"`,
+		`</internal/v1/users/42/orders?page=2&per_page=5>; rel="prev"`,
+		`</internal/v1/users/42/orders?page=5&per_page=5>; rel="last"`,
+	} {
+		if !strings.Contains(link, want) {
+			t.Errorf("Link = %s, missing %s", link, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/muhammadheryan/e-commerce/model"
+)
+
+// Sweep runs one pass of a background cleanup and returns how many rows it handled
+type Sweep func(ctx context.Context) (int, error)
+
+// Sweeps are the background cleanups that can also be run on demand, a nil one is skipped
+type Sweeps struct {
+	// ReleaseExpiredReservations releases cart reservations past their TTL
+	ReleaseExpiredReservations Sweep
+	// ExpireOrders cancels pending orders past their expires_at, releasing their stock
+	ExpireOrders Sweep
+}
+
+// @Summary Run the reservation expiry sweep
+// @Description Run one pass of the expired reservation cleanup now instead of waiting for the next interval: release expired cart reservations, then cancel expired pending orders. Each pass handles one batch, call again while counts come back non-zero
+// @Tags Warehouse
+// @Produce json
+// @Success 200 {object} model.SweepResponse
+// @Failure 500 {object} errors.CustomError
+// @Security InternalAPIKey
+// @Router /internal/v1/reservations/sweep [post]
+func (s *RestHandler) SweepReservations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var res model.SweepResponse
+	if sweep := s.Sweeps.ReleaseExpiredReservations; sweep != nil {
+		released, err := sweep(ctx)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		res.ReleasedReservations = released
+	}
+	if sweep := s.Sweeps.ExpireOrders; sweep != nil {
+		expired, err := sweep(ctx)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		res.ExpiredOrders = expired
+	}
+	writeSuccess(w, res)
+}
@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	appcart "github.com/muhammadheryan/e-commerce/application/cart"
+	apporder "github.com/muhammadheryan/e-commerce/application/order"
+	"github.com/muhammadheryan/e-commerce/cmd/config"
+	"github.com/muhammadheryan/e-commerce/constant"
+	ordermocks "github.com/muhammadheryan/e-commerce/mocks/repository/order"
+	txmocks "github.com/muhammadheryan/e-commerce/mocks/repository/tx"
+	warehousemocks "github.com/muhammadheryan/e-commerce/mocks/repository/warehouse"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSweepReservations(t *testing.T) {
+	cfg := &config.Config{InternalAPIKey: "internal-key"}
+	tests := []struct {
+		name       string
+		mock       func(txRepo *txmocks.TxRepository, warehouseRepo *warehousemocks.WarehouseRepository, orderRepo *ordermocks.OrderRepository)
+		wantStatus int
+		wantData   string
+		wantCancel int64
+	}{
+		{
+			name: "releases expired reservations and expires orders",
+			mock: func(txRepo *txmocks.TxRepository, warehouseRepo *warehousemocks.WarehouseRepository, orderRepo *ordermocks.OrderRepository) {
+				tx := &sqlx.Tx{}
+				txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				warehouseRepo.On("ReleaseExpiredCartReservationsTx", mock.Anything, tx, mock.Anything).Return(3, nil).Once()
+				txRepo.On("CommitTx", tx).Return(nil).Once()
+				orderRepo.On("ListExpiredPendingOrderIDs", mock.Anything, mock.Anything).Return([]uint64{11, 12}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantData:   `{"released_reservations":3,"expired_orders":2}`,
+			wantCancel: 2,
+		},
+		{
+			name: "reservation release fails, orders are left alone",
+			mock: func(txRepo *txmocks.TxRepository, warehouseRepo *warehousemocks.WarehouseRepository, orderRepo *ordermocks.OrderRepository) {
+				tx := &sqlx.Tx{}
+				txRepo.On("BeginTx", mock.Anything).Return(tx, nil).Once()
+				warehouseRepo.On("ReleaseExpiredCartReservationsTx", mock.Anything, tx, mock.Anything).Return(0, errors.New("db error")).Once()
+				txRepo.On("RollbackTx", tx).Return(nil).Once()
+			},
+			wantStatus: constant.ErrorTypeHTTPCode[constant.ErrInternal],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txRepo := txmocks.NewTxRepository(t)
+			warehouseRepo := warehousemocks.NewWarehouseRepository(t)
+			orderRepo := ordermocks.NewOrderRepository(t)
+			tt.mock(txRepo, warehouseRepo, orderRepo)
+
+			orderApp := &countingOrderApp{}
+			sweeps := Sweeps{
+				ReleaseExpiredReservations: appcart.NewReservationSweeper(txRepo, warehouseRepo, 0).Sweep,
+				ExpireOrders:               apporder.NewOrderExpirer(orderApp, orderRepo, 0).Sweep,
+			}
+			h := NewTransport(nil, nil, orderApp, nil, nil, nil, cfg, nil, sweeps, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/internal/v1/reservations/sweep", nil)
+			req.Header.Set("Authorization", "Bearer internal-key")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantData != "" {
+				if env := decodeEnvelope(t, rec); string(env["data"]) != tt.wantData {
+					t.Fatalf("data = %s, want %s", env["data"], tt.wantData)
+				}
+			}
+			if got := orderApp.canceled.Load(); got != tt.wantCancel {
+				t.Fatalf("orders canceled = %d, want %d", got, tt.wantCancel)
+			}
+		})
+	}
+}
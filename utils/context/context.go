@@ -14,3 +14,8 @@ func GetUserID(ctx context.Context) (uint64, bool) {
 	id, ok := v.(uint64)
 	return id, ok
 }
+
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(constant.RequestIDKey).(string)
+	return id
+}
@@ -0,0 +1,27 @@
+package context
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// queryTimeout bounds every database query, zero leaves queries unbounded
+var queryTimeout atomic.Int64
+
+// SetQueryTimeout sets the deadline WithQueryTimeout gives to database queries
+func SetQueryTimeout(d time.Duration) {
+	queryTimeout.Store(int64(d))
+}
+
+// WithQueryTimeout returns ctx bounded by the query timeout, an earlier
+// deadline already on ctx is kept. Repositories wrap the context of each
+// call so a slow or blocked query (a FOR UPDATE waiting on a lock included)
+// is cancelled instead of holding its connection indefinitely.
+func WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	d := time.Duration(queryTimeout.Load())
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,37 @@
+package context_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	utilsContext "github.com/muhammadheryan/e-commerce/utils/context"
+)
+
+func TestWithQueryTimeout(t *testing.T) {
+	t.Cleanup(func() { utilsContext.SetQueryTimeout(0) })
+
+	utilsContext.SetQueryTimeout(0)
+	ctx, cancel := utilsContext.WithQueryTimeout(context.Background())
+	cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatal("deadline set while the query timeout is disabled")
+	}
+
+	utilsContext.SetQueryTimeout(time.Minute)
+	ctx, cancel = utilsContext.WithQueryTimeout(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > time.Minute {
+		t.Fatalf("deadline = %v (set %v), want within a minute", deadline, ok)
+	}
+
+	// an earlier deadline from the caller wins
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Second)
+	defer parentCancel()
+	ctx, cancel = utilsContext.WithQueryTimeout(parent)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) > time.Second {
+		t.Fatalf("deadline = %v, want the caller's one second", deadline)
+	}
+}
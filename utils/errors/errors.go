@@ -1,6 +1,10 @@
 package errors
 
-import "github.com/muhammadheryan/e-commerce/constant"
+import (
+	"errors"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+)
 
 type CustomError struct {
 	errType constant.ErrorType
@@ -23,3 +27,34 @@ func SetCustomError(errorType constant.ErrorType) CustomError {
 		errType: errorType,
 	}
 }
+
+func (c CustomError) ErrorType() constant.ErrorType {
+	return c.errType
+}
+
+// IsType reports whether err, or any error it wraps, is a CustomError of the
+// given type. Use it instead of comparing messages, which break when reworded.
+func IsType(err error, errorType constant.ErrorType) bool {
+	var ce CustomError
+	return errors.As(err, &ce) && ce.errType == errorType
+}
+
+// DetailedError is a CustomError carrying data that tells the caller what caused
+// it, written to the response as details. errors.As still finds the CustomError.
+type DetailedError struct {
+	CustomError
+	details any
+}
+
+// WithDetails builds a DetailedError of the given type
+func WithDetails(errorType constant.ErrorType, details any) *DetailedError {
+	return &DetailedError{CustomError: SetCustomError(errorType), details: details}
+}
+
+func (d *DetailedError) Details() any {
+	return d.details
+}
+
+func (d *DetailedError) Unwrap() error {
+	return d.CustomError
+}
@@ -0,0 +1,44 @@
+package errors_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/constant"
+	cerr "github.com/muhammadheryan/e-commerce/utils/errors"
+)
+
+func TestIsType(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		errType constant.ErrorType
+		want    bool
+	}{
+		{"same type", cerr.SetCustomError(constant.ErrNotFound), constant.ErrNotFound, true},
+		{"wrapped", fmt.Errorf("lookup: %w", cerr.SetCustomError(constant.ErrNotFound)), constant.ErrNotFound, true},
+		{"other type", cerr.SetCustomError(constant.ErrInternal), constant.ErrNotFound, false},
+		{"plain error with the same message", errors.New(cerr.SetCustomError(constant.ErrNotFound).Error()), constant.ErrNotFound, false},
+		{"nil", nil, constant.ErrNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cerr.IsType(tt.err, tt.errType); got != tt.want {
+				t.Fatalf("IsType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithDetails(t *testing.T) {
+	err := fmt.Errorf("deactivate: %w", cerr.WithDetails(constant.ErrWarehouseHasReservedStock, 7))
+
+	if !cerr.IsType(err, constant.ErrWarehouseHasReservedStock) {
+		t.Fatal("detailed error is not found as its CustomError type")
+	}
+	var detailed *cerr.DetailedError
+	if !errors.As(err, &detailed) || detailed.Details() != 7 {
+		t.Fatalf("details not found on %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package money
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when no currency is configured
+const DefaultCurrency = "IDR"
+
+// minorUnits is the number of decimals of each supported ISO 4217 currency.
+// Amounts are stored as DECIMAL(12,2), so currencies with more than 2 decimals
+// can't be represented and aren't listed.
+var minorUnits = map[string]int{
+	"IDR": 2,
+	"USD": 2,
+	"EUR": 2,
+	"SGD": 2,
+	"MYR": 2,
+	"AUD": 2,
+	"GBP": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+}
+
+// MinorUnits returns the number of decimals of the currency and whether it is
+// supported. An empty code is the default currency.
+func MinorUnits(currency string) (int, bool) {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	units, ok := minorUnits[strings.ToUpper(currency)]
+	return units, ok
+}
+
+// Round rounds amount half-up (away from zero) to the minor unit of the
+// currency. The rounding works on the shortest decimal form of amount, so a
+// value written as 1.005 rounds to 1.01 even though the float is slightly
+// below it. An unsupported currency is rounded to 2 decimals.
+func Round(amount float64, currency string) float64 {
+	units, ok := MinorUnits(currency)
+	if !ok {
+		units = 2
+	}
+	return roundHalfUp(amount, units)
+}
+
+func roundHalfUp(amount float64, decimals int) float64 {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return amount
+	}
+	s := strconv.FormatFloat(math.Abs(amount), 'f', -1, 64)
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) <= decimals {
+		return amount
+	}
+
+	n, err := strconv.ParseInt(whole+frac[:decimals], 10, 64)
+	if err != nil {
+		// too large for exact rounding, cents don't matter at that size
+		return amount
+	}
+	if frac[decimals] >= '5' {
+		n++
+	}
+	rounded := float64(n) / math.Pow10(decimals)
+	if amount < 0 {
+		return -rounded
+	}
+	return rounded
+}
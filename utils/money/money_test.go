@@ -0,0 +1,44 @@
+package money_test
+
+import (
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/utils/money"
+)
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   float64
+		currency string
+		want     float64
+	}{
+		{"half a cent rounds up", 0.005, "IDR", 0.01},
+		{"float just below the half still rounds up", 1.005, "USD", 1.01},
+		{"another binary edge", 2.675, "USD", 2.68},
+		{"below the half rounds down", 1.004, "USD", 1},
+		{"negative half rounds away from zero", -0.005, "USD", -0.01},
+		{"already at the minor unit", 10.5, "USD", 10.5},
+		{"zero decimal currency", 100.5, "JPY", 101},
+		{"zero decimal currency rounds down", 100.49, "JPY", 100},
+		{"lower case code", 0.125, "usd", 0.13},
+		{"empty code is the default currency", 1.005, "", 1.01},
+		{"unsupported code rounds to 2 decimals", 1.005, "XXX", 1.01},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := money.Round(tt.amount, tt.currency); got != tt.want {
+				t.Fatalf("Round(%v, %q) = %v, want %v", tt.amount, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinorUnits(t *testing.T) {
+	if units, ok := money.MinorUnits("JPY"); !ok || units != 0 {
+		t.Fatalf("MinorUnits(JPY) = %d, %v, want 0, true", units, ok)
+	}
+	if _, ok := money.MinorUnits("KWD"); ok {
+		t.Fatalf("MinorUnits(KWD) supported, want unsupported since it has 3 decimals")
+	}
+}
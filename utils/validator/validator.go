@@ -1,28 +1,80 @@
 package validatorx
 
 import (
+	goerrors "errors"
+	"reflect"
+	"regexp"
+	"strings"
 	"sync"
 
 	gpvalidator "github.com/go-playground/validator/v10"
 )
 
 var (
-	v   *gpvalidator.Validate
-	mut sync.Mutex
+	v    *gpvalidator.Validate
+	once sync.Once
 )
 
+// idPhonePattern is an Indonesian mobile number, in local (08...) or international (+628...) form
+var idPhonePattern = regexp.MustCompile(`^(\+62|0)8[0-9]{7,12}$`)
+
+// FieldError is one failed rule. Field is the path of the field as the client
+// sent it, e.g. items[0].quantity, Param the rule's argument when it has one.
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// Init sets up the shared validator, it is safe to call from many goroutines
+// and only the first call does any work
 func Init() {
-	mut.Lock()
-	defer mut.Unlock()
-	if v != nil {
-		return
-	}
+	once.Do(setup)
+}
+
+func setup() {
 	v = gpvalidator.New()
+	// report fields by their json name, the one clients know them by
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name, _, _ := strings.Cut(fld.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	_ = v.RegisterValidation("id_phone", validateIDPhone)
 }
 
 func ValidateStruct(s interface{}) error {
-	if v == nil {
-		Init()
-	}
+	// v is only read after once has completed, so a first use racing other
+	// goroutines can't see it half set up
+	Init()
 	return v.Struct(s)
 }
+
+// ValidateStructDetailed validates s like ValidateStruct and lists every rule
+// that failed, nil when s is valid
+func ValidateStructDetailed(s interface{}) []FieldError {
+	err := ValidateStruct(s)
+	if err == nil {
+		return nil
+	}
+	var validationErrs gpvalidator.ValidationErrors
+	if !goerrors.As(err, &validationErrs) {
+		// s isn't a struct, nothing field-level to report
+		return []FieldError{{Rule: "struct"}}
+	}
+
+	res := make([]FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		// the namespace starts with the Go name of the validated struct itself
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		res = append(res, FieldError{Field: field, Rule: fe.Tag(), Param: fe.Param()})
+	}
+	return res
+}
+
+// validateIDPhone backs the id_phone tag
+func validateIDPhone(fl gpvalidator.FieldLevel) bool {
+	return idPhonePattern.MatchString(fl.Field().String())
+}
@@ -0,0 +1,49 @@
+package validatorx
+
+import (
+	"sync"
+	"testing"
+)
+
+// reset forgets the shared validator so the next use sets it up again, a test
+// exercising the first use can't rely on being the first to run
+func reset() {
+	v = nil
+	once = sync.Once{}
+}
+
+// TestValidateStruct_ConcurrentFirstUse is meant for go test -race, every
+// goroutine may be the one setting the validator up
+func TestValidateStruct_ConcurrentFirstUse(t *testing.T) {
+	type request struct {
+		Phone string `json:"phone" validate:"required,id_phone"`
+	}
+
+	reset()
+	t.Cleanup(reset)
+
+	const goroutines = 64
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	start := make(chan struct{})
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(valid bool) {
+			defer wg.Done()
+			<-start
+			req := request{Phone: "081234567890"}
+			if !valid {
+				req.Phone = "not-a-phone"
+			}
+			if err := ValidateStruct(req); (err == nil) != valid {
+				errs <- err
+			}
+		}(i%2 == 0)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("ValidateStruct() error = %v, wrong for its input", err)
+	}
+}
@@ -0,0 +1,100 @@
+package validatorx_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/muhammadheryan/e-commerce/model"
+	validatorx "github.com/muhammadheryan/e-commerce/utils/validator"
+)
+
+func TestValidateStruct_IDPhone(t *testing.T) {
+	type request struct {
+		Phone string `validate:"required,id_phone"`
+	}
+
+	tests := []struct {
+		phone string
+		valid bool
+	}{
+		{"081234567890", true},
+		{"+6281234567890", true},
+		{"0812345678", true},
+		{"081234567", true},
+		{"08123456", false},
+		{"08123456789012", true},
+		{"081234567890123", false},
+		{"6281234567890", false},
+		{"+62 812 3456 7890", false},
+		{"021234567", false},
+		{"0812-3456-7890", false},
+		{"not a phone", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			err := validatorx.ValidateStruct(&request{Phone: tt.phone})
+			if (err == nil) != tt.valid {
+				t.Fatalf("ValidateStruct(%q) error = %v, want valid %v", tt.phone, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateStructDetailed(t *testing.T) {
+	tests := []struct {
+		name string
+		req  any
+		want []validatorx.FieldError
+	}{
+		{
+			name: "valid",
+			req:  &model.OrderRequest{Items: []model.OrderItemRequest{{ProductID: 1, Quantity: 2}}},
+			want: nil,
+		},
+		{
+			name: "nested items are reported by index and json name",
+			req: &model.OrderRequest{Items: []model.OrderItemRequest{
+				{ProductID: 1, Quantity: 2},
+				{ProductID: 0, Quantity: -1},
+			}},
+			want: []validatorx.FieldError{
+				{Field: "items[1].product_id", Rule: "required"},
+				{Field: "items[1].quantity", Rule: "gt", Param: "0"},
+			},
+		},
+		{
+			name: "missing items",
+			req:  &model.OrderRequest{},
+			want: []validatorx.FieldError{{Field: "items", Rule: "required_without", Param: "FromCart"}},
+		},
+		{
+			name: "top level fields",
+			req:  &model.RegisterRequest{Name: "Test User", Email: "not-an-email", Phone: "12345", Password: "abc"},
+			want: []validatorx.FieldError{
+				{Field: "email", Rule: "email"},
+				{Field: "phone", Rule: "id_phone"},
+				{Field: "password", Rule: "min", Param: "6"},
+			},
+		},
+		{
+			name: "shipping address phone",
+			req: &model.OrderRequest{
+				Items: []model.OrderItemRequest{{ProductID: 1, Quantity: 1}},
+				ShippingAddress: &model.ShippingAddress{
+					RecipientName: "Budi", Phone: "021-555-0100", AddressLine: "Jl. Sudirman No. 1",
+					City: "Jakarta", Province: "DKI Jakarta", PostalCode: "10220",
+				},
+			},
+			want: []validatorx.FieldError{{Field: "shipping_address.phone", Rule: "id_phone"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validatorx.ValidateStructDetailed(tt.req)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ValidateStructDetailed() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}